@@ -0,0 +1,71 @@
+package fluo
+
+import (
+	"context"
+	"time"
+)
+
+// WithActionTimeout bounds the execution time of every transition, entry and
+// exit action of the machine. The action's context carries the deadline, and
+// an action still running when it passes fails with a TimeoutError, which is
+// routed like any other action error. The action keeps running in the
+// background until it returns, but no longer holds up event processing.
+func WithActionTimeout(timeout time.Duration) MachineOption {
+	return func(sm *StateMachine) {
+		sm.actionTimeout = timeout
+	}
+}
+
+// runAction executes an action with panic recovery and the machine's action
+// timeout, timing it when statistics are enabled. Recovered panics are handled
+// under the machine's panic policy. A dry run skips the action.
+func (sm *StateMachine) runAction(action ActionFunc) error {
+	if sm.dryRun {
+		return nil
+	}
+	if sm.stats != nil {
+		defer sm.stats.observeAction(time.Now())
+	}
+	var err error
+	if sm.actionTimeout <= 0 {
+		err = safeExecuteAction(action, sm.context)
+	} else {
+		err = sm.runActionWithTimeout(action, sm.actionTimeout)
+	}
+	if err != nil {
+		sm.observers.NotifyStageError(StageAction, err, sm.context)
+		sm.recoveredPanic(err)
+	}
+	return err
+}
+
+// runActionWithTimeout runs an action on its own goroutine under a deadline
+// and gives up once the timeout expires
+func (sm *StateMachine) runActionWithTimeout(action ActionFunc, timeout time.Duration) error {
+	timeoutErr := NewTimeoutError("action", sm.currentState, timeout)
+	// The deadline derives from the event's Go context: deriving it from the
+	// machine context would have Value look itself up through the binding
+	var parent context.Context = sm.context
+	smCtx, isMachineContext := sm.context.(*StateMachineContext)
+	if isMachineContext {
+		parent = smCtx.goContext()
+	}
+	deadline, cancel := context.WithTimeoutCause(parent, timeout, timeoutErr)
+	defer cancel()
+	if isMachineContext {
+		defer smCtx.bindEventContext(deadline)()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- safeExecuteAction(action, sm.context)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-deadline.Done():
+		// The event's own deadline or cancellation may have come first
+		return context.Cause(deadline)
+	}
+}
@@ -0,0 +1,114 @@
+package fluo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// slowAction sleeps for the delay, returning early when the context is done
+func slowAction(delay time.Duration) ActionFunc {
+	return func(ctx Context) error {
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+		}
+		return nil
+	}
+}
+
+func TestActionTimeout_RejectsTransition(t *testing.T) {
+	machine := NewMachine().
+		State("idle").Initial().
+		To("busy").On("start").Do(slowAction(time.Second)).
+		State("busy").
+		Build().CreateInstance(WithActionTimeout(20 * time.Millisecond))
+	_ = machine.Start()
+
+	start := time.Now()
+	result := machine.HandleEvent("start", nil)
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("Expected the action to time out quickly, took %s", elapsed)
+	}
+	AssertEventProcessed(t, result, false)
+	if !IsTimeoutError(result.Error) {
+		t.Errorf("Expected TimeoutError, got %v", result.Error)
+	}
+	AssertState(t, machine, "idle")
+}
+
+func TestActionTimeout_RoutesToErrorState(t *testing.T) {
+	definition := NewMachine().
+		State("idle").Initial().
+		To("busy").On("start").Do(slowAction(time.Second)).OnError("failed").
+		To("ready").On("prepare").OnError("failed").
+		State("busy").
+		State("ready").
+		OnEntry(slowAction(time.Second)).
+		State("failed").
+		Build()
+
+	machine := definition.CreateInstance(WithActionTimeout(20 * time.Millisecond))
+	_ = machine.Start()
+
+	result := machine.HandleEvent("start", nil)
+	AssertState(t, machine, "failed")
+	var timeout *TimeoutError
+	if !errors.As(result.Error, &timeout) || timeout.Kind != "action" {
+		t.Errorf("Expected an action TimeoutError, got %v", result.Error)
+	}
+
+	machine = definition.CreateInstance(WithActionTimeout(20 * time.Millisecond))
+	_ = machine.Start()
+	result = machine.HandleEvent("prepare", nil)
+	AssertState(t, machine, "failed")
+	if !errors.As(result.Error, &timeout) {
+		t.Errorf("Expected the entry action timeout to be routed, got %v", result.Error)
+	}
+}
+
+func TestActionTimeout_ActionSeesDeadline(t *testing.T) {
+	var sawDeadline bool
+	machine := NewMachine().
+		State("idle").Initial().
+		To("busy").On("start").Do(func(ctx Context) error {
+		_, sawDeadline = ctx.Deadline()
+		return nil
+	}).
+		State("busy").
+		Build().CreateInstance(WithActionTimeout(time.Second))
+	_ = machine.Start()
+
+	AssertEventProcessed(t, machine.HandleEvent("start", nil), true)
+	if !sawDeadline {
+		t.Error("Expected the action to see its deadline")
+	}
+	AssertState(t, machine, "busy")
+}
+
+func TestActionTimeout_ActionSeesEventValues(t *testing.T) {
+	type requestKey struct{}
+	var requestID any
+	machine := NewMachine().
+		State("idle").Initial().
+		To("busy").On("start").Do(func(ctx Context) error {
+		requestID = ctx.Value(requestKey{})
+		_ = ctx.Value("missing")
+		return nil
+	}).
+		State("busy").
+		Build().CreateInstance(WithActionTimeout(time.Second))
+	_ = machine.Start()
+
+	eventCtx, cancel := context.WithCancel(context.WithValue(context.Background(), requestKey{}, "req-1"))
+	defer cancel()
+	start := time.Now()
+	AssertEventProcessed(t, machine.SendEventWithContext(eventCtx, "start", nil), true)
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("Expected context lookups not to spin until the timeout, took %s", elapsed)
+	}
+	if requestID != "req-1" {
+		t.Errorf("Expected the action to see the event's values, got %v", requestID)
+	}
+}
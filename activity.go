@@ -0,0 +1,114 @@
+package fluo
+
+import "context"
+
+// ActivityFunc is a long-running "do" activity of a state. It is started on
+// its own goroutine when the state is entered and must return promptly once
+// stop is closed, which happens when the state is exited or the machine stops.
+type ActivityFunc func(ctx Context, stop <-chan struct{}) error
+
+// runningActivity is a started do-activity of a single state
+type runningActivity struct {
+	stateID string
+	stop    chan struct{}
+}
+
+// activityOf returns the do-activity of a state, if any
+func activityOf(state State) ActivityFunc {
+	if atomicState, ok := state.(*AtomicStateImpl); ok {
+		return atomicState.activity
+	}
+	return nil
+}
+
+// startActivity starts the do-activity of a state.
+// The caller must hold the machine mutex.
+func (sm *StateMachine) startActivity(state State) {
+	activity := activityOf(state)
+	if activity == nil {
+		return
+	}
+	sm.cancelActivity(state.ID())
+
+	ra := &runningActivity{
+		stateID: state.ID(),
+		stop:    make(chan struct{}),
+	}
+	sm.activities[ra.stateID] = ra
+
+	go func() {
+		err := safeExecuteActivity(activity, sm.context, ra.stop)
+		sm.finishActivity(ra, err)
+	}()
+}
+
+// cancelActivity signals the do-activity of a state to stop.
+// The caller must hold the machine mutex.
+func (sm *StateMachine) cancelActivity(stateID string) {
+	if ra, ok := sm.activities[stateID]; ok {
+		close(ra.stop)
+		delete(sm.activities, stateID)
+	}
+}
+
+// stopAllActivities signals every running do-activity of the machine to stop.
+// The caller must hold the machine mutex.
+func (sm *StateMachine) stopAllActivities() {
+	for stateID := range sm.activities {
+		sm.cancelActivity(stateID)
+	}
+}
+
+// finishActivity records the end of a do-activity that ran to completion
+func (sm *StateMachine) finishActivity(ra *runningActivity, err error) {
+	if sm.eventLoop != nil && sm.eventLoop.enqueue(&queuedEvent{ctx: context.Background(), activity: ra, err: err}) {
+		return
+	}
+
+	defer sm.lockForEvent("__completion_" + ra.stateID)()
+
+	sm.finishActivityLocked(ra, err)
+}
+
+// finishActivityLocked reports a failed activity or fires the completion
+// transition of its state, unless the activity was cancelled in the meantime.
+// The caller must hold the machine mutex.
+func (sm *StateMachine) finishActivityLocked(ra *runningActivity, err error) *EventResult {
+	if sm.activities[ra.stateID] != ra {
+		return nil
+	}
+	delete(sm.activities, ra.stateID)
+
+	if err != nil {
+		sm.observers.NotifyError(err, sm.context)
+		return nil
+	}
+	if sm.machineState != MachineStateStarted {
+		return nil
+	}
+
+	return sm.fireCompletion(ra.stateID)
+}
+
+// fireCompletion takes the completion transition of a state, if it has one.
+// The caller must hold the machine mutex.
+func (sm *StateMachine) fireCompletion(stateID string) *EventResult {
+	completionEvent := "__completion_" + stateID
+	for _, transition := range sm.transitions[stateID] {
+		if transition.EventName == completionEvent {
+			return sm.processEvent(context.Background(), completionEvent, nil)
+		}
+	}
+	return nil
+}
+
+// safeExecuteActivity runs a do-activity with panic recovery
+func safeExecuteActivity(activity ActivityFunc, ctx Context, stop <-chan struct{}) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = newPanicError("activity", r, ctx)
+		}
+	}()
+
+	return activity(ctx, stop)
+}
@@ -0,0 +1,137 @@
+package fluo
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func observedErrors(observer *TestObserver) int {
+	observer.mutex.RLock()
+	defer observer.mutex.RUnlock()
+	return len(observer.Errors)
+}
+
+func TestActivity_CompletionFiresTransition(t *testing.T) {
+	definition := NewMachine().
+		State("idle").Initial().
+		To("working").On("start").
+		State("working").
+		OnActivity(func(ctx Context, stop <-chan struct{}) error {
+			ctx.Set("result", 42)
+			return nil
+		}).
+		To("done").OnCompletion().
+		State("done").
+		Build()
+
+	machine := definition.CreateInstance()
+	_ = machine.Start()
+	machine.HandleEvent("start", nil)
+
+	waitForState(t, machine, "done", time.Second)
+	if value, _ := machine.Context().Get("result"); value != 42 {
+		t.Errorf("Expected activity to set result, got %v", value)
+	}
+}
+
+func TestActivity_CancelledOnExit(t *testing.T) {
+	stopped := make(chan struct{})
+	definition := NewMachine().
+		State("working").Initial().
+		OnActivity(func(ctx Context, stop <-chan struct{}) error {
+			<-stop
+			close(stopped)
+			return nil
+		}).
+		To("done").OnCompletion().
+		To("cancelled").On("cancel").
+		State("done").
+		State("cancelled").
+		Build()
+
+	machine := definition.CreateInstance()
+	_ = machine.Start()
+	machine.HandleEvent("cancel", nil)
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Expected activity to be stopped on exit")
+	}
+	time.Sleep(10 * time.Millisecond)
+	AssertState(t, machine, "cancelled")
+}
+
+func TestActivity_ErrorReported(t *testing.T) {
+	failure := errors.New("upload failed")
+	definition := NewMachine().
+		State("working").Initial().
+		OnActivity(func(ctx Context, stop <-chan struct{}) error {
+			return failure
+		}).
+		To("done").OnCompletion().
+		State("done").
+		Build()
+
+	machine := definition.CreateInstance()
+	observer := NewTestObserver()
+	machine.AddObserver(observer)
+	_ = machine.Start()
+
+	deadline := time.Now().Add(time.Second)
+	for observedErrors(observer) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if observedErrors(observer) != 1 {
+		t.Fatalf("Expected the activity error to be reported, got %d errors", observedErrors(observer))
+	}
+	AssertState(t, machine, "working")
+}
+
+func TestActivity_StoppedWithMachine(t *testing.T) {
+	var running atomic.Bool
+	definition := NewMachine().
+		State("working").Initial().
+		OnActivity(func(ctx Context, stop <-chan struct{}) error {
+			running.Store(true)
+			<-stop
+			running.Store(false)
+			return nil
+		}).
+		Build()
+
+	machine := definition.CreateInstance()
+	_ = machine.Start()
+	deadline := time.Now().Add(time.Second)
+	for !running.Load() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	_ = machine.Stop()
+	deadline = time.Now().Add(time.Second)
+	for running.Load() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if running.Load() {
+		t.Error("Expected activity to stop with the machine")
+	}
+}
+
+func TestActivity_WithEventLoop(t *testing.T) {
+	definition := NewMachine().
+		State("working").Initial().
+		OnActivity(func(ctx Context, stop <-chan struct{}) error {
+			return nil
+		}).
+		To("done").OnCompletion().
+		State("done").
+		Build()
+
+	machine := definition.CreateInstance(WithEventLoop(0))
+	_ = machine.Start()
+	defer machine.Stop()
+
+	waitForState(t, machine, "done", time.Second)
+}
@@ -0,0 +1,229 @@
+package fluo
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultMailboxSize is the mailbox size used by NewActor when none is given
+const DefaultMailboxSize = 100
+
+// OverflowPolicy decides what an actor does with an event sent to a full mailbox
+type OverflowPolicy int
+
+const (
+	// OverflowBlock makes the sender wait until the mailbox has room or its context is done
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropOldest discards the oldest queued event to make room for the new one
+	OverflowDropOldest
+	// OverflowReject fails the send with ErrCodeMailboxFull
+	OverflowReject
+)
+
+// Actor owns a machine and feeds it events from a bounded mailbox on a single
+// goroutine, so senders never contend for the machine mutex. Events are
+// processed one at a time in arrival order.
+type Actor struct {
+	machine  Machine
+	size     int
+	overflow OverflowPolicy
+
+	mailbox   chan *actorMessage
+	send      sync.Mutex   // Serializes drop-oldest senders
+	senders   atomic.Int64 // Sends in progress, which the drain waits for so no event is stranded
+	stopped   atomic.Bool
+	abandoned atomic.Bool // Stop gave up draining, so queued events are rejected
+	stopOnce  sync.Once
+	quit      chan struct{}
+	done      chan struct{}
+}
+
+// actorMessage is an event waiting in an actor mailbox
+type actorMessage struct {
+	ctx    context.Context
+	name   string
+	data   any
+	result chan *EventResult
+}
+
+// ActorOption configures an Actor
+type ActorOption func(*Actor)
+
+// WithMailboxSize sets the number of events the mailbox holds
+func WithMailboxSize(size int) ActorOption {
+	return func(a *Actor) {
+		if size > 0 {
+			a.size = size
+		}
+	}
+}
+
+// WithOverflowPolicy sets what happens to events sent to a full mailbox
+func WithOverflowPolicy(policy OverflowPolicy) ActorOption {
+	return func(a *Actor) {
+		a.overflow = policy
+	}
+}
+
+// NewActor wraps a machine in an actor and starts its mailbox goroutine. The
+// machine should only receive events through the actor from then on.
+func NewActor(machine Machine, opts ...ActorOption) *Actor {
+	a := &Actor{
+		machine: machine,
+		size:    DefaultMailboxSize,
+		quit:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	a.mailbox = make(chan *actorMessage, a.size)
+
+	go a.run()
+	return a
+}
+
+// Machine returns the machine owned by the actor
+func (a *Actor) Machine() Machine {
+	return a.machine
+}
+
+// Len returns the number of events waiting in the mailbox
+func (a *Actor) Len() int {
+	return len(a.mailbox)
+}
+
+// Send queues an event and returns a channel delivering its result. Under
+// OverflowDropOldest the result of a dropped event reports ErrCodeMailboxFull.
+func (a *Actor) Send(ctx context.Context, eventName string, eventData any) (<-chan *EventResult, error) {
+	message := &actorMessage{
+		ctx:    ctx,
+		name:   eventName,
+		data:   eventData,
+		result: make(chan *EventResult, 1),
+	}
+
+	a.senders.Add(1)
+	defer a.senders.Add(-1)
+
+	if a.stopped.Load() {
+		return nil, NewActorStoppedError("send")
+	}
+
+	switch a.overflow {
+	case OverflowReject:
+		select {
+		case a.mailbox <- message:
+		default:
+			return nil, NewMailboxFullError("send", a.size)
+		}
+	case OverflowDropOldest:
+		a.send.Lock()
+		defer a.send.Unlock()
+		for {
+			select {
+			case a.mailbox <- message:
+				return message.result, nil
+			default:
+			}
+			select {
+			case dropped := <-a.mailbox:
+				dropped.reject(NewMailboxFullError("send", a.size), RejectionCancelled)
+			default:
+			}
+		}
+	default:
+		select {
+		case a.mailbox <- message:
+		case <-a.quit:
+			return nil, NewActorStoppedError("send")
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return message.result, nil
+}
+
+// Ask sends an event and waits for its result
+func (a *Actor) Ask(ctx context.Context, eventName string, eventData any) (*EventResult, error) {
+	results, err := a.Send(ctx, eventName, eventData)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case result := <-results:
+		return result, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Stop stops accepting events and waits until the queued events have been
+// processed. Senders blocked on a full mailbox fail as if the actor was stopped.
+// If ctx ends first, the events still queued are rejected and Stop returns
+// the context error. The machine itself is left running.
+func (a *Actor) Stop(ctx context.Context) error {
+	a.stopOnce.Do(func() {
+		a.stopped.Store(true)
+		close(a.quit)
+	})
+
+	select {
+	case <-a.done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	// Abandon the drain: the loop rejects what is left once its current event finishes
+	a.abandoned.Store(true)
+	<-a.done
+	return ctx.Err()
+}
+
+// run processes mailbox events until Stop, then drains the mailbox
+func (a *Actor) run() {
+	defer close(a.done)
+
+	for {
+		select {
+		case message := <-a.mailbox:
+			a.process(message)
+		case <-a.quit:
+			for {
+				select {
+				case message := <-a.mailbox:
+					a.process(message)
+				default:
+					// A send that saw the actor running may still enqueue
+					if a.senders.Load() == 0 {
+						return
+					}
+					runtime.Gosched()
+				}
+			}
+		}
+	}
+}
+
+// process delivers one mailbox event to the machine
+func (a *Actor) process(message *actorMessage) {
+	if a.abandoned.Load() {
+		message.reject(NewActorStoppedError("drain"), RejectionTerminated)
+		return
+	}
+	if err := message.ctx.Err(); err != nil {
+		message.reject(err, RejectionCancelled)
+		return
+	}
+	message.result <- a.machine.SendEventWithContext(message.ctx, message.name, message.data)
+}
+
+// reject delivers a rejected result for an event the machine never received
+func (m *actorMessage) reject(err error, code RejectionCode) {
+	m.result <- NewEventResult(false, false, "", "").
+		WithRejection(err.Error()).
+		WithRejectionCode(code).
+		WithError(err)
+}
@@ -0,0 +1,220 @@
+package fluo
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// buildActorDefinition counts "tick" events; "slow" blocks until release is closed
+func buildActorDefinition(release chan struct{}) MachineDefinition {
+	builder := NewMachine()
+	builder.State("running").Initial().
+		To("running").On("tick").Internal().
+		Do(func(ctx Context) error {
+			count, _ := ctx.Get("count")
+			n, _ := count.(int)
+			ctx.Set("count", n+1)
+			return nil
+		}).
+		To("running").On("slow").Internal().
+		Do(func(ctx Context) error {
+			<-release
+			return nil
+		})
+	return builder.Build()
+}
+
+func TestActor_ProcessesInOrder(t *testing.T) {
+	machine := buildActorDefinition(nil).CreateInstance()
+	_ = machine.Start()
+	actor := NewActor(machine)
+
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := actor.Ask(context.Background(), "tick", nil); err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if count, _ := machine.Context().Get("count"); count != 20 {
+		t.Errorf("Expected 20 ticks, got %v", count)
+	}
+	if err := actor.Stop(context.Background()); err != nil {
+		t.Errorf("Expected a clean stop, got %v", err)
+	}
+	if _, err := actor.Send(context.Background(), "tick", nil); GetErrorCode(err) != ErrCodeMachineNotStarted {
+		t.Errorf("Expected a stopped actor to refuse events, got %v", err)
+	}
+}
+
+func TestActor_OverflowReject(t *testing.T) {
+	release := make(chan struct{})
+	machine := buildActorDefinition(release).CreateInstance()
+	_ = machine.Start()
+	actor := NewActor(machine, WithMailboxSize(1), WithOverflowPolicy(OverflowReject))
+
+	busy, _ := actor.Send(context.Background(), "slow", nil)
+	waitForMailbox(t, actor, 0)
+	if _, err := actor.Send(context.Background(), "tick", nil); err != nil {
+		t.Fatalf("Expected the mailbox to take one event, got %v", err)
+	}
+	if _, err := actor.Send(context.Background(), "tick", nil); GetErrorCode(err) != ErrCodeMailboxFull {
+		t.Errorf("Expected a full mailbox to reject, got %v", err)
+	}
+
+	close(release)
+	<-busy
+	_ = actor.Stop(context.Background())
+}
+
+func TestActor_OverflowDropOldest(t *testing.T) {
+	release := make(chan struct{})
+	machine := buildActorDefinition(release).CreateInstance()
+	_ = machine.Start()
+	actor := NewActor(machine, WithMailboxSize(1), WithOverflowPolicy(OverflowDropOldest))
+
+	_, _ = actor.Send(context.Background(), "slow", nil)
+	waitForMailbox(t, actor, 0)
+	oldest, _ := actor.Send(context.Background(), "tick", nil)
+	newest, _ := actor.Send(context.Background(), "tick", nil)
+
+	if result := <-oldest; result.Processed || GetErrorCode(result.Error) != ErrCodeMailboxFull {
+		t.Errorf("Expected the oldest event to be dropped, got %+v", result)
+	}
+	close(release)
+	if result := <-newest; !result.Processed {
+		t.Errorf("Expected the newest event to be processed, got %+v", result)
+	}
+	_ = actor.Stop(context.Background())
+}
+
+func TestActor_OverflowBlockHonorsContext(t *testing.T) {
+	release := make(chan struct{})
+	machine := buildActorDefinition(release).CreateInstance()
+	_ = machine.Start()
+	actor := NewActor(machine, WithMailboxSize(1))
+
+	_, _ = actor.Send(context.Background(), "slow", nil)
+	waitForMailbox(t, actor, 0)
+	_, _ = actor.Send(context.Background(), "tick", nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := actor.Send(ctx, "tick", nil); err != context.DeadlineExceeded {
+		t.Errorf("Expected a blocked send to give up with its context, got %v", err)
+	}
+	close(release)
+	_ = actor.Stop(context.Background())
+}
+
+func TestActor_StopDrainsMailbox(t *testing.T) {
+	release := make(chan struct{})
+	machine := buildActorDefinition(release).CreateInstance()
+	_ = machine.Start()
+	actor := NewActor(machine)
+
+	_, _ = actor.Send(context.Background(), "slow", nil)
+	var results []<-chan *EventResult
+	for range 5 {
+		result, _ := actor.Send(context.Background(), "tick", nil)
+		results = append(results, result)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	go func() {
+		// Finish the running event only once Stop has abandoned the drain
+		for {
+			if actor.abandoned.Load() {
+				close(release)
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	if err := actor.Stop(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Expected Stop to give up on the drain, got %v", err)
+	}
+	for _, result := range results {
+		if r := <-result; r.Processed {
+			t.Errorf("Expected abandoned events to be rejected, got %+v", r)
+		}
+	}
+
+	drained := buildActorDefinition(nil).CreateInstance()
+	_ = drained.Start()
+	actor = NewActor(drained)
+	for range 5 {
+		_, _ = actor.Send(context.Background(), "tick", nil)
+	}
+	if err := actor.Stop(context.Background()); err != nil {
+		t.Fatalf("Expected a clean stop, got %v", err)
+	}
+	if count, _ := drained.Context().Get("count"); count != 5 {
+		t.Errorf("Expected the queued events to be drained, got %v", count)
+	}
+}
+
+// waitForMailbox waits until the actor's mailbox holds the given number of events
+func waitForMailbox(t *testing.T, actor *Actor, expected int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for actor.Len() != expected && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if actor.Len() != expected {
+		t.Fatalf("Expected %d queued events, got %d", expected, actor.Len())
+	}
+}
+
+func TestActor_StopReleasesBlockedSenders(t *testing.T) {
+	release := make(chan struct{})
+	machine := buildActorDefinition(release).CreateInstance()
+	_ = machine.Start()
+	actor := NewActor(machine, WithMailboxSize(1))
+
+	_, _ = actor.Send(context.Background(), "slow", nil)
+	waitForMailbox(t, actor, 0)
+	queued, _ := actor.Send(context.Background(), "tick", nil)
+
+	var senders sync.WaitGroup
+	errs := make(chan error, 3)
+	for range 3 {
+		senders.Add(1)
+		go func() {
+			defer senders.Done()
+			_, err := actor.Send(context.Background(), "tick", nil)
+			errs <- err
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- actor.Stop(context.Background()) }()
+	close(release)
+	select {
+	case err := <-stopped:
+		if err != nil {
+			t.Fatalf("Expected Stop to drain, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected Stop not to deadlock with blocked senders")
+	}
+	senders.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil && GetErrorCode(err) != ErrCodeMachineNotStarted {
+			t.Errorf("Expected blocked senders to be queued or stopped, got %v", err)
+		}
+	}
+	if r := <-queued; !r.Processed {
+		t.Errorf("Expected the queued event to be drained, got %+v", r)
+	}
+}
@@ -0,0 +1,235 @@
+package fluo
+
+import (
+	"container/list"
+	"strconv"
+	"time"
+)
+
+// Metadata labels kept on instances of a manager archiving completed instances
+const (
+	// CreatedAtKey records when the manager created an instance, in RFC 3339 format
+	CreatedAtKey = "fluo.created-at"
+	// TransitionCountKey records the number of transitions an instance took
+	TransitionCountKey = "fluo.transitions"
+	// ArchivedAtKey marks the tombstone stored in place of an archived
+	// instance, recording when it was archived in RFC 3339 format
+	ArchivedAtKey = "fluo.archived-at"
+)
+
+// DefaultTombstoneLimit is the number of tombstones a manager keeps in memory
+// unless WithTombstoneLimit is given
+const DefaultTombstoneLimit = 10000
+
+// ArchiveRecord is the compacted record of a completed instance, kept for
+// reporting once the instance is removed from the store
+type ArchiveRecord struct {
+	InstanceID string `json:"instanceId"`
+	// Version is the definition version the instance ran
+	Version    int    `json:"version"`
+	FinalState string `json:"finalState"`
+	// Context holds the archived context keys the instance had set
+	Context map[string]any `json:"context,omitempty"`
+	// CreatedAt is zero for instances created before archiving was enabled
+	CreatedAt   time.Time     `json:"createdAt"`
+	CompletedAt time.Time     `json:"completedAt"`
+	Duration    time.Duration `json:"duration"`
+	// Transitions counts the transitions taken in response to events
+	Transitions int `json:"transitions"`
+}
+
+// ArchiveSink stores the archive records of completed instances
+type ArchiveSink interface {
+	Archive(record *ArchiveRecord) error
+}
+
+// ArchiveFunc adapts a function to the ArchiveSink interface
+type ArchiveFunc func(record *ArchiveRecord) error
+
+// Archive calls the function
+func (f ArchiveFunc) Archive(record *ArchiveRecord) error {
+	return f(record)
+}
+
+// WithArchive archives instances when they complete: after the event or the
+// timed, completion or scheduled transition that reaches a top-level final
+// state, a record with the final state, the given context keys, the run
+// duration and the transition count is written to sink, and the instance is
+// untracked. The ID is kept as a tombstone, so later events, loads and
+// creates fail with ErrCodeInstanceArchived until the ID is deleted. With a
+// store, the instance's snapshot is replaced by a tombstone snapshot carrying
+// only ArchivedAtKey, which survives restarts; RestoreAll reports such
+// snapshots as archived. The manager also keeps the most recent tombstones in
+// memory, see WithTombstoneLimit, which are the only record of archived
+// instances without a store. When the sink fails, the instance stays live and
+// the error is reported in the event result, or to the instance's observers.
+func WithArchive(sink ArchiveSink, contextKeys ...string) ManagerOption {
+	return func(m *Manager) {
+		m.archive = sink
+		m.archiveKeys = contextKeys
+	}
+}
+
+// WithTombstoneLimit bounds the tombstones of archived instances a manager
+// keeps in memory, DefaultTombstoneLimit by default. Beyond the limit the
+// oldest are dropped, after which a manager without a store accepts their IDs
+// again.
+func WithTombstoneLimit(limit int) ManagerOption {
+	return func(m *Manager) {
+		m.tombstoneLimit = limit
+	}
+}
+
+// tombstones is the set of archived instance IDs kept in memory, dropping the
+// oldest beyond its limit. A nil set holds nothing and ignores additions.
+type tombstones struct {
+	limit int
+	order *list.List
+	ids   map[string]*list.Element
+}
+
+// newTombstones creates an empty set holding at most limit IDs
+func newTombstones(limit int) *tombstones {
+	if limit <= 0 {
+		limit = DefaultTombstoneLimit
+	}
+	return &tombstones{limit: limit, order: list.New(), ids: make(map[string]*list.Element)}
+}
+
+// add records an archived ID, dropping the oldest beyond the limit
+func (t *tombstones) add(id string) {
+	if t == nil {
+		return
+	}
+	if _, ok := t.ids[id]; ok {
+		return
+	}
+	t.ids[id] = t.order.PushBack(id)
+	for t.order.Len() > t.limit {
+		delete(t.ids, t.order.Remove(t.order.Front()).(string))
+	}
+}
+
+// has reports whether an ID is recorded as archived
+func (t *tombstones) has(id string) bool {
+	if t == nil {
+		return false
+	}
+	_, ok := t.ids[id]
+	return ok
+}
+
+// remove forgets an archived ID, reporting whether it was recorded
+func (t *tombstones) remove(id string) bool {
+	if t == nil {
+		return false
+	}
+	element, ok := t.ids[id]
+	if ok {
+		t.order.Remove(element)
+		delete(t.ids, id)
+	}
+	return ok
+}
+
+// tombstoneSnapshot is the snapshot stored in place of an archived instance
+func tombstoneSnapshot(id string, archivedAt time.Time) *Snapshot {
+	return &Snapshot{
+		InstanceID: id,
+		Metadata:   map[string]string{ArchivedAtKey: archivedAt.Format(time.RFC3339Nano)},
+	}
+}
+
+// isTombstone reports whether a stored snapshot is the tombstone of an
+// archived instance
+func isTombstone(snapshot *Snapshot) bool {
+	_, ok := snapshot.Metadata[ArchivedAtKey]
+	return ok
+}
+
+// transitionTally counts the event-driven transitions of an instance in its
+// metadata, so the count survives eviction and restore
+type transitionTally struct {
+	BaseObserver
+	sm *StateMachine
+}
+
+// OnTransition increments the instance's transition count
+func (c *transitionTally) OnTransition(from string, to string, event Event, ctx Context) {
+	if event == nil {
+		return
+	}
+	c.sm.labelMutex.Lock()
+	defer c.sm.labelMutex.Unlock()
+	if c.sm.metadata == nil {
+		c.sm.metadata = make(map[string]string)
+	}
+	count, _ := strconv.Atoi(c.sm.metadata[TransitionCountKey])
+	c.sm.metadata[TransitionCountKey] = strconv.Itoa(count + 1)
+}
+
+// withTransitionCount counts the transitions of an instance
+func withTransitionCount() MachineOption {
+	return func(sm *StateMachine) {
+		sm.observers.AddObserver(&transitionTally{sm: sm})
+	}
+}
+
+// archiveCompleted archives an instance that has completed. The sink is
+// written with only the instance mutex held, and the instance is untracked
+// afterwards in the manager's lock order before its tombstone is stored.
+func (m *Manager) archiveCompleted(id string, instance *managedInstance) error {
+	instance.mutex.Lock()
+	if instance.evicted || instance.archived || !instance.machine.IsCompleted() {
+		instance.mutex.Unlock()
+		return nil
+	}
+	record := m.archiveRecord(id, instance)
+	if err := m.archive.Archive(record); err != nil {
+		instance.mutex.Unlock()
+		return err
+	}
+	instance.archived = true
+	instance.mutex.Unlock()
+
+	m.mutex.Lock()
+	instance.mutex.Lock()
+	if !instance.evicted {
+		m.untrack(id, instance)
+	}
+	delete(m.evicted, id)
+	m.archived.add(id)
+	instance.mutex.Unlock()
+	m.mutex.Unlock()
+
+	if m.store != nil {
+		return m.store.Save(id, tombstoneSnapshot(id, record.CompletedAt))
+	}
+	return nil
+}
+
+// archiveRecord builds the archive record of a locked, completed instance
+func (m *Manager) archiveRecord(id string, instance *managedInstance) *ArchiveRecord {
+	machine := instance.machine
+	metadata := machine.Metadata()
+	record := &ArchiveRecord{
+		InstanceID:  id,
+		Version:     instance.version,
+		FinalState:  machine.CurrentState(),
+		CompletedAt: time.Now(),
+	}
+	record.Transitions, _ = strconv.Atoi(metadata[TransitionCountKey])
+	if createdAt, err := time.Parse(time.RFC3339Nano, metadata[CreatedAtKey]); err == nil {
+		record.CreatedAt = createdAt
+		record.Duration = record.CompletedAt.Sub(createdAt)
+	}
+	for _, key := range m.archiveKeys {
+		if value, ok := machine.Context().Get(key); ok {
+			if record.Context == nil {
+				record.Context = make(map[string]any, len(m.archiveKeys))
+			}
+			record.Context[key] = value
+		}
+	}
+	return record
+}
@@ -0,0 +1,180 @@
+package fluo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newArchivingManager(sink ArchiveSink, opts ...ManagerOption) *Manager {
+	builder := NewMachine()
+	builder.State("idle").Initial().
+		To("running").On("start")
+	builder.State("running").
+		To("idle").On("stop").
+		To("done").On("finish")
+	builder.State("done").Final()
+	return NewManager(builder.Build(), append(opts, WithArchive(sink, "customer", "missing"))...)
+}
+
+func TestManager_ArchiveOnCompletion(t *testing.T) {
+	var records []*ArchiveRecord
+	store := NewMemoryStore()
+	manager := newArchivingManager(ArchiveFunc(func(record *ArchiveRecord) error {
+		records = append(records, record)
+		return nil
+	}), WithStore(store))
+
+	machine, _ := manager.Create("order-1")
+	_ = machine.Start()
+	machine.Context().Set("customer", "alice")
+	machine.Context().Set("notes", "not archived")
+	AssertEventProcessed(t, manager.SendEvent("order-1", "start", nil), true)
+	AssertEventProcessed(t, manager.SendEvent("order-1", "stop", nil), true)
+	AssertEventProcessed(t, manager.SendEvent("order-1", "start", nil), true)
+
+	// Evicting and restoring keeps the bookkeeping in the snapshot metadata
+	if err := manager.Evict("order-1"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(records) != 0 {
+		t.Fatalf("Expected no record before completion, got %d", len(records))
+	}
+	AssertEventProcessed(t, manager.SendEvent("order-1", "finish", nil), true)
+
+	if len(records) != 1 {
+		t.Fatalf("Expected one archive record, got %d", len(records))
+	}
+	record := records[0]
+	if record.InstanceID != "order-1" || record.FinalState != "done" || record.Version != 1 {
+		t.Errorf("Unexpected record %+v", record)
+	}
+	if record.Transitions != 4 {
+		t.Errorf("Expected 4 transitions, got %d", record.Transitions)
+	}
+	if len(record.Context) != 1 || record.Context["customer"] != "alice" {
+		t.Errorf("Expected only the archived context keys, got %v", record.Context)
+	}
+	if record.CreatedAt.IsZero() || record.Duration <= 0 || record.CompletedAt.Before(record.CreatedAt) {
+		t.Errorf("Expected the run duration, got %v from %v", record.Duration, record.CreatedAt)
+	}
+
+	if manager.Len() != 0 {
+		t.Errorf("Expected the archived instance to be untracked, got %v", manager.IDs())
+	}
+	if snapshot, stored, _ := store.Load("order-1"); !stored || !isTombstone(snapshot) || snapshot.CurrentState != "" {
+		t.Errorf("Expected the stored instance to be replaced by a tombstone, got %+v", snapshot)
+	}
+	result := manager.SendEvent("order-1", "start", nil)
+	if GetErrorCode(result.Error) != ErrCodeInstanceArchived {
+		t.Errorf("Expected events to hit the tombstone, got %v", result.Error)
+	}
+	if _, err := manager.Create("order-1"); GetErrorCode(err) != ErrCodeInstanceArchived {
+		t.Errorf("Expected an archived ID to be taken, got %v", err)
+	}
+
+	if err := manager.Delete("order-1"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := manager.Create("order-1"); err != nil {
+		t.Errorf("Expected a deleted tombstone to free the ID, got %v", err)
+	}
+}
+
+func TestManager_ArchiveTombstoneSurvivesRestart(t *testing.T) {
+	store := NewMemoryStore()
+	discard := ArchiveFunc(func(*ArchiveRecord) error { return nil })
+	manager := newArchivingManager(discard, WithStore(store))
+
+	machine, _ := manager.Create("order-1")
+	_ = machine.Start()
+	AssertEventProcessed(t, manager.SendEvent("order-1", "start", nil), true)
+	AssertEventProcessed(t, manager.SendEvent("order-1", "finish", nil), true)
+
+	restarted := newArchivingManager(discard, WithStore(store))
+	if _, err := restarted.Create("order-1"); GetErrorCode(err) != ErrCodeInstanceArchived {
+		t.Errorf("Expected the stored tombstone to keep the ID taken, got %v", err)
+	}
+	if _, err := restarted.Load("order-1"); GetErrorCode(err) != ErrCodeInstanceArchived {
+		t.Errorf("Expected loading a stored tombstone to fail, got %v", err)
+	}
+	report, _ := restarted.RestoreAll(context.Background(), store, RestoreOptions{})
+	if len(report.Restored) != 0 || GetErrorCode(report.Failed["order-1"]) != ErrCodeInstanceArchived {
+		t.Errorf("Expected the stored tombstone to be reported as archived, got %+v", report)
+	}
+
+	if err := restarted.Delete("order-1"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := restarted.Create("order-1"); err != nil {
+		t.Errorf("Expected a deleted tombstone to free the ID, got %v", err)
+	}
+}
+
+func TestManager_TombstoneLimit(t *testing.T) {
+	manager := newArchivingManager(ArchiveFunc(func(*ArchiveRecord) error { return nil }), WithTombstoneLimit(2))
+
+	for _, id := range []string{"order-1", "order-2", "order-3"} {
+		machine, _ := manager.Create(id)
+		_ = machine.Start()
+		AssertEventProcessed(t, manager.SendEvent(id, "start", nil), true)
+		AssertEventProcessed(t, manager.SendEvent(id, "finish", nil), true)
+	}
+
+	if _, err := manager.Create("order-1"); err != nil {
+		t.Errorf("Expected the oldest tombstone to be dropped, got %v", err)
+	}
+	for _, id := range []string{"order-2", "order-3"} {
+		if _, err := manager.Create(id); GetErrorCode(err) != ErrCodeInstanceArchived {
+			t.Errorf("Expected the tombstone of %s to be kept, got %v", id, err)
+		}
+	}
+}
+
+func TestManager_ArchiveSinkFailureKeepsInstance(t *testing.T) {
+	sinkErr := errors.New("archive unavailable")
+	manager := newArchivingManager(ArchiveFunc(func(*ArchiveRecord) error { return sinkErr }))
+
+	machine, _ := manager.Create("order-1")
+	_ = machine.Start()
+	AssertEventProcessed(t, manager.SendEvent("order-1", "start", nil), true)
+	result := manager.SendEvent("order-1", "finish", nil)
+	AssertEventProcessed(t, result, true)
+	if !errors.Is(result.Error, sinkErr) {
+		t.Errorf("Expected the sink error in the result, got %v", result.Error)
+	}
+	if _, ok := manager.Get("order-1"); !ok {
+		t.Error("Expected the instance to stay live when archiving fails")
+	}
+}
+
+func TestManager_ArchiveOnTimedCompletion(t *testing.T) {
+	archived := make(chan *ArchiveRecord, 1)
+	builder := NewMachine()
+	builder.State("running").Initial().After(10 * time.Millisecond).To("done")
+	builder.State("done").Final()
+	manager := NewManager(builder.Build(), WithArchive(ArchiveFunc(func(record *ArchiveRecord) error {
+		archived <- record
+		return nil
+	})))
+
+	machine, _ := manager.Create("order-1")
+	_ = machine.Start()
+
+	select {
+	case record := <-archived:
+		if record.FinalState != "done" || record.Transitions != 1 {
+			t.Errorf("Unexpected record %+v", record)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the timed completion to be archived")
+	}
+	deadline := time.Now().Add(time.Second)
+	for manager.Len() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the archived instance to be untracked, got %v", manager.IDs())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,67 @@
+package fluo
+
+import (
+	"maps"
+	"slices"
+	"strings"
+)
+
+// AvailableEventsOption configures an AvailableEvents query
+type AvailableEventsOption func(*availableEventsQuery)
+
+// availableEventsQuery holds the settings of an AvailableEvents query
+type availableEventsQuery struct {
+	evaluateGuards bool
+}
+
+// WithGuardEvaluation runs the guards of candidate transitions so that events
+// whose guards currently fail are left out. Guards run with their timeouts as
+// when dispatching, but panics and timeouts count as failures without being
+// reported.
+func WithGuardEvaluation() AvailableEventsOption {
+	return func(q *availableEventsQuery) {
+		q.evaluateGuards = true
+	}
+}
+
+// AvailableEvents returns the sorted names of the events HandleEvent would
+// currently select a transition for. Each declared event is run through the
+// transition selector without dispatching it, so transitions behind a
+// disabled flag or from a completed region are excluded, and so are events
+// the conflict policy would reject as ambiguous. Timed and completion
+// transitions, which the machine fires itself, are never listed. Guards are
+// not evaluated unless WithGuardEvaluation is given.
+func (sm *StateMachine) AvailableEvents(opts ...AvailableEventsOption) []string {
+	query := &availableEventsQuery{}
+	for _, opt := range opts {
+		opt(query)
+	}
+
+	sm.mutex.Lock()
+	defer sm.unlock()
+
+	if sm.machineState != MachineStateStarted {
+		return []string{}
+	}
+
+	candidates := make(map[string]bool)
+	for _, transitions := range sm.transitions {
+		for _, transition := range transitions {
+			if transition.EventName != "" && !strings.HasPrefix(transition.EventName, "__") {
+				candidates[transition.EventName] = true
+			}
+		}
+	}
+
+	events := make([]string, 0, len(candidates))
+	for _, eventName := range slices.Sorted(maps.Keys(candidates)) {
+		event := NewEvent(eventName, nil)
+		_, end := sm.startProbe(event, !query.evaluateGuards)
+		transition, _, err := sm.findMatchingTransition(eventName, event)
+		end()
+		if err == nil && transition != nil {
+			events = append(events, eventName)
+		}
+	}
+	return events
+}
@@ -0,0 +1,104 @@
+package fluo
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func buildAvailableEventsDefinition() MachineDefinition {
+	hasItems := func(ctx Context) bool {
+		items, _ := ctx.Get("items")
+		return items != nil
+	}
+	builder := NewMachine()
+	builder.State("cart").Initial().
+		After(time.Hour).To("abandoned").
+		To("checkout").On("checkout").When(hasItems).
+		To("cart").On("add").Internal().
+		To("beta").On("preview").IfFlag("beta")
+	builder.State("checkout").
+		To("cart").On("back")
+	builder.State("beta")
+	builder.State("abandoned")
+	return builder.Build()
+}
+
+func TestAvailableEvents(t *testing.T) {
+	machine := buildAvailableEventsDefinition().CreateInstance()
+	if events := machine.AvailableEvents(); len(events) != 0 {
+		t.Errorf("Expected no events before start, got %v", events)
+	}
+	_ = machine.Start()
+	defer func() { _ = machine.Stop() }()
+
+	if events := machine.AvailableEvents(); !slices.Equal(events, []string{"add", "checkout"}) {
+		t.Errorf("Expected guarded events without guard evaluation, got %v", events)
+	}
+	if events := machine.AvailableEvents(WithGuardEvaluation()); !slices.Equal(events, []string{"add"}) {
+		t.Errorf("Expected the failing guard to hide checkout, got %v", events)
+	}
+
+	machine.Context().Set("items", 1)
+	if events := machine.AvailableEvents(WithGuardEvaluation()); !slices.Equal(events, []string{"add", "checkout"}) {
+		t.Errorf("Expected checkout once its guard passes, got %v", events)
+	}
+	machine.HandleEvent("checkout", nil)
+	if events := machine.AvailableEvents(); !slices.Equal(events, []string{"back"}) {
+		t.Errorf("Expected the events of the new state, got %v", events)
+	}
+}
+
+func TestAvailableEvents_ParallelRegions(t *testing.T) {
+	builder := NewMachine()
+	builder.State("start").Initial().
+		To("work").On("begin")
+	parallel := builder.ParallelState("work")
+	parallel.Region("a").State("idle").Initial().
+		To("done").On("tick")
+	parallel.Region("a").FinalState("done").
+		To("idle").On("again")
+	parallel.Region("b").State("idle").Initial().
+		To("done").On("tock")
+	parallel.Region("b").State("done")
+	parallel.RegionReentry(RejectWithReason)
+	parallel.To("start").On("cancel")
+
+	machine := builder.Build().CreateInstance()
+	_ = machine.Start()
+	machine.HandleEvent("begin", nil)
+	if events := machine.AvailableEvents(); !slices.Equal(events, []string{"cancel", "tick", "tock"}) {
+		t.Errorf("Expected region and parallel events, got %v", events)
+	}
+
+	machine.HandleEvent("tick", nil)
+	if events := machine.AvailableEvents(); slices.Contains(events, "again") {
+		t.Errorf("Expected transitions from a completed region to be excluded, got %v", events)
+	}
+	AssertEventProcessed(t, machine.HandleEvent("again", nil), false)
+}
+
+func TestAvailableEvents_FollowsSelector(t *testing.T) {
+	builder := NewMachine()
+	builder.State("idle").Initial().
+		To("a").On("split").
+		To("b").On("split").
+		To("slow").On("wait").When(func(ctx Context) bool {
+		time.Sleep(50 * time.Millisecond)
+		return true
+	}).GuardTimeout(5 * time.Millisecond).
+		To("a").On("go")
+	builder.State("a")
+	builder.State("b")
+	builder.State("slow")
+
+	machine := builder.Build().CreateInstance(WithConflictPolicy(ErrorOnAmbiguity))
+	_ = machine.Start()
+
+	if events := machine.AvailableEvents(); !slices.Equal(events, []string{"go", "wait"}) {
+		t.Errorf("Expected the ambiguous event to be excluded, got %v", events)
+	}
+	if events := machine.AvailableEvents(WithGuardEvaluation()); !slices.Equal(events, []string{"go"}) {
+		t.Errorf("Expected the timed out guard to hide wait, got %v", events)
+	}
+}
@@ -0,0 +1,164 @@
+package fluo
+
+import (
+	"fmt"
+	"testing"
+)
+
+// buildFlatBenchmarkMachine toggles between two top-level states
+func buildFlatBenchmarkMachine(opts ...MachineOption) Machine {
+	builder := NewMachine()
+	builder.State("ping").Initial().
+		To("pong").On("tick")
+	builder.State("pong").
+		To("ping").On("tick")
+	return builder.Build().CreateInstance(opts...)
+}
+
+// buildDeepBenchmarkMachine toggles between two leaves nested in depth composites
+func buildDeepBenchmarkMachine(depth int, opts ...MachineOption) Machine {
+	leaf := "level1"
+	for level := 2; level <= depth; level++ {
+		leaf += fmt.Sprintf(".level%d", level)
+	}
+
+	builder := NewMachine()
+	builder.State("start").Initial().
+		To(leaf + ".ping").On("enter")
+
+	var declare func(level int) func(CompositeScope)
+	declare = func(level int) func(CompositeScope) {
+		return func(c CompositeScope) {
+			if level < depth {
+				c.Composite(fmt.Sprintf("level%d", level+1), declare(level+1))
+				return
+			}
+			c.State("ping").Initial().
+				To("pong").On("tick")
+			c.State("pong").
+				To("ping").On("tick")
+		}
+	}
+	builder.Composite("level1", declare(1))
+	return builder.Build().CreateInstance(opts...)
+}
+
+// buildParallelBenchmarkMachine toggles every region of a parallel state on the same event
+func buildParallelBenchmarkMachine(regions int, opts ...MachineOption) Machine {
+	builder := NewMachine()
+	builder.State("start").Initial().
+		To("running").On("enter")
+
+	parallel := builder.ParallelState("running")
+	for i := 1; i <= regions; i++ {
+		region := parallel.Region(fmt.Sprintf("region%d", i))
+		region.State(fmt.Sprintf("ping%d", i)).Initial().
+			To(fmt.Sprintf("pong%d", i)).On("tick")
+		region.State(fmt.Sprintf("pong%d", i)).
+			To(fmt.Sprintf("ping%d", i)).On("tick")
+	}
+	return builder.Build().CreateInstance(opts...)
+}
+
+// benchmarkTicks sends the tick event to a started machine b.N times
+func benchmarkTicks(b *testing.B, machine Machine, enter string) {
+	if err := machine.Start(); err != nil {
+		b.Fatal(err)
+	}
+	if enter != "" {
+		machine.HandleEvent(enter, nil)
+	}
+	if result := machine.HandleEvent("tick", nil); !result.Processed {
+		b.Fatalf("Expected tick to be processed, got %+v", result)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		machine.HandleEvent("tick", nil)
+	}
+}
+
+func BenchmarkHandleEvent_Flat(b *testing.B) {
+	benchmarkTicks(b, buildFlatBenchmarkMachine(), "")
+}
+
+func BenchmarkHandleEvent_FlatWithStats(b *testing.B) {
+	benchmarkTicks(b, buildFlatBenchmarkMachine(WithStats()), "")
+}
+
+func BenchmarkHandleEvent_Guarded(b *testing.B) {
+	builder := NewMachine()
+	builder.State("ping").Initial().
+		To("pong").On("tick").When(func(ctx Context) bool { return true }).
+		Do(func(ctx Context) error { return nil })
+	builder.State("pong").
+		To("ping").On("tick").When(func(ctx Context) bool { return true }).
+		Do(func(ctx Context) error { return nil })
+	benchmarkTicks(b, builder.Build().CreateInstance(), "")
+}
+
+func BenchmarkHandleEvent_Deep(b *testing.B) {
+	for _, depth := range []int{2, 5, 10} {
+		b.Run(fmt.Sprintf("depth=%d", depth), func(b *testing.B) {
+			benchmarkTicks(b, buildDeepBenchmarkMachine(depth), "enter")
+		})
+	}
+}
+
+func BenchmarkHandleEvent_Parallel(b *testing.B) {
+	for _, regions := range []int{2, 4, 8} {
+		b.Run(fmt.Sprintf("regions=%d", regions), func(b *testing.B) {
+			benchmarkTicks(b, buildParallelBenchmarkMachine(regions), "enter")
+		})
+	}
+}
+
+func BenchmarkHandleEvent_Concurrent(b *testing.B) {
+	machine := buildFlatBenchmarkMachine()
+	if err := machine.Start(); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			machine.HandleEvent("tick", nil)
+		}
+	})
+}
+
+func BenchmarkCreateInstance(b *testing.B) {
+	definition := NewMachine().
+		State("ping").Initial().
+		To("pong").On("tick").
+		State("pong").
+		To("ping").On("tick").
+		Build()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		machine := definition.CreateInstance()
+		_ = machine.Start()
+		machine.HandleEvent("tick", nil)
+	}
+}
+
+func BenchmarkPool(b *testing.B) {
+	pool := NewMachine().
+		State("ping").Initial().
+		To("pong").On("tick").
+		State("pong").
+		To("ping").On("tick").
+		Build().
+		NewPool()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		machine := pool.Get()
+		_ = machine.Start()
+		machine.HandleEvent("tick", nil)
+		pool.Put(machine)
+	}
+}
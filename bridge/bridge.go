@@ -0,0 +1,240 @@
+// Package bridge feeds events from message brokers such as Kafka or NATS into
+// the instances of a fluo.Manager. A Source adapts a broker client, a Decoder
+// maps each message to an instance ID, event name and payload, and a Bridge
+// dispatches the messages one at a time with at-least-once handling: a
+// message is acknowledged only once its event was processed or definitively
+// rejected.
+//
+// An adapter for NATS JetStream lives in the natsbridge module below this
+// directory. Kafka readers adapt the same way: Ack commits the message's
+// offset and Nack returns ErrRedeliveryUnsupported.
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/anggasct/fluo"
+)
+
+// ErrRedeliveryUnsupported is returned by Nack when a source cannot redeliver
+// a single message, such as a Kafka partition reader. The bridge then stops
+// without acknowledging the message, so it is delivered again after a restart.
+var ErrRedeliveryUnsupported = errors.New("source cannot redeliver a single message")
+
+// Message is a message received from a broker
+type Message interface {
+	// Subject returns the subject or topic the message was received on
+	Subject() string
+	// Data returns the message body
+	Data() []byte
+	// Ack acknowledges the message so it is not delivered again
+	Ack() error
+	// Nack asks the broker to deliver the message again
+	Nack() error
+}
+
+// Source receives messages from a broker
+type Source interface {
+	// Receive blocks until a message is available or ctx is done
+	Receive(ctx context.Context) (Message, error)
+}
+
+// Dispatch is an event addressed to a managed instance
+type Dispatch struct {
+	InstanceID string
+	Event      string
+	Payload    any
+}
+
+// Decoder maps a message to the event it carries. A decoding error is
+// permanent: the message is reported to the error handler and acknowledged.
+type Decoder func(msg Message) (Dispatch, error)
+
+// Bridge dispatches the messages of a source into a manager
+type Bridge struct {
+	source  Source
+	manager *fluo.Manager
+	decode  Decoder
+
+	attempts int
+	backoff  time.Duration
+
+	onRejected func(msg Message, dispatch Dispatch, result *fluo.EventResult)
+	onError    func(msg Message, err error)
+}
+
+// Option configures a Bridge
+type Option func(*Bridge)
+
+// WithRetry retries an event whose instance could not be loaded or that
+// failed with a timeout, an action error or a cancelled context, up to
+// attempts times in total, waiting backoff between attempts. The message is nacked once the
+// attempts are used up.
+func WithRetry(attempts int, backoff time.Duration) Option {
+	return func(b *Bridge) {
+		b.attempts = max(attempts, 1)
+		b.backoff = backoff
+	}
+}
+
+// WithRejectionHandler is called for events the instance rejected and for
+// events addressed to an instance that does not exist. Their messages are
+// acknowledged since delivering them again would not change the outcome.
+func WithRejectionHandler(handler func(msg Message, dispatch Dispatch, result *fluo.EventResult)) Option {
+	return func(b *Bridge) {
+		b.onRejected = handler
+	}
+}
+
+// WithErrorHandler is called for messages that could not be decoded,
+// dispatched or acknowledged
+func WithErrorHandler(handler func(msg Message, err error)) Option {
+	return func(b *Bridge) {
+		b.onError = handler
+	}
+}
+
+// New creates a bridge from a source to a manager
+func New(source Source, manager *fluo.Manager, decode Decoder, opts ...Option) *Bridge {
+	b := &Bridge{
+		source:   source,
+		manager:  manager,
+		decode:   decode,
+		attempts: 1,
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// Run dispatches messages until ctx is done, which returns nil, or until the
+// source or a Nack fails, which returns the error. Messages are handled one
+// at a time in the order they are received.
+func (b *Bridge) Run(ctx context.Context) error {
+	for {
+		msg, err := b.source.Receive(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to receive message: %w", err)
+		}
+		if err := b.Handle(ctx, msg); err != nil {
+			return err
+		}
+	}
+}
+
+// Handle dispatches a single message and acknowledges it. It returns an error
+// only when the message had to be redelivered and could not be nacked.
+func (b *Bridge) Handle(ctx context.Context, msg Message) error {
+	dispatch, err := b.decode(msg)
+	if err != nil {
+		b.reportError(msg, fmt.Errorf("failed to decode message on '%s': %w", msg.Subject(), err))
+		b.ack(msg)
+		return nil
+	}
+
+	result, err := b.send(ctx, dispatch)
+	switch {
+	case err != nil:
+		b.reportError(msg, fmt.Errorf("failed to dispatch '%s' to instance '%s': %w", dispatch.Event, dispatch.InstanceID, err))
+		if err := msg.Nack(); err != nil {
+			return fmt.Errorf("failed to nack message on '%s': %w", msg.Subject(), err)
+		}
+	case result.Processed:
+		if result.Error != nil {
+			// Redelivering would apply the event twice, so failures after
+			// processing, such as saving the instance, are only reported
+			b.reportError(msg, fmt.Errorf("event '%s' was processed by instance '%s' with an error: %w", dispatch.Event, dispatch.InstanceID, result.Error))
+		}
+		b.ack(msg)
+	default:
+		if b.onRejected != nil {
+			b.onRejected(msg, dispatch, result)
+		}
+		b.ack(msg)
+	}
+	return nil
+}
+
+// send dispatches an event. It returns an error when the instance could not
+// be loaded or the event failed in a way that may succeed on a later attempt,
+// after retrying as configured.
+func (b *Bridge) send(ctx context.Context, dispatch Dispatch) (*fluo.EventResult, error) {
+	for attempt := 1; ; attempt++ {
+		var failure error
+		if _, err := b.manager.Load(dispatch.InstanceID); err != nil {
+			if fluo.GetErrorCode(err) == fluo.ErrCodeInstanceNotFound {
+				return fluo.NewEventResult(false, false, "", "").
+					WithRejection(fmt.Sprintf("instance '%s' does not exist", dispatch.InstanceID)).
+					WithError(err), nil
+			}
+			failure = err
+		} else {
+			result := b.manager.SendEventWithContext(ctx, dispatch.InstanceID, dispatch.Event, dispatch.Payload)
+			if result.Processed || !retryable(result.Error) {
+				return result, nil
+			}
+			failure = result.Error
+		}
+
+		if attempt >= b.attempts {
+			return nil, failure
+		}
+		select {
+		case <-ctx.Done():
+			return nil, failure
+		case <-time.After(b.backoff):
+		}
+	}
+}
+
+// retryable reports whether an event that was not processed may be accepted
+// when sent again
+func retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	switch fluo.GetErrorCode(err) {
+	case fluo.ErrCodeTimeout, fluo.ErrCodeActionFailed, fluo.ErrCodeGuardPanicked:
+		return true
+	default:
+		return false
+	}
+}
+
+// ack acknowledges a handled message, reporting a failure to the error handler
+func (b *Bridge) ack(msg Message) {
+	if err := msg.Ack(); err != nil {
+		b.reportError(msg, fmt.Errorf("failed to ack message on '%s': %w", msg.Subject(), err))
+	}
+}
+
+func (b *Bridge) reportError(msg Message, err error) {
+	if b.onError != nil {
+		b.onError(msg, err)
+	}
+}
+
+// JSONDecoder decodes message bodies of the form
+// {"instance_id": "...", "event": "...", "payload": ...}
+func JSONDecoder(msg Message) (Dispatch, error) {
+	var body struct {
+		InstanceID string `json:"instance_id"`
+		Event      string `json:"event"`
+		Payload    any    `json:"payload"`
+	}
+	if err := json.Unmarshal(msg.Data(), &body); err != nil {
+		return Dispatch{}, err
+	}
+	if body.InstanceID == "" || body.Event == "" {
+		return Dispatch{}, errors.New("instance_id and event are required")
+	}
+	return Dispatch{InstanceID: body.InstanceID, Event: body.Event, Payload: body.Payload}, nil
+}
@@ -0,0 +1,139 @@
+package bridge_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anggasct/fluo"
+	"github.com/anggasct/fluo/bridge"
+)
+
+type testMessage struct {
+	data  string
+	acked bool
+	naked bool
+}
+
+func (m *testMessage) Subject() string { return "orders" }
+func (m *testMessage) Data() []byte    { return []byte(m.data) }
+func (m *testMessage) Ack() error      { m.acked = true; return nil }
+func (m *testMessage) Nack() error     { m.naked = true; return nil }
+
+type testSource struct {
+	messages chan bridge.Message
+}
+
+func (s *testSource) Receive(ctx context.Context) (bridge.Message, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case msg := <-s.messages:
+		return msg, nil
+	}
+}
+
+type failingStore struct{ *fluo.MemoryStore }
+
+func (s failingStore) Load(id string) (*fluo.Snapshot, bool, error) {
+	if id == "evicted" {
+		return nil, false, errors.New("store unavailable")
+	}
+	return s.MemoryStore.Load(id)
+}
+
+func newTestManager(opts ...fluo.ManagerOption) *fluo.Manager {
+	definition := fluo.NewMachine().
+		State("pending").Initial().
+		To("paid").On("pay").
+		State("paid").
+		Build()
+
+	manager := fluo.NewManager(definition, opts...)
+	instance, _ := manager.Create("order-1")
+	_ = instance.Start()
+	return manager
+}
+
+func TestBridge_Handle(t *testing.T) {
+	manager := newTestManager()
+	var rejected []string
+	var errs []error
+	b := bridge.New(nil, manager, bridge.JSONDecoder,
+		bridge.WithRejectionHandler(func(msg bridge.Message, dispatch bridge.Dispatch, result *fluo.EventResult) {
+			rejected = append(rejected, dispatch.InstanceID+"/"+dispatch.Event)
+		}),
+		bridge.WithErrorHandler(func(msg bridge.Message, err error) {
+			errs = append(errs, err)
+		}))
+	ctx := context.Background()
+
+	accepted := &testMessage{data: `{"instance_id": "order-1", "event": "pay", "payload": {"amount": 10}}`}
+	_ = b.Handle(ctx, accepted)
+	if !accepted.acked {
+		t.Error("Expected a processed event to be acked")
+	}
+	if state := func() string { m, _ := manager.Get("order-1"); return m.CurrentState() }(); state != "paid" {
+		t.Errorf("Expected order-1 to be paid, got %s", state)
+	}
+
+	again := &testMessage{data: `{"instance_id": "order-1", "event": "pay"}`}
+	missing := &testMessage{data: `{"instance_id": "order-2", "event": "pay"}`}
+	_ = b.Handle(ctx, again)
+	_ = b.Handle(ctx, missing)
+	if !again.acked || !missing.acked || len(rejected) != 2 {
+		t.Errorf("Expected rejected events to be acked and reported, got %v", rejected)
+	}
+
+	malformed := &testMessage{data: `not json`}
+	_ = b.Handle(ctx, malformed)
+	if !malformed.acked || len(errs) != 1 {
+		t.Errorf("Expected a malformed message to be acked and reported, got %v", errs)
+	}
+}
+
+func TestBridge_RetriesAndNacks(t *testing.T) {
+	manager := newTestManager(fluo.WithStore(failingStore{fluo.NewMemoryStore()}))
+	var errs []error
+	b := bridge.New(nil, manager, bridge.JSONDecoder,
+		bridge.WithRetry(3, time.Millisecond),
+		bridge.WithErrorHandler(func(msg bridge.Message, err error) {
+			errs = append(errs, err)
+		}))
+
+	msg := &testMessage{data: `{"instance_id": "evicted", "event": "pay"}`}
+	if err := b.Handle(context.Background(), msg); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if msg.acked || !msg.naked || len(errs) != 1 {
+		t.Errorf("Expected a failed dispatch to be nacked, got acked=%v nacked=%v errors=%v", msg.acked, msg.naked, errs)
+	}
+}
+
+func TestBridge_Run(t *testing.T) {
+	manager := newTestManager()
+	source := &testSource{messages: make(chan bridge.Message)}
+	b := bridge.New(source, manager, bridge.JSONDecoder)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	var runErr error
+	go func() {
+		defer wg.Done()
+		runErr = b.Run(ctx)
+	}()
+
+	msg := &testMessage{data: `{"instance_id": "order-1", "event": "pay"}`}
+	source.messages <- msg
+	// An unbuffered channel hands over the next message only after the first was handled
+	source.messages <- &testMessage{data: `{"instance_id": "order-1", "event": "noop"}`}
+	cancel()
+	wg.Wait()
+
+	if runErr != nil || !msg.acked {
+		t.Errorf("Expected the bridge to stop cleanly after acking, got %v, acked=%v", runErr, msg.acked)
+	}
+}
@@ -0,0 +1,55 @@
+// Package natsbridge adapts NATS JetStream consumers to bridge.Source. A
+// JetStream consumer with explicit acknowledgement gives the bridge its
+// at-least-once delivery: nacked messages are redelivered by the server.
+package natsbridge
+
+import (
+	"context"
+
+	"github.com/anggasct/fluo/bridge"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// Source receives the messages of a JetStream consumer
+type Source struct {
+	messages jetstream.MessagesContext
+}
+
+// NewSource starts consuming messages from a JetStream consumer
+func NewSource(consumer jetstream.Consumer, opts ...jetstream.PullMessagesOpt) (*Source, error) {
+	messages, err := consumer.Messages(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Source{messages: messages}, nil
+}
+
+// Receive blocks until a message is available or ctx is done. Once ctx is
+// done the source is stopped and cannot be used again.
+func (s *Source) Receive(ctx context.Context) (bridge.Message, error) {
+	stop := context.AfterFunc(ctx, s.messages.Stop)
+	defer stop()
+
+	msg, err := s.messages.Next()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	return message{msg}, nil
+}
+
+// Stop stops consuming messages
+func (s *Source) Stop() {
+	s.messages.Stop()
+}
+
+// message adapts a JetStream message to bridge.Message
+type message struct {
+	jetstream.Msg
+}
+
+func (m message) Nack() error {
+	return m.Nak()
+}
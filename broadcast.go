@@ -0,0 +1,81 @@
+package fluo
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// WithRegionBroadcast delivers an event handled by a parallel region to every
+// other active region that can handle it, in the same run-to-completion step.
+// Without it the event is consumed by the first region with a matching
+// transition. Only transitions staying within their region are broadcast;
+// the EventResult describes the first region's transition. A region whose
+// transition action fails stays where it is without stopping the others.
+func WithRegionBroadcast() MachineOption {
+	return func(sm *StateMachine) {
+		sm.broadcast = true
+	}
+}
+
+// broadcastToRegions lets the other active regions take their transitions for
+// an event already handled from sourceStateID. The caller must hold the
+// machine mutex.
+func (sm *StateMachine) broadcastToRegions(sourceStateID, eventName string, event Event) {
+	if !sm.broadcast {
+		return
+	}
+	handled := sm.findRegionForState(sourceStateID)
+	if handled == nil {
+		return
+	}
+
+	done := map[Region]bool{handled: true}
+	for {
+		transition, stateID, region := sm.nextBroadcastTransition(eventName, done)
+		if transition == nil {
+			return
+		}
+		done[region] = true
+
+		if smCtx, ok := sm.context.(*StateMachineContext); ok {
+			smCtx.updateTransitionInfo(stateID, sm.currentState, transition.TargetState, event)
+		}
+		if transition.Action != nil {
+			kind := "transition"
+			if transition.Internal {
+				kind = "internal"
+			}
+			sm.observers.NotifyActionExecution(transition.actionLabel(kind), stateID, event, sm.context)
+			if err := sm.runAction(transition.Action); err != nil {
+				sm.observers.NotifyEventRejected(event, fmt.Sprintf("%s in region '%s'", transition.actionFailure(err), region.ID()), sm.context)
+				continue
+			}
+		}
+		if transition.Internal {
+			sm.observers.NotifyInternalTransition(stateID, event, sm.context)
+		} else {
+			sm.takeRegionTransition(stateID, transition.TargetState, event)
+		}
+		sm.entryErr = nil
+	}
+}
+
+// nextBroadcastTransition finds, in state ID order, an active state of a
+// region not yet done with a viable in-region transition for the event
+func (sm *StateMachine) nextBroadcastTransition(eventName string, done map[Region]bool) (*Transition, string, Region) {
+	for _, stateID := range slices.Sorted(maps.Keys(sm.activeStates)) {
+		region := sm.findRegionForState(stateID)
+		if region == nil || done[region] || sm.rejectsFromFinal(stateID) {
+			continue
+		}
+		transition, err := sm.selectTransition(stateID, sm.transitions[stateID], eventName, func(transition Transition) bool {
+			return transition.Internal || sm.findRegionForState(transition.TargetState) == region
+		})
+		if err != nil || transition == nil {
+			continue
+		}
+		return transition, stateID, region
+	}
+	return nil, "", nil
+}
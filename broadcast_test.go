@@ -0,0 +1,124 @@
+package fluo
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+// buildBroadcastMachine builds a parallel state whose three regions all
+// handle the "night" event
+func buildBroadcastMachine(opts ...MachineOption) Machine {
+	builder := NewMachine()
+	builder.State("off").Initial().
+		To("home").On("power_on")
+
+	home := builder.ParallelState("home")
+	security := home.Region("security")
+	security.State("disarmed").Initial().
+		To("home.security.armed").On("night")
+	security.State("armed")
+
+	climate := home.Region("climate")
+	climate.State("comfort").Initial().
+		To("home.climate.eco").On("night").
+		Do(func(ctx Context) error {
+			if failing, _ := ctx.Get("fail_climate"); failing == true {
+				return errors.New("thermostat offline")
+			}
+			return nil
+		})
+	climate.State("eco")
+
+	lighting := home.Region("lighting")
+	lighting.State("on").Initial().
+		To("home.lighting.on").On("night").Internal().
+		Do(func(ctx Context) error {
+			ctx.Set("dimmed", true)
+			return nil
+		})
+
+	return builder.Build().CreateInstance(opts...)
+}
+
+func TestRegionBroadcast_AllRegionsHandleEvent(t *testing.T) {
+	machine := buildBroadcastMachine(WithRegionBroadcast())
+	_ = machine.Start()
+	machine.HandleEvent("power_on", nil)
+
+	AssertEventProcessed(t, machine.HandleEvent("night", nil), true)
+	for _, stateID := range []string{"home.security.armed", "home.climate.eco", "home.lighting.on"} {
+		if !machine.IsStateActive(stateID) {
+			t.Errorf("Expected '%s' to be active, got %v", stateID, machine.GetActiveStates())
+		}
+	}
+	if dimmed, _ := machine.Context().Get("dimmed"); dimmed != true {
+		t.Error("Expected the internal lighting transition to run")
+	}
+}
+
+func TestRegionBroadcast_ReportsRegionTransitions(t *testing.T) {
+	machine := buildBroadcastMachine(WithRegionBroadcast())
+	_ = machine.Start()
+	if result := machine.HandleEvent("power_on", nil); len(result.RegionTransitions) != 0 {
+		t.Errorf("Expected no region transitions when entering the parallel state, got %+v", result.RegionTransitions)
+	}
+
+	result := machine.HandleEvent("night", nil)
+	expected := []RegionTransition{
+		{ParallelState: "home", Region: "climate", From: "home.climate.comfort", To: "home.climate.eco"},
+		{ParallelState: "home", Region: "security", From: "home.security.disarmed", To: "home.security.armed"},
+	}
+	if !slices.Equal(result.RegionTransitions, expected) {
+		t.Errorf("Expected region transitions %+v, got %+v", expected, result.RegionTransitions)
+	}
+}
+
+func TestRegionTransitions_SingleRegion(t *testing.T) {
+	machine := buildBroadcastMachine()
+	_ = machine.Start()
+	machine.HandleEvent("power_on", nil)
+
+	result := machine.HandleEvent("night", nil)
+	expected := []RegionTransition{
+		{ParallelState: "home", Region: "climate", From: "home.climate.comfort", To: "home.climate.eco"},
+	}
+	if !slices.Equal(result.RegionTransitions, expected) {
+		t.Errorf("Expected region transitions %+v, got %+v", expected, result.RegionTransitions)
+	}
+}
+
+func TestRegionBroadcast_DisabledByDefault(t *testing.T) {
+	machine := buildBroadcastMachine()
+	_ = machine.Start()
+	machine.HandleEvent("power_on", nil)
+
+	AssertEventProcessed(t, machine.HandleEvent("night", nil), true)
+	moved := 0
+	for _, stateID := range []string{"home.security.armed", "home.climate.eco"} {
+		if machine.IsStateActive(stateID) {
+			moved++
+		}
+	}
+	if dimmed, _ := machine.Context().Get("dimmed"); dimmed == true {
+		moved++
+	}
+	if moved != 1 {
+		t.Errorf("Expected exactly one region to handle the event, got %d: %v", moved, machine.GetActiveStates())
+	}
+}
+
+func TestRegionBroadcast_FailedActionLeavesRegion(t *testing.T) {
+	machine := buildBroadcastMachine(WithRegionBroadcast())
+	_ = machine.Start()
+	machine.HandleEvent("power_on", nil)
+	machine.Context().Set("fail_climate", true)
+
+	machine.HandleEvent("night", nil)
+	if !machine.IsStateActive("home.climate.comfort") {
+		t.Errorf("Expected the climate region to stay put, got %v", machine.GetActiveStates())
+	}
+	if !machine.IsStateActive("home.security.armed") {
+		t.Errorf("Expected the security region to be armed, got %v", machine.GetActiveStates())
+	}
+}
@@ -1,23 +1,41 @@
 package fluo
 
 import (
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 )
 
 // ChoiceCondition represents a condition and target for a choice pseudostate
 type ChoiceCondition struct {
 	// Guard condition for this choice branch
 	Guard GuardFunc
+	// GuardName names the guard for exports, empty when it is anonymous
+	GuardName string
 	// Target state for this choice branch
 	Target string
+	// TargetFunc computes the target state when the branch is taken, in place of Target
+	TargetFunc TargetFunc
 	// Action to execute for this specific branch
 	Action ActionFunc
 }
 
+// target returns the target state of the branch
+func (c ChoiceCondition) target(ctx Context) string {
+	if c.TargetFunc != nil {
+		return c.TargetFunc(ctx)
+	}
+	return c.Target
+}
+
 // MachineBuilder provides the main entry point for building state machines
 type MachineBuilder interface {
 	State(id string) StateBuilder
+	SubmachineState(id string, definition MachineDefinition) StateBuilder
 	CompositeState(id string) CompositeStateBuilder
 	ParallelState(id string) ParallelStateBuilder
 
@@ -28,7 +46,18 @@ type MachineBuilder interface {
 	History(id string) HistoryBuilder
 	DeepHistory(id string) HistoryBuilder
 
+	// Scoped declaration of hierarchical states
+	Composite(id string, declare func(CompositeScope)) MachineBuilder
+	Parallel(id string, declare func(ParallelScope)) MachineBuilder
+
+	// Extended state variables initialized in every instance
+	Vars(vars ...VarDeclaration) MachineBuilder
+
+	// Validation
+	RequireOtherwise() MachineBuilder
+
 	Build() MachineDefinition
+	BuildE() (MachineDefinition, error)
 }
 
 // StateBuilder handles regular atomic state configuration
@@ -36,13 +65,17 @@ type StateBuilder interface {
 	To(target string) TransitionBuilder
 	ToSelf() TransitionBuilder
 	ToParent(target string) TransitionBuilder
+	After(duration time.Duration) TimedTransitionBuilder
+	Timeout(duration time.Duration) StateBuilder
 
 	OnEntry(action ActionFunc) StateBuilder
 	OnExit(action ActionFunc) StateBuilder
+	OnActivity(activity ActivityFunc) StateBuilder
 	Final() StateBuilder
 	Initial() StateBuilder
 
 	State(id string) StateBuilder
+	SubmachineState(id string, definition MachineDefinition) StateBuilder
 	CompositeState(id string) CompositeStateBuilder
 	ParallelState(id string) ParallelStateBuilder
 	Choice(id string) ChoiceBuilder
@@ -52,6 +85,12 @@ type StateBuilder interface {
 	History(id string) HistoryBuilder
 	DeepHistory(id string) HistoryBuilder
 	Build() MachineDefinition
+	BuildE() (MachineDefinition, error)
+}
+
+// TimedTransitionBuilder selects the target of a timed transition
+type TimedTransitionBuilder interface {
+	To(target string) TransitionBuilder
 }
 
 // TransitionBuilder handles transition configuration with inline actions
@@ -60,12 +99,25 @@ type TransitionBuilder interface {
 	On(event string) TransitionBuilder
 	OnCompletion() TransitionBuilder // Completion transition (automatic when state completes)
 
+	Internal() TransitionBuilder // Internal transition (no exit/entry actions)
+	Local() TransitionBuilder    // Local transition (source is not exited when entering its substate)
+
 	// Conditions
 	When(guard GuardFunc) TransitionBuilder
+	WhenGuard(name string) TransitionBuilder
+	WhenNamed(name string, guard GuardFunc) TransitionBuilder
 	Unless(guard GuardFunc) TransitionBuilder
+	IfFlag(flag string) TransitionBuilder
+	GuardTimeout(timeout time.Duration) TransitionBuilder
+	Priority(priority int) TransitionBuilder
+
+	// Documentation
+	Annotate(description string) TransitionBuilder
+	Tag(tags ...string) TransitionBuilder
 
 	// Actions
 	Do(action ActionFunc) TransitionBuilder
+	DoNamed(name string, action ActionFunc) TransitionBuilder
 	DoIf(condition GuardFunc, action ActionFunc) TransitionBuilder
 	DoAsync(action ActionFunc) TransitionBuilder
 
@@ -80,8 +132,10 @@ type TransitionBuilder interface {
 
 	// Navigation back
 	State(id string) StateBuilder
+	SubmachineState(id string, definition MachineDefinition) StateBuilder
 	CompositeState(id string) CompositeStateBuilder
 	Build() MachineDefinition
+	BuildE() (MachineDefinition, error)
 }
 
 // CompositeStateBuilder handles hierarchical states
@@ -98,6 +152,10 @@ type CompositeStateBuilder interface {
 	History(id string) HistoryBuilder
 	DeepHistory(id string) HistoryBuilder
 
+	// Connection points
+	EntryPoint(id string) ConnectionPointBuilder
+	ExitPoint(id string) ConnectionPointBuilder
+
 	// State actions
 	OnEntry(action ActionFunc) CompositeStateBuilder
 	OnExit(action ActionFunc) CompositeStateBuilder
@@ -109,12 +167,18 @@ type CompositeStateBuilder interface {
 	// Navigation back to parent
 	End() MachineBuilder
 	Build() MachineDefinition
+	BuildE() (MachineDefinition, error)
 }
 
 // ParallelStateBuilder handles parallel regions
 type ParallelStateBuilder interface {
 	// Regions
 	Region(id string) RegionBuilder
+	RegionReentry(policy RegionReentryPolicy) ParallelStateBuilder
+
+	// History of the region configuration
+	History(id string) HistoryBuilder
+	DeepHistory(id string) HistoryBuilder
 
 	// State actions
 	OnEntry(action ActionFunc) ParallelStateBuilder
@@ -127,12 +191,14 @@ type ParallelStateBuilder interface {
 	// Navigation back
 	End() MachineBuilder
 	Build() MachineDefinition
+	BuildE() (MachineDefinition, error)
 }
 
 // RegionBuilder handles parallel region configuration
 type RegionBuilder interface {
 	// States within this region
 	State(id string) StateBuilder
+	FinalState(id string) StateBuilder
 	CompositeState(id string) CompositeStateBuilder
 
 	// Pseudostates within this region
@@ -147,11 +213,13 @@ type RegionBuilder interface {
 	Region(id string) RegionBuilder // Sibling region
 	End() ParallelStateBuilder      // Back to parallel state
 	Build() MachineDefinition
+	BuildE() (MachineDefinition, error)
 }
 
 // ChoiceBuilder handles choice pseudostate with conditions
 type ChoiceBuilder interface {
 	When(condition GuardFunc) ChoiceTransitionBuilder
+	WhenNamed(name string, condition GuardFunc) ChoiceTransitionBuilder
 	Otherwise(target string) ChoiceBuilder
 	Do(action ActionFunc) ChoiceBuilder
 	OnEntry(action ActionFunc) ChoiceBuilder
@@ -159,16 +227,21 @@ type ChoiceBuilder interface {
 	// Navigation back
 	State(id string) StateBuilder
 	Build() MachineDefinition
+	BuildE() (MachineDefinition, error)
 }
 
 // ChoiceTransitionBuilder handles conditional transitions from choice
 type ChoiceTransitionBuilder interface {
 	To(target string) ChoiceBuilder
+	ToFunc(target TargetFunc) ChoiceBuilder
 	Do(action ActionFunc) ChoiceTransitionBuilder
 }
 
-// JunctionBuilder handles simple merge points
+// JunctionBuilder handles merge points with guarded outgoing segments
 type JunctionBuilder interface {
+	When(condition GuardFunc) JunctionTransitionBuilder
+	WhenNamed(name string, condition GuardFunc) JunctionTransitionBuilder
+	Otherwise(target string) JunctionBuilder
 	To(target string) JunctionBuilder
 	Do(action ActionFunc) JunctionBuilder
 	OnEntry(action ActionFunc) JunctionBuilder
@@ -176,22 +249,43 @@ type JunctionBuilder interface {
 	// Navigation back
 	State(id string) StateBuilder
 	Build() MachineDefinition
+	BuildE() (MachineDefinition, error)
+}
+
+// JunctionTransitionBuilder handles guarded segments leaving a junction
+type JunctionTransitionBuilder interface {
+	To(target string) JunctionBuilder
+	Do(action ActionFunc) JunctionTransitionBuilder
+}
+
+// ConnectionPointBuilder handles entry and exit points of a composite state
+type ConnectionPointBuilder interface {
+	To(target string) ConnectionPointBuilder
+	Do(action ActionFunc) ConnectionPointBuilder
+
+	// Navigation back
+	End() CompositeStateBuilder
+	Build() MachineDefinition
+	BuildE() (MachineDefinition, error)
 }
 
 // ForkBuilder handles splitting to parallel targets
 type ForkBuilder interface {
 	To(targets ...string) ForkBuilder
+	ToBranch(label, target string, action ActionFunc) ForkBuilder
 	Do(action ActionFunc) ForkBuilder
 	OnEntry(action ActionFunc) ForkBuilder
 
 	// Navigation back
 	State(id string) StateBuilder
 	Build() MachineDefinition
+	BuildE() (MachineDefinition, error)
 }
 
 // JoinBuilder handles synchronization from multiple sources
 type JoinBuilder interface {
 	From(sources ...string) JoinBuilder
+	FromFork(forkID string) JoinBuilder
 	To(target string) JoinBuilder
 	Do(action ActionFunc) JoinBuilder
 	OnEntry(action ActionFunc) JoinBuilder
@@ -199,6 +293,7 @@ type JoinBuilder interface {
 	// Navigation back
 	State(id string) StateBuilder
 	Build() MachineDefinition
+	BuildE() (MachineDefinition, error)
 }
 
 // HistoryBuilder handles history pseudostates
@@ -207,9 +302,14 @@ type HistoryBuilder interface {
 	Do(action ActionFunc) HistoryBuilder
 	OnEntry(action ActionFunc) HistoryBuilder
 
+	// History retention
+	ExpireAfter(ttl time.Duration) HistoryBuilder
+	ForgetAfter(exits int) HistoryBuilder
+
 	// Navigation back
 	State(id string) StateBuilder
 	Build() MachineDefinition
+	BuildE() (MachineDefinition, error)
 }
 
 // Implementation structs
@@ -222,14 +322,27 @@ type machineBuilderImpl struct {
 	transitions              []Transition
 	built                    bool
 	currentTransitionBuilder *transitionBuilderImpl
+	savedTransitions         map[*Transition]int // Index in transitions of each saved transition builder
+	requireOtherwise         bool                // Every choice must have an unguarded default branch
+	vars                     []VarDeclaration
+
+	// State timeouts and the targets their OnTimeout transitions lead to
+	timeouts       map[string]time.Duration
+	timeoutTargets map[string]string
+
+	// Scoped declaration guard rails
+	scopes     []string // IDs of the scopes whose closures are running, innermost last
+	scopeCalls int      // Depth of declarations issued through a scope
+	scopeErrs  []error  // Declarations that bypassed or outlived their scope
 }
 
 // NewMachine creates a new machine builder with the new fluent API
 func NewMachine() MachineBuilder {
 	return &machineBuilderImpl{
-		machine:     newStateMachine(),
-		states:      make(map[string]State),
-		transitions: make([]Transition, 0),
+		machine:          newStateMachine(),
+		states:           make(map[string]State),
+		transitions:      make([]Transition, 0),
+		savedTransitions: make(map[*Transition]int),
 	}
 }
 
@@ -240,6 +353,7 @@ func NewMachineDefinition() MachineBuilder {
 
 // State creates a new atomic state builder
 func (mb *machineBuilderImpl) State(id string) StateBuilder {
+	mb.checkScope("State", id)
 	mb.saveCurrentTransition()
 
 	var state State
@@ -258,8 +372,31 @@ func (mb *machineBuilderImpl) State(id string) StateBuilder {
 	}
 }
 
+// RequireOtherwise makes building fail for any choice without an Otherwise()
+// or other unguarded default branch, instead of the choice failing at runtime
+// when no guard passes
+func (mb *machineBuilderImpl) RequireOtherwise() MachineBuilder {
+	mb.requireOtherwise = true
+	return mb
+}
+
+// SubmachineState creates a state that runs its own instance of another
+// definition while active. Events are offered to the submachine first, and
+// its reaching a top-level final state fires the state's completion transition.
+func (mb *machineBuilderImpl) SubmachineState(id string, definition MachineDefinition) StateBuilder {
+	mb.checkScope("SubmachineState", id)
+	stateBuilder := mb.State(id)
+	if sb, ok := stateBuilder.(*stateBuilderImpl); ok {
+		if atomicState, ok := sb.currentState.(*AtomicStateImpl); ok {
+			atomicState.WithSubmachine(definition)
+		}
+	}
+	return stateBuilder
+}
+
 // CompositeState creates a new composite state builder
 func (mb *machineBuilderImpl) CompositeState(id string) CompositeStateBuilder {
+	mb.checkScope("CompositeState", id)
 	// Create or get existing composite state
 	var state CompositeState
 	if existingState, exists := mb.states[id]; exists {
@@ -284,6 +421,7 @@ func (mb *machineBuilderImpl) CompositeState(id string) CompositeStateBuilder {
 
 // ParallelState creates a new parallel state builder
 func (mb *machineBuilderImpl) ParallelState(id string) ParallelStateBuilder {
+	mb.checkScope("ParallelState", id)
 	// Create or get existing parallel state
 	var state ParallelState
 	if existingState, exists := mb.states[id]; exists {
@@ -308,6 +446,7 @@ func (mb *machineBuilderImpl) ParallelState(id string) ParallelStateBuilder {
 
 // Choice creates a choice pseudostate builder
 func (mb *machineBuilderImpl) Choice(id string) ChoiceBuilder {
+	mb.checkScope("Choice", id)
 	// Save any current transition builder first
 	mb.saveCurrentTransition()
 
@@ -322,6 +461,7 @@ func (mb *machineBuilderImpl) Choice(id string) ChoiceBuilder {
 
 // Junction creates a junction pseudostate builder
 func (mb *machineBuilderImpl) Junction(id string) JunctionBuilder {
+	mb.checkScope("Junction", id)
 	// Save any current transition builder first
 	mb.saveCurrentTransition()
 
@@ -336,6 +476,7 @@ func (mb *machineBuilderImpl) Junction(id string) JunctionBuilder {
 
 // Fork creates a fork pseudostate builder
 func (mb *machineBuilderImpl) Fork(id string) ForkBuilder {
+	mb.checkScope("Fork", id)
 	// Save any current transition builder first
 	mb.saveCurrentTransition()
 
@@ -350,6 +491,7 @@ func (mb *machineBuilderImpl) Fork(id string) ForkBuilder {
 
 // Join creates a join pseudostate builder
 func (mb *machineBuilderImpl) Join(id string) JoinBuilder {
+	mb.checkScope("Join", id)
 	// Save any current transition builder first
 	mb.saveCurrentTransition()
 
@@ -364,6 +506,7 @@ func (mb *machineBuilderImpl) Join(id string) JoinBuilder {
 
 // History creates a shallow history pseudostate builder
 func (mb *machineBuilderImpl) History(id string) HistoryBuilder {
+	mb.checkScope("History", id)
 	history := NewHistoryState(id, false)
 	mb.states[id] = history
 
@@ -375,6 +518,7 @@ func (mb *machineBuilderImpl) History(id string) HistoryBuilder {
 
 // DeepHistory creates a deep history pseudostate builder
 func (mb *machineBuilderImpl) DeepHistory(id string) HistoryBuilder {
+	mb.checkScope("DeepHistory", id)
 	history := NewHistoryState(id, true)
 	mb.states[id] = history
 
@@ -384,23 +528,41 @@ func (mb *machineBuilderImpl) DeepHistory(id string) HistoryBuilder {
 	}
 }
 
-// Build constructs the final machine definition
+// Build constructs the final machine definition, panicking if the
+// configuration is invalid; use BuildE to handle the error instead
 func (mb *machineBuilderImpl) Build() MachineDefinition {
+	definition, err := mb.BuildE()
+	if err != nil {
+		panic(fmt.Sprintf("Failed to build machine: %v", err))
+	}
+	return definition
+}
+
+// BuildE constructs the final machine definition, returning the validation
+// errors of an invalid configuration
+func (mb *machineBuilderImpl) BuildE() (MachineDefinition, error) {
 	// Complex machine building process - validation, state setup, transition wiring, and pseudostate configuration
 	mb.saveCurrentTransition()
 
+	if len(mb.scopes) > 0 {
+		return nil, NewConfigurationError("builder", fmt.Sprintf("Build() was called inside the closure of '%s'", mb.scopes[len(mb.scopes)-1]))
+	}
+	if len(mb.scopeErrs) > 0 {
+		return nil, errors.Join(mb.scopeErrs...)
+	}
+
 	if mb.built {
-		return &simpleMachineDefinition{
-			machine:        mb.machine,
-			initialState:   mb.initialState,
-			states:         mb.states,
-			transitions:    mb.transitions,
-			joinConditions: mb.machine.joinConditions,
-		}
+		return mb.definition(), nil
 	}
 
+	if err := mb.addTimeoutTransitions(); err != nil {
+		return nil, err
+	}
+	if err := mb.addForkJoinSources(); err != nil {
+		return nil, err
+	}
 	if err := mb.validate(); err != nil {
-		panic(fmt.Sprintf("Failed to build machine: %v", err))
+		return nil, err
 	}
 
 	mb.machine.initialState = mb.initialState
@@ -430,35 +592,108 @@ func (mb *machineBuilderImpl) Build() MachineDefinition {
 
 	mb.built = true
 
-	return &simpleMachineDefinition{
+	return mb.definition(), nil
+}
+
+// definition freezes the built configuration into an immutable definition
+// whose maps are shared by every instance it creates
+func (mb *machineBuilderImpl) definition() *simpleMachineDefinition {
+	definition := &simpleMachineDefinition{
 		machine:        mb.machine,
 		initialState:   mb.initialState,
-		states:         mb.states,
-		transitions:    mb.transitions,
-		joinConditions: mb.machine.joinConditions,
+		states:         make(map[string]State, len(mb.states)),
+		transitions:    append([]Transition(nil), mb.transitions...),
+		bySource:       make(map[string][]Transition),
+		joinConditions: make(map[string][][]string, len(mb.machine.joinConditions)),
+		vars:           slices.Clone(mb.vars),
+	}
+	for id, state := range mb.states {
+		definition.states[id] = state
+	}
+	definition.hash = sync.OnceValue(func() string { return DefinitionHash(definition) })
+	for _, transition := range definition.transitions {
+		definition.bySource[transition.SourceState] = append(definition.bySource[transition.SourceState], transition)
+	}
+	for joinID, combinations := range mb.machine.joinConditions {
+		copiedCombinations := make([][]string, len(combinations))
+		for i, combination := range combinations {
+			copiedCombinations[i] = append([]string(nil), combination...)
+		}
+		definition.joinConditions[joinID] = copiedCombinations
 	}
+	return definition
 }
 
-// validate checks the machine configuration
+// validate checks the machine configuration, joining every problem found
+// into a single error of StateError, TransitionError and ConfigurationError values
 func (mb *machineBuilderImpl) validate() error {
 	if mb.initialState == "" {
-		return fmt.Errorf("no initial state defined")
+		return NewConfigurationError("machine", "no initial state defined")
 	}
 
+	var errs []error
 	if _, exists := mb.states[mb.initialState]; !exists {
-		return fmt.Errorf("initial state '%s' does not exist", mb.initialState)
+		errs = append(errs, NewStateError(ErrCodeStateNotFound, mb.initialState, fmt.Sprintf("initial state '%s' does not exist", mb.initialState)))
 	}
 
 	for _, transition := range mb.transitions {
+		if transition.GuardName != "" && transition.Guard == nil {
+			errs = append(errs, NewConfigurationError("builder", fmt.Sprintf("guard '%s' is not registered", transition.GuardName)))
+		}
 		if _, exists := mb.states[transition.SourceState]; !exists {
-			return fmt.Errorf("source state '%s' does not exist for transition", transition.SourceState)
+			errs = append(errs, NewTransitionError(ErrCodeStateNotFound, transition.SourceState, transition.TargetState, transition.EventName,
+				fmt.Sprintf("source state '%s' does not exist for transition", transition.SourceState)))
 		}
 		if _, exists := mb.states[transition.TargetState]; !exists {
-			return fmt.Errorf("target state '%s' does not exist for transition", transition.TargetState)
+			errs = append(errs, NewTransitionError(ErrCodeStateNotFound, transition.SourceState, transition.TargetState, transition.EventName,
+				fmt.Sprintf("target state '%s' does not exist for transition", transition.TargetState)))
+		}
+		if transition.Internal && transition.TargetState != transition.SourceState {
+			errs = append(errs, NewConfigurationError("builder",
+				fmt.Sprintf("internal transition %s must target its source state", transition.describe())))
+		}
+		if _, exists := mb.states[transition.ErrorState]; transition.ErrorState != "" && !exists {
+			errs = append(errs, NewTransitionError(ErrCodeStateNotFound, transition.SourceState, transition.ErrorState, transition.EventName,
+				fmt.Sprintf("error state '%s' does not exist for transition", transition.ErrorState)))
+		}
+	}
+
+	errs = append(errs, validateVars(mb.vars)...)
+
+	for _, stateID := range slices.Sorted(maps.Keys(mb.states)) {
+		if pseudo, ok := mb.states[stateID].(*PseudoStateImpl); ok && pseudo.Kind() == Junction && !mb.hasDefaultBranch(pseudo) {
+			errs = append(errs, NewStateError(ErrCodeInvalidConfiguration, stateID, fmt.Sprintf("junction '%s' has no else segment; add Otherwise()", stateID)))
+		}
+	}
+
+	if mb.requireOtherwise {
+		for _, stateID := range slices.Sorted(maps.Keys(mb.states)) {
+			if pseudo, ok := mb.states[stateID].(*PseudoStateImpl); ok && pseudo.Kind() == Choice && !mb.hasDefaultBranch(pseudo) {
+				errs = append(errs, NewStateError(ErrCodeInvalidConfiguration, stateID, fmt.Sprintf("choice '%s' has no Otherwise() branch", stateID)))
+			}
 		}
 	}
 
-	return nil
+	return errors.Join(errs...)
+}
+
+// hasDefaultBranch reports whether a choice or junction has a branch taken
+// when no guard passes
+func (mb *machineBuilderImpl) hasDefaultBranch(choice *PseudoStateImpl) bool {
+	if choice.defaultTarget != "" {
+		return true
+	}
+	for _, condition := range choice.choiceConditions {
+		if condition.Guard == nil {
+			return true
+		}
+	}
+	for _, transition := range mb.transitions {
+		if transition.SourceState == choice.ID() && transition.Guard == nil && transition.Flag == "" {
+			return true
+		}
+	}
+	return false
 }
 
 // addTransition adds a transition to the machine
@@ -466,26 +701,131 @@ func (mb *machineBuilderImpl) addTransition(transition Transition) {
 	mb.transitions = append(mb.transitions, transition)
 }
 
-// saveCurrentTransition saves the current transition builder if any
-func (mb *machineBuilderImpl) saveCurrentTransition() {
-	if mb.currentTransitionBuilder != nil {
-		// Check if this transition has already been added to avoid duplicates
-		transition := *mb.currentTransitionBuilder.transition
-		alreadyAdded := false
-
-		for _, existingTransition := range mb.transitions {
-			if existingTransition.SourceState == transition.SourceState &&
-				existingTransition.TargetState == transition.TargetState &&
-				existingTransition.EventName == transition.EventName {
-				alreadyAdded = true
-				break
+// addTimeoutTransitions turns every state timeout and its OnTimeout target
+// into a timed transition
+func (mb *machineBuilderImpl) addTimeoutTransitions() error {
+	var errs []error
+	for _, stateID := range slices.Sorted(maps.Keys(mb.timeoutTargets)) {
+		if mb.timeouts[stateID] <= 0 {
+			errs = append(errs, NewConfigurationError("builder", fmt.Sprintf("state '%s' declares OnTimeout without a positive Timeout", stateID)))
+		}
+	}
+	for _, stateID := range slices.Sorted(maps.Keys(mb.timeouts)) {
+		target, declared := mb.timeoutTargets[stateID]
+		if !declared {
+			errs = append(errs, NewConfigurationError("builder", fmt.Sprintf("state '%s' declares a Timeout without an OnTimeout target", stateID)))
+			continue
+		}
+		if mb.timeouts[stateID] <= 0 {
+			continue
+		}
+		mb.addTransition(Transition{
+			SourceState: stateID,
+			TargetState: target,
+			EventName:   timedEventName(stateID, mb.timeouts[stateID]),
+			After:       mb.timeouts[stateID],
+			Timeout:     true,
+		})
+	}
+	return errors.Join(errs...)
+}
+
+// addForkJoinSources adds the source combinations of every join built with
+// FromFork, derived from the branches of its fork
+func (mb *machineBuilderImpl) addForkJoinSources() error {
+	var errs []error
+	for _, joinID := range slices.Sorted(maps.Keys(mb.states)) {
+		join, ok := mb.states[joinID].(*PseudoStateImpl)
+		if !ok || join.Kind() != Join || join.joinFork == "" {
+			continue
+		}
+		fork, ok := mb.states[join.joinFork].(*PseudoStateImpl)
+		if !ok || fork.Kind() != Fork {
+			errs = append(errs, NewConfigurationError("builder", fmt.Sprintf("join '%s' derives its sources from '%s', which is not a fork", joinID, join.joinFork)))
+			continue
+		}
+
+		combinations := [][]string{nil}
+		for _, target := range fork.forkTargets {
+			sources := mb.branchSources(target, joinID)
+			if len(sources) == 0 {
+				errs = append(errs, NewConfigurationError("builder", fmt.Sprintf("no state of the branch of fork '%s' entering '%s' transitions to join '%s'", join.joinFork, target, joinID)))
+				continue
+			}
+			var extended [][]string
+			for _, combination := range combinations {
+				for _, source := range sources {
+					extended = append(extended, append(slices.Clone(combination), source))
+				}
 			}
+			combinations = extended
 		}
+		for _, combination := range combinations {
+			if len(combination) > 0 {
+				join.SetJoinSources(combination)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
 
-		if !alreadyAdded {
-			mb.addTransition(transition)
+// branchSources returns, sorted, the states reachable from a fork target
+// without passing the join that have a transition to the join
+func (mb *machineBuilderImpl) branchSources(target, joinID string) []string {
+	next := make(map[string][]string)
+	for _, transition := range mb.transitions {
+		next[transition.SourceState] = append(next[transition.SourceState], transition.TargetState)
+	}
+	for stateID, state := range mb.states {
+		if pseudoState, ok := state.(*PseudoStateImpl); ok {
+			for _, condition := range pseudoState.choiceConditions {
+				next[stateID] = append(next[stateID], condition.Target)
+			}
+			if pseudoState.defaultTarget != "" {
+				next[stateID] = append(next[stateID], pseudoState.defaultTarget)
+			}
 		}
+	}
 
+	var sources []string
+	visited := map[string]bool{target: true}
+	queue := []string{target}
+	for len(queue) > 0 {
+		stateID := queue[0]
+		queue = queue[1:]
+		for _, nextID := range next[stateID] {
+			if nextID == joinID {
+				if !slices.Contains(sources, stateID) {
+					sources = append(sources, stateID)
+				}
+				continue
+			}
+			if !visited[nextID] {
+				visited[nextID] = true
+				queue = append(queue, nextID)
+			}
+		}
+	}
+	slices.Sort(sources)
+	return sources
+}
+
+// saveTransition adds the transition of a transition builder to the machine.
+// A builder saved again replaces its earlier copy, so the several chaining
+// paths that save the current builder never duplicate or drop a transition.
+func (mb *machineBuilderImpl) saveTransition(tb *transitionBuilderImpl) {
+	if index, saved := mb.savedTransitions[tb.transition]; saved {
+		mb.transitions[index] = *tb.transition
+		return
+	}
+	mb.savedTransitions[tb.transition] = len(mb.transitions)
+	mb.addTransition(*tb.transition)
+}
+
+// saveCurrentTransition saves the current transition builder if any
+func (mb *machineBuilderImpl) saveCurrentTransition() {
+	if mb.currentTransitionBuilder != nil {
+		mb.saveTransition(mb.currentTransitionBuilder)
 		mb.currentTransitionBuilder = nil
 	}
 }
@@ -513,6 +853,29 @@ func (sb *stateBuilderImpl) To(target string) TransitionBuilder {
 	// Don't save pending transitions here - let the transition builder handle it
 	// This prevents duplicate transitions when chaining
 
+	transition := NewTransition(sb.stateID, sb.resolveTarget(target), "")
+
+	transitionBuilder := &transitionBuilderImpl{
+		machineBuilder: sb.machineBuilder,
+		transition:     transition,
+		sourceBuilder:  sb,
+	}
+
+	// Set this as the current transition builder on the machine builder
+	if mb, ok := sb.machineBuilder.(*machineBuilderImpl); ok {
+		// Save any previous transition builder first
+		if mb.currentTransitionBuilder != nil {
+			mb.saveTransition(mb.currentTransitionBuilder)
+		}
+		mb.currentTransitionBuilder = transitionBuilder
+	}
+
+	return transitionBuilder
+}
+
+// resolveTarget resolves a state name relative to the region or composite
+// state the state is declared in
+func (sb *stateBuilderImpl) resolveTarget(target string) string {
 	// Resolve relative state names based on context
 	resolvedTarget := target
 
@@ -556,24 +919,7 @@ func (sb *stateBuilderImpl) To(target string) TransitionBuilder {
 		}
 	}
 
-	transition := NewTransition(sb.stateID, resolvedTarget, "")
-
-	transitionBuilder := &transitionBuilderImpl{
-		machineBuilder: sb.machineBuilder,
-		transition:     transition,
-		sourceBuilder:  sb,
-	}
-
-	// Set this as the current transition builder on the machine builder
-	if mb, ok := sb.machineBuilder.(*machineBuilderImpl); ok {
-		// Save any previous transition builder first
-		if mb.currentTransitionBuilder != nil {
-			mb.addTransition(*mb.currentTransitionBuilder.transition)
-		}
-		mb.currentTransitionBuilder = transitionBuilder
-	}
-
-	return transitionBuilder
+	return resolvedTarget
 }
 
 // ToSelf creates a self-transition
@@ -596,6 +942,27 @@ func (sb *stateBuilderImpl) ToParent(target string) TransitionBuilder {
 	return sb.To("../" + target)
 }
 
+// After starts a timed transition that fires once the state has been active for the given duration
+func (sb *stateBuilderImpl) After(duration time.Duration) TimedTransitionBuilder {
+	return &timedTransitionBuilderImpl{
+		stateBuilder: sb,
+		after:        duration,
+	}
+}
+
+// Timeout bounds how long the state may stay active. Entering the state arms a
+// timer, exiting it cancels the timer, and exceeding it takes the transition
+// declared with OnTimeout on any of the state's transitions.
+func (sb *stateBuilderImpl) Timeout(duration time.Duration) StateBuilder {
+	if mb, ok := sb.machineBuilder.(*machineBuilderImpl); ok {
+		if mb.timeouts == nil {
+			mb.timeouts = make(map[string]time.Duration)
+		}
+		mb.timeouts[sb.stateID] = duration
+	}
+	return sb
+}
+
 // OnEntry sets entry action for the state
 func (sb *stateBuilderImpl) OnEntry(action ActionFunc) StateBuilder {
 	if atomicState, ok := sb.currentState.(*AtomicStateImpl); ok {
@@ -612,6 +979,15 @@ func (sb *stateBuilderImpl) OnExit(action ActionFunc) StateBuilder {
 	return sb
 }
 
+// OnActivity sets a do-activity started when the state is entered and stopped
+// when it is exited; an activity returning nil fires the state's completion transition
+func (sb *stateBuilderImpl) OnActivity(activity ActivityFunc) StateBuilder {
+	if atomicState, ok := sb.currentState.(*AtomicStateImpl); ok {
+		atomicState.WithActivity(activity)
+	}
+	return sb
+}
+
 // Final marks this state as final
 func (sb *stateBuilderImpl) Final() StateBuilder {
 	if atomicState, ok := sb.currentState.(*AtomicStateImpl); ok {
@@ -661,6 +1037,12 @@ func (sb *stateBuilderImpl) State(id string) StateBuilder {
 	return sb.machineBuilder.State(id)
 }
 
+// SubmachineState navigates to a new submachine state definition
+func (sb *stateBuilderImpl) SubmachineState(id string, definition MachineDefinition) StateBuilder {
+	sb.savePendingTransitions()
+	return sb.machineBuilder.SubmachineState(id, definition)
+}
+
 func (sb *stateBuilderImpl) CompositeState(id string) CompositeStateBuilder {
 	sb.savePendingTransitions()
 	return sb.machineBuilder.CompositeState(id)
@@ -706,11 +1088,17 @@ func (sb *stateBuilderImpl) Build() MachineDefinition {
 	return sb.machineBuilder.Build()
 }
 
+// BuildE finalizes the machine, returning validation errors instead of panicking
+func (sb *stateBuilderImpl) BuildE() (MachineDefinition, error) {
+	sb.savePendingTransitions()
+	return sb.machineBuilder.BuildE()
+}
+
 // savePendingTransitions saves all pending transitions to the machine builder
 func (sb *stateBuilderImpl) savePendingTransitions() {
 	if mb, ok := sb.machineBuilder.(*machineBuilderImpl); ok {
 		for _, transitionBuilder := range sb.pendingTransitions {
-			mb.addTransition(*transitionBuilder.transition)
+			mb.saveTransition(transitionBuilder)
 		}
 	}
 	// Clear pending transitions after saving
@@ -738,17 +1126,81 @@ func (tb *transitionBuilderImpl) OnCompletion() TransitionBuilder {
 	return tb
 }
 
+// Internal makes this an internal transition that stays in the source state
+// without exit/entry actions. Its target must be the source state; Build
+// reports an internal transition declared with another target.
+func (tb *transitionBuilderImpl) Internal() TransitionBuilder {
+	if tb.transition.TargetState == "" {
+		tb.transition.TargetState = tb.transition.SourceState
+	}
+	tb.transition.Internal = true
+	return tb
+}
+
+// Local makes a transition into a substate of its source leave the source
+// active, without running its exit and entry actions again
+func (tb *transitionBuilderImpl) Local() TransitionBuilder {
+	tb.transition.Local = true
+	return tb
+}
+
 // When adds a guard condition
 func (tb *transitionBuilderImpl) When(guard GuardFunc) TransitionBuilder {
 	tb.transition.Guard = guard
+	tb.transition.GuardName = ""
+	return tb
+}
+
+// WhenGuard adds the guard registered under name in the default registry;
+// the name is kept for exports and building fails if it is not registered
+func (tb *transitionBuilderImpl) WhenGuard(name string) TransitionBuilder {
+	tb.transition.Guard, _ = DefaultRegistry.Guard(name)
+	tb.transition.GuardName = name
+	return tb
+}
+
+// WhenNamed adds a guard condition named for observers, explanations, exports
+// and error messages, without registering it
+func (tb *transitionBuilderImpl) WhenNamed(name string, guard GuardFunc) TransitionBuilder {
+	tb.transition.Guard = guard
+	tb.transition.GuardName = name
 	return tb
 }
 
 // Unless adds a negated guard condition
 func (tb *transitionBuilderImpl) Unless(guard GuardFunc) TransitionBuilder {
-	tb.transition.Guard = func(ctx Context) bool {
-		return !guard(ctx)
-	}
+	tb.transition.Guard = Not(guard)
+	tb.transition.GuardName = ""
+	return tb
+}
+
+// Priority ranks this transition against others from the same state for the same event
+func (tb *transitionBuilderImpl) Priority(priority int) TransitionBuilder {
+	tb.transition.Priority = priority
+	return tb
+}
+
+// GuardTimeout bounds the evaluation time of this transition's guard
+func (tb *transitionBuilderImpl) GuardTimeout(timeout time.Duration) TransitionBuilder {
+	tb.transition.GuardTimeout = timeout
+	return tb
+}
+
+// IfFlag gates the transition behind a named feature flag
+func (tb *transitionBuilderImpl) IfFlag(flag string) TransitionBuilder {
+	tb.transition.Flag = flag
+	return tb
+}
+
+// Annotate documents the transition and its guard for generated documentation
+func (tb *transitionBuilderImpl) Annotate(description string) TransitionBuilder {
+	tb.transition.Description = description
+	return tb
+}
+
+// Tag labels the transition for audits and exports
+func (tb *transitionBuilderImpl) Tag(tags ...string) TransitionBuilder {
+	tb.transition.Tags = append(tb.transition.Tags, tags...)
 	return tb
 }
 
@@ -756,6 +1208,15 @@ func (tb *transitionBuilderImpl) Unless(guard GuardFunc) TransitionBuilder {
 func (tb *transitionBuilderImpl) Do(action ActionFunc) TransitionBuilder {
 	// For now, set single action - can be enhanced to support multiple actions
 	tb.transition.Action = action
+	tb.transition.ActionName = ""
+	return tb
+}
+
+// DoNamed adds an action named for observers, exports and error messages,
+// without registering it
+func (tb *transitionBuilderImpl) DoNamed(name string, action ActionFunc) TransitionBuilder {
+	tb.transition.Action = action
+	tb.transition.ActionName = name
 	return tb
 }
 
@@ -781,23 +1242,40 @@ func (tb *transitionBuilderImpl) DoAsync(action ActionFunc) TransitionBuilder {
 	return tb.Do(asyncAction)
 }
 
-// OnError sets error transition target
+// OnError routes the transition to errorState when its action or an entry
+// action of its target fails, instead of rejecting the event. The error state
+// is resolved relative to the source state like the target of To.
 func (tb *transitionBuilderImpl) OnError(errorState string) TransitionBuilder {
-	// This can be enhanced to support error handling in transition
+	tb.transition.ErrorState = tb.resolveTarget(errorState)
 	return tb
 }
 
-// OnTimeout sets timeout transition target
+// OnTimeout sets the state the source state moves to once it has been active
+// longer than its Timeout. Building fails if the source declares no timeout.
+// The timeout state is resolved relative to the source state like the target of To.
 func (tb *transitionBuilderImpl) OnTimeout(timeoutState string) TransitionBuilder {
-	// This can be enhanced to support timeout handling
+	if mb, ok := tb.machineBuilder.(*machineBuilderImpl); ok {
+		if mb.timeoutTargets == nil {
+			mb.timeoutTargets = make(map[string]string)
+		}
+		mb.timeoutTargets[tb.transition.SourceState] = tb.resolveTarget(timeoutState)
+	}
 	return tb
 }
 
+// resolveTarget resolves a state name relative to the source state, as To does
+func (tb *transitionBuilderImpl) resolveTarget(target string) string {
+	if sb, ok := tb.sourceBuilder.(*stateBuilderImpl); ok {
+		return sb.resolveTarget(target)
+	}
+	return target
+}
+
 // To creates another transition from the same source state
 func (tb *transitionBuilderImpl) To(target string) TransitionBuilder {
 	// Add current transition to machine
 	if mb, ok := tb.machineBuilder.(*machineBuilderImpl); ok {
-		mb.addTransition(*tb.transition)
+		mb.saveTransition(tb)
 	}
 
 	// Create new transition
@@ -830,16 +1308,24 @@ func (tb *transitionBuilderImpl) ToParent(target string) TransitionBuilder {
 func (tb *transitionBuilderImpl) State(id string) StateBuilder {
 	// Add current transition before switching
 	if mb, ok := tb.machineBuilder.(*machineBuilderImpl); ok {
-		mb.addTransition(*tb.transition)
+		mb.saveTransition(tb)
 	}
 	return tb.machineBuilder.State(id)
 }
 
+// SubmachineState navigates to a new submachine state definition
+func (tb *transitionBuilderImpl) SubmachineState(id string, definition MachineDefinition) StateBuilder {
+	if mb, ok := tb.machineBuilder.(*machineBuilderImpl); ok {
+		mb.saveTransition(tb)
+	}
+	return tb.machineBuilder.SubmachineState(id, definition)
+}
+
 // CompositeState navigates to composite state definition
 func (tb *transitionBuilderImpl) CompositeState(id string) CompositeStateBuilder {
 	// Add current transition before switching
 	if mb, ok := tb.machineBuilder.(*machineBuilderImpl); ok {
-		mb.addTransition(*tb.transition)
+		mb.saveTransition(tb)
 	}
 	return tb.machineBuilder.CompositeState(id)
 }
@@ -848,11 +1334,35 @@ func (tb *transitionBuilderImpl) CompositeState(id string) CompositeStateBuilder
 func (tb *transitionBuilderImpl) Build() MachineDefinition {
 	// Add current transition before building
 	if mb, ok := tb.machineBuilder.(*machineBuilderImpl); ok {
-		mb.addTransition(*tb.transition)
+		mb.saveTransition(tb)
 	}
 	return tb.machineBuilder.Build()
 }
 
+// BuildE finalizes the machine, returning validation errors instead of panicking
+func (tb *transitionBuilderImpl) BuildE() (MachineDefinition, error) {
+	if mb, ok := tb.machineBuilder.(*machineBuilderImpl); ok {
+		mb.saveTransition(tb)
+	}
+	return tb.machineBuilder.BuildE()
+}
+
+// timedTransitionBuilderImpl implements TimedTransitionBuilder
+type timedTransitionBuilderImpl struct {
+	stateBuilder *stateBuilderImpl
+	after        time.Duration
+}
+
+// To sets the target of the timed transition
+func (ttb *timedTransitionBuilderImpl) To(target string) TransitionBuilder {
+	tb := ttb.stateBuilder.To(target)
+	if impl, ok := tb.(*transitionBuilderImpl); ok {
+		impl.transition.EventName = timedEventName(ttb.stateBuilder.stateID, ttb.after)
+		impl.transition.After = ttb.after
+	}
+	return tb
+}
+
 // Placeholder implementations for other builders
 // These will be implemented as needed
 
@@ -887,6 +1397,33 @@ func (csb *compositeStateBuilderImpl) Junction(id string) JunctionBuilder {
 	return csb.machineBuilder.Junction(csb.stateID + "." + id)
 }
 
+// EntryPoint creates a named entry point; transitions targeting it enter the
+// composite state and continue to the entry point's target, which is resolved
+// relative to the composite state
+func (csb *compositeStateBuilderImpl) EntryPoint(id string) ConnectionPointBuilder {
+	return csb.connectionPoint(id, EntryPoint)
+}
+
+// ExitPoint creates a named exit point; transitions from substates targeting
+// it leave the composite state for the exit point's target
+func (csb *compositeStateBuilderImpl) ExitPoint(id string) ConnectionPointBuilder {
+	return csb.connectionPoint(id, ExitPoint)
+}
+
+// connectionPoint creates an entry or exit point pseudostate of the composite state
+func (csb *compositeStateBuilderImpl) connectionPoint(id string, kind PseudoStateKind) ConnectionPointBuilder {
+	point := NewPseudoState(csb.stateID+"."+id, kind)
+	if mb, ok := csb.machineBuilder.(*machineBuilderImpl); ok {
+		mb.saveCurrentTransition()
+		mb.states[point.ID()] = point
+	}
+
+	return &connectionPointBuilderImpl{
+		compositeBuilder: csb,
+		point:            point,
+	}
+}
+
 func (csb *compositeStateBuilderImpl) Fork(id string) ForkBuilder {
 	return csb.machineBuilder.Fork(csb.stateID + "." + id)
 }
@@ -903,13 +1440,21 @@ func (csb *compositeStateBuilderImpl) DeepHistory(id string) HistoryBuilder {
 	return csb.machineBuilder.DeepHistory(csb.stateID + "." + id)
 }
 
+// OnEntry sets the entry action of the composite state, run before its
+// initial substate is entered
 func (csb *compositeStateBuilderImpl) OnEntry(action ActionFunc) CompositeStateBuilder {
-	// Set entry action on composite state
+	if atomic := atomicStateOf(csb.compositeState); atomic != nil {
+		atomic.WithEntryAction(action)
+	}
 	return csb
 }
 
+// OnExit sets the exit action of the composite state, run after its active
+// substate is exited
 func (csb *compositeStateBuilderImpl) OnExit(action ActionFunc) CompositeStateBuilder {
-	// Set exit action on composite state
+	if atomic := atomicStateOf(csb.compositeState); atomic != nil {
+		atomic.WithExitAction(action)
+	}
 	return csb
 }
 
@@ -922,7 +1467,7 @@ func (csb *compositeStateBuilderImpl) To(target string) TransitionBuilder {
 	}
 	if mb, ok := csb.machineBuilder.(*machineBuilderImpl); ok {
 		if mb.currentTransitionBuilder != nil {
-			mb.addTransition(*mb.currentTransitionBuilder.transition)
+			mb.saveTransition(mb.currentTransitionBuilder)
 		}
 		mb.currentTransitionBuilder = tb
 	}
@@ -941,6 +1486,11 @@ func (csb *compositeStateBuilderImpl) Build() MachineDefinition {
 	return csb.machineBuilder.Build()
 }
 
+// BuildE finalizes the machine, returning validation errors instead of panicking
+func (csb *compositeStateBuilderImpl) BuildE() (MachineDefinition, error) {
+	return csb.machineBuilder.BuildE()
+}
+
 // Placeholder implementations for other builders
 type parallelStateBuilderImpl struct {
 	machineBuilder MachineBuilder
@@ -962,11 +1512,42 @@ func (psb *parallelStateBuilderImpl) Region(id string) RegionBuilder {
 	}
 }
 
+// RegionReentry sets what happens when an event targets a region that has
+// already reached its final state
+func (psb *parallelStateBuilderImpl) RegionReentry(policy RegionReentryPolicy) ParallelStateBuilder {
+	if parallel, ok := psb.parallelState.(*ParallelStateImpl); ok {
+		parallel.WithRegionReentry(policy)
+	}
+	return psb
+}
+
+// History creates a shallow history pseudostate of the parallel state; entering
+// it restores the state each region was in when the parallel state was exited
+func (psb *parallelStateBuilderImpl) History(id string) HistoryBuilder {
+	return psb.machineBuilder.History(psb.stateID + "." + id)
+}
+
+// DeepHistory creates a deep history pseudostate of the parallel state;
+// entering it restores the innermost state of every region
+func (psb *parallelStateBuilderImpl) DeepHistory(id string) HistoryBuilder {
+	return psb.machineBuilder.DeepHistory(psb.stateID + "." + id)
+}
+
+// OnEntry sets the entry action of the parallel state, run before its regions
+// are entered
 func (psb *parallelStateBuilderImpl) OnEntry(action ActionFunc) ParallelStateBuilder {
+	if atomic := atomicStateOf(psb.parallelState); atomic != nil {
+		atomic.WithEntryAction(action)
+	}
 	return psb
 }
 
+// OnExit sets the exit action of the parallel state, run after its regions
+// are exited
 func (psb *parallelStateBuilderImpl) OnExit(action ActionFunc) ParallelStateBuilder {
+	if atomic := atomicStateOf(psb.parallelState); atomic != nil {
+		atomic.WithExitAction(action)
+	}
 	return psb
 }
 
@@ -979,7 +1560,7 @@ func (psb *parallelStateBuilderImpl) To(target string) TransitionBuilder {
 	}
 	if mb, ok := psb.machineBuilder.(*machineBuilderImpl); ok {
 		if mb.currentTransitionBuilder != nil {
-			mb.addTransition(*mb.currentTransitionBuilder.transition)
+			mb.saveTransition(mb.currentTransitionBuilder)
 		}
 		mb.currentTransitionBuilder = tb
 	}
@@ -998,6 +1579,11 @@ func (psb *parallelStateBuilderImpl) Build() MachineDefinition {
 	return psb.machineBuilder.Build()
 }
 
+// BuildE finalizes the machine, returning validation errors instead of panicking
+func (psb *parallelStateBuilderImpl) BuildE() (MachineDefinition, error) {
+	return psb.machineBuilder.BuildE()
+}
+
 type regionBuilderImpl struct {
 	machineBuilder  MachineBuilder
 	parallelBuilder ParallelStateBuilder
@@ -1029,6 +1615,11 @@ func (rb *regionBuilderImpl) State(id string) StateBuilder {
 	return stateBuilder
 }
 
+// FinalState adds a final state to the region; entering it completes the region
+func (rb *regionBuilderImpl) FinalState(id string) StateBuilder {
+	return rb.State(id).Final()
+}
+
 func (rb *regionBuilderImpl) CompositeState(id string) CompositeStateBuilder {
 	fullID := rb.parentStateID + "." + rb.regionID + "." + id
 	return rb.machineBuilder.CompositeState(fullID)
@@ -1076,6 +1667,11 @@ func (rb *regionBuilderImpl) Build() MachineDefinition {
 	return rb.machineBuilder.Build()
 }
 
+// BuildE finalizes the machine, returning validation errors instead of panicking
+func (rb *regionBuilderImpl) BuildE() (MachineDefinition, error) {
+	return rb.machineBuilder.BuildE()
+}
+
 // Pseudostate builder implementations
 type choiceBuilderImpl struct {
 	machineBuilder MachineBuilder
@@ -1089,6 +1685,15 @@ func (cb *choiceBuilderImpl) When(condition GuardFunc) ChoiceTransitionBuilder {
 	}
 }
 
+// WhenNamed starts a branch whose guard is named for exports
+func (cb *choiceBuilderImpl) WhenNamed(name string, condition GuardFunc) ChoiceTransitionBuilder {
+	return &choiceTransitionBuilderImpl{
+		choiceBuilder: cb,
+		condition:     condition,
+		name:          name,
+	}
+}
+
 func (cb *choiceBuilderImpl) Otherwise(target string) ChoiceBuilder {
 	cb.choiceState.SetDefaultTarget(target)
 	return cb
@@ -1111,14 +1716,27 @@ func (cb *choiceBuilderImpl) Build() MachineDefinition {
 	return cb.machineBuilder.Build()
 }
 
+// BuildE finalizes the machine, returning validation errors instead of panicking
+func (cb *choiceBuilderImpl) BuildE() (MachineDefinition, error) {
+	return cb.machineBuilder.BuildE()
+}
+
 type choiceTransitionBuilderImpl struct {
 	choiceBuilder *choiceBuilderImpl
 	condition     GuardFunc
+	name          string
 	action        ActionFunc
 }
 
 func (ctb *choiceTransitionBuilderImpl) To(target string) ChoiceBuilder {
-	ctb.choiceBuilder.choiceState.AddChoiceCondition(ctb.condition, target, ctb.action)
+	ctb.choiceBuilder.choiceState.addChoiceCondition(ChoiceCondition{Guard: ctb.condition, GuardName: ctb.name, Target: target, Action: ctb.action})
+	return ctb.choiceBuilder
+}
+
+// ToFunc sets a target computed from the context when the branch is taken,
+// such as one of several reviewer queues. The computed state must exist.
+func (ctb *choiceTransitionBuilderImpl) ToFunc(target TargetFunc) ChoiceBuilder {
+	ctb.choiceBuilder.choiceState.addChoiceCondition(ChoiceCondition{Guard: ctb.condition, GuardName: ctb.name, TargetFunc: target, Action: ctb.action})
 	return ctb.choiceBuilder
 }
 
@@ -1133,11 +1751,36 @@ type junctionBuilderImpl struct {
 	junctionState  *PseudoStateImpl
 }
 
-func (jb *junctionBuilderImpl) To(target string) JunctionBuilder {
+// When starts a guarded segment. Segments are tried in declaration order
+// when the transition into the junction is selected, before any of its
+// actions run, so guards see the context as it was before the transition.
+func (jb *junctionBuilderImpl) When(condition GuardFunc) JunctionTransitionBuilder {
+	return &junctionTransitionBuilderImpl{
+		junctionBuilder: jb,
+		condition:       condition,
+	}
+}
+
+// WhenNamed starts a guarded segment whose guard is named for exports
+func (jb *junctionBuilderImpl) WhenNamed(name string, condition GuardFunc) JunctionTransitionBuilder {
+	return &junctionTransitionBuilderImpl{
+		junctionBuilder: jb,
+		condition:       condition,
+		name:            name,
+	}
+}
+
+// Otherwise sets the else segment, taken when no guarded segment is enabled
+func (jb *junctionBuilderImpl) Otherwise(target string) JunctionBuilder {
 	jb.junctionState.SetDefaultTarget(target)
 	return jb
 }
 
+// To sets the unguarded segment, the same as Otherwise
+func (jb *junctionBuilderImpl) To(target string) JunctionBuilder {
+	return jb.Otherwise(target)
+}
+
 func (jb *junctionBuilderImpl) Do(action ActionFunc) JunctionBuilder {
 	jb.junctionState.WithEntryAction(action)
 	return jb
@@ -1155,13 +1798,81 @@ func (jb *junctionBuilderImpl) Build() MachineDefinition {
 	return jb.machineBuilder.Build()
 }
 
+// BuildE finalizes the machine, returning validation errors instead of panicking
+func (jb *junctionBuilderImpl) BuildE() (MachineDefinition, error) {
+	return jb.machineBuilder.BuildE()
+}
+
+type junctionTransitionBuilderImpl struct {
+	junctionBuilder *junctionBuilderImpl
+	condition       GuardFunc
+	name            string
+	action          ActionFunc
+}
+
+func (jtb *junctionTransitionBuilderImpl) To(target string) JunctionBuilder {
+	jtb.junctionBuilder.junctionState.addChoiceCondition(ChoiceCondition{Guard: jtb.condition, GuardName: jtb.name, Target: target, Action: jtb.action})
+	return jtb.junctionBuilder
+}
+
+func (jtb *junctionTransitionBuilderImpl) Do(action ActionFunc) JunctionTransitionBuilder {
+	jtb.action = action
+	return jtb
+}
+
+// connectionPointBuilderImpl implements ConnectionPointBuilder
+type connectionPointBuilderImpl struct {
+	compositeBuilder *compositeStateBuilderImpl
+	point            *PseudoStateImpl
+}
+
+// To sets the target of the connection point. Entry point targets without a
+// dot name substates of the composite state; exit point targets are used as is.
+func (cpb *connectionPointBuilderImpl) To(target string) ConnectionPointBuilder {
+	if cpb.point.Kind() == EntryPoint && !strings.Contains(target, ".") {
+		target = cpb.compositeBuilder.stateID + "." + target
+	}
+	cpb.point.SetDefaultTarget(target)
+	return cpb
+}
+
+// Do sets an action run when the connection point is passed
+func (cpb *connectionPointBuilderImpl) Do(action ActionFunc) ConnectionPointBuilder {
+	cpb.point.WithEntryAction(action)
+	return cpb
+}
+
+// End returns to the composite state builder
+func (cpb *connectionPointBuilderImpl) End() CompositeStateBuilder {
+	return cpb.compositeBuilder
+}
+
+func (cpb *connectionPointBuilderImpl) Build() MachineDefinition {
+	return cpb.compositeBuilder.Build()
+}
+
+// BuildE finalizes the machine, returning validation errors instead of panicking
+func (cpb *connectionPointBuilderImpl) BuildE() (MachineDefinition, error) {
+	return cpb.compositeBuilder.BuildE()
+}
+
 type forkBuilderImpl struct {
 	machineBuilder MachineBuilder
 	forkState      *PseudoStateImpl
 }
 
+// To adds targets to the fork, after those already added
 func (fb *forkBuilderImpl) To(targets ...string) ForkBuilder {
-	fb.forkState.SetForkTargets(targets)
+	for _, target := range targets {
+		fb.forkState.AddForkTarget(target)
+	}
+	return fb
+}
+
+// ToBranch adds a labelled target running its own action before the target
+// is entered; observers implementing ForkBranchObserver see the label
+func (fb *forkBuilderImpl) ToBranch(label, target string, action ActionFunc) ForkBuilder {
+	fb.forkState.AddForkBranch(ForkBranch{Label: label, Target: target, Action: action})
 	return fb
 }
 
@@ -1182,6 +1893,11 @@ func (fb *forkBuilderImpl) Build() MachineDefinition {
 	return fb.machineBuilder.Build()
 }
 
+// BuildE finalizes the machine, returning validation errors instead of panicking
+func (fb *forkBuilderImpl) BuildE() (MachineDefinition, error) {
+	return fb.machineBuilder.BuildE()
+}
+
 type joinBuilderImpl struct {
 	machineBuilder MachineBuilder
 	joinState      *PseudoStateImpl
@@ -1192,6 +1908,16 @@ func (jb *joinBuilderImpl) From(sources ...string) JoinBuilder {
 	return jb
 }
 
+// FromFork derives the source combinations from the branches of a fork. When
+// the machine is built, every fork target's branch contributes the states
+// reachable from the target that transition to the join, and each pick of one
+// such state per branch is a source combination. The combinations follow the
+// fork's targets as they are when Build is called.
+func (jb *joinBuilderImpl) FromFork(forkID string) JoinBuilder {
+	jb.joinState.SetJoinFork(forkID)
+	return jb
+}
+
 func (jb *joinBuilderImpl) To(target string) JoinBuilder {
 	jb.joinState.SetJoinTarget(target)
 	return jb
@@ -1214,6 +1940,11 @@ func (jb *joinBuilderImpl) Build() MachineDefinition {
 	return jb.machineBuilder.Build()
 }
 
+// BuildE finalizes the machine, returning validation errors instead of panicking
+func (jb *joinBuilderImpl) BuildE() (MachineDefinition, error) {
+	return jb.machineBuilder.BuildE()
+}
+
 type historyBuilderImpl struct {
 	machineBuilder MachineBuilder
 	historyState   *PseudoStateImpl
@@ -1233,6 +1964,22 @@ func (hb *historyBuilderImpl) OnEntry(action ActionFunc) HistoryBuilder {
 	return hb.Do(action)
 }
 
+// ExpireAfter forgets history recorded longer than ttl before the history
+// state is entered, so the owning state starts fresh
+func (hb *historyBuilderImpl) ExpireAfter(ttl time.Duration) HistoryBuilder {
+	hb.historyState.SetHistoryTTL(ttl)
+	return hb
+}
+
+// ForgetAfter keeps the recorded history for the given number of exits of the
+// state owning it. Once that state was exited more often, its history is
+// forgotten, so the next entry through the history state starts fresh and
+// exits are counted again.
+func (hb *historyBuilderImpl) ForgetAfter(exits int) HistoryBuilder {
+	hb.historyState.SetHistoryExitLimit(exits)
+	return hb
+}
+
 func (hb *historyBuilderImpl) State(id string) StateBuilder {
 	return hb.machineBuilder.State(id)
 }
@@ -1241,53 +1988,48 @@ func (hb *historyBuilderImpl) Build() MachineDefinition {
 	return hb.machineBuilder.Build()
 }
 
-// simpleMachineDefinition is a simple implementation of MachineDefinition for now
+// BuildE finalizes the machine, returning validation errors instead of panicking
+func (hb *historyBuilderImpl) BuildE() (MachineDefinition, error) {
+	return hb.machineBuilder.BuildE()
+}
+
+// simpleMachineDefinition is the immutable result of building a machine. Its
+// states, transitions and join conditions are never modified after Build, so
+// instances share them and only allocate their runtime configuration.
 type simpleMachineDefinition struct {
 	machine        *StateMachine
 	initialState   string
 	states         map[string]State
 	transitions    []Transition
+	bySource       map[string][]Transition // Transitions indexed by source state, shared with instances
 	joinConditions map[string][][]string
+	hash           func() string // Structural hash recorded in snapshots, computed once
+	vars           []VarDeclaration
 }
 
 // CreateInstance creates a new machine instance
-func (smd *simpleMachineDefinition) CreateInstance() Machine {
+func (smd *simpleMachineDefinition) CreateInstance(opts ...MachineOption) Machine {
 	newMachine := newStateMachine()
 	newMachine.initialState = smd.initialState
 	newMachine.currentState = smd.initialState
-	newMachine.states = make(map[string]State)
-	newMachine.transitions = make(map[string][]Transition)
-	newMachine.joinConditions = make(map[string][][]string)
-	newMachine.joinTracking = make(map[string]map[string]bool)
-
-	// Copy states
-	for id, state := range smd.states {
-		newMachine.states[id] = state
-	}
-
-	// Copy transitions
-	for _, transition := range smd.transitions {
-		sourceState := transition.SourceState
-		if newMachine.transitions[sourceState] == nil {
-			newMachine.transitions[sourceState] = make([]Transition, 0)
-		}
-		newMachine.transitions[sourceState] = append(newMachine.transitions[sourceState], transition)
-	}
-
-	// Copy join conditions (combinations)
-	for joinID, combinations := range smd.joinConditions {
-		copiedCombinations := make([][]string, len(combinations))
-		for i, combination := range combinations {
-			copiedCombination := make([]string, len(combination))
-			copy(copiedCombination, combination)
-			copiedCombinations[i] = copiedCombination
-		}
-		newMachine.joinConditions[joinID] = copiedCombinations
+	newMachine.states = smd.states
+	newMachine.transitions = smd.bySource
+	newMachine.joinConditions = smd.joinConditions
+	newMachine.definition = smd
+	newMachine.initVars()
+
+	for _, opt := range opts {
+		opt(newMachine)
 	}
 
 	return newMachine
 }
 
+// CreateInstanceWithID creates a new machine instance with the given correlation ID
+func (smd *simpleMachineDefinition) CreateInstanceWithID(id string, opts ...MachineOption) Machine {
+	return smd.CreateInstance(append([]MachineOption{WithInstanceID(id)}, opts...)...)
+}
+
 func (smd *simpleMachineDefinition) Build() MachineDefinition {
 	return smd
 }
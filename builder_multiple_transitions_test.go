@@ -88,3 +88,45 @@ func TestMultipleTransitionsAreSaved(t *testing.T) {
 		t.Error("Missing transition for power_off -> off")
 	}
 }
+
+func TestGuardedAlternativesAcrossStatementsAreSaved(t *testing.T) {
+	builder := NewMachine()
+	builder.State("review").Initial().
+		To("approved").On("decide").When(func(ctx Context) bool {
+		amount, _ := ctx.Get("amount")
+		return amount == "small"
+	})
+	builder.State("review").
+		To("approved").On("decide").When(func(ctx Context) bool {
+		manager, _ := ctx.Get("manager")
+		return manager == true
+	})
+	definition := builder.State("approved").Build()
+
+	if count := len(definition.GetTransitions()["review"]); count != 2 {
+		t.Fatalf("Expected both guarded transitions to be saved, got %d", count)
+	}
+
+	machine := definition.CreateInstance()
+	_ = machine.Start()
+	machine.Context().Set("manager", true)
+	AssertEventProcessed(t, machine.HandleEvent("decide", nil), true)
+	AssertState(t, machine, "approved")
+}
+
+func TestChainedTransitionsAreNotDuplicated(t *testing.T) {
+	definition := NewMachine().
+		State("a").Initial().
+		To("b").On("go").
+		CompositeState("b").
+		To("a").On("back").
+		State("c").
+		Build()
+
+	if count := len(definition.GetTransitions()["a"]); count != 1 {
+		t.Errorf("Expected 1 transition from a, got %d", count)
+	}
+	if count := len(definition.GetTransitions()["b"]); count != 1 {
+		t.Errorf("Expected 1 transition from b, got %d", count)
+	}
+}
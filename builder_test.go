@@ -1,6 +1,8 @@
 package fluo
 
 import (
+	"errors"
+	"strings"
 	"testing"
 )
 
@@ -255,6 +257,39 @@ func TestMachineBuilder_ParallelState(t *testing.T) {
 	}
 }
 
+func TestMachineBuilder_ParallelStateActions(t *testing.T) {
+	var log []string
+	record := func(entry string) ActionFunc {
+		return func(ctx Context) error {
+			log = append(log, entry)
+			return nil
+		}
+	}
+
+	builder := NewMachine()
+	builder.State("off").Initial().
+		To("home").On("power_on")
+	home := builder.ParallelState("home").
+		OnEntry(record("enter home")).
+		OnExit(record("exit home"))
+	home.Region("lights").State("on").Initial().
+		OnEntry(record("enter lights"))
+	home.Region("climate").State("comfort").Initial().
+		OnExit(record("exit climate"))
+	home.To("off").On("power_off")
+
+	machine := builder.Build().CreateInstance()
+	_ = machine.Start()
+	machine.HandleEvent("power_on", nil)
+	machine.HandleEvent("power_off", nil)
+	AssertState(t, machine, "off")
+
+	want := []string{"enter home", "enter lights", "exit climate", "exit home"}
+	if strings.Join(log, ", ") != strings.Join(want, ", ") {
+		t.Errorf("Expected %v, got %v", want, log)
+	}
+}
+
 func TestMachineBuilder_ChoicePseudostate(t *testing.T) {
 	builder := NewMachine()
 
@@ -644,3 +679,82 @@ func TestMachineBuilder_ToParent(t *testing.T) {
 		t.Errorf("Expected machine to start successfully, got error: %v", err)
 	}
 }
+
+func TestMachineBuilder_BuildE(t *testing.T) {
+	definition, err := NewMachine().
+		State("idle").Initial().
+		To("running").On("start").
+		State("running").
+		BuildE()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	machine := definition.CreateInstance()
+	_ = machine.Start()
+	AssertEventProcessed(t, machine.HandleEvent("start", nil), true)
+	AssertState(t, machine, "running")
+}
+
+func TestMachineBuilder_BuildEMissingInitial(t *testing.T) {
+	definition, err := NewMachine().
+		State("state1").
+		BuildE()
+	if err == nil || definition != nil {
+		t.Fatal("Expected an error and no definition without an initial state")
+	}
+
+	var configErr *ConfigurationError
+	if !errors.As(err, &configErr) {
+		t.Errorf("Expected ConfigurationError, got %T", err)
+	}
+}
+
+func TestMachineBuilder_BuildEReportsAllUnknownTargets(t *testing.T) {
+	_, err := NewMachine().
+		State("start").Initial().
+		To("missing1").On("a").
+		To("missing2").On("b").
+		BuildE()
+	if err == nil {
+		t.Fatal("Expected an error for unknown targets")
+	}
+
+	var transitionErr *TransitionError
+	if !errors.As(err, &transitionErr) {
+		t.Fatalf("Expected TransitionError, got %T", err)
+	}
+	if transitionErr.Code != ErrCodeStateNotFound || transitionErr.From != "start" {
+		t.Errorf("Unexpected transition error: %+v", transitionErr)
+	}
+	if !strings.Contains(err.Error(), "missing1") || !strings.Contains(err.Error(), "missing2") {
+		t.Errorf("Expected both unknown targets to be reported, got %v", err)
+	}
+}
+
+func TestMachineBuilder_BuildEFromSubBuilders(t *testing.T) {
+	builder := NewMachine()
+	builder.State("idle").Initial().To("done").On("finish")
+	_, err := builder.CompositeState("done").BuildE()
+	if err != nil {
+		t.Fatalf("Expected no error from composite builder, got %v", err)
+	}
+
+	builder = NewMachine()
+	builder.State("idle").Initial().To("decide").On("go")
+	_, err = builder.Choice("decide").
+		When(func(ctx Context) bool { return true }).To("idle").
+		Otherwise("idle").
+		BuildE()
+	if err != nil {
+		t.Fatalf("Expected no error from choice builder, got %v", err)
+	}
+
+	_, err = NewMachine().
+		State("idle").
+		CompositeState("parent").
+		BuildE()
+	if err == nil {
+		t.Error("Expected composite builder to report a missing initial state")
+	}
+}
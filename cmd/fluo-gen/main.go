@@ -0,0 +1,78 @@
+// Command fluo-gen generates typed Go code from a YAML or JSON machine
+// document. It is meant to run from a go:generate directive:
+//
+//	//go:generate go run github.com/anggasct/fluo/cmd/fluo-gen -name Order order.yaml
+//
+// The generated file declares constants for the states, events, guards and
+// actions of the document, a constructor loading the definition and a machine
+// wrapper sending and checking typed states and events.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/anggasct/fluo"
+)
+
+func main() {
+	name := flag.String("name", "", "prefix of the generated identifiers")
+	pkg := flag.String("package", os.Getenv("GOPACKAGE"), "package of the generated file (defaults to $GOPACKAGE)")
+	output := flag.String("o", "", "output file (defaults to <document>_fluo.go)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: fluo-gen [flags] <document.yaml|document.json>\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := run(flag.Arg(0), *output, *pkg, *name); err != nil {
+		fmt.Fprintf(os.Stderr, "fluo-gen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// run generates the code for one document
+func run(input, output, pkg, name string) error {
+	data, err := os.ReadFile(input)
+	if err != nil {
+		return err
+	}
+
+	var doc *fluo.MachineDocument
+	extension := filepath.Ext(input)
+	switch strings.ToLower(extension) {
+	case ".json":
+		doc, err = fluo.ParseDocumentJSON(data)
+	case ".yaml", ".yml":
+		doc, err = fluo.ParseDocumentYAML(data)
+	default:
+		return fmt.Errorf("%s: unsupported document format %q", input, extension)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", input, err)
+	}
+
+	if pkg == "" {
+		return fmt.Errorf("no package given; pass -package or run through go generate")
+	}
+	code, err := fluo.GenerateGo(doc, fluo.GenerateOptions{
+		Package: pkg,
+		Name:    name,
+		Source:  filepath.Base(input),
+	})
+	if err != nil {
+		return err
+	}
+
+	if output == "" {
+		output = strings.TrimSuffix(input, extension) + "_fluo.go"
+	}
+	return os.WriteFile(output, code, 0o644)
+}
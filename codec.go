@@ -0,0 +1,229 @@
+package fluo
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+)
+
+// Codec encodes and decodes machine snapshots
+type Codec interface {
+	Name() string
+	Encode(snapshot *Snapshot) ([]byte, error)
+	Decode(data []byte) (*Snapshot, error)
+}
+
+// DefaultCodec is the codec used when none is specified
+var DefaultCodec Codec = JSONCodec{}
+
+// JSONCodec encodes snapshots as JSON
+type JSONCodec struct{}
+
+// Name returns the codec name
+func (JSONCodec) Name() string {
+	return "json"
+}
+
+// Encode encodes a snapshot as JSON
+func (JSONCodec) Encode(snapshot *Snapshot) ([]byte, error) {
+	return json.Marshal(snapshot)
+}
+
+// Decode decodes a JSON snapshot
+func (JSONCodec) Decode(data []byte) (*Snapshot, error) {
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// GobCodec encodes snapshots with encoding/gob. Custom context value types
+// must be registered with gob.Register before encoding.
+type GobCodec struct{}
+
+// Name returns the codec name
+func (GobCodec) Name() string {
+	return "gob"
+}
+
+// Encode encodes a snapshot with gob
+func (GobCodec) Encode(snapshot *Snapshot) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(snapshot); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode decodes a gob snapshot
+func (GobCodec) Decode(data []byte) (*Snapshot, error) {
+	var snapshot Snapshot
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&snapshot); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// MsgpackCodec encodes snapshots as MessagePack
+type MsgpackCodec struct{}
+
+// Name returns the codec name
+func (MsgpackCodec) Name() string {
+	return "msgpack"
+}
+
+// Encode encodes a snapshot as MessagePack
+func (MsgpackCodec) Encode(snapshot *Snapshot) ([]byte, error) {
+	encodedContext := make(map[string]any, len(snapshot.EncodedContext))
+	for key, data := range snapshot.EncodedContext {
+		encodedContext[key] = data
+	}
+
+	typedContext := make(map[string]any, len(snapshot.TypedContext))
+	for key, typed := range snapshot.TypedContext {
+		typedContext[key] = map[string]any{"type": typed.Type, "data": typed.Data}
+	}
+
+	var buf bytes.Buffer
+	err := encodeMsgpack(&buf, map[string]any{
+		"instanceId":         snapshot.InstanceID,
+		"metadata":           snapshot.Metadata,
+		"currentState":       snapshot.CurrentState,
+		"initialState":       snapshot.InitialState,
+		"machineState":       int64(snapshot.MachineState),
+		"regionStates":       snapshot.RegionStates,
+		"activeStates":       snapshot.ActiveStates,
+		"history":            snapshot.History,
+		"contextData":        snapshot.Context,
+		"encodedContextData": encodedContext,
+		"typedContextData":   typedContext,
+		"contextScopes":      snapshot.ContextScopes,
+		"definitionVersion":  snapshot.DefinitionVersion,
+		"definitionHash":     snapshot.DefinitionHash,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode decodes a MessagePack snapshot
+func (MsgpackCodec) Decode(data []byte) (*Snapshot, error) {
+	value, err := decodeMsgpack(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	fields, ok := value.(map[string]any)
+	if !ok {
+		return nil, NewConfigurationError("msgpack", "snapshot is not a map")
+	}
+
+	snapshot := &Snapshot{
+		Context: make(map[string]any),
+	}
+	snapshot.InstanceID, _ = fields["instanceId"].(string)
+	snapshot.Metadata = msgpackStringMap(fields["metadata"])
+	snapshot.CurrentState, _ = fields["currentState"].(string)
+	snapshot.InitialState, _ = fields["initialState"].(string)
+	snapshot.DefinitionVersion, _ = fields["definitionVersion"].(string)
+	snapshot.DefinitionHash, _ = fields["definitionHash"].(string)
+	if machineState, ok := fields["machineState"].(int64); ok {
+		snapshot.MachineState = MachineState(machineState)
+	}
+	snapshot.RegionStates = msgpackStringMap(fields["regionStates"])
+	snapshot.History = msgpackStringMap(fields["history"])
+	snapshot.ContextScopes = msgpackStringMap(fields["contextScopes"])
+	if activeStates, ok := fields["activeStates"].([]any); ok {
+		for _, stateID := range activeStates {
+			if s, ok := stateID.(string); ok {
+				snapshot.ActiveStates = append(snapshot.ActiveStates, s)
+			}
+		}
+	}
+	if contextData, ok := fields["contextData"].(map[string]any); ok {
+		snapshot.Context = contextData
+	}
+	if encodedContext, ok := fields["encodedContextData"].(map[string]any); ok && len(encodedContext) > 0 {
+		snapshot.EncodedContext = make(map[string][]byte, len(encodedContext))
+		for key, data := range encodedContext {
+			if b, ok := data.([]byte); ok {
+				snapshot.EncodedContext[key] = b
+			}
+		}
+	}
+	if typedContext, ok := fields["typedContextData"].(map[string]any); ok && len(typedContext) > 0 {
+		snapshot.TypedContext = make(map[string]TypedValue, len(typedContext))
+		for key, value := range typedContext {
+			if typed, ok := value.(map[string]any); ok {
+				typeName, _ := typed["type"].(string)
+				data, _ := typed["data"].([]byte)
+				snapshot.TypedContext[key] = TypedValue{Type: typeName, Data: data}
+			}
+		}
+	}
+
+	return snapshot, nil
+}
+
+// ProtobufCodec encodes snapshots in the protocol buffer wire format, as the
+// message below. Context values are encoded as JSON inside the message, so
+// they decode like JSONCodec values.
+//
+//	message Snapshot {
+//	  string instance_id = 1;
+//	  map<string, string> metadata = 2;
+//	  string current_state = 3;
+//	  string initial_state = 4;
+//	  int32 machine_state = 5;
+//	  map<string, string> region_states = 6;
+//	  repeated string active_states = 7;
+//	  map<string, string> history = 8;
+//	  map<string, bytes> context_data = 9; // JSON encoded values
+//	  map<string, bytes> encoded_context_data = 10;
+//	  map<string, TypedValue> typed_context_data = 11;
+//	  map<string, string> context_scopes = 12;
+//	  string definition_version = 13;
+//	  string definition_hash = 14;
+//	}
+//
+//	message TypedValue {
+//	  string type = 1;
+//	  bytes data = 2;
+//	}
+type ProtobufCodec struct{}
+
+// Name returns the codec name
+func (ProtobufCodec) Name() string {
+	return "protobuf"
+}
+
+// Encode encodes a snapshot as a protocol buffer message
+func (ProtobufCodec) Encode(snapshot *Snapshot) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := encodeProtobuf(&buf, snapshot); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode decodes a protocol buffer snapshot
+func (ProtobufCodec) Decode(data []byte) (*Snapshot, error) {
+	return decodeProtobuf(data)
+}
+
+// msgpackStringMap converts a decoded map into a map of strings
+func msgpackStringMap(value any) map[string]string {
+	decoded, ok := value.(map[string]any)
+	if !ok || len(decoded) == 0 {
+		return nil
+	}
+	result := make(map[string]string, len(decoded))
+	for key, v := range decoded {
+		if s, ok := v.(string); ok {
+			result[key] = s
+		}
+	}
+	return result
+}
@@ -0,0 +1,275 @@
+package fluo
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+)
+
+// ActiveConfiguration is a complete runtime configuration of a machine
+type ActiveConfiguration struct {
+	// CurrentState is the machine's main current state
+	CurrentState string
+	// RegionStates maps regions ("parallelState.region" path or bare region ID) to their current state
+	RegionStates map[string]string
+	// ActiveStates lists additional active states, such as fork branches
+	ActiveStates []string
+	// History maps composite states to their last active substate, and
+	// region paths ("parallelState.region") to their last active state
+	History map[string]string
+}
+
+// CaptureConfiguration returns the machine's complete runtime configuration
+func (sm *StateMachine) CaptureConfiguration() ActiveConfiguration {
+	sm.mutex.RLock()
+	defer sm.mutex.RUnlock()
+
+	return sm.captureConfiguration()
+}
+
+// captureConfiguration builds the runtime configuration. The caller must hold the machine mutex.
+func (sm *StateMachine) captureConfiguration() ActiveConfiguration {
+	cfg := ActiveConfiguration{
+		CurrentState: sm.currentState,
+		RegionStates: make(map[string]string),
+		ActiveStates: make([]string, 0),
+		History:      maps.Clone(sm.stateHistory),
+	}
+
+	for stateID, state := range sm.states {
+		if parallelState, ok := state.(ParallelState); ok {
+			for _, region := range parallelState.Regions() {
+				if sm.regionCurrent(region) != nil {
+					cfg.RegionStates[stateID+"."+region.ID()] = sm.regionCurrent(region).ID()
+				}
+			}
+		}
+	}
+
+	for stateID := range sm.activeStates {
+		if stateID != sm.currentState && sm.findRegionForState(stateID) == nil && !sm.isParallelState(stateID) {
+			cfg.ActiveStates = append(cfg.ActiveStates, stateID)
+		}
+	}
+	slices.Sort(cfg.ActiveStates)
+
+	return cfg
+}
+
+// SetConfiguration validates and installs a complete runtime configuration.
+// No entry or exit actions are executed; it is intended for recovery tooling
+// repairing machines whose persisted configuration is known to be wrong.
+func (sm *StateMachine) SetConfiguration(cfg ActiveConfiguration) error {
+	sm.mutex.Lock()
+	defer sm.unlock()
+
+	return sm.setConfiguration(cfg)
+}
+
+// EnterConfiguration moves a started machine into a complete runtime
+// configuration, such as several region states of a parallel machine at once,
+// so tests and recovery flows can place it in any legal configuration.
+// Unlike SetConfiguration, the current configuration is exited with its exit
+// actions and the new one entered with its entry actions, outermost first.
+// Without History the recorded history is kept. The first failing entry
+// action is returned after the machine moved.
+func (sm *StateMachine) EnterConfiguration(cfg ActiveConfiguration) error {
+	sm.mutex.Lock()
+	defer sm.unlock()
+
+	if sm.machineState != MachineStateStarted {
+		return NewMachineNotStartedError("EnterConfiguration")
+	}
+	if _, err := sm.validateConfiguration(cfg); err != nil {
+		return err
+	}
+
+	sm.updateStateHistory(sm.currentState)
+	sm.exitBelow("")
+	if cfg.History == nil {
+		cfg.History = maps.Clone(sm.stateHistory)
+	}
+	if err := sm.setConfiguration(cfg); err != nil {
+		return err
+	}
+
+	entered := make(map[string]int)
+	leaves := append([]string{sm.currentState}, slices.Sorted(maps.Keys(sm.activeStates))...)
+	for _, leaf := range leaves {
+		for stateID := leaf; stateID != ""; stateID = sm.enclosingState(stateID) {
+			entered[stateID] = sm.stateDepth(stateID)
+		}
+	}
+	sm.entryErr = nil
+	for _, stateID := range slices.SortedFunc(maps.Keys(entered), func(a, b string) int {
+		return cmp.Or(cmp.Compare(entered[a], entered[b]), cmp.Compare(a, b))
+	}) {
+		if state, exists := sm.states[stateID]; exists {
+			sm.runEntryAction(state)
+		}
+	}
+	sm.observers.NotifyStateEnter(sm.currentState, sm.context)
+	for _, stateID := range leaves[1:] {
+		if stateID != sm.currentState && !sm.isParallelState(stateID) {
+			sm.observers.NotifyStateEnter(stateID, sm.context)
+		}
+	}
+
+	err := sm.entryErr
+	sm.entryErr = nil
+	return err
+}
+
+// setConfiguration installs a configuration. The caller must hold the machine mutex.
+func (sm *StateMachine) setConfiguration(cfg ActiveConfiguration) error {
+	regions, err := sm.validateConfiguration(cfg)
+	if err != nil {
+		return err
+	}
+
+	previousState := sm.currentState
+
+	sm.stopAllTimers()
+	sm.stopAllActivities()
+	sm.stopAllSubmachines()
+	for _, state := range sm.states {
+		if parallelState, ok := state.(ParallelState); ok {
+			for _, region := range parallelState.Regions() {
+				sm.setRegionCurrent(region, nil)
+			}
+		}
+	}
+
+	sm.currentState = cfg.CurrentState
+	sm.activeStates = make(map[string]bool)
+	sm.joinTracking = make(map[string]map[string]bool)
+	sm.parallelRegions = make(map[string][]string)
+
+	for _, stateID := range sm.getStateHierarchy(cfg.CurrentState) {
+		if sm.isParallelState(stateID) {
+			sm.activeStates[stateID] = true
+		}
+	}
+	for region, stateID := range regions {
+		sm.setRegionCurrent(region, sm.states[stateID])
+		sm.activeStates[stateID] = true
+		sm.activeStates[region.ParentState().ID()] = true
+	}
+	for _, stateID := range cfg.ActiveStates {
+		sm.activeStates[stateID] = true
+	}
+
+	sm.stateHistory = make(map[string]string)
+	maps.Copy(sm.stateHistory, cfg.History)
+	// Restored history counts as recorded now for retention purposes
+	sm.historyTimes = make(map[string]time.Time, len(cfg.History))
+	for key := range cfg.History {
+		sm.historyTimes[key] = time.Now()
+	}
+	sm.historyExits = make(map[string]int)
+
+	if smCtx, ok := sm.context.(*StateMachineContext); ok {
+		smCtx.updateCurrentState(sm.currentState)
+	}
+
+	if sm.machineState == MachineStateStarted {
+		sm.resumeActiveStates()
+	}
+
+	if previousState != sm.currentState {
+		sm.observers.NotifyTransition(previousState, sm.currentState, nil, sm.context)
+	}
+
+	return nil
+}
+
+// validateConfiguration checks a configuration against the machine definition
+// and resolves its region keys
+func (sm *StateMachine) validateConfiguration(cfg ActiveConfiguration) (map[Region]string, error) {
+	if _, exists := sm.states[cfg.CurrentState]; !exists {
+		return nil, NewStateNotFoundError(cfg.CurrentState)
+	}
+
+	regions := make(map[Region]string)
+	for regionKey, stateID := range cfg.RegionStates {
+		region := sm.lookupRegion(regionKey)
+		if region == nil {
+			return nil, NewStateNotFoundError(regionKey)
+		}
+
+		if !sm.regionContainsState(region, stateID) {
+			return nil, NewInvalidStateError(stateID, fmt.Sprintf("state '%s' does not belong to region '%s'", stateID, regionKey))
+		}
+
+		parallelID := region.ParentState().ID()
+		if cfg.CurrentState != parallelID && !sm.isDescendantOf(cfg.CurrentState, parallelID) {
+			return nil, NewInvalidStateError(parallelID, fmt.Sprintf("region '%s' belongs to parallel state '%s' which is not active", regionKey, parallelID))
+		}
+
+		regions[region] = stateID
+	}
+
+	for _, stateID := range cfg.ActiveStates {
+		if _, exists := sm.states[stateID]; !exists {
+			return nil, NewStateNotFoundError(stateID)
+		}
+	}
+
+	for compositeID, stateID := range cfg.History {
+		composite, exists := sm.states[compositeID]
+		if !exists {
+			// Region history is keyed by the path of the region
+			region := sm.findRegionByPath(compositeID)
+			if region == nil {
+				return nil, NewStateNotFoundError(compositeID)
+			}
+			if !sm.regionContainsState(region, stateID) {
+				return nil, NewInvalidStateError(stateID, fmt.Sprintf("history state '%s' does not belong to region '%s'", stateID, compositeID))
+			}
+			continue
+		}
+		if !composite.IsComposite() {
+			return nil, NewInvalidStateError(compositeID, fmt.Sprintf("history owner '%s' is not a composite state", compositeID))
+		}
+		if _, exists := sm.states[stateID]; !exists {
+			return nil, NewStateNotFoundError(stateID)
+		}
+	}
+
+	return regions, nil
+}
+
+// findRegionByID finds the first region with the given bare ID
+func (sm *StateMachine) findRegionByID(regionID string) Region {
+	for _, state := range sm.states {
+		if parallelState, ok := state.(ParallelState); ok {
+			for _, region := range parallelState.Regions() {
+				if region.ID() == regionID {
+					return region
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// regionContainsState reports whether a state belongs to a region, directly or nested
+func (sm *StateMachine) regionContainsState(region Region, stateID string) bool {
+	if _, exists := sm.states[stateID]; !exists {
+		return false
+	}
+	for _, regionState := range region.States() {
+		if regionState.ID() == stateID || sm.isDescendantOf(stateID, regionState.ID()) {
+			return true
+		}
+	}
+	return sm.isDescendantOf(stateID, region.ParentState().ID()+"."+region.ID())
+}
+
+// isParallelState reports whether the given state is a parallel state
+func (sm *StateMachine) isParallelState(stateID string) bool {
+	state, exists := sm.states[stateID]
+	return exists && state.IsParallel()
+}
@@ -0,0 +1,254 @@
+package fluo
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSetConfiguration_ParallelRegions(t *testing.T) {
+	machine := CreateParallelMachine()
+	_ = machine.Start()
+
+	err := machine.SetConfiguration(ActiveConfiguration{
+		CurrentState: "active",
+		RegionStates: map[string]string{
+			"active.motor": "active.motor.running",
+			"lights":       "active.lights.on",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	AssertState(t, machine, "active")
+	if state := machine.RegionState("motor"); state != "active.motor.running" {
+		t.Errorf("Expected motor running, got %s", state)
+	}
+	if state := machine.RegionState("lights"); state != "active.lights.on" {
+		t.Errorf("Expected lights on, got %s", state)
+	}
+	if !machine.IsStateActive("active.motor.running") || !machine.IsStateActive("active.lights.on") {
+		t.Error("Expected region states to be active")
+	}
+}
+
+func TestSetConfiguration_RoundTrip(t *testing.T) {
+	machine := CreateParallelMachine()
+	_ = machine.Start()
+	_ = machine.HandleEvent("activate", nil)
+	_ = machine.HandleEvent("start_motor", nil)
+
+	cfg := machine.CaptureConfiguration()
+	if cfg.CurrentState != "active" {
+		t.Fatalf("Expected captured current state active, got %s", cfg.CurrentState)
+	}
+	if cfg.RegionStates["active.motor"] != "active.motor.running" {
+		t.Fatalf("Expected captured motor region, got %v", cfg.RegionStates)
+	}
+
+	restored := CreateParallelMachine()
+	_ = restored.Start()
+	if err := restored.SetConfiguration(cfg); err != nil {
+		t.Fatalf("Expected no error restoring configuration, got %v", err)
+	}
+
+	AssertState(t, restored, "active")
+	if state := restored.RegionState("motor"); state != "active.motor.running" {
+		t.Errorf("Expected motor running after restore, got %s", state)
+	}
+
+	result := restored.HandleEvent("turn_on_lights", nil)
+	if !result.Success() {
+		t.Errorf("Expected restored machine to keep processing events, got %+v", result)
+	}
+}
+
+func TestSetConfiguration_History(t *testing.T) {
+	var log []string
+	machine := createTransactionMachine(&log)
+
+	err := machine.SetConfiguration(ActiveConfiguration{
+		CurrentState: "idle",
+		History:      map[string]string{"transaction": "transaction.enter_amount"},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	cfg := machine.CaptureConfiguration()
+	if cfg.History["transaction"] != "transaction.enter_amount" {
+		t.Errorf("Expected history to be installed, got %v", cfg.History)
+	}
+}
+
+func TestSetConfiguration_Validation(t *testing.T) {
+	machine := CreateParallelMachine()
+
+	tests := []struct {
+		name string
+		cfg  ActiveConfiguration
+	}{
+		{"unknown current state", ActiveConfiguration{CurrentState: "missing"}},
+		{"unknown region", ActiveConfiguration{
+			CurrentState: "active",
+			RegionStates: map[string]string{"active.wheels": "active.wheels.on"},
+		}},
+		{"state outside region", ActiveConfiguration{
+			CurrentState: "active",
+			RegionStates: map[string]string{"active.motor": "active.lights.on"},
+		}},
+		{"inactive parallel state", ActiveConfiguration{
+			CurrentState: "inactive",
+			RegionStates: map[string]string{"active.motor": "active.motor.running"},
+		}},
+		{"unknown active state", ActiveConfiguration{
+			CurrentState: "inactive",
+			ActiveStates: []string{"missing"},
+		}},
+		{"history on atomic state", ActiveConfiguration{
+			CurrentState: "inactive",
+			History:      map[string]string{"inactive": "active"},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := machine.SetConfiguration(tt.cfg); err == nil {
+				t.Error("Expected validation error")
+			}
+		})
+	}
+
+	AssertState(t, machine, "inactive")
+}
+
+func TestRegionState_PerInstance(t *testing.T) {
+	builder := NewMachine()
+	builder.State("inactive").Initial().
+		To("active").On("activate")
+	parallel := builder.ParallelState("active")
+	motor := parallel.Region("motor")
+	motor.State("stopped").Initial().
+		To("running").On("start_motor")
+	motor.State("running")
+	definition := builder.Build()
+
+	first := definition.CreateInstance()
+	second := definition.CreateInstance()
+	for _, machine := range []Machine{first, second} {
+		_ = machine.Start()
+		machine.HandleEvent("activate", nil)
+	}
+	AssertEventProcessed(t, first.HandleEvent("start_motor", nil), true)
+
+	if state := first.RegionState("motor"); state != "active.motor.running" {
+		t.Errorf("Expected first instance running, got %s", state)
+	}
+	if state := second.RegionState("active.motor"); state != "active.motor.stopped" {
+		t.Errorf("Expected second instance stopped, got %s", state)
+	}
+}
+
+func TestSetRegionStates(t *testing.T) {
+	machine := CreateParallelMachine()
+	_ = machine.Start()
+	machine.HandleEvent("activate", nil)
+
+	err := machine.SetRegionStates(map[string]string{
+		"motor":         "active.motor.running",
+		"active.lights": "active.lights.on",
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !machine.IsStateActive("active.motor.running") || !machine.IsStateActive("active.lights.on") {
+		t.Errorf("Expected new region states active, got %v", machine.GetActiveStates())
+	}
+	if machine.IsStateActive("active.motor.stopped") || machine.IsStateActive("active.lights.off") {
+		t.Errorf("Expected previous region states inactive, got %v", machine.GetActiveStates())
+	}
+
+	err = machine.SetRegionStates(map[string]string{
+		"motor":  "active.motor.stopped",
+		"lights": "active.motor.running",
+	})
+	if err == nil {
+		t.Fatal("Expected error for state outside its region")
+	}
+	if state := machine.RegionState("motor"); state != "active.motor.running" {
+		t.Errorf("Expected no assignment applied on error, motor is %s", state)
+	}
+
+	if err := machine.SetRegionStates(map[string]string{"unknown": "active.motor.running"}); err == nil {
+		t.Error("Expected error for unknown region")
+	}
+}
+
+func TestRegionOf(t *testing.T) {
+	machine := CreateParallelMachine()
+
+	if region, ok := machine.RegionOf("active.lights.on"); !ok || region != "lights" {
+		t.Errorf("Expected lights region, got %q, %v", region, ok)
+	}
+	if _, ok := machine.RegionOf("inactive"); ok {
+		t.Error("Expected no region for a top-level state")
+	}
+}
+
+func TestEnterConfiguration_RunsActions(t *testing.T) {
+	var log []string
+	record := func(entry string) ActionFunc {
+		return func(ctx Context) error {
+			log = append(log, entry)
+			return nil
+		}
+	}
+	builder := NewMachine()
+	builder.State("idle").Initial().
+		OnExit(record("exit idle")).
+		To("active").On("activate")
+	builder.Parallel("active", func(p ParallelScope) {
+		p.Region("motor", func(r RegionScope) {
+			r.State("stopped").Initial().OnEntry(record("enter motor.stopped"))
+			r.State("running").OnEntry(record("enter motor.running"))
+		})
+		p.Region("lights", func(r RegionScope) {
+			r.State("off").Initial().OnEntry(record("enter lights.off"))
+			r.State("on").OnEntry(record("enter lights.on"))
+		})
+	})
+	machine := builder.Build().CreateInstance()
+	observer := NewTestObserver()
+	machine.AddObserver(observer)
+	_ = machine.Start()
+
+	err := machine.EnterConfiguration(ActiveConfiguration{
+		CurrentState: "active",
+		RegionStates: map[string]string{
+			"active.motor":  "active.motor.running",
+			"active.lights": "active.lights.on",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	AssertState(t, machine, "active")
+	if state := machine.RegionState("active.motor"); state != "active.motor.running" {
+		t.Errorf("Expected motor running, got %s", state)
+	}
+	expected := []string{"exit idle", "enter lights.on", "enter motor.running"}
+	if !slices.Equal(log, expected) {
+		t.Errorf("Expected %v, got %v", expected, log)
+	}
+	if !slices.ContainsFunc(observer.StateEnters, func(e StateEvent) bool { return e.State == "active.lights.on" }) {
+		t.Error("Expected observers to see the region states entered")
+	}
+
+	if err := machine.EnterConfiguration(ActiveConfiguration{
+		CurrentState: "active",
+		RegionStates: map[string]string{"active.motor": "active.lights.on"},
+	}); GetErrorCode(err) != ErrCodeInvalidState {
+		t.Errorf("Expected a state outside its region to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,160 @@
+package fluo
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+)
+
+// ConflictPolicy decides which transition fires when several transitions from
+// the same state match an event
+type ConflictPolicy int
+
+const (
+	// FirstMatch fires the first registered transition whose guard passes
+	FirstMatch ConflictPolicy = iota
+	// HighestPriority fires the highest priority transition whose guard passes,
+	// falling back to registration order between equal priorities
+	HighestPriority
+	// ErrorOnAmbiguity rejects the event when several transitions of the
+	// highest viable priority pass their guards
+	ErrorOnAmbiguity
+)
+
+// String returns the policy name
+func (p ConflictPolicy) String() string {
+	switch p {
+	case FirstMatch:
+		return "FirstMatch"
+	case HighestPriority:
+		return "HighestPriority"
+	case ErrorOnAmbiguity:
+		return "ErrorOnAmbiguity"
+	default:
+		return fmt.Sprintf("ConflictPolicy(%d)", int(p))
+	}
+}
+
+// WithConflictPolicy sets how several viable transitions from one state are resolved
+func WithConflictPolicy(policy ConflictPolicy) MachineOption {
+	return func(sm *StateMachine) {
+		sm.conflictPolicy = policy
+	}
+}
+
+// consult selects the transition a state consulted by the given routing
+// priority takes for an event, recording the step while the selector is probed
+func (sm *StateMachine) consult(priority int, origin, stateID, eventName string, accept func(Transition) bool) (*Transition, error) {
+	if sm.probe != nil {
+		sm.probe.consult(priority, origin, stateID, sm.findRegionForState(stateID))
+	}
+	transition, err := sm.selectTransition(stateID, sm.transitions[stateID], eventName, accept)
+	if transition != nil {
+		sm.debugMatch(priority, origin, stateID, eventName, transition.TargetState)
+	}
+	return transition, err
+}
+
+// selectTransition picks the transition from a state that fires for an event
+// under the machine's conflict policy. accept optionally filters candidates
+// before their guards are evaluated.
+func (sm *StateMachine) selectTransition(stateID string, transitions []Transition, eventName string, accept func(Transition) bool) (*Transition, error) {
+	if sm.conflictPolicy == FirstMatch {
+		for _, transition := range transitions {
+			if sm.transitionViable(transition, eventName, accept) {
+				return &transition, nil
+			}
+		}
+		return nil, nil
+	}
+
+	candidates := make([]Transition, 0, len(transitions))
+	for _, transition := range transitions {
+		if transition.EventName == eventName {
+			candidates = append(candidates, transition)
+		}
+	}
+	slices.SortStableFunc(candidates, func(a, b Transition) int {
+		return cmp.Compare(b.Priority, a.Priority)
+	})
+
+	var matched []Transition
+	for _, transition := range candidates {
+		if len(matched) > 0 && transition.Priority < matched[0].Priority {
+			break
+		}
+		if !sm.transitionViable(transition, eventName, accept) {
+			continue
+		}
+		matched = append(matched, transition)
+		if sm.conflictPolicy == HighestPriority {
+			break
+		}
+	}
+
+	switch len(matched) {
+	case 0:
+		return nil, nil
+	case 1:
+		return &matched[0], nil
+	default:
+		targets := make([]string, len(matched))
+		for i, transition := range matched {
+			targets[i] = transition.TargetState
+		}
+		return nil, NewAmbiguousTransitionError(stateID, eventName, targets)
+	}
+}
+
+// transitionViable reports whether a transition matches the event, is enabled and passes its guard
+func (sm *StateMachine) transitionViable(transition Transition, eventName string, accept func(Transition) bool) bool {
+	if transition.EventName != eventName {
+		return false
+	}
+	if !sm.flagEnabled(transition) {
+		sm.probe.consider(transition, GuardNotEvaluated, fmt.Sprintf("flag '%s' disabled", transition.Flag))
+		return false
+	}
+	if accept != nil && !accept(transition) {
+		sm.probe.consider(transition, GuardNotEvaluated, "not a source of the join")
+		return false
+	}
+	if transition.Guard == nil || (sm.probe != nil && sm.probe.skipGuards) {
+		sm.probe.consider(transition, GuardNotEvaluated, "")
+		return true
+	}
+	result, err := sm.evaluateGuard(transition.Guard, transition.GuardTimeout)
+	sm.debugGuard(transition, result, err)
+	if (err != nil || !result) && sm.trace.failedGuard == "" {
+		sm.trace.failedGuard = transition.guardIdentity()
+	}
+	if err != nil {
+		// Guard panicked or timed out - skip this transition
+		sm.probe.consider(transition, GuardErrored, err.Error())
+		return false
+	}
+	if !result {
+		sm.probe.consider(transition, GuardFailed, "guard rejected")
+		return false
+	}
+	sm.probe.consider(transition, GuardPassed, "")
+	return true
+}
+
+// validJoinSource reports whether a state may take a transition into a join
+func (sm *StateMachine) validJoinSource(stateID, targetID string) bool {
+	pseudo, ok := sm.states[targetID].(*PseudoStateImpl)
+	if !ok || pseudo.Kind() != Join {
+		return true
+	}
+	combinations, exists := sm.joinConditions[targetID]
+	if !exists {
+		return true
+	}
+	for _, combination := range combinations {
+		if slices.Contains(combination, stateID) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package fluo
+
+import (
+	"strings"
+	"testing"
+)
+
+func buildConflictMachine() MachineDefinition {
+	always := func(ctx Context) bool { return true }
+	return NewMachine().
+		State("review").Initial().
+		To("approved").On("decide").When(always).
+		To("escalated").On("decide").When(always).Priority(10).
+		To("rejected").On("decide").Priority(-1).
+		State("approved").
+		State("escalated").
+		State("rejected").
+		Build()
+}
+
+func TestConflictPolicy_FirstMatchDefault(t *testing.T) {
+	machine := buildConflictMachine().CreateInstance()
+	_ = machine.Start()
+
+	machine.HandleEvent("decide", nil)
+	AssertState(t, machine, "approved")
+}
+
+func TestConflictPolicy_HighestPriority(t *testing.T) {
+	machine := buildConflictMachine().CreateInstance(WithConflictPolicy(HighestPriority))
+	_ = machine.Start()
+
+	if match := machine.ExplainRouting("decide").Match; match == nil || match.Target != "escalated" {
+		t.Errorf("Expected explanation to match escalated, got %+v", match)
+	}
+	machine.HandleEvent("decide", nil)
+	AssertState(t, machine, "escalated")
+}
+
+func TestConflictPolicy_ErrorOnAmbiguity(t *testing.T) {
+	always := func(ctx Context) bool { return true }
+	definition := NewMachine().
+		State("review").Initial().
+		To("approved").On("decide").When(always).
+		To("escalated").On("decide").When(always).
+		To("rejected").On("decide").Priority(-1).
+		State("approved").
+		State("escalated").
+		State("rejected").
+		Build()
+
+	machine := definition.CreateInstance(WithConflictPolicy(ErrorOnAmbiguity))
+	_ = machine.Start()
+
+	result := machine.HandleEvent("decide", nil)
+	AssertEventProcessed(t, result, false)
+	if GetErrorCode(result.Error) != ErrCodeAmbiguousTransition {
+		t.Errorf("Expected ErrCodeAmbiguousTransition, got %v", result.Error)
+	}
+	if !strings.Contains(result.RejectionReason, "approved, escalated") {
+		t.Errorf("Expected both targets in the reason, got %q", result.RejectionReason)
+	}
+	AssertState(t, machine, "review")
+}
+
+func TestConflictPolicy_ErrorOnAmbiguityResolvedByPriority(t *testing.T) {
+	machine := buildConflictMachine().CreateInstance(WithConflictPolicy(ErrorOnAmbiguity))
+	_ = machine.Start()
+
+	AssertEventProcessed(t, machine.HandleEvent("decide", nil), true)
+	AssertState(t, machine, "escalated")
+}
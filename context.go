@@ -2,7 +2,9 @@ package fluo
 
 import (
 	"context"
+	"maps"
 	"sync"
+	"time"
 )
 
 // Context provides access to data and information during state machine execution
@@ -11,6 +13,9 @@ type Context interface {
 
 	Get(key string) (any, bool)
 	Set(key string, value any)
+	SetTransient(key string, value any)
+	SetScoped(state string, key string, value any)
+	IsTransient(key string) bool
 	GetAll() map[string]any
 
 	GetMachine() Machine
@@ -33,6 +38,9 @@ type Context interface {
 type StateMachineContext struct {
 	context.Context
 	data          map[string]any
+	transient     map[string]struct{}
+	scopes        map[string]string // Owning state of each scoped key
+	revision      uint64            // Bumped by every write of the data, so unchanged data is not measured again
 	machine       Machine
 	currentState  string
 	sourceState   string
@@ -40,6 +48,9 @@ type StateMachineContext struct {
 	currentEvent  Event
 	previousState string
 
+	// Deadline and cancellation of the event being processed, if it has any
+	eventContext context.Context
+
 	mutex sync.RWMutex
 }
 
@@ -48,6 +59,8 @@ func NewContext(parent context.Context, machine Machine) Context {
 	return &StateMachineContext{
 		Context:       parent,
 		data:          make(map[string]any),
+		transient:     make(map[string]struct{}),
+		scopes:        make(map[string]string),
 		machine:       machine,
 		currentState:  "",
 		sourceState:   "",
@@ -62,6 +75,8 @@ func NewSimpleContext() Context {
 	return &StateMachineContext{
 		Context:       context.Background(),
 		data:          make(map[string]any),
+		transient:     make(map[string]struct{}),
+		scopes:        make(map[string]string),
 		machine:       nil,
 		currentState:  "",
 		sourceState:   "",
@@ -83,15 +98,53 @@ func (ctx *StateMachineContext) Get(key string) (any, bool) {
 func (ctx *StateMachineContext) Set(key string, value any) {
 	ctx.mutex.Lock()
 	defer ctx.mutex.Unlock()
+	ctx.revision++
+	ctx.data[key] = value
+	delete(ctx.transient, key)
+	delete(ctx.scopes, key)
+}
+
+// SetTransient stores a value that is readable with Get but excluded from
+// GetAll and therefore from snapshots and observers
+func (ctx *StateMachineContext) SetTransient(key string, value any) {
+	ctx.mutex.Lock()
+	defer ctx.mutex.Unlock()
+	ctx.revision++
+	ctx.data[key] = value
+	ctx.transient[key] = struct{}{}
+	delete(ctx.scopes, key)
+}
+
+// SetScoped stores a value owned by a state. The value is removed when the
+// state is exited, after its exit action ran, so values scoped to a composite
+// state live until the composite itself is exited. The state should be
+// active; a later Set or SetTransient of the key releases it from the state.
+func (ctx *StateMachineContext) SetScoped(state string, key string, value any) {
+	ctx.mutex.Lock()
+	defer ctx.mutex.Unlock()
+	ctx.revision++
 	ctx.data[key] = value
+	delete(ctx.transient, key)
+	ctx.scopes[key] = state
 }
 
-// GetAll returns all context data
+// IsTransient reports whether a key was stored with SetTransient
+func (ctx *StateMachineContext) IsTransient(key string) bool {
+	ctx.mutex.RLock()
+	defer ctx.mutex.RUnlock()
+	_, transient := ctx.transient[key]
+	return transient
+}
+
+// GetAll returns all persistent context data, omitting transient keys
 func (ctx *StateMachineContext) GetAll() map[string]any {
 	ctx.mutex.RLock()
 	defer ctx.mutex.RUnlock()
 	result := make(map[string]any)
 	for k, v := range ctx.data {
+		if _, transient := ctx.transient[k]; transient {
+			continue
+		}
 		result[k] = v
 	}
 	return result
@@ -185,6 +238,8 @@ func (ctx *StateMachineContext) WithValue(key string, value any) Context {
 	newCtx := &StateMachineContext{
 		Context:       ctx.Context,
 		data:          make(map[string]any),
+		transient:     make(map[string]struct{}),
+		scopes:        make(map[string]string),
 		machine:       ctx.machine,
 		currentState:  ctx.currentState,
 		sourceState:   ctx.sourceState,
@@ -198,10 +253,16 @@ func (ctx *StateMachineContext) WithValue(key string, value any) Context {
 	for k, v := range ctx.data {
 		newCtx.data[k] = v
 	}
+	for k := range ctx.transient {
+		newCtx.transient[k] = struct{}{}
+	}
+	maps.Copy(newCtx.scopes, ctx.scopes)
 	ctx.mutex.RUnlock()
 
 	// Add new value
 	newCtx.data[key] = value
+	delete(newCtx.transient, key)
+	delete(newCtx.scopes, key)
 
 	return newCtx
 }
@@ -211,6 +272,8 @@ func (ctx *StateMachineContext) Fork() Context {
 	newCtx := &StateMachineContext{
 		Context:       ctx.Context,
 		data:          make(map[string]any),
+		transient:     make(map[string]struct{}),
+		scopes:        make(map[string]string),
 		machine:       ctx.machine,
 		currentState:  ctx.currentState,
 		sourceState:   ctx.sourceState,
@@ -224,6 +287,10 @@ func (ctx *StateMachineContext) Fork() Context {
 	for k, v := range ctx.data {
 		newCtx.data[k] = v
 	}
+	for k := range ctx.transient {
+		newCtx.transient[k] = struct{}{}
+	}
+	maps.Copy(newCtx.scopes, ctx.scopes)
 	ctx.mutex.RUnlock()
 
 	return newCtx
@@ -236,8 +303,70 @@ func (ctx *StateMachineContext) GetPreviousState() string {
 	return ctx.previousState
 }
 
+// Deadline returns the deadline of the event being processed, or of the
+// machine's context outside event processing
+func (ctx *StateMachineContext) Deadline() (time.Time, bool) {
+	return ctx.goContext().Deadline()
+}
+
+// Done is closed when the event being processed is cancelled or its deadline
+// passes, or outside event processing when the machine's context is done
+func (ctx *StateMachineContext) Done() <-chan struct{} {
+	return ctx.goContext().Done()
+}
+
+// Err reports why Done was closed
+func (ctx *StateMachineContext) Err() error {
+	return ctx.goContext().Err()
+}
+
+// Value looks a key up in the context of the event being processed, then in
+// the machine's context
+func (ctx *StateMachineContext) Value(key any) any {
+	ctx.mutex.RLock()
+	eventContext := ctx.eventContext
+	ctx.mutex.RUnlock()
+
+	if eventContext != nil {
+		if value := eventContext.Value(key); value != nil {
+			return value
+		}
+	}
+	return ctx.Context.Value(key)
+}
+
 // Internal methods for state machine management
 
+// goContext returns the context of the event being processed, or the
+// machine's context outside event processing
+func (ctx *StateMachineContext) goContext() context.Context {
+	ctx.mutex.RLock()
+	defer ctx.mutex.RUnlock()
+	if ctx.eventContext != nil {
+		return ctx.eventContext
+	}
+	return ctx.Context
+}
+
+// bindEventContext makes guards and actions see the deadline and cancellation
+// of the event being processed until the returned function restores the
+// previous binding. Contexts that can never be cancelled are not bound.
+func (ctx *StateMachineContext) bindEventContext(eventContext context.Context) func() {
+	if eventContext == nil || eventContext.Done() == nil {
+		return func() {}
+	}
+
+	ctx.mutex.Lock()
+	defer ctx.mutex.Unlock()
+	outer := ctx.eventContext
+	ctx.eventContext = eventContext
+	return func() {
+		ctx.mutex.Lock()
+		defer ctx.mutex.Unlock()
+		ctx.eventContext = outer
+	}
+}
+
 // updateTransitionInfo updates the transition-related information in the context
 func (ctx *StateMachineContext) updateTransitionInfo(currentState, sourceState, targetState string, event Event) {
 	ctx.mutex.Lock()
@@ -265,3 +394,85 @@ func (ctx *StateMachineContext) updateCurrentEvent(event Event) {
 	defer ctx.mutex.Unlock()
 	ctx.currentEvent = event
 }
+
+// replaceData replaces the persistent context data, keeping transient keys
+func (ctx *StateMachineContext) replaceData(values map[string]any) {
+	ctx.mutex.Lock()
+	defer ctx.mutex.Unlock()
+	ctx.revision++
+
+	for key := range ctx.data {
+		if _, transient := ctx.transient[key]; !transient {
+			delete(ctx.data, key)
+		}
+	}
+	maps.Copy(ctx.data, values)
+	for key := range values {
+		delete(ctx.transient, key)
+	}
+	maps.DeleteFunc(ctx.scopes, func(key, _ string) bool {
+		_, kept := values[key]
+		return !kept
+	})
+}
+
+// clearData removes every value, persistent, transient and scoped
+func (ctx *StateMachineContext) clearData() {
+	ctx.mutex.Lock()
+	defer ctx.mutex.Unlock()
+	ctx.revision++
+	clear(ctx.data)
+	clear(ctx.transient)
+	clear(ctx.scopes)
+}
+
+// clearScope removes the values owned by a state
+func (ctx *StateMachineContext) clearScope(state string) {
+	ctx.mutex.Lock()
+	defer ctx.mutex.Unlock()
+	ctx.revision++
+	for key, owner := range ctx.scopes {
+		if owner == state {
+			delete(ctx.data, key)
+			delete(ctx.scopes, key)
+		}
+	}
+}
+
+// releaseScopes removes the values whose owning state is no longer active
+func (ctx *StateMachineContext) releaseScopes(active func(state string) bool) {
+	ctx.mutex.Lock()
+	defer ctx.mutex.Unlock()
+	ctx.revision++
+	for key, owner := range ctx.scopes {
+		if !active(owner) {
+			delete(ctx.data, key)
+			delete(ctx.scopes, key)
+		}
+	}
+}
+
+// dataRevision returns the revision of the data, which changes on every write
+func (ctx *StateMachineContext) dataRevision() uint64 {
+	ctx.mutex.RLock()
+	defer ctx.mutex.RUnlock()
+	return ctx.revision
+}
+
+// scopeOwners returns the owning state of each scoped key
+func (ctx *StateMachineContext) scopeOwners() map[string]string {
+	ctx.mutex.RLock()
+	defer ctx.mutex.RUnlock()
+	return maps.Clone(ctx.scopes)
+}
+
+// setScopeOwners assigns owning states to keys present in the context
+func (ctx *StateMachineContext) setScopeOwners(owners map[string]string) {
+	ctx.mutex.Lock()
+	defer ctx.mutex.Unlock()
+	for key, state := range owners {
+		if _, exists := ctx.data[key]; exists {
+			ctx.scopes[key] = state
+		}
+	}
+}
@@ -280,6 +280,40 @@ func TestContext_Fork(t *testing.T) {
 	}
 }
 
+func TestContext_TransientKeys(t *testing.T) {
+	ctx := CreateTestContext()
+	ctx.Set("order_id", "A-42")
+	ctx.SetTransient("cache", map[string]int{"hits": 1})
+
+	if value, ok := ctx.Get("cache"); !ok || value == nil {
+		t.Error("Expected transient value to be readable with Get")
+	}
+	if !ctx.IsTransient("cache") || ctx.IsTransient("order_id") {
+		t.Error("Expected only 'cache' to be transient")
+	}
+
+	all := ctx.GetAll()
+	if _, ok := all["cache"]; ok {
+		t.Error("Expected GetAll to omit transient keys")
+	}
+	if _, ok := all["order_id"]; !ok {
+		t.Error("Expected GetAll to include persistent keys")
+	}
+
+	forked := ctx.Fork()
+	if !forked.IsTransient("cache") {
+		t.Error("Expected forked context to keep transient keys transient")
+	}
+
+	ctx.Set("cache", "now persistent")
+	if ctx.IsTransient("cache") {
+		t.Error("Expected Set to make a transient key persistent")
+	}
+	if _, ok := ctx.GetAll()["cache"]; !ok {
+		t.Error("Expected GetAll to include a key after Set")
+	}
+}
+
 func TestContext_ThreadSafety(t *testing.T) {
 	ctx := CreateTestContext()
 
@@ -482,3 +516,101 @@ func TestContext_ComplexDataStructures(t *testing.T) {
 		t.Error("Expected nested data to be accessible")
 	}
 }
+
+func buildScopedContextDefinition() MachineDefinition {
+	registry := NewRegistry()
+	registry.RegisterAction("enterStep1", func(ctx Context) error {
+		ctx.SetScoped("wizard", "draft", map[string]any{})
+		ctx.SetScoped("wizard.step1", "step1_input", "a")
+		return nil
+	})
+	registry.RegisterAction("exitStep1", func(ctx Context) error {
+		if _, ok := ctx.Get("step1_input"); !ok {
+			return fmt.Errorf("scoped value removed before the exit action")
+		}
+		return nil
+	})
+
+	definition, err := registry.LoadDefinitionJSON([]byte(`{
+		"initial": "idle",
+		"states": [
+			{"id": "idle", "transitions": [{"event": "open", "target": "wizard"}]},
+			{"id": "wizard", "type": "composite", "initial": "step1", "states": [
+				{"id": "step1", "onEntry": "enterStep1", "onExit": "exitStep1",
+				 "transitions": [{"event": "next", "target": "step2"}]},
+				{"id": "step2", "transitions": [{"event": "finish", "target": "idle"}]}
+			]}
+		]
+	}`))
+	if err != nil {
+		panic(err)
+	}
+	return definition
+}
+
+func TestContext_SetScopedRemovedOnExit(t *testing.T) {
+	machine := buildScopedContextDefinition().CreateInstance()
+	observer := NewTestObserver()
+	machine.AddObserver(observer)
+	_ = machine.Start()
+
+	machine.HandleEvent("open", nil)
+	if _, ok := machine.Context().Get("step1_input"); !ok {
+		t.Fatal("Expected the scoped value while the state is active")
+	}
+
+	machine.HandleEvent("next", nil)
+	if _, ok := machine.Context().Get("step1_input"); ok {
+		t.Error("Expected the value scoped to step1 to be removed when it is exited")
+	}
+	if _, ok := machine.Context().Get("draft"); !ok {
+		t.Error("Expected the value scoped to the composite to outlive its substates")
+	}
+	if len(observer.Errors) != 0 {
+		t.Errorf("Expected the exit action to see the scoped value, got %v", observer.Errors)
+	}
+
+	machine.HandleEvent("finish", nil)
+	if _, ok := machine.Context().Get("draft"); ok {
+		t.Error("Expected the value scoped to the composite to be removed when it is exited")
+	}
+}
+
+func TestContext_SetReleasesScope(t *testing.T) {
+	machine := buildScopedContextDefinition().CreateInstance()
+	_ = machine.Start()
+	machine.HandleEvent("open", nil)
+
+	machine.Context().Set("step1_input", "kept")
+	machine.HandleEvent("next", nil)
+	if value, _ := machine.Context().Get("step1_input"); value != "kept" {
+		t.Errorf("Expected Set to release the key from its state, got %v", value)
+	}
+}
+
+func TestContext_ScopesSurviveSnapshot(t *testing.T) {
+	def := buildScopedContextDefinition()
+	machine := def.CreateInstance()
+	_ = machine.Start()
+	machine.HandleEvent("open", nil)
+
+	snapshot, err := machine.Snapshot()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if snapshot.ContextScopes["step1_input"] != "wizard.step1" {
+		t.Fatalf("Expected the scope in the snapshot, got %v", snapshot.ContextScopes)
+	}
+
+	data, _ := MsgpackCodec{}.Encode(snapshot)
+	decoded, _ := MsgpackCodec{}.Decode(data)
+
+	restored := def.CreateInstance()
+	if err := restored.Restore(decoded); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	restored.HandleEvent("next", nil)
+	if _, ok := restored.Context().Get("step1_input"); ok {
+		t.Error("Expected the restored scope to be cleaned up on exit")
+	}
+}
@@ -0,0 +1,95 @@
+package fluo
+
+import (
+	"log/slog"
+	"maps"
+	"slices"
+)
+
+// DebugLevel controls the routing diagnostics a machine writes to its debug logger
+type DebugLevel int32
+
+const (
+	// DebugOff disables routing diagnostics
+	DebugOff DebugLevel = iota
+	// DebugRouting logs each event lookup and the transition it matched
+	DebugRouting
+	// DebugTrace additionally logs the active states, guard outcomes and join synchronization
+	DebugTrace
+)
+
+// WithDebugLogger sets the logger that receives routing diagnostics (slog.Default() by default)
+func WithDebugLogger(logger *slog.Logger) MachineOption {
+	return func(sm *StateMachine) {
+		sm.debugLogger = logger
+	}
+}
+
+// WithDebug sets the initial debug level of the machine
+func WithDebug(level DebugLevel) MachineOption {
+	return func(sm *StateMachine) {
+		sm.debugLevel.Store(int32(level))
+	}
+}
+
+// SetDebug changes the debug level of the machine at runtime. Diagnostics are
+// written at info level so they pass production log filters while enabled.
+func (sm *StateMachine) SetDebug(level DebugLevel) {
+	sm.debugLevel.Store(int32(level))
+}
+
+// debugging reports whether diagnostics of the given level are enabled
+func (sm *StateMachine) debugging(level DebugLevel) bool {
+	return level != DebugOff && DebugLevel(sm.debugLevel.Load()) >= level
+}
+
+// debug writes a diagnostic record when the level is enabled
+func (sm *StateMachine) debug(level DebugLevel, msg string, args ...any) {
+	if !sm.debugging(level) {
+		return
+	}
+	logger := sm.debugLogger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	logger.Info(msg, args...)
+}
+
+// debugLookup logs the start of a transition lookup, unless the selector is
+// only probed
+func (sm *StateMachine) debugLookup(eventName, sourceStateID string) {
+	if sm.probe != nil || !sm.debugging(DebugRouting) {
+		return
+	}
+	sm.debug(DebugRouting, "fluo: searching for transition", "event", eventName, "source", sourceStateID)
+	sm.debug(DebugTrace, "fluo: active states", "states", slices.Sorted(maps.Keys(sm.activeStates)))
+}
+
+// debugMatch logs the transition found by a routing priority
+func (sm *StateMachine) debugMatch(priority int, origin, stateID, eventName, target string) {
+	if sm.probe != nil || !sm.debugging(DebugRouting) {
+		return
+	}
+	sm.debug(DebugRouting, "fluo: matched transition",
+		"priority", priority, "origin", origin, "state", stateID, "event", eventName, "target", target)
+}
+
+// debugGuard logs the outcome of a guard evaluated while routing an event
+func (sm *StateMachine) debugGuard(transition Transition, passed bool, err error) {
+	if sm.probe != nil || !sm.debugging(DebugTrace) {
+		return
+	}
+	outcome := GuardFailed
+	switch {
+	case err != nil:
+		outcome = GuardErrored
+	case passed:
+		outcome = GuardPassed
+	}
+	args := []any{"source", transition.SourceState, "event", transition.EventName,
+		"target", transition.TargetState, "guard", transition.GuardName, "outcome", outcome.String()}
+	if err != nil {
+		args = append(args, "error", err)
+	}
+	sm.debug(DebugTrace, "fluo: evaluated guard", args...)
+}
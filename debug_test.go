@@ -0,0 +1,81 @@
+package fluo
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestDebug_RoutingLogsToggledAtRuntime(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+
+	machine := NewMachine().
+		State("idle").Initial().
+		To("running").On("start").
+		State("running").
+		To("stopped").On("stop").
+		State("stopped").
+		To("idle").On("reset").
+		Build().
+		CreateInstance(WithDebugLogger(logger))
+	_ = machine.Start()
+
+	machine.HandleEvent("start", nil)
+	if buf.Len() != 0 {
+		t.Fatalf("Expected no diagnostics while debug is off, got: %s", buf.String())
+	}
+
+	machine.SetDebug(DebugRouting)
+	machine.HandleEvent("stop", nil)
+
+	output := buf.String()
+	if !strings.Contains(output, "fluo: searching for transition") || !strings.Contains(output, "event=stop") {
+		t.Errorf("Expected lookup diagnostics, got: %s", output)
+	}
+	if !strings.Contains(output, "fluo: matched transition") || !strings.Contains(output, "target=stopped") {
+		t.Errorf("Expected match diagnostics, got: %s", output)
+	}
+	if strings.Contains(output, "fluo: active states") {
+		t.Error("Expected trace diagnostics to stay disabled at DebugRouting")
+	}
+
+	buf.Reset()
+	machine.SetDebug(DebugTrace)
+	machine.HandleEvent("reset", nil)
+	if !strings.Contains(buf.String(), "fluo: active states") {
+		t.Errorf("Expected trace diagnostics at DebugTrace, got: %s", buf.String())
+	}
+
+	buf.Reset()
+	machine.SetDebug(DebugOff)
+	machine.HandleEvent("start", nil)
+	if buf.Len() != 0 {
+		t.Errorf("Expected no diagnostics after turning debug off, got: %s", buf.String())
+	}
+}
+
+func TestDebug_InstanceScoped(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	definition := NewMachine().
+		State("idle").Initial().
+		To("running").On("start").
+		State("running").
+		Build()
+
+	quiet := definition.CreateInstance(WithDebugLogger(logger))
+	loud := definition.CreateInstance(WithDebugLogger(logger), WithDebug(DebugRouting))
+	_ = quiet.Start()
+	_ = loud.Start()
+
+	quiet.HandleEvent("start", nil)
+	if buf.Len() != 0 {
+		t.Fatalf("Expected the quiet instance not to log, got: %s", buf.String())
+	}
+	loud.HandleEvent("start", nil)
+	if !strings.Contains(buf.String(), "fluo: matched transition") {
+		t.Errorf("Expected the debug instance to log, got: %s", buf.String())
+	}
+}
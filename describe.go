@@ -0,0 +1,155 @@
+package fluo
+
+import (
+	"strings"
+	"time"
+)
+
+// MachineDescription is a structured summary of a machine definition used to
+// generate documentation and other tooling
+type MachineDescription struct {
+	InitialState string                  `json:"initialState"`
+	States       []StateDescription      `json:"states"`
+	Transitions  []TransitionDescription `json:"transitions"`
+	Vars         []VarDescription        `json:"vars,omitempty"`
+}
+
+// VarDescription summarizes a declared extended state variable
+type VarDescription struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Initial any    `json:"initial,omitempty"`
+}
+
+// StateDescription summarizes a state
+type StateDescription struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Kind    string `json:"kind"`
+	Parent  string `json:"parent,omitempty"`
+	Initial bool   `json:"initial,omitempty"`
+}
+
+// TransitionDescription summarizes a transition or a pseudostate branch
+type TransitionDescription struct {
+	From        string        `json:"from"`
+	To          string        `json:"to"`
+	Event       string        `json:"event,omitempty"`
+	Trigger     string        `json:"trigger"`
+	Guarded     bool          `json:"guarded,omitempty"`
+	Guard       string        `json:"guard,omitempty"`
+	Description string        `json:"description,omitempty"`
+	HasAction   bool          `json:"hasAction,omitempty"`
+	Action      string        `json:"action,omitempty"`
+	Tags        []string      `json:"tags,omitempty"`
+	Flag        string        `json:"flag,omitempty"`
+	Internal    bool          `json:"internal,omitempty"`
+	After       time.Duration `json:"after,omitempty"`
+}
+
+// Describe summarizes the states, transitions and pseudostate branches of a
+// definition, ordered by state ID
+func Describe(def MachineDefinition) *MachineDescription {
+	model := newDiagramModel(def)
+	description := &MachineDescription{
+		InitialState: def.GetInitialState(),
+		States:       make([]StateDescription, 0, len(model.nodes)),
+		Transitions:  make([]TransitionDescription, 0, len(model.transitions)),
+	}
+
+	for _, v := range definitionVars(def) {
+		description.Vars = append(description.Vars, VarDescription{Name: v.Name(), Type: v.Type().String(), Initial: v.initialValue()})
+	}
+
+	containment := model.containment()
+
+	for _, node := range sortedDiagramNodes(model.nodes) {
+		description.States = append(description.States, StateDescription{
+			ID:      node.id,
+			Name:    diagramLabel(node.id),
+			Kind:    describeStateKind(node),
+			Parent:  containment.container[node.id],
+			Initial: containment.initials[node.id],
+		})
+	}
+
+	for _, transition := range model.transitions {
+		event := transition.EventName
+		if transition.After > 0 || strings.HasPrefix(event, "__completion") {
+			event = ""
+		}
+		description.Transitions = append(description.Transitions, TransitionDescription{
+			From:        transition.SourceState,
+			To:          transition.TargetState,
+			Event:       event,
+			Trigger:     transitionTrigger(transition),
+			Guarded:     transition.Guard != nil,
+			Guard:       transition.GuardName,
+			Description: transition.Description,
+			HasAction:   transition.Action != nil,
+			Action:      transition.ActionName,
+			Tags:        transition.Tags,
+			Flag:        transition.Flag,
+			Internal:    transition.Internal,
+			After:       transition.After,
+		})
+	}
+
+	for _, node := range sortedDiagramNodes(model.nodes) {
+		for _, edge := range pseudoStateEdges(node.state) {
+			trigger := strings.Trim(edge.label, "[]")
+			if trigger == "guard" {
+				trigger = "branch"
+			}
+			description.Transitions = append(description.Transitions, TransitionDescription{
+				From:    edge.from,
+				To:      edge.to,
+				Trigger: trigger,
+				Guarded: edge.label == "[guard]",
+			})
+		}
+	}
+
+	return description
+}
+
+// describeStateKind names the kind of a state
+func describeStateKind(node *diagramNode) string {
+	if pseudo, ok := node.state.(PseudoState); ok {
+		switch pseudo.Kind() {
+		case Choice:
+			return "choice"
+		case Junction:
+			return "junction"
+		case Fork:
+			return "fork"
+		case Join:
+			return "join"
+		case History:
+			return "history"
+		case DeepHistory:
+			return "deepHistory"
+		case EntryPoint:
+			return "entryPoint"
+		case ExitPoint:
+			return "exitPoint"
+		case Terminate:
+			return "terminate"
+		default:
+			return "initial"
+		}
+	}
+
+	switch {
+	case node.state.IsFinal():
+		return "final"
+	case submachineOf(node.state) != nil:
+		return "submachine"
+	case len(node.regions) > 0 || node.state.IsParallel():
+		return "parallel"
+	case len(node.children) > 0:
+		return "composite"
+	default:
+		return "atomic"
+	}
+}
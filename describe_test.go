@@ -0,0 +1,92 @@
+package fluo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDescribe_StatesAndTransitions(t *testing.T) {
+	builder := NewMachine()
+	builder.State("idle").Initial().
+		To("review").On("submit").
+		When(func(ctx Context) bool { return true }).
+		Annotate("amount is below the auto-approval limit")
+	builder.State("review").
+		To("decide").On("assess").IfFlag("new_engine")
+	builder.State("review").After(5 * time.Minute).To("idle")
+	builder.Choice("decide").
+		When(func(ctx Context) bool { return true }).To("done").
+		Otherwise("idle")
+	builder.State("done").Final()
+
+	description := Describe(builder.Build())
+
+	if description.InitialState != "idle" {
+		t.Errorf("Expected initial state idle, got %s", description.InitialState)
+	}
+
+	kinds := make(map[string]string)
+	for _, state := range description.States {
+		kinds[state.ID] = state.Kind
+		if state.ID == "idle" && !state.Initial {
+			t.Error("Expected idle to be marked initial")
+		}
+	}
+	expectedKinds := map[string]string{"idle": "atomic", "review": "atomic", "decide": "choice", "done": "final"}
+	for id, kind := range expectedKinds {
+		if kinds[id] != kind {
+			t.Errorf("Expected state %s to be %s, got %s", id, kind, kinds[id])
+		}
+	}
+
+	var submit, timed, flagged, otherwise *TransitionDescription
+	for i := range description.Transitions {
+		transition := &description.Transitions[i]
+		switch {
+		case transition.Event == "submit":
+			submit = transition
+		case transition.After > 0:
+			timed = transition
+		case transition.Flag != "":
+			flagged = transition
+		case transition.From == "decide" && transition.Trigger == "else":
+			otherwise = transition
+		}
+	}
+
+	if submit == nil || !submit.Guarded || submit.Description != "amount is below the auto-approval limit" {
+		t.Errorf("Expected annotated guarded submit transition, got %+v", submit)
+	}
+	if timed == nil || timed.Trigger != "after 5m0s" || timed.Event != "" {
+		t.Errorf("Expected timed transition without an event, got %+v", timed)
+	}
+	if flagged == nil || flagged.Flag != "new_engine" {
+		t.Errorf("Expected flagged transition, got %+v", flagged)
+	}
+	if otherwise == nil || otherwise.To != "idle" {
+		t.Errorf("Expected choice else branch to idle, got %+v", otherwise)
+	}
+}
+
+func TestDescribe_Hierarchy(t *testing.T) {
+	definition := NewMachine()
+	definition.State("inactive").Initial().To("active").On("activate")
+	region := definition.ParallelState("active").Region("motor")
+	region.State("stopped").Initial()
+	region.State("running")
+
+	description := Describe(definition.Build())
+
+	for _, state := range description.States {
+		switch state.ID {
+		case "active":
+			if state.Kind != "parallel" {
+				t.Errorf("Expected active to be parallel, got %s", state.Kind)
+			}
+		case "active.motor.stopped":
+			if state.Parent != "active.motor" || !state.Initial || state.Name != "stopped" {
+				t.Errorf("Unexpected region state description: %+v", state)
+			}
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package fluo
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"html"
+	"slices"
+	"strings"
+)
+
+// docTransitionNotes renders the guard, flag and kind details of a transition
+func docTransitionNotes(transition TransitionDescription) (guard string, notes string) {
+	switch {
+	case transition.Description != "":
+		guard = transition.Description
+	case transition.Guard != "":
+		guard = transition.Guard
+	case transition.Guarded:
+		guard = "guarded"
+	}
+
+	var parts []string
+	if transition.Flag != "" {
+		parts = append(parts, "flag "+transition.Flag)
+	}
+	if transition.Internal {
+		parts = append(parts, "internal")
+	}
+	return guard, strings.Join(parts, ", ")
+}
+
+// docTransitionAction describes the action of a transition: its registered
+// name, or "transition" or "internal" for an unnamed action
+func docTransitionAction(transition TransitionDescription) string {
+	switch {
+	case transition.Action != "":
+		return transition.Action
+	case !transition.HasAction:
+		return ""
+	case transition.Internal:
+		return "internal"
+	default:
+		return "transition"
+	}
+}
+
+// docMatrix builds the transition matrix: the states that take part in
+// transitions and the triggers leading from each source to each target
+func docMatrix(description *MachineDescription) ([]string, map[string]map[string][]string) {
+	var states []string
+	cells := make(map[string]map[string][]string)
+	for _, transition := range description.Transitions {
+		for _, id := range []string{transition.From, transition.To} {
+			if !slices.Contains(states, id) {
+				states = append(states, id)
+			}
+		}
+		if cells[transition.From] == nil {
+			cells[transition.From] = make(map[string][]string)
+		}
+		cells[transition.From][transition.To] = append(cells[transition.From][transition.To], transition.Trigger)
+	}
+	slices.Sort(states)
+	return states, cells
+}
+
+// escapeMarkdownCell escapes text for use inside a Markdown table cell
+func escapeMarkdownCell(text string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(text, "|", "\\|"), "\n", " ")
+}
+
+// ExportMarkdown renders human-readable documentation of a machine definition:
+// an embedded Mermaid diagram, a state table, a transition table with guard
+// descriptions from Annotate, and a transition matrix
+func ExportMarkdown(def MachineDefinition, title string) string {
+	description := Describe(def)
+
+	var out strings.Builder
+	out.WriteString(fmt.Sprintf("# %s\n\n", title))
+	if description.InitialState != "" {
+		out.WriteString(fmt.Sprintf("Initial state: `%s`\n\n", description.InitialState))
+	}
+
+	out.WriteString("## Diagram\n\n```mermaid\n")
+	out.WriteString(ExportMermaid(def))
+	out.WriteString("```\n\n")
+
+	out.WriteString("## States\n\n| State | Kind | Parent | Initial |\n| --- | --- | --- | --- |\n")
+	for _, state := range description.States {
+		parent := ""
+		if state.Parent != "" {
+			parent = fmt.Sprintf("`%s`", state.Parent)
+		}
+		initial := ""
+		if state.Initial {
+			initial = "yes"
+		}
+		out.WriteString(fmt.Sprintf("| `%s` | %s | %s | %s |\n", state.ID, state.Kind, parent, initial))
+	}
+
+	out.WriteString("\n## Transitions\n\n| From | Trigger | To | Guard | Notes |\n| --- | --- | --- | --- | --- |\n")
+	for _, transition := range description.Transitions {
+		guard, notes := docTransitionNotes(transition)
+		out.WriteString(fmt.Sprintf("| `%s` | %s | `%s` | %s | %s |\n",
+			transition.From, escapeMarkdownCell(transition.Trigger), transition.To, escapeMarkdownCell(guard), escapeMarkdownCell(notes)))
+	}
+
+	states, cells := docMatrix(description)
+	out.WriteString("\n## Transition Matrix\n\n| From \\ To |")
+	for _, target := range states {
+		out.WriteString(fmt.Sprintf(" `%s` |", target))
+	}
+	out.WriteString("\n| --- |" + strings.Repeat(" --- |", len(states)) + "\n")
+	for _, source := range states {
+		if len(cells[source]) == 0 {
+			continue
+		}
+		out.WriteString(fmt.Sprintf("| `%s` |", source))
+		for _, target := range states {
+			out.WriteString(fmt.Sprintf(" %s |", escapeMarkdownCell(strings.Join(cells[source][target], ", "))))
+		}
+		out.WriteString("\n")
+	}
+
+	return out.String()
+}
+
+// ExportHTML renders the same documentation as ExportMarkdown as a standalone
+// HTML page; the diagram is rendered by the Mermaid script loaded from a CDN
+func ExportHTML(def MachineDefinition, title string) string {
+	description := Describe(def)
+	escape := html.EscapeString
+
+	var out strings.Builder
+	out.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	out.WriteString(fmt.Sprintf("<title>%s</title>\n", escape(title)))
+	out.WriteString("<style>table{border-collapse:collapse}th,td{border:1px solid #ccc;padding:4px 8px;text-align:left}</style>\n")
+	out.WriteString("</head>\n<body>\n")
+	out.WriteString(fmt.Sprintf("<h1>%s</h1>\n", escape(title)))
+	if description.InitialState != "" {
+		out.WriteString(fmt.Sprintf("<p>Initial state: <code>%s</code></p>\n", escape(description.InitialState)))
+	}
+
+	out.WriteString("<h2>Diagram</h2>\n<pre class=\"mermaid\">\n")
+	out.WriteString(escape(ExportMermaid(def)))
+	out.WriteString("</pre>\n")
+
+	out.WriteString("<h2>States</h2>\n<table>\n<tr><th>State</th><th>Kind</th><th>Parent</th><th>Initial</th></tr>\n")
+	for _, state := range description.States {
+		initial := ""
+		if state.Initial {
+			initial = "yes"
+		}
+		out.WriteString(fmt.Sprintf("<tr><td><code>%s</code></td><td>%s</td><td>%s</td><td>%s</td></tr>\n",
+			escape(state.ID), escape(state.Kind), escape(state.Parent), initial))
+	}
+	out.WriteString("</table>\n")
+
+	out.WriteString("<h2>Transitions</h2>\n<table>\n<tr><th>From</th><th>Trigger</th><th>To</th><th>Guard</th><th>Notes</th></tr>\n")
+	for _, transition := range description.Transitions {
+		guard, notes := docTransitionNotes(transition)
+		out.WriteString(fmt.Sprintf("<tr><td><code>%s</code></td><td>%s</td><td><code>%s</code></td><td>%s</td><td>%s</td></tr>\n",
+			escape(transition.From), escape(transition.Trigger), escape(transition.To), escape(guard), escape(notes)))
+	}
+	out.WriteString("</table>\n")
+
+	states, cells := docMatrix(description)
+	out.WriteString("<h2>Transition Matrix</h2>\n<table>\n<tr><th>From \\ To</th>")
+	for _, target := range states {
+		out.WriteString(fmt.Sprintf("<th><code>%s</code></th>", escape(target)))
+	}
+	out.WriteString("</tr>\n")
+	for _, source := range states {
+		if len(cells[source]) == 0 {
+			continue
+		}
+		out.WriteString(fmt.Sprintf("<tr><th><code>%s</code></th>", escape(source)))
+		for _, target := range states {
+			out.WriteString(fmt.Sprintf("<td>%s</td>", escape(strings.Join(cells[source][target], ", "))))
+		}
+		out.WriteString("</tr>\n")
+	}
+	out.WriteString("</table>\n")
+
+	out.WriteString("<script type=\"module\">import mermaid from \"https://cdn.jsdelivr.net/npm/mermaid@10/dist/mermaid.esm.min.mjs\"; mermaid.initialize({ startOnLoad: true });</script>\n")
+	out.WriteString("</body>\n</html>\n")
+	return out.String()
+}
+
+// ExportCSV renders the flat transition matrix of a machine definition as CSV
+// with one row per transition or pseudostate branch: source, event, guard
+// description, target, actions and tags
+func ExportCSV(def MachineDefinition) ([]byte, error) {
+	description := Describe(def)
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	if err := writer.Write([]string{"source", "event", "guard", "target", "actions", "tags"}); err != nil {
+		return nil, err
+	}
+	for _, transition := range description.Transitions {
+		guard, _ := docTransitionNotes(transition)
+		record := []string{transition.From, transition.Trigger, guard, transition.To, docTransitionAction(transition), strings.Join(transition.Tags, ";")}
+		if err := writer.Write(record); err != nil {
+			return nil, err
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
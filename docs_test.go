@@ -0,0 +1,98 @@
+package fluo
+
+import (
+	"encoding/csv"
+	"strings"
+	"testing"
+)
+
+func buildDocsTestMachine() MachineDefinition {
+	return NewMachine().
+		State("draft").Initial().
+		To("review").On("submit").
+		When(func(ctx Context) bool { return true }).
+		Annotate("document has <b>all</b> | required fields").
+		State("review").
+		To("published").On("approve").
+		To("draft").On("reject").
+		State("published").Final().
+		Build()
+}
+
+func TestExportMarkdown(t *testing.T) {
+	doc := ExportMarkdown(buildDocsTestMachine(), "Document Approval")
+
+	expected := []string{
+		"# Document Approval",
+		"Initial state: `draft`",
+		"```mermaid\nstateDiagram-v2",
+		"| `draft` | atomic |  | yes |",
+		"| `published` | final |  |  |",
+		"| `draft` | submit | `review` | document has <b>all</b> \\| required fields |  |",
+		"| From \\ To | `draft` | `published` | `review` |",
+		"| `review` | reject | approve |  |",
+	}
+	for _, fragment := range expected {
+		if !strings.Contains(doc, fragment) {
+			t.Errorf("Expected markdown to contain %q\n%s", fragment, doc)
+		}
+	}
+}
+
+func TestExportHTML(t *testing.T) {
+	doc := ExportHTML(buildDocsTestMachine(), "Approval & Review")
+
+	expected := []string{
+		"<title>Approval &amp; Review</title>",
+		"<pre class=\"mermaid\">\nstateDiagram-v2",
+		"<td>document has &lt;b&gt;all&lt;/b&gt; | required fields</td>",
+		"<tr><th><code>review</code></th><td>reject</td><td>approve</td><td></td></tr>",
+	}
+	for _, fragment := range expected {
+		if !strings.Contains(doc, fragment) {
+			t.Errorf("Expected HTML to contain %q\n%s", fragment, doc)
+		}
+	}
+}
+
+func TestExportCSV(t *testing.T) {
+	builder := NewMachine()
+	builder.State("draft").Initial().
+		To("review").On("submit").
+		When(func(ctx Context) bool { return true }).
+		Annotate("all fields, including \"title\", are set").
+		Do(func(ctx Context) error { return nil }).
+		Tag("sox", "audit")
+	builder.State("review").
+		To("decide").On("assess")
+	builder.Choice("decide").
+		When(func(ctx Context) bool { return true }).To("draft").
+		Otherwise("published")
+	builder.State("published").Final()
+
+	data, err := ExportCSV(builder.Build())
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	records, err := csv.NewReader(strings.NewReader(string(data))).ReadAll()
+	if err != nil {
+		t.Fatalf("Expected valid CSV, got: %v", err)
+	}
+
+	expected := [][]string{
+		{"source", "event", "guard", "target", "actions", "tags"},
+		{"draft", "submit", "all fields, including \"title\", are set", "review", "transition", "sox;audit"},
+		{"review", "assess", "", "decide", "", ""},
+		{"decide", "branch", "guarded", "draft", "", ""},
+		{"decide", "else", "", "published", "", ""},
+	}
+	if len(records) != len(expected) {
+		t.Fatalf("Expected %d rows, got %d: %v", len(expected), len(records), records)
+	}
+	for i, row := range expected {
+		if strings.Join(records[i], "|") != strings.Join(row, "|") {
+			t.Errorf("Row %d: expected %v, got %v", i, row, records[i])
+		}
+	}
+}
@@ -1,6 +1,10 @@
 package fluo
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+	"time"
+)
 
 // ErrorCode represents specific error conditions in the state machine
 type ErrorCode int
@@ -26,6 +30,28 @@ const (
 	ErrCodeInvalidState
 	// Concurrent modification detected
 	ErrCodeConcurrentModification
+	// Event data or context exceeds a configured size limit
+	ErrCodePayloadTooLarge
+	// Guard or action exceeded its time budget
+	ErrCodeTimeout
+	// Several transitions of equal priority matched the same event
+	ErrCodeAmbiguousTransition
+	// No machine instance exists with the given ID
+	ErrCodeInstanceNotFound
+	// A machine instance with the given ID already exists
+	ErrCodeInstanceExists
+	// An actor mailbox is full or dropped the event
+	ErrCodeMailboxFull
+	// A machine instance completed and was archived
+	ErrCodeInstanceArchived
+	// A before-transition hook vetoed the selected transition
+	ErrCodeTransitionVetoed
+	// A snapshot was taken from an incompatible definition
+	ErrCodeIncompatibleSnapshot
+	// A guard panicked
+	ErrCodeGuardPanicked
+	// A do-activity panicked
+	ErrCodeActivityPanicked
 )
 
 // StateError represents state-related errors
@@ -111,6 +137,16 @@ func NewNoTransitionError(from, event string) *TransitionError {
 	}
 }
 
+// NewAmbiguousTransitionError creates an error for several equally ranked transitions matching one event
+func NewAmbiguousTransitionError(from, event string, targets []string) *TransitionError {
+	return &TransitionError{
+		Code:   ErrCodeAmbiguousTransition,
+		From:   from,
+		Event:  event,
+		Reason: fmt.Sprintf("ambiguous transitions from state '%s' for event '%s' to %s", from, event, strings.Join(targets, ", ")),
+	}
+}
+
 // GuardError represents guard condition failures
 type GuardError struct {
 	From  string
@@ -136,6 +172,32 @@ func NewGuardRejectedError(from, to, event string, guardName string) *GuardError
 	}
 }
 
+// VetoError represents a selected transition vetoed by a before-transition hook
+type VetoError struct {
+	From        string
+	To          string
+	Event       string
+	OriginalErr error
+}
+
+func (e *VetoError) Error() string {
+	return fmt.Sprintf("transition vetoed [%s->%s on %s]: %v", e.From, e.To, e.Event, e.OriginalErr)
+}
+
+func (e *VetoError) Unwrap() error {
+	return e.OriginalErr
+}
+
+// NewVetoError creates a new transition veto error
+func NewVetoError(from, to, event string, err error) *VetoError {
+	return &VetoError{
+		From:        from,
+		To:          to,
+		Event:       event,
+		OriginalErr: err,
+	}
+}
+
 // ConfigurationError represents machine configuration issues
 type ConfigurationError struct {
 	Component string
@@ -183,6 +245,51 @@ func NewMachineError(code ErrorCode, operation string, message string) *MachineE
 	}
 }
 
+// NewInstanceNotFoundError creates an error for an unknown machine instance ID
+func NewInstanceNotFoundError(id string) *MachineError {
+	return &MachineError{
+		Code:      ErrCodeInstanceNotFound,
+		Operation: "lookup",
+		Message:   fmt.Sprintf("instance '%s' not found", id),
+	}
+}
+
+// NewInstanceArchivedError creates an error for a machine instance that was archived
+func NewInstanceArchivedError(id string) *MachineError {
+	return &MachineError{
+		Code:      ErrCodeInstanceArchived,
+		Operation: "lookup",
+		Message:   fmt.Sprintf("instance '%s' was archived", id),
+	}
+}
+
+// NewMailboxFullError creates an error for an event an actor mailbox could not hold
+func NewMailboxFullError(operation string, size int) *MachineError {
+	return &MachineError{
+		Code:      ErrCodeMailboxFull,
+		Operation: operation,
+		Message:   fmt.Sprintf("actor mailbox of %d events is full", size),
+	}
+}
+
+// NewIncompatibleSnapshotError creates an error for a snapshot taken from an incompatible definition
+func NewIncompatibleSnapshotError(reason string) *MachineError {
+	return &MachineError{
+		Code:      ErrCodeIncompatibleSnapshot,
+		Operation: "restore",
+		Message:   reason,
+	}
+}
+
+// NewActorStoppedError creates an error for an event sent to a stopped actor
+func NewActorStoppedError(operation string) *MachineError {
+	return &MachineError{
+		Code:      ErrCodeMachineNotStarted,
+		Operation: operation,
+		Message:   "actor is stopped",
+	}
+}
+
 // ActionError represents action execution errors
 type ActionError struct {
 	Action      string
@@ -210,6 +317,51 @@ func NewActionError(action, state string, err error) *ActionError {
 	}
 }
 
+// PayloadError represents event data or context exceeding a configured size limit
+type PayloadError struct {
+	Kind  string // "event" or "context"
+	Key   string // Event name or context key that exceeded the limit
+	Size  int
+	Limit int
+}
+
+func (e *PayloadError) Error() string {
+	return fmt.Sprintf("%s payload too large [%s]: %d bytes exceeds limit of %d bytes", e.Kind, e.Key, e.Size, e.Limit)
+}
+
+// NewPayloadTooLargeError creates a new payload size limit error
+func NewPayloadTooLargeError(kind, key string, size, limit int) *PayloadError {
+	return &PayloadError{
+		Kind:  kind,
+		Key:   key,
+		Size:  size,
+		Limit: limit,
+	}
+}
+
+// TimeoutError represents a guard or action that exceeded its time budget
+type TimeoutError struct {
+	Kind    string // "guard" or "action"
+	State   string
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	if e.State != "" {
+		return fmt.Sprintf("%s timed out in state '%s' after %s", e.Kind, e.State, e.Timeout)
+	}
+	return fmt.Sprintf("%s timed out after %s", e.Kind, e.Timeout)
+}
+
+// NewTimeoutError creates a new timeout error
+func NewTimeoutError(kind, state string, timeout time.Duration) *TimeoutError {
+	return &TimeoutError{
+		Kind:    kind,
+		State:   state,
+		Timeout: timeout,
+	}
+}
+
 // IsStateError checks if an error is a StateError
 func IsStateError(err error) bool {
 	_, ok := err.(*StateError)
@@ -228,6 +380,12 @@ func IsGuardError(err error) bool {
 	return ok
 }
 
+// IsVetoError checks if an error is a VetoError
+func IsVetoError(err error) bool {
+	_, ok := err.(*VetoError)
+	return ok
+}
+
 // IsConfigurationError checks if an error is a ConfigurationError
 func IsConfigurationError(err error) bool {
 	_, ok := err.(*ConfigurationError)
@@ -246,6 +404,18 @@ func IsActionError(err error) bool {
 	return ok
 }
 
+// IsPayloadError checks if an error is a PayloadError
+func IsPayloadError(err error) bool {
+	_, ok := err.(*PayloadError)
+	return ok
+}
+
+// IsTimeoutError checks if an error is a TimeoutError
+func IsTimeoutError(err error) bool {
+	_, ok := err.(*TimeoutError)
+	return ok
+}
+
 // GetErrorCode returns the error code for known error types
 func GetErrorCode(err error) ErrorCode {
 	switch e := err.(type) {
@@ -257,10 +427,18 @@ func GetErrorCode(err error) ErrorCode {
 		return e.Code
 	case *GuardError:
 		return ErrCodeGuardRejected
+	case *VetoError:
+		return ErrCodeTransitionVetoed
 	case *ConfigurationError:
 		return ErrCodeInvalidConfiguration
 	case *ActionError:
 		return ErrCodeActionFailed
+	case *PayloadError:
+		return ErrCodePayloadTooLarge
+	case *TimeoutError:
+		return ErrCodeTimeout
+	case *PanicError:
+		return e.code()
 	default:
 		return ErrCodeNone
 	}
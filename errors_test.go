@@ -16,6 +16,17 @@ func TestErrors_ErrorCode(t *testing.T) {
 		ErrCodeInvalidConfiguration,
 		ErrCodeInvalidState,
 		ErrCodeConcurrentModification,
+		ErrCodePayloadTooLarge,
+		ErrCodeTimeout,
+		ErrCodeAmbiguousTransition,
+		ErrCodeInstanceNotFound,
+		ErrCodeInstanceExists,
+		ErrCodeMailboxFull,
+		ErrCodeInstanceArchived,
+		ErrCodeTransitionVetoed,
+		ErrCodeIncompatibleSnapshot,
+		ErrCodeGuardPanicked,
+		ErrCodeActivityPanicked,
 	}
 
 	for i, code := range testCases {
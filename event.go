@@ -1,6 +1,7 @@
 package fluo
 
 import (
+	"fmt"
 	"time"
 )
 
@@ -80,6 +81,85 @@ type EventResult struct {
 	CurrentState    string
 	Error           error
 	RejectionReason string
+
+	// RejectionCode classifies why the event was not processed
+	RejectionCode RejectionCode
+
+	// FailedGuard and FailedAction identify the guard or action behind a
+	// RejectionGuardFailed or RejectionActionFailed result: the registered
+	// name, or "<source>-><target> on <event>" when it has none
+	FailedGuard  string
+	FailedAction string
+
+	// RegionTransitions lists the transitions parallel regions took while the
+	// event was processed, in the order they were taken
+	RegionTransitions []RegionTransition
+
+	// Panic is the first guard or action panic recovered while the event was
+	// processed, whatever the panic policy made of it
+	Panic *PanicError
+}
+
+// RejectionCode classifies why an event was not processed
+type RejectionCode int
+
+const (
+	// RejectionNone means the event was processed
+	RejectionNone RejectionCode = iota
+	// RejectionNotStarted means the machine or instance was not running
+	RejectionNotStarted
+	// RejectionNoTransition means no transition is declared for the event in the active states
+	RejectionNoTransition
+	// RejectionGuardFailed means transitions for the event exist but their guards failed
+	RejectionGuardFailed
+	// RejectionActionFailed means the transition action failed and no error state took over
+	RejectionActionFailed
+	// RejectionInvalidEvent means the event name or data was rejected before routing
+	RejectionInvalidEvent
+	// RejectionTerminated means the machine, region or actor handling the event has finished
+	RejectionTerminated
+	// RejectionCancelled means the event's context was done before it was processed
+	RejectionCancelled
+	// RejectionAmbiguous means several transitions fired under ErrorOnAmbiguity
+	RejectionAmbiguous
+	// RejectionVetoed means a before-transition hook vetoed the selected transition
+	RejectionVetoed
+)
+
+// String returns the code name
+func (c RejectionCode) String() string {
+	switch c {
+	case RejectionNone:
+		return "None"
+	case RejectionNotStarted:
+		return "NotStarted"
+	case RejectionNoTransition:
+		return "NoTransition"
+	case RejectionGuardFailed:
+		return "GuardFailed"
+	case RejectionActionFailed:
+		return "ActionFailed"
+	case RejectionInvalidEvent:
+		return "InvalidEvent"
+	case RejectionTerminated:
+		return "Terminated"
+	case RejectionCancelled:
+		return "Cancelled"
+	case RejectionAmbiguous:
+		return "Ambiguous"
+	case RejectionVetoed:
+		return "Vetoed"
+	default:
+		return fmt.Sprintf("RejectionCode(%d)", int(c))
+	}
+}
+
+// RegionTransition is a transition a parallel region took between two of its states
+type RegionTransition struct {
+	ParallelState string
+	Region        string
+	From          string
+	To            string
 }
 
 // NewEventResult creates a new event result
@@ -105,6 +185,12 @@ func (r *EventResult) WithRejection(reason string) *EventResult {
 	return r
 }
 
+// WithRejectionCode classifies a rejected event
+func (r *EventResult) WithRejectionCode(code RejectionCode) *EventResult {
+	r.RejectionCode = code
+	return r
+}
+
 // Success returns true if the event was processed successfully
 func (r *EventResult) Success() bool {
 	return r.Processed && r.Error == nil
@@ -1,6 +1,7 @@
 package fluo
 
 import (
+	"errors"
 	"reflect"
 	"testing"
 	"time"
@@ -496,3 +497,54 @@ func compareValues(a, b any) bool {
 
 	return reflect.DeepEqual(a, b)
 }
+
+func TestEventResult_RejectionCodes(t *testing.T) {
+	RegisterGuard("test.hasStock", func(ctx Context) bool { return false })
+	definition := NewMachine().
+		State("idle").Initial().
+		To("reserved").On("reserve").WhenGuard("test.hasStock").
+		To("busy").On("start").When(func(ctx Context) bool { return false }).
+		To("failed").On("fail").Do(func(ctx Context) error { return errors.New("boom") }).
+		To("done").On("finish").
+		State("reserved").
+		State("busy").
+		State("failed").
+		State("done").Final().
+		Build()
+
+	machine := definition.CreateInstance()
+	if result := machine.HandleEvent("start", nil); result.RejectionCode != RejectionNotStarted {
+		t.Errorf("Expected NotStarted, got %s", result.RejectionCode)
+	}
+	_ = machine.Start()
+
+	cases := []struct {
+		event        string
+		code         RejectionCode
+		failedGuard  string
+		failedAction string
+	}{
+		{"", RejectionInvalidEvent, "", ""},
+		{"unknown", RejectionNoTransition, "", ""},
+		{"reserve", RejectionGuardFailed, "test.hasStock", ""},
+		{"start", RejectionGuardFailed, "idle->busy on start", ""},
+		{"fail", RejectionActionFailed, "", "idle->failed on fail"},
+	}
+	for _, tc := range cases {
+		result := machine.HandleEvent(tc.event, nil)
+		if result.Processed || result.RejectionCode != tc.code {
+			t.Errorf("Event %q: expected %s, got %s", tc.event, tc.code, result.RejectionCode)
+		}
+		if result.FailedGuard != tc.failedGuard || result.FailedAction != tc.failedAction {
+			t.Errorf("Event %q: expected guard %q and action %q, got %q and %q",
+				tc.event, tc.failedGuard, tc.failedAction, result.FailedGuard, result.FailedAction)
+		}
+	}
+
+	if result := machine.HandleEvent("finish", nil); result.RejectionCode != RejectionNone {
+		t.Errorf("Expected a processed event to have no code, got %s", result.RejectionCode)
+	}
+	if result := machine.HandleEvent("start", nil); result.RejectionCode != RejectionTerminated {
+		t.Errorf("Expected Terminated in a final state, got %s", result.RejectionCode)
+	}
+}
@@ -0,0 +1,98 @@
+package fluo
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// EventLog is an append-only record of the events a machine accepted
+type EventLog interface {
+	// Append records an accepted event
+	Append(entry JournalEntry) error
+	// Entries returns the recorded events in the order they were accepted
+	Entries() ([]JournalEntry, error)
+}
+
+// MemoryEventLog is an EventLog keeping its entries in memory
+type MemoryEventLog struct {
+	entries []JournalEntry
+	mutex   sync.RWMutex
+}
+
+// NewMemoryEventLog creates an empty in-memory event log
+func NewMemoryEventLog() *MemoryEventLog {
+	return &MemoryEventLog{}
+}
+
+// Append records an accepted event
+func (l *MemoryEventLog) Append(entry JournalEntry) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.entries = append(l.entries, entry)
+	return nil
+}
+
+// Entries returns a copy of the recorded events
+func (l *MemoryEventLog) Entries() ([]JournalEntry, error) {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	return slices.Clone(l.entries), nil
+}
+
+// WithEventLog enables event sourcing: every event the machine accepts is
+// appended to the log with its payload and the configuration it produced.
+// Events raised by timers, do-activities and submachines are recorded too;
+// completions triggered while processing another event are not, since
+// replaying that event reproduces them. Append failures are reported to
+// observers as errors.
+func WithEventLog(log EventLog) MachineOption {
+	return func(sm *StateMachine) {
+		sm.eventLog = log
+	}
+}
+
+// recordEvent appends an accepted event to the event log and the time-travel
+// timeline, whichever are enabled. The caller must hold the machine mutex.
+func (sm *StateMachine) recordEvent(eventName string, eventData any, result *EventResult) {
+	entry := JournalEntry{
+		EventName:     eventName,
+		EventData:     eventData,
+		Timestamp:     time.Now(),
+		Processed:     result.Processed,
+		PreviousState: result.PreviousState,
+		CurrentState:  result.CurrentState,
+		ActiveStates:  sm.activeStateSet(),
+	}
+	if sm.timeline != nil {
+		sm.timeline.record(sm, entry)
+	}
+	if sm.eventLog == nil {
+		return
+	}
+	if err := sm.eventLog.Append(entry); err != nil {
+		sm.observers.NotifyError(fmt.Errorf("failed to append event '%s' to the event log: %w", eventName, err), sm.context)
+	}
+}
+
+// Replay reconstitutes a machine by starting a new instance of the definition
+// and sending it the logged events in order. Context values set outside of
+// actions are not part of the log and must be supplied through opts or set
+// before replaying. An event that is no longer accepted stops the replay.
+func Replay(def MachineDefinition, events []JournalEntry, opts ...MachineOption) (Machine, error) {
+	machine := def.CreateInstance(opts...)
+	if err := machine.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start replay machine: %w", err)
+	}
+
+	for i, entry := range events {
+		result := machine.HandleEvent(entry.EventName, entry.EventData)
+		if !result.Processed {
+			return machine, NewMachineError(ErrCodeInvalidEvent, "replay",
+				fmt.Sprintf("event %d ('%s') was rejected in state '%s': %s", i, entry.EventName, result.CurrentState, result.RejectionReason))
+		}
+	}
+
+	return machine, nil
+}
@@ -0,0 +1,121 @@
+package fluo
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func buildEventLogDefinition() MachineDefinition {
+	builder := NewMachine()
+	builder.State("draft").Initial().
+		To("review").On("submit").
+		Do(func(ctx Context) error {
+			ctx.Set("submitted", true)
+			return nil
+		})
+	builder.State("review").
+		To("approved").On("approve").
+		To("draft").On("reject")
+	builder.State("approved").
+		After(10 * time.Millisecond).To("archived")
+	builder.State("archived")
+	return builder.Build()
+}
+
+func TestEventLog_RecordsAcceptedEvents(t *testing.T) {
+	log := NewMemoryEventLog()
+	machine := buildEventLogDefinition().CreateInstance(WithEventLog(log))
+	_ = machine.Start()
+
+	machine.HandleEvent("submit", "v1")
+	machine.HandleEvent("unknown", nil)
+	machine.HandleEvent("reject", nil)
+	machine.HandleEvent("submit", "v2")
+
+	entries, _ := log.Entries()
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 accepted events, got %d", len(entries))
+	}
+	if entries[0].EventName != "submit" || entries[0].EventData != "v1" || entries[0].CurrentState != "review" {
+		t.Errorf("Unexpected first entry: %+v", entries[0])
+	}
+	if entries[1].PreviousState != "review" || entries[1].CurrentState != "draft" {
+		t.Errorf("Unexpected second entry: %+v", entries[1])
+	}
+	if entries[2].Timestamp.IsZero() || len(entries[2].ActiveStates) != 1 || entries[2].ActiveStates[0] != "review" {
+		t.Errorf("Unexpected third entry: %+v", entries[2])
+	}
+}
+
+func TestEventLog_RecordsTimedEvents(t *testing.T) {
+	log := NewMemoryEventLog()
+	machine := buildEventLogDefinition().CreateInstance(WithEventLog(log))
+	_ = machine.Start()
+
+	machine.HandleEvent("submit", nil)
+	machine.HandleEvent("approve", nil)
+
+	deadline := time.Now().Add(time.Second)
+	for machine.CurrentState() != "archived" && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	entries, _ := log.Entries()
+	if len(entries) != 3 || entries[2].CurrentState != "archived" {
+		t.Fatalf("Expected the timed transition to be logged, got %+v", entries)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	def := buildEventLogDefinition()
+	log := NewMemoryEventLog()
+	machine := def.CreateInstance(WithEventLog(log))
+	_ = machine.Start()
+	machine.HandleEvent("submit", nil)
+	machine.HandleEvent("reject", nil)
+	machine.HandleEvent("submit", nil)
+
+	entries, _ := log.Entries()
+	replayed, err := Replay(def, entries)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	AssertState(t, replayed, "review")
+	if submitted, _ := replayed.Context().Get("submitted"); submitted != true {
+		t.Error("Expected replayed actions to rebuild the context")
+	}
+
+	report, err := VerifyReplay(def, entries, 2)
+	if err != nil || !report.Deterministic() {
+		t.Errorf("Expected the log to verify, got %+v, %v", report, err)
+	}
+}
+
+func TestReplay_RejectedEvent(t *testing.T) {
+	entries := []JournalEntry{{EventName: "submit"}, {EventName: "archive"}}
+
+	machine, err := Replay(buildEventLogDefinition(), entries)
+	if GetErrorCode(err) != ErrCodeInvalidEvent {
+		t.Fatalf("Expected replay to stop at the rejected event, got %v", err)
+	}
+	AssertState(t, machine, "review")
+}
+
+type failingEventLog struct{ MemoryEventLog }
+
+func (l *failingEventLog) Append(entry JournalEntry) error {
+	return errors.New("disk full")
+}
+
+func TestEventLog_AppendFailureNotifiesObservers(t *testing.T) {
+	observer := NewTestObserver()
+	machine := buildEventLogDefinition().CreateInstance(WithEventLog(&failingEventLog{}))
+	machine.AddObserver(observer)
+	_ = machine.Start()
+
+	AssertEventProcessed(t, machine.HandleEvent("submit", nil), true)
+	if len(observer.Errors) != 1 {
+		t.Fatalf("Expected one error notification, got %d", len(observer.Errors))
+	}
+}
@@ -0,0 +1,358 @@
+package fluo
+
+import (
+	"context"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultEventQueueSize is the queue size used by WithEventLoop when none is given
+const DefaultEventQueueSize = 100
+
+// WithEventLoop enables event-loop mode: the machine owns a goroutine and a bounded
+// queue per EventPriority, and events are processed strictly one at a time,
+// by priority and then in arrival order. SendEventAsync returns immediately
+// with a channel delivering the EventResult, while SendEvent and HandleEvent
+// enqueue and wait for the result.
+// Other goroutines block while the queue of their event's priority is full.
+// Actions may enqueue follow-up events with SendEventAsync; they are processed
+// after the current run-to-completion step, and overflow a full queue rather
+// than block the loop.
+func WithEventLoop(queueSize int) MachineOption {
+	if queueSize <= 0 {
+		queueSize = DefaultEventQueueSize
+	}
+	return func(sm *StateMachine) {
+		sm.eventLoop = newEventLoop(queueSize)
+	}
+}
+
+// EventPriority orders the events waiting in the event loop: queued events of
+// a higher priority are processed before those of a lower one, and events of
+// equal priority in arrival order
+type EventPriority int
+
+const (
+	// PriorityLow events wait until no other event is queued
+	PriorityLow EventPriority = -1
+	// PriorityNormal is the priority of events sent without one, and of timers,
+	// do-activity and submachine completions
+	PriorityNormal EventPriority = 0
+	// PriorityHigh events go ahead of normal ones
+	PriorityHigh EventPriority = 1
+	// PriorityCritical events go ahead of everything else
+	PriorityCritical EventPriority = 2
+)
+
+// priorityLanes is the number of priority levels, one queue each
+const priorityLanes = int(PriorityCritical-PriorityLow) + 1
+
+// lane returns the index of the queue holding events of the priority,
+// clamping priorities outside the defined levels
+func (p EventPriority) lane() int {
+	return int(min(max(p, PriorityLow), PriorityCritical) - PriorityLow)
+}
+
+// queuedEvent is a unit of work waiting in the event loop
+type queuedEvent struct {
+	ctx      context.Context
+	name     string
+	data     any
+	priority EventPriority
+	timer    *stateTimer
+	result   chan *EventResult
+
+	activity *runningActivity // Finished do-activity, with its error
+	err      error
+
+	submachine *runningSubmachine // Child instance that reached a final state
+}
+
+// eventLoop processes queued events on a dedicated goroutine
+type eventLoop struct {
+	lanes   [priorityLanes]chan *queuedEvent // One bounded queue per priority, lowest first
+	mutex   sync.RWMutex                     // Held for reading by enqueuers so stop never strands a queued event
+	running bool
+	quit    chan struct{}
+	done    chan struct{}
+
+	// Events an action of the event being processed sends to a full queue wait
+	// here instead of blocking the loop goroutine that drains the queue
+	dispatching   atomic.Bool
+	goroutine     atomic.Uint64 // ID of the loop goroutine
+	overflow      [priorityLanes][]*queuedEvent
+	drained       [priorityLanes]chan struct{} // Closed once the overflow of a lane is processed
+	overflowMutex sync.Mutex
+	wake          chan struct{} // Signals a waiting loop that an event overflowed
+}
+
+// newEventLoop creates an event loop with a bounded queue
+func newEventLoop(queueSize int) *eventLoop {
+	l := &eventLoop{wake: make(chan struct{}, 1)}
+	for i := range l.lanes {
+		l.lanes[i] = make(chan *queuedEvent, queueSize)
+	}
+	return l
+}
+
+// start launches the loop goroutine if it is not already running
+func (l *eventLoop) start(sm *StateMachine) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.running {
+		return
+	}
+
+	l.running = true
+	l.quit = make(chan struct{})
+	l.done = make(chan struct{})
+	go l.run(sm, l.quit, l.done)
+}
+
+// stop signals the loop goroutine to drain the queue and waits for it to exit
+func (l *eventLoop) stop() {
+	l.mutex.Lock()
+	if !l.running {
+		l.mutex.Unlock()
+		return
+	}
+	l.running = false
+	close(l.quit)
+	done := l.done
+	l.mutex.Unlock()
+
+	<-done
+}
+
+// enqueue adds an event to the queue of its priority, blocking while that
+// queue is full or overflows. Only the loop goroutine, sending from an action
+// of the event it processes, overflows a full queue instead, since blocking it
+// would stop the queue from draining. It returns false if the loop is not
+// running or the event's context is done.
+func (l *eventLoop) enqueue(item *queuedEvent) bool {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	if !l.running {
+		return false
+	}
+
+	lane := item.priority.lane()
+	for {
+		l.overflowMutex.Lock()
+		if len(l.overflow[lane]) == 0 {
+			select {
+			case l.lanes[lane] <- item:
+				l.overflowMutex.Unlock()
+				return true
+			default:
+			}
+		}
+		if l.onLoop() {
+			l.overflowLocked(lane, item)
+			l.overflowMutex.Unlock()
+			return true
+		}
+		overflowing := len(l.overflow[lane]) > 0
+		drained := l.drained[lane]
+		l.overflowMutex.Unlock()
+
+		if !overflowing {
+			select {
+			case l.lanes[lane] <- item:
+				return true
+			case <-item.ctx.Done():
+				return false
+			}
+		}
+		// Overflowed events go first, keeping the events of a lane in order
+		select {
+		case <-drained:
+		case <-item.ctx.Done():
+			return false
+		}
+	}
+}
+
+// onLoop reports whether the caller runs on the loop goroutine while it
+// processes an event
+func (l *eventLoop) onLoop() bool {
+	return l.dispatching.Load() && l.goroutine.Load() == goroutineID()
+}
+
+// overflowLocked appends an event to the overflow of its lane and wakes the
+// loop. The caller must hold the overflow mutex.
+func (l *eventLoop) overflowLocked(lane int, item *queuedEvent) {
+	if len(l.overflow[lane]) == 0 {
+		l.drained[lane] = make(chan struct{})
+	}
+	l.overflow[lane] = append(l.overflow[lane], item)
+	select {
+	case l.wake <- struct{}{}:
+	default:
+	}
+}
+
+// goroutineID returns the ID of the calling goroutine, parsed from the first
+// line of its stack trace
+func goroutineID() uint64 {
+	var buf [64]byte
+	stack := strings.TrimPrefix(string(buf[:runtime.Stack(buf[:], false)]), "goroutine ")
+	id, _ := strconv.ParseUint(stack[:strings.IndexByte(stack, ' ')], 10, 64)
+	return id
+}
+
+// next returns the queued event of the highest priority, or nil when every
+// queue is empty. Overflowed events follow the events queued before them.
+func (l *eventLoop) next() *queuedEvent {
+	for i := len(l.lanes) - 1; i >= 0; i-- {
+		select {
+		case item := <-l.lanes[i]:
+			return item
+		default:
+		}
+
+		l.overflowMutex.Lock()
+		if overflow := l.overflow[i]; len(overflow) > 0 {
+			item := overflow[0]
+			overflow[0] = nil
+			l.overflow[i] = overflow[1:]
+			if len(l.overflow[i]) == 0 {
+				close(l.drained[i])
+			}
+			l.overflowMutex.Unlock()
+			return item
+		}
+		l.overflowMutex.Unlock()
+	}
+	return nil
+}
+
+// wait blocks until an event is queued or overflowed, or quit is closed, with
+// one case per lane. It returns nil and false when an event overflowed.
+func (l *eventLoop) wait(quit chan struct{}) (*queuedEvent, bool) {
+	select {
+	case item := <-l.lanes[3]:
+		return item, false
+	case item := <-l.lanes[2]:
+		return item, false
+	case item := <-l.lanes[1]:
+		return item, false
+	case item := <-l.lanes[0]:
+		return item, false
+	case <-l.wake:
+		return nil, false
+	case <-quit:
+		return nil, true
+	}
+}
+
+// run processes queued events until quit is closed, then drains what is left
+func (l *eventLoop) run(sm *StateMachine, quit, done chan struct{}) {
+	defer close(done)
+	l.goroutine.Store(goroutineID())
+
+	for {
+		item := l.next()
+		if item == nil {
+			var stopping bool
+			if item, stopping = l.wait(quit); stopping {
+				// Stopping: drain what is left, highest priority first
+				for item := l.next(); item != nil; item = l.next() {
+					l.dispatch(sm, item)
+				}
+				return
+			}
+			if item == nil {
+				continue
+			}
+		}
+		l.dispatch(sm, item)
+	}
+}
+
+// dispatch processes an event, letting full queues overflow meanwhile
+func (l *eventLoop) dispatch(sm *StateMachine, item *queuedEvent) {
+	l.dispatching.Store(true)
+	defer l.dispatching.Store(false)
+	sm.dispatchQueuedEvent(item)
+}
+
+// dispatchQueuedEvent processes a single queued event under the machine mutex.
+// Sent events pass through the middleware chain first.
+func (sm *StateMachine) dispatchQueuedEvent(item *queuedEvent) {
+	var result *EventResult
+	if item.timer == nil && item.activity == nil && item.submachine == nil {
+		result = sm.handleEvent(item.ctx, item.name, item.data)
+	} else {
+		if item.timer != nil {
+			unlock := sm.lockForEvent(item.timer.eventName)
+			result = sm.fireTimerLocked(item.timer)
+			unlock()
+		} else if item.activity != nil {
+			unlock := sm.lockForEvent("__completion_" + item.activity.stateID)
+			result = sm.finishActivityLocked(item.activity, item.err)
+			unlock()
+		} else {
+			unlock := sm.lockForEvent("__completion_" + item.submachine.stateID)
+			result = sm.completeSubmachineLocked(item.submachine)
+			unlock()
+		}
+	}
+
+	if item.result != nil {
+		item.result <- result
+	}
+}
+
+// SendEventAsync sends an event without waiting for it to be processed
+func (sm *StateMachine) SendEventAsync(eventName string, eventData any) <-chan *EventResult {
+	return sm.SendEventAsyncWithContext(context.Background(), eventName, eventData)
+}
+
+// SendEventAsyncWithContext sends an event with context without waiting for it to be processed.
+// The returned channel receives exactly one EventResult. Without event-loop mode the
+// event is processed before returning and the channel is already filled.
+func (sm *StateMachine) SendEventAsyncWithContext(ctx context.Context, eventName string, eventData any) <-chan *EventResult {
+	return sm.sendEventAsync(ctx, eventName, eventData, PriorityNormal)
+}
+
+// SendEventWithPriority sends an event without waiting for it to be processed.
+// In event-loop mode it is processed before queued events of a lower priority;
+// otherwise it is processed before returning, like SendEventAsync.
+func (sm *StateMachine) SendEventWithPriority(eventName string, eventData any, priority EventPriority) <-chan *EventResult {
+	return sm.sendEventAsync(context.Background(), eventName, eventData, priority)
+}
+
+// sendEventAsync queues an event of the given priority, or processes it
+// directly without an event loop
+func (sm *StateMachine) sendEventAsync(ctx context.Context, eventName string, eventData any, priority EventPriority) <-chan *EventResult {
+	result := make(chan *EventResult, 1)
+
+	if sm.eventLoop != nil {
+		item := &queuedEvent{
+			ctx:      ctx,
+			name:     eventName,
+			data:     eventData,
+			priority: priority,
+			result:   result,
+		}
+		if sm.eventLoop.enqueue(item) {
+			return result
+		}
+		if ctx.Err() != nil {
+			result <- NewEventResult(false, false, "", "").
+				WithRejection("event cancelled before it was queued").
+				WithRejectionCode(RejectionCancelled).
+				WithError(ctx.Err())
+			return result
+		}
+	}
+
+	result <- sm.handleEvent(ctx, eventName, eventData)
+	return result
+}
@@ -0,0 +1,433 @@
+package fluo
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEventLoop_AsyncResult(t *testing.T) {
+	definition := NewMachine().
+		State("idle").Initial().
+		To("running").On("start").
+		State("running").
+		Build()
+
+	machine := definition.CreateInstance(WithEventLoop(10))
+	_ = machine.Start()
+	defer func() { _ = machine.Stop() }()
+
+	select {
+	case result := <-machine.SendEventAsync("start", nil):
+		if !result.Success() {
+			t.Fatalf("Expected event to succeed, got %+v", result)
+		}
+		if result.CurrentState != "running" {
+			t.Errorf("Expected running, got %s", result.CurrentState)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for event result")
+	}
+
+	AssertState(t, machine, "running")
+}
+
+func TestEventLoop_ProcessesInOrder(t *testing.T) {
+	var order []int
+	definition := NewMachine().
+		State("counting").Initial().
+		ToSelf().On("tick").Do(func(ctx Context) error {
+		order = append(order, ctx.GetEventData().(int))
+		return nil
+	}).
+		Build()
+
+	machine := definition.CreateInstance(WithEventLoop(5))
+	_ = machine.Start()
+
+	results := make([]<-chan *EventResult, 0, 20)
+	for i := 0; i < 20; i++ {
+		results = append(results, machine.SendEventAsync("tick", i))
+	}
+	for _, result := range results {
+		<-result
+	}
+	_ = machine.Stop()
+
+	if len(order) != 20 {
+		t.Fatalf("Expected 20 processed events, got %d", len(order))
+	}
+	for i, value := range order {
+		if value != i {
+			t.Fatalf("Expected events in order, got %v", order)
+		}
+	}
+}
+
+func TestEventLoop_RunToCompletion(t *testing.T) {
+	var trace []string
+	definition := NewMachine().
+		State("a").Initial().
+		To("b").On("go").Do(func(ctx Context) error {
+		ctx.GetMachine().SendEventAsync("next", nil)
+		trace = append(trace, "action:go")
+		return nil
+	}).
+		State("b").
+		OnEntry(func(ctx Context) error {
+			trace = append(trace, "enter:b")
+			return nil
+		}).
+		To("c").On("next").
+		State("c").
+		Build()
+
+	machine := definition.CreateInstance(WithEventLoop(10))
+	_ = machine.Start()
+	defer func() { _ = machine.Stop() }()
+
+	result := machine.SendEvent("go", nil)
+	if !result.Success() {
+		t.Fatalf("Expected go to succeed, got %+v", result)
+	}
+
+	waitForState(t, machine, "c", time.Second)
+
+	if len(trace) != 2 || trace[0] != "action:go" || trace[1] != "enter:b" {
+		t.Errorf("Expected internal event to run after the current step, got %v", trace)
+	}
+}
+
+func TestEventLoop_ConcurrentSenders(t *testing.T) {
+	count := 0
+	definition := NewMachine().
+		State("counting").Initial().
+		ToSelf().On("tick").Do(func(ctx Context) error {
+		count++
+		return nil
+	}).
+		Build()
+
+	machine := definition.CreateInstance(WithEventLoop(4))
+	_ = machine.Start()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				machine.HandleEvent("tick", nil)
+			}
+		}()
+	}
+	wg.Wait()
+	_ = machine.Stop()
+
+	if count != 100 {
+		t.Errorf("Expected 100 processed events, got %d", count)
+	}
+}
+
+func TestEventLoop_StoppedMachineRejects(t *testing.T) {
+	definition := NewMachine().
+		State("idle").Initial().
+		To("running").On("start").
+		State("running").
+		Build()
+	machine := definition.CreateInstance(WithEventLoop(1))
+
+	result := <-machine.SendEventAsync("start", nil)
+	if result.Processed {
+		t.Error("Expected event to be rejected before start")
+	}
+
+	_ = machine.Start()
+	_ = machine.Stop()
+
+	result = machine.SendEvent("start", nil)
+	if result.Processed {
+		t.Error("Expected event to be rejected after stop")
+	}
+
+	if err := machine.Start(); err != nil {
+		t.Fatalf("Expected restart to succeed, got %v", err)
+	}
+	defer func() { _ = machine.Stop() }()
+}
+
+func TestEventLoop_StopDrainsQueue(t *testing.T) {
+	var processed []int
+	release := make(chan struct{})
+	definition := NewMachine().
+		State("counting").Initial().
+		ToSelf().On("tick").Do(func(ctx Context) error {
+		if ctx.GetEventData().(int) == 0 {
+			<-release
+		}
+		processed = append(processed, ctx.GetEventData().(int))
+		return nil
+	}).
+		Build()
+	machine := definition.CreateInstance(WithEventLoop(10))
+	_ = machine.Start()
+
+	results := make([]<-chan *EventResult, 0, 5)
+	for i := 0; i < 5; i++ {
+		results = append(results, machine.SendEventAsync("tick", i))
+	}
+	stopped := make(chan error, 1)
+	go func() { stopped <- machine.Stop() }()
+	close(release)
+
+	if err := <-stopped; err != nil {
+		t.Fatalf("Expected Stop to succeed, got %v", err)
+	}
+	for i, result := range results {
+		if r := <-result; !r.Processed {
+			t.Errorf("Expected queued event %d to be processed before stopping, got %v", i, r.RejectionReason)
+		}
+	}
+	if len(processed) != 5 {
+		t.Errorf("Expected 5 processed events, got %v", processed)
+	}
+}
+
+func TestEventLoop_CancelledContext(t *testing.T) {
+	blocker := make(chan struct{})
+	definition := NewMachine().
+		State("idle").Initial().
+		ToSelf().On("block").Do(func(ctx Context) error {
+		<-blocker
+		return nil
+	}).
+		Build()
+
+	machine := definition.CreateInstance(WithEventLoop(1))
+	_ = machine.Start()
+
+	machine.SendEventAsync("block", nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	result := machine.HandleEventWithContext(ctx, "block", nil)
+	if result.Processed || result.Error == nil {
+		t.Errorf("Expected cancelled result, got %+v", result)
+	}
+
+	close(blocker)
+	_ = machine.Stop()
+}
+
+func TestEventLoop_TimedTransition(t *testing.T) {
+	definition := NewMachine().
+		State("booting").Initial().
+		After(10 * time.Millisecond).To("ready").
+		State("ready").
+		Build()
+
+	machine := definition.CreateInstance(WithEventLoop(10))
+	_ = machine.Start()
+	defer func() { _ = machine.Stop() }()
+
+	waitForState(t, machine, "ready", time.Second)
+}
+
+func TestEventLoop_Priority(t *testing.T) {
+	var mutex sync.Mutex
+	var order []string
+	record := func(name string) ActionFunc {
+		return func(ctx Context) error {
+			mutex.Lock()
+			defer mutex.Unlock()
+			order = append(order, name)
+			return nil
+		}
+	}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	builder := NewMachine()
+	builder.State("green").Initial().
+		To("green").On("block").Do(func(ctx Context) error {
+		close(started)
+		<-release
+		return nil
+	}).
+		To("green").On("tick").Do(record("tick")).
+		To("green").On("idle").Do(record("idle")).
+		To("red").On("emergency_vehicle").Do(record("emergency_vehicle"))
+	builder.State("red").
+		To("red").On("tick").Do(record("tick")).
+		To("red").On("idle").Do(record("idle"))
+
+	machine := builder.Build().CreateInstance(WithEventLoop(10))
+	_ = machine.Start()
+	defer func() { _ = machine.Stop() }()
+
+	machine.SendEventAsync("block", nil)
+	<-started
+
+	var results []<-chan *EventResult
+	results = append(results, machine.SendEventWithPriority("idle", nil, PriorityLow))
+	results = append(results, machine.SendEventAsync("tick", nil))
+	results = append(results, machine.SendEventAsync("tick", nil))
+	results = append(results, machine.SendEventWithPriority("emergency_vehicle", nil, PriorityCritical))
+	close(release)
+
+	for _, result := range results {
+		select {
+		case <-result:
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for queued events")
+		}
+	}
+
+	expected := []string{"emergency_vehicle", "tick", "tick", "idle"}
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(order) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, order)
+	}
+	for i := range expected {
+		if order[i] != expected[i] {
+			t.Fatalf("Expected %v, got %v", expected, order)
+		}
+	}
+}
+
+func TestSendEventWithPriority_WithoutEventLoop(t *testing.T) {
+	machine := CreateSimpleMachine()
+	_ = machine.Start()
+
+	result := <-machine.SendEventWithPriority("start", nil, PriorityHigh)
+	if !result.Success() {
+		t.Fatalf("Expected the event to be processed directly, got %+v", result)
+	}
+	AssertState(t, machine, "running")
+}
+
+func TestEventLoop_ActionOverflowsFullQueue(t *testing.T) {
+	var results []<-chan *EventResult
+	definition := NewMachine().
+		State("idle").Initial().
+		ToSelf().On("burst").Do(func(ctx Context) error {
+		for i := range 3 {
+			results = append(results, ctx.GetMachine().SendEventAsync("tick", i))
+		}
+		return nil
+	}).
+		ToSelf().On("tick").
+		Build()
+
+	machine := definition.CreateInstance(WithEventLoop(1))
+	_ = machine.Start()
+	defer machine.Stop()
+
+	select {
+	case result := <-machine.SendEventAsync("burst", nil):
+		AssertEventProcessed(t, result, true)
+	case <-time.After(time.Second):
+		t.Fatal("Expected an action sending to a full queue not to block the loop")
+	}
+	for i, result := range results {
+		select {
+		case r := <-result:
+			AssertEventProcessed(t, r, true)
+		case <-time.After(time.Second):
+			t.Fatalf("Expected overflowed event %d to be processed", i)
+		}
+	}
+}
+
+func TestEventLoop_OutsideSenderBlocksOnFullQueue(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	definition := NewMachine().
+		State("idle").Initial().
+		ToSelf().On("block").Do(func(ctx Context) error {
+		close(started)
+		<-release
+		return nil
+	}).
+		ToSelf().On("tick").
+		Build()
+
+	machine := definition.CreateInstance(WithEventLoop(1))
+	_ = machine.Start()
+	defer machine.Stop()
+	var releaseOnce sync.Once
+	unblock := func() { releaseOnce.Do(func() { close(release) }) }
+	defer unblock()
+
+	machine.SendEventAsync("block", nil)
+	<-started
+	first := machine.SendEventAsync("tick", nil)
+
+	sent := make(chan (<-chan *EventResult))
+	go func() {
+		sent <- machine.SendEventAsync("tick", nil)
+	}()
+	select {
+	case <-sent:
+		t.Fatal("Expected a sender outside the loop to block on a full queue")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if result := <-machine.SendEventAsyncWithContext(ctx, "tick", nil); result.Processed || result.Error == nil {
+		t.Errorf("Expected a cancelled send to give up on a full queue, got %+v", result)
+	}
+
+	unblock()
+	AssertEventProcessed(t, <-first, true)
+	select {
+	case result := <-sent:
+		AssertEventProcessed(t, <-result, true)
+	case <-time.After(time.Second):
+		t.Fatal("Expected the blocked sender to be queued once the queue drained")
+	}
+}
+
+func TestEventLoop_StartedByRestore(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	definition := NewMachine().
+		State("idle").Initial().
+		To("busy").On("block").Do(func(ctx Context) error {
+		close(started)
+		<-release
+		return nil
+	}).
+		State("busy").
+		Build()
+
+	source := definition.CreateInstance()
+	_ = source.Start()
+	snapshot, _ := source.Snapshot()
+
+	machine := definition.CreateInstance(WithEventLoop(10))
+	if err := machine.Restore(snapshot); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer machine.Stop()
+
+	queued := make(chan (<-chan *EventResult), 1)
+	go func() {
+		queued <- machine.SendEventAsync("block", nil)
+	}()
+	var result <-chan *EventResult
+	select {
+	case result = <-queued:
+	case <-time.After(time.Second):
+		close(release)
+		t.Fatal("Expected the event to be queued on the restored machine's event loop")
+	}
+	<-started
+	close(release)
+	AssertEventProcessed(t, <-result, true)
+	AssertState(t, machine, "busy")
+}
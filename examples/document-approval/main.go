@@ -3,22 +3,24 @@ package main
 import (
 	"fmt"
 	"log"
+	"os"
 	"time"
 
 	"github.com/anggasct/fluo"
+	"github.com/anggasct/fluo/examples/lib"
 )
 
 func main() {
 	fmt.Println("=== Document Approval Workflow Example ===")
 	fmt.Println("Demonstrating: Choice, Fork/Join, History, and Parallel States")
 
-	machine := buildDocumentApprovalMachine()
-	instance := machine.CreateInstance()
+	machine := lib.NewDocumentApproval(lib.DocumentApprovalConfig{Output: os.Stdout})
+	instance := machine.CreateInstance(lib.Options()...)
 
 	observer := &DocumentWorkflowObserver{
 		TransitionCount: 0,
 		StateHistory:    []string{},
-		Metrics:         make(map[string]interface{}),
+		Metrics:         make(map[string]any),
 	}
 	instance.AddObserver(observer)
 
@@ -34,28 +36,27 @@ func main() {
 }
 
 func setupDocumentContext(ctx fluo.Context) {
-	document := &Document{
+	document := &lib.Document{
 		ID:        "DOC-2024-001",
 		Title:     "Software Architecture Guidelines",
 		Author:    "Senior Architect",
-		Type:      TechnicalDoc,
-		Priority:  Standard,
+		Type:      lib.TechnicalDoc,
+		Priority:  lib.Standard,
 		Content:   "Comprehensive guidelines for software architecture...",
 		CreatedAt: time.Now().Add(-2 * time.Hour),
 		Version:   1,
 	}
 
-	reviewContext := &ReviewContext{
+	reviewContext := &lib.ReviewContext{
 		Document:      document,
-		LegalDecision: Pending,
-		TechDecision:  Pending,
+		LegalDecision: lib.Pending,
+		TechDecision:  lib.Pending,
 		RejectCount:   0,
 		History:       []string{},
-		Metadata:      make(map[string]interface{}),
+		Metadata:      make(map[string]any),
 	}
 
-	ctx.Set("review_context", reviewContext)
-	ctx.Set("document", document)
+	lib.SetReview(ctx, reviewContext)
 }
 
 type DocumentWorkflowObserver struct {
@@ -100,7 +101,7 @@ func (o *DocumentWorkflowObserver) updateMetrics(from, to, eventName string, ctx
 		o.Metrics[transitionKey] = 1
 	}
 
-	if reviewCtx := getReviewContext(ctx); reviewCtx != nil {
+	if reviewCtx := lib.GetReview(ctx); reviewCtx != nil {
 		if to == "approved" || to == "rejected" {
 			duration := time.Since(reviewCtx.ReviewStartTime)
 			o.Metrics["workflow_duration"] = duration
@@ -303,61 +304,59 @@ func runRevisionScenario(instance fluo.Machine, observer *DocumentWorkflowObserv
 }
 
 func setupUrgentDocumentContext(ctx fluo.Context) {
-	document := &Document{
+	document := &lib.Document{
 		ID:        "DOC-2024-URGENT-001",
 		Title:     "Critical Security Policy Update",
 		Author:    "Security Officer",
-		Type:      PolicyDoc,
-		Priority:  Urgent,
+		Type:      lib.PolicyDoc,
+		Priority:  lib.Urgent,
 		Content:   "Critical security policy updates required immediately...",
 		CreatedAt: time.Now().Add(-1 * time.Hour),
 		Version:   1,
 	}
 
-	reviewContext := &ReviewContext{
+	reviewContext := &lib.ReviewContext{
 		Document:      document,
-		LegalDecision: Pending,
-		TechDecision:  Pending,
+		LegalDecision: lib.Pending,
+		TechDecision:  lib.Pending,
 		RejectCount:   0,
 		History:       []string{},
-		Metadata:      make(map[string]interface{}),
+		Metadata:      make(map[string]any),
 	}
 
-	ctx.Set("review_context", reviewContext)
-	ctx.Set("document", document)
+	lib.SetReview(ctx, reviewContext)
 }
 
 func setupDocumentForRevision(ctx fluo.Context) {
-	document := &Document{
+	document := &lib.Document{
 		ID:        "DOC-2024-REVISION-001",
 		Title:     "Draft Technical Specification",
 		Author:    "Junior Developer",
-		Type:      TechnicalDoc,
-		Priority:  Standard,
+		Type:      lib.TechnicalDoc,
+		Priority:  lib.Standard,
 		Content:   "Incomplete technical specification that needs revision...",
 		CreatedAt: time.Now().Add(-2 * time.Hour),
 		Version:   1,
 	}
 
-	reviewContext := &ReviewContext{
+	reviewContext := &lib.ReviewContext{
 		Document:      document,
-		LegalDecision: Rejected, // Set to rejected to trigger revision flow
+		LegalDecision: lib.Rejected, // Set to rejected to trigger revision flow
 		LegalReviewer: "Legal Team",
 		LegalComments: "Legal terms need clarification",
-		TechDecision:  Approved, // One approved, one rejected = revision needed
+		TechDecision:  lib.Approved, // One approved, one rejected = revision needed
 		TechReviewer:  "Tech Lead",
 		TechComments:  "Technical approach is sound",
 		RejectCount:   0,
 		History:       []string{},
-		Metadata:      make(map[string]interface{}),
+		Metadata:      make(map[string]any),
 	}
 
-	ctx.Set("review_context", reviewContext)
-	ctx.Set("document", document)
+	lib.SetReview(ctx, reviewContext)
 }
 
 func printFinalDocumentState(ctx fluo.Context) {
-	if reviewCtx := getReviewContext(ctx); reviewCtx != nil {
+	if reviewCtx := lib.GetReview(ctx); reviewCtx != nil {
 		fmt.Printf("\n=== Final Document State ===\n")
 		fmt.Printf("Title: %s\n", reviewCtx.Document.Title)
 		fmt.Printf("Priority: %s\n", reviewCtx.Document.Priority)
@@ -0,0 +1,536 @@
+package lib
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/anggasct/fluo"
+)
+
+// DocumentType classifies documents under review
+type DocumentType string
+
+// Priority selects the review path of a document
+type Priority string
+
+// ReviewDecision is the outcome of a legal or technical review
+type ReviewDecision string
+
+const (
+	PolicyDoc    DocumentType = "policy"
+	TechnicalDoc DocumentType = "technical"
+	ContractDoc  DocumentType = "contract"
+	GeneralDoc   DocumentType = "general"
+
+	Low      Priority = "low"
+	Standard Priority = "standard"
+	High     Priority = "high"
+	Urgent   Priority = "urgent"
+
+	Approved ReviewDecision = "approved"
+	Rejected ReviewDecision = "rejected"
+	Pending  ReviewDecision = "pending"
+)
+
+// Document is a document submitted for approval
+type Document struct {
+	ID          string
+	Title       string
+	Author      string
+	Type        DocumentType
+	Priority    Priority
+	Content     string
+	CreatedAt   time.Time
+	SubmittedAt time.Time
+	Version     int
+}
+
+// ReviewContext is the context value driving the document approval workflow
+type ReviewContext struct {
+	Document        *Document
+	LegalDecision   ReviewDecision
+	LegalReviewer   string
+	LegalComments   string
+	TechDecision    ReviewDecision
+	TechReviewer    string
+	TechComments    string
+	RejectCount     int
+	ReviewStartTime time.Time
+	History         []string
+	Metadata        map[string]any
+}
+
+// ReviewKey is the context key holding the *ReviewContext
+const ReviewKey = "review_context"
+
+// NewReview creates a pending review of a document
+func NewReview(doc *Document) *ReviewContext {
+	return &ReviewContext{
+		Document:      doc,
+		LegalDecision: Pending,
+		TechDecision:  Pending,
+		History:       []string{},
+		Metadata:      make(map[string]any),
+	}
+}
+
+// SetReview stores a review in the machine context
+func SetReview(ctx fluo.Context, review *ReviewContext) {
+	ctx.Set(ReviewKey, review)
+}
+
+// GetReview returns the review stored in the machine context
+func GetReview(ctx fluo.Context) *ReviewContext {
+	review, _ := fluo.GetAs[*ReviewContext](ctx, ReviewKey)
+	return review
+}
+
+// DocumentApprovalConfig configures the document approval definition
+type DocumentApprovalConfig struct {
+	// Output receives progress messages; nil discards them
+	Output io.Writer
+	// ExpeditedSLA sends stalled expedited reviews to the initial review; zero disables the timer
+	ExpeditedSLA time.Duration
+}
+
+// approvalActions implements the document approval behavior
+type approvalActions struct {
+	out io.Writer
+}
+
+// newApprovalActions creates the document approval behavior for a configuration
+func newApprovalActions(cfg DocumentApprovalConfig) *approvalActions {
+	out := cfg.Output
+	if out == nil {
+		out = io.Discard
+	}
+	return &approvalActions{out: out}
+}
+
+// NewDocumentApproval builds the document approval workflow: choices route
+// documents by priority, a fork runs legal and technical review side by side
+// and a join consolidates their decisions.
+func NewDocumentApproval(cfg DocumentApprovalConfig) fluo.MachineDefinition {
+	a := newApprovalActions(cfg)
+	builder := fluo.NewMachine()
+
+	builder.State("draft").Initial().
+		OnEntry(a.log("Document in draft mode")).
+		To("submission_routing").On("submit").Do(a.validateAndSubmitDocument)
+
+	builder.Choice("submission_routing").
+		OnEntry(a.log("Routing document based on priority and type")).
+		When(isUrgentDocument).To("expedited_review").
+		When(isStandardDocument).To("initial_review").
+		Otherwise("initial_review")
+
+	expedited := builder.State("expedited_review").
+		OnEntry(a.log("Expedited review for urgent documents"))
+	expedited.To("final_decision").On("expedited_complete").Do(a.processExpeditedReview)
+	if cfg.ExpeditedSLA > 0 {
+		expedited.After(cfg.ExpeditedSLA).To("initial_review").Do(a.log("Expedited review SLA missed, falling back to initial review"))
+	}
+
+	builder.State("initial_review").
+		OnEntry(a.log("Initial document review")).
+		To("priority_routing").On("review_complete").Do(a.initializeReviewProcess)
+
+	builder.Choice("priority_routing").
+		OnEntry(a.log("Routing based on document priority")).
+		When(isHighPriorityDoc).To("high_priority_path").
+		When(isStandardPriorityDoc).To("standard_review_path").
+		Otherwise("low_priority_path")
+
+	builder.State("high_priority_path").
+		OnEntry(a.log("High priority review path - SLA: 24 hours")).
+		To("parallel_fork").On("priority_review_complete").Do(a.markPriorityReviewComplete)
+
+	builder.State("standard_review_path").
+		OnEntry(a.log("Standard review path - SLA: 5 business days")).
+		To("parallel_fork").On("standard_review_complete").Do(a.markStandardReviewComplete)
+
+	builder.State("low_priority_path").
+		OnEntry(a.log("Low priority review path - SLA: 10 business days")).
+		To("parallel_fork").On("low_priority_complete").Do(a.markLowPriorityComplete)
+
+	builder.Fork("parallel_fork").
+		OnEntry(a.log("Fork: Activating ALL target states simultaneously")).
+		ToBranch("legal", "legal_approval_branch", a.log("Fork: Starting legal review")).
+		ToBranch("technical", "technical_approval_branch", a.log("Fork: Starting technical review")).
+		Do(a.initiateParallelApproval)
+
+	builder.State("legal_approval_branch").
+		OnEntry(a.log("Legal approval branch active (parallel)")).
+		To("legal_decision").On("legal_review_done").Do(a.processLegalReview)
+
+	builder.Choice("legal_decision").
+		OnEntry(a.log("Making legal decision")).
+		When(isLegalApproved).To("legal_approved").
+		Otherwise("legal_rejected")
+
+	builder.State("legal_approved").
+		OnEntry(a.log("Legal approval granted")).
+		To("sync_join").On("legal_complete").Do(a.recordLegalApproval)
+
+	builder.State("legal_rejected").
+		OnEntry(a.log("Legal approval denied")).
+		To("sync_join").On("legal_complete").Do(a.recordLegalRejection)
+
+	builder.State("technical_approval_branch").
+		OnEntry(a.log("Technical approval branch active (parallel)")).
+		To("technical_decision").On("technical_review_done").Do(a.processTechnicalReview)
+
+	builder.Choice("technical_decision").
+		OnEntry(a.log("Making technical decision")).
+		When(isTechnicalApproved).To("technical_approved").
+		Otherwise("technical_rejected")
+
+	builder.State("technical_approved").
+		OnEntry(a.log("Technical approval granted")).
+		To("sync_join").On("technical_complete").Do(a.recordTechnicalApproval).
+		To("revision_required").On("revise").Do(a.handleDocumentRevision)
+
+	builder.State("technical_rejected").
+		OnEntry(a.log("Technical approval denied")).
+		To("sync_join").On("technical_complete").Do(a.recordTechnicalRejection)
+
+	builder.Join("sync_join").
+		OnEntry(a.log("Join: Synchronizing parallel branches")).
+		FromFork("parallel_fork").
+		To("consolidation_junction").
+		Do(a.synchronizeApprovals)
+
+	builder.Junction("consolidation_junction").
+		OnEntry(a.log("Consolidating parallel results")).
+		To("final_decision").
+		Do(a.consolidateReviewResults)
+
+	builder.Fork("fork_demo").
+		OnEntry(a.log("Fork demonstration: Splitting to multiple branches")).
+		To("branch_a", "branch_b", "branch_c").
+		Do(a.initiateForkDemo)
+
+	builder.State("branch_a").
+		OnEntry(a.log("Fork branch A active")).
+		To("join_demo").On("branch_a_done").Do(a.completeBranchA)
+
+	builder.State("branch_b").
+		OnEntry(a.log("Fork branch B active")).
+		To("join_demo").On("branch_b_done").Do(a.completeBranchB)
+
+	builder.State("branch_c").
+		OnEntry(a.log("Fork branch C active")).
+		To("join_demo").On("branch_c_done").Do(a.completeBranchC)
+
+	builder.Join("join_demo").
+		OnEntry(a.log("Join demonstration: Synchronizing all branches")).
+		From("branch_a", "branch_b", "branch_c").
+		To("fork_demo_complete").
+		Do(a.synchronizeForkBranches)
+
+	builder.State("fork_demo_complete").Final().
+		OnEntry(a.log("Fork/Join demonstration completed"))
+
+	builder.History("review_history").
+		OnEntry(a.log("Restoring previous review state")).
+		Default("initial_review").
+		Do(a.restoreWorkflowState)
+
+	builder.DeepHistory("deep_review_history").
+		OnEntry(a.log("Deep restore of workflow state")).
+		Default("initial_review").
+		Do(a.deepRestoreWorkflowState)
+
+	builder.Choice("final_decision").
+		OnEntry(a.log("Making final approval decision")).
+		When(allApprovalsGranted).To("approved").
+		When(canResubmitDocument).To("revision_required").
+		Otherwise("rejected")
+
+	builder.State("revision_required").
+		OnEntry(a.log("Document requires revision")).
+		To("draft").On("revise").Do(a.handleDocumentRevision).
+		To("submission_routing").On("submit").Do(a.validateAndSubmitDocument)
+
+	builder.State("approved").
+		OnEntry(a.log("Document approved - workflow complete")).
+		To("fork_demo").On("fork_demo_trigger").Do(a.log("Starting fork demo"))
+
+	builder.State("rejected").Final().
+		OnEntry(a.log("Document rejected - workflow terminated"))
+
+	return builder.Build()
+}
+
+// RegisterDocumentApproval adds the document approval guards and actions to a
+// registry under the "approval." prefix
+func RegisterDocumentApproval(r *fluo.Registry, cfg DocumentApprovalConfig) {
+	a := newApprovalActions(cfg)
+
+	r.RegisterGuard("approval.is_urgent", isUrgentDocument)
+	r.RegisterGuard("approval.is_standard", isStandardDocument)
+	r.RegisterGuard("approval.is_high_priority", isHighPriorityDoc)
+	r.RegisterGuard("approval.is_standard_priority", isStandardPriorityDoc)
+	r.RegisterGuard("approval.legal_approved", isLegalApproved)
+	r.RegisterGuard("approval.technical_approved", isTechnicalApproved)
+	r.RegisterGuard("approval.all_approved", allApprovalsGranted)
+	r.RegisterGuard("approval.can_resubmit", canResubmitDocument)
+	r.RegisterAction("approval.submit", a.validateAndSubmitDocument)
+	r.RegisterAction("approval.expedite", a.processExpeditedReview)
+	r.RegisterAction("approval.start_review", a.initializeReviewProcess)
+	r.RegisterAction("approval.legal_review", a.processLegalReview)
+	r.RegisterAction("approval.technical_review", a.processTechnicalReview)
+	r.RegisterAction("approval.revise", a.handleDocumentRevision)
+	r.RegisterAction("approval.synchronize", a.synchronizeApprovals)
+}
+
+func isUrgentDocument(ctx fluo.Context) bool {
+	if reviewCtx := GetReview(ctx); reviewCtx != nil {
+		return reviewCtx.Document.Priority == Urgent
+	}
+	return false
+}
+
+func isStandardDocument(ctx fluo.Context) bool {
+	if reviewCtx := GetReview(ctx); reviewCtx != nil {
+		priority := reviewCtx.Document.Priority
+		return priority == Standard || priority == High
+	}
+	return false
+}
+
+func isHighPriorityDoc(ctx fluo.Context) bool {
+	if reviewCtx := GetReview(ctx); reviewCtx != nil {
+		return reviewCtx.Document.Priority == High
+	}
+	return false
+}
+
+func isStandardPriorityDoc(ctx fluo.Context) bool {
+	if reviewCtx := GetReview(ctx); reviewCtx != nil {
+		return reviewCtx.Document.Priority == Standard
+	}
+	return false
+}
+
+func isLegalApproved(ctx fluo.Context) bool {
+	if reviewCtx := GetReview(ctx); reviewCtx != nil {
+		return reviewCtx.LegalDecision == Approved
+	}
+	return false
+}
+
+func isTechnicalApproved(ctx fluo.Context) bool {
+	if reviewCtx := GetReview(ctx); reviewCtx != nil {
+		return reviewCtx.TechDecision == Approved
+	}
+	return false
+}
+
+func allApprovalsGranted(ctx fluo.Context) bool {
+	if reviewCtx := GetReview(ctx); reviewCtx != nil {
+		return reviewCtx.LegalDecision == Approved && reviewCtx.TechDecision == Approved
+	}
+	return false
+}
+
+func canResubmitDocument(ctx fluo.Context) bool {
+	if reviewCtx := GetReview(ctx); reviewCtx != nil {
+		return reviewCtx.RejectCount < 3
+	}
+	return false
+}
+
+func (a *approvalActions) validateAndSubmitDocument(ctx fluo.Context) error {
+	reviewCtx := GetReview(ctx)
+	if reviewCtx == nil {
+		return fmt.Errorf("invalid review context")
+	}
+
+	doc := reviewCtx.Document
+	doc.SubmittedAt = time.Now()
+
+	fmt.Fprintf(a.out, "Document '%s' submitted by %s\n", doc.Title, doc.Author)
+	fmt.Fprintf(a.out, "Priority: %s, Type: %s\n", doc.Priority, doc.Type)
+
+	reviewCtx.History = append(reviewCtx.History, fmt.Sprintf("Submitted at %v", doc.SubmittedAt))
+	return nil
+}
+
+func (a *approvalActions) processExpeditedReview(ctx fluo.Context) error {
+	fmt.Fprintln(a.out, "Processing expedited review - fast track approval")
+	reviewCtx := GetReview(ctx)
+	if reviewCtx != nil {
+		reviewCtx.LegalDecision = Approved
+		reviewCtx.TechDecision = Approved
+		reviewCtx.History = append(reviewCtx.History, "Expedited review completed")
+	}
+	return nil
+}
+
+func (a *approvalActions) initializeReviewProcess(ctx fluo.Context) error {
+	reviewCtx := GetReview(ctx)
+	if reviewCtx == nil {
+		return fmt.Errorf("invalid review context")
+	}
+
+	reviewCtx.ReviewStartTime = time.Now()
+	fmt.Fprintf(a.out, "Initializing review process for document: %s\n", reviewCtx.Document.Title)
+	reviewCtx.History = append(reviewCtx.History, "Review process initialized")
+	return nil
+}
+
+func (a *approvalActions) markPriorityReviewComplete(ctx fluo.Context) error {
+	fmt.Fprintln(a.out, "High priority review completed")
+	return recordReviewStep(ctx, "High priority review completed")
+}
+
+func (a *approvalActions) markStandardReviewComplete(ctx fluo.Context) error {
+	fmt.Fprintln(a.out, "Standard review completed")
+	return recordReviewStep(ctx, "Standard review completed")
+}
+
+func (a *approvalActions) markLowPriorityComplete(ctx fluo.Context) error {
+	fmt.Fprintln(a.out, "Low priority review completed")
+	return recordReviewStep(ctx, "Low priority review completed")
+}
+
+func (a *approvalActions) initiateParallelApproval(ctx fluo.Context) error {
+	fmt.Fprintln(a.out, "Initiating parallel legal and technical approval")
+	return recordReviewStep(ctx, "Parallel approval initiated")
+}
+
+func (a *approvalActions) processLegalReview(ctx fluo.Context) error {
+	reviewCtx := GetReview(ctx)
+	if reviewCtx != nil {
+		// Only set decision if it's still pending (respect initial context)
+		if reviewCtx.LegalDecision == Pending {
+			if reviewCtx.Document.Type == ContractDoc {
+				reviewCtx.LegalDecision = Approved
+				reviewCtx.LegalComments = "Contract terms acceptable"
+			} else {
+				reviewCtx.LegalDecision = Approved
+				reviewCtx.LegalComments = "Legal review completed successfully"
+			}
+		}
+		// If already set (e.g., to Rejected), keep it as is
+		reviewCtx.LegalReviewer = "Legal Team Lead"
+		fmt.Fprintf(a.out, "Legal review decision: %s\n", reviewCtx.LegalDecision)
+	}
+	return recordReviewStep(ctx, "Legal review processed")
+}
+
+func (a *approvalActions) processTechnicalReview(ctx fluo.Context) error {
+	reviewCtx := GetReview(ctx)
+	if reviewCtx != nil {
+		// Only set decision if it's still pending (respect initial context)
+		if reviewCtx.TechDecision == Pending {
+			if reviewCtx.Document.Type == TechnicalDoc {
+				reviewCtx.TechDecision = Approved
+				reviewCtx.TechComments = "Technical implementation is sound"
+			} else {
+				reviewCtx.TechDecision = Approved
+				reviewCtx.TechComments = "Technical review completed"
+			}
+		}
+		// If already set (e.g., to Rejected), keep it as is
+		reviewCtx.TechReviewer = "Senior Technical Architect"
+		fmt.Fprintf(a.out, "Technical review decision: %s\n", reviewCtx.TechDecision)
+	}
+	return recordReviewStep(ctx, "Technical review processed")
+}
+
+func (a *approvalActions) recordLegalApproval(ctx fluo.Context) error {
+	fmt.Fprintln(a.out, "Recording legal approval")
+	return recordReviewStep(ctx, "Legal approval recorded")
+}
+
+func (a *approvalActions) recordLegalRejection(ctx fluo.Context) error {
+	fmt.Fprintln(a.out, "Recording legal rejection")
+	return recordReviewStep(ctx, "Legal rejection recorded")
+}
+
+func (a *approvalActions) recordTechnicalApproval(ctx fluo.Context) error {
+	fmt.Fprintln(a.out, "Recording technical approval")
+	return recordReviewStep(ctx, "Technical approval recorded")
+}
+
+func (a *approvalActions) recordTechnicalRejection(ctx fluo.Context) error {
+	fmt.Fprintln(a.out, "Recording technical rejection")
+	return recordReviewStep(ctx, "Technical rejection recorded")
+}
+
+func (a *approvalActions) synchronizeApprovals(ctx fluo.Context) error {
+	reviewCtx := GetReview(ctx)
+	if reviewCtx != nil {
+		fmt.Fprintf(a.out, "Synchronizing approvals - Legal: %s, Technical: %s\n",
+			reviewCtx.LegalDecision, reviewCtx.TechDecision)
+	}
+	return recordReviewStep(ctx, "Approvals synchronized")
+}
+
+func (a *approvalActions) consolidateReviewResults(ctx fluo.Context) error {
+	fmt.Fprintln(a.out, "Consolidating all review results")
+	return recordReviewStep(ctx, "Review results consolidated")
+}
+
+func (a *approvalActions) restoreWorkflowState(ctx fluo.Context) error {
+	fmt.Fprintln(a.out, "Restoring previous workflow state from history")
+	return recordReviewStep(ctx, "Workflow state restored")
+}
+
+func (a *approvalActions) deepRestoreWorkflowState(ctx fluo.Context) error {
+	fmt.Fprintln(a.out, "Deep restoring complex workflow state")
+	return recordReviewStep(ctx, "Deep workflow state restored")
+}
+
+func (a *approvalActions) handleDocumentRevision(ctx fluo.Context) error {
+	reviewCtx := GetReview(ctx)
+	if reviewCtx != nil {
+		reviewCtx.RejectCount++
+		reviewCtx.Document.Version++
+		fmt.Fprintf(a.out, "Document revision required - Version %d, Attempt %d\n",
+			reviewCtx.Document.Version, reviewCtx.RejectCount)
+	}
+	return recordReviewStep(ctx, "Document revision initiated")
+}
+
+func (a *approvalActions) initiateForkDemo(ctx fluo.Context) error {
+	fmt.Fprintln(a.out, "Initiating Fork demonstration with parallel execution")
+	return recordReviewStep(ctx, "Fork demo initiated")
+}
+
+func (a *approvalActions) completeBranchA(ctx fluo.Context) error {
+	fmt.Fprintln(a.out, "Completing Fork branch A")
+	return recordReviewStep(ctx, "Branch A completed")
+}
+
+func (a *approvalActions) completeBranchB(ctx fluo.Context) error {
+	fmt.Fprintln(a.out, "Completing Fork branch B")
+	return recordReviewStep(ctx, "Branch B completed")
+}
+
+func (a *approvalActions) completeBranchC(ctx fluo.Context) error {
+	fmt.Fprintln(a.out, "Completing Fork branch C")
+	return recordReviewStep(ctx, "Branch C completed")
+}
+
+func (a *approvalActions) synchronizeForkBranches(ctx fluo.Context) error {
+	fmt.Fprintln(a.out, "Synchronizing all fork branches with Join logic")
+	return recordReviewStep(ctx, "Fork branches synchronized")
+}
+
+func (a *approvalActions) log(message string) fluo.ActionFunc {
+	return func(ctx fluo.Context) error {
+		fmt.Fprintln(a.out, message)
+		return nil
+	}
+}
+
+func recordReviewStep(ctx fluo.Context, step string) error {
+	if review := GetReview(ctx); review != nil {
+		review.History = append(review.History, fmt.Sprintf("%s at %v", step, time.Now().Format("15:04:05")))
+	}
+	return nil
+}
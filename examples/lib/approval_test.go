@@ -0,0 +1,127 @@
+package lib
+
+import (
+	"testing"
+	"time"
+
+	"github.com/anggasct/fluo"
+)
+
+func startReview(t *testing.T, cfg DocumentApprovalConfig, review *ReviewContext) fluo.Machine {
+	t.Helper()
+	machine := NewDocumentApproval(cfg).CreateInstance(Options()...)
+	SetReview(machine.Context(), review)
+	if err := machine.Start(); err != nil {
+		t.Fatalf("Expected machine to start, got %v", err)
+	}
+	return machine
+}
+
+func TestDocumentApproval_Standard(t *testing.T) {
+	review := NewReview(&Document{ID: "DOC-1", Type: TechnicalDoc, Priority: Standard})
+	machine := startReview(t, DocumentApprovalConfig{}, review)
+
+	sendAll(t, machine, "submit", "review_complete", "standard_review_complete")
+	if !machine.IsStateActive("legal_approval_branch") || !machine.IsStateActive("technical_approval_branch") {
+		t.Fatalf("Expected both review branches active, got %v", machine.GetActiveStates())
+	}
+
+	sendAll(t, machine, "legal_review_done", "technical_review_done", "legal_complete", "technical_complete")
+	if machine.CurrentState() != "approved" {
+		t.Errorf("Expected approved, got %s", machine.CurrentState())
+	}
+	if review.LegalDecision != Approved || review.TechDecision != Approved {
+		t.Errorf("Expected both decisions approved, got %s and %s", review.LegalDecision, review.TechDecision)
+	}
+}
+
+func TestDocumentApproval_Revision(t *testing.T) {
+	review := NewReview(&Document{ID: "DOC-2", Type: TechnicalDoc, Priority: Standard, Version: 1})
+	review.LegalDecision = Rejected
+	machine := startReview(t, DocumentApprovalConfig{}, review)
+
+	sendAll(t, machine, "submit", "review_complete", "standard_review_complete",
+		"legal_review_done", "technical_review_done", "legal_complete", "technical_complete")
+	if machine.CurrentState() != "revision_required" {
+		t.Fatalf("Expected revision_required, got %s", machine.CurrentState())
+	}
+
+	sendAll(t, machine, "revise")
+	if machine.CurrentState() != "draft" || review.Document.Version != 2 {
+		t.Errorf("Expected revised draft at version 2, got %s at version %d", machine.CurrentState(), review.Document.Version)
+	}
+}
+
+func TestDocumentApproval_ExpeditedSLA(t *testing.T) {
+	review := NewReview(&Document{ID: "DOC-3", Type: PolicyDoc, Priority: Urgent})
+	machine := startReview(t, DocumentApprovalConfig{ExpeditedSLA: 20 * time.Millisecond}, review)
+
+	sendAll(t, machine, "submit")
+	if machine.CurrentState() != "expedited_review" {
+		t.Fatalf("Expected expedited_review, got %s", machine.CurrentState())
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for machine.CurrentState() != "initial_review" && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if machine.CurrentState() != "initial_review" {
+		t.Errorf("Expected missed SLA to fall back to initial_review, got %s", machine.CurrentState())
+	}
+}
+
+func TestDocumentApproval_SnapshotRestore(t *testing.T) {
+	review := NewReview(&Document{ID: "DOC-4", Type: ContractDoc, Priority: Urgent})
+	machine := startReview(t, DocumentApprovalConfig{}, review)
+	sendAll(t, machine, "submit")
+
+	data, err := machine.MarshalSnapshot(fluo.JSONCodec{})
+	if err != nil {
+		t.Fatalf("Expected snapshot, got %v", err)
+	}
+
+	restored := NewDocumentApproval(DocumentApprovalConfig{}).CreateInstance(Options()...)
+	if err := restored.UnmarshalSnapshot(fluo.JSONCodec{}, data); err != nil {
+		t.Fatalf("Expected restore, got %v", err)
+	}
+	if got := GetReview(restored.Context()); got == nil || got.Document.ID != "DOC-4" {
+		t.Fatalf("Expected review to survive the snapshot, got %+v", got)
+	}
+
+	sendAll(t, restored, "expedited_complete")
+	if restored.CurrentState() != "approved" {
+		t.Errorf("Expected approved, got %s", restored.CurrentState())
+	}
+}
+
+func TestRegisterDocumentApproval(t *testing.T) {
+	registry := fluo.NewRegistry()
+	RegisterDocumentApproval(registry, DocumentApprovalConfig{})
+
+	definition, err := registry.LoadDefinitionJSON([]byte(`{
+		"initial": "draft",
+		"states": [
+			{"id": "draft", "transitions": [{"event": "submit", "target": "routing", "action": "approval.submit"}]},
+			{"id": "routing", "type": "choice",
+				"branches": [{"guard": "approval.is_urgent", "target": "expedited"}],
+				"otherwise": "review"},
+			{"id": "expedited"},
+			{"id": "review"}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("Expected document to load with the registered behavior, got %v", err)
+	}
+
+	review := NewReview(&Document{ID: "DOC-5", Priority: Urgent})
+	machine := definition.CreateInstance(Options()...)
+	SetReview(machine.Context(), review)
+	_ = machine.Start()
+	sendAll(t, machine, "submit")
+	if machine.CurrentState() != "expedited" {
+		t.Errorf("Expected expedited, got %s", machine.CurrentState())
+	}
+	if review.Document.SubmittedAt.IsZero() {
+		t.Error("Expected the registered submit action to run")
+	}
+}
@@ -0,0 +1,12 @@
+// Package lib provides the example charts as importable reference definitions.
+//
+// Each chart comes with its guards and actions registered under stable names
+// for document-based definitions, optional timers, and context types
+// registered for snapshot persistence. Embed a definition as-is or copy it as
+// a known-good starting point:
+//
+//	def := lib.NewOrderPipeline(lib.OrderPipelineConfig{AbandonAfter: time.Hour})
+//	machine := def.CreateInstance(lib.Options()...)
+//	lib.SetOrder(machine.Context(), &lib.Order{ID: "ORD-1", ItemType: lib.Digital})
+//	_ = machine.Start()
+package lib
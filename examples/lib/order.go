@@ -0,0 +1,183 @@
+package lib
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/anggasct/fluo"
+)
+
+// ItemType distinguishes digital from physical orders
+type ItemType string
+
+const (
+	Digital  ItemType = "digital"
+	Physical ItemType = "physical"
+)
+
+// Order is the context value driving the order pipeline
+type Order struct {
+	ID       string
+	ItemType ItemType
+	Amount   int
+	InStock  bool
+}
+
+// OrderKey is the context key holding the *Order
+const OrderKey = "order"
+
+// SetOrder stores an order in the machine context
+func SetOrder(ctx fluo.Context, order *Order) {
+	ctx.Set(OrderKey, order)
+}
+
+// GetOrder returns the order stored in the machine context
+func GetOrder(ctx fluo.Context) *Order {
+	order, _ := fluo.GetAs[*Order](ctx, OrderKey)
+	return order
+}
+
+// OrderPipelineConfig configures the order pipeline definition
+type OrderPipelineConfig struct {
+	// Output receives progress messages; nil discards them
+	Output io.Writer
+	// AbandonAfter cancels orders that are not placed in time; zero disables the timer
+	AbandonAfter time.Duration
+}
+
+// orderActions implements the order pipeline behavior
+type orderActions struct {
+	out io.Writer
+}
+
+// newOrderActions creates the order pipeline behavior for a configuration
+func newOrderActions(cfg OrderPipelineConfig) *orderActions {
+	out := cfg.Output
+	if out == nil {
+		out = io.Discard
+	}
+	return &orderActions{out: out}
+}
+
+// NewOrderPipeline builds the order pipeline: payment and risk checks run in
+// parallel, then digital orders are delivered directly while physical orders
+// are packed and labelled in parallel before shipping.
+func NewOrderPipeline(cfg OrderPipelineConfig) fluo.MachineDefinition {
+	a := newOrderActions(cfg)
+	b := fluo.NewMachine()
+
+	created := b.State("order_created").Initial().
+		OnEntry(a.log("Order created"))
+	created.To("prechecks").On("place")
+	if cfg.AbandonAfter > 0 {
+		created.After(cfg.AbandonAfter).To("canceled").Do(a.log("Order abandoned"))
+	}
+
+	pre := b.ParallelState("prechecks")
+	pay := pre.Region("payment")
+	pay.State("pending").Initial().
+		OnEntry(a.log("Waiting for payment confirmation")).
+		To("ok").On("pay_ok").Do(a.markPaid)
+	pay.State("ok").Final().
+		OnEntry(a.log("Payment confirmed"))
+	risk := pre.Region("risk")
+	risk.State("checking").Initial().
+		OnEntry(a.log("Performing risk/fraud checks")).
+		To("cleared").On("risk_ok").Do(a.markRiskCleared)
+	risk.State("cleared").Final().
+		OnEntry(a.log("Risk cleared"))
+	pre.End()
+
+	b.ParallelState("prechecks").To("digital_fulfillment").OnCompletion().When(isDigital)
+	b.ParallelState("prechecks").To("packaging").OnCompletion().When(isPhysical)
+
+	b.State("digital_fulfillment").
+		OnEntry(a.log("Fulfilling digital item (send download/email)")).
+		To("completed").On("deliver")
+
+	pkg := b.ParallelState("packaging")
+	pkr := pkg.Region("pack")
+	pkr.State("work").Initial().
+		OnEntry(a.log("Packing items")).
+		To("done").On("pack_done").Do(a.markPacked)
+	pkr.State("done").Final().
+		OnEntry(a.log("Package ready"))
+	lbr := pkg.Region("label")
+	lbr.State("work").Initial().
+		OnEntry(a.log("Creating shipping label")).
+		To("done").On("label_done").Do(a.markLabelReady)
+	lbr.State("done").Final().
+		OnEntry(a.log("Label ready"))
+	pkg.End()
+
+	b.ParallelState("packaging").To("shipping").OnCompletion()
+
+	b.State("shipping").
+		OnEntry(a.log("Shipping in progress")).
+		To("completed").On("ship").
+		To("canceled").On("cancel")
+
+	b.State("completed").Final().OnEntry(a.log("Order completed"))
+	b.State("canceled").Final().OnEntry(a.log("Order canceled"))
+
+	return b.Build()
+}
+
+// RegisterOrderPipeline adds the order pipeline guards and actions to a
+// registry under the "order." prefix
+func RegisterOrderPipeline(r *fluo.Registry, cfg OrderPipelineConfig) {
+	a := newOrderActions(cfg)
+
+	r.RegisterGuard("order.is_digital", isDigital)
+	r.RegisterGuard("order.is_physical", isPhysical)
+	r.RegisterAction("order.mark_paid", a.markPaid)
+	r.RegisterAction("order.mark_risk_cleared", a.markRiskCleared)
+	r.RegisterAction("order.mark_packed", a.markPacked)
+	r.RegisterAction("order.mark_label_ready", a.markLabelReady)
+}
+
+func isDigital(ctx fluo.Context) bool {
+	if o := GetOrder(ctx); o != nil {
+		return o.ItemType == Digital
+	}
+	return false
+}
+
+func isPhysical(ctx fluo.Context) bool {
+	if o := GetOrder(ctx); o != nil {
+		return o.ItemType == Physical
+	}
+	return false
+}
+
+func (a *orderActions) log(message string) fluo.ActionFunc {
+	return func(ctx fluo.Context) error {
+		fmt.Fprintf(a.out, "[LOG] %s\n", message)
+		return nil
+	}
+}
+
+func (a *orderActions) markPaid(ctx fluo.Context) error {
+	ctx.Set("paid", true)
+	fmt.Fprintln(a.out, "Payment confirmed")
+	return nil
+}
+
+func (a *orderActions) markRiskCleared(ctx fluo.Context) error {
+	ctx.Set("risk_cleared", true)
+	fmt.Fprintln(a.out, "Risk check cleared")
+	return nil
+}
+
+func (a *orderActions) markPacked(ctx fluo.Context) error {
+	ctx.Set("packed", true)
+	fmt.Fprintln(a.out, "Package completed")
+	return nil
+}
+
+func (a *orderActions) markLabelReady(ctx fluo.Context) error {
+	ctx.Set("label_ready", true)
+	fmt.Fprintln(a.out, "Shipping label ready")
+	return nil
+}
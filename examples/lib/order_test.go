@@ -0,0 +1,110 @@
+package lib
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anggasct/fluo"
+)
+
+func startOrder(t *testing.T, cfg OrderPipelineConfig, order *Order) fluo.Machine {
+	t.Helper()
+	machine := NewOrderPipeline(cfg).CreateInstance(Options()...)
+	SetOrder(machine.Context(), order)
+	if err := machine.Start(); err != nil {
+		t.Fatalf("Expected machine to start, got %v", err)
+	}
+	return machine
+}
+
+func sendAll(t *testing.T, machine fluo.Machine, events ...string) {
+	t.Helper()
+	for _, event := range events {
+		if result := machine.SendEvent(event, nil); !result.Success() {
+			t.Fatalf("Expected '%s' to be processed in %s, got %s", event, machine.CurrentState(), result.RejectionReason)
+		}
+	}
+}
+
+func TestOrderPipeline_Digital(t *testing.T) {
+	var out bytes.Buffer
+	machine := startOrder(t, OrderPipelineConfig{Output: &out}, &Order{ID: "ORD-1", ItemType: Digital})
+
+	sendAll(t, machine, "place", "pay_ok", "risk_ok")
+	if machine.CurrentState() != "digital_fulfillment" {
+		t.Fatalf("Expected digital_fulfillment, got %s", machine.CurrentState())
+	}
+	sendAll(t, machine, "deliver")
+	if machine.CurrentState() != "completed" {
+		t.Errorf("Expected completed, got %s", machine.CurrentState())
+	}
+	if !strings.Contains(out.String(), "Order completed") {
+		t.Errorf("Expected progress output, got %q", out.String())
+	}
+}
+
+func TestOrderPipeline_Physical(t *testing.T) {
+	machine := startOrder(t, OrderPipelineConfig{}, &Order{ID: "ORD-2", ItemType: Physical, InStock: true})
+
+	sendAll(t, machine, "place", "pay_ok", "risk_ok", "pack_done", "label_done")
+	if machine.CurrentState() != "shipping" {
+		t.Fatalf("Expected shipping, got %s", machine.CurrentState())
+	}
+	sendAll(t, machine, "ship")
+	if machine.CurrentState() != "completed" {
+		t.Errorf("Expected completed, got %s", machine.CurrentState())
+	}
+}
+
+func TestOrderPipeline_AbandonTimer(t *testing.T) {
+	machine := startOrder(t, OrderPipelineConfig{AbandonAfter: 20 * time.Millisecond}, &Order{ID: "ORD-3", ItemType: Digital})
+
+	deadline := time.Now().Add(time.Second)
+	for machine.CurrentState() != "canceled" && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if machine.CurrentState() != "canceled" {
+		t.Errorf("Expected abandoned order to be canceled, got %s", machine.CurrentState())
+	}
+}
+
+func TestOrderPipeline_SnapshotRestore(t *testing.T) {
+	machine := startOrder(t, OrderPipelineConfig{}, &Order{ID: "ORD-4", ItemType: Physical})
+	sendAll(t, machine, "place", "pay_ok")
+
+	data, err := machine.MarshalSnapshot(fluo.JSONCodec{})
+	if err != nil {
+		t.Fatalf("Expected snapshot, got %v", err)
+	}
+
+	restored := NewOrderPipeline(OrderPipelineConfig{}).CreateInstance(Options()...)
+	if err := restored.UnmarshalSnapshot(fluo.JSONCodec{}, data); err != nil {
+		t.Fatalf("Expected restore, got %v", err)
+	}
+	if order := GetOrder(restored.Context()); order == nil || order.ID != "ORD-4" {
+		t.Fatalf("Expected order to survive the snapshot, got %+v", order)
+	}
+
+	sendAll(t, restored, "risk_ok", "pack_done", "label_done", "ship")
+	if restored.CurrentState() != "completed" {
+		t.Errorf("Expected completed, got %s", restored.CurrentState())
+	}
+}
+
+func TestRegisterOrderPipeline(t *testing.T) {
+	registry := fluo.NewRegistry()
+	RegisterOrderPipeline(registry, OrderPipelineConfig{})
+
+	for _, name := range []string{"order.is_digital", "order.is_physical"} {
+		if _, ok := registry.Guard(name); !ok {
+			t.Errorf("Expected guard '%s' to be registered", name)
+		}
+	}
+	for _, name := range []string{"order.mark_paid", "order.mark_risk_cleared", "order.mark_packed", "order.mark_label_ready"} {
+		if _, ok := registry.Action(name); !ok {
+			t.Errorf("Expected action '%s' to be registered", name)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package lib
+
+import (
+	"sync"
+
+	"github.com/anggasct/fluo"
+)
+
+var (
+	types     *fluo.TypeRegistry
+	typesOnce sync.Once
+)
+
+// Types returns a type registry holding the context types of the reference
+// charts, so orders and reviews keep their concrete types across snapshots
+func Types() *fluo.TypeRegistry {
+	typesOnce.Do(func() {
+		types = fluo.NewTypeRegistry()
+		if err := RegisterTypes(types); err != nil {
+			panic(err)
+		}
+	})
+	return types
+}
+
+// RegisterTypes adds the context types of the reference charts to a type registry
+func RegisterTypes(r *fluo.TypeRegistry) error {
+	if err := r.Register("lib.Order", (*Order)(nil)); err != nil {
+		return err
+	}
+	return r.Register("lib.ReviewContext", (*ReviewContext)(nil))
+}
+
+// Options returns the machine options that wire persistence for the reference charts
+func Options() []fluo.MachineOption {
+	return []fluo.MachineOption{fluo.WithTypeRegistry(Types())}
+}
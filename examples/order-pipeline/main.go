@@ -2,9 +2,11 @@ package main
 
 import (
 	"fmt"
+	"os"
 	"time"
 
 	"github.com/anggasct/fluo"
+	"github.com/anggasct/fluo/examples/lib"
 )
 
 type OrderObserver struct{ fluo.BaseObserver }
@@ -22,19 +24,19 @@ func (o *OrderObserver) OnStateEnter(state string, ctx fluo.Context) {
 }
 
 func main() {
-	def := BuildOrderMachine()
-	m := def.CreateInstance()
+	def := lib.NewOrderPipeline(lib.OrderPipelineConfig{Output: os.Stdout})
+	m := def.CreateInstance(lib.Options()...)
 	m.AddObserver(&OrderObserver{})
 
 	fmt.Println("=== Scenario 1: Digital Order ===")
-	m.Context().Set("order", &Order{ID: "ORD-1001", ItemType: Digital, Amount: 3999, InStock: true})
+	lib.SetOrder(m.Context(), &lib.Order{ID: "ORD-1001", ItemType: lib.Digital, Amount: 3999, InStock: true})
 	_ = m.Start()
 	runDigitalFlow(m)
 
 	fmt.Println("\n=== Scenario 2: Physical Order ===")
 	_ = m.Reset()
 	_ = m.Start()
-	m.Context().Set("order", &Order{ID: "ORD-1002", ItemType: Physical, Amount: 12999, InStock: true})
+	lib.SetOrder(m.Context(), &lib.Order{ID: "ORD-1002", ItemType: lib.Physical, Amount: 12999, InStock: true})
 	runPhysicalFlow(m)
 }
 
@@ -14,21 +14,13 @@ func log(message string) fluo.ActionFunc {
 }
 
 func getSmartHome(ctx fluo.Context) *SmartHome {
-	if v, ok := ctx.Get("smart_home"); ok {
-		if sh, ok := v.(*SmartHome); ok {
-			return sh
-		}
-	}
-	return nil
+	sh, _ := fluo.GetAs[*SmartHome](ctx, "smart_home")
+	return sh
 }
 
 func getSensorData(ctx fluo.Context) *SensorData {
-	if v, ok := ctx.Get("sensor_data"); ok {
-		if sd, ok := v.(*SensorData); ok {
-			return sd
-		}
-	}
-	return nil
+	sd, _ := fluo.GetAs[*SensorData](ctx, "sensor_data")
+	return sd
 }
 
 func initializeSystem(ctx fluo.Context) error {
@@ -17,6 +17,9 @@ func BuildSmartHomeMachine() fluo.MachineDefinition {
 
 	op := b.ParallelState("operational")
 
+	// Returning from maintenance resumes every subsystem where it left off
+	op.DeepHistory("history")
+
 	sec := op.Region("security")
 	sec.State("disarmed").Initial().
 		OnEntry(log("Security system disarmed"))
@@ -92,7 +95,7 @@ func BuildSmartHomeMachine() fluo.MachineDefinition {
 
 	b.State("maintenance").
 		OnEntry(log("System in maintenance mode")).
-		To("operational").On("maintenance_complete").
+		To("operational.history").On("maintenance_complete").
 		To("off").On("power_off")
 
 	b.State("emergency").
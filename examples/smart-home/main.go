@@ -21,6 +21,13 @@ func (o *SmartHomeObserver) OnStateEnter(state string, ctx fluo.Context) {
 	fmt.Printf("Entered: %s\n", state)
 }
 
+// SecurityLog follows the security region only
+type SecurityLog struct{}
+
+func (l *SecurityLog) OnRegionStateEnter(parallelState, region, state string, ctx fluo.Context) {
+	fmt.Printf("[security] %s\n", state)
+}
+
 func main() {
 	def := BuildSmartHomeMachine()
 
@@ -47,6 +54,7 @@ func main() {
 	fmt.Println("\n=== Scenario 3: Security Breach Emergency ===")
 	m3 := def.CreateInstance()
 	m3.AddObserver(&SmartHomeObserver{})
+	_ = m3.AddRegionObserver("operational", "security", &SecurityLog{})
 	_ = m3.Start()
 	smartHome = &SmartHome{Temperature: 70, IsOccupied: false}
 	sensorData = &SensorData{Temperature: 70, MotionDetected: false, DoorOpen: false, PowerLevel: 95}
@@ -15,12 +15,8 @@ func log(message string) fluo.ActionFunc {
 }
 
 func getIntersection(ctx fluo.Context) *Intersection {
-	if v, ok := ctx.Get("intersection"); ok {
-		if i, ok := v.(*Intersection); ok {
-			return i
-		}
-	}
-	return nil
+	i, _ := fluo.GetAs[*Intersection](ctx, "intersection")
+	return i
 }
 
 func initializeIntersection(ctx fluo.Context) error {
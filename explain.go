@@ -0,0 +1,126 @@
+package fluo
+
+import (
+	"fmt"
+	"strings"
+)
+
+// GuardOutcome is the result of a guard evaluated while explaining an event
+type GuardOutcome int
+
+const (
+	// GuardNotEvaluated means the transition has no guard or was skipped before its guard ran
+	GuardNotEvaluated GuardOutcome = iota
+	// GuardPassed means the guard returned true
+	GuardPassed
+	// GuardFailed means the guard returned false
+	GuardFailed
+	// GuardErrored means the guard panicked
+	GuardErrored
+)
+
+// String returns the name of the outcome
+func (o GuardOutcome) String() string {
+	switch o {
+	case GuardPassed:
+		return "passed"
+	case GuardFailed:
+		return "failed"
+	case GuardErrored:
+		return "errored"
+	default:
+		return "not evaluated"
+	}
+}
+
+// Explanation is a dry run of transition resolution for an event
+type Explanation struct {
+	Event      string
+	State      string
+	Considered []ExplainedTransition // Transitions the selector considered, in routing order
+	Tier       int                   // Routing priority tier that matched, 0 if the event would be rejected
+	Origin     string                // Origin of the matching tier, see RoutingStep
+	Match      *ExplainedTransition  // Transition that would fire, nil if the event would be rejected
+	Rejection  string                // Reason the event would be rejected
+}
+
+// ExplainedTransition is a transition considered while explaining an event
+type ExplainedTransition struct {
+	Tier     int
+	Origin   string
+	Source   string
+	Target   string
+	Guard    string // Registered guard name, if any
+	Outcome  GuardOutcome
+	Priority int
+	Viable   bool
+	Reason   string // Why the transition would not fire
+}
+
+// Explain dry-runs transition resolution for an event through the selector
+// HandleEvent uses and reports every transition it considered, the outcome of
+// each guard and the priority tier that matched. Guards are evaluated without
+// being reported and nothing is dispatched.
+func (sm *StateMachine) Explain(eventName string) Explanation {
+	routing := sm.ExplainRouting(eventName)
+	explanation := Explanation{
+		Event:     routing.Event,
+		State:     routing.CurrentState,
+		Rejection: routing.Rejection,
+	}
+	match := -1
+	for _, step := range routing.Steps {
+		for i := range step.Candidates {
+			candidate := &step.Candidates[i]
+			if candidate == routing.Match {
+				match = len(explanation.Considered)
+				explanation.Tier = step.Priority
+				explanation.Origin = step.Origin
+			}
+			explanation.Considered = append(explanation.Considered, ExplainedTransition{
+				Tier:     step.Priority,
+				Origin:   step.Origin,
+				Source:   candidate.Source,
+				Target:   candidate.Target,
+				Guard:    candidate.Guard,
+				Outcome:  candidate.Outcome,
+				Priority: candidate.Priority,
+				Viable:   candidate.Viable,
+				Reason:   candidate.Reason,
+			})
+		}
+	}
+	if match >= 0 {
+		explanation.Match = &explanation.Considered[match]
+	}
+	return explanation
+}
+
+// String renders the explanation as one line per considered transition
+func (e Explanation) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "event '%s' in state '%s'\n", e.Event, e.State)
+	if len(e.Considered) == 0 {
+		sb.WriteString("  no transitions declared for the event\n")
+	}
+	for _, transition := range e.Considered {
+		fmt.Fprintf(&sb, "  tier %d %s %s -> %s", transition.Tier, transition.Origin, transition.Source, transition.Target)
+		if transition.Guard != "" || transition.Outcome != GuardNotEvaluated {
+			guard := transition.Guard
+			if guard == "" {
+				guard = "guard"
+			}
+			fmt.Fprintf(&sb, " [%s %s]", guard, transition.Outcome)
+		}
+		if !transition.Viable {
+			fmt.Fprintf(&sb, " (%s)", transition.Reason)
+		}
+		sb.WriteString("\n")
+	}
+	if e.Match != nil {
+		fmt.Fprintf(&sb, "  matched tier %d %s: %s -> %s\n", e.Tier, e.Origin, e.Match.Source, e.Match.Target)
+	} else {
+		fmt.Fprintf(&sb, "  rejected: %s\n", e.Rejection)
+	}
+	return sb.String()
+}
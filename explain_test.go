@@ -0,0 +1,137 @@
+package fluo
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func buildExplainDefinition() MachineDefinition {
+	registry := NewRegistry()
+	registry.RegisterGuard("isVIP", func(ctx Context) bool {
+		vip, _ := ctx.Get("vip")
+		return vip == true
+	})
+	registry.RegisterGuard("inStock", func(ctx Context) bool {
+		stock, _ := ctx.Get("stock")
+		return stock != 0
+	})
+
+	definition, err := registry.LoadDefinitionJSON([]byte(`{
+		"initial": "cart",
+		"states": [
+			{"id": "cart", "transitions": [
+				{"event": "checkout", "target": "priority", "guard": "isVIP", "priority": 10},
+				{"event": "checkout", "target": "standard", "guard": "inStock"}
+			]},
+			{"id": "priority"},
+			{"id": "standard"}
+		]
+	}`))
+	if err != nil {
+		panic(err)
+	}
+	return definition
+}
+
+func TestExplain_GuardOutcomes(t *testing.T) {
+	machine := buildExplainDefinition().CreateInstance()
+	_ = machine.Start()
+	machine.Context().Set("stock", 3)
+
+	explanation := machine.Explain("checkout")
+	if len(explanation.Considered) != 2 {
+		t.Fatalf("Expected 2 considered transitions, got %+v", explanation.Considered)
+	}
+	vip, stock := explanation.Considered[0], explanation.Considered[1]
+	if vip.Guard != "isVIP" || vip.Outcome != GuardFailed || vip.Viable {
+		t.Errorf("Expected the isVIP guard to fail, got %+v", vip)
+	}
+	if stock.Guard != "inStock" || stock.Outcome != GuardPassed || !stock.Viable {
+		t.Errorf("Expected the inStock guard to pass, got %+v", stock)
+	}
+	if explanation.Match == nil || explanation.Match.Target != "standard" {
+		t.Fatalf("Expected a match to standard, got %+v", explanation.Match)
+	}
+	if explanation.Tier != 5 || explanation.Origin != "hierarchical" {
+		t.Errorf("Expected the hierarchical tier to match, got %d %s", explanation.Tier, explanation.Origin)
+	}
+	AssertState(t, machine, "cart")
+}
+
+func TestExplain_Rejected(t *testing.T) {
+	machine := buildExplainDefinition().CreateInstance()
+	_ = machine.Start()
+	machine.Context().Set("stock", 0)
+
+	explanation := machine.Explain("checkout")
+	if explanation.Match != nil || explanation.Tier != 0 {
+		t.Fatalf("Expected no match, got %+v", explanation)
+	}
+	result := machine.HandleEvent("checkout", nil)
+	if explanation.Rejection != result.RejectionReason {
+		t.Errorf("Expected rejection %q, got %q", result.RejectionReason, explanation.Rejection)
+	}
+	output := explanation.String()
+	if !strings.Contains(output, "[isVIP failed]") || !strings.Contains(output, "[inStock failed]") {
+		t.Errorf("Expected guard names and outcomes in the rendering, got %s", output)
+	}
+}
+
+func TestDebug_TraceLogsGuardOutcomes(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	machine := buildExplainDefinition().CreateInstance(WithDebugLogger(logger), WithDebug(DebugTrace))
+	_ = machine.Start()
+	machine.Context().Set("stock", 1)
+
+	machine.HandleEvent("checkout", nil)
+	output := buf.String()
+	if !strings.Contains(output, "fluo: evaluated guard") || !strings.Contains(output, "guard=isVIP outcome=failed") {
+		t.Errorf("Expected the failed guard to be traced, got: %s", output)
+	}
+	if !strings.Contains(output, "guard=inStock outcome=passed") {
+		t.Errorf("Expected the passing guard to be traced, got: %s", output)
+	}
+}
+
+func TestExplain_FollowsConflictPolicy(t *testing.T) {
+	builder := NewMachine()
+	builder.State("idle").Initial().
+		To("a").On("go").Priority(1).
+		To("b").On("go").Priority(5).
+		To("c").On("split").
+		To("d").On("split").
+		To("e").On("probe").When(func(ctx Context) bool { panic("guard boom") })
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		builder.State(id)
+	}
+	definition := builder.Build()
+
+	highest := definition.CreateInstance(WithConflictPolicy(HighestPriority))
+	_ = highest.Start()
+	if explanation := highest.Explain("go"); explanation.Match == nil || explanation.Match.Target != "b" {
+		t.Errorf("Expected the highest priority transition to match, got %+v", explanation.Match)
+	}
+
+	strict := definition.CreateInstance(WithConflictPolicy(ErrorOnAmbiguity), WithPanicPolicy(PanicPropagate))
+	observer := NewTestObserver()
+	strict.AddObserver(observer)
+	_ = strict.Start()
+	explanation := strict.Explain("split")
+	if explanation.Match != nil {
+		t.Errorf("Expected the ambiguous event to be rejected, got %+v", explanation.Match)
+	}
+	if result := strict.HandleEvent("split", nil); explanation.Rejection != result.RejectionReason {
+		t.Errorf("Expected rejection %q, got %q", result.RejectionReason, explanation.Rejection)
+	}
+
+	explanation = strict.Explain("probe")
+	if len(explanation.Considered) != 1 || explanation.Considered[0].Outcome != GuardErrored {
+		t.Errorf("Expected the panicking guard to be reported as errored, got %+v", explanation.Considered)
+	}
+	if len(observer.Errors) != 0 {
+		t.Errorf("Expected explaining to notify nothing, got %v", observer.Errors)
+	}
+}
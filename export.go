@@ -0,0 +1,453 @@
+package fluo
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// diagramNode is a state placed in the containment tree used by the diagram exporters
+type diagramNode struct {
+	id       string
+	state    State
+	children []*diagramNode
+	regions  []*diagramRegion
+}
+
+// diagramRegion is a parallel region in the containment tree
+type diagramRegion struct {
+	id       string
+	region   Region
+	children []*diagramNode
+}
+
+// diagramModel is the containment tree of a machine definition
+type diagramModel struct {
+	def         MachineDefinition
+	roots       []*diagramNode
+	nodes       map[string]*diagramNode
+	transitions []Transition
+}
+
+// newDiagramModel builds the containment tree for a definition, nesting states by
+// parent links, region membership and dotted state IDs
+func newDiagramModel(def MachineDefinition) *diagramModel {
+	states := def.GetStates()
+	model := &diagramModel{
+		def:   def,
+		nodes: make(map[string]*diagramNode),
+	}
+
+	ids := make([]string, 0, len(states))
+	for id := range states {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	for _, id := range ids {
+		model.nodes[id] = &diagramNode{id: id, state: states[id]}
+	}
+
+	regionsByPath := make(map[string]*diagramRegion)
+	for _, id := range ids {
+		if parallelState, ok := states[id].(ParallelState); ok {
+			for _, region := range parallelState.Regions() {
+				dr := &diagramRegion{id: id + "." + region.ID(), region: region}
+				regionsByPath[dr.id] = dr
+				model.nodes[id].regions = append(model.nodes[id].regions, dr)
+			}
+		}
+	}
+
+	for _, id := range ids {
+		node := model.nodes[id]
+		if region := findDiagramRegion(regionsByPath, id); region != nil {
+			region.children = append(region.children, node)
+			continue
+		}
+		if parentID := diagramParentID(states, id); parentID != "" {
+			parent := model.nodes[parentID]
+			parent.children = append(parent.children, node)
+			continue
+		}
+		model.roots = append(model.roots, node)
+	}
+
+	transitions := def.GetTransitions()
+	sources := make([]string, 0, len(transitions))
+	for source := range transitions {
+		sources = append(sources, source)
+	}
+	slices.Sort(sources)
+	for _, source := range sources {
+		model.transitions = append(model.transitions, transitions[source]...)
+	}
+
+	return model
+}
+
+// diagramContainment records where each state of a model is nested
+type diagramContainment struct {
+	container map[string]string // Containing state or region path ("parallel.region")
+	parent    map[string]string // Containing state (the parallel state for region members)
+	initials  map[string]bool   // Initial states of the machine, composites and regions
+}
+
+// containment walks the containment tree of the model
+func (model *diagramModel) containment() *diagramContainment {
+	c := &diagramContainment{
+		container: make(map[string]string),
+		parent:    make(map[string]string),
+		initials:  map[string]bool{model.def.GetInitialState(): true},
+	}
+
+	var walk func(nodes []*diagramNode, container, parent string)
+	walk = func(nodes []*diagramNode, container, parent string) {
+		for _, node := range nodes {
+			c.container[node.id] = container
+			c.parent[node.id] = parent
+			if composite, ok := node.state.(CompositeState); ok && composite.InitialState() != nil {
+				c.initials[composite.InitialState().ID()] = true
+			}
+			walk(node.children, node.id, node.id)
+			for _, region := range node.regions {
+				if initial := region.region.InitialState(); initial != nil {
+					c.initials[initial.ID()] = true
+				}
+				walk(region.children, region.id, node.id)
+			}
+		}
+	}
+	walk(model.roots, "", "")
+	return c
+}
+
+// findDiagramRegion returns the innermost region whose path is a prefix of the state ID
+func findDiagramRegion(regions map[string]*diagramRegion, stateID string) *diagramRegion {
+	var found *diagramRegion
+	for path, region := range regions {
+		if strings.HasPrefix(stateID, path+".") && !strings.Contains(stateID[len(path)+1:], ".") {
+			if found == nil || len(path) > len(found.id) {
+				found = region
+			}
+		}
+	}
+	return found
+}
+
+// diagramParentID returns the containing state of a state, or "" for top-level states
+func diagramParentID(states map[string]State, stateID string) string {
+	if parent := states[stateID].Parent(); parent != nil {
+		if _, exists := states[parent.ID()]; exists {
+			return parent.ID()
+		}
+	}
+
+	for parent := StatePath(stateID).Parent(); parent != ""; parent = parent.Parent() {
+		if _, exists := states[parent.String()]; exists {
+			return parent.String()
+		}
+	}
+	return ""
+}
+
+// diagramAlias converts a state ID into an identifier accepted by diagram languages
+func diagramAlias(stateID string) string {
+	var alias strings.Builder
+	for _, r := range stateID {
+		if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			alias.WriteRune(r)
+		} else {
+			alias.WriteRune('_')
+		}
+	}
+	return alias.String()
+}
+
+// diagramLabel returns the local name of a state (the last segment of its ID)
+func diagramLabel(stateID string) string {
+	return StatePath(stateID).Name()
+}
+
+// transitionTrigger renders what fires a transition: its event, timer or completion
+func transitionTrigger(transition Transition) string {
+	switch {
+	case transition.After > 0:
+		return fmt.Sprintf("after %s", transition.After)
+	case transition.EventName == "" || strings.HasPrefix(transition.EventName, "__completion"):
+		return "completion"
+	default:
+		return transition.EventName
+	}
+}
+
+// transitionLabel renders the event, timer, guard and kind of a transition
+func transitionLabel(transition Transition) string {
+	label := transitionTrigger(transition)
+	if transition.GuardName != "" {
+		label += fmt.Sprintf(" [%s]", transition.GuardName)
+	} else if transition.Guard != nil {
+		label += " [guard]"
+	}
+	if transition.Flag != "" {
+		label += fmt.Sprintf(" {%s}", transition.Flag)
+	}
+	if transition.Internal {
+		label += " (internal)"
+	}
+	return label
+}
+
+// pseudoEdge is an outgoing edge of a pseudostate
+type pseudoEdge struct {
+	from  string
+	to    string
+	label string
+}
+
+// pseudoStateEdges returns the edges configured on a pseudostate itself
+func pseudoStateEdges(state State) []pseudoEdge {
+	pseudo, ok := state.(*PseudoStateImpl)
+	if !ok {
+		return nil
+	}
+
+	var edges []pseudoEdge
+	switch pseudo.Kind() {
+	case Choice, Junction:
+		for _, condition := range pseudo.choiceConditions {
+			if condition.TargetFunc != nil {
+				// A computed target has no edge to draw
+				continue
+			}
+			label := ""
+			if condition.Guard != nil {
+				label = "[guard]"
+			}
+			edges = append(edges, pseudoEdge{from: pseudo.ID(), to: condition.Target, label: label})
+		}
+		if pseudo.defaultTarget != "" {
+			edges = append(edges, pseudoEdge{from: pseudo.ID(), to: pseudo.defaultTarget, label: "[else]"})
+		}
+	case Fork:
+		for _, target := range pseudo.forkTargets {
+			edges = append(edges, pseudoEdge{from: pseudo.ID(), to: target, label: pseudo.forkBranches[target].Label})
+		}
+	case Join:
+		seen := make(map[string]bool)
+		for _, combination := range pseudo.joinSourceCombinations {
+			for _, source := range combination {
+				if !seen[source] {
+					seen[source] = true
+					edges = append(edges, pseudoEdge{from: source, to: pseudo.ID()})
+				}
+			}
+		}
+		if pseudo.joinTarget != "" {
+			edges = append(edges, pseudoEdge{from: pseudo.ID(), to: pseudo.joinTarget})
+		}
+	case EntryPoint, ExitPoint:
+		if pseudo.defaultTarget != "" {
+			edges = append(edges, pseudoEdge{from: pseudo.ID(), to: pseudo.defaultTarget})
+		}
+	case History, DeepHistory:
+		if pseudo.historyDefault != "" {
+			edges = append(edges, pseudoEdge{from: pseudo.ID(), to: pseudo.historyDefault, label: "default"})
+		}
+	}
+	return edges
+}
+
+// ExportPlantUML renders a machine definition as a PlantUML state diagram
+func ExportPlantUML(def MachineDefinition) string {
+	model := newDiagramModel(def)
+
+	var out strings.Builder
+	out.WriteString("@startuml\n")
+	if initial := def.GetInitialState(); initial != "" {
+		out.WriteString(fmt.Sprintf("[*] --> %s\n", diagramAlias(initial)))
+	}
+	for _, node := range model.roots {
+		writePlantUMLNode(&out, node, "")
+	}
+	for _, transition := range model.transitions {
+		out.WriteString(fmt.Sprintf("%s --> %s : %s\n", diagramAlias(transition.SourceState), diagramAlias(transition.TargetState), transitionLabel(transition)))
+	}
+	for _, node := range sortedDiagramNodes(model.nodes) {
+		for _, edge := range pseudoStateEdges(node.state) {
+			writePlantUMLEdge(&out, edge)
+		}
+	}
+	out.WriteString("@enduml\n")
+	return out.String()
+}
+
+// writePlantUMLNode renders a state and its children
+func writePlantUMLNode(out *strings.Builder, node *diagramNode, indent string) {
+	alias := diagramAlias(node.id)
+	label := diagramLabel(node.id)
+
+	if pseudo, ok := node.state.(PseudoState); ok {
+		out.WriteString(fmt.Sprintf("%sstate \"%s\" as %s %s\n", indent, label, alias, plantUMLStereotype(pseudo.Kind())))
+		return
+	}
+
+	if node.state.IsFinal() {
+		out.WriteString(fmt.Sprintf("%sstate \"%s\" as %s <<end>>\n", indent, label, alias))
+		return
+	}
+
+	if len(node.children) == 0 && len(node.regions) == 0 {
+		out.WriteString(fmt.Sprintf("%sstate \"%s\" as %s\n", indent, label, alias))
+		return
+	}
+
+	out.WriteString(fmt.Sprintf("%sstate \"%s\" as %s {\n", indent, label, alias))
+	if composite, ok := node.state.(CompositeState); ok && composite.InitialState() != nil {
+		out.WriteString(fmt.Sprintf("%s  [*] --> %s\n", indent, diagramAlias(composite.InitialState().ID())))
+	}
+	for _, child := range node.children {
+		writePlantUMLNode(out, child, indent+"  ")
+	}
+	for i, region := range node.regions {
+		if i > 0 || len(node.children) > 0 {
+			out.WriteString(indent + "  --\n")
+		}
+		out.WriteString(fmt.Sprintf("%s  state \"%s\" as %s {\n", indent, region.region.ID(), diagramAlias(region.id)))
+		if initial := region.region.InitialState(); initial != nil {
+			out.WriteString(fmt.Sprintf("%s    [*] --> %s\n", indent, diagramAlias(initial.ID())))
+		}
+		for _, child := range region.children {
+			writePlantUMLNode(out, child, indent+"    ")
+		}
+		out.WriteString(indent + "  }\n")
+	}
+	out.WriteString(indent + "}\n")
+}
+
+// writePlantUMLEdge renders a pseudostate edge
+func writePlantUMLEdge(out *strings.Builder, edge pseudoEdge) {
+	if edge.label != "" {
+		out.WriteString(fmt.Sprintf("%s --> %s : %s\n", diagramAlias(edge.from), diagramAlias(edge.to), edge.label))
+		return
+	}
+	out.WriteString(fmt.Sprintf("%s --> %s\n", diagramAlias(edge.from), diagramAlias(edge.to)))
+}
+
+// plantUMLStereotype returns the PlantUML stereotype for a pseudostate kind
+func plantUMLStereotype(kind PseudoStateKind) string {
+	switch kind {
+	case Fork:
+		return "<<fork>>"
+	case Join:
+		return "<<join>>"
+	case History:
+		return "<<history>>"
+	case DeepHistory:
+		return "<<history*>>"
+	case EntryPoint:
+		return "<<entryPoint>>"
+	case ExitPoint:
+		return "<<exitPoint>>"
+	case Terminate:
+		return "<<end>>"
+	default:
+		return "<<choice>>"
+	}
+}
+
+// ExportMermaid renders a machine definition as a Mermaid stateDiagram-v2
+func ExportMermaid(def MachineDefinition) string {
+	model := newDiagramModel(def)
+
+	var out strings.Builder
+	out.WriteString("stateDiagram-v2\n")
+	if initial := def.GetInitialState(); initial != "" {
+		out.WriteString(fmt.Sprintf("    [*] --> %s\n", diagramAlias(initial)))
+	}
+	for _, node := range model.roots {
+		writeMermaidNode(&out, node, "    ")
+	}
+	for _, transition := range model.transitions {
+		out.WriteString(fmt.Sprintf("    %s --> %s : %s\n", diagramAlias(transition.SourceState), diagramAlias(transition.TargetState), transitionLabel(transition)))
+	}
+	for _, node := range sortedDiagramNodes(model.nodes) {
+		for _, edge := range pseudoStateEdges(node.state) {
+			writeMermaidEdge(&out, edge)
+		}
+	}
+	return out.String()
+}
+
+// writeMermaidNode renders a state and its children
+func writeMermaidNode(out *strings.Builder, node *diagramNode, indent string) {
+	alias := diagramAlias(node.id)
+	label := diagramLabel(node.id)
+
+	out.WriteString(fmt.Sprintf("%sstate \"%s\" as %s\n", indent, label, alias))
+
+	if pseudo, ok := node.state.(PseudoState); ok {
+		switch pseudo.Kind() {
+		case Choice, Junction:
+			out.WriteString(fmt.Sprintf("%sstate %s <<choice>>\n", indent, alias))
+		case Fork:
+			out.WriteString(fmt.Sprintf("%sstate %s <<fork>>\n", indent, alias))
+		case Join:
+			out.WriteString(fmt.Sprintf("%sstate %s <<join>>\n", indent, alias))
+		}
+		return
+	}
+
+	if node.state.IsFinal() {
+		out.WriteString(fmt.Sprintf("%s%s --> [*]\n", indent, alias))
+		return
+	}
+
+	if len(node.children) == 0 && len(node.regions) == 0 {
+		return
+	}
+
+	out.WriteString(fmt.Sprintf("%sstate %s {\n", indent, alias))
+	if composite, ok := node.state.(CompositeState); ok && composite.InitialState() != nil {
+		out.WriteString(fmt.Sprintf("%s    [*] --> %s\n", indent, diagramAlias(composite.InitialState().ID())))
+	}
+	for _, child := range node.children {
+		writeMermaidNode(out, child, indent+"    ")
+	}
+	for i, region := range node.regions {
+		if i > 0 || len(node.children) > 0 {
+			out.WriteString(indent + "    --\n")
+		}
+		out.WriteString(fmt.Sprintf("%s    state \"%s\" as %s\n", indent, region.region.ID(), diagramAlias(region.id)))
+		out.WriteString(fmt.Sprintf("%s    state %s {\n", indent, diagramAlias(region.id)))
+		if initial := region.region.InitialState(); initial != nil {
+			out.WriteString(fmt.Sprintf("%s        [*] --> %s\n", indent, diagramAlias(initial.ID())))
+		}
+		for _, child := range region.children {
+			writeMermaidNode(out, child, indent+"        ")
+		}
+		out.WriteString(indent + "    }\n")
+	}
+	out.WriteString(indent + "}\n")
+}
+
+// writeMermaidEdge renders a pseudostate edge
+func writeMermaidEdge(out *strings.Builder, edge pseudoEdge) {
+	if edge.label != "" {
+		out.WriteString(fmt.Sprintf("    %s --> %s : %s\n", diagramAlias(edge.from), diagramAlias(edge.to), edge.label))
+		return
+	}
+	out.WriteString(fmt.Sprintf("    %s --> %s\n", diagramAlias(edge.from), diagramAlias(edge.to)))
+}
+
+// sortedDiagramNodes returns the nodes of a model ordered by state ID
+func sortedDiagramNodes(nodes map[string]*diagramNode) []*diagramNode {
+	sorted := make([]*diagramNode, 0, len(nodes))
+	for _, node := range nodes {
+		sorted = append(sorted, node)
+	}
+	slices.SortFunc(sorted, func(a, b *diagramNode) int {
+		return strings.Compare(a.id, b.id)
+	})
+	return sorted
+}
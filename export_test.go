@@ -0,0 +1,110 @@
+package fluo
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func createExportDefinition() MachineDefinition {
+	builder := NewMachine()
+	builder.State("idle").Initial().
+		To("active").On("activate").
+		To("choice").On("decide").When(func(ctx Context) bool { return true })
+
+	parallelBuilder := builder.ParallelState("active")
+	motorRegion := parallelBuilder.Region("motor")
+	motorRegion.State("stopped").Initial().
+		To("running").On("start_motor")
+	motorRegion.State("running")
+
+	lightsRegion := parallelBuilder.Region("lights")
+	lightsRegion.State("off").Initial().
+		To("on").On("turn_on_lights")
+	lightsRegion.State("on")
+
+	builder.Choice("choice").
+		When(func(ctx Context) bool { return true }).To("done").
+		Otherwise("idle")
+
+	builder.State("done").Final()
+
+	return builder.Build()
+}
+
+func TestExportPlantUML(t *testing.T) {
+	out := ExportPlantUML(createExportDefinition())
+
+	expected := []string{
+		"@startuml",
+		"[*] --> idle",
+		"state \"active\" as active {",
+		"state \"motor\" as active_motor {",
+		"[*] --> active_motor_stopped",
+		"--",
+		"state \"choice\" as choice <<choice>>",
+		"state \"done\" as done <<end>>",
+		"idle --> active : activate",
+		"idle --> choice : decide [guard]",
+		"active_motor_stopped --> active_motor_running : start_motor",
+		"choice --> done : [guard]",
+		"choice --> idle : [else]",
+		"@enduml",
+	}
+	for _, line := range expected {
+		if !strings.Contains(out, line) {
+			t.Errorf("Expected PlantUML output to contain %q, got:\n%s", line, out)
+		}
+	}
+
+	if out != ExportPlantUML(createExportDefinition()) {
+		t.Error("Expected PlantUML output to be deterministic")
+	}
+}
+
+func TestExportMermaid(t *testing.T) {
+	out := ExportMermaid(createExportDefinition())
+
+	expected := []string{
+		"stateDiagram-v2",
+		"[*] --> idle",
+		"state active {",
+		"state active_motor {",
+		"--",
+		"state choice <<choice>>",
+		"done --> [*]",
+		"idle --> active : activate",
+		"active_lights_off --> active_lights_on : turn_on_lights",
+		"choice --> idle : [else]",
+	}
+	for _, line := range expected {
+		if !strings.Contains(out, line) {
+			t.Errorf("Expected Mermaid output to contain %q, got:\n%s", line, out)
+		}
+	}
+
+	if out != ExportMermaid(createExportDefinition()) {
+		t.Error("Expected Mermaid output to be deterministic")
+	}
+}
+
+func TestExport_CompositeAndTimedTransitions(t *testing.T) {
+	builder := NewMachine()
+	builder.State("waiting").Initial().
+		After(5 * time.Second).To("transaction")
+	builder.CompositeState("transaction")
+	builder.State("transaction.select").Initial().
+		To("transaction.confirm").On("next")
+	builder.State("transaction.confirm")
+
+	out := ExportPlantUML(builder.Build())
+	for _, line := range []string{
+		"waiting --> transaction : after 5s",
+		"state \"transaction\" as transaction {",
+		"state \"select\" as transaction_select",
+	} {
+		if !strings.Contains(out, line) {
+			t.Errorf("Expected PlantUML output to contain %q, got:\n%s", line, out)
+		}
+	}
+}
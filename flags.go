@@ -0,0 +1,64 @@
+package fluo
+
+import "sync"
+
+// FlagProvider resolves named feature flags that gate transitions at runtime
+type FlagProvider interface {
+	IsEnabled(ctx Context, flag string) bool
+}
+
+// FlagProviderFunc adapts a function into a FlagProvider
+type FlagProviderFunc func(ctx Context, flag string) bool
+
+// IsEnabled calls the underlying function
+func (f FlagProviderFunc) IsEnabled(ctx Context, flag string) bool {
+	return f(ctx, flag)
+}
+
+// StaticFlags is an in-memory FlagProvider whose flags can be toggled while machines run
+type StaticFlags struct {
+	flags map[string]bool
+	mutex sync.RWMutex
+}
+
+// NewStaticFlags creates a flag set with the given flags enabled
+func NewStaticFlags(enabled ...string) *StaticFlags {
+	flags := &StaticFlags{flags: make(map[string]bool)}
+	for _, flag := range enabled {
+		flags.flags[flag] = true
+	}
+	return flags
+}
+
+// Set enables or disables a flag
+func (f *StaticFlags) Set(flag string, enabled bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.flags[flag] = enabled
+}
+
+// IsEnabled reports whether a flag is enabled
+func (f *StaticFlags) IsEnabled(_ Context, flag string) bool {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	return f.flags[flag]
+}
+
+// WithFlagProvider sets the provider used to resolve transition flags. Without
+// a provider every flagged transition is disabled.
+func WithFlagProvider(provider FlagProvider) MachineOption {
+	return func(sm *StateMachine) {
+		sm.flags = provider
+	}
+}
+
+// flagEnabled reports whether a transition's flag allows it to fire
+func (sm *StateMachine) flagEnabled(transition Transition) bool {
+	if transition.Flag == "" {
+		return true
+	}
+	if sm.flags == nil {
+		return false
+	}
+	return sm.flags.IsEnabled(sm.context, transition.Flag)
+}
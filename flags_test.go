@@ -0,0 +1,70 @@
+package fluo
+
+import "testing"
+
+func buildFlaggedMachine() MachineDefinition {
+	return NewMachine().
+		State("review").Initial().
+		To("new_flow").On("assess").IfFlag("use_new_risk_engine").
+		To("legacy_flow").On("assess").
+		State("new_flow").
+		State("legacy_flow").
+		Build()
+}
+
+func TestFlags_DisabledWithoutProvider(t *testing.T) {
+	machine := buildFlaggedMachine().CreateInstance()
+	_ = machine.Start()
+
+	AssertEventProcessed(t, machine.HandleEvent("assess", nil), true)
+	AssertState(t, machine, "legacy_flow")
+}
+
+func TestFlags_ToggledAtRuntime(t *testing.T) {
+	flags := NewStaticFlags()
+	definition := buildFlaggedMachine()
+
+	machine := definition.CreateInstance(WithFlagProvider(flags))
+	_ = machine.Start()
+	machine.HandleEvent("assess", nil)
+	AssertState(t, machine, "legacy_flow")
+
+	flags.Set("use_new_risk_engine", true)
+
+	machine = definition.CreateInstance(WithFlagProvider(flags))
+	_ = machine.Start()
+	machine.HandleEvent("assess", nil)
+	AssertState(t, machine, "new_flow")
+}
+
+func TestFlags_ProviderFunc(t *testing.T) {
+	var asked []string
+	provider := FlagProviderFunc(func(ctx Context, flag string) bool {
+		asked = append(asked, flag)
+		tenant, _ := GetAs[string](ctx, "tenant")
+		return tenant == "beta"
+	})
+
+	machine := buildFlaggedMachine().CreateInstance(WithFlagProvider(provider))
+	machine.Context().Set("tenant", "beta")
+	_ = machine.Start()
+	machine.HandleEvent("assess", nil)
+
+	AssertState(t, machine, "new_flow")
+	if len(asked) != 1 || asked[0] != "use_new_risk_engine" {
+		t.Errorf("Expected provider to be asked for the flag once, got %v", asked)
+	}
+}
+
+func TestFlags_OnlyFlaggedTransitionRejected(t *testing.T) {
+	machine := NewMachine().
+		State("idle").Initial().
+		To("beta").On("try").IfFlag("beta").
+		State("beta").
+		Build().
+		CreateInstance(WithFlagProvider(NewStaticFlags("other")))
+	_ = machine.Start()
+
+	AssertEventProcessed(t, machine.HandleEvent("try", nil), false)
+	AssertState(t, machine, "idle")
+}
@@ -0,0 +1,176 @@
+package fluo
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// ForceEventName is the event observers see for a forced transition without
+// an event of its own
+const ForceEventName = "__force"
+
+// ForceOptions configures a forced transition
+type ForceOptions struct {
+	// SkipActions moves the machine without running exit and entry actions.
+	// Timers, do-activities and submachines of the states left are still
+	// stopped, and those of the states entered started.
+	SkipActions bool
+
+	// Event names the trigger reported to observers, ForceEventName if empty
+	Event string
+
+	// Data is the event data the actions see
+	Data any
+}
+
+// ForceTransition moves a started machine to the target state regardless of
+// its transitions, for administrative overrides such as unsticking an
+// instance. Unlike SetState, every active state not containing the target is
+// exited, innermost first, and the target is entered along with its initial
+// substates and regions, so the active configuration stays consistent. A
+// target already active or containing the current state is exited and
+// re-entered. States of a parallel region are set with SetConfiguration
+// instead. The first failing entry action is returned after the machine moved.
+func (sm *StateMachine) ForceTransition(target string, opts ForceOptions) error {
+	sm.mutex.Lock()
+	defer sm.unlock()
+
+	if sm.machineState != MachineStateStarted {
+		return NewMachineNotStartedError("ForceTransition")
+	}
+	state, exists := sm.states[target]
+	if !exists {
+		return NewStateNotFoundError(target)
+	}
+	if _, ok := state.(PseudoState); ok {
+		return NewInvalidStateError(target, fmt.Sprintf("state '%s' is a pseudostate", target))
+	}
+	if sm.findRegionForState(target) != nil {
+		return NewInvalidStateError(target, fmt.Sprintf("state '%s' belongs to a parallel region; use SetConfiguration", target))
+	}
+
+	eventName := opts.Event
+	if eventName == "" {
+		eventName = ForceEventName
+	}
+	event := NewEvent(eventName, opts.Data)
+	previousState := sm.currentState
+	if smCtx, ok := sm.context.(*StateMachineContext); ok {
+		smCtx.updateTransitionInfo(previousState, previousState, target, event)
+	}
+
+	sm.skipActions = opts.SkipActions
+	defer func() { sm.skipActions = false }()
+
+	boundary := sm.forceBoundary(previousState, target)
+	sm.updateStateHistory(previousState)
+	sm.exitBelow(boundary)
+
+	sm.entryErr = nil
+	actualTargetState := sm.executeCompositeStateEntry(target, event)
+	sm.currentState = actualTargetState
+	if smCtx, ok := sm.context.(*StateMachineContext); ok {
+		smCtx.updateCurrentState(sm.currentState)
+	}
+	sm.enterWithin(boundary, actualTargetState, false)
+	sm.enterRegions("")
+
+	sm.observers.NotifyTransition(previousState, actualTargetState, event, sm.context)
+	sm.observers.NotifyStateEnter(actualTargetState, sm.context)
+
+	err := sm.entryErr
+	sm.entryErr = nil
+	return err
+}
+
+// forceBoundary returns the innermost proper ancestor of the target that
+// contains the current state, or "" when the whole configuration is left
+func (sm *StateMachine) forceBoundary(currentState, target string) string {
+	ancestors := make(map[string]bool)
+	for stateID := sm.parentOf(target); stateID != ""; stateID = sm.parentOf(stateID) {
+		ancestors[stateID] = true
+	}
+	for stateID := currentState; stateID != ""; stateID = sm.parentOf(stateID) {
+		if ancestors[stateID] {
+			return stateID
+		}
+	}
+	return ""
+}
+
+// exitBelow exits every active state nested below the boundary, or every
+// active state for an empty boundary, innermost first, and drops their
+// region, fork and join tracking
+func (sm *StateMachine) exitBelow(boundary string) {
+	below := func(stateID string) bool {
+		return boundary == "" || sm.isDescendantOf(stateID, boundary)
+	}
+
+	depth := make(map[string]int)
+	leaves := append([]string{sm.currentState}, slices.Sorted(maps.Keys(sm.activeStates))...)
+	for _, leaf := range leaves {
+		for stateID := leaf; stateID != "" && below(stateID); stateID = sm.enclosingState(stateID) {
+			if _, seen := depth[stateID]; seen {
+				break
+			}
+			depth[stateID] = sm.stateDepth(stateID)
+		}
+	}
+	exiting := slices.SortedFunc(maps.Keys(depth), func(a, b string) int {
+		return cmp.Or(cmp.Compare(depth[b], depth[a]), cmp.Compare(a, b))
+	})
+
+	for _, stateID := range exiting {
+		state, exists := sm.states[stateID]
+		if !exists {
+			continue
+		}
+		if parallelState, ok := state.(ParallelState); ok {
+			sm.recordRegionHistory(parallelState)
+			for _, region := range parallelState.Regions() {
+				sm.setRegionCurrent(region, nil)
+			}
+		}
+		sm.exitState(state)
+		if stateID == sm.currentState || sm.activeStates[stateID] {
+			sm.observers.NotifyStateExit(stateID, sm.context)
+		}
+		delete(sm.activeStates, stateID)
+	}
+
+	for regionKey, regionStates := range sm.parallelRegions {
+		if slices.ContainsFunc(regionStates, below) {
+			delete(sm.parallelRegions, regionKey)
+		}
+	}
+	for _, arrived := range sm.joinTracking {
+		for stateID := range arrived {
+			if below(stateID) {
+				delete(arrived, stateID)
+			}
+		}
+	}
+}
+
+// enclosingState returns the state enclosing a state: its parent, or the
+// parallel state owning its region
+func (sm *StateMachine) enclosingState(stateID string) string {
+	if parent := sm.parentOf(stateID); parent != "" {
+		return parent
+	}
+	if region := sm.findRegionForState(stateID); region != nil {
+		return region.ParentState().ID()
+	}
+	return ""
+}
+
+// stateDepth counts the states enclosing a state
+func (sm *StateMachine) stateDepth(stateID string) int {
+	depth := 0
+	for parent := sm.enclosingState(stateID); parent != ""; parent = sm.enclosingState(parent) {
+		depth++
+	}
+	return depth
+}
@@ -0,0 +1,112 @@
+package fluo
+
+import (
+	"slices"
+	"testing"
+)
+
+// buildForceMachine builds a machine with a composite and a parallel state,
+// recording entry and exit actions
+func buildForceMachine(log *[]string) MachineDefinition {
+	record := func(entry string) ActionFunc {
+		return func(ctx Context) error {
+			*log = append(*log, entry)
+			return nil
+		}
+	}
+	builder := NewMachine()
+	builder.State("idle").Initial().
+		OnEntry(record("enter idle")).OnExit(record("exit idle")).
+		To("work").On("begin")
+	builder.Composite("work", func(c CompositeScope) {
+		c.State("draft").Initial().
+			OnEntry(record("enter work.draft")).OnExit(record("exit work.draft")).
+			To("review").On("submit")
+		c.State("review").
+			OnEntry(record("enter work.review")).OnExit(record("exit work.review"))
+	})
+	builder.Parallel("active", func(p ParallelScope) {
+		p.Region("motor", func(r RegionScope) {
+			r.State("off").Initial().
+				OnEntry(record("enter motor.off")).OnExit(record("exit motor.off"))
+		})
+		p.Region("light", func(r RegionScope) {
+			r.State("dim").Initial().
+				OnEntry(record("enter light.dim")).OnExit(record("exit light.dim"))
+		})
+	})
+	return builder.Build()
+}
+
+func TestForceTransition_RunsActions(t *testing.T) {
+	var log []string
+	machine := buildForceMachine(&log).CreateInstance()
+	_ = machine.Start()
+	machine.SendEvent("begin", nil)
+	machine.SendEvent("submit", nil)
+	log = nil
+
+	if err := machine.ForceTransition("active", ForceOptions{}); err != nil {
+		t.Fatalf("ForceTransition failed: %v", err)
+	}
+	AssertState(t, machine, "active")
+	if !slices.Contains(log, "exit work.review") || !slices.Contains(log, "enter motor.off") || !slices.Contains(log, "enter light.dim") {
+		t.Errorf("Expected exit and entry actions, got %v", log)
+	}
+	log = nil
+
+	if err := machine.ForceTransition("idle", ForceOptions{}); err != nil {
+		t.Fatalf("ForceTransition failed: %v", err)
+	}
+	AssertState(t, machine, "idle")
+	if active := machine.GetActiveStates(); !slices.Equal(active, []string{"idle"}) {
+		t.Errorf("Expected the regions to be left, got %v", active)
+	}
+	if !slices.Contains(log, "exit motor.off") || !slices.Contains(log, "exit light.dim") || !slices.Contains(log, "enter idle") {
+		t.Errorf("Expected the region states to be exited, got %v", log)
+	}
+}
+
+func TestForceTransition_SkipActions(t *testing.T) {
+	var log []string
+	observer := NewTestObserver()
+	machine := buildForceMachine(&log).CreateInstance()
+	machine.AddObserver(observer)
+	_ = machine.Start()
+	log = nil
+
+	if err := machine.ForceTransition("work.draft", ForceOptions{SkipActions: true, Event: "admin"}); err != nil {
+		t.Fatalf("ForceTransition failed: %v", err)
+	}
+	AssertState(t, machine, "work.draft")
+	if len(log) != 0 {
+		t.Errorf("Expected no actions, got %v", log)
+	}
+	last := observer.Transitions[len(observer.Transitions)-1]
+	if last.To != "work.draft" || last.Event.GetName() != "admin" {
+		t.Errorf("Expected observers to see the forced transition, got %+v", last)
+	}
+
+	// Events are handled from the forced configuration
+	machine.SendEvent("submit", nil)
+	AssertState(t, machine, "work.review")
+	if !slices.Equal(log, []string{"exit work.draft", "enter work.review"}) {
+		t.Errorf("Expected only the actions of the later transition, got %v", log)
+	}
+}
+
+func TestForceTransition_Errors(t *testing.T) {
+	var log []string
+	machine := buildForceMachine(&log).CreateInstance()
+
+	if err := machine.ForceTransition("idle", ForceOptions{}); !IsMachineError(err) {
+		t.Errorf("Expected a MachineError before start, got %v", err)
+	}
+	_ = machine.Start()
+	if err := machine.ForceTransition("missing", ForceOptions{}); GetErrorCode(err) != ErrCodeStateNotFound {
+		t.Errorf("Expected state not found, got %v", err)
+	}
+	if err := machine.ForceTransition("active.motor.off", ForceOptions{}); GetErrorCode(err) != ErrCodeInvalidState {
+		t.Errorf("Expected an invalid state error for a region state, got %v", err)
+	}
+}
@@ -0,0 +1,230 @@
+package fluo
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// GenerateOptions configures the Go code emitted by GenerateGo
+type GenerateOptions struct {
+	// Package is the package clause of the generated file
+	Package string
+
+	// Name prefixes the generated identifiers: "Order" yields OrderState,
+	// OrderStateIdle, OrderEvent, NewOrderDefinition and OrderMachine
+	Name string
+
+	// Source names the document the code is generated from in the header
+	Source string
+}
+
+// GenerateGo emits Go source declaring typed constants for the states, events,
+// guards and actions of a document, a constructor loading the definition
+// through a registry and a TypedMachine alias sending and checking them, so call
+// sites no longer pass state and event names as plain strings. States are
+// named by their full IDs: "review.approved" becomes StateReviewApproved.
+func GenerateGo(doc *MachineDocument, opts GenerateOptions) ([]byte, error) {
+	if doc == nil {
+		return nil, NewConfigurationError("generator", "document is nil")
+	}
+	if !token.IsIdentifier(opts.Package) {
+		return nil, NewConfigurationError("generator", fmt.Sprintf("invalid package name '%s'", opts.Package))
+	}
+	if opts.Name != "" && !token.IsIdentifier(opts.Name) {
+		return nil, NewConfigurationError("generator", fmt.Sprintf("invalid name '%s'", opts.Name))
+	}
+
+	names := collectDocumentNames(doc)
+	stateType := opts.Name + "State"
+	eventType := opts.Name + "Event"
+	machineType := opts.Name + "Machine"
+	if opts.Name == "" {
+		machineType = "TypedMachine"
+	}
+
+	var out bytes.Buffer
+	source := "a machine document"
+	if opts.Source != "" {
+		source = opts.Source
+	}
+	fmt.Fprintf(&out, "// Code generated by fluo-gen from %s. DO NOT EDIT.\n\n", source)
+	fmt.Fprintf(&out, "package %s\n\nimport \"github.com/anggasct/fluo\"\n\n", opts.Package)
+
+	fmt.Fprintf(&out, "// %s is a state of the machine, named by its full ID\ntype %s string\n\n", stateType, stateType)
+	if err := writeGeneratedConstants(&out, stateType, stateType, names.states); err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(&out, "// %s is an event handled by the machine\ntype %s string\n\n", eventType, eventType)
+	if err := writeGeneratedConstants(&out, eventType, eventType, names.events); err != nil {
+		return nil, err
+	}
+	if len(names.guards) > 0 {
+		out.WriteString("// Guard names to register before loading the definition\n")
+		if err := writeGeneratedConstants(&out, opts.Name+"Guard", "", names.guards); err != nil {
+			return nil, err
+		}
+	}
+	if len(names.actions) > 0 {
+		out.WriteString("// Action names to register before loading the definition\n")
+		if err := writeGeneratedConstants(&out, opts.Name+"Action", "", names.actions); err != nil {
+			return nil, err
+		}
+	}
+
+	fmt.Fprintf(&out, "// New%sDefinition builds the machine, resolving guards and actions by name\n", opts.Name)
+	out.WriteString("// from the registry, or from the default registry when it is nil\n")
+	fmt.Fprintf(&out, "func New%sDefinition(registry *fluo.Registry) (fluo.MachineDefinition, error) {\n", opts.Name)
+	out.WriteString("\tif registry == nil {\n\t\tregistry = fluo.DefaultRegistry\n\t}\n\treturn registry.LoadDefinition(&")
+	writeGoLiteral(&out, reflect.ValueOf(*doc), true)
+	out.WriteString(")\n}\n\n")
+
+	fmt.Fprintf(&out, "// %s is a machine instance sending and checking typed states and events\n", machineType)
+	fmt.Fprintf(&out, "type %s = fluo.TypedMachine[%s, %s]\n", machineType, stateType, eventType)
+
+	formatted, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("generator: formatting generated code: %w", err)
+	}
+	return formatted, nil
+}
+
+// documentNames are the names declared by a document, each sorted
+type documentNames struct {
+	states  []string
+	events  []string
+	guards  []string
+	actions []string
+}
+
+// collectDocumentNames gathers the full state IDs, events, guards and actions
+// of a document
+func collectDocumentNames(doc *MachineDocument) documentNames {
+	var names documentNames
+	var walk func(states []StateDocument, parentPath string)
+	walk = func(states []StateDocument, parentPath string) {
+		for _, state := range states {
+			id := state.ID
+			if parentPath != "" {
+				id = parentPath + "." + state.ID
+			}
+			names.states = append(names.states, id)
+			names.actions = append(names.actions, state.OnEntry, state.OnExit)
+			for _, transition := range state.Transitions {
+				names.events = append(names.events, transition.Event)
+				names.guards = append(names.guards, transition.Guard)
+				names.actions = append(names.actions, transition.Action)
+			}
+			for _, branch := range state.Branches {
+				names.guards = append(names.guards, branch.Guard)
+				names.actions = append(names.actions, branch.Action)
+			}
+			walk(state.States, id)
+			for _, region := range state.Regions {
+				walk(region.States, id+"."+region.ID)
+			}
+		}
+	}
+	walk(doc.States, "")
+
+	for _, list := range []*[]string{&names.states, &names.events, &names.guards, &names.actions} {
+		*list = slices.DeleteFunc(*list, func(name string) bool { return name == "" })
+		slices.Sort(*list)
+		*list = slices.Compact(*list)
+	}
+	return names
+}
+
+// writeGeneratedConstants declares a constant per name, prefixed with prefix
+// and of the given type, or untyped when typeName is empty
+func writeGeneratedConstants(out *bytes.Buffer, prefix, typeName string, names []string) error {
+	out.WriteString("const (\n")
+	seen := make(map[string]string, len(names))
+	for _, name := range names {
+		identifier := prefix + exportedIdentifier(name)
+		if other, exists := seen[identifier]; exists {
+			return NewConfigurationError("generator", fmt.Sprintf("'%s' and '%s' both map to %s", other, name, identifier))
+		}
+		if !token.IsIdentifier(identifier) {
+			return NewConfigurationError("generator", fmt.Sprintf("'%s' does not map to a Go identifier", name))
+		}
+		seen[identifier] = name
+		if typeName == "" {
+			fmt.Fprintf(out, "\t%s = %s\n", identifier, strconv.Quote(name))
+		} else {
+			fmt.Fprintf(out, "\t%s %s = %s\n", identifier, typeName, strconv.Quote(name))
+		}
+	}
+	out.WriteString(")\n\n")
+	return nil
+}
+
+// exportedIdentifier turns a name such as "review.approved" or "send-mail"
+// into the exported identifier part "ReviewApproved" or "SendMail"
+func exportedIdentifier(name string) string {
+	var identifier strings.Builder
+	upper := true
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		identifier.WriteRune(r)
+	}
+	return identifier.String()
+}
+
+// writeGoLiteral writes a document value as a Go composite literal, leaving
+// out zero fields. typed writes the type of a struct, which elements of a
+// slice literal elide.
+func writeGoLiteral(out *bytes.Buffer, value reflect.Value, typed bool) {
+	switch value.Kind() {
+	case reflect.Struct:
+		if typed {
+			out.WriteString("fluo." + value.Type().Name())
+		}
+		out.WriteString("{\n")
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Field(i)
+			if field.IsZero() {
+				continue
+			}
+			out.WriteString(value.Type().Field(i).Name + ": ")
+			writeGoLiteral(out, field, true)
+			out.WriteString(",\n")
+		}
+		out.WriteString("}")
+	case reflect.Slice:
+		element := value.Type().Elem()
+		if element.Kind() == reflect.Struct {
+			out.WriteString("[]fluo." + element.Name() + "{\n")
+		} else {
+			out.WriteString("[]" + element.String() + "{")
+		}
+		for i := 0; i < value.Len(); i++ {
+			writeGoLiteral(out, value.Index(i), false)
+			if element.Kind() == reflect.Struct {
+				out.WriteString(",\n")
+			} else if i < value.Len()-1 {
+				out.WriteString(", ")
+			}
+		}
+		out.WriteString("}")
+	case reflect.String:
+		out.WriteString(strconv.Quote(value.String()))
+	case reflect.Bool:
+		out.WriteString(strconv.FormatBool(value.Bool()))
+	case reflect.Int:
+		out.WriteString(strconv.FormatInt(value.Int(), 10))
+	}
+}
@@ -0,0 +1,72 @@
+package fluo
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+const generateTestDocument = `
+initial: draft
+states:
+  - id: draft
+    transitions:
+      - event: submit
+        target: review
+        guard: isComplete
+  - id: review
+    type: composite
+    initial: pending
+    onEntry: notify-reviewers
+    states:
+      - id: pending
+        transitions: [{event: approve, target: approved, action: recordApproval}]
+      - id: approved
+        type: final
+`
+
+func TestGenerateGo(t *testing.T) {
+	doc, err := ParseDocumentYAML([]byte(generateTestDocument))
+	if err != nil {
+		t.Fatalf("Expected the document to parse, got: %v", err)
+	}
+	code, err := GenerateGo(doc, GenerateOptions{Package: "orders", Name: "Order", Source: "order.yaml"})
+	if err != nil {
+		t.Fatalf("Expected code to be generated, got: %v", err)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "order_fluo.go", code, 0); err != nil {
+		t.Fatalf("Expected valid Go source, got: %v\n%s", err, code)
+	}
+
+	source := string(code)
+	for _, expected := range []string{
+		"// Code generated by fluo-gen from order.yaml. DO NOT EDIT.",
+		"package orders",
+		`OrderStateDraft          OrderState = "draft"`,
+		`OrderStateReviewApproved OrderState = "review.approved"`,
+		`OrderEventSubmit  OrderEvent = "submit"`,
+		`OrderGuardIsComplete = "isComplete"`,
+		`OrderActionNotifyReviewers = "notify-reviewers"`,
+		"func NewOrderDefinition(registry *fluo.Registry) (fluo.MachineDefinition, error) {",
+		"type OrderMachine = fluo.TypedMachine[OrderState, OrderEvent]",
+		`Initial: "pending",`,
+	} {
+		if !strings.Contains(source, expected) {
+			t.Errorf("Expected generated code to contain %q\n%s", expected, source)
+		}
+	}
+	if strings.Contains(source, "Internal:") {
+		t.Error("Expected zero fields to be left out of the document literal")
+	}
+}
+
+func TestGenerateGo_Errors(t *testing.T) {
+	doc := &MachineDocument{Initial: "a", States: []StateDocument{{ID: "send-mail"}, {ID: "send_mail"}}}
+	if _, err := GenerateGo(doc, GenerateOptions{Package: "flows"}); !IsConfigurationError(err) {
+		t.Errorf("Expected a configuration error for colliding identifiers, got %v", err)
+	}
+	if _, err := GenerateGo(&MachineDocument{}, GenerateOptions{Package: "not a package"}); !IsConfigurationError(err) {
+		t.Errorf("Expected a configuration error for an invalid package, got %v", err)
+	}
+}
@@ -0,0 +1,5 @@
+// Package fluopb contains the protobuf contract of the fluo gRPC service and
+// the code generated from it.
+package fluopb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative fluo.proto
@@ -0,0 +1,258 @@
+// Package grpcserver implements the fluo.v1.MachineService gRPC service
+// defined in fluopb/fluo.proto, so machines can be driven and observed from
+// other languages. It is a separate module to keep gRPC out of the
+// dependencies of the core library.
+package grpcserver
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/anggasct/fluo"
+	"github.com/anggasct/fluo/grpcserver/fluopb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// DefaultSubscriptionBuffer is the number of transitions buffered for a
+// subscriber before its stream is ended for falling behind
+const DefaultSubscriptionBuffer = 64
+
+// Server implements fluopb.MachineServiceServer for registered machine definitions
+type Server struct {
+	fluopb.UnimplementedMachineServiceServer
+
+	machines map[string]*fluo.Manager
+	buffer   int
+	mutex    sync.RWMutex
+}
+
+// Option configures a Server
+type Option func(*Server)
+
+// WithSubscriptionBuffer sets the number of transitions buffered per subscriber
+func WithSubscriptionBuffer(size int) Option {
+	return func(s *Server) {
+		s.buffer = size
+	}
+}
+
+// New creates a server with no registered machines
+func New(opts ...Option) *Server {
+	s := &Server{
+		machines: make(map[string]*fluo.Manager),
+		buffer:   DefaultSubscriptionBuffer,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Register serves a definition under the given name. Its instances are
+// managed by a fluo.Manager created with opts, which is returned so the
+// caller can work with the same instances directly.
+func (s *Server) Register(name string, definition fluo.MachineDefinition, opts ...fluo.ManagerOption) *fluo.Manager {
+	manager := fluo.NewManager(definition, opts...)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.machines[name] = manager
+	return manager
+}
+
+// Attach registers the service with a gRPC server
+func (s *Server) Attach(server grpc.ServiceRegistrar) {
+	fluopb.RegisterMachineServiceServer(server, s)
+}
+
+// CreateInstance creates and starts an instance of a registered machine
+func (s *Server) CreateInstance(ctx context.Context, request *fluopb.CreateInstanceRequest) (*fluopb.Instance, error) {
+	manager, err := s.lookup(request.GetMachine())
+	if err != nil {
+		return nil, err
+	}
+
+	instance, err := manager.Create(request.GetId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	for key, value := range request.GetContext().AsMap() {
+		instance.Context().Set(key, value)
+	}
+	if err := instance.Start(); err != nil {
+		_ = manager.Delete(instance.ID())
+		return nil, toStatus(err)
+	}
+	if err := manager.Save(instance.ID()); err != nil {
+		return nil, toStatus(err)
+	}
+	return describe(instance), nil
+}
+
+// SendEvent sends an event to an instance
+func (s *Server) SendEvent(ctx context.Context, request *fluopb.SendEventRequest) (*fluopb.EventResult, error) {
+	manager, err := s.lookup(request.GetMachine())
+	if err != nil {
+		return nil, err
+	}
+	if request.GetEvent() == "" {
+		return nil, status.Error(codes.InvalidArgument, "event name is required")
+	}
+
+	var data any
+	if request.GetData() != nil {
+		data = request.GetData().AsInterface()
+	}
+
+	result := manager.SendEventWithContext(ctx, request.GetId(), request.GetEvent(), data)
+	if fluo.GetErrorCode(result.Error) == fluo.ErrCodeInstanceNotFound {
+		return nil, toStatus(result.Error)
+	}
+
+	response := &fluopb.EventResult{
+		Processed:       result.Processed,
+		StateChanged:    result.StateChanged,
+		PreviousState:   result.PreviousState,
+		CurrentState:    result.CurrentState,
+		RejectionReason: result.RejectionReason,
+	}
+	if result.Error != nil {
+		response.Error = result.Error.Error()
+	}
+	if instance, ok := manager.Get(request.GetId()); ok {
+		response.ActiveStates = activeStates(instance)
+	}
+	return response, nil
+}
+
+// GetState returns the current and active states of an instance
+func (s *Server) GetState(ctx context.Context, request *fluopb.GetStateRequest) (*fluopb.Instance, error) {
+	manager, err := s.lookup(request.GetMachine())
+	if err != nil {
+		return nil, err
+	}
+
+	instance, err := manager.Load(request.GetId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return describe(instance), nil
+}
+
+// Subscribe streams the transitions of an instance until the client cancels
+// the call. Response headers are sent once the subscription is active, so
+// transitions taken after they are received are delivered. A subscriber that
+// falls more than the subscription buffer behind has its stream ended with
+// ResourceExhausted.
+func (s *Server) Subscribe(request *fluopb.SubscribeRequest, stream grpc.ServerStreamingServer[fluopb.Transition]) error {
+	manager, err := s.lookup(request.GetMachine())
+	if err != nil {
+		return err
+	}
+
+	instance, err := manager.Load(request.GetId())
+	if err != nil {
+		return toStatus(err)
+	}
+
+	subscriber := &subscriber{
+		id:          instance.ID(),
+		transitions: make(chan *fluopb.Transition, s.buffer),
+		lagging:     make(chan struct{}),
+	}
+	instance.AddObserver(subscriber)
+	defer instance.RemoveObserver(subscriber)
+
+	// Headers tell the client the subscription is active
+	if err := stream.SendHeader(metadata.MD{}); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-subscriber.lagging:
+			return status.Error(codes.ResourceExhausted, "subscriber fell too far behind")
+		case transition := <-subscriber.transitions:
+			if err := stream.Send(transition); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// lookup returns the manager of a registered machine
+func (s *Server) lookup(name string) (*fluo.Manager, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	manager, ok := s.machines[name]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "machine '%s' is not registered", name)
+	}
+	return manager, nil
+}
+
+// subscriber is an observer forwarding transitions to a Subscribe stream
+type subscriber struct {
+	fluo.BaseObserver
+	id          string
+	transitions chan *fluopb.Transition
+	lagging     chan struct{}
+	once        sync.Once
+}
+
+// OnTransition queues a transition without blocking the machine
+func (sub *subscriber) OnTransition(from string, to string, event fluo.Event, ctx fluo.Context) {
+	transition := &fluopb.Transition{
+		Id:   sub.id,
+		From: from,
+		To:   to,
+		Time: timestamppb.Now(),
+	}
+	if event != nil {
+		transition.Event = event.GetName()
+	}
+
+	select {
+	case sub.transitions <- transition:
+	default:
+		sub.once.Do(func() { close(sub.lagging) })
+	}
+}
+
+// describe renders an instance's state
+func describe(instance fluo.Machine) *fluopb.Instance {
+	return &fluopb.Instance{
+		Id:           instance.ID(),
+		CurrentState: instance.CurrentState(),
+		ActiveStates: activeStates(instance),
+	}
+}
+
+// activeStates returns an instance's active states in sorted order
+func activeStates(instance fluo.Machine) []string {
+	states := instance.GetActiveStates()
+	slices.Sort(states)
+	return states
+}
+
+// toStatus maps a fluo error to a gRPC status
+func toStatus(err error) error {
+	switch fluo.GetErrorCode(err) {
+	case fluo.ErrCodeInstanceNotFound:
+		return status.Error(codes.NotFound, err.Error())
+	case fluo.ErrCodeInstanceExists:
+		return status.Error(codes.AlreadyExists, err.Error())
+	case fluo.ErrCodeInvalidConfiguration, fluo.ErrCodeStateNotFound:
+		return status.Error(codes.InvalidArgument, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
@@ -0,0 +1,160 @@
+package grpcserver_test
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anggasct/fluo"
+	"github.com/anggasct/fluo/grpcserver"
+	"github.com/anggasct/fluo/grpcserver/fluopb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func newTestClient(t *testing.T) fluopb.MachineServiceClient {
+	t.Helper()
+
+	definition := fluo.NewMachine().
+		State("draft").Initial().
+		To("review").On("submit").
+		State("review").
+		To("approved").On("approve").
+		State("approved").
+		Build()
+
+	service := grpcserver.New()
+	service.Register("document", definition)
+
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	service.Attach(server)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return fluopb.NewMachineServiceClient(conn)
+}
+
+func TestServer_CreateSendAndGetState(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+
+	values, _ := structpb.NewStruct(map[string]any{"owner": "alice"})
+	instance, err := client.CreateInstance(ctx, &fluopb.CreateInstanceRequest{Machine: "document", Id: "doc-1", Context: values})
+	if err != nil || instance.GetCurrentState() != "draft" {
+		t.Fatalf("Unexpected create response: %v, %v", instance, err)
+	}
+	if _, err := client.CreateInstance(ctx, &fluopb.CreateInstanceRequest{Machine: "document", Id: "doc-1"}); status.Code(err) != codes.AlreadyExists {
+		t.Errorf("Expected AlreadyExists, got %v", err)
+	}
+
+	result, err := client.SendEvent(ctx, &fluopb.SendEventRequest{Machine: "document", Id: "doc-1", Event: "submit", Data: structpb.NewStringValue("v1")})
+	if err != nil || !result.GetProcessed() || result.GetCurrentState() != "review" {
+		t.Errorf("Unexpected event result: %v, %v", result, err)
+	}
+
+	result, err = client.SendEvent(ctx, &fluopb.SendEventRequest{Machine: "document", Id: "doc-1", Event: "submit"})
+	if err != nil || result.GetProcessed() || result.GetRejectionReason() == "" {
+		t.Errorf("Expected a rejected event, got %v, %v", result, err)
+	}
+
+	instance, err = client.GetState(ctx, &fluopb.GetStateRequest{Machine: "document", Id: "doc-1"})
+	if err != nil || instance.GetCurrentState() != "review" {
+		t.Errorf("Unexpected state: %v, %v", instance, err)
+	}
+}
+
+func TestServer_NotFound(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+
+	if _, err := client.GetState(ctx, &fluopb.GetStateRequest{Machine: "unknown", Id: "doc-1"}); status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound for an unknown machine, got %v", err)
+	}
+	if _, err := client.SendEvent(ctx, &fluopb.SendEventRequest{Machine: "document", Id: "missing", Event: "submit"}); status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound for an unknown instance, got %v", err)
+	}
+}
+
+func TestServer_Subscribe(t *testing.T) {
+	client := newTestClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := client.CreateInstance(ctx, &fluopb.CreateInstanceRequest{Machine: "document", Id: "doc-1"}); err != nil {
+		t.Fatalf("Failed to create instance: %v", err)
+	}
+
+	stream, err := client.Subscribe(ctx, &fluopb.SubscribeRequest{Machine: "document", Id: "doc-1"})
+	if err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+	// The stream is established once the server has sent its headers
+	if _, err := stream.Header(); err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+
+	go func() {
+		for _, event := range []string{"submit", "approve"} {
+			_, _ = client.SendEvent(ctx, &fluopb.SendEventRequest{Machine: "document", Id: "doc-1", Event: event})
+		}
+	}()
+
+	for _, expected := range []string{"review", "approved"} {
+		transition, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Failed to receive transition: %v", err)
+		}
+		if transition.GetTo() != expected || transition.GetId() != "doc-1" {
+			t.Errorf("Expected transition to %s, got %v", expected, transition)
+		}
+	}
+}
+
+func TestServer_SubscribeWhileSending(t *testing.T) {
+	client := newTestClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := client.CreateInstance(ctx, &fluopb.CreateInstanceRequest{Machine: "document", Id: "doc-1"}); err != nil {
+		t.Fatalf("Failed to create instance: %v", err)
+	}
+
+	// Subscriptions come and go while other calls send events to the instance
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for range 10 {
+				subscribeCtx, unsubscribe := context.WithCancel(ctx)
+				if stream, err := client.Subscribe(subscribeCtx, &fluopb.SubscribeRequest{Machine: "document", Id: "doc-1"}); err == nil {
+					_, _ = stream.Header()
+				}
+				unsubscribe()
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for range 10 {
+				_, _ = client.SendEvent(ctx, &fluopb.SendEventRequest{Machine: "document", Id: "doc-1", Event: "submit"})
+			}
+		}()
+	}
+	wg.Wait()
+}
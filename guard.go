@@ -0,0 +1,123 @@
+package fluo
+
+import "time"
+
+// And combines guards into one that passes when all of them pass, evaluating
+// them in order and stopping at the first failure
+func And(guards ...GuardFunc) GuardFunc {
+	return func(ctx Context) bool {
+		for _, guard := range guards {
+			if !guard(ctx) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Or combines guards into one that passes when any of them passes, evaluating
+// them in order and stopping at the first success
+func Or(guards ...GuardFunc) GuardFunc {
+	return func(ctx Context) bool {
+		for _, guard := range guards {
+			if guard(ctx) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not negates a guard
+func Not(guard GuardFunc) GuardFunc {
+	return func(ctx Context) bool {
+		return !guard(ctx)
+	}
+}
+
+// ChoiceFallbackFunc decides where a choice goes when none of its branches
+// is viable. It returns the target state, or an error to report; returning
+// neither leaves the choice failing as without a fallback. A target that is
+// not a state of the machine fails the choice with a TransitionError.
+type ChoiceFallbackFunc func(choiceID string, ctx Context) (string, error)
+
+// WithChoiceFallback sets the policy applied when a choice has no viable branch
+func WithChoiceFallback(fallback ChoiceFallbackFunc) MachineOption {
+	return func(sm *StateMachine) {
+		sm.choiceFallback = fallback
+	}
+}
+
+// WithGuardTimeout bounds the evaluation time of every guard of the machine.
+// A guard that exceeds the timeout is treated as failed and reported to
+// observers as a TimeoutError; it keeps running in the background until it
+// returns, but no longer holds up event processing.
+func WithGuardTimeout(timeout time.Duration) MachineOption {
+	return func(sm *StateMachine) {
+		sm.guardTimeout = timeout
+	}
+}
+
+// evaluateGuard runs a guard with panic recovery and the applicable timeout,
+// reporting failures to observers. A zero timeout uses the machine-wide one.
+// While the selector is probed failures are returned without being reported,
+// and a dry run fails every guard.
+func (sm *StateMachine) evaluateGuard(guard GuardFunc, timeout time.Duration) (bool, error) {
+	if timeout <= 0 {
+		timeout = sm.guardTimeout
+	}
+	if sm.dryRun {
+		return false, nil
+	}
+	if sm.probe != nil {
+		if timeout <= 0 {
+			return explainGuard(guard, sm.context)
+		}
+		return sm.evaluateGuardWithTimeout(guard, timeout, explainGuard)
+	}
+	if sm.stats != nil {
+		defer sm.stats.observeGuard(time.Now())
+	}
+
+	var result bool
+	var err error
+	if timeout <= 0 {
+		result, err = safeEvaluateGuard(guard, sm.context)
+	} else {
+		result, err = sm.evaluateGuardWithTimeout(guard, timeout, safeEvaluateGuard)
+	}
+
+	if err != nil {
+		sm.observers.NotifyError(err, sm.context)
+		sm.observers.NotifyStageError(StageGuard, err, sm.context)
+		sm.recoveredPanic(err)
+		return false, err
+	}
+	return result, nil
+}
+
+// guardOutcome is the result of a guard evaluated on another goroutine
+type guardOutcome struct {
+	result bool
+	err    error
+}
+
+// evaluateGuardWithTimeout runs a guard with evaluate on its own goroutine and
+// gives up once the timeout expires
+func (sm *StateMachine) evaluateGuardWithTimeout(guard GuardFunc, timeout time.Duration, evaluate func(GuardFunc, Context) (bool, error)) (bool, error) {
+	done := make(chan guardOutcome, 1)
+	go func() {
+		result, err := evaluate(guard, sm.context)
+		done <- guardOutcome{result: result, err: err}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case outcome := <-done:
+		return outcome.result, outcome.err
+	case <-timer.C:
+		return false, NewTimeoutError("guard", sm.currentState, timeout)
+	}
+}
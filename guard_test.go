@@ -0,0 +1,317 @@
+package fluo
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func slowGuard(delay time.Duration) GuardFunc {
+	return func(ctx Context) bool {
+		time.Sleep(delay)
+		return true
+	}
+}
+
+func TestGuardTimeout_MachineWide(t *testing.T) {
+	definition := NewMachine().
+		State("idle").Initial().
+		To("checked").On("check").When(slowGuard(time.Second)).
+		To("fallback").On("check").
+		State("checked").
+		State("fallback").
+		Build()
+
+	machine := definition.CreateInstance(WithGuardTimeout(20 * time.Millisecond))
+	observer := NewTestObserver()
+	machine.AddObserver(observer)
+	_ = machine.Start()
+
+	start := time.Now()
+	machine.HandleEvent("check", nil)
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("Expected guard to time out quickly, took %s", elapsed)
+	}
+	AssertState(t, machine, "fallback")
+
+	if len(observer.Errors) != 1 {
+		t.Fatalf("Expected 1 reported error, got %d", len(observer.Errors))
+	}
+	if !IsTimeoutError(observer.Errors[0].Error) {
+		t.Errorf("Expected TimeoutError, got %v", observer.Errors[0].Error)
+	}
+	if GetErrorCode(observer.Errors[0].Error) != ErrCodeTimeout {
+		t.Errorf("Expected ErrCodeTimeout")
+	}
+}
+
+func TestGuardTimeout_PerTransitionOverride(t *testing.T) {
+	definition := NewMachine().
+		State("idle").Initial().
+		To("checked").On("check").When(slowGuard(50 * time.Millisecond)).GuardTimeout(time.Second).
+		State("checked").
+		Build()
+
+	machine := definition.CreateInstance(WithGuardTimeout(10 * time.Millisecond))
+	_ = machine.Start()
+
+	AssertEventProcessed(t, machine.HandleEvent("check", nil), true)
+	AssertState(t, machine, "checked")
+}
+
+func TestGuardTimeout_DefaultOff(t *testing.T) {
+	definition := NewMachine().
+		State("idle").Initial().
+		To("checked").On("check").When(slowGuard(30 * time.Millisecond)).
+		State("checked").
+		Build()
+
+	machine := definition.CreateInstance()
+	observer := NewTestObserver()
+	machine.AddObserver(observer)
+	_ = machine.Start()
+
+	AssertEventProcessed(t, machine.HandleEvent("check", nil), true)
+	AssertState(t, machine, "checked")
+	if len(observer.Errors) != 0 {
+		t.Errorf("Expected no errors, got %d", len(observer.Errors))
+	}
+}
+
+func TestGuardCombinators(t *testing.T) {
+	yes := func(ctx Context) bool { return true }
+	no := func(ctx Context) bool { return false }
+	ctx := NewSimpleContext()
+
+	tests := []struct {
+		name  string
+		guard GuardFunc
+		want  bool
+	}{
+		{"and all pass", And(yes, yes), true},
+		{"and one fails", And(yes, no), false},
+		{"and empty", And(), true},
+		{"or one passes", Or(no, yes), true},
+		{"or none pass", Or(no, no), false},
+		{"or empty", Or(), false},
+		{"not", Not(no), true},
+		{"nested", And(yes, Or(no, Not(no))), true},
+	}
+	for _, tt := range tests {
+		if got := tt.guard(ctx); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGuardCombinators_ShortCircuit(t *testing.T) {
+	calls := 0
+	counting := func(ctx Context) bool {
+		calls++
+		return true
+	}
+	ctx := NewSimpleContext()
+
+	And(func(ctx Context) bool { return false }, counting)(ctx)
+	Or(func(ctx Context) bool { return true }, counting)(ctx)
+	if calls != 0 {
+		t.Errorf("Expected combinators to short-circuit, got %d calls", calls)
+	}
+}
+
+func TestWhenGuard_Registered(t *testing.T) {
+	RegisterGuard("test.isUrgent", func(ctx Context) bool {
+		urgent, _ := ctx.Get("urgent")
+		return urgent == true
+	})
+
+	definition := NewMachine().
+		State("queued").Initial().
+		To("escalated").On("triage").WhenGuard("test.isUrgent").
+		To("backlog").On("triage").
+		State("escalated").
+		State("backlog").
+		Build()
+
+	if !strings.Contains(ExportMermaid(definition), "[test.isUrgent]") {
+		t.Error("Expected guard name in the Mermaid export")
+	}
+	for _, transition := range Describe(definition).Transitions {
+		if transition.To == "escalated" && transition.Guard != "test.isUrgent" {
+			t.Errorf("Expected described guard name, got %q", transition.Guard)
+		}
+	}
+
+	machine := definition.CreateInstance()
+	_ = machine.Start()
+	machine.Context().Set("urgent", true)
+	machine.HandleEvent("triage", nil)
+	AssertState(t, machine, "escalated")
+}
+
+func TestWhenGuard_Unregistered(t *testing.T) {
+	_, err := NewMachine().
+		State("queued").Initial().
+		To("escalated").On("triage").WhenGuard("test.missing").
+		State("escalated").
+		BuildE()
+
+	var configErr *ConfigurationError
+	if !errors.As(err, &configErr) || !strings.Contains(err.Error(), "test.missing") {
+		t.Errorf("Expected ConfigurationError naming the guard, got %v", err)
+	}
+}
+
+func TestWhenNamed_DoNamed(t *testing.T) {
+	definition := NewMachine().
+		State("cart").Initial().
+		To("paid").On("checkout").
+		WhenNamed("hasItems", func(ctx Context) bool { return GetOr(ctx, "items", 0) > 0 }).
+		DoNamed("chargeCard", func(ctx Context) error { return errors.New("card declined") }).
+		State("paid").
+		Build()
+
+	if !strings.Contains(ExportMermaid(definition), "[hasItems]") {
+		t.Error("Expected guard name in the Mermaid export")
+	}
+	for _, transition := range Describe(definition).Transitions {
+		if transition.Guard != "hasItems" || transition.Action != "chargeCard" {
+			t.Errorf("Expected described guard and action names, got %q and %q", transition.Guard, transition.Action)
+		}
+	}
+
+	machine := definition.CreateInstance()
+	_ = machine.Start()
+	if explanation := machine.(*StateMachine).Explain("checkout"); !strings.Contains(explanation.String(), "hasItems failed") {
+		t.Errorf("Expected explanation to name the guard, got %s", explanation)
+	}
+	if result := machine.SendEvent("checkout", nil); result.FailedGuard != "hasItems" {
+		t.Errorf("Expected failed guard hasItems, got %q", result.FailedGuard)
+	}
+
+	machine.Context().Set("items", 2)
+	result := machine.SendEvent("checkout", nil)
+	if result.FailedAction != "chargeCard" {
+		t.Errorf("Expected failed action chargeCard, got %q", result.FailedAction)
+	}
+	AssertState(t, machine, "cart")
+}
+
+func TestEventContext_GuardSeesDeadline(t *testing.T) {
+	var sawDeadline bool
+	machine := NewMachine().
+		State("idle").Initial().
+		To("busy").On("start").When(func(ctx Context) bool {
+		_, sawDeadline = ctx.Deadline()
+		return true
+	}).
+		State("busy").
+		Build().CreateInstance()
+	_ = machine.Start()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	AssertEventProcessed(t, machine.HandleEventWithContext(ctx, "start", nil), true)
+	if !sawDeadline {
+		t.Error("Expected the guard to see the event's deadline")
+	}
+	if _, ok := machine.Context().Deadline(); ok {
+		t.Error("Expected the deadline to be unbound after the event")
+	}
+}
+
+func TestEventContext_ActionHonorsCancellation(t *testing.T) {
+	machine := NewMachine().
+		State("idle").Initial().
+		To("busy").On("start").Do(func(ctx Context) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+			return nil
+		}
+	}).
+		State("busy").
+		Build().CreateInstance()
+	_ = machine.Start()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	result := machine.HandleEventWithContext(ctx, "start", nil)
+	AssertEventProcessed(t, result, false)
+	if !errors.Is(result.Error, context.DeadlineExceeded) {
+		t.Errorf("Expected a deadline error, got %v", result.Error)
+	}
+	AssertState(t, machine, "idle")
+}
+
+func TestEventContext_ExpiredActionAbortsTransition(t *testing.T) {
+	machine := NewMachine().
+		State("idle").Initial().
+		To("busy").On("start").Do(func(ctx Context) error {
+		time.Sleep(30 * time.Millisecond)
+		return nil
+	}).OnError("failed").
+		State("busy").
+		State("failed").
+		Build().CreateInstance()
+	_ = machine.Start()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	result := machine.HandleEventWithContext(ctx, "start", nil)
+	AssertEventProcessed(t, result, false)
+	if !strings.Contains(result.RejectionReason, "transition aborted") {
+		t.Errorf("Expected the transition to be aborted, got %q", result.RejectionReason)
+	}
+	AssertState(t, machine, "idle")
+}
+
+func TestEventContext_CancelledBeforeProcessing(t *testing.T) {
+	guarded := false
+	machine := NewMachine().
+		State("idle").Initial().
+		To("busy").On("start").When(func(ctx Context) bool {
+		guarded = true
+		return true
+	}).
+		State("busy").
+		Build().CreateInstance()
+	_ = machine.Start()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result := machine.HandleEventWithContext(ctx, "start", nil)
+	AssertEventProcessed(t, result, false)
+	if !errors.Is(result.Error, context.Canceled) || guarded {
+		t.Errorf("Expected the event to be rejected before guards ran, got %v", result.Error)
+	}
+}
+
+func TestDoNamed_ObservedByName(t *testing.T) {
+	declined := func(ctx Context) error { return errors.New("card declined") }
+	definition := NewMachine().
+		State("cart").Initial().
+		To("paid").On("checkout").DoNamed("chargeCard", declined).
+		To("cart").On("recount").Internal().DoNamed("recountItems", declined).
+		State("paid").
+		Build()
+
+	machine := definition.CreateInstance()
+	observer := NewTestObserver()
+	machine.AddObserver(observer)
+	_ = machine.Start()
+	machine.HandleEvent("checkout", nil)
+	machine.HandleEvent("recount", nil)
+
+	if len(observer.Actions) != 2 || observer.Actions[0].ActionType != "chargeCard" || observer.Actions[1].ActionType != "recountItems" {
+		t.Errorf("Expected observers to see the action names, got %+v", observer.Actions)
+	}
+	if len(observer.EventRejects) != 2 ||
+		observer.EventRejects[0].Reason != "transition action 'chargeCard' failed: card declined" ||
+		observer.EventRejects[1].Reason != "transition action 'recountItems' failed: card declined" {
+		t.Errorf("Expected both rejections to name their action the same way, got %+v", observer.EventRejects)
+	}
+}
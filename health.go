@@ -0,0 +1,115 @@
+package fluo
+
+import (
+	"maps"
+	"slices"
+)
+
+// MachineHealth reports the size of the active state set and the states that
+// linger outside the machine's current configuration
+type MachineHealth struct {
+	ActiveStates    int
+	MaxActiveStates int
+	LimitExceeded   bool
+	// Leaked lists active states that are neither on the current state's path,
+	// in an active parallel region, in an intact fork, nor waiting on a join
+	Leaked []string
+}
+
+// WithMaxActiveStates bounds the active state set. Growing past the bound
+// alerts ActiveStateLimitObserver observers and is reported by Health.
+func WithMaxActiveStates(limit int) MachineOption {
+	return func(sm *StateMachine) {
+		sm.maxActiveStates = limit
+	}
+}
+
+// Health reports the active state set size against its bound and any leaked states
+func (sm *StateMachine) Health() MachineHealth {
+	sm.mutex.RLock()
+	defer sm.mutex.RUnlock()
+
+	active := len(sm.activeStateSet())
+	return MachineHealth{
+		ActiveStates:    active,
+		MaxActiveStates: sm.maxActiveStates,
+		LimitExceeded:   sm.maxActiveStates > 0 && active > sm.maxActiveStates,
+		Leaked:          sm.leakedStates(),
+	}
+}
+
+// activeStateSet returns the current state and all other active states, in ID order
+func (sm *StateMachine) activeStateSet() []string {
+	set := maps.Clone(sm.activeStates)
+	if set == nil {
+		set = make(map[string]bool)
+	}
+	if sm.currentState != "" {
+		set[sm.currentState] = true
+	}
+	return slices.Sorted(maps.Keys(set))
+}
+
+// checkActiveStateLimit alerts observers once each time the active state set grows past its bound
+func (sm *StateMachine) checkActiveStateLimit() {
+	if sm.maxActiveStates <= 0 {
+		return
+	}
+	active := sm.activeStateSet()
+	if len(active) <= sm.maxActiveStates {
+		sm.activeLimitExceeded = false
+		return
+	}
+	if sm.activeLimitExceeded {
+		return
+	}
+	sm.activeLimitExceeded = true
+	sm.observers.NotifyActiveStateLimitExceeded(active, sm.maxActiveStates, sm.context)
+}
+
+// leakedStates returns the active states outside the current configuration, in ID order
+func (sm *StateMachine) leakedStates() []string {
+	expected := make(map[string]bool)
+	for stateID := sm.currentState; stateID != ""; {
+		expected[stateID] = true
+		state, exists := sm.states[stateID]
+		if !exists || state.Parent() == nil {
+			break
+		}
+		stateID = state.Parent().ID()
+	}
+	for _, group := range sm.parallelRegions {
+		// A fork's targets belong to the configuration until one of them leaves
+		live := true
+		for _, stateID := range group {
+			if !sm.activeStates[stateID] {
+				live = false
+				break
+			}
+		}
+		if live {
+			for _, stateID := range group {
+				expected[stateID] = true
+			}
+		}
+	}
+	for _, combinations := range sm.joinConditions {
+		for _, combination := range combinations {
+			for _, source := range combination {
+				expected[source] = true
+			}
+		}
+	}
+
+	var leaked []string
+	for _, stateID := range slices.Sorted(maps.Keys(sm.activeStates)) {
+		if expected[stateID] {
+			continue
+		}
+		if region := sm.findRegionForState(stateID); region != nil && sm.activeStates[region.ParentState().ID()] {
+			continue
+		}
+		leaked = append(leaked, stateID)
+	}
+	return leaked
+}
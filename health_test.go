@@ -0,0 +1,89 @@
+package fluo
+
+import (
+	"slices"
+	"testing"
+)
+
+func buildLeakyForkMachine() MachineDefinition {
+	builder := NewMachine()
+	builder.State("start").Initial().
+		To("fork1").On("split")
+	builder.Fork("fork1").
+		To("upload", "notify")
+	builder.State("upload").
+		To("aborted").On("abort")
+	builder.State("notify")
+	builder.State("aborted").
+		To("start").On("retry")
+	return builder.Build()
+}
+
+func TestHealth_ReportsLeakedForkTargets(t *testing.T) {
+	machine := buildLeakyForkMachine().CreateInstance()
+	_ = machine.Start()
+
+	if health := machine.Health(); len(health.Leaked) != 0 {
+		t.Fatalf("Expected no leaked states at start, got %v", health.Leaked)
+	}
+
+	machine.HandleEvent("split", nil)
+	if health := machine.Health(); len(health.Leaked) != 0 {
+		t.Fatalf("Expected intact fork targets not to be leaked, got %v", health.Leaked)
+	}
+	machine.HandleEvent("abort", nil)
+
+	health := machine.Health()
+	if !slices.Contains(health.Leaked, "notify") {
+		t.Errorf("Expected notify to be reported as leaked, got %v", health.Leaked)
+	}
+	if health.ActiveStates != len(machine.GetActiveStates()) {
+		t.Errorf("Expected %d active states, got %d", len(machine.GetActiveStates()), health.ActiveStates)
+	}
+}
+
+func TestHealth_ParallelRegionsAreNotLeaked(t *testing.T) {
+	builder := NewMachine()
+	builder.State("start").Initial().
+		To("work").On("begin")
+	parallel := builder.ParallelState("work")
+	parallel.Region("a").State("idle").Initial()
+	parallel.Region("b").State("idle").Initial()
+
+	machine := builder.Build().CreateInstance()
+	_ = machine.Start()
+	machine.HandleEvent("begin", nil)
+
+	if health := machine.Health(); len(health.Leaked) != 0 {
+		t.Errorf("Expected no leaked states, got %v", health.Leaked)
+	}
+}
+
+func TestMaxActiveStates_AlertsOncePerCrossing(t *testing.T) {
+	machine := buildLeakyForkMachine().CreateInstance(WithMaxActiveStates(2))
+	observer := NewTestObserver()
+	machine.AddObserver(observer)
+	_ = machine.Start()
+
+	machine.HandleEvent("split", nil)
+	if len(observer.LimitAlerts) != 1 {
+		t.Fatalf("Expected one alert after the fork, got %d", len(observer.LimitAlerts))
+	}
+	if alert := observer.LimitAlerts[0]; alert.Limit != 2 || len(alert.Active) != 3 {
+		t.Errorf("Unexpected alert %+v", alert)
+	}
+	if !machine.Health().LimitExceeded {
+		t.Error("Expected Health to report the exceeded limit")
+	}
+
+	machine.HandleEvent("abort", nil)
+	machine.HandleEvent("retry", nil)
+	if len(observer.LimitAlerts) != 1 {
+		t.Errorf("Expected no repeated alert while within the limit, got %d", len(observer.LimitAlerts))
+	}
+
+	machine.HandleEvent("split", nil)
+	if len(observer.LimitAlerts) != 2 {
+		t.Errorf("Expected a new alert after crossing the limit again, got %d", len(observer.LimitAlerts))
+	}
+}
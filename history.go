@@ -0,0 +1,110 @@
+package fluo
+
+import (
+	"maps"
+	"slices"
+	"time"
+)
+
+// ClearHistory forgets the history recorded for a state and the states nested
+// in it, so the next entry through a history pseudostate starts fresh. For a
+// parallel state the recorded configuration of every region is forgotten.
+func (sm *StateMachine) ClearHistory(stateID string) error {
+	sm.mutex.Lock()
+	defer sm.unlock()
+
+	if _, exists := sm.states[stateID]; !exists {
+		return NewStateNotFoundError(stateID)
+	}
+	sm.clearHistory(stateID)
+	return nil
+}
+
+// clearHistory forgets the history of a state, its regions and its descendants
+func (sm *StateMachine) clearHistory(stateID string) {
+	within := func(key string) bool {
+		return StatePath(stateID).Contains(StatePath(key))
+	}
+	for key := range sm.stateHistory {
+		if within(key) {
+			delete(sm.stateHistory, key)
+		}
+	}
+	for key := range sm.historyTimes {
+		if within(key) {
+			delete(sm.historyTimes, key)
+		}
+	}
+	for key := range sm.historyExits {
+		if within(key) {
+			delete(sm.historyExits, key)
+		}
+	}
+}
+
+// expireHistory forgets the history of a state when the retention of the
+// history pseudostate entering it has run out
+func (sm *StateMachine) expireHistory(pseudoState *PseudoStateImpl, stateID string) {
+	if stateID != "" && sm.historyExpired(pseudoState, stateID) {
+		sm.clearHistory(stateID)
+	}
+}
+
+// historyExpired reports whether the retention of a history pseudostate has
+// run out for the history recorded for a state
+func (sm *StateMachine) historyExpired(pseudoState *PseudoStateImpl, stateID string) bool {
+	if limit := pseudoState.historyExitLimit; limit > 0 && sm.historyExits[stateID] > limit {
+		return true
+	}
+
+	if ttl := pseudoState.historyTTL; ttl > 0 {
+		for key, recorded := range sm.historyTimes {
+			if StatePath(stateID).Contains(StatePath(key)) && time.Since(recorded) > ttl {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HistoryOf returns the state the history pseudostate of a composite state
+// restores on its next entry: the direct substate last active for shallow
+// history, or the innermost state last active when the composite only has
+// deep history. ok is false when no history is recorded or the retention of
+// the history pseudostate has run out.
+func (sm *StateMachine) HistoryOf(compositeID string) (stateID string, ok bool) {
+	sm.mutex.RLock()
+	defer sm.mutex.RUnlock()
+
+	recorded := sm.stateHistory[compositeID]
+	if recorded == "" {
+		return "", false
+	}
+	history := sm.historyPseudoStateOf(compositeID)
+	if history != nil && sm.historyExpired(history, compositeID) {
+		return "", false
+	}
+	if history != nil && history.Kind() == DeepHistory {
+		return recorded, true
+	}
+	return sm.immediateSubstate(compositeID, recorded), true
+}
+
+// historyPseudoStateOf returns the history pseudostate of a composite state,
+// preferring shallow history when it has both
+func (sm *StateMachine) historyPseudoStateOf(compositeID string) *PseudoStateImpl {
+	var found *PseudoStateImpl
+	for _, stateID := range slices.Sorted(maps.Keys(sm.states)) {
+		pseudoState, ok := sm.states[stateID].(*PseudoStateImpl)
+		if !ok || sm.getHistoryParentID(pseudoState) != compositeID {
+			continue
+		}
+		switch pseudoState.Kind() {
+		case History:
+			return pseudoState
+		case DeepHistory:
+			found = pseudoState
+		}
+	}
+	return found
+}
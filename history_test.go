@@ -0,0 +1,168 @@
+package fluo
+
+import (
+	"testing"
+	"time"
+)
+
+// buildRetainedHistoryMachine builds a parallel state with one region whose
+// history pseudostate is configured by retain
+func buildRetainedHistoryMachine(retain func(HistoryBuilder)) Machine {
+	builder := NewMachine()
+	builder.State("off").Initial().
+		To("on").On("power_on").
+		To("on.history").On("resume")
+
+	on := builder.ParallelState("on")
+	retain(on.DeepHistory("history"))
+
+	security := on.Region("security")
+	security.State("disarmed").Initial().
+		To("on.security.armed").On("arm")
+	security.State("armed")
+
+	builder.ParallelState("on").
+		To("off").On("power_off")
+
+	return builder.Build().CreateInstance()
+}
+
+// armAndLeave arms the security region and leaves the parallel state
+func armAndLeave(t *testing.T, machine Machine) {
+	t.Helper()
+	for _, event := range []string{"power_on", "arm", "power_off"} {
+		AssertEventProcessed(t, machine.HandleEvent(event, nil), true)
+	}
+}
+
+func TestClearHistory(t *testing.T) {
+	machine := buildRetainedHistoryMachine(func(HistoryBuilder) {})
+	_ = machine.Start()
+	armAndLeave(t, machine)
+
+	if err := machine.ClearHistory("on"); err != nil {
+		t.Fatalf("Expected history to be cleared, got %v", err)
+	}
+	AssertEventProcessed(t, machine.HandleEvent("resume", nil), true)
+	if !machine.IsStateActive("on.security.disarmed") {
+		t.Errorf("Expected a fresh start after ClearHistory, got %v", machine.GetActiveStates())
+	}
+
+	if err := machine.ClearHistory("missing"); err == nil {
+		t.Error("Expected an error for an unknown state")
+	}
+}
+
+func TestHistory_ForgetAfter(t *testing.T) {
+	machine := buildRetainedHistoryMachine(func(history HistoryBuilder) {
+		history.ForgetAfter(1)
+	})
+	_ = machine.Start()
+	armAndLeave(t, machine)
+
+	AssertEventProcessed(t, machine.HandleEvent("resume", nil), true)
+	if !machine.IsStateActive("on.security.armed") {
+		t.Fatalf("Expected the first resume to restore history, got %v", machine.GetActiveStates())
+	}
+
+	AssertEventProcessed(t, machine.HandleEvent("power_off", nil), true)
+	AssertEventProcessed(t, machine.HandleEvent("resume", nil), true)
+	if !machine.IsStateActive("on.security.disarmed") {
+		t.Errorf("Expected history to be forgotten after one restore, got %v", machine.GetActiveStates())
+	}
+}
+
+func TestHistory_ForgetAfterCountsExits(t *testing.T) {
+	machine := buildRetainedHistoryMachine(func(history HistoryBuilder) {
+		history.ForgetAfter(2)
+	})
+	_ = machine.Start()
+	armAndLeave(t, machine)
+	armAndLeave(t, machine)
+
+	AssertEventProcessed(t, machine.HandleEvent("resume", nil), true)
+	if !machine.IsStateActive("on.security.armed") {
+		t.Fatalf("Expected history to be kept for two exits, got %v", machine.GetActiveStates())
+	}
+
+	AssertEventProcessed(t, machine.HandleEvent("power_off", nil), true)
+	armAndLeave(t, machine)
+	AssertEventProcessed(t, machine.HandleEvent("resume", nil), true)
+	if !machine.IsStateActive("on.security.disarmed") {
+		t.Errorf("Expected history to be forgotten after exits without restores, got %v", machine.GetActiveStates())
+	}
+}
+
+func TestHistory_ExpireAfter(t *testing.T) {
+	machine := buildRetainedHistoryMachine(func(history HistoryBuilder) {
+		history.ExpireAfter(20 * time.Millisecond)
+	})
+	_ = machine.Start()
+	armAndLeave(t, machine)
+
+	AssertEventProcessed(t, machine.HandleEvent("resume", nil), true)
+	if !machine.IsStateActive("on.security.armed") {
+		t.Fatalf("Expected fresh history to be restored, got %v", machine.GetActiveStates())
+	}
+
+	AssertEventProcessed(t, machine.HandleEvent("power_off", nil), true)
+	time.Sleep(40 * time.Millisecond)
+	AssertEventProcessed(t, machine.HandleEvent("resume", nil), true)
+	if !machine.IsStateActive("on.security.disarmed") {
+		t.Errorf("Expected expired history to be ignored, got %v", machine.GetActiveStates())
+	}
+}
+
+// buildNestedHistoryMachine builds a composite with a nested composite whose
+// history pseudostate is shallow or deep
+func buildNestedHistoryMachine(deep bool) Machine {
+	builder := NewMachine()
+	builder.State("idle").Initial().
+		To("outer.history").On("resume")
+	builder.Composite("outer", func(c CompositeScope) {
+		c.Composite("inner", func(i CompositeScope) {
+			i.State("a").Initial().
+				To("b").On("next")
+			i.State("b").
+				To("idle").On("leave")
+		})
+		c.State("other")
+		if deep {
+			c.DeepHistory("history").Default("outer.inner")
+		} else {
+			c.History("history").Default("outer.inner")
+		}
+	})
+	return builder.Build().CreateInstance()
+}
+
+func TestHistoryOf(t *testing.T) {
+	tests := []struct {
+		name     string
+		deep     bool
+		recorded string
+		resumed  string
+	}{
+		{"shallow", false, "outer.inner", "outer.inner.a"},
+		{"deep", true, "outer.inner.b", "outer.inner.b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			machine := buildNestedHistoryMachine(tt.deep)
+			_ = machine.Start()
+			if _, ok := machine.HistoryOf("outer"); ok {
+				t.Error("Expected no history before the composite was left")
+			}
+
+			for _, event := range []string{"resume", "next", "leave"} {
+				AssertEventProcessed(t, machine.HandleEvent(event, nil), true)
+			}
+			if stateID, ok := machine.HistoryOf("outer"); !ok || stateID != tt.recorded {
+				t.Errorf("Expected history %s, got %q (%v)", tt.recorded, stateID, ok)
+			}
+
+			machine.HandleEvent("resume", nil)
+			AssertState(t, machine, tt.resumed)
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package fluo
+
+import "fmt"
+
+// BeforeTransitionFunc inspects a transition once it has been selected, after
+// its guards passed and before any action runs. Returning an error vetoes the
+// transition: the event is rejected with RejectionVetoed and the machine stays
+// where it was. Internal transitions are checked with from and to both set to
+// the source state. The target is the state the transition settles in, past
+// any junction or choice it goes through. A panicking hook vetoes the
+// transition and is handled by the panic policy like a panicking guard.
+type BeforeTransitionFunc func(from, to string, event Event, ctx Context) error
+
+// WithBeforeTransition adds a hook vetoing transitions for machine-wide
+// policies such as maintenance windows or feature flags, independent of the
+// guards declared on each transition. Hooks run in the order they were added
+// and the first veto wins.
+func WithBeforeTransition(hook BeforeTransitionFunc) MachineOption {
+	return func(sm *StateMachine) {
+		sm.beforeTransition = append(sm.beforeTransition, hook)
+	}
+}
+
+// vetoedResult runs the before-transition hooks for a selected transition
+// leading to target and rejects the event when one of them vetoes it,
+// returning nil otherwise
+func (sm *StateMachine) vetoedResult(sourceStateID, target string, transition *Transition, event Event) *EventResult {
+	if len(sm.beforeTransition) == 0 {
+		return nil
+	}
+	to := target
+	if transition.Internal {
+		to = sourceStateID
+	} else if settled, err := sm.probeTarget(target); err == nil {
+		to = settled
+	}
+	for _, hook := range sm.beforeTransition {
+		err := safeBeforeTransition(hook, sourceStateID, to, event, sm.context)
+		if err == nil {
+			continue
+		}
+		sm.recoveredPanic(err)
+		veto := NewVetoError(sourceStateID, to, event.GetName(), err)
+		reason := fmt.Sprintf("transition vetoed: %v", err)
+		sm.observers.NotifyEventRejected(event, reason, sm.context)
+		return NewEventResult(false, false, sm.currentState, sm.currentState).
+			WithRejection(reason).
+			WithRejectionCode(RejectionVetoed).
+			WithError(veto)
+	}
+	return nil
+}
+
+// safeBeforeTransition runs a before-transition hook with panic recovery
+func safeBeforeTransition(hook BeforeTransitionFunc, from, to string, event Event, ctx Context) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = newPanicError("hook", r, ctx)
+		}
+	}()
+
+	return hook(from, to, event, ctx)
+}
@@ -0,0 +1,152 @@
+package fluo
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestBeforeTransition_Veto(t *testing.T) {
+	maintenance := errors.New("maintenance window")
+	var calls []string
+	machine := buildToggleMachine(WithBeforeTransition(func(from, to string, event Event, ctx Context) error {
+		calls = append(calls, from+"->"+to)
+		if ctx.GetCurrentEvent().GetName() != event.GetName() {
+			t.Errorf("Expected the context to carry the event, got %v", ctx.GetCurrentEvent())
+		}
+		if to == "on" {
+			return maintenance
+		}
+		return nil
+	}))
+	observer := NewTestObserver()
+	machine.AddObserver(observer)
+	_ = machine.Start()
+
+	result := machine.HandleEvent("toggle", nil)
+	AssertEventProcessed(t, result, false)
+	AssertState(t, machine, "off")
+	if result.RejectionCode != RejectionVetoed {
+		t.Errorf("Expected RejectionVetoed, got %v", result.RejectionCode)
+	}
+	if !IsVetoError(result.Error) || !errors.Is(result.Error, maintenance) {
+		t.Errorf("Expected a VetoError wrapping the hook error, got %v", result.Error)
+	}
+	if GetErrorCode(result.Error) != ErrCodeTransitionVetoed {
+		t.Errorf("Expected ErrCodeTransitionVetoed, got %v", GetErrorCode(result.Error))
+	}
+	if len(observer.Transitions) != 0 || len(observer.EventRejects) != 1 {
+		t.Errorf("Expected only a rejection to be observed, got %d transitions and %d rejections",
+			len(observer.Transitions), len(observer.EventRejects))
+	}
+	if len(calls) != 1 || calls[0] != "off->on" {
+		t.Errorf("Expected the hook to see off->on, got %v", calls)
+	}
+}
+
+func TestBeforeTransition_RunsAfterGuards(t *testing.T) {
+	hooked := 0
+	builder := NewMachine()
+	builder.State("idle").Initial().
+		To("busy").On("go").When(func(ctx Context) bool { return false })
+	builder.State("busy")
+	machine := builder.Build().CreateInstance(WithBeforeTransition(func(from, to string, event Event, ctx Context) error {
+		hooked++
+		return nil
+	}))
+	_ = machine.Start()
+
+	result := machine.HandleEvent("go", nil)
+	if result.RejectionCode != RejectionGuardFailed {
+		t.Errorf("Expected RejectionGuardFailed, got %v", result.RejectionCode)
+	}
+	if hooked != 0 {
+		t.Errorf("Expected the hook to run only for selected transitions, ran %d times", hooked)
+	}
+}
+
+func TestBeforeTransition_FirstVetoWins(t *testing.T) {
+	var order []int
+	hook := func(n int, err error) BeforeTransitionFunc {
+		return func(from, to string, event Event, ctx Context) error {
+			order = append(order, n)
+			return err
+		}
+	}
+	machine := buildToggleMachine(
+		WithBeforeTransition(hook(1, nil)),
+		WithBeforeTransition(hook(2, errors.New("denied"))),
+		WithBeforeTransition(hook(3, nil)),
+	)
+	_ = machine.Start()
+
+	AssertEventProcessed(t, machine.HandleEvent("toggle", nil), false)
+	if len(order) != 2 || order[0] != 1 || order[1] != 2 {
+		t.Errorf("Expected hooks 1 and 2 to run, got %v", order)
+	}
+}
+
+func TestBeforeTransition_PanicVetoes(t *testing.T) {
+	observer := &panicRecorder{}
+	machine := buildToggleMachine(WithBeforeTransition(func(from, to string, event Event, ctx Context) error {
+		panic("hook boom")
+	}))
+	machine.AddObserver(observer)
+	_ = machine.Start()
+
+	result := machine.HandleEvent("toggle", nil)
+	AssertEventProcessed(t, result, false)
+	AssertState(t, machine, "off")
+	if result.RejectionCode != RejectionVetoed {
+		t.Errorf("Expected RejectionVetoed, got %v", result.RejectionCode)
+	}
+	var panicErr *PanicError
+	if !errors.As(result.Error, &panicErr) || panicErr.Kind != "hook" {
+		t.Errorf("Expected a veto wrapping the hook panic, got %v", result.Error)
+	}
+	if len(observer.panics) != 1 {
+		t.Errorf("Expected observers to see the panic, got %d", len(observer.panics))
+	}
+
+	propagating := buildToggleMachine(WithPanicPolicy(PanicPropagate), WithBeforeTransition(func(from, to string, event Event, ctx Context) error {
+		panic("hook boom")
+	}))
+	_ = propagating.Start()
+	func() {
+		defer func() {
+			if recovered, ok := recover().(*PanicError); !ok || recovered.Kind != "hook" {
+				t.Errorf("Expected the hook panic to propagate, got %v", recovered)
+			}
+		}()
+		propagating.HandleEvent("toggle", nil)
+	}()
+	AssertState(t, propagating, "off")
+}
+
+func TestBeforeTransition_SeesSettledTarget(t *testing.T) {
+	var seen []string
+	builder := NewMachine()
+	builder.State("idle").Initial().
+		To("route").On("go").
+		To("decide").On("pick")
+	builder.Junction("route").
+		To("routed")
+	builder.Choice("decide").
+		When(func(ctx Context) bool { return true }).To("picked").
+		Otherwise("idle")
+	builder.State("routed")
+	builder.State("picked")
+	machine := builder.Build().CreateInstance(WithBeforeTransition(func(from, to string, event Event, ctx Context) error {
+		seen = append(seen, to)
+		if ctx.GetTargetState() == to {
+			t.Errorf("Expected the transition info to be updated only once the hooks allowed it")
+		}
+		return errors.New("denied")
+	}))
+	_ = machine.Start()
+
+	machine.HandleEvent("go", nil)
+	machine.HandleEvent("pick", nil)
+	if len(seen) != 2 || seen[0] != "routed" || seen[1] != "picked" {
+		t.Errorf("Expected the hooks to see the settled targets, got %v", seen)
+	}
+}
@@ -0,0 +1,326 @@
+// Package httpserver exposes state machine definitions over HTTP so services
+// written in other languages can create instances, send them events and
+// inspect their state.
+//
+// Every registered definition is served under /machines/{machine}:
+//
+//	GET    /machines                                   list registered machines
+//	GET    /machines/{machine}/diagram?format=mermaid  diagram (mermaid, plantuml or scxml)
+//	GET    /machines/{machine}/instances               list tracked instance IDs
+//	POST   /machines/{machine}/instances               create and start an instance
+//	GET    /machines/{machine}/instances/{id}          current and active states
+//	POST   /machines/{machine}/instances/{id}/events   send an event
+//	DELETE /machines/{machine}/instances/{id}          delete an instance
+package httpserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+
+	"github.com/anggasct/fluo"
+)
+
+// Server is an http.Handler serving registered machine definitions
+type Server struct {
+	machines map[string]*machine
+	mux      *http.ServeMux
+	mutex    sync.RWMutex
+}
+
+// machine is a registered definition and the manager of its instances
+type machine struct {
+	definition fluo.MachineDefinition
+	manager    *fluo.Manager
+}
+
+// New creates a server with no registered machines
+func New() *Server {
+	s := &Server{
+		machines: make(map[string]*machine),
+		mux:      http.NewServeMux(),
+	}
+
+	s.mux.HandleFunc("GET /machines", s.listMachines)
+	s.mux.HandleFunc("GET /machines/{machine}/diagram", s.diagram)
+	s.mux.HandleFunc("GET /machines/{machine}/instances", s.listInstances)
+	s.mux.HandleFunc("POST /machines/{machine}/instances", s.createInstance)
+	s.mux.HandleFunc("GET /machines/{machine}/instances/{id}", s.getInstance)
+	s.mux.HandleFunc("POST /machines/{machine}/instances/{id}/events", s.sendEvent)
+	s.mux.HandleFunc("DELETE /machines/{machine}/instances/{id}", s.deleteInstance)
+	return s
+}
+
+// Register serves a definition under the given name. Its instances are
+// managed by a fluo.Manager created with opts, which is returned so the
+// caller can work with the same instances directly.
+func (s *Server) Register(name string, definition fluo.MachineDefinition, opts ...fluo.ManagerOption) *fluo.Manager {
+	manager := fluo.NewManager(definition, opts...)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.machines[name] = &machine{definition: definition, manager: manager}
+	return manager
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// CreateRequest is the body of an instance creation request
+type CreateRequest struct {
+	// ID of the instance; a random ID is generated when empty
+	ID string `json:"id,omitempty"`
+	// Context values set before the instance is started
+	Context map[string]any `json:"context,omitempty"`
+}
+
+// EventRequest is the body of an event request
+type EventRequest struct {
+	Event string `json:"event"`
+	Data  any    `json:"data,omitempty"`
+}
+
+// InstanceResponse describes an instance
+type InstanceResponse struct {
+	ID           string   `json:"id"`
+	CurrentState string   `json:"current_state"`
+	ActiveStates []string `json:"active_states"`
+}
+
+// EventResponse describes the outcome of an event
+type EventResponse struct {
+	Processed       bool     `json:"processed"`
+	StateChanged    bool     `json:"state_changed"`
+	PreviousState   string   `json:"previous_state"`
+	CurrentState    string   `json:"current_state"`
+	ActiveStates    []string `json:"active_states,omitempty"`
+	RejectionReason string   `json:"rejection_reason,omitempty"`
+	RejectionCode   string   `json:"rejection_code,omitempty"`
+	Error           string   `json:"error,omitempty"`
+}
+
+// ErrorResponse is the body of a failed request
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+func (s *Server) listMachines(w http.ResponseWriter, r *http.Request) {
+	s.mutex.RLock()
+	names := make([]string, 0, len(s.machines))
+	for name := range s.machines {
+		names = append(names, name)
+	}
+	s.mutex.RUnlock()
+
+	slices.Sort(names)
+	writeJSON(w, http.StatusOK, map[string][]string{"machines": names})
+}
+
+func (s *Server) diagram(w http.ResponseWriter, r *http.Request) {
+	m, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "mermaid":
+		writeText(w, "text/plain; charset=utf-8", []byte(fluo.ExportMermaid(m.definition)))
+	case "plantuml":
+		writeText(w, "text/plain; charset=utf-8", []byte(fluo.ExportPlantUML(m.definition)))
+	case "scxml":
+		data, err := fluo.ExportSCXML(m.definition)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeText(w, "application/scxml+xml", data)
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Errorf("unknown diagram format '%s'", format))
+	}
+}
+
+func (s *Server) listInstances(w http.ResponseWriter, r *http.Request) {
+	m, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string][]string{"instances": m.manager.IDs()})
+}
+
+func (s *Server) createInstance(w http.ResponseWriter, r *http.Request) {
+	m, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+
+	var request CreateRequest
+	if !readJSON(w, r, &request) {
+		return
+	}
+
+	instance, err := m.manager.Create(request.ID)
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	for key, value := range request.Context {
+		instance.Context().Set(key, value)
+	}
+	if err := instance.Start(); err != nil {
+		_ = m.manager.Delete(instance.ID())
+		writeError(w, statusOf(err), err)
+		return
+	}
+	if err := m.manager.Save(instance.ID()); err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, describe(instance))
+}
+
+func (s *Server) getInstance(w http.ResponseWriter, r *http.Request) {
+	m, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+
+	instance, err := m.manager.Load(r.PathValue("id"))
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, describe(instance))
+}
+
+func (s *Server) sendEvent(w http.ResponseWriter, r *http.Request) {
+	m, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+
+	var request EventRequest
+	if !readJSON(w, r, &request) {
+		return
+	}
+	if request.Event == "" {
+		writeError(w, http.StatusBadRequest, errors.New("event name is required"))
+		return
+	}
+
+	id := r.PathValue("id")
+	result := m.manager.SendEventWithContext(r.Context(), id, request.Event, request.Data)
+	response := EventResponse{
+		Processed:       result.Processed,
+		StateChanged:    result.StateChanged,
+		PreviousState:   result.PreviousState,
+		CurrentState:    result.CurrentState,
+		RejectionReason: result.RejectionReason,
+	}
+	if result.RejectionCode != fluo.RejectionNone {
+		response.RejectionCode = result.RejectionCode.String()
+	}
+	if result.Error != nil {
+		response.Error = result.Error.Error()
+	}
+
+	status := http.StatusOK
+	switch {
+	case fluo.GetErrorCode(result.Error) == fluo.ErrCodeInstanceNotFound:
+		status = http.StatusNotFound
+	case !result.Processed:
+		status = http.StatusUnprocessableEntity
+	}
+	if instance, ok := m.manager.Get(id); ok {
+		response.ActiveStates = activeStates(instance)
+	}
+	writeJSON(w, status, response)
+}
+
+func (s *Server) deleteInstance(w http.ResponseWriter, r *http.Request) {
+	m, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+
+	if err := m.manager.Delete(r.PathValue("id")); err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// lookup finds the machine named in the request path, writing a 404 if it is not registered
+func (s *Server) lookup(w http.ResponseWriter, r *http.Request) (*machine, bool) {
+	name := r.PathValue("machine")
+
+	s.mutex.RLock()
+	m, ok := s.machines[name]
+	s.mutex.RUnlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("machine '%s' is not registered", name))
+	}
+	return m, ok
+}
+
+// describe renders an instance's state
+func describe(instance fluo.Machine) InstanceResponse {
+	return InstanceResponse{
+		ID:           instance.ID(),
+		CurrentState: instance.CurrentState(),
+		ActiveStates: activeStates(instance),
+	}
+}
+
+// activeStates returns an instance's active states in sorted order
+func activeStates(instance fluo.Machine) []string {
+	states := instance.GetActiveStates()
+	slices.Sort(states)
+	return states
+}
+
+// statusOf maps a fluo error to an HTTP status
+func statusOf(err error) int {
+	switch fluo.GetErrorCode(err) {
+	case fluo.ErrCodeInstanceNotFound:
+		return http.StatusNotFound
+	case fluo.ErrCodeInstanceExists:
+		return http.StatusConflict
+	case fluo.ErrCodeInvalidConfiguration, fluo.ErrCodeStateNotFound:
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// readJSON decodes a request body, writing a 400 if it is malformed. An empty body is accepted.
+func readJSON(w http.ResponseWriter, r *http.Request, target any) bool {
+	if err := json.NewDecoder(r.Body).Decode(target); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func writeText(w http.ResponseWriter, contentType string, body []byte) {
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, ErrorResponse{Error: err.Error()})
+}
@@ -0,0 +1,132 @@
+package httpserver_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/anggasct/fluo"
+	"github.com/anggasct/fluo/httpserver"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	definition := fluo.NewMachine().
+		State("draft").Initial().
+		To("review").On("submit").
+		State("review").
+		To("approved").On("approve").
+		State("approved").
+		Build()
+
+	server := httpserver.New()
+	server.Register("document", definition)
+
+	ts := httptest.NewServer(server)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func do(t *testing.T, method, url string, body any, target any) int {
+	t.Helper()
+
+	var reader bytes.Buffer
+	if body != nil {
+		_ = json.NewEncoder(&reader).Encode(body)
+	}
+	request, _ := http.NewRequest(method, url, &reader)
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer response.Body.Close()
+
+	if target != nil {
+		_ = json.NewDecoder(response.Body).Decode(target)
+	}
+	return response.StatusCode
+}
+
+func TestServer_InstanceLifecycle(t *testing.T) {
+	ts := newTestServer(t)
+	base := ts.URL + "/machines/document/instances"
+
+	var created httpserver.InstanceResponse
+	status := do(t, http.MethodPost, base, httpserver.CreateRequest{ID: "doc-1", Context: map[string]any{"owner": "alice"}}, &created)
+	if status != http.StatusCreated || created.ID != "doc-1" || created.CurrentState != "draft" {
+		t.Fatalf("Unexpected create response %d: %+v", status, created)
+	}
+	if status := do(t, http.MethodPost, base, httpserver.CreateRequest{ID: "doc-1"}, nil); status != http.StatusConflict {
+		t.Errorf("Expected a duplicate ID to conflict, got %d", status)
+	}
+
+	var result httpserver.EventResponse
+	status = do(t, http.MethodPost, base+"/doc-1/events", httpserver.EventRequest{Event: "submit"}, &result)
+	if status != http.StatusOK || !result.Processed || result.CurrentState != "review" {
+		t.Errorf("Unexpected event response %d: %+v", status, result)
+	}
+
+	status = do(t, http.MethodPost, base+"/doc-1/events", httpserver.EventRequest{Event: "submit"}, &result)
+	if status != http.StatusUnprocessableEntity || result.Processed || result.RejectionReason == "" || result.RejectionCode != "NoTransition" {
+		t.Errorf("Expected a rejected event, got %d: %+v", status, result)
+	}
+
+	var instance httpserver.InstanceResponse
+	if status := do(t, http.MethodGet, base+"/doc-1", nil, &instance); status != http.StatusOK || instance.CurrentState != "review" {
+		t.Errorf("Unexpected instance response %d: %+v", status, instance)
+	}
+
+	var list map[string][]string
+	do(t, http.MethodGet, base, nil, &list)
+	if len(list["instances"]) != 1 || list["instances"][0] != "doc-1" {
+		t.Errorf("Unexpected instance list: %+v", list)
+	}
+
+	if status := do(t, http.MethodDelete, base+"/doc-1", nil, nil); status != http.StatusNoContent {
+		t.Errorf("Expected delete to succeed, got %d", status)
+	}
+	if status := do(t, http.MethodGet, base+"/doc-1", nil, nil); status != http.StatusNotFound {
+		t.Errorf("Expected a deleted instance to be gone, got %d", status)
+	}
+	if status := do(t, http.MethodPost, base+"/doc-1/events", httpserver.EventRequest{Event: "submit"}, nil); status != http.StatusNotFound {
+		t.Errorf("Expected an event for a missing instance to 404, got %d", status)
+	}
+}
+
+func TestServer_Machines(t *testing.T) {
+	ts := newTestServer(t)
+
+	var list map[string][]string
+	do(t, http.MethodGet, ts.URL+"/machines", nil, &list)
+	if len(list["machines"]) != 1 || list["machines"][0] != "document" {
+		t.Errorf("Unexpected machine list: %+v", list)
+	}
+
+	if status := do(t, http.MethodGet, ts.URL+"/machines/unknown/instances", nil, nil); status != http.StatusNotFound {
+		t.Errorf("Expected an unknown machine to 404, got %d", status)
+	}
+}
+
+func TestServer_Diagram(t *testing.T) {
+	ts := newTestServer(t)
+
+	response, err := http.Get(ts.URL + "/machines/document/diagram?format=plantuml")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer response.Body.Close()
+
+	var body bytes.Buffer
+	_, _ = body.ReadFrom(response.Body)
+	if response.StatusCode != http.StatusOK || !strings.Contains(body.String(), "@startuml") {
+		t.Errorf("Unexpected diagram response %d: %s", response.StatusCode, body.String())
+	}
+
+	if status := do(t, http.MethodGet, ts.URL+"/machines/document/diagram?format=png", nil, nil); status != http.StatusBadRequest {
+		t.Errorf("Expected an unknown format to be rejected, got %d", status)
+	}
+}
@@ -0,0 +1,89 @@
+package fluo
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"maps"
+)
+
+// WithInstanceID sets the correlation ID of the machine instance
+func WithInstanceID(id string) MachineOption {
+	return func(sm *StateMachine) {
+		sm.id = id
+	}
+}
+
+// WithMetadata adds metadata labels to the machine instance
+func WithMetadata(metadata map[string]string) MachineOption {
+	return func(sm *StateMachine) {
+		if sm.metadata == nil {
+			sm.metadata = make(map[string]string, len(metadata))
+		}
+		maps.Copy(sm.metadata, metadata)
+	}
+}
+
+// newInstanceID returns a random instance ID
+func newInstanceID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// ID returns the correlation ID of the machine instance
+func (sm *StateMachine) ID() string {
+	sm.mutex.RLock()
+	defer sm.mutex.RUnlock()
+	return sm.id
+}
+
+// Metadata returns a copy of the instance's metadata labels
+func (sm *StateMachine) Metadata() map[string]string {
+	sm.mutex.RLock()
+	defer sm.mutex.RUnlock()
+	return maps.Clone(sm.metadata)
+}
+
+// SetMetadata sets a metadata label on the instance
+func (sm *StateMachine) SetMetadata(key, value string) {
+	sm.mutex.Lock()
+	defer sm.unlock()
+	sm.labelMutex.Lock()
+	defer sm.labelMutex.Unlock()
+	if sm.metadata == nil {
+		sm.metadata = make(map[string]string)
+	}
+	sm.metadata[key] = value
+}
+
+// InstanceID returns the ID of the machine instance a context belongs to, or
+// "" when the context is not attached to a machine. Unlike Machine.ID it does
+// not lock the machine, so observers and actions may call it.
+func InstanceID(ctx Context) string {
+	if sm := contextMachine(ctx); sm != nil {
+		sm.labelMutex.RLock()
+		defer sm.labelMutex.RUnlock()
+		return sm.id
+	}
+	return ""
+}
+
+// InstanceMetadata returns a copy of the metadata of the machine instance a
+// context belongs to. Like InstanceID it is safe to call from observers and actions.
+func InstanceMetadata(ctx Context) map[string]string {
+	if sm := contextMachine(ctx); sm != nil {
+		sm.labelMutex.RLock()
+		defer sm.labelMutex.RUnlock()
+		return maps.Clone(sm.metadata)
+	}
+	return nil
+}
+
+// contextMachine returns the machine a context belongs to, if any
+func contextMachine(ctx Context) *StateMachine {
+	if ctx == nil {
+		return nil
+	}
+	sm, _ := ctx.GetMachine().(*StateMachine)
+	return sm
+}
@@ -0,0 +1,109 @@
+package fluo
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+)
+
+func TestInstanceID_Generated(t *testing.T) {
+	first := NewMachine().State("idle").Initial().Build().CreateInstance()
+	second := NewMachine().State("idle").Initial().Build().CreateInstance()
+	if first.ID() == "" || first.ID() == second.ID() {
+		t.Errorf("Expected distinct generated IDs, got %q and %q", first.ID(), second.ID())
+	}
+}
+
+func TestCreateInstanceWithID(t *testing.T) {
+	definition := NewMachine().
+		State("idle").Initial().
+		To("busy").On("start").
+		State("busy").
+		Build()
+
+	var seen string
+	observer := &instanceObserver{onTransition: func(ctx Context) {
+		seen = InstanceID(ctx) + "/" + InstanceMetadata(ctx)["tenant"]
+	}}
+	machine := definition.CreateInstanceWithID("order-42", WithMetadata(map[string]string{"tenant": "acme"}))
+	machine.AddObserver(observer)
+	_ = machine.Start()
+	machine.HandleEvent("start", nil)
+
+	if machine.ID() != "order-42" {
+		t.Errorf("Expected ID order-42, got %q", machine.ID())
+	}
+	if seen != "order-42/acme" {
+		t.Errorf("Expected identity in observer callbacks, got %q", seen)
+	}
+}
+
+func TestInstanceIdentity_Snapshot(t *testing.T) {
+	definition := NewMachine().State("idle").Initial().Build()
+	machine := definition.CreateInstanceWithID("order-7")
+	machine.SetMetadata("region", "eu")
+	_ = machine.Start()
+
+	for _, codec := range []Codec{JSONCodec{}, MsgpackCodec{}, ProtobufCodec{}} {
+		data, err := machine.MarshalSnapshot(codec)
+		if err != nil {
+			t.Fatalf("%s: marshal failed: %v", codec.Name(), err)
+		}
+		restored := definition.CreateInstance()
+		if err := restored.UnmarshalSnapshot(codec, data); err != nil {
+			t.Fatalf("%s: unmarshal failed: %v", codec.Name(), err)
+		}
+		if restored.ID() != "order-7" || restored.Metadata()["region"] != "eu" {
+			t.Errorf("%s: expected identity to round-trip, got %q %v", codec.Name(), restored.ID(), restored.Metadata())
+		}
+	}
+}
+
+func TestLoggingObserver_InstanceFields(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	machine := NewMachine().State("idle").Initial().Build().
+		CreateInstanceWithID("order-9", WithMetadata(map[string]string{"tenant": "acme"}))
+	machine.AddObserver(NewLoggingObserver(WithLogger(logger)))
+	_ = machine.Start()
+
+	for _, record := range decodeLogRecords(t, &buf) {
+		metadata, _ := record["metadata"].(map[string]any)
+		if record["instance"] != "order-9" || metadata["tenant"] != "acme" {
+			t.Errorf("Expected instance fields on every record, got %v", record)
+		}
+	}
+}
+
+type instanceObserver struct {
+	BaseObserver
+	onTransition func(ctx Context)
+}
+
+func (o *instanceObserver) OnTransition(from string, to string, event Event, ctx Context) {
+	o.onTransition(ctx)
+}
+
+// metadataReader reads the instance metadata on every transition
+type metadataReader struct {
+	BaseObserver
+}
+
+func (*metadataReader) OnTransition(from string, to string, event Event, ctx Context) {
+	_ = InstanceMetadata(ctx)
+	_ = InstanceID(ctx)
+}
+
+func TestInstanceMetadata_AsyncObservers(t *testing.T) {
+	machine := buildToggleMachine(WithAsyncObservers(16))
+	machine.AddObserver(&metadataReader{})
+	_ = machine.Start()
+	defer machine.Observers().Close()
+
+	for range 50 {
+		machine.HandleEvent("toggle", nil)
+		machine.SetMetadata("toggled", "true")
+	}
+	machine.Observers().Flush()
+}
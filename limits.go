@@ -0,0 +1,121 @@
+package fluo
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// PayloadLimits bounds the size of event data and of the machine context.
+// Sizes are measured in bytes of encoded JSON; zero disables a limit. Events
+// with oversized data are rejected. An oversized context is reported to
+// observers when it is noticed and refused by Snapshot, so it never reaches
+// a store, while events keep being processed.
+type PayloadLimits struct {
+	MaxEventDataSize int
+	MaxContextSize   int
+}
+
+// WithPayloadLimits sets the payload size limits of the machine
+func WithPayloadLimits(limits PayloadLimits) MachineOption {
+	return func(sm *StateMachine) {
+		sm.limits = limits
+	}
+}
+
+// WithMaxEventDataSize limits the encoded size of event data
+func WithMaxEventDataSize(bytes int) MachineOption {
+	return func(sm *StateMachine) {
+		sm.limits.MaxEventDataSize = bytes
+	}
+}
+
+// WithMaxContextSize limits the total encoded size of the machine context.
+// The context is measured before events once it was written to.
+func WithMaxContextSize(bytes int) MachineOption {
+	return func(sm *StateMachine) {
+		sm.limits.MaxContextSize = bytes
+	}
+}
+
+// payloadSize returns the encoded size of a value, falling back to its
+// formatted size for values that cannot be encoded as JSON
+func payloadSize(value any) int {
+	if value == nil {
+		return 0
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return len(fmt.Sprintf("%v", value))
+	}
+	return len(data)
+}
+
+// checkEventData verifies event data against the configured limit
+func (sm *StateMachine) checkEventData(eventName string, eventData any) error {
+	if sm.limits.MaxEventDataSize <= 0 || eventData == nil {
+		return nil
+	}
+	if size := payloadSize(eventData); size > sm.limits.MaxEventDataSize {
+		return NewPayloadTooLargeError("event", eventName, size, sm.limits.MaxEventDataSize)
+	}
+	return nil
+}
+
+// contextMeasure is the outcome of measuring a revision of a context
+type contextMeasure struct {
+	context  *StateMachineContext
+	revision uint64
+	err      error
+}
+
+// checkContextSize verifies the total context size against the configured limit,
+// reporting the largest key as the offender. The context is only measured
+// again once it was written to.
+func (sm *StateMachine) checkContextSize() error {
+	if sm.limits.MaxContextSize <= 0 {
+		return nil
+	}
+
+	smCtx, tracked := sm.context.(*StateMachineContext)
+	var revision uint64
+	if tracked {
+		revision = smCtx.dataRevision()
+		if measure := sm.contextSize.Load(); measure != nil && measure.context == smCtx && measure.revision == revision {
+			return measure.err
+		}
+	}
+
+	total := 0
+	largestKey, largestSize := "", -1
+	for key, value := range sm.context.GetAll() {
+		size := len(key) + payloadSize(value)
+		total += size
+		if size > largestSize || (size == largestSize && key < largestKey) {
+			largestKey, largestSize = key, size
+		}
+	}
+
+	var err error
+	if total > sm.limits.MaxContextSize {
+		err = NewPayloadTooLargeError("context", largestKey, total, sm.limits.MaxContextSize)
+	}
+	if tracked {
+		sm.contextSize.Store(&contextMeasure{context: smCtx, revision: revision, err: err})
+	}
+	return err
+}
+
+// reportContextSize reports a context that outgrew the configured limit to
+// observers once, when the growth is first noticed. Events keep being
+// processed, so actions can trim the context again; snapshots are refused
+// until they do.
+func (sm *StateMachine) reportContextSize() {
+	if sm.limits.MaxContextSize <= 0 {
+		return
+	}
+	previous := sm.contextSize.Load()
+	err := sm.checkContextSize()
+	if err != nil && (previous == nil || previous.err == nil) {
+		sm.observers.NotifyError(err, sm.context)
+	}
+}
@@ -0,0 +1,81 @@
+package fluo
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPayloadLimits_EventDataTooLarge(t *testing.T) {
+	definition := NewMachine().
+		State("idle").Initial().
+		To("running").On("start").
+		State("running").
+		Build()
+
+	machine := definition.CreateInstance(WithMaxEventDataSize(32))
+	observer := NewTestObserver()
+	machine.AddObserver(observer)
+	_ = machine.Start()
+
+	result := machine.HandleEvent("start", strings.Repeat("x", 64))
+	AssertEventProcessed(t, result, false)
+	AssertState(t, machine, "idle")
+
+	if !IsPayloadError(result.Error) {
+		t.Fatalf("Expected payload error, got: %v", result.Error)
+	}
+	payloadErr := result.Error.(*PayloadError)
+	if payloadErr.Kind != "event" || payloadErr.Key != "start" || payloadErr.Limit != 32 {
+		t.Errorf("Unexpected payload error details: %+v", payloadErr)
+	}
+	if GetErrorCode(result.Error) != ErrCodePayloadTooLarge {
+		t.Error("Expected ErrCodePayloadTooLarge error code")
+	}
+	if len(observer.EventRejects) != 1 {
+		t.Errorf("Expected 1 rejection notification, got %d", len(observer.EventRejects))
+	}
+
+	result = machine.HandleEvent("start", "small")
+	AssertEventProcessed(t, result, true)
+}
+
+func TestPayloadLimits_ContextTooLarge(t *testing.T) {
+	definition := NewMachine().
+		State("idle").Initial().
+		To("running").On("start").
+		State("running").
+		To("idle").On("stop").
+		Build()
+
+	machine := definition.CreateInstance(WithPayloadLimits(PayloadLimits{MaxContextSize: 100}))
+	observer := NewTestObserver()
+	machine.AddObserver(observer)
+	_ = machine.Start()
+
+	machine.Context().Set("small", "ok")
+	machine.Context().Set("blob", strings.Repeat("x", 200))
+
+	// The oversized context is reported once, without wedging the machine
+	AssertEventProcessed(t, machine.HandleEvent("start", nil), true)
+	AssertEventProcessed(t, machine.HandleEvent("stop", nil), true)
+	if len(observer.Errors) != 1 {
+		t.Fatalf("Expected the oversized context to be reported once, got %v", observer.Errors)
+	}
+	if payloadErr, ok := observer.Errors[0].Error.(*PayloadError); !ok || payloadErr.Kind != "context" || payloadErr.Key != "blob" {
+		t.Errorf("Expected context payload error naming 'blob', got: %v", observer.Errors[0].Error)
+	}
+
+	if _, err := machine.Snapshot(); !IsPayloadError(err) {
+		t.Errorf("Expected snapshot to be refused, got: %v", err)
+	}
+
+	machine.Context().Set("blob", "trimmed")
+	if _, err := machine.Snapshot(); err != nil {
+		t.Errorf("Expected snapshot of the trimmed context, got: %v", err)
+	}
+	machine.Context().Set("blob", strings.Repeat("y", 200))
+	machine.HandleEvent("start", nil)
+	if len(observer.Errors) != 2 {
+		t.Errorf("Expected growing past the limit again to be reported, got %v", observer.Errors)
+	}
+}
@@ -0,0 +1,547 @@
+package fluo
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// MachineDocument is the declarative form of a machine definition
+type MachineDocument struct {
+	Initial string          `json:"initial"`
+	States  []StateDocument `json:"states"`
+}
+
+// StateDocument declares a state. IDs are local to the enclosing composite
+// state or region; Type is one of atomic (default), final, composite,
+// parallel, choice, junction, fork, join, history or deepHistory.
+type StateDocument struct {
+	ID          string               `json:"id"`
+	Type        string               `json:"type,omitempty"`
+	Initial     string               `json:"initial,omitempty"`
+	OnEntry     string               `json:"onEntry,omitempty"`
+	OnExit      string               `json:"onExit,omitempty"`
+	Timeout     string               `json:"timeout,omitempty"`
+	OnTimeout   string               `json:"onTimeout,omitempty"`
+	States      []StateDocument      `json:"states,omitempty"`
+	Regions     []RegionDocument     `json:"regions,omitempty"`
+	Transitions []TransitionDocument `json:"transitions,omitempty"`
+
+	// Choice and junction branches
+	Branches  []BranchDocument `json:"branches,omitempty"`
+	Otherwise string           `json:"otherwise,omitempty"`
+
+	// Fork targets, join sources and target, and history default
+	Targets []string `json:"targets,omitempty"`
+	Sources []string `json:"sources,omitempty"`
+	Target  string   `json:"target,omitempty"`
+	Default string   `json:"default,omitempty"`
+
+	// History retention: a duration string and an exit count
+	ExpireAfter string `json:"expireAfter,omitempty"`
+	ForgetAfter int    `json:"forgetAfter,omitempty"`
+}
+
+// RegionDocument declares a region of a parallel state
+type RegionDocument struct {
+	ID      string          `json:"id"`
+	Initial string          `json:"initial,omitempty"`
+	States  []StateDocument `json:"states"`
+}
+
+// TransitionDocument declares a transition. Transitions without an event are
+// completion transitions; After declares a timed transition ("5s", "1m30s").
+type TransitionDocument struct {
+	Event       string   `json:"event,omitempty"`
+	Target      string   `json:"target,omitempty"`
+	Guard       string   `json:"guard,omitempty"`
+	Action      string   `json:"action,omitempty"`
+	OnError     string   `json:"onError,omitempty"`
+	Internal    bool     `json:"internal,omitempty"`
+	After       string   `json:"after,omitempty"`
+	Flag        string   `json:"flag,omitempty"`
+	Priority    int      `json:"priority,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+}
+
+// BranchDocument declares a guarded branch of a choice or junction
+type BranchDocument struct {
+	Guard  string `json:"guard"`
+	Target string `json:"target"`
+	Action string `json:"action,omitempty"`
+}
+
+// documentLoader builds a machine definition from a document
+type documentLoader struct {
+	registry *Registry
+	mb       *machineBuilderImpl
+	pending  []func() error
+}
+
+// LoadDefinition builds a machine definition from a document using the default registry
+func LoadDefinition(doc *MachineDocument) (MachineDefinition, error) {
+	return DefaultRegistry.LoadDefinition(doc)
+}
+
+// LoadDefinitionJSON builds a machine definition from a JSON document using the default registry
+func LoadDefinitionJSON(data []byte) (MachineDefinition, error) {
+	return DefaultRegistry.LoadDefinitionJSON(data)
+}
+
+// LoadDefinitionYAML builds a machine definition from a YAML document using the default registry
+func LoadDefinitionYAML(data []byte) (MachineDefinition, error) {
+	return DefaultRegistry.LoadDefinitionYAML(data)
+}
+
+// ParseDocumentJSON parses a JSON machine document
+func ParseDocumentJSON(data []byte) (*MachineDocument, error) {
+	var doc MachineDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// ParseDocumentYAML parses a YAML machine document
+func ParseDocumentYAML(data []byte) (*MachineDocument, error) {
+	value, err := parseYAML(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// The parsed document is converted through JSON to reuse the document schema
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return ParseDocumentJSON(encoded)
+}
+
+// LoadDefinitionJSON builds a machine definition from a JSON document
+func (r *Registry) LoadDefinitionJSON(data []byte) (MachineDefinition, error) {
+	doc, err := ParseDocumentJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	return r.LoadDefinition(doc)
+}
+
+// LoadDefinitionYAML builds a machine definition from a YAML document
+func (r *Registry) LoadDefinitionYAML(data []byte) (MachineDefinition, error) {
+	doc, err := ParseDocumentYAML(data)
+	if err != nil {
+		return nil, err
+	}
+	return r.LoadDefinition(doc)
+}
+
+// LoadDefinition builds a machine definition from a document, resolving guards and
+// actions by name from the registry
+func (r *Registry) LoadDefinition(doc *MachineDocument) (MachineDefinition, error) {
+	if doc == nil {
+		return nil, NewConfigurationError("loader", "document is nil")
+	}
+
+	mb, _ := NewMachine().(*machineBuilderImpl)
+	loader := &documentLoader{registry: r, mb: mb}
+
+	for _, stateDoc := range doc.States {
+		if err := loader.declare(stateDoc, "", nil); err != nil {
+			return nil, err
+		}
+	}
+
+	if doc.Initial == "" {
+		return nil, NewConfigurationError("loader", "no initial state defined")
+	}
+	initial, err := loader.resolveTarget(doc.Initial, "")
+	if err != nil {
+		return nil, err
+	}
+	mb.initialState = initial
+
+	for _, resolve := range loader.pending {
+		if err := resolve(); err != nil {
+			return nil, err
+		}
+	}
+	definition, err := mb.BuildE()
+	if err != nil {
+		return nil, NewConfigurationError("loader", err.Error())
+	}
+	return definition, nil
+}
+
+// declare creates a state and its descendants below parentPath
+func (l *documentLoader) declare(doc StateDocument, parentPath string, region *RegionImpl) error {
+	if doc.ID == "" {
+		return NewConfigurationError("loader", fmt.Sprintf("state without id in '%s'", parentPath))
+	}
+
+	id := doc.ID
+	if parentPath != "" {
+		id = parentPath + "." + doc.ID
+	}
+	if _, exists := l.mb.states[id]; exists {
+		return NewConfigurationError("loader", fmt.Sprintf("duplicate state id '%s'", id))
+	}
+
+	state, err := l.createState(id, doc)
+	if err != nil {
+		return err
+	}
+	l.mb.states[id] = state
+	if region != nil {
+		region.AddState(state)
+	}
+
+	if err := l.setStateActions(state, doc); err != nil {
+		return err
+	}
+
+	for _, transitionDoc := range doc.Transitions {
+		l.pending = append(l.pending, func() error {
+			return l.addTransition(id, parentPath, transitionDoc)
+		})
+	}
+	if doc.Timeout != "" || doc.OnTimeout != "" {
+		l.pending = append(l.pending, func() error {
+			return l.setTimeout(id, parentPath, doc)
+		})
+	}
+	return nil
+}
+
+// setTimeout declares the timeout of a state and the state it times out to
+func (l *documentLoader) setTimeout(id, parentPath string, doc StateDocument) error {
+	timeout, err := time.ParseDuration(doc.Timeout)
+	if err != nil || timeout <= 0 || doc.OnTimeout == "" {
+		return NewConfigurationError("loader", fmt.Sprintf("state '%s' needs a positive timeout and an onTimeout target, got '%s' and '%s'", id, doc.Timeout, doc.OnTimeout))
+	}
+	target, err := l.resolveTarget(doc.OnTimeout, parentPath)
+	if err != nil {
+		return err
+	}
+
+	if l.mb.timeouts == nil {
+		l.mb.timeouts = make(map[string]time.Duration)
+	}
+	if l.mb.timeoutTargets == nil {
+		l.mb.timeoutTargets = make(map[string]string)
+	}
+	l.mb.timeouts[id] = timeout
+	l.mb.timeoutTargets[id] = target
+	return nil
+}
+
+// createState creates the state for a document according to its type
+func (l *documentLoader) createState(id string, doc StateDocument) (State, error) {
+	switch doc.Type {
+	case "", "atomic":
+		return NewAtomicState(id), nil
+	case "final":
+		return NewFinalState(id), nil
+	case "composite":
+		composite := NewCompositeState(id)
+		for _, child := range doc.States {
+			if err := l.declare(child, id, nil); err != nil {
+				return nil, err
+			}
+		}
+		initial := doc.Initial
+		if initial == "" && len(doc.States) > 0 {
+			initial = doc.States[0].ID
+		}
+		if initial != "" {
+			l.pending = append(l.pending, func() error {
+				target, err := l.resolveTarget(initial, id)
+				if err != nil {
+					return err
+				}
+				composite.WithInitialState(l.mb.states[target])
+				return nil
+			})
+		}
+		return composite, nil
+	case "parallel":
+		parallel := NewParallelState(id)
+		for _, regionDoc := range doc.Regions {
+			if err := l.declareRegion(regionDoc, parallel, id); err != nil {
+				return nil, err
+			}
+		}
+		return parallel, nil
+	case "choice", "junction":
+		kind := Choice
+		if doc.Type == "junction" {
+			kind = Junction
+		}
+		pseudo := NewPseudoState(id, kind)
+		l.pending = append(l.pending, func() error {
+			return l.configureChoice(pseudo, doc, parentOf(id))
+		})
+		return pseudo, nil
+	case "fork":
+		pseudo := NewPseudoState(id, Fork)
+		l.pending = append(l.pending, func() error {
+			for _, target := range doc.Targets {
+				resolved, err := l.resolveTarget(target, parentOf(id))
+				if err != nil {
+					return err
+				}
+				pseudo.AddForkTarget(resolved)
+			}
+			return nil
+		})
+		return pseudo, nil
+	case "join":
+		pseudo := NewPseudoState(id, Join)
+		l.pending = append(l.pending, func() error {
+			sources := make([]string, 0, len(doc.Sources))
+			for _, source := range doc.Sources {
+				resolved, err := l.resolveTarget(source, parentOf(id))
+				if err != nil {
+					return err
+				}
+				sources = append(sources, resolved)
+			}
+			pseudo.SetJoinSources(sources)
+			target, err := l.resolveTarget(doc.Target, parentOf(id))
+			if err != nil {
+				return err
+			}
+			pseudo.SetJoinTarget(target)
+			return nil
+		})
+		return pseudo, nil
+	case "history", "deepHistory":
+		pseudo := NewHistoryState(id, doc.Type == "deepHistory")
+		if doc.ExpireAfter != "" {
+			ttl, err := time.ParseDuration(doc.ExpireAfter)
+			if err != nil || ttl <= 0 {
+				return nil, NewConfigurationError("loader", fmt.Sprintf("history state '%s' has invalid expireAfter '%s'", id, doc.ExpireAfter))
+			}
+			pseudo.SetHistoryTTL(ttl)
+		}
+		pseudo.SetHistoryExitLimit(doc.ForgetAfter)
+		if doc.Default != "" {
+			l.pending = append(l.pending, func() error {
+				target, err := l.resolveTarget(doc.Default, parentOf(id))
+				if err != nil {
+					return err
+				}
+				pseudo.SetHistoryDefault(target)
+				return nil
+			})
+		}
+		return pseudo, nil
+	}
+
+	return nil, NewConfigurationError("loader", fmt.Sprintf("state '%s' has unknown type '%s'", id, doc.Type))
+}
+
+// declareRegion creates a region of a parallel state and its states
+func (l *documentLoader) declareRegion(doc RegionDocument, parallel *ParallelStateImpl, parallelID string) error {
+	if doc.ID == "" {
+		return NewConfigurationError("loader", fmt.Sprintf("region without id in '%s'", parallelID))
+	}
+
+	region := NewRegion(doc.ID, parallel)
+	parallel.AddRegion(region)
+
+	regionPath := parallelID + "." + doc.ID
+	for _, stateDoc := range doc.States {
+		if err := l.declare(stateDoc, regionPath, region); err != nil {
+			return err
+		}
+	}
+
+	initial := doc.Initial
+	if initial == "" && len(doc.States) > 0 {
+		initial = doc.States[0].ID
+	}
+	if initial != "" {
+		l.pending = append(l.pending, func() error {
+			target, err := l.resolveTarget(initial, regionPath)
+			if err != nil {
+				return err
+			}
+			region.WithInitialState(l.mb.states[target])
+			return nil
+		})
+	}
+	return nil
+}
+
+// setStateActions attaches named entry and exit actions to a state
+func (l *documentLoader) setStateActions(state State, doc StateDocument) error {
+	atomic := atomicStateOf(state)
+	if atomic == nil {
+		return nil
+	}
+
+	if doc.OnEntry != "" {
+		action, err := l.action(doc.OnEntry)
+		if err != nil {
+			return err
+		}
+		atomic.WithEntryAction(action)
+	}
+	if doc.OnExit != "" {
+		action, err := l.action(doc.OnExit)
+		if err != nil {
+			return err
+		}
+		atomic.WithExitAction(action)
+	}
+	return nil
+}
+
+// atomicStateOf returns the embedded AtomicStateImpl of a built-in state
+func atomicStateOf(state State) *AtomicStateImpl {
+	switch s := state.(type) {
+	case *AtomicStateImpl:
+		return s
+	case *CompositeStateImpl:
+		return &s.AtomicStateImpl
+	case *ParallelStateImpl:
+		return &s.AtomicStateImpl
+	case *PseudoStateImpl:
+		return &s.AtomicStateImpl
+	}
+	return nil
+}
+
+// configureChoice adds the branches of a choice or junction
+func (l *documentLoader) configureChoice(pseudo *PseudoStateImpl, doc StateDocument, scope string) error {
+	for _, branch := range doc.Branches {
+		guard, err := l.guard(branch.Guard)
+		if err != nil {
+			return err
+		}
+		var action ActionFunc
+		if branch.Action != "" {
+			if action, err = l.action(branch.Action); err != nil {
+				return err
+			}
+		}
+		target, err := l.resolveTarget(branch.Target, scope)
+		if err != nil {
+			return err
+		}
+		pseudo.addChoiceCondition(ChoiceCondition{Guard: guard, GuardName: branch.Guard, Target: target, Action: action})
+	}
+
+	if doc.Otherwise != "" {
+		target, err := l.resolveTarget(doc.Otherwise, scope)
+		if err != nil {
+			return err
+		}
+		pseudo.SetDefaultTarget(target)
+	}
+	return nil
+}
+
+// addTransition adds a transition declared on a state
+func (l *documentLoader) addTransition(source, scope string, doc TransitionDocument) error {
+	transition := Transition{
+		SourceState: source,
+		TargetState: source,
+		EventName:   doc.Event,
+		Internal:    doc.Internal,
+	}
+
+	if !doc.Internal || doc.Target != "" {
+		target, err := l.resolveTarget(doc.Target, scope)
+		if err != nil {
+			return err
+		}
+		if doc.Internal && target != source {
+			return NewConfigurationError("loader", fmt.Sprintf("internal transition from '%s' on '%s' must target its source state, not '%s'", source, doc.Event, target))
+		}
+		transition.TargetState = target
+	}
+	if doc.OnError != "" {
+		errorState, err := l.resolveTarget(doc.OnError, scope)
+		if err != nil {
+			return err
+		}
+		transition.ErrorState = errorState
+	}
+
+	if doc.After != "" {
+		after, err := time.ParseDuration(doc.After)
+		if err != nil || after <= 0 {
+			return NewConfigurationError("loader", fmt.Sprintf("transition from '%s' has invalid after duration '%s'", source, doc.After))
+		}
+		transition.After = after
+		transition.EventName = timedEventName(source, after)
+	} else if doc.Event == "" {
+		transition.EventName = "__completion_" + source
+	}
+
+	if doc.Guard != "" {
+		guard, err := l.guard(doc.Guard)
+		if err != nil {
+			return err
+		}
+		transition.Guard = guard
+		transition.GuardName = doc.Guard
+	}
+	transition.Flag = doc.Flag
+	transition.Priority = doc.Priority
+	transition.Description = doc.Description
+	transition.Tags = doc.Tags
+	if doc.Action != "" {
+		action, err := l.action(doc.Action)
+		if err != nil {
+			return err
+		}
+		transition.Action = action
+		transition.ActionName = doc.Action
+	}
+
+	l.mb.addTransition(transition)
+	return nil
+}
+
+// resolveTarget resolves a state reference, trying it as a full ID first and
+// then relative to each enclosing scope from the innermost outwards
+func (l *documentLoader) resolveTarget(target, scope string) (string, error) {
+	if target == "" {
+		return "", NewConfigurationError("loader", fmt.Sprintf("missing target in '%s'", scope))
+	}
+	if _, exists := l.mb.states[target]; exists {
+		return target, nil
+	}
+	for prefix := scope; prefix != ""; prefix = parentOf(prefix) {
+		if _, exists := l.mb.states[prefix+"."+target]; exists {
+			return prefix + "." + target, nil
+		}
+	}
+	return "", NewStateNotFoundError(target)
+}
+
+// parentOf returns the enclosing path of a dotted ID
+func parentOf(id string) string {
+	return StatePath(id).Parent().String()
+}
+
+// guard looks up a named guard
+func (l *documentLoader) guard(name string) (GuardFunc, error) {
+	guard, ok := l.registry.Guard(name)
+	if !ok {
+		return nil, NewConfigurationError("loader", fmt.Sprintf("guard '%s' is not registered", name))
+	}
+	return guard, nil
+}
+
+// action looks up a named action
+func (l *documentLoader) action(name string) (ActionFunc, error) {
+	action, ok := l.registry.Action(name)
+	if !ok {
+		return nil, NewConfigurationError("loader", fmt.Sprintf("action '%s' is not registered", name))
+	}
+	return action, nil
+}
@@ -0,0 +1,186 @@
+package fluo
+
+import (
+	"testing"
+	"time"
+)
+
+const testMachineJSON = `{
+  "initial": "idle",
+  "states": [
+    {"id": "idle", "onEntry": "recordIdle", "transitions": [
+      {"event": "submit", "target": "review"},
+      {"after": "50ms", "target": "expired"}
+    ]},
+    {"id": "review", "type": "composite", "initial": "pending", "states": [
+      {"id": "pending", "transitions": [{"event": "decide", "target": "route"}]},
+      {"id": "route", "type": "choice",
+       "branches": [{"guard": "isUrgent", "target": "escalated"}],
+       "otherwise": "approved"},
+      {"id": "escalated"},
+      {"id": "approved", "type": "final"}
+    ], "transitions": [{"event": "cancel", "target": "idle"}]},
+    {"id": "expired", "type": "final"}
+  ]
+}`
+
+const testMachineYAML = `
+# Document approval flow
+initial: idle
+states:
+  - id: idle
+    onEntry: recordIdle
+    transitions:
+      - event: submit
+        target: review
+      - after: 50ms
+        target: expired
+  - id: review
+    type: composite
+    initial: pending
+    states:
+      - id: pending
+        transitions:
+          - {event: decide, target: route}
+      - id: route
+        type: choice
+        branches:
+          - guard: isUrgent
+            target: escalated
+        otherwise: approved
+      - id: escalated
+      - id: approved
+        type: final
+    transitions:
+      - event: cancel
+        target: idle
+  - id: expired
+    type: final
+`
+
+func newTestLoaderRegistry(urgent *bool, entries *int) *Registry {
+	registry := NewRegistry()
+	registry.RegisterGuard("isUrgent", func(ctx Context) bool { return *urgent })
+	registry.RegisterAction("recordIdle", func(ctx Context) error {
+		*entries++
+		return nil
+	})
+	return registry
+}
+
+func TestLoadDefinition_JSONAndYAML(t *testing.T) {
+	loaders := map[string]func(*Registry) (MachineDefinition, error){
+		"json": func(r *Registry) (MachineDefinition, error) { return r.LoadDefinitionJSON([]byte(testMachineJSON)) },
+		"yaml": func(r *Registry) (MachineDefinition, error) { return r.LoadDefinitionYAML([]byte(testMachineYAML)) },
+	}
+
+	for name, load := range loaders {
+		t.Run(name, func(t *testing.T) {
+			urgent := false
+			entries := 0
+			definition, err := load(newTestLoaderRegistry(&urgent, &entries))
+			if err != nil {
+				t.Fatalf("Expected no error loading definition, got: %v", err)
+			}
+
+			machine := definition.CreateInstance()
+			_ = machine.Start()
+			AssertState(t, machine, "idle")
+
+			_ = machine.HandleEvent("submit", nil)
+			AssertState(t, machine, "review.pending")
+
+			_ = machine.HandleEvent("decide", nil)
+			AssertState(t, machine, "review.approved")
+
+			urgent = true
+			machine = definition.CreateInstance()
+			_ = machine.Start()
+			_ = machine.HandleEvent("submit", nil)
+			_ = machine.HandleEvent("decide", nil)
+			AssertState(t, machine, "review.escalated")
+
+			if entries == 0 {
+				t.Error("Expected named entry action to run")
+			}
+
+			var timed bool
+			for _, transition := range definition.GetTransitions()["idle"] {
+				timed = timed || transition.After == 50*time.Millisecond
+			}
+			if !timed {
+				t.Error("Expected timed transition to be loaded")
+			}
+		})
+	}
+}
+
+func TestLoadDefinition_ParallelAndDefaultRegistry(t *testing.T) {
+	RegisterGuard("loaderTestAlways", func(ctx Context) bool { return true })
+
+	definition, err := LoadDefinitionYAML([]byte(`
+initial: off
+states:
+  - id: off
+    transitions:
+      - event: power
+        target: on
+        guard: loaderTestAlways
+  - id: on
+    type: parallel
+    regions:
+      - id: motor
+        states:
+          - id: stopped
+            transitions: [{event: start, target: running}]
+          - id: running
+      - id: lights
+        initial: dark
+        states:
+          - id: lit
+          - id: dark
+            transitions: [{event: toggle, target: lit}]
+`))
+	if err != nil {
+		t.Fatalf("Expected no error loading definition, got: %v", err)
+	}
+
+	machine := definition.CreateInstance()
+	_ = machine.Start()
+	_ = machine.HandleEvent("power", nil)
+	_ = machine.HandleEvent("start", nil)
+	_ = machine.HandleEvent("toggle", nil)
+
+	if machine.RegionState("motor") != "on.motor.running" {
+		t.Errorf("Expected motor region running, got %s", machine.RegionState("motor"))
+	}
+	if machine.RegionState("lights") != "on.lights.lit" {
+		t.Errorf("Expected lights region lit, got %s", machine.RegionState("lights"))
+	}
+}
+
+func TestLoadDefinition_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+	}{
+		{"missing initial", `{"states": [{"id": "a"}]}`},
+		{"unknown initial", `{"initial": "b", "states": [{"id": "a"}]}`},
+		{"unknown target", `{"initial": "a", "states": [{"id": "a", "transitions": [{"event": "go", "target": "b"}]}]}`},
+		{"unregistered guard", `{"initial": "a", "states": [{"id": "a", "transitions": [{"event": "go", "target": "a", "guard": "nope"}]}]}`},
+		{"unregistered action", `{"initial": "a", "states": [{"id": "a", "onEntry": "nope"}]}`},
+		{"unknown type", `{"initial": "a", "states": [{"id": "a", "type": "weird"}]}`},
+		{"duplicate state", `{"initial": "a", "states": [{"id": "a"}, {"id": "a"}]}`},
+		{"invalid after", `{"initial": "a", "states": [{"id": "a", "transitions": [{"after": "soon", "target": "a"}]}]}`},
+		{"invalid expireAfter", `{"initial": "a", "states": [{"id": "a"}, {"id": "h", "type": "history", "expireAfter": "later"}]}`},
+		{"internal with other target", `{"initial": "a", "states": [{"id": "a", "transitions": [{"event": "go", "target": "b", "internal": true}]}, {"id": "b"}]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewRegistry().LoadDefinitionJSON([]byte(tt.document)); err == nil {
+				t.Error("Expected error loading invalid document")
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package fluo
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+// LoggingObserver writes machine lifecycle events to a structured slog logger.
+// Transitions, state changes, actions and lifecycle events are logged at the
+// configured level; guard evaluations at debug, rejected events at warn and
+// errors at error level.
+type LoggingObserver struct {
+	logger  *slog.Logger
+	level   slog.Level
+	machine string
+
+	entered map[string]time.Time // When each state was last entered, for transition durations
+	mutex   sync.Mutex
+}
+
+// LoggingOption configures a LoggingObserver
+type LoggingOption func(*LoggingObserver)
+
+// WithLogger sets the logger used by the observer (slog.Default() by default)
+func WithLogger(logger *slog.Logger) LoggingOption {
+	return func(o *LoggingObserver) {
+		o.logger = logger
+	}
+}
+
+// WithLogLevel sets the level of routine lifecycle records (slog.LevelInfo by default)
+func WithLogLevel(level slog.Level) LoggingOption {
+	return func(o *LoggingObserver) {
+		o.level = level
+	}
+}
+
+// WithMachineName adds a machine field to every record
+func WithMachineName(name string) LoggingOption {
+	return func(o *LoggingObserver) {
+		o.machine = name
+	}
+}
+
+// NewLoggingObserver creates an observer that logs through log/slog
+func NewLoggingObserver(opts ...LoggingOption) *LoggingObserver {
+	o := &LoggingObserver{
+		logger:  slog.Default(),
+		level:   slog.LevelInfo,
+		entered: make(map[string]time.Time),
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// log writes a record with the machine and instance fields and the given attributes
+func (o *LoggingObserver) log(ctx Context, level slog.Level, msg string, attrs ...slog.Attr) {
+	var logCtx context.Context = context.Background()
+	if ctx != nil {
+		logCtx = ctx
+	}
+	if !o.logger.Enabled(logCtx, level) {
+		return
+	}
+	if metadata := InstanceMetadata(ctx); len(metadata) > 0 {
+		labels := make([]any, 0, len(metadata))
+		for _, key := range slices.Sorted(maps.Keys(metadata)) {
+			labels = append(labels, slog.String(key, metadata[key]))
+		}
+		attrs = append([]slog.Attr{slog.Group("metadata", labels...)}, attrs...)
+	}
+	if id := InstanceID(ctx); id != "" {
+		attrs = append([]slog.Attr{slog.String("instance", id)}, attrs...)
+	}
+	if o.machine != "" {
+		attrs = append([]slog.Attr{slog.String("machine", o.machine)}, attrs...)
+	}
+	o.logger.LogAttrs(logCtx, level, msg, attrs...)
+}
+
+// eventAttr returns the event field, or nothing for eventless notifications
+func eventAttr(event Event) []slog.Attr {
+	if event == nil {
+		return nil
+	}
+	return []slog.Attr{slog.String("event", event.GetName())}
+}
+
+// OnTransition logs a transition with the time spent in the source state
+func (o *LoggingObserver) OnTransition(from string, to string, event Event, ctx Context) {
+	attrs := []slog.Attr{slog.String("from", from), slog.String("to", to)}
+	attrs = append(attrs, eventAttr(event)...)
+
+	o.mutex.Lock()
+	if enteredAt, ok := o.entered[from]; ok {
+		attrs = append(attrs, slog.Duration("duration", time.Since(enteredAt)))
+	}
+	o.mutex.Unlock()
+
+	o.log(ctx, o.level, "transition", attrs...)
+}
+
+// OnStateEnter logs a state entry
+func (o *LoggingObserver) OnStateEnter(state string, ctx Context) {
+	o.mutex.Lock()
+	o.entered[state] = time.Now()
+	o.mutex.Unlock()
+
+	o.log(ctx, o.level, "state entered", slog.String("state", state))
+}
+
+// OnStateExit logs a state exit with the time spent in the state
+func (o *LoggingObserver) OnStateExit(state string, ctx Context) {
+	attrs := []slog.Attr{slog.String("state", state)}
+
+	o.mutex.Lock()
+	if enteredAt, ok := o.entered[state]; ok {
+		attrs = append(attrs, slog.Duration("duration", time.Since(enteredAt)))
+	}
+	o.mutex.Unlock()
+
+	o.log(ctx, o.level, "state exited", attrs...)
+}
+
+// OnGuardEvaluation logs a guard result at debug level
+func (o *LoggingObserver) OnGuardEvaluation(from string, to string, event Event, result bool, ctx Context) {
+	attrs := []slog.Attr{slog.String("from", from), slog.String("to", to)}
+	attrs = append(attrs, eventAttr(event)...)
+	attrs = append(attrs, slog.Bool("result", result))
+	o.log(ctx, slog.LevelDebug, "guard evaluated", attrs...)
+}
+
+// OnEventRejected logs a rejected event at warn level
+func (o *LoggingObserver) OnEventRejected(event Event, reason string, ctx Context) {
+	attrs := eventAttr(event)
+	if ctx != nil {
+		attrs = append(attrs, slog.String("state", ctx.GetCurrentState()))
+	}
+	attrs = append(attrs, slog.String("reason", reason))
+	o.log(ctx, slog.LevelWarn, "event rejected", attrs...)
+}
+
+// OnError logs an error at error level
+func (o *LoggingObserver) OnError(err error, ctx Context) {
+	o.log(ctx, slog.LevelError, "machine error", slog.Any("error", err))
+}
+
+// OnActionExecution logs an action execution
+func (o *LoggingObserver) OnActionExecution(actionType string, state string, event Event, ctx Context) {
+	attrs := []slog.Attr{slog.String("action", actionType), slog.String("state", state)}
+	attrs = append(attrs, eventAttr(event)...)
+	o.log(ctx, o.level, "action executed", attrs...)
+}
+
+// OnMachineStarted logs the machine start
+func (o *LoggingObserver) OnMachineStarted(ctx Context) {
+	o.log(ctx, o.level, "machine started")
+}
+
+// OnMachineStopped logs the machine stop
+func (o *LoggingObserver) OnMachineStopped(ctx Context) {
+	o.log(ctx, o.level, "machine stopped")
+}
+
+// OnTimerFired logs an expired state timer
+func (o *LoggingObserver) OnTimerFired(state string, after time.Duration, ctx Context) {
+	o.log(ctx, o.level, "timer fired", slog.String("state", state), slog.Duration("after", after))
+}
+
+// OnRegionCompleted logs a region reaching its final state
+func (o *LoggingObserver) OnRegionCompleted(parallelState string, region string, ctx Context) {
+	o.log(ctx, o.level, "region completed", slog.String("state", parallelState), slog.String("region", region))
+}
+
+// OnActiveStateLimitExceeded logs the active state set outgrowing its bound
+func (o *LoggingObserver) OnActiveStateLimitExceeded(active []string, limit int, ctx Context) {
+	o.log(ctx, slog.LevelWarn, "active state limit exceeded", slog.Int("active", len(active)), slog.Int("limit", limit), slog.Any("states", active))
+}
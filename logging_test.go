@@ -0,0 +1,82 @@
+package fluo
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func decodeLogRecords(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("Invalid log line %q: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestLoggingObserver_StructuredFields(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	machine := CreateSimpleMachine()
+	machine.AddObserver(NewLoggingObserver(WithLogger(logger), WithMachineName("orders")))
+	_ = machine.Start()
+	machine.HandleEvent("start", nil)
+	machine.HandleEvent("unknown", nil)
+
+	records := decodeLogRecords(t, &buf)
+
+	var transition, rejected map[string]any
+	for _, record := range records {
+		if record["machine"] != "orders" {
+			t.Errorf("Expected machine field on every record, got %v", record)
+		}
+		switch record["msg"] {
+		case "transition":
+			transition = record
+		case "event rejected":
+			rejected = record
+		}
+	}
+
+	if transition == nil {
+		t.Fatal("Expected a transition record")
+	}
+	if transition["from"] != "idle" || transition["to"] != "running" || transition["event"] != "start" {
+		t.Errorf("Unexpected transition fields: %v", transition)
+	}
+	if _, ok := transition["duration"]; !ok {
+		t.Error("Expected transition record to include the time spent in the source state")
+	}
+	if transition["level"] != "INFO" {
+		t.Errorf("Expected transition at INFO, got %v", transition["level"])
+	}
+
+	if rejected == nil || rejected["level"] != "WARN" || rejected["event"] != "unknown" {
+		t.Errorf("Expected rejected event at WARN, got %v", rejected)
+	}
+}
+
+func TestLoggingObserver_Level(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo}))
+
+	machine := CreateSimpleMachine()
+	machine.AddObserver(NewLoggingObserver(WithLogger(logger), WithLogLevel(slog.LevelDebug)))
+	_ = machine.Start()
+	machine.HandleEvent("start", nil)
+
+	for _, record := range decodeLogRecords(t, &buf) {
+		t.Errorf("Expected debug-level lifecycle records to be filtered, got %v", record)
+	}
+}
@@ -2,12 +2,15 @@ package fluo
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
+	"maps"
 	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // Machine represents a state machine instance
@@ -15,41 +18,92 @@ type Machine interface {
 	Start() error
 	Stop() error
 	Reset() error
+	ResetKeepingContext() error
+	ResetSubtree(stateID string) error
+	ClearHistory(stateID string) error
+	HistoryOf(compositeID string) (stateID string, ok bool)
 
 	CurrentState() string
 	SetState(state string) error
+	ForceTransition(target string, opts ForceOptions) error
+	SetConfiguration(cfg ActiveConfiguration) error
+	EnterConfiguration(cfg ActiveConfiguration) error
+	CaptureConfiguration() ActiveConfiguration
 
 	SetRegionState(regionID string, stateID string) error
+	SetRegionStates(states map[string]string) error
 	RegionState(regionID string) string
+	RegionOf(stateID string) (regionID string, ok bool)
+	RegionCompleted(regionID string) bool
+	IsCompleted() bool
+	IsInFinalState() bool
+	Health() MachineHealth
+	Stats() MachineStats
+	ExplainRouting(eventName string) RoutingExplanation
+	Explain(eventName string) Explanation
+	Peek(eventName string, eventData any) *EventResult
+	AvailableEvents(opts ...AvailableEventsOption) []string
+	TimeoutRemaining(stateID string) (time.Duration, bool)
+	Submachine(stateID string) Machine
 	GetStateHierarchy() []string
 	IsInState(stateID string) bool
 	GetActiveStates() []string
+	GetConfiguration() Configuration
 	IsStateActive(stateID string) bool
 	GetParallelRegions() map[string][]string
 
 	SendEvent(eventName string, eventData any) *EventResult
 	SendEventWithContext(ctx context.Context, eventName string, eventData any) *EventResult
+	SendEventAsync(eventName string, eventData any) <-chan *EventResult
+	SendEventAsyncWithContext(ctx context.Context, eventName string, eventData any) <-chan *EventResult
+	SendEventWithPriority(eventName string, eventData any, priority EventPriority) <-chan *EventResult
+	SendEventAfter(delay time.Duration, eventName string, eventData any) *ScheduledEvent
+	SendEventAt(at time.Time, eventName string, eventData any) *ScheduledEvent
 	HandleEvent(eventName string, eventData any) *EventResult
 	HandleEventWithContext(ctx context.Context, eventName string, eventData any) *EventResult
 
+	ID() string
+	Metadata() map[string]string
+	SetMetadata(key, value string)
+
 	AddObserver(observer Observer)
+	AddObserverFiltered(observer Observer, filter ObserverFilter)
+	Use(middleware ...Middleware)
 	RemoveObserver(observer Observer)
+	AddRegionObserver(parallelState string, region string, observer RegionObserver) error
+	RemoveRegionObserver(observer RegionObserver)
+	Observers() *ObserverManager
+	SetDebug(level DebugLevel)
 
 	Context() Context
 	WithContext(ctx Context) Machine
 
+	Snapshot() (*Snapshot, error)
+	Restore(snapshot *Snapshot) error
+	MarshalSnapshot(codec Codec) ([]byte, error)
+	UnmarshalSnapshot(codec Codec, data []byte) error
 	MarshalJSON() ([]byte, error)
 	UnmarshalJSON(data []byte) error
+
+	History() []JournalEntry
+	RewindTo(index int) error
 }
 
 // MachineDefinition represents the configuration of a state machine
 type MachineDefinition interface {
-	CreateInstance() Machine
+	CreateInstance(opts ...MachineOption) Machine
+	CreateInstanceWithID(id string, opts ...MachineOption) Machine
 	Build() MachineDefinition
 
 	GetInitialState() string
 	GetStates() map[string]State
 	GetTransitions() map[string][]Transition
+
+	SelfTest() error
+	Validate() []ValidationIssue
+	TransitionTable() TransitionTable
+
+	NewPool(opts ...MachineOption) *Pool
 }
 
 // MachineState represents the current state of the machine
@@ -75,13 +129,122 @@ type StateMachine struct {
 	observers    *ObserverManager
 	machineState MachineState
 	mutex        sync.RWMutex
+	view         atomic.Pointer[configurationView] // Published by unlock for lock-free state queries
 
-	stateHistory map[string]string
+	stateHistory   map[string]string    // Last state of composite states, and of regions keyed by "<parallel state>.<region>"
+	historyRestore *historyRestore      // Region history a history pseudostate asked the next parallel entry to restore
+	historyTimes   map[string]time.Time // When each stateHistory entry was recorded
+	historyExits   map[string]int       // How often each state with history was exited since its history was last forgotten
 
 	// Parallel execution support
 	parallelRegions map[string][]string        // Track active states per region
 	joinConditions  map[string][][]string      // Track required source state combinations for join pseudostates
 	joinTracking    map[string]map[string]bool // Track which source states have arrived at each join
+	regionEntries   []regionEntry              // Region states activated with their parallel state, entered after it
+
+	// Timed transition support
+	timers   map[string][]*stateTimer // Armed timers keyed by the state that owns them
+	schedule eventSchedule            // Events sent later with SendEventAfter and SendEventAt
+
+	entryErr error // First entry action error of the transition being taken
+
+	activities  map[string]*runningActivity   // Running do-activities keyed by state
+	submachines map[string]*runningSubmachine // Child instances of submachine states keyed by state
+
+	// Event-loop mode support (nil when events are processed on the caller's goroutine)
+	eventLoop *eventLoop
+
+	// Snapshot support
+	valueMarshalers   map[string]ValueMarshaler // Custom marshalers keyed by context key
+	typeRegistry      *TypeRegistry             // Registry for typed context values (nil uses DefaultTypeRegistry)
+	definition        *simpleMachineDefinition  // Definition the instance was created from (nil for builder-internal machines)
+	definitionVersion string                    // Version label recorded in snapshots
+	snapshotMigration MigrationFunc             // Adapts snapshots of incompatible definitions on restore
+
+	// Payload guardrails, and the last measure of the context against them
+	limits      PayloadLimits
+	contextSize atomic.Pointer[contextMeasure]
+
+	// Feature flags gating transitions (nil disables flagged transitions)
+	flags FlagProvider
+
+	// Decides where a choice without a viable branch goes
+	choiceFallback ChoiceFallbackFunc
+
+	// Receives recovered guard and action panics
+	panicReporter PanicReporter
+
+	// How recovered guard and action panics are handled
+	panicPolicy  PanicPolicy
+	panicHandler PanicHandlerFunc
+
+	// Guard evaluation budget (0 disables the timeout)
+	guardTimeout time.Duration
+
+	// Action execution budget (0 disables the timeout)
+	actionTimeout time.Duration
+
+	// Hooks that may veto a transition once it has been selected
+	beforeTransition []BeforeTransitionFunc
+
+	// Set while the transition selector runs without side effects
+	probe *routingProbe
+
+	// Current state of each active parallel region in this instance
+	regionStates map[Region]State
+
+	// Correlation identity of the instance. Writers hold both mutexes, so
+	// observers and actions read it under labelMutex while the machine is locked.
+	id         string
+	metadata   map[string]string
+	labelMutex sync.RWMutex
+
+	// Event handling middleware, outermost first
+	middleware      []Middleware
+	middlewareMutex sync.RWMutex
+
+	// How several viable transitions from one state are resolved
+	conflictPolicy ConflictPolicy
+
+	// Whether events handled by one parallel region are delivered to the others too
+	broadcast bool
+
+	// What happened while resolving the current event, for its result
+	trace eventTrace
+
+	// Bound on the active state set (0 disables it) and whether it is currently exceeded
+	maxActiveStates     int
+	activeLimitExceeded bool
+
+	// Stop retains the configuration for the next Start to resume from
+	resumable bool
+
+	// Set while a forced transition moves the machine without running actions
+	skipActions bool
+
+	// Set on the instances SelfTest drives: guards fail, and actions, timers,
+	// do-activities and submachines do not run
+	dryRun bool
+
+	// Pseudostates the transition in progress is passing through
+	pseudoPath map[string]bool
+
+	// Event sourcing log and the nesting depth of the event being processed
+	eventLog   EventLog
+	eventDepth int
+
+	// Recorded transitions and checkpoints for History and RewindTo
+	timeline *timeline
+
+	// Mutex usage statistics, nil unless enabled
+	stats *lockStats
+
+	// Pool the instance is recycled into, nil unless it came from one
+	pool *Pool
+
+	// Routing diagnostics
+	debugLogger *slog.Logger
+	debugLevel  atomic.Int32
 }
 
 // newStateMachine creates a new state machine instance
@@ -92,10 +255,17 @@ func newStateMachine() *StateMachine {
 		observers:       NewObserverManager(),
 		machineState:    MachineStateStopped,
 		stateHistory:    make(map[string]string),
+		historyTimes:    make(map[string]time.Time),
+		historyExits:    make(map[string]int),
 		activeStates:    make(map[string]bool),
 		parallelRegions: make(map[string][]string),
 		joinConditions:  make(map[string][][]string),
 		joinTracking:    make(map[string]map[string]bool),
+		timers:          make(map[string][]*stateTimer),
+		activities:      make(map[string]*runningActivity),
+		submachines:     make(map[string]*runningSubmachine),
+		regionStates:    make(map[Region]State),
+		id:              newInstanceID(),
 	}
 
 	sm.context = NewContext(context.Background(), sm)
@@ -107,7 +277,7 @@ func safeEvaluateGuard(guard GuardFunc, ctx Context) (result bool, err error) {
 	defer func() {
 		if r := recover(); r != nil {
 			result = false
-			err = fmt.Errorf("guard panic: %v", r)
+			err = newPanicError("guard", r, ctx)
 		}
 	}()
 
@@ -119,7 +289,7 @@ func safeEvaluateGuard(guard GuardFunc, ctx Context) (result bool, err error) {
 func safeExecuteAction(action ActionFunc, ctx Context) (err error) {
 	defer func() {
 		if r := recover(); r != nil {
-			err = fmt.Errorf("action panic: %v", r)
+			err = newPanicError("action", r, ctx)
 		}
 	}()
 
@@ -127,22 +297,36 @@ func safeExecuteAction(action ActionFunc, ctx Context) (err error) {
 	return err
 }
 
-// Start starts the state machine
+// configurationError reports a definition problem found while the machine
+// runs to observers and returns it
+func (sm *StateMachine) configurationError(component, issue string) error {
+	err := NewConfigurationError(component, issue)
+	sm.observers.NotifyStageError(StageConfiguration, err, sm.context)
+	return err
+}
+
+// Start starts the state machine in its initial state. A machine stopped
+// with Stop resumes from the configuration it was stopped in instead, without
+// running entry actions again; Reset first to start over.
 func (sm *StateMachine) Start() error {
 	sm.mutex.Lock()
-	defer sm.mutex.Unlock()
+	defer sm.unlock()
 
 	if sm.machineState == MachineStateStarted {
 		return NewMachineError(ErrCodeInvalidState, "Start", "machine is already started")
 	}
+	if sm.resumable {
+		sm.resume()
+		return nil
+	}
 
 	if sm.initialState == "" {
-		return NewConfigurationError("StateMachine", "no initial state defined")
+		return sm.configurationError("StateMachine", "no initial state defined")
 	}
 
 	// Validate that initial state exists
 	if _, exists := sm.states[sm.initialState]; !exists {
-		return NewConfigurationError("StateMachine", fmt.Sprintf("initial state '%s' does not exist", sm.initialState))
+		return sm.configurationError("StateMachine", fmt.Sprintf("initial state '%s' does not exist", sm.initialState))
 	}
 
 	sm.machineState = MachineStateStarted
@@ -155,16 +339,39 @@ func (sm *StateMachine) Start() error {
 	}
 
 	sm.executeEntryActions("", sm.currentState, nil)
+	sm.enterRegions("")
 	sm.observers.NotifyStateEnter(sm.currentState, sm.context)
 	sm.observers.NotifyMachineStarted(sm.context)
 
+	if sm.timeline != nil {
+		sm.timeline.start(sm)
+	}
+
+	if sm.eventLoop != nil {
+		sm.eventLoop.start(sm)
+	}
+
 	return nil
 }
 
-// Stop stops the state machine
+// Stop stops the state machine once the run-to-completion step in progress
+// has finished, after processing the events already queued in the event loop.
+// Exit actions do not run and the configuration is retained, so a later Start
+// resumes where the machine stopped. Timers, do-activities and submachines are
+// suspended until then.
 func (sm *StateMachine) Stop() error {
+	// The event loop is drained outside the machine mutex, while the machine
+	// still accepts the queued events
+	if sm.eventLoop != nil && sm.started() {
+		sm.eventLoop.stop()
+	}
+	return sm.stop()
+}
+
+// stop transitions the machine to the stopped state
+func (sm *StateMachine) stop() error {
 	sm.mutex.Lock()
-	defer sm.mutex.Unlock()
+	defer sm.unlock()
 
 	if sm.machineState != MachineStateStarted {
 		return NewMachineNotStartedError("Stop")
@@ -175,21 +382,103 @@ func (sm *StateMachine) Stop() error {
 	}
 	sm.observers.NotifyMachineStopped(sm.context)
 
+	sm.stopAllTimers()
+	sm.stopAllActivities()
+	sm.suspendSubmachines()
 	sm.machineState = MachineStateStopped
+	sm.resumable = true
 	return nil
 }
 
-// Reset resets the state machine
+// started reports whether the machine is started
+func (sm *StateMachine) started() bool {
+	sm.mutex.RLock()
+	defer sm.mutex.RUnlock()
+	return sm.machineState == MachineStateStarted
+}
+
+// resume restarts a stopped machine in its retained configuration.
+// The caller must hold the machine mutex.
+func (sm *StateMachine) resume() {
+	sm.machineState = MachineStateStarted
+	sm.resumable = false
+	sm.resumeActiveStates()
+
+	sm.observers.NotifyStateEnter(sm.currentState, sm.context)
+	sm.observers.NotifyMachineStarted(sm.context)
+
+	if sm.timeline != nil {
+		sm.timeline.start(sm)
+	}
+	if sm.eventLoop != nil {
+		sm.eventLoop.start(sm)
+	}
+}
+
+// resumeActiveStates arms the timers and starts the do-activities and
+// submachines of the active states. The caller must hold the machine mutex.
+func (sm *StateMachine) resumeActiveStates() {
+	for _, stateID := range sm.getStateHierarchy(sm.currentState) {
+		sm.resumeState(stateID)
+	}
+	for stateID := range sm.activeStates {
+		sm.resumeState(stateID)
+	}
+}
+
+// resumeState arms the timers and starts the do-activity and submachine of
+// an active state
+func (sm *StateMachine) resumeState(stateID string) {
+	sm.armTimers(stateID)
+	sm.startActivity(sm.states[stateID])
+	sm.resumeSubmachine(sm.states[stateID])
+}
+
+// Reset stops the machine and returns it to its initial state as if it was
+// freshly created: context data, active states, region states, join progress
+// and history are discarded, and declared variables get their initial values.
+// Call Start to run it again.
 func (sm *StateMachine) Reset() error {
+	return sm.reset(false)
+}
+
+// ResetKeepingContext resets the machine like Reset but keeps its context data
+func (sm *StateMachine) ResetKeepingContext() error {
+	return sm.reset(true)
+}
+
+// reset discards the runtime configuration, and the context data unless keepContext
+func (sm *StateMachine) reset(keepContext bool) error {
 	sm.mutex.Lock()
-	defer sm.mutex.Unlock()
+	defer sm.unlock()
 
 	previousState := sm.currentState
 	sm.currentState = sm.initialState
 	sm.machineState = MachineStateStopped
+	sm.resumable = false
+	sm.stopAllTimers()
+	sm.stopAllActivities()
+	sm.stopAllSubmachines()
+
+	clear(sm.activeStates)
+	clear(sm.stateHistory)
+	clear(sm.historyTimes)
+	clear(sm.historyExits)
+	clear(sm.parallelRegions)
+	clear(sm.joinTracking)
+	clear(sm.regionStates)
+	sm.historyRestore = nil
+	sm.entryErr = nil
+	sm.activeLimitExceeded = false
 
 	if smCtx, ok := sm.context.(*StateMachineContext); ok {
 		smCtx.updateCurrentState(sm.currentState)
+		if !keepContext {
+			smCtx.clearData()
+		}
+	}
+	if !keepContext {
+		sm.initVars()
 	}
 
 	if previousState != sm.currentState {
@@ -207,15 +496,14 @@ func (sm *StateMachine) Reset() error {
 
 // CurrentState returns the current state
 func (sm *StateMachine) CurrentState() string {
-	sm.mutex.RLock()
-	defer sm.mutex.RUnlock()
-	return sm.currentState
+	return sm.loadView().current
 }
 
-// SetState sets the current state
+// SetState sets the current state without exiting or entering any state;
+// use ForceTransition to move the machine with a consistent configuration
 func (sm *StateMachine) SetState(state string) error {
 	sm.mutex.Lock()
-	defer sm.mutex.Unlock()
+	defer sm.unlock()
 
 	if _, exists := sm.states[state]; !exists {
 		return NewStateNotFoundError(state)
@@ -260,12 +548,104 @@ func (sm *StateMachine) HandleEvent(eventName string, eventData any) *EventResul
 
 // HandleEventWithContext handles an event synchronously with context
 func (sm *StateMachine) HandleEventWithContext(ctx context.Context, eventName string, eventData any) *EventResult {
-	sm.mutex.Lock()
-	defer sm.mutex.Unlock()
+	if sm.eventLoop != nil {
+		select {
+		case result := <-sm.SendEventAsyncWithContext(ctx, eventName, eventData):
+			return result
+		case <-ctx.Done():
+			// The machine may be busy, so the current state is not read here
+			return NewEventResult(false, false, "", "").
+				WithRejection("event cancelled while waiting for the event loop").
+				WithRejectionCode(RejectionCancelled).
+				WithError(ctx.Err())
+		}
+	}
+
+	return sm.handleEvent(ctx, eventName, eventData)
+}
+
+// processEvent runs a single event through transition resolution and execution,
+// recording it in the event log and timeline if it was accepted. The caller must hold the machine mutex.
+func (sm *StateMachine) processEvent(ctx context.Context, eventName string, eventData any) *EventResult {
+	if sm.eventLog == nil && sm.timeline == nil {
+		return sm.resolveEvent(ctx, eventName, eventData)
+	}
+
+	sm.eventDepth++
+	result := func() *EventResult {
+		defer func() { sm.eventDepth-- }()
+		return sm.resolveEvent(ctx, eventName, eventData)
+	}()
+	if sm.eventDepth == 0 && result != nil && result.Processed {
+		sm.recordEvent(eventName, eventData, result)
+	}
+	return result
+}
+
+// resolveEvent runs a single event through transition resolution and execution.
+// The caller must hold the machine mutex.
+func (sm *StateMachine) resolveEvent(ctx context.Context, eventName string, eventData any) *EventResult {
+	outer := sm.trace
+	sm.trace = eventTrace{}
+	defer func() { sm.trace = outer }()
+
+	if smCtx, ok := sm.context.(*StateMachineContext); ok {
+		defer smCtx.bindEventContext(ctx)()
+	}
+
+	result := sm.resolveEventStep(ctx, eventName, eventData)
+	if result != nil && len(sm.trace.regionSteps) > 0 {
+		result.RegionTransitions = sm.trace.regionSteps
+	}
+	if result != nil && result.Panic == nil {
+		result.Panic = sm.trace.panic
+	}
+	return result
+}
+
+// eventTrace records what happens while an event is resolved
+type eventTrace struct {
+	regionSteps   []RegionTransition // Region transitions taken
+	failedGuard   string             // Identity of the first guard that failed
+	panic         *PanicError        // First panic recovered
+	rejectedPanic *PanicError        // Panic rejecting the event under PanicRejectEvent
+}
+
+// cancelledResult rejects an event whose context is done, leaving the machine
+// in the given state, and returns nil while the context is live
+func (sm *StateMachine) cancelledResult(ctx context.Context, event Event, state string) *EventResult {
+	err := ctx.Err()
+	if err == nil {
+		return nil
+	}
+	reason := fmt.Sprintf("transition aborted: %v", err)
+	sm.observers.NotifyEventRejected(event, reason, sm.context)
+	return NewEventResult(false, false, state, state).
+		WithRejection(reason).
+		WithRejectionCode(RejectionCancelled).
+		WithError(err)
+}
+
+// actionFailedResult rejects an event whose transition action failed, leaving
+// the machine in the given state
+func (sm *StateMachine) actionFailedResult(state string, transition *Transition, err error) *EventResult {
+	result := NewEventResult(false, false, state, state).
+		WithRejectionCode(RejectionActionFailed).
+		WithError(err)
+	result.FailedAction = transition.actionIdentity()
+	return result
+}
+
+// resolveEventStep finds and takes the transition for an event.
+// The caller must hold the machine mutex.
+func (sm *StateMachine) resolveEventStep(ctx context.Context, eventName string, eventData any) *EventResult {
+	defer sm.checkActiveStateLimit()
+	defer sm.releaseScopes()
 
 	if sm.machineState != MachineStateStarted {
 		return NewEventResult(false, false, sm.currentState, sm.currentState).
-			WithRejection("machine is not started")
+			WithRejection("machine is not started").
+			WithRejectionCode(RejectionNotStarted)
 	}
 
 	event := NewEvent(eventName, eventData)
@@ -276,106 +656,187 @@ func (sm *StateMachine) HandleEventWithContext(ctx context.Context, eventName st
 		sm.observers.NotifyEventRejected(event, reason, sm.context)
 		return NewEventResult(false, false, sm.currentState, sm.currentState).
 			WithRejection(reason).
+			WithRejectionCode(RejectionInvalidEvent).
 			WithError(errors.New(reason))
 	}
 
+	if err := sm.checkEventData(eventName, eventData); err != nil {
+		sm.observers.NotifyEventRejected(event, err.Error(), sm.context)
+		return NewEventResult(false, false, sm.currentState, sm.currentState).
+			WithRejection(err.Error()).
+			WithRejectionCode(RejectionInvalidEvent).
+			WithError(err)
+	}
+	sm.reportContextSize()
+
+	if result := sm.cancelledResult(ctx, event, sm.currentState); result != nil {
+		return result
+	}
+
 	if smCtx, ok := sm.context.(*StateMachineContext); ok {
 		smCtx.updateCurrentEvent(event)
 	}
 
+	if result := sm.forwardToSubmachines(eventName, eventData); result != nil {
+		return result
+	}
+
 	matchingTransition, sourceStateID, err := sm.findMatchingTransition(eventName, event)
+	if result := sm.panicRejection(event, sm.currentState, nil); result != nil {
+		return result
+	}
 	if err != nil {
+		if IsTransitionError(err) && GetErrorCode(err) == ErrCodeAmbiguousTransition {
+			sm.observers.NotifyEventRejected(event, err.Error(), sm.context)
+			return NewEventResult(false, false, sm.currentState, sm.currentState).
+				WithRejection(err.Error()).
+				WithRejectionCode(RejectionAmbiguous).
+				WithError(err)
+		}
 		reason := fmt.Sprintf("no valid transition found for event '%s' in state '%s'", eventName, sm.currentState)
+		regionReason := sm.completedRegionRejection(eventName)
+		if regionReason != "" {
+			reason = regionReason
+		}
 		sm.observers.NotifyEventRejected(event, reason, sm.context)
-		return NewEventResult(false, false, sm.currentState, sm.currentState).
+		result := NewEventResult(false, false, sm.currentState, sm.currentState).
 			WithRejection(reason).
 			WithError(fmt.Errorf("%s", reason))
+		switch {
+		case regionReason != "" || sm.completed():
+			result.RejectionCode = RejectionTerminated
+		case sm.trace.failedGuard != "":
+			result.RejectionCode = RejectionGuardFailed
+			result.FailedGuard = sm.trace.failedGuard
+		default:
+			result.RejectionCode = RejectionNoTransition
+		}
+		return result
+	}
+
+	// Guards may have run past the event's deadline
+	if result := sm.cancelledResult(ctx, event, sm.currentState); result != nil {
+		return result
 	}
 
 	previousState := sm.currentState
-	targetState := matchingTransition.TargetState
+	// Junction segments are selected before any action of the compound transition runs
+	targetState, junctionActions, err := sm.junctionPath(matchingTransition.TargetState)
+	if err != nil {
+		sm.observers.NotifyEventRejected(event, err.Error(), sm.context)
+		return NewEventResult(false, false, sm.currentState, sm.currentState).
+			WithRejection(err.Error()).
+			WithRejectionCode(RejectionGuardFailed).
+			WithError(err)
+	}
+	if result := sm.vetoedResult(sourceStateID, targetState, matchingTransition, event); result != nil {
+		return result
+	}
 	isRegionTransition := sm.isRegionTransition(sourceStateID, targetState)
 
 	if smCtx, ok := sm.context.(*StateMachineContext); ok {
 		smCtx.updateTransitionInfo(sourceStateID, previousState, targetState, event)
 	}
 
-	if isRegionTransition {
-		// Handle region state transition - update the region's current state but not the machine's current state
-		// Store the target state info before updating to check for completion later
-		var targetRegion Region
-		var isFinalState bool
-		if targetStateObj, exists := sm.states[targetState]; exists {
-			isFinalState = targetStateObj.IsFinal()
-			targetRegion = sm.findRegionForState(targetState)
-		}
+	// A completed region restarts only once a transition from its initial state was selected
+	sm.reinitializeCompletedRegion(sourceStateID, event)
 
-		// Execute transition action BEFORE state change - if it fails, abort transition
+	if matchingTransition.Internal {
+		// Internal transition - run the action without leaving the source state
 		if matchingTransition.Action != nil {
-			// Record action execution regardless of outcome
-			sm.observers.NotifyActionExecution("transition", sourceStateID, event, sm.context)
-			if err := safeExecuteAction(matchingTransition.Action, sm.context); err != nil {
-				reason := fmt.Sprintf("transition action failed: %v", err)
-				sm.observers.NotifyEventRejected(event, reason, sm.context)
-				return NewEventResult(false, false, sourceStateID, sourceStateID).
-					WithError(err)
+			sm.observers.NotifyActionExecution(matchingTransition.actionLabel("internal"), sourceStateID, event, sm.context)
+			err := sm.runAction(matchingTransition.Action)
+			if result := sm.cancelledResult(ctx, event, sourceStateID); result != nil {
+				return result
+			}
+			if result := sm.panicRejection(event, sourceStateID, matchingTransition); result != nil {
+				return result
+			}
+			if err != nil {
+				sm.observers.NotifyEventRejected(event, matchingTransition.actionFailure(err), sm.context)
+				sm.broadcastToRegions(sourceStateID, eventName, event)
+				return sm.actionFailedResult(sourceStateID, matchingTransition, err)
 			}
 		}
+		sm.observers.NotifyInternalTransition(sourceStateID, event, sm.context)
+		sm.broadcastToRegions(sourceStateID, eventName, event)
+		return NewEventResult(true, false, sourceStateID, sourceStateID)
+	}
 
-		sm.updateRegionStateWithoutCompletionCheck(sourceStateID, targetState)
-
-		delete(sm.activeStates, sourceStateID)
-		sm.activeStates[targetState] = true
-
-		if sourceState, exists := sm.states[sourceStateID]; exists {
-			sourceState.Exit(sm.context)
-		}
-
-		if targetStateObj, exists := sm.states[targetState]; exists {
-			targetStateObj.Enter(sm.context)
+	// Execute transition action and the actions of the junction segments
+	// taken BEFORE state change - if one fails, abort the transition or, when
+	// it declares an error state, route there instead
+	var routedErr error
+	actionState := previousState
+	if isRegionTransition {
+		actionState = sourceStateID
+	}
+	actions := junctionActions
+	if matchingTransition.Action != nil {
+		// Record action execution regardless of outcome
+		sm.observers.NotifyActionExecution(matchingTransition.actionLabel("transition"), actionState, event, sm.context)
+		actions = append([]ActionFunc{matchingTransition.Action}, junctionActions...)
+	}
+	for _, action := range actions {
+		err := sm.runAction(action)
+		// An action outliving the event's deadline aborts the transition, even
+		// when it declares an error state
+		if result := sm.cancelledResult(ctx, event, actionState); result != nil {
+			return result
+		}
+		if result := sm.panicRejection(event, actionState, matchingTransition); result != nil {
+			return result
+		}
+		if err == nil {
+			continue
+		}
+		if matchingTransition.ErrorState == "" {
+			sm.observers.NotifyEventRejected(event, matchingTransition.actionFailure(err), sm.context)
+			if isRegionTransition {
+				sm.broadcastToRegions(sourceStateID, eventName, event)
+			}
+			return sm.actionFailedResult(actionState, matchingTransition, err)
 		}
-
-		sm.observers.NotifyStateExit(sourceStateID, sm.context)
-		sm.observers.NotifyTransition(sourceStateID, targetState, event, sm.context)
-		sm.observers.NotifyStateEnter(targetState, sm.context)
-
-		// Check for parallel state completion AFTER action execution
-		if isFinalState && targetRegion != nil {
-			sm.checkParallelStateCompletion(targetRegion.ParentState())
+		routedErr = sm.recordRoutedError(NewActionError(matchingTransition.actionLabel("transition"), actionState, err))
+		targetState = matchingTransition.ErrorState
+		isRegionTransition = sm.isRegionTransition(sourceStateID, targetState)
+		if smCtx, ok := sm.context.(*StateMachineContext); ok {
+			smCtx.updateTransitionInfo(sourceStateID, previousState, targetState, event)
 		}
+		break
+	}
+	sm.entryErr = nil
 
-		return NewEventResult(true, true, sourceStateID, targetState)
+	if isRegionTransition {
+		sm.takeRegionTransition(sourceStateID, targetState, event)
+		result := sm.finishErrorRouting(NewEventResult(true, true, sourceStateID, targetState), matchingTransition, routedErr, event)
+		sm.broadcastToRegions(sourceStateID, eventName, event)
+		return result
 	} else {
-		// Execute transition action BEFORE state change - if it fails, abort transition
-		if matchingTransition.Action != nil {
-			// Record action execution regardless of outcome
-			sm.observers.NotifyActionExecution("transition", previousState, event, sm.context)
-			if err := safeExecuteAction(matchingTransition.Action, sm.context); err != nil {
-				reason := fmt.Sprintf("transition action failed: %v", err)
-				sm.observers.NotifyEventRejected(event, reason, sm.context)
-				return NewEventResult(false, false, previousState, previousState).
-					WithError(err)
-			}
-		}
-
 		// Handle normal state transition - complex hierarchical state change with exit/entry actions and pseudostate processing
-		sm.executeExitActions(previousState, targetState, event)
-
+		// A transition into a descendant of its source is local or external: only an external one exits and re-enters the source
+		intoDescendant := sm.isDescendantOf(targetState, sourceStateID)
+		local := intoDescendant && matchingTransition.Local
+		// The regions of a parallel state are exited before the state itself
 		if prevStateObj, ok := sm.states[previousState]; ok && prevStateObj.IsParallel() {
 			if parallelState, ok := prevStateObj.(ParallelState); ok {
-				for _, region := range parallelState.Regions() {
-					if region.CurrentState() != nil {
-						region.CurrentState().Exit(sm.context)
-						sm.observers.NotifyStateExit(region.CurrentState().ID(), sm.context)
-						delete(sm.activeStates, region.CurrentState().ID())
-						if regionImpl, ok := region.(*RegionImpl); ok {
-							regionImpl.currentState = nil
-						}
-					}
-				}
+				sm.exitParallelRegions(parallelState)
 				delete(sm.activeStates, previousState)
 			}
 		}
+		if intoDescendant {
+			sm.exitWithin(previousState, sourceStateID, !local)
+		} else {
+			sm.executeExitActions(previousState, targetState, event)
+		}
+
+		// Leaving an ancestor also leaves the fork branches still active beneath it
+		for stateID := sourceStateID; stateID != ""; stateID = sm.parentOf(stateID) {
+			if sm.isDescendantOf(targetState, stateID) && (stateID != sourceStateID || local) {
+				break
+			}
+			sm.exitForkBranches(stateID, sourceStateID, previousState)
+		}
 
 		sm.updateStateHistory(previousState)
 
@@ -412,17 +873,26 @@ func (sm *StateMachine) HandleEventWithContext(ctx context.Context, eventName st
 			smCtx.updateCurrentState(sm.currentState)
 		}
 
-		sm.executeEntryActions(previousState, actualTargetState, event)
+		if intoDescendant {
+			sm.enterWithin(sourceStateID, actualTargetState, !local)
+		} else {
+			sm.executeEntryActions(previousState, actualTargetState, event)
+		}
+		sm.enterRegions("")
 
 		if previousState != "" {
-			sm.observers.NotifyStateExit(sourceStateID, sm.context)
+			if !local {
+				sm.observers.NotifyStateExit(sourceStateID, sm.context)
+			} else if previousState != sourceStateID {
+				sm.observers.NotifyStateExit(previousState, sm.context)
+			}
 			sm.observers.NotifyTransition(sourceStateID, actualTargetState, event, sm.context)
 		}
 		sm.observers.NotifyStateEnter(actualTargetState, sm.context)
 
 		// For self-transitions, StateChanged should be true because exit/entry actions are executed
 		stateChanged := previousState != actualTargetState || previousState == targetState
-		return NewEventResult(true, stateChanged, sourceStateID, actualTargetState)
+		return sm.finishErrorRouting(NewEventResult(true, stateChanged, sourceStateID, actualTargetState), matchingTransition, routedErr, event)
 	}
 }
 
@@ -432,17 +902,58 @@ func (sm *StateMachine) executeExitActions(fromState, toState string, _ Event) {
 
 	currentStateID := fromState
 	for currentStateID != "" && currentStateID != commonAncestor {
-		if state, exists := sm.states[currentStateID]; exists {
-			state.Exit(sm.context)
-
-			if state.Parent() != nil {
-				currentStateID = state.Parent().ID()
-			} else {
-				break
-			}
-		} else {
+		state, exists := sm.states[currentStateID]
+		if !exists {
 			break
 		}
+		sm.exitState(state)
+		currentStateID = sm.parentOf(currentStateID)
+	}
+}
+
+// parentOf returns the parent of a state, falling back to its dotted ID prefix
+func (sm *StateMachine) parentOf(stateID string) string {
+	if state, exists := sm.states[stateID]; exists && state.Parent() != nil {
+		return state.Parent().ID()
+	}
+	if parent := StatePath(stateID).Parent(); parent != "" {
+		if _, exists := sm.states[parent.String()]; exists {
+			return parent.String()
+		}
+	}
+	return ""
+}
+
+// exitWithin exits the states from fromState up to an ancestor, exiting the
+// ancestor itself only when inclusive
+func (sm *StateMachine) exitWithin(fromState, ancestorID string, inclusive bool) {
+	for stateID := fromState; stateID != ""; stateID = sm.parentOf(stateID) {
+		if stateID == ancestorID && !inclusive {
+			return
+		}
+		if state, exists := sm.states[stateID]; exists {
+			sm.exitState(state)
+		}
+		if stateID == ancestorID {
+			return
+		}
+	}
+}
+
+// enterWithin enters the states from an ancestor down to toState, entering
+// the ancestor itself only when inclusive
+func (sm *StateMachine) enterWithin(ancestorID, toState string, inclusive bool) {
+	var path []string
+	for stateID := toState; stateID != "" && stateID != ancestorID; stateID = sm.parentOf(stateID) {
+		path = append(path, stateID)
+	}
+	if inclusive {
+		path = append(path, ancestorID)
+	}
+	for i := len(path) - 1; i >= 0; i-- {
+		if state, exists := sm.states[path[i]]; exists {
+			sm.enterState(state)
+		}
 	}
 }
 
@@ -453,11 +964,154 @@ func (sm *StateMachine) executeEntryActions(fromState, toState string, _ Event)
 
 	for _, stateID := range entryPath {
 		if state, exists := sm.states[stateID]; exists {
-			state.Enter(sm.context)
+			sm.enterState(state)
 		}
 	}
 }
 
+// enterState runs the entry action of a state, keeping the first error for
+// OnError routing, arms its timed transitions and
+// starts its do-activity and submachine
+func (sm *StateMachine) enterState(state State) {
+	if !sm.skipActions {
+		sm.runEntryAction(state)
+	}
+	if !sm.dryRun {
+		sm.armTimers(state.ID())
+		sm.startActivity(state)
+		sm.startSubmachine(state)
+	}
+	sm.enterRegions(state.ID())
+}
+
+// regionEntry is a region state activated along with its parallel state
+type regionEntry struct {
+	parallelID string
+	regionID   string
+	stateID    string
+}
+
+// enterRegions enters the region states activated with a parallel state, or
+// every activated region state still waiting when parallelID is empty, which
+// callers activating a configuration do once its entry path is entered
+func (sm *StateMachine) enterRegions(parallelID string) {
+	pending := sm.regionEntries
+	sm.regionEntries = nil
+	for _, entry := range pending {
+		if parallelID != "" && entry.parallelID != parallelID {
+			sm.regionEntries = append(sm.regionEntries, entry)
+			continue
+		}
+		if state, exists := sm.states[entry.stateID]; exists {
+			sm.enterState(state)
+		}
+		sm.observers.NotifyRegionStateEnter(entry.parallelID, entry.regionID, entry.stateID, sm.context)
+	}
+}
+
+// runEntryAction runs the entry action of a state, keeping the first error
+// for OnError routing
+func (sm *StateMachine) runEntryAction(state State) {
+	if atomic := atomicStateOf(state); atomic != nil {
+		// Run the entry action directly to keep its error
+		if atomic.entryAction != nil {
+			if err := sm.runAction(atomic.entryAction); err != nil && sm.entryErr == nil {
+				sm.entryErr = NewActionError("entry", state.ID(), err)
+			}
+		}
+	} else {
+		state.Enter(sm.context)
+	}
+}
+
+// exitState cancels the timed transitions, do-activity and submachine of a
+// state, runs its exit action and removes the context values scoped to it
+func (sm *StateMachine) exitState(state State) {
+	if state.IsComposite() || state.IsParallel() {
+		sm.historyExits[state.ID()]++
+	}
+	sm.cancelTimers(state.ID())
+	sm.cancelActivity(state.ID())
+	sm.stopSubmachine(state.ID())
+	if sm.skipActions {
+		// A forced transition without actions
+	} else if atomic := atomicStateOf(state); atomic != nil {
+		if atomic.exitAction != nil {
+			_ = sm.runAction(atomic.exitAction)
+		}
+	} else {
+		state.Exit(sm.context)
+	}
+
+	if smCtx, ok := sm.context.(*StateMachineContext); ok {
+		smCtx.clearScope(state.ID())
+	}
+}
+
+// releaseScopes removes scoped context values whose owning state was left
+// without running its own exit, such as a composite whose substate was the
+// source of the transition. The caller must hold the machine mutex.
+func (sm *StateMachine) releaseScopes() {
+	smCtx, ok := sm.context.(*StateMachineContext)
+	if !ok || sm.machineState != MachineStateStarted {
+		return
+	}
+	smCtx.releaseScopes(func(state string) bool {
+		if StatePath(state).Contains(StatePath(sm.currentState)) {
+			return true
+		}
+		for active := range sm.activeStates {
+			if StatePath(state).Contains(StatePath(active)) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// exitParallelRegions exits the current state of every region of a parallel state and clears the regions
+func (sm *StateMachine) exitParallelRegions(parallelState ParallelState) {
+	sm.recordRegionHistory(parallelState)
+	for _, region := range parallelState.Regions() {
+		if sm.regionCurrent(region) != nil {
+			sm.exitState(sm.regionCurrent(region))
+			sm.observers.NotifyStateExit(sm.regionCurrent(region).ID(), sm.context)
+			delete(sm.activeStates, sm.regionCurrent(region).ID())
+			sm.setRegionCurrent(region, nil)
+		}
+	}
+}
+
+// exitForkBranches exits the states still active beneath an ancestor, except
+// those the caller is already exiting, and drops their fork and join tracking
+func (sm *StateMachine) exitForkBranches(ancestorID string, exempt ...string) {
+	for _, stateID := range slices.Sorted(maps.Keys(sm.activeStates)) {
+		// Region states are left to the exit of their parallel state
+		if slices.Contains(exempt, stateID) || !sm.isDescendantOf(stateID, ancestorID) || sm.findRegionForState(stateID) != nil {
+			continue
+		}
+		if state, exists := sm.states[stateID]; exists {
+			sm.exitState(state)
+		}
+		sm.observers.NotifyStateExit(stateID, sm.context)
+		delete(sm.activeStates, stateID)
+	}
+
+	beneath := func(stateID string) bool {
+		return sm.isDescendantOf(stateID, ancestorID)
+	}
+	for regionKey, regionStates := range sm.parallelRegions {
+		if slices.ContainsFunc(regionStates, beneath) {
+			delete(sm.parallelRegions, regionKey)
+		}
+	}
+	for _, arrived := range sm.joinTracking {
+		maps.DeleteFunc(arrived, func(stateID string, _ bool) bool {
+			return beneath(stateID)
+		})
+	}
+}
+
 // findCommonAncestor finds the common ancestor of two states
 func (sm *StateMachine) findCommonAncestor(state1, state2 string) string {
 	if state1 == state2 {
@@ -490,15 +1144,14 @@ func (sm *StateMachine) getStateHierarchy(stateID string) []string {
 		return []string{}
 	}
 
-	state, exists := sm.states[stateID]
-	if !exists {
+	if _, exists := sm.states[stateID]; !exists {
 		return []string{stateID}
 	}
 
 	hierarchy := []string{stateID}
 
-	for parent := state.Parent(); parent != nil; parent = parent.Parent() {
-		hierarchy = append([]string{parent.ID()}, hierarchy...)
+	for parent := sm.parentOf(stateID); parent != ""; parent = sm.parentOf(parent) {
+		hierarchy = append([]string{parent}, hierarchy...)
 	}
 
 	return hierarchy
@@ -542,16 +1195,20 @@ func (sm *StateMachine) executeCompositeStateEntry(stateID string, event Event)
 	// Complex parallel state activation - activate all regions and their initial states
 	if parallelState, ok := state.(ParallelState); ok {
 		sm.activeStates[stateID] = true
+		restore := sm.takeHistoryRestore(stateID)
 		for _, region := range parallelState.Regions() {
-			if initialState := region.InitialState(); initialState != nil {
-				if regionImpl, ok := region.(*RegionImpl); ok {
-					regionImpl.currentState = initialState
-					finalState := sm.executeCompositeStateEntry(initialState.ID(), event)
-					sm.activeStates[finalState] = true
-					if regionState, exists := sm.states[finalState]; exists {
-						regionState.Enter(sm.context)
-					}
-				}
+			entryState, finalState := sm.regionEntryState(stateID, region, restore)
+			if entryState == nil {
+				continue
+			}
+			sm.setRegionCurrent(region, entryState)
+			if finalState == "" {
+				finalState = sm.executeCompositeStateEntry(entryState.ID(), event)
+			}
+			// Region states are entered once the parallel state itself is
+			if !sm.activeStates[finalState] {
+				sm.activeStates[finalState] = true
+				sm.regionEntries = append(sm.regionEntries, regionEntry{parallelID: stateID, regionID: region.ID(), stateID: finalState})
 			}
 		}
 	}
@@ -614,10 +1271,8 @@ func (sm *StateMachine) findMatchingTransition(eventName string, event Event) (*
 	// Store the original source state for error reporting
 	sourceStateID := sm.currentState
 
-	// Debug logging for transition resolution
-	// TODO: Consider making this configurable via context or machine configuration
-	// fmt.Printf("[DEBUG] findMatchingTransition: searching for event '%s' from source '%s'\n", eventName, sourceStateID)
-	// fmt.Printf("[DEBUG] Active states: %v\n", sm.activeStates)
+	sm.debugLookup(eventName, sourceStateID)
+	active := slices.Sorted(maps.Keys(sm.activeStates))
 
 	// ====================================================================
 	// PRIORITY 1: ACTIVE REGIONAL STATES (Highest Priority)
@@ -625,32 +1280,30 @@ func (sm *StateMachine) findMatchingTransition(eventName string, event Event) (*
 	// Check transitions from all active regional states within parallel states
 	// This ensures regional transitions are found before parallel state transitions
 	// Regional states have highest priority because they represent the most specific context
-	for activeStateID := range sm.activeStates {
+	// They are visited in sorted order so the region taking the event is deterministic
+	for _, activeStateID := range active {
 		if activeStateID == sm.currentState {
 			continue // Skip the main current state - we'll handle it in the traditional hierarchy
 		}
 
+		if sm.rejectsFromFinal(activeStateID) {
+			continue
+		}
+
 		// Check if this active state is in a parallel region
 		if region := sm.findRegionForState(activeStateID); region != nil {
-			// This is a regional state, check its transitions first
-			transitions := sm.transitions[activeStateID]
-			for _, transition := range transitions {
-				if transition.EventName == eventName {
-					guardPassed := true
-					if transition.Guard != nil {
-						result, err := safeEvaluateGuard(transition.Guard, sm.context)
-						if err != nil {
-							// Guard panicked - skip this transition
-							continue
-						}
-						guardPassed = result
-					}
-					if guardPassed {
-						// fmt.Printf("[DEBUG] PRIORITY 1: Found matching transition from regional state '%s' for event '%s' -> '%s'\n",
-						// 	activeStateID, eventName, transition.TargetState)
-						return &transition, activeStateID, nil
-					}
-				}
+			// This is a regional state, check its transitions first. A completed
+			// region that reinitializes takes the event from its initial state.
+			sourceID := activeStateID
+			if initialID := sm.reinitializationSource(activeStateID, eventName); initialID != "" {
+				sourceID = initialID
+			}
+			transition, err := sm.consult(1, "regional", sourceID, eventName, nil)
+			if err != nil {
+				return nil, "", err
+			}
+			if transition != nil {
+				return transition, sourceID, nil
 			}
 		}
 	}
@@ -660,44 +1313,19 @@ func (sm *StateMachine) findMatchingTransition(eventName string, event Event) (*
 	// ====================================================================
 	// Check transitions from all active states (for Fork parallel execution)
 	// These are states that were activated by Fork pseudostates and are running in parallel
-	for activeStateID := range sm.activeStates {
-		transitions := sm.transitions[activeStateID]
-		for _, transition := range transitions {
-			if transition.EventName == eventName {
-				guardPassed := true
-				if transition.Guard != nil {
-					result, err := safeEvaluateGuard(transition.Guard, sm.context)
-					if err != nil {
-						// Guard panicked - skip this transition
-						continue
-					}
-					guardPassed = result
-				}
-				if guardPassed {
-					// Check if target is a Join pseudostate - if so, verify this is a valid source
-					if targetState, exists := sm.states[transition.TargetState]; exists {
-						if pseudoState, ok := targetState.(*PseudoStateImpl); ok && pseudoState.Kind() == Join {
-							// This transition leads to a Join - verify the source is valid for at least one combination
-							if combinations, hasCombinations := sm.joinConditions[transition.TargetState]; hasCombinations {
-								isValidSource := false
-								for _, combination := range combinations {
-									if slices.Contains(combination, activeStateID) {
-										isValidSource = true
-										break
-									}
-								}
-								if !isValidSource {
-									// This active state is not a valid source for any Join combination - skip this transition
-									continue
-								}
-							}
-						}
-					}
-					// fmt.Printf("[DEBUG] PRIORITY 2: Found matching transition from active state '%s' for event '%s' -> '%s'\n",
-					// 	activeStateID, eventName, transition.TargetState)
-					return &transition, activeStateID, nil
-				}
-			}
+	for _, activeStateID := range active {
+		if sm.rejectsFromFinal(activeStateID) {
+			continue
+		}
+		transition, err := sm.consult(2, "active", activeStateID, eventName, func(transition Transition) bool {
+			// A transition into a Join is only valid from one of its sources
+			return sm.validJoinSource(activeStateID, transition.TargetState)
+		})
+		if err != nil {
+			return nil, "", err
+		}
+		if transition != nil {
+			return transition, activeStateID, nil
 		}
 	}
 
@@ -707,30 +1335,19 @@ func (sm *StateMachine) findMatchingTransition(eventName string, event Event) (*
 	// Check transitions from parallel state parent states when event is from a region
 	// This ensures events from regions can bubble up to parent parallel states
 	// This handles the case where a region state wants to transition the entire parallel state
-	for activeStateID := range sm.activeStates {
+	for _, activeStateID := range active {
 		if _, exists := sm.states[activeStateID]; exists {
 			// Check if this active state is in a parallel region
 			if region := sm.findRegionForState(activeStateID); region != nil {
 				parallelStateID := region.ParentState().ID()
 				// Check transitions defined at the parallel state level
-				if parallelTransitions, hasParallelTransitions := sm.transitions[parallelStateID]; hasParallelTransitions {
-					for _, transition := range parallelTransitions {
-						if transition.EventName == eventName {
-							guardPassed := true
-							if transition.Guard != nil {
-								result, err := safeEvaluateGuard(transition.Guard, sm.context)
-								if err != nil {
-									// Guard panicked - skip this transition
-									continue
-								}
-								guardPassed = result
-							}
-							if guardPassed {
-								// fmt.Printf("[DEBUG] PRIORITY 3: Found matching transition from parallel state '%s' for region event '%s' -> '%s'\n",
-								// 	parallelStateID, eventName, transition.TargetState)
-								return &transition, parallelStateID, nil
-							}
-						}
+				if _, hasParallelTransitions := sm.transitions[parallelStateID]; hasParallelTransitions {
+					transition, err := sm.consult(3, "parallel", parallelStateID, eventName, nil)
+					if err != nil {
+						return nil, "", err
+					}
+					if transition != nil {
+						return transition, parallelStateID, nil
 					}
 				}
 			}
@@ -744,31 +1361,20 @@ func (sm *StateMachine) findMatchingTransition(eventName string, event Event) (*
 	// This handles complex parallel hierarchies where events need to bubble up through multiple levels
 	// For example: Region1 -> ParallelStateA -> ParallelStateB -> Root
 	// Events from Region1 can bubble up to ParallelStateA, then ParallelStateB
-	for activeStateID := range sm.activeStates {
+	for _, activeStateID := range active {
 		if state, exists := sm.states[activeStateID]; exists {
 			// Walk up the parent chain to find parallel states
 			currentParent := state.Parent()
 			for currentParent != nil {
 				if currentParent.IsParallel() {
 					// Check transitions at this parallel state level
-					if parallelTransitions, hasParallelTransitions := sm.transitions[currentParent.ID()]; hasParallelTransitions {
-						for _, transition := range parallelTransitions {
-							if transition.EventName == eventName {
-								guardPassed := true
-								if transition.Guard != nil {
-									result, err := safeEvaluateGuard(transition.Guard, sm.context)
-									if err != nil {
-										// Guard panicked - skip this transition
-										continue
-									}
-									guardPassed = result
-								}
-								if guardPassed {
-									// fmt.Printf("[DEBUG] PRIORITY 4: Found matching transition from parent parallel state '%s' for bubbled event '%s' -> '%s'\n",
-									// 	currentParent.ID(), eventName, transition.TargetState)
-									return &transition, currentParent.ID(), nil
-								}
-							}
+					if _, hasParallelTransitions := sm.transitions[currentParent.ID()]; hasParallelTransitions {
+						transition, err := sm.consult(4, "parent-parallel", currentParent.ID(), eventName, nil)
+						if err != nil {
+							return nil, "", err
+						}
+						if transition != nil {
+							return transition, currentParent.ID(), nil
 						}
 					}
 				}
@@ -791,47 +1397,23 @@ func (sm *StateMachine) findMatchingTransition(eventName string, event Event) (*
 				// Don't process join pseudostates in normal event routing
 			} else {
 				// Check transitions from the current state in the hierarchy
-				transitions := sm.transitions[currentStateID]
-				for _, transition := range transitions {
-					if transition.EventName == eventName {
-						guardPassed := true
-						if transition.Guard != nil {
-							result, err := safeEvaluateGuard(transition.Guard, sm.context)
-							if err != nil {
-								// Guard panicked - skip this transition
-								continue
-							}
-							guardPassed = result
-						}
-						if guardPassed {
-							// fmt.Printf("[DEBUG] PRIORITY 5: Found matching transition from hierarchical state '%s' for event '%s' -> '%s'\n",
-							// 	currentStateID, eventName, transition.TargetState)
-							return &transition, currentStateID, nil
-						}
-					}
+				transition, err := sm.consult(5, "hierarchical", currentStateID, eventName, nil)
+				if err != nil {
+					return nil, "", err
+				}
+				if transition != nil {
+					return transition, currentStateID, nil
 				}
 			}
 		} else {
 			// Handle transitions from states that might not be in the states map
 			// This can happen with pseudostates or other special states
-			transitions := sm.transitions[currentStateID]
-			for _, transition := range transitions {
-				if transition.EventName == eventName {
-					guardPassed := true
-					if transition.Guard != nil {
-						result, err := safeEvaluateGuard(transition.Guard, sm.context)
-						if err != nil {
-							// Guard panicked - skip this transition
-							continue
-						}
-						guardPassed = result
-					}
-					if guardPassed {
-						// fmt.Printf("[DEBUG] PRIORITY 5: Found matching transition from non-cataloged state '%s' for event '%s' -> '%s'\n",
-						// 	currentStateID, eventName, transition.TargetState)
-						return &transition, currentStateID, nil
-					}
-				}
+			transition, err := sm.consult(5, "non-cataloged", currentStateID, eventName, nil)
+			if err != nil {
+				return nil, "", err
+			}
+			if transition != nil {
+				return transition, currentStateID, nil
 			}
 		}
 
@@ -840,26 +1422,14 @@ func (sm *StateMachine) findMatchingTransition(eventName string, event Event) (*
 		if state, exists := sm.states[currentStateID]; exists && state.IsParallel() {
 			if parallelState, ok := state.(ParallelState); ok {
 				for _, region := range parallelState.Regions() {
-					if region.CurrentState() != nil {
-						regionStateID := region.CurrentState().ID()
-						regionTransitions := sm.transitions[regionStateID]
-						for _, transition := range regionTransitions {
-							if transition.EventName == eventName {
-								guardPassed := true
-								if transition.Guard != nil {
-									result, err := safeEvaluateGuard(transition.Guard, sm.context)
-									if err != nil {
-										// Guard panicked - skip this transition
-										continue
-									}
-									guardPassed = result
-								}
-								if guardPassed {
-									// fmt.Printf("[DEBUG] PRIORITY 5: Found matching transition from parallel region state '%s' for event '%s' -> '%s'\n",
-									// 	regionStateID, eventName, transition.TargetState)
-									return &transition, regionStateID, nil
-								}
-							}
+					if sm.regionCurrent(region) != nil && !sm.rejectsFromFinal(sm.regionCurrent(region).ID()) {
+						regionStateID := sm.regionCurrent(region).ID()
+						transition, err := sm.consult(5, "parallel-region", regionStateID, eventName, nil)
+						if err != nil {
+							return nil, "", err
+						}
+						if transition != nil {
+							return transition, regionStateID, nil
 						}
 					}
 				}
@@ -867,15 +1437,10 @@ func (sm *StateMachine) findMatchingTransition(eventName string, event Event) (*
 		}
 
 		// Move up to the parent state in the hierarchy
-		if state, exists := sm.states[currentStateID]; exists {
-			if state.Parent() != nil {
-				currentStateID = state.Parent().ID()
-			} else {
-				break // Reached the top of the hierarchy
-			}
-		} else {
+		if _, exists := sm.states[currentStateID]; !exists {
 			break // State not found, exit the loop
 		}
+		currentStateID = sm.parentOf(currentStateID)
 	}
 
 	// ====================================================================
@@ -907,6 +1472,16 @@ func (sm *StateMachine) executePseudoStateWithSource(stateID string, event Event
 		return stateID, nil // Cannot process this pseudostate type
 	}
 
+	// Pseudostates leading back to themselves without an event would recurse forever
+	if sm.pseudoPath[stateID] {
+		return "", NewTransitionError(ErrCodeTransitionNotAllowed, stateID, "", "", fmt.Sprintf("pseudostate '%s' is part of a cycle without an event", stateID))
+	}
+	if sm.pseudoPath == nil {
+		sm.pseudoPath = make(map[string]bool)
+	}
+	sm.pseudoPath[stateID] = true
+	defer delete(sm.pseudoPath, stateID)
+
 	switch pseudoState.Kind() {
 	case Choice:
 		return sm.executeChoicePseudoState(pseudoImpl, event)
@@ -920,86 +1495,167 @@ func (sm *StateMachine) executePseudoStateWithSource(stateID string, event Event
 		return sm.executeHistoryPseudoState(pseudoImpl, event, false)
 	case DeepHistory:
 		return sm.executeHistoryPseudoState(pseudoImpl, event, true)
+	case EntryPoint, ExitPoint:
+		return sm.executeConnectionPointPseudoState(pseudoImpl, event)
 	default:
 		return stateID, nil // Unknown pseudostate, treat as normal state
 	}
 }
 
-// executeChoicePseudoState processes a choice pseudostate by evaluating conditions
+// executeChoicePseudoState processes a choice pseudostate by evaluating
+// conditions. A failing branch action is routed like a failing entry action.
 func (sm *StateMachine) executeChoicePseudoState(pseudoState *PseudoStateImpl, event Event) (string, error) {
+	target, action, err := sm.choiceBranch(pseudoState)
+	if err != nil {
+		sm.observers.NotifyError(err, sm.context)
+		return "", err
+	}
+	if action != nil {
+		if err := sm.runAction(action); err != nil && sm.entryErr == nil {
+			sm.entryErr = NewActionError("choice", pseudoState.ID(), err)
+		}
+	}
+	return sm.resolvePseudoStateTarget(target, event)
+}
+
+// choiceBranch selects the branch of a choice: the first condition whose
+// guard passes, or without conditions the first passing transition leaving
+// the choice, else the default target, else the choice fallback. It returns
+// the target and action of the branch.
+func (sm *StateMachine) choiceBranch(pseudoState *PseudoStateImpl) (string, ActionFunc, error) {
 	if len(pseudoState.choiceConditions) > 0 {
 		for _, condition := range pseudoState.choiceConditions {
-			guardPassed := true
 			if condition.Guard != nil {
-				result, err := safeEvaluateGuard(condition.Guard, sm.context)
-				if err != nil {
-					// Guard panicked - skip this condition
+				passed, err := sm.evaluateGuard(condition.Guard, 0)
+				if err != nil || !passed {
+					// A guard that panicked or timed out skips its condition
 					continue
 				}
-				guardPassed = result
 			}
-			if guardPassed {
-				if condition.Action != nil {
-					_ = safeExecuteAction(condition.Action, sm.context)
-				}
-				return sm.resolvePseudoStateTarget(condition.Target, event)
+			target := condition.target(sm.context)
+			if _, exists := sm.states[target]; !exists {
+				return "", nil, NewTransitionError(ErrCodeTransitionNotAllowed, pseudoState.ID(), target, "", fmt.Sprintf("choice state '%s' computed unknown target '%s'", pseudoState.ID(), target))
 			}
+			return target, condition.Action, nil
 		}
 	} else {
-		transitions, exists := sm.transitions[pseudoState.ID()]
-		if exists && len(transitions) > 0 {
-			for _, transition := range transitions {
-				guardPassed := true
-				if transition.Guard != nil {
-					result, err := safeEvaluateGuard(transition.Guard, sm.context)
-					if err != nil {
-						// Guard panicked - skip this transition
-						continue
-					}
-					guardPassed = result
-				}
-				if guardPassed {
-					if transition.Action != nil {
-						_ = safeExecuteAction(transition.Action, sm.context)
-					}
-					return sm.resolvePseudoStateTarget(transition.TargetState, event)
+		for _, transition := range sm.transitions[pseudoState.ID()] {
+			if transition.Guard != nil {
+				passed, err := sm.evaluateGuard(transition.Guard, transition.GuardTimeout)
+				if err != nil || !passed {
+					continue
 				}
 			}
+			return transition.TargetState, transition.Action, nil
 		}
 	}
 
 	if pseudoState.defaultTarget != "" {
-		return sm.resolvePseudoStateTarget(pseudoState.defaultTarget, event)
+		return pseudoState.defaultTarget, nil, nil
+	}
+
+	if sm.choiceFallback != nil && !sm.dryRun {
+		target, err := sm.choiceFallback(pseudoState.ID(), sm.context)
+		if err != nil {
+			return "", nil, err
+		}
+		if target != "" {
+			if _, exists := sm.states[target]; !exists {
+				return "", nil, NewTransitionError(ErrCodeTransitionNotAllowed, pseudoState.ID(), target, "", fmt.Sprintf("choice fallback for '%s' returned unknown target '%s'", pseudoState.ID(), target))
+			}
+			return target, nil, nil
+		}
 	}
 
-	return "", NewTransitionError(ErrCodeTransitionNotAllowed, pseudoState.ID(), "", "", fmt.Sprintf("no valid transition from choice state '%s'", pseudoState.ID()))
+	return "", nil, NewTransitionError(ErrCodeTransitionNotAllowed, pseudoState.ID(), "", "", fmt.Sprintf("no valid transition from choice state '%s'", pseudoState.ID()))
 }
 
-// executeJunctionPseudoState processes a junction pseudostate by evaluating outgoing transitions
+// executeJunctionPseudoState processes a junction pseudostate by evaluating
+// outgoing transitions. A failing segment action is routed like a failing
+// entry action.
 func (sm *StateMachine) executeJunctionPseudoState(pseudoState *PseudoStateImpl, event Event) (string, error) {
-	if pseudoState.defaultTarget != "" {
-		return sm.resolvePseudoStateTarget(pseudoState.defaultTarget, event)
+	target, action, ok := sm.junctionSegment(pseudoState)
+	if !ok {
+		return "", &TransitionError{Code: ErrCodeTransitionNotAllowed, From: pseudoState.ID(), Event: "", Reason: fmt.Sprintf("no valid transition from junction state '%s'", pseudoState.ID())}
+	}
+	if action != nil {
+		if err := sm.runAction(action); err != nil && sm.entryErr == nil {
+			sm.entryErr = NewActionError("junction", pseudoState.ID(), err)
+		}
 	}
+	return sm.resolvePseudoStateTarget(target, event)
+}
 
-	transitions, exists := sm.transitions[pseudoState.ID()]
-	if exists && len(transitions) > 0 {
-		for _, transition := range transitions {
-			guardPassed := true
-			if transition.Guard != nil {
-				result, err := safeEvaluateGuard(transition.Guard, sm.context)
-				if err != nil {
-					// Guard panicked - skip this transition
-					continue
-				}
-				guardPassed = result
+// junctionSegment selects the outgoing segment of a junction: the first
+// guarded segment whose guard passes, else the unguarded default, else the
+// first passing transition leaving the junction
+func (sm *StateMachine) junctionSegment(pseudoState *PseudoStateImpl) (string, ActionFunc, bool) {
+	for _, condition := range pseudoState.choiceConditions {
+		if condition.Guard != nil {
+			passed, err := sm.evaluateGuard(condition.Guard, 0)
+			if err != nil || !passed {
+				// A guard that panicked or timed out disables its segment
+				continue
 			}
-			if guardPassed {
-				return sm.resolvePseudoStateTarget(transition.TargetState, event)
+		}
+		return condition.target(sm.context), condition.Action, true
+	}
+
+	if pseudoState.defaultTarget != "" {
+		return pseudoState.defaultTarget, nil, true
+	}
+
+	for _, transition := range sm.transitions[pseudoState.ID()] {
+		if transition.Guard != nil {
+			passed, err := sm.evaluateGuard(transition.Guard, transition.GuardTimeout)
+			if err != nil || !passed {
+				continue
 			}
 		}
+		return transition.TargetState, transition.Action, true
+	}
+	return "", nil, false
+}
+
+// junctionPath follows the junctions a transition targets, selecting their
+// segments statically: every guard is evaluated before any action of the
+// compound transition runs. It returns the state the compound transition
+// ends in and the actions of the segments taken, in order.
+func (sm *StateMachine) junctionPath(target string) (string, []ActionFunc, error) {
+	var actions []ActionFunc
+	visited := make(map[string]bool)
+	for {
+		junction, ok := sm.states[target].(*PseudoStateImpl)
+		if !ok || junction.Kind() != Junction {
+			return target, actions, nil
+		}
+		if visited[target] {
+			return "", nil, NewTransitionError(ErrCodeTransitionNotAllowed, target, "", "", fmt.Sprintf("junction state '%s' is part of a cycle", target))
+		}
+		visited[target] = true
+
+		next, action, ok := sm.junctionSegment(junction)
+		if !ok {
+			return "", nil, NewTransitionError(ErrCodeTransitionNotAllowed, target, "", "", fmt.Sprintf("no valid transition from junction state '%s'", target))
+		}
+		if action != nil {
+			actions = append(actions, action)
+		}
+		target = next
+	}
+}
+
+// executeConnectionPointPseudoState passes through an entry or exit point to
+// its target, running the action of the connection point on the way
+func (sm *StateMachine) executeConnectionPointPseudoState(pseudoState *PseudoStateImpl, event Event) (string, error) {
+	if pseudoState.defaultTarget != "" {
+		if !sm.skipActions {
+			pseudoState.Enter(sm.context)
+		}
+		return sm.resolvePseudoStateTarget(pseudoState.defaultTarget, event)
 	}
 
-	return "", &TransitionError{Code: ErrCodeTransitionNotAllowed, From: pseudoState.ID(), Event: "", Reason: fmt.Sprintf("no valid transition from junction state '%s'", pseudoState.ID())}
+	return "", NewTransitionError(ErrCodeTransitionNotAllowed, pseudoState.ID(), "", "", fmt.Sprintf("connection point '%s' has no target", pseudoState.ID()))
 }
 
 // executeForkPseudoState processes a fork pseudostate (activates parallel regions)
@@ -1014,8 +1670,16 @@ func (sm *StateMachine) executeForkPseudoState(pseudoState *PseudoStateImpl, eve
 
 			sm.activeStates[resolvedTarget] = true
 
+			branch := pseudoState.forkBranch(target)
+			if branch.Action != nil && !sm.skipActions {
+				if err := sm.runAction(branch.Action); err != nil && sm.entryErr == nil {
+					sm.entryErr = NewActionError(branch.Label, pseudoState.ID(), err)
+				}
+			}
+			sm.observers.NotifyForkBranch(pseudoState.ID(), branch.Label, resolvedTarget, sm.context)
+
 			if targetState := sm.states[resolvedTarget]; targetState != nil {
-				targetState.Enter(sm.context)
+				sm.enterState(targetState)
 				sm.observers.NotifyStateEnter(resolvedTarget, sm.context)
 			}
 
@@ -1045,9 +1709,10 @@ func (sm *StateMachine) executeForkPseudoState(pseudoState *PseudoStateImpl, eve
 
 			targetStates = append(targetStates, resolvedTarget)
 			sm.activeStates[resolvedTarget] = true
+			sm.observers.NotifyForkBranch(pseudoState.ID(), resolvedTarget, resolvedTarget, sm.context)
 
 			if targetState := sm.states[resolvedTarget]; targetState != nil {
-				targetState.Enter(sm.context)
+				sm.enterState(targetState)
 				sm.observers.NotifyStateEnter(resolvedTarget, sm.context)
 			}
 
@@ -1062,7 +1727,7 @@ func (sm *StateMachine) executeForkPseudoState(pseudoState *PseudoStateImpl, eve
 		return primaryTarget, nil
 	}
 
-	return "", NewConfigurationError("ForkState", fmt.Sprintf("no outgoing transitions from fork state '%s'", pseudoState.ID()))
+	return "", sm.configurationError("ForkState", fmt.Sprintf("no outgoing transitions from fork state '%s'", pseudoState.ID()))
 }
 
 // executeJoinPseudoStateWithSource processes a join pseudostate with explicit source state
@@ -1089,7 +1754,7 @@ func (sm *StateMachine) executeJoinPseudoStateWithSource(pseudoState *PseudoStat
 
 		if fromState != "" {
 			sm.joinTracking[joinStateID][fromState] = true
-			// fmt.Printf("[DEBUG] Join '%s': marked '%s' as arrived. Tracking: %v\n", joinStateID, fromState, sm.joinTracking[joinStateID])
+			sm.debug(DebugTrace, "fluo: join source arrived", "join", joinStateID, "source", fromState)
 		}
 
 		// Check if any combination is satisfied
@@ -1106,11 +1771,10 @@ func (sm *StateMachine) executeJoinPseudoStateWithSource(pseudoState *PseudoStat
 			if combinationReady {
 				allSourcesReady = true
 				readyCombination = combination
-				// fmt.Printf("[DEBUG] Join '%s': combination %v is ready!\n", joinStateID, combination)
 				break
 			}
 		}
-		// fmt.Printf("[DEBUG] Join '%s': allSourcesReady = %v\n", joinStateID, allSourcesReady)
+		sm.debug(DebugTrace, "fluo: join evaluated", "join", joinStateID, "ready", allSourcesReady, "combination", readyCombination)
 
 		if !allSourcesReady {
 			// Join is not ready yet - stay in source state
@@ -1159,11 +1823,15 @@ func (sm *StateMachine) executeJoinPseudoStateWithSource(pseudoState *PseudoStat
 		return sm.resolvePseudoStateTarget(transition.TargetState, event)
 	}
 
-	return "", NewConfigurationError("JoinState", fmt.Sprintf("no outgoing transitions from join state '%s'", joinStateID))
+	return "", sm.configurationError("JoinState", fmt.Sprintf("no outgoing transitions from join state '%s'", joinStateID))
 }
 
 // executeHistoryPseudoState processes a history pseudostate
 func (sm *StateMachine) executeHistoryPseudoState(pseudoState *PseudoStateImpl, event Event, deep bool) (string, error) {
+	if parallelState, ok := sm.states[sm.parentOf(pseudoState.ID())].(ParallelState); ok {
+		return sm.executeParallelHistory(parallelState, pseudoState, event, deep)
+	}
+
 	parentID := sm.getHistoryParentID(pseudoState)
 
 	if parentID == "" {
@@ -1172,6 +1840,7 @@ func (sm *StateMachine) executeHistoryPseudoState(pseudoState *PseudoStateImpl,
 			transition := transitions[0]
 			targetState := transition.TargetState
 
+			sm.expireHistory(pseudoState, targetState)
 			if historicalState, exists := sm.stateHistory[targetState]; exists && historicalState != "" {
 				if deep {
 					return historicalState, nil
@@ -1185,6 +1854,7 @@ func (sm *StateMachine) executeHistoryPseudoState(pseudoState *PseudoStateImpl,
 		return sm.useHistoryDefault(pseudoState, event)
 	}
 
+	sm.expireHistory(pseudoState, parentID)
 	if historicalState, exists := sm.stateHistory[parentID]; exists && historicalState != "" {
 		if deep {
 			return historicalState, nil
@@ -1202,12 +1872,93 @@ func (sm *StateMachine) executeHistoryPseudoState(pseudoState *PseudoStateImpl,
 	return sm.useHistoryDefault(pseudoState, event)
 }
 
+// historyRestore asks the next entry of a parallel state to restore the
+// recorded configuration of its regions
+type historyRestore struct {
+	parallelID string
+	deep       bool
+}
+
+// executeParallelHistory processes a history pseudostate of a parallel state.
+// With recorded region history it targets the parallel state and has its entry
+// restore every region; otherwise it follows the default or enters the regions
+// at their initial states.
+func (sm *StateMachine) executeParallelHistory(parallelState ParallelState, pseudoState *PseudoStateImpl, event Event, deep bool) (string, error) {
+	sm.expireHistory(pseudoState, parallelState.ID())
+	for _, region := range parallelState.Regions() {
+		if sm.stateHistory[regionHistoryKey(parallelState.ID(), region)] != "" {
+			sm.historyRestore = &historyRestore{parallelID: parallelState.ID(), deep: deep}
+			return parallelState.ID(), nil
+		}
+	}
+
+	if transitions := sm.transitions[pseudoState.ID()]; len(transitions) > 0 {
+		return sm.resolvePseudoStateTarget(transitions[0].TargetState, event)
+	}
+	if pseudoState.historyDefault != "" {
+		return sm.useHistoryDefault(pseudoState, event)
+	}
+	return parallelState.ID(), nil
+}
+
+// takeHistoryRestore returns and clears the pending history restore of a parallel state
+func (sm *StateMachine) takeHistoryRestore(parallelID string) *historyRestore {
+	restore := sm.historyRestore
+	if restore == nil || restore.parallelID != parallelID {
+		return nil
+	}
+	sm.historyRestore = nil
+	return restore
+}
+
+// regionEntryState returns the state a region is entered in: its initial state,
+// or its recorded state when restoring history. A deep restore also returns the
+// innermost recorded state, which is entered directly.
+func (sm *StateMachine) regionEntryState(parallelID string, region Region, restore *historyRestore) (State, string) {
+	if restore != nil {
+		if recorded := sm.stateHistory[regionHistoryKey(parallelID, region)]; recorded != "" {
+			for _, regionState := range region.States() {
+				if regionState.ID() != recorded && !sm.isDescendantOf(recorded, regionState.ID()) {
+					continue
+				}
+				if restore.deep {
+					return regionState, recorded
+				}
+				return regionState, ""
+			}
+		}
+	}
+	return region.InitialState(), ""
+}
+
+// recordRegionHistory records the innermost active state of every region of a
+// parallel state that is being exited
+func (sm *StateMachine) recordRegionHistory(parallelState ParallelState) {
+	for _, region := range parallelState.Regions() {
+		current := sm.regionCurrent(region)
+		if current == nil {
+			continue
+		}
+		innermost := current.ID()
+		for _, stateID := range slices.Sorted(maps.Keys(sm.activeStates)) {
+			if sm.isDescendantOf(stateID, innermost) {
+				innermost = stateID
+			}
+		}
+		key := regionHistoryKey(parallelState.ID(), region)
+		sm.stateHistory[key] = innermost
+		sm.historyTimes[key] = time.Now()
+	}
+}
+
+// regionHistoryKey is the stateHistory key of a region
+func regionHistoryKey(parallelID string, region Region) string {
+	return parallelID + "." + region.ID()
+}
+
 // getHistoryParentID returns the parent state ID of a history pseudostate
 func (sm *StateMachine) getHistoryParentID(pseudoState *PseudoStateImpl) string {
-	if pseudoState.Parent() != nil {
-		return pseudoState.Parent().ID()
-	}
-	return ""
+	return sm.parentOf(pseudoState.ID())
 }
 
 // useHistoryDefault handles the fallback to default history state
@@ -1220,17 +1971,19 @@ func (sm *StateMachine) useHistoryDefault(pseudoState *PseudoStateImpl, event Ev
 
 // getImmediateSubstate extracts the immediate substate for shallow history
 func (sm *StateMachine) getImmediateSubstate(parentID string, lastState string) (string, error) {
-	parts := sm.splitStatePath(lastState)
-	if len(parts) > 1 {
-		// Find the immediate child of the parentID
-		for i, part := range parts {
-			if part == parentID && i+1 < len(parts) {
-				return parts[i+1], nil
-			}
+	return sm.immediateSubstate(parentID, lastState), nil
+}
+
+// immediateSubstate returns the direct substate of a parent that contains
+// the last active state, or the last active state itself when it is not
+// nested in the parent
+func (sm *StateMachine) immediateSubstate(parentID string, lastState string) string {
+	for stateID := lastState; stateID != ""; stateID = sm.parentOf(stateID) {
+		if sm.parentOf(stateID) == parentID {
+			return stateID
 		}
 	}
-	// If we can't extract a substate, just return the full path
-	return lastState, nil
+	return lastState
 }
 
 // resolvePseudoStateTarget resolves a target state ID, handling nested pseudostates
@@ -1248,55 +2001,139 @@ func (sm *StateMachine) resolvePseudoStateTarget(targetStateID string, event Eve
 
 // SetRegionState sets the state of a specific region in a parallel state
 func (sm *StateMachine) SetRegionState(regionID string, stateID string) error {
+	return sm.SetRegionStates(map[string]string{regionID: stateID})
+}
+
+// SetRegionStates sets the current state of several regions at once. Regions
+// are named by ID or by path "<parallel state>.<region>". Every assignment is
+// validated before any is applied. Like SetConfiguration no entry or exit
+// actions run, but timers, do-activities and submachines follow the states.
+func (sm *StateMachine) SetRegionStates(states map[string]string) error {
 	sm.mutex.Lock()
-	defer sm.mutex.Unlock()
+	defer sm.unlock()
 
-	// Find the region
-	for _, state := range sm.states {
-		if parallelState, ok := state.(ParallelState); ok {
-			for _, region := range parallelState.Regions() {
-				if region.ID() == regionID {
-					// Find the target state in the region
-					for _, regionState := range region.States() {
-						if regionState.ID() == stateID {
-							// Set the region's current state
-							if regionImpl, ok := region.(*RegionImpl); ok {
-								regionImpl.currentState = regionState
-								return nil
-							}
-						}
-					}
-					return &StateError{Code: ErrCodeStateNotFound, StateID: stateID, Message: fmt.Sprintf("state '%s' not found in region '%s'", stateID, regionID)}
-				}
-			}
+	regions := make(map[Region]string, len(states))
+	for _, regionID := range slices.Sorted(maps.Keys(states)) {
+		region := sm.lookupRegion(regionID)
+		if region == nil {
+			return NewStateNotFoundError(regionID)
+		}
+		stateID := states[regionID]
+		if !sm.regionContainsState(region, stateID) {
+			return &StateError{Code: ErrCodeStateNotFound, StateID: stateID, Message: fmt.Sprintf("state '%s' not found in region '%s'", stateID, regionID)}
+		}
+		regions[region] = stateID
+	}
+
+	for region, stateID := range regions {
+		if previous := sm.regionCurrent(region); previous != nil {
+			sm.cancelTimers(previous.ID())
+			sm.cancelActivity(previous.ID())
+			sm.stopSubmachine(previous.ID())
+			delete(sm.activeStates, previous.ID())
+		}
+
+		state := sm.states[stateID]
+		sm.setRegionCurrent(region, state)
+		if !sm.activeStates[region.ParentState().ID()] {
+			continue
+		}
+		sm.activeStates[stateID] = true
+		if sm.machineState == MachineStateStarted {
+			sm.armTimers(stateID)
+			sm.startActivity(state)
+			sm.startSubmachine(state)
 		}
 	}
 
-	return NewStateNotFoundError(regionID)
+	return nil
 }
 
-// RegionState returns the current state of a specific region
+// RegionState returns the current state of a region, named by ID or by path
+// "<parallel state>.<region>"
 func (sm *StateMachine) RegionState(regionID string) string {
 	sm.mutex.RLock()
 	defer sm.mutex.RUnlock()
 
-	// Find the region
-	for _, state := range sm.states {
-		if parallelState, ok := state.(ParallelState); ok {
-			for _, region := range parallelState.Regions() {
-				if region.ID() == regionID {
-					if region.CurrentState() != nil {
-						return region.CurrentState().ID()
-					}
-					return ""
-				}
-			}
+	if region := sm.lookupRegion(regionID); region != nil {
+		if current := sm.regionCurrent(region); current != nil {
+			return current.ID()
 		}
 	}
-
 	return ""
 }
 
+// RegionOf returns the ID of the region a state belongs to
+func (sm *StateMachine) RegionOf(stateID string) (string, bool) {
+	sm.mutex.RLock()
+	defer sm.mutex.RUnlock()
+
+	if region := sm.findRegionForState(stateID); region != nil {
+		return region.ID(), true
+	}
+	return "", false
+}
+
+// RegionCompleted reports whether a region is in a final state. The region is
+// named by its ID or by its path "<parallel state>.<region>".
+func (sm *StateMachine) RegionCompleted(regionID string) bool {
+	sm.mutex.RLock()
+	defer sm.mutex.RUnlock()
+
+	if region := sm.lookupRegion(regionID); region != nil {
+		return sm.isRegionComplete(region)
+	}
+	return false
+}
+
+// IsCompleted reports whether the machine has reached a top-level final
+// state, or a top-level parallel state whose regions have all finished. Final
+// states nested in composites or regions only complete their enclosing state.
+func (sm *StateMachine) IsCompleted() bool {
+	sm.mutex.RLock()
+	defer sm.mutex.RUnlock()
+
+	return sm.completed()
+}
+
+// completed reports whether the machine has reached a top-level final
+// configuration. The caller must hold the machine mutex.
+func (sm *StateMachine) completed() bool {
+	return sm.inFinalState() && sm.parentOf(sm.currentState) == ""
+}
+
+// IsInFinalState reports whether the current state is a final state at any
+// level, or a parallel state whose regions have all reached final states
+func (sm *StateMachine) IsInFinalState() bool {
+	sm.mutex.RLock()
+	defer sm.mutex.RUnlock()
+
+	return sm.inFinalState()
+}
+
+// inFinalState reports whether the current state is final or a completed parallel state
+func (sm *StateMachine) inFinalState() bool {
+	if sm.machineState != MachineStateStarted {
+		return false
+	}
+	state, exists := sm.states[sm.currentState]
+	if !exists {
+		return false
+	}
+	if state.IsFinal() {
+		return true
+	}
+	if parallelState, ok := state.(ParallelState); ok && len(parallelState.Regions()) > 0 {
+		for _, region := range parallelState.Regions() {
+			if !sm.isRegionComplete(region) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
 // GetStateHierarchy returns the full hierarchical path of the current state
 func (sm *StateMachine) GetStateHierarchy() []string {
 	sm.mutex.RLock()
@@ -1321,37 +2158,25 @@ func (sm *StateMachine) GetStateHierarchy() []string {
 	return hierarchy
 }
 
-// IsInState checks if the machine is currently in the specified state or any of its substates
+// IsInState checks if the machine is in the specified state: the state is
+// active, or encloses an active state. The whole configuration counts, so a
+// state active in any region of a parallel state or in a fork branch is
+// found, as are its ancestors and the path "<parallel state>.<region>" of its
+// region.
 func (sm *StateMachine) IsInState(stateID string) bool {
-	sm.mutex.RLock()
-	defer sm.mutex.RUnlock()
-
-	// Direct match
-	if sm.currentState == stateID {
-		return true
-	}
-
-	// Check if current state is a child of the specified state
-	currentState, exists := sm.states[sm.currentState]
-	if !exists {
-		return false
-	}
-
-	// Walk up the parent chain
-	for parent := currentState.Parent(); parent != nil; parent = parent.Parent() {
-		if parent.ID() == stateID {
-			return true
-		}
-	}
-
-	return false
+	return sm.loadView().within()[stateID]
 }
 
-// GetActiveStates returns all currently active states (including parallel regions)
+// GetActiveStates returns all currently active states (including parallel
+// regions): the current state first, then the others sorted by ID. Use
+// GetConfiguration for the active states as a tree.
 func (sm *StateMachine) GetActiveStates() []string {
-	sm.mutex.RLock()
-	defer sm.mutex.RUnlock()
+	return slices.Clone(sm.loadView().active)
+}
 
+// activeStatesLocked lists the active states for GetActiveStates.
+// The caller must hold the machine mutex.
+func (sm *StateMachine) activeStatesLocked() []string {
 	activeStates := []string{}
 
 	// Add current state if set
@@ -1385,8 +2210,8 @@ func (sm *StateMachine) GetActiveStates() []string {
 				if parallelState, ok := state.(ParallelState); ok {
 					// Add all active region states
 					for _, region := range parallelState.Regions() {
-						if region.CurrentState() != nil {
-							regionState := region.CurrentState().ID()
+						if sm.regionCurrent(region) != nil {
+							regionState := sm.regionCurrent(region).ID()
 							// Avoid duplicates
 							alreadyAdded := slices.Contains(activeStates, regionState)
 							if !alreadyAdded {
@@ -1399,21 +2224,19 @@ func (sm *StateMachine) GetActiveStates() []string {
 		}
 	}
 
+	// Order the states after the current one so the list is stable
+	first := 0
+	if sm.currentState != "" {
+		first = 1
+	}
+	slices.Sort(activeStates[first:])
 	return activeStates
 }
 
 // IsStateActive checks if a specific state is currently active
 func (sm *StateMachine) IsStateActive(stateID string) bool {
-	sm.mutex.RLock()
-	defer sm.mutex.RUnlock()
-
-	// Check if it's the current state
-	if sm.currentState == stateID {
-		return true
-	}
-
-	// Check if it's in the active states map (parallel execution)
-	return sm.activeStates[stateID]
+	view := sm.loadView()
+	return view.current == stateID || view.set[stateID]
 }
 
 // GetParallelRegions returns the current parallel regions and their active states
@@ -1436,11 +2259,39 @@ func (sm *StateMachine) AddObserver(observer Observer) {
 	sm.observers.AddObserver(observer)
 }
 
+// AddObserverFiltered adds an observer receiving only the notifications about
+// the states or events named by the filter
+func (sm *StateMachine) AddObserverFiltered(observer Observer, filter ObserverFilter) {
+	sm.observers.AddObserverFiltered(observer, filter)
+}
+
 // RemoveObserver removes an observer from the machine
 func (sm *StateMachine) RemoveObserver(observer Observer) {
 	sm.observers.RemoveObserver(observer)
 }
 
+// Observers returns the manager delivering the machine's observer
+// notifications, for configuring async delivery
+func (sm *StateMachine) Observers() *ObserverManager {
+	return sm.observers
+}
+
+// AddRegionObserver adds an observer notified only about the states of one
+// region of a parallel state
+func (sm *StateMachine) AddRegionObserver(parallelState string, region string, observer RegionObserver) error {
+	regionPath := parallelState + "." + region
+	if sm.findRegionByPath(regionPath) == nil {
+		return NewStateNotFoundError(regionPath)
+	}
+	sm.observers.AddRegionObserver(regionPath, observer)
+	return nil
+}
+
+// RemoveRegionObserver removes a region observer from every region it observes
+func (sm *StateMachine) RemoveRegionObserver(observer RegionObserver) {
+	sm.observers.RemoveRegionObserver(observer)
+}
+
 // Context returns the machine's context
 func (sm *StateMachine) Context() Context {
 	return sm.context
@@ -1454,53 +2305,12 @@ func (sm *StateMachine) WithContext(ctx Context) Machine {
 
 // MarshalJSON serializes the machine state to JSON
 func (sm *StateMachine) MarshalJSON() ([]byte, error) {
-	sm.mutex.RLock()
-	defer sm.mutex.RUnlock()
-
-	data := map[string]any{
-		"currentState": sm.currentState,
-		"initialState": sm.initialState,
-		"machineState": sm.machineState,
-		"contextData":  sm.context.GetAll(),
-	}
-
-	return json.Marshal(data)
+	return sm.MarshalSnapshot(JSONCodec{})
 }
 
 // UnmarshalJSON deserializes the machine state from JSON
 func (sm *StateMachine) UnmarshalJSON(data []byte) error {
-	sm.mutex.Lock()
-	defer sm.mutex.Unlock()
-
-	var state map[string]any
-	if err := json.Unmarshal(data, &state); err != nil {
-		return err
-	}
-
-	if currentState, ok := state["currentState"].(string); ok {
-		sm.currentState = currentState
-	}
-
-	if initialState, ok := state["initialState"].(string); ok {
-		sm.initialState = initialState
-	}
-
-	if machineStateFloat, ok := state["machineState"].(float64); ok {
-		sm.machineState = MachineState(int(machineStateFloat))
-	}
-
-	if contextData, ok := state["contextData"].(map[string]any); ok {
-		for k, v := range contextData {
-			sm.context.Set(k, v)
-		}
-	}
-
-	return nil
-}
-
-// splitStatePath splits a hierarchical state path (like "parent.child.grandchild") into segments
-func (sm *StateMachine) splitStatePath(statePath string) []string {
-	return strings.Split(statePath, ".")
+	return sm.UnmarshalSnapshot(JSONCodec{}, data)
 }
 
 // updateStateHistory records the previous state in the history for its composite parent states
@@ -1514,12 +2324,11 @@ func (sm *StateMachine) updateStateHistory(stateID string) {
 		return
 	}
 
-	currentState := state
-	for parent := currentState.Parent(); parent != nil; parent = parent.Parent() {
-		if parent.IsComposite() {
-			sm.stateHistory[parent.ID()] = stateID
+	for parentID := sm.parentOf(state.ID()); parentID != ""; parentID = sm.parentOf(parentID) {
+		if parent, exists := sm.states[parentID]; exists && parent.IsComposite() {
+			sm.stateHistory[parentID] = stateID
+			sm.historyTimes[parentID] = time.Now()
 		}
-		currentState = parent
 	}
 }
 
@@ -1569,9 +2378,7 @@ func (sm *StateMachine) updateRegionStateWithoutCompletionCheck(sourceStateID, t
 
 	if targetRegion != nil {
 		if targetState, exists := sm.states[targetStateID]; exists {
-			if regionImpl, ok := targetRegion.(*RegionImpl); ok {
-				regionImpl.currentState = targetState
-			}
+			sm.setRegionCurrent(targetRegion, targetState)
 		}
 	}
 }
@@ -1619,11 +2426,14 @@ func (sm *StateMachine) checkParallelStateCompletion(parallelState ParallelState
 	transitions := sm.transitions[parallelState.ID()]
 	for _, transition := range transitions {
 		if transition.EventName == completionEventName {
+			if !sm.flagEnabled(transition) {
+				continue
+			}
 			guardPassed := true
 			if transition.Guard != nil {
-				result, err := safeEvaluateGuard(transition.Guard, sm.context)
+				result, err := sm.evaluateGuard(transition.Guard, transition.GuardTimeout)
 				if err != nil {
-					// Guard panicked - skip this transition
+					// Guard panicked or timed out - skip this transition
 					continue
 				}
 				guardPassed = result
@@ -1640,9 +2450,9 @@ func (sm *StateMachine) checkParallelStateCompletion(parallelState ParallelState
 		if transition.EventName == "" {
 			guardPassed := true
 			if transition.Guard != nil {
-				result, err := safeEvaluateGuard(transition.Guard, sm.context)
+				result, err := sm.evaluateGuard(transition.Guard, transition.GuardTimeout)
 				if err != nil {
-					// Guard panicked - skip this transition
+					// Guard panicked or timed out - skip this transition
 					continue
 				}
 				guardPassed = result
@@ -1662,12 +2472,12 @@ func (sm *StateMachine) isRegionComplete(region Region) bool {
 	}
 
 	// Check if region has a current state
-	if region.CurrentState() == nil {
+	if sm.regionCurrent(region) == nil {
 		return false
 	}
 
 	// Check if the current state is a final state
-	currentStateID := region.CurrentState().ID()
+	currentStateID := sm.regionCurrent(region).ID()
 	if currentState, exists := sm.states[currentStateID]; exists {
 		return currentState.IsFinal()
 	}
@@ -1684,22 +2494,20 @@ func (sm *StateMachine) executeCompletionTransition(sourceStateID string, transi
 		if parallelState, ok := sourceState.(ParallelState); ok {
 			// Exit all region states first
 			for _, region := range parallelState.Regions() {
-				if region.CurrentState() != nil {
-					regionStateID := region.CurrentState().ID()
-					region.CurrentState().Exit(sm.context)
+				if sm.regionCurrent(region) != nil {
+					regionStateID := sm.regionCurrent(region).ID()
+					sm.exitState(sm.regionCurrent(region))
 					sm.observers.NotifyStateExit(regionStateID, sm.context)
 					delete(sm.activeStates, regionStateID)
 
 					// Clear region current state
-					if regionImpl, ok := region.(*RegionImpl); ok {
-						regionImpl.currentState = nil
-					}
+					sm.setRegionCurrent(region, nil)
 				}
 			}
 		}
 
 		// Exit the parallel state itself
-		sourceState.Exit(sm.context)
+		sm.exitState(sourceState)
 		sm.observers.NotifyStateExit(sourceStateID, sm.context)
 		delete(sm.activeStates, sourceStateID)
 
@@ -1714,8 +2522,8 @@ func (sm *StateMachine) executeCompletionTransition(sourceStateID string, transi
 
 	// Execute transition action if present
 	if transition.Action != nil {
-		_ = safeExecuteAction(transition.Action, sm.context)
-		sm.observers.NotifyActionExecution("completion_transition", sourceStateID, event, sm.context)
+		_ = sm.runAction(transition.Action)
+		sm.observers.NotifyActionExecution(transition.actionLabel("completion_transition"), sourceStateID, event, sm.context)
 	}
 
 	// Process target state (handle composite states and pseudostates)
@@ -1744,6 +2552,7 @@ func (sm *StateMachine) executeCompletionTransition(sourceStateID string, transi
 
 	// Execute entry actions for the target state hierarchy
 	sm.executeEntryActions(previousState, actualTargetState, event)
+	sm.enterRegions("")
 }
 
 // cleanupParallelRegions removes tracking for completed parallel state
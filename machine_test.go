@@ -71,6 +71,77 @@ func TestStateMachine_Reset(t *testing.T) {
 	AssertState(t, machine, "idle")
 }
 
+func TestStateMachine_ResetClearsContext(t *testing.T) {
+	machine := CreateSimpleMachine()
+	_ = machine.Start()
+	machine.Context().Set("order", "o-1")
+	machine.Context().SetTransient("session", "s-1")
+	_ = machine.HandleEvent("start", nil)
+
+	if err := machine.Reset(); err != nil {
+		t.Fatalf("Expected no error resetting machine, got: %v", err)
+	}
+	if _, ok := machine.Context().Get("order"); ok {
+		t.Error("Expected Reset to clear context data")
+	}
+	if _, ok := machine.Context().Get("session"); ok {
+		t.Error("Expected Reset to clear transient context data")
+	}
+
+	_ = machine.Start()
+	machine.Context().Set("order", "o-2")
+	_ = machine.HandleEvent("start", nil)
+	if err := machine.ResetKeepingContext(); err != nil {
+		t.Fatalf("Expected no error resetting machine, got: %v", err)
+	}
+	AssertState(t, machine, "idle")
+	if order, _ := machine.Context().Get("order"); order != "o-2" {
+		t.Errorf("Expected ResetKeepingContext to keep context data, got %v", order)
+	}
+}
+
+func TestStateMachine_StopStartResumes(t *testing.T) {
+	entries := 0
+	definition := NewMachine().
+		State("idle").Initial().
+		To("waiting").On("start").
+		State("waiting").
+		OnEntry(func(ctx Context) error {
+			entries++
+			return nil
+		}).
+		After(20 * time.Millisecond).To("expired").
+		State("expired").
+		Build()
+
+	machine := definition.CreateInstance()
+	_ = machine.Start()
+	machine.SendEvent("start", nil)
+	if err := machine.Stop(); err != nil {
+		t.Fatalf("Expected no error stopping machine, got: %v", err)
+	}
+
+	// The timer of the retained state is suspended while stopped
+	time.Sleep(40 * time.Millisecond)
+	AssertState(t, machine, "waiting")
+
+	if err := machine.Start(); err != nil {
+		t.Fatalf("Expected the machine to resume, got: %v", err)
+	}
+	AssertState(t, machine, "waiting")
+	if entries != 1 {
+		t.Errorf("Expected resuming not to run entry actions again, got %d entries", entries)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	AssertState(t, machine, "expired")
+
+	// Reset discards the retained configuration
+	_ = machine.Reset()
+	_ = machine.Start()
+	AssertState(t, machine, "idle")
+}
+
 func TestStateMachine_BasicTransition(t *testing.T) {
 	machine := CreateSimpleMachine()
 	observer := NewTestObserver()
@@ -236,6 +307,50 @@ func TestStateMachine_IsInState(t *testing.T) {
 	}
 }
 
+func TestStateMachine_IsInStateParallel(t *testing.T) {
+	builder := NewMachine()
+	builder.State("idle").Initial().
+		To("home").On("arrive").
+		To("split").On("fork")
+	builder.Parallel("home", func(p ParallelScope) {
+		p.Region("security", func(r RegionScope) {
+			r.State("off").Initial().
+				To("armed").On("arm")
+			r.State("armed")
+		})
+		p.Region("lighting", func(r RegionScope) {
+			r.State("dim").Initial()
+		})
+	})
+	builder.Fork("split").To("upload", "scan")
+	builder.State("upload")
+	builder.State("scan")
+	builder.State("away")
+	definition := builder.Build()
+
+	machine := definition.CreateInstance()
+	_ = machine.Start()
+	machine.HandleEvent("arrive", nil)
+	machine.HandleEvent("arm", nil)
+	for _, stateID := range []string{"home", "home.security", "home.security.armed", "home.lighting", "home.lighting.dim"} {
+		if !machine.IsInState(stateID) {
+			t.Errorf("Expected the machine to be in %s", stateID)
+		}
+	}
+	for _, stateID := range []string{"idle", "home.security.off", "away"} {
+		if machine.IsInState(stateID) {
+			t.Errorf("Expected the machine not to be in %s", stateID)
+		}
+	}
+
+	machine = definition.CreateInstance()
+	_ = machine.Start()
+	machine.HandleEvent("fork", nil)
+	if !machine.IsInState("upload") || !machine.IsInState("scan") {
+		t.Errorf("Expected both fork branches to be in the configuration, got %v", machine.GetActiveStates())
+	}
+}
+
 func TestStateMachine_GetActiveStates(t *testing.T) {
 	machine := CreateSimpleMachine()
 	_ = machine.Start()
@@ -605,3 +720,73 @@ func TestStateMachine_EdgeCases(t *testing.T) {
 		}
 	})
 }
+
+func TestMachine_IsCompleted(t *testing.T) {
+	builder := NewMachine()
+	builder.State("idle").Initial().
+		To("wizard.step").On("begin")
+	wizard := builder.CompositeState("wizard")
+	wizard.State("step").
+		To("wizard.done").On("finish").
+		To("closed").On("close")
+	wizard.State("done").Final()
+	builder.State("closed").Final()
+
+	machine := builder.Build().CreateInstance()
+	if machine.IsCompleted() || machine.IsInFinalState() {
+		t.Error("Expected a stopped machine not to be completed")
+	}
+
+	_ = machine.Start()
+	machine.HandleEvent("begin", nil)
+	if machine.IsCompleted() || machine.IsInFinalState() {
+		t.Error("Expected a machine in a non-final state not to be completed")
+	}
+
+	machine.HandleEvent("finish", nil)
+	AssertState(t, machine, "wizard.done")
+	if !machine.IsInFinalState() {
+		t.Error("Expected the composite's final state to be a final state")
+	}
+	if machine.IsCompleted() {
+		t.Error("Expected a nested final state not to complete the machine")
+	}
+
+	machine = builder.Build().CreateInstance()
+	_ = machine.Start()
+	machine.HandleEvent("begin", nil)
+	machine.HandleEvent("close", nil)
+	AssertState(t, machine, "closed")
+	if !machine.IsCompleted() || !machine.IsInFinalState() {
+		t.Error("Expected a top-level final state to complete the machine")
+	}
+}
+
+func TestMachine_IsCompletedParallel(t *testing.T) {
+	builder := NewMachine()
+	builder.State("start").Initial().
+		To("checks").On("begin")
+
+	parallel := builder.ParallelState("checks")
+	credit := parallel.Region("credit")
+	credit.State("pending").Initial().
+		To("done").On("credit_ok")
+	credit.FinalState("done")
+	fraud := parallel.Region("fraud")
+	fraud.State("pending").Initial().
+		To("done").On("fraud_ok")
+	fraud.FinalState("done")
+
+	machine := builder.Build().CreateInstance()
+	_ = machine.Start()
+	machine.HandleEvent("begin", nil)
+	machine.HandleEvent("credit_ok", nil)
+	if machine.IsInFinalState() || machine.IsCompleted() {
+		t.Error("Expected a parallel state with an unfinished region not to be final")
+	}
+
+	machine.HandleEvent("fraud_ok", nil)
+	if !machine.IsInFinalState() || !machine.IsCompleted() {
+		t.Errorf("Expected a top-level parallel state with finished regions to complete the machine, active: %v", machine.GetActiveStates())
+	}
+}
@@ -0,0 +1,548 @@
+package fluo
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Manager creates, tracks and evicts the instances of a machine definition and
+// routes events to them by instance ID. With a Store, instances are saved after
+// every event and after the transitions their timers, do-activities,
+// submachines and scheduled events take, and evicted instances are restored on
+// demand; RestoreAll restores them in bulk after a restart. UpgradeDefinition
+// swaps in new definition versions while instances are in flight, and
+// WithArchive moves completed instances out of the store into an archive.
+type Manager struct {
+	definition     MachineDefinition
+	version        int
+	upgrades       map[int]definitionUpgrade // Upgrades by the version they introduced
+	options        []MachineOption
+	store          Store
+	instances      map[string]*managedInstance
+	evicted        map[string][]MachineOption // Create options of evicted instances, reapplied when they are loaded
+	pending        map[string]Store           // Stores of instances left to restore on their first event by RestoreAll
+	loading        map[string]*loadCall       // Store reads in progress for untracked instances
+	archive        ArchiveSink
+	archiveKeys    []string
+	archived       *tombstones // Recent tombstones of archived instances
+	tombstoneLimit int
+	mutex          sync.Mutex
+}
+
+// managedInstance is a tracked instance. Its mutex orders event processing and
+// saving so the stored snapshot never goes back in time.
+type managedInstance struct {
+	machine  Machine
+	version  int
+	options  []MachineOption // Options passed to Create, reapplied whenever the machine is rebuilt
+	evicted  bool
+	archived bool
+	mutex    sync.Mutex
+
+	// Set while the manager sends the instance an event, whose save covers
+	// the transitions taken meanwhile
+	sending atomic.Bool
+	// Set while a save of transitions taken outside of SendEvent is queued
+	persistQueued atomic.Bool
+}
+
+// ManagerOption configures a Manager
+type ManagerOption func(*Manager)
+
+// WithStore persists instance snapshots in the given store
+func WithStore(store Store) ManagerOption {
+	return func(m *Manager) {
+		m.store = store
+	}
+}
+
+// WithInstanceOptions sets machine options applied to every managed instance
+func WithInstanceOptions(opts ...MachineOption) ManagerOption {
+	return func(m *Manager) {
+		m.options = append(m.options, opts...)
+	}
+}
+
+// NewManager creates a manager for instances of a definition
+func NewManager(definition MachineDefinition, opts ...ManagerOption) *Manager {
+	m := &Manager{
+		definition: definition,
+		version:    1,
+		instances:  make(map[string]*managedInstance),
+		evicted:    make(map[string][]MachineOption),
+		pending:    make(map[string]Store),
+		loading:    make(map[string]*loadCall),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	if m.archive != nil {
+		m.archived = newTombstones(m.tombstoneLimit)
+	}
+	return m
+}
+
+// Create creates a tracked instance with the given ID, or a random ID when id
+// is empty. The instance is not started. The options are applied after the
+// manager's instance options, and again whenever the instance is restored
+// from the store or migrated to a new definition version.
+func (m *Manager) Create(id string, opts ...MachineOption) (Machine, error) {
+	if id == "" {
+		id = newInstanceID()
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for {
+		if err := m.available(id); err != nil {
+			return nil, err
+		}
+		if call, ok := m.loading[id]; ok {
+			m.wait(call)
+			continue
+		}
+		if m.store == nil {
+			break
+		}
+
+		// The store is read without the manager mutex, so a slow store
+		// does not hold up the other instances
+		var snapshot *Snapshot
+		var stored bool
+		var err error
+		m.loadUnlocked(id, func() {
+			snapshot, stored, err = m.store.Load(id)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if stored && isTombstone(snapshot) {
+			m.archived.add(id)
+			return nil, NewInstanceArchivedError(id)
+		}
+		if stored {
+			return nil, m.existsError(id)
+		}
+		// RestoreAll may have tracked the ID meanwhile
+		if err := m.available(id); err != nil {
+			return nil, err
+		}
+		break
+	}
+
+	options := m.instanceOptions(m.version, opts)
+	if m.archive != nil {
+		options = append(options, WithMetadata(map[string]string{CreatedAtKey: time.Now().Format(time.RFC3339Nano)}))
+	}
+	machine := m.definition.CreateInstanceWithID(id, options...)
+	m.instances[id] = m.newInstance(id, machine, m.version, opts)
+	return machine, nil
+}
+
+// available reports whether an ID is free for a new instance, as far as the
+// manager knows without its store. The caller must hold the manager mutex.
+func (m *Manager) available(id string) error {
+	if _, exists := m.instances[id]; exists {
+		return m.existsError(id)
+	}
+	if _, exists := m.pending[id]; exists {
+		return m.existsError(id)
+	}
+	if m.archived.has(id) {
+		return NewInstanceArchivedError(id)
+	}
+	return nil
+}
+
+// existsError reports that an instance ID is already taken
+func (m *Manager) existsError(id string) error {
+	return NewMachineError(ErrCodeInstanceExists, "create", fmt.Sprintf("instance '%s' already exists", id))
+}
+
+// Get returns a tracked instance without consulting the store
+func (m *Manager) Get(id string) (Machine, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if instance, ok := m.instances[id]; ok {
+		return instance.machine, true
+	}
+	return nil, false
+}
+
+// Load returns an instance, restoring it from the store if it is not tracked
+func (m *Manager) Load(id string) (Machine, error) {
+	instance, err := m.load(id)
+	if err != nil {
+		return nil, err
+	}
+	return instance.machine, nil
+}
+
+// load returns a tracked instance, restoring it from the store if needed.
+// The store is read and the snapshot restored without the manager mutex, and
+// the instance is tracked only if nothing changed the ID meanwhile.
+func (m *Manager) load(id string) (*managedInstance, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for {
+		if instance, ok := m.instances[id]; ok {
+			return instance, nil
+		}
+		if m.archived.has(id) {
+			return nil, NewInstanceArchivedError(id)
+		}
+		if call, ok := m.loading[id]; ok {
+			m.wait(call)
+			continue
+		}
+		store, pending := m.pending[id]
+		if !pending {
+			store = m.store
+		}
+		if store == nil {
+			return nil, NewInstanceNotFoundError(id)
+		}
+
+		lineage := m.lineage()
+		opts := m.evicted[id]
+		var machine Machine
+		var version int
+		var err error
+		current := m.loadUnlocked(id, func() {
+			machine, version, err = m.restoreStore(lineage, store, id, opts)
+		})
+		if err != nil {
+			if GetErrorCode(err) == ErrCodeInstanceArchived {
+				m.archived.add(id)
+			}
+			return nil, err
+		}
+
+		// Delete may have removed the instance, RestoreAll tracked it or
+		// UpgradeDefinition outdated the restored machine meanwhile
+		_, tracked := m.instances[id]
+		if !current || tracked || m.version != lineage.version {
+			if sm, ok := machine.(*StateMachine); ok {
+				sm.suspend()
+			}
+			if !current {
+				return nil, NewInstanceNotFoundError(id)
+			}
+			continue
+		}
+		instance := m.newInstance(id, machine, version, opts)
+		m.instances[id] = instance
+		delete(m.evicted, id)
+		delete(m.pending, id)
+		return instance, nil
+	}
+}
+
+// restoreStore restores an instance from a store into the lineage
+func (m *Manager) restoreStore(lineage definitionLineage, store Store, id string, opts []MachineOption) (Machine, int, error) {
+	snapshot, ok, err := store.Load(id)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !ok {
+		return nil, 0, NewInstanceNotFoundError(id)
+	}
+	if isTombstone(snapshot) {
+		return nil, 0, NewInstanceArchivedError(id)
+	}
+	return m.restoreLatest(lineage, id, snapshot, opts)
+}
+
+// loadCall is a store read in progress for an untracked ID. Loads and creates
+// of the ID wait for it rather than reading the store again.
+type loadCall struct {
+	done chan struct{}
+}
+
+// loadUnlocked runs read, a store read of an ID, with the manager mutex
+// released, and wakes the loads and creates of the ID that waited for it. It
+// reports whether the read is still current, that is Delete did not drop it
+// meanwhile. The caller must hold the manager mutex, and holds it again on
+// return, even if read panics.
+func (m *Manager) loadUnlocked(id string, read func()) (current bool) {
+	call := &loadCall{done: make(chan struct{})}
+	m.loading[id] = call
+	m.mutex.Unlock()
+	defer func() {
+		m.mutex.Lock()
+		close(call.done)
+		current = m.loading[id] == call
+		if current {
+			delete(m.loading, id)
+		}
+	}()
+	read()
+	return current
+}
+
+// wait releases the manager mutex until a store read finishes. The caller
+// must hold the manager mutex, and holds it again on return.
+func (m *Manager) wait(call *loadCall) {
+	m.mutex.Unlock()
+	<-call.done
+	m.mutex.Lock()
+}
+
+// SendEvent routes an event to an instance
+func (m *Manager) SendEvent(id string, eventName string, eventData any) *EventResult {
+	return m.SendEventWithContext(context.Background(), id, eventName, eventData)
+}
+
+// SendEventWithContext routes an event with context to an instance and saves
+// the instance afterwards. A failure to load or save the instance is reported
+// in the result's Error.
+func (m *Manager) SendEventWithContext(ctx context.Context, id string, eventName string, eventData any) *EventResult {
+	for {
+		instance, err := m.load(id)
+		if err != nil {
+			return NewEventResult(false, false, "", "").
+				WithRejection(fmt.Sprintf("instance '%s' is not available", id)).
+				WithRejectionCode(RejectionNotStarted).
+				WithError(err)
+		}
+
+		instance.mutex.Lock()
+		if instance.evicted {
+			// Evicted while waiting; the next load restores the saved snapshot
+			instance.mutex.Unlock()
+			continue
+		}
+		result := m.send(ctx, id, instance, eventName, eventData)
+		instance.mutex.Unlock()
+		if m.archive != nil {
+			if err := m.archiveCompleted(id, instance); err != nil && result.Error == nil {
+				result.Error = err
+			}
+		}
+		return result
+	}
+}
+
+// send processes an event on a locked instance and saves it
+func (m *Manager) send(ctx context.Context, id string, instance *managedInstance, eventName string, eventData any) *EventResult {
+	instance.sending.Store(true)
+	result := instance.machine.SendEventWithContext(ctx, eventName, eventData)
+	instance.sending.Store(false)
+	if err := m.save(id, instance.machine); err != nil && result.Error == nil {
+		result.Error = err
+	}
+	return result
+}
+
+// Save writes an instance's snapshot to the store
+func (m *Manager) Save(id string) error {
+	m.mutex.Lock()
+	instance, ok := m.instances[id]
+	m.mutex.Unlock()
+	if !ok {
+		return NewInstanceNotFoundError(id)
+	}
+
+	instance.mutex.Lock()
+	defer instance.mutex.Unlock()
+	return m.save(id, instance.machine)
+}
+
+// save writes a snapshot of machine to the store, if there is one
+func (m *Manager) save(id string, machine Machine) error {
+	if m.store == nil {
+		return nil
+	}
+	snapshot, err := machine.Snapshot()
+	if err != nil {
+		return err
+	}
+	return m.store.Save(id, snapshot)
+}
+
+// Evict saves an instance to the store and stops tracking it. Its timers,
+// activities and submachines are stopped without running exit actions; the
+// next event for the ID restores it from the store.
+func (m *Manager) Evict(id string) error {
+	m.mutex.Lock()
+	instance, ok := m.instances[id]
+	m.mutex.Unlock()
+	if !ok {
+		return NewInstanceNotFoundError(id)
+	}
+
+	// The snapshot is saved without the manager mutex, so a slow store does
+	// not hold up the other instances
+	instance.mutex.Lock()
+	if instance.evicted {
+		instance.mutex.Unlock()
+		return NewInstanceNotFoundError(id)
+	}
+	err := m.save(id, instance.machine)
+	instance.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	instance.mutex.Lock()
+	defer instance.mutex.Unlock()
+	if instance.evicted {
+		m.mutex.Unlock()
+		return NewInstanceNotFoundError(id)
+	}
+	m.untrack(id, instance)
+	if len(instance.options) > 0 {
+		m.evicted[id] = instance.options
+	}
+	m.mutex.Unlock()
+
+	// Transitions a timer or activity took since the save are saved again now
+	// that the instance is suspended
+	if instance.persistQueued.Load() {
+		return m.save(id, instance.machine)
+	}
+	return nil
+}
+
+// Delete stops tracking an instance and removes it from the store, along with
+// the tombstone of an archived instance
+func (m *Manager) Delete(id string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	delete(m.evicted, id)
+	delete(m.loading, id)
+	_, pending := m.pending[id]
+	delete(m.pending, id)
+	archived := m.archived.remove(id)
+	instance, tracked := m.instances[id]
+	if tracked {
+		instance.mutex.Lock()
+		m.untrack(id, instance)
+		instance.mutex.Unlock()
+	}
+	if m.store != nil {
+		return m.store.Delete(id)
+	}
+	if !tracked && !pending && !archived {
+		return NewInstanceNotFoundError(id)
+	}
+	return nil
+}
+
+// newInstance wraps a machine into a managed instance, saving the
+// transitions it takes outside of SendEvent when there is a store or archive
+func (m *Manager) newInstance(id string, machine Machine, version int, opts []MachineOption) *managedInstance {
+	instance := &managedInstance{machine: machine, version: version, options: opts}
+	m.watch(id, instance)
+	return instance
+}
+
+// watch observes the machine of an instance for transitions to persist
+func (m *Manager) watch(id string, instance *managedInstance) {
+	if m.store != nil || m.archive != nil {
+		instance.machine.AddObserver(&persistObserver{manager: m, id: id, instance: instance})
+	}
+}
+
+// persistObserver queues a save of its instance after every transition
+// taken outside of SendEvent, such as timed, completion and scheduled ones
+type persistObserver struct {
+	BaseObserver
+	manager  *Manager
+	id       string
+	instance *managedInstance
+}
+
+// OnTransition queues a save of the instance
+func (o *persistObserver) OnTransition(from string, to string, event Event, ctx Context) {
+	o.queue()
+}
+
+// OnInternalTransition queues a save of the instance
+func (o *persistObserver) OnInternalTransition(state string, event Event, ctx Context) {
+	o.queue()
+}
+
+// queue saves the instance on its own goroutine, as observers run under the
+// machine mutex. At most one save is queued at a time.
+func (o *persistObserver) queue() {
+	if o.instance.sending.Load() || !o.instance.persistQueued.CompareAndSwap(false, true) {
+		return
+	}
+	go o.manager.persist(o.id, o.instance)
+}
+
+// persist saves an instance after transitions taken outside of SendEvent and
+// archives it when they completed it. Failures are reported to the machine's
+// observers, as there is no caller to return them to.
+func (m *Manager) persist(id string, instance *managedInstance) {
+	instance.mutex.Lock()
+	instance.persistQueued.Store(false)
+	machine := instance.machine
+	var err error
+	if !instance.evicted {
+		err = m.save(id, machine)
+	}
+	instance.mutex.Unlock()
+
+	if err == nil && m.archive != nil {
+		err = m.archiveCompleted(id, instance)
+	}
+	if sm, ok := machine.(*StateMachine); ok && err != nil {
+		sm.mutex.Lock()
+		sm.observers.NotifyError(err, sm.context)
+		sm.mutex.Unlock()
+	}
+}
+
+// untrack forgets a locked instance and suspends it. The caller must hold both mutexes.
+func (m *Manager) untrack(id string, instance *managedInstance) {
+	delete(m.instances, id)
+	instance.evicted = true
+	if sm, ok := instance.machine.(*StateMachine); ok {
+		sm.suspend()
+	}
+}
+
+// IDs returns the IDs of the tracked instances in sorted order
+func (m *Manager) IDs() []string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	ids := make([]string, 0, len(m.instances))
+	for id := range m.instances {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// Len returns the number of tracked instances
+func (m *Manager) Len() int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return len(m.instances)
+}
+
+// suspend stops the instance's timers, activities, submachines and event loop
+// without running exit actions, leaving an evicted instance inert
+func (sm *StateMachine) suspend() {
+	sm.mutex.Lock()
+	sm.stopAllTimers()
+	sm.stopAllActivities()
+	sm.stopAllSubmachines()
+	sm.unlock()
+
+	if sm.eventLoop != nil {
+		sm.eventLoop.stop()
+	}
+}
@@ -0,0 +1,277 @@
+package fluo
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestManager(opts ...ManagerOption) *Manager {
+	builder := NewMachine()
+	builder.State("idle").Initial().
+		To("running").On("start")
+	builder.State("running").
+		To("idle").On("stop")
+	return NewManager(builder.Build(), opts...)
+}
+
+func TestManager_CreateAndRoute(t *testing.T) {
+	manager := newTestManager()
+
+	first, err := manager.Create("order-1")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	second, _ := manager.Create("order-2")
+	_ = first.Start()
+	_ = second.Start()
+
+	AssertEventProcessed(t, manager.SendEvent("order-1", "start", nil), true)
+	AssertState(t, first, "running")
+	AssertState(t, second, "idle")
+
+	if first.ID() != "order-1" {
+		t.Errorf("Expected instance ID order-1, got %s", first.ID())
+	}
+	if _, err := manager.Create("order-1"); GetErrorCode(err) != ErrCodeInstanceExists {
+		t.Errorf("Expected duplicate ID to be rejected, got %v", err)
+	}
+	if ids := manager.IDs(); len(ids) != 2 || ids[0] != "order-1" || ids[1] != "order-2" {
+		t.Errorf("Expected both IDs, got %v", ids)
+	}
+
+	result := manager.SendEvent("missing", "start", nil)
+	AssertEventProcessed(t, result, false)
+	if GetErrorCode(result.Error) != ErrCodeInstanceNotFound {
+		t.Errorf("Expected instance not found, got %v", result.Error)
+	}
+}
+
+func TestManager_GeneratedID(t *testing.T) {
+	manager := newTestManager()
+
+	machine, err := manager.Create("")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got, ok := manager.Get(machine.ID()); !ok || got != machine {
+		t.Error("Expected the generated ID to find the instance")
+	}
+}
+
+func TestManager_EvictAndRestore(t *testing.T) {
+	store := NewMemoryStore()
+	manager := newTestManager(WithStore(store))
+
+	machine, _ := manager.Create("doc-1")
+	_ = machine.Start()
+	machine.Context().Set("owner", "alice")
+	AssertEventProcessed(t, manager.SendEvent("doc-1", "start", nil), true)
+
+	if err := manager.Evict("doc-1"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if manager.Len() != 0 {
+		t.Errorf("Expected no tracked instances, got %v", manager.IDs())
+	}
+	if _, ok := manager.Get("doc-1"); ok {
+		t.Error("Expected evicted instance not to be tracked")
+	}
+
+	AssertEventProcessed(t, manager.SendEvent("doc-1", "stop", nil), true)
+	restored, ok := manager.Get("doc-1")
+	if !ok {
+		t.Fatal("Expected the instance to be restored from the store")
+	}
+	AssertState(t, restored, "idle")
+	if owner, _ := restored.Context().Get("owner"); owner != "alice" {
+		t.Errorf("Expected context to be restored, got %v", owner)
+	}
+
+	snapshot, _, _ := store.Load("doc-1")
+	if snapshot.CurrentState != "idle" {
+		t.Errorf("Expected store to hold the latest state, got %s", snapshot.CurrentState)
+	}
+
+	if _, err := manager.Create("doc-1"); GetErrorCode(err) != ErrCodeInstanceExists {
+		t.Errorf("Expected a stored ID to be taken, got %v", err)
+	}
+	if err := manager.Delete("doc-1"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := manager.Load("doc-1"); GetErrorCode(err) != ErrCodeInstanceNotFound {
+		t.Errorf("Expected deleted instance to be gone, got %v", err)
+	}
+}
+
+func TestManager_ConcurrentRouting(t *testing.T) {
+	manager := newTestManager(WithStore(NewMemoryStore()))
+	for i := range 10 {
+		machine, _ := manager.Create(fmt.Sprintf("m-%d", i))
+		_ = machine.Start()
+	}
+
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			for range 20 {
+				manager.SendEvent(id, "start", nil)
+				manager.SendEvent(id, "stop", nil)
+			}
+			_ = manager.Evict(id)
+		}(fmt.Sprintf("m-%d", i))
+	}
+	wg.Wait()
+
+	for i := range 10 {
+		machine, err := manager.Load(fmt.Sprintf("m-%d", i))
+		if err != nil {
+			t.Fatalf("Expected instance to load, got %v", err)
+		}
+		AssertState(t, machine, "idle")
+	}
+}
+
+// slowStore is a MemoryStore whose loads of one ID block until released
+type slowStore struct {
+	*MemoryStore
+	slowID  string
+	release chan struct{}
+	loads   atomic.Int32
+}
+
+// Load blocks on loads of the slow ID
+func (s *slowStore) Load(id string) (*Snapshot, bool, error) {
+	if id == s.slowID {
+		s.loads.Add(1)
+		<-s.release
+	}
+	return s.MemoryStore.Load(id)
+}
+
+func TestManager_SlowLoadDoesNotBlockOtherInstances(t *testing.T) {
+	store := &slowStore{MemoryStore: NewMemoryStore(), release: make(chan struct{})}
+	manager := newTestManager(WithStore(store))
+	for _, id := range []string{"slow", "fast"} {
+		machine, _ := manager.Create(id)
+		_ = machine.Start()
+	}
+	if err := manager.Evict("slow"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	store.slowID = "slow"
+
+	var wg sync.WaitGroup
+	loaded := make(chan Machine, 2)
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			machine, err := manager.Load("slow")
+			if err != nil {
+				t.Errorf("Expected the slow instance to load, got %v", err)
+			}
+			loaded <- machine
+		}()
+	}
+	defer close(store.release)
+	for store.loads.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		AssertEventProcessed(t, manager.SendEvent("fast", "start", nil), true)
+		if _, err := manager.Create("other"); err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected other instances to be served while a load is blocked")
+	}
+
+	store.release <- struct{}{}
+	wg.Wait()
+	if first, second := <-loaded, <-loaded; first == nil || first != second {
+		t.Error("Expected concurrent loads to share one restored instance")
+	}
+	if loads := store.loads.Load(); loads != 1 {
+		t.Errorf("Expected the slow instance to be read once, got %d reads", loads)
+	}
+}
+
+// countingMiddleware counts the events passing through it
+func countingMiddleware(count *atomic.Int32) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, event Event) *EventResult {
+			count.Add(1)
+			return next(ctx, event)
+		}
+	}
+}
+
+func TestManager_EvictKeepsCreateOptions(t *testing.T) {
+	manager := newTestManager(WithStore(NewMemoryStore()))
+
+	var count atomic.Int32
+	machine, _ := manager.Create("doc-1", WithMiddleware(countingMiddleware(&count)))
+	_ = machine.Start()
+	manager.SendEvent("doc-1", "start", nil)
+	_ = manager.Evict("doc-1")
+
+	AssertEventProcessed(t, manager.SendEvent("doc-1", "stop", nil), true)
+	if count.Load() != 2 {
+		t.Errorf("Expected the restored instance to keep its middleware, got %d calls", count.Load())
+	}
+
+	_ = manager.Delete("doc-1")
+	created, _ := manager.Create("doc-1")
+	_ = created.Start()
+	manager.SendEvent("doc-1", "start", nil)
+	if count.Load() != 2 {
+		t.Errorf("Expected a deleted instance's options to be forgotten, got %d calls", count.Load())
+	}
+}
+
+func TestManager_SavesTimedTransitions(t *testing.T) {
+	store := NewMemoryStore()
+	builder := NewMachine()
+	builder.State("idle").Initial().To("waiting").On("start")
+	builder.State("waiting").After(10 * time.Millisecond).To("expired")
+	builder.State("expired")
+	manager := NewManager(builder.Build(), WithStore(store))
+
+	machine, _ := manager.Create("order-1")
+	_ = machine.Start()
+	AssertEventProcessed(t, manager.SendEvent("order-1", "start", nil), true)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		snapshot, _, _ := store.Load("order-1")
+		if snapshot.CurrentState == "expired" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the timed transition to be saved, store holds %s", snapshot.CurrentState)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// The saved timed transition survives eviction
+	if err := manager.Evict("order-1"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	restored, err := manager.Load("order-1")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	AssertState(t, restored, "expired")
+}
@@ -0,0 +1,285 @@
+package fluo
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// MergeOptions controls how Merge combines two definitions
+type MergeOptions struct {
+	// Namespace nests the states of the second definition in a composite state
+	// of that ID, entered at the second definition's initial state, so one
+	// fragment can be merged several times under different namespaces. An
+	// empty namespace merges the states at the top level as they are.
+	Namespace string
+
+	// Transitions stitch the definitions together. Their states are named by
+	// their IDs in the merged definition.
+	Transitions []Transition
+}
+
+// Merge combines two definitions into a new one starting at the initial state
+// of the first, so shared fragments such as an error handling sub-flow can be
+// declared once and reused across machines. Neither definition is modified.
+// The merged definition declares the variables of both.
+// State IDs of the merged definition must be unique, and stitching transitions
+// must connect states that exist in it.
+func Merge(base, fragment MachineDefinition, opts MergeOptions) (MachineDefinition, error) {
+	merged := &simpleMachineDefinition{
+		initialState:   base.GetInitialState(),
+		states:         base.GetStates(),
+		bySource:       make(map[string][]Transition),
+		joinConditions: make(map[string][][]string),
+	}
+	merged.vars = append(definitionVars(base), definitionVars(fragment)...)
+	if errs := validateVars(merged.vars); len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	for _, transitions := range sortedTransitions(base.GetTransitions()) {
+		merged.transitions = append(merged.transitions, transitions...)
+	}
+
+	fragmentStates := fragment.GetStates()
+	fragmentTransitions := fragment.GetTransitions()
+	if opts.Namespace != "" {
+		if _, exists := merged.states[opts.Namespace]; exists {
+			return nil, NewConfigurationError("merge", fmt.Sprintf("namespace '%s' is already a state", opts.Namespace))
+		}
+		namespace := newMergeNamespace(opts.Namespace)
+		fragmentStates = namespace.states(fragmentStates)
+		fragmentTransitions = namespace.transitions(fragmentTransitions)
+
+		// The top-level states of the fragment become substates of the
+		// namespace, so transitions declared on it leave the whole sub-flow
+		composite := NewCompositeState(opts.Namespace)
+		for _, id := range slices.Sorted(maps.Keys(fragmentStates)) {
+			if state := fragmentStates[id]; state.Parent() == nil {
+				composite.AddSubstate(state)
+			}
+		}
+		if initial, exists := fragmentStates[namespace.id(fragment.GetInitialState())]; exists {
+			composite.WithInitialState(initial)
+		}
+		merged.states[opts.Namespace] = composite
+	}
+
+	for _, id := range slices.Sorted(maps.Keys(fragmentStates)) {
+		if _, exists := merged.states[id]; exists {
+			return nil, NewConfigurationError("merge", fmt.Sprintf("state '%s' is declared by both definitions", id))
+		}
+		merged.states[id] = fragmentStates[id]
+	}
+	for _, transitions := range sortedTransitions(fragmentTransitions) {
+		merged.transitions = append(merged.transitions, transitions...)
+	}
+
+	for _, transition := range opts.Transitions {
+		for _, id := range []string{transition.SourceState, transition.TargetState, transition.ErrorState} {
+			if _, exists := merged.states[id]; id != "" && !exists {
+				return nil, NewStateError(ErrCodeStateNotFound, id,
+					fmt.Sprintf("stitching transition %s references unknown state '%s'", transition.describe(), id))
+			}
+		}
+		merged.transitions = append(merged.transitions, transition)
+	}
+
+	for _, transition := range merged.transitions {
+		merged.bySource[transition.SourceState] = append(merged.bySource[transition.SourceState], transition)
+	}
+	for id, state := range merged.states {
+		if pseudoState, ok := state.(*PseudoStateImpl); ok && pseudoState.Kind() == Join && len(pseudoState.joinSourceCombinations) > 0 {
+			merged.joinConditions[id] = pseudoState.joinSourceCombinations
+		}
+	}
+	merged.hash = sync.OnceValue(func() string { return DefinitionHash(merged) })
+	return merged, nil
+}
+
+// sortedTransitions returns the transitions of a definition grouped by source
+// state in state ID order, keeping the declaration order within each group
+func sortedTransitions(transitions map[string][]Transition) [][]Transition {
+	grouped := make([][]Transition, 0, len(transitions))
+	for _, source := range slices.Sorted(maps.Keys(transitions)) {
+		grouped = append(grouped, transitions[source])
+	}
+	return grouped
+}
+
+// mergeNamespace copies the states and transitions of a definition below a
+// namespace, renaming every state reference
+type mergeNamespace struct {
+	prefix string
+	copies map[State]State
+}
+
+// newMergeNamespace creates a namespace for the given composite state ID
+func newMergeNamespace(namespace string) *mergeNamespace {
+	return &mergeNamespace{
+		prefix: namespace + ".",
+		copies: make(map[State]State),
+	}
+}
+
+// id returns the namespaced ID of a state, leaving empty IDs empty
+func (n *mergeNamespace) id(stateID string) string {
+	if stateID == "" {
+		return ""
+	}
+	return n.prefix + stateID
+}
+
+// ids returns the namespaced IDs of several states
+func (n *mergeNamespace) ids(stateIDs []string) []string {
+	if stateIDs == nil {
+		return nil
+	}
+	renamed := make([]string, len(stateIDs))
+	for i, stateID := range stateIDs {
+		renamed[i] = n.id(stateID)
+	}
+	return renamed
+}
+
+// states copies every state of a definition into the namespace
+func (n *mergeNamespace) states(states map[string]State) map[string]State {
+	renamed := make(map[string]State, len(states))
+	for _, state := range states {
+		copied := n.state(state)
+		renamed[copied.ID()] = copied
+	}
+	return renamed
+}
+
+// transitions copies the transitions of a definition into the namespace
+func (n *mergeNamespace) transitions(transitions map[string][]Transition) map[string][]Transition {
+	renamed := make(map[string][]Transition, len(transitions))
+	for source, group := range transitions {
+		renamed[n.id(source)] = n.transitionList(group)
+	}
+	return renamed
+}
+
+// transitionList copies a list of transitions into the namespace
+func (n *mergeNamespace) transitionList(transitions []Transition) []Transition {
+	if transitions == nil {
+		return nil
+	}
+	renamed := make([]Transition, len(transitions))
+	for i, transition := range transitions {
+		transition.SourceState = n.id(transition.SourceState)
+		transition.TargetState = n.id(transition.TargetState)
+		transition.ErrorState = n.id(transition.ErrorState)
+		renamed[i] = transition
+	}
+	return renamed
+}
+
+// state copies a state into the namespace. Copies are shared, so parent
+// links, composite initial states and region members keep pointing at the
+// copies of the states they referenced.
+func (n *mergeNamespace) state(state State) State {
+	if state == nil {
+		return nil
+	}
+	if copied, exists := n.copies[state]; exists {
+		return copied
+	}
+
+	switch original := state.(type) {
+	case *AtomicStateImpl:
+		copied := *original
+		n.copies[state] = &copied
+		n.atomic(&copied)
+		return &copied
+	case *PseudoStateImpl:
+		copied := *original
+		n.copies[state] = &copied
+		n.atomic(&copied.AtomicStateImpl)
+		copied.defaultTarget = n.id(original.defaultTarget)
+		copied.forkTargets = n.ids(original.forkTargets)
+		copied.forkBranches = nil
+		for target, branch := range original.forkBranches {
+			branch.Target = n.id(target)
+			if copied.forkBranches == nil {
+				copied.forkBranches = make(map[string]ForkBranch)
+			}
+			copied.forkBranches[branch.Target] = branch
+		}
+		copied.joinTarget = n.id(original.joinTarget)
+		copied.joinFork = n.id(original.joinFork)
+		copied.historyDefault = n.id(original.historyDefault)
+		copied.joinSourceCombinations = nil
+		for _, combination := range original.joinSourceCombinations {
+			copied.joinSourceCombinations = append(copied.joinSourceCombinations, n.ids(combination))
+		}
+		copied.choiceConditions = nil
+		for _, condition := range original.choiceConditions {
+			condition.Target = n.id(condition.Target)
+			if targetFunc := condition.TargetFunc; targetFunc != nil {
+				condition.TargetFunc = func(ctx Context) string { return n.id(targetFunc(ctx)) }
+			}
+			copied.choiceConditions = append(copied.choiceConditions, condition)
+		}
+		return &copied
+	case *CompositeStateImpl:
+		copied := *original
+		n.copies[state] = &copied
+		n.composite(&copied)
+		return &copied
+	case *SequentialStateImpl:
+		copied := *original
+		n.copies[state] = &copied
+		n.composite(&copied.CompositeStateImpl)
+		return &copied
+	case *ParallelStateImpl:
+		copied := *original
+		n.copies[state] = &copied
+		n.composite(&copied.CompositeStateImpl)
+		copied.regions = make([]Region, 0, len(original.regions))
+		for _, region := range original.regions {
+			copied.regions = append(copied.regions, n.region(region, &copied))
+		}
+		return &copied
+	default:
+		// States implemented outside the package keep their IDs
+		return state
+	}
+}
+
+// atomic renames the fields shared by every state kind
+func (n *mergeNamespace) atomic(state *AtomicStateImpl) {
+	state.id = n.id(state.id)
+	state.parent = n.state(state.parent)
+}
+
+// composite renames a composite state and copies its substates
+func (n *mergeNamespace) composite(state *CompositeStateImpl) {
+	n.atomic(&state.AtomicStateImpl)
+	state.initialState = n.state(state.initialState)
+	substates := state.substates
+	state.substates = make([]State, 0, len(substates))
+	state.substateMap = make(map[string]State, len(substates))
+	for _, substate := range substates {
+		copied := n.state(substate)
+		state.substates = append(state.substates, copied)
+		state.substateMap[copied.ID()] = copied
+	}
+	state.transitions = n.transitionList(state.transitions)
+}
+
+// region copies a region of a parallel state and its states
+func (n *mergeNamespace) region(region Region, parent ParallelState) Region {
+	original, ok := region.(*RegionImpl)
+	if !ok {
+		return region
+	}
+	copied := NewRegion(original.id, parent)
+	for _, state := range original.states {
+		copied.AddState(n.state(state))
+	}
+	copied.initialState = n.state(original.initialState)
+	return copied
+}
@@ -0,0 +1,208 @@
+package fluo
+
+import (
+	"slices"
+	"testing"
+)
+
+// buildRecoveryFragment builds an error handling sub-flow that retries until
+// the "recoverable" context value is false
+func buildRecoveryFragment() MachineDefinition {
+	builder := NewMachine()
+	builder.State("failed").Initial().
+		To("assess").On("retry")
+	builder.Choice("assess").
+		When(func(ctx Context) bool {
+			recoverable, _ := ctx.Get("recoverable")
+			return recoverable == true
+		}).To("retrying").
+		Otherwise("abandoned")
+	builder.State("retrying")
+	builder.State("abandoned").Final()
+	return builder.Build()
+}
+
+func buildWorkflowBase() MachineDefinition {
+	builder := NewMachine()
+	builder.State("idle").Initial().
+		To("working").On("start")
+	builder.State("working").
+		To("idle").On("finish")
+	return builder.Build()
+}
+
+func TestMerge_Namespace(t *testing.T) {
+	merged, err := Merge(buildWorkflowBase(), buildRecoveryFragment(), MergeOptions{
+		Namespace: "recovery",
+		Transitions: []Transition{
+			*NewTransition("working", "recovery", "fail"),
+			*NewTransition("recovery.retrying", "working", "resume"),
+		},
+	})
+	if err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	if merged.GetInitialState() != "idle" {
+		t.Errorf("Expected the base initial state, got %s", merged.GetInitialState())
+	}
+
+	machine := merged.CreateInstance()
+	_ = machine.Start()
+	machine.HandleEvent("start", nil)
+	AssertEventProcessed(t, machine.HandleEvent("fail", nil), true)
+	AssertState(t, machine, "recovery.failed")
+
+	machine.Context().Set("recoverable", true)
+	AssertEventProcessed(t, machine.HandleEvent("retry", nil), true)
+	AssertState(t, machine, "recovery.retrying")
+	AssertEventProcessed(t, machine.HandleEvent("resume", nil), true)
+	AssertState(t, machine, "working")
+
+	machine.HandleEvent("fail", nil)
+	machine.Context().Set("recoverable", false)
+	machine.HandleEvent("retry", nil)
+	AssertState(t, machine, "recovery.abandoned")
+}
+
+func TestMerge_FragmentReusedUnderSeveralNamespaces(t *testing.T) {
+	fragment := buildRecoveryFragment()
+	first, err := Merge(buildWorkflowBase(), fragment, MergeOptions{
+		Namespace:   "payment",
+		Transitions: []Transition{*NewTransition("working", "payment", "fail")},
+	})
+	if err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	merged, err := Merge(first, fragment, MergeOptions{
+		Namespace:   "shipping",
+		Transitions: []Transition{*NewTransition("idle", "shipping", "fail")},
+	})
+	if err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+
+	machine := merged.CreateInstance()
+	_ = machine.Start()
+	machine.HandleEvent("fail", nil)
+	machine.HandleEvent("retry", nil)
+	AssertState(t, machine, "shipping.abandoned")
+
+	if _, exists := fragment.GetStates()["failed"]; !exists {
+		t.Error("Expected the fragment to keep its own state IDs")
+	}
+	if fragment.GetStates()["assess"].(*PseudoStateImpl).choiceConditions[0].Target != "retrying" {
+		t.Error("Expected the fragment's choice targets to be left untouched")
+	}
+}
+
+func TestMerge_TopLevel(t *testing.T) {
+	fragment := NewMachine().
+		State("broken").Initial().
+		Build()
+	merged, err := Merge(buildWorkflowBase(), fragment, MergeOptions{
+		Transitions: []Transition{
+			*NewTransition("working", "broken", "break"),
+			*NewTransition("broken", "idle", "repair"),
+		},
+	})
+	if err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+
+	machine := merged.CreateInstance()
+	_ = machine.Start()
+	machine.HandleEvent("start", nil)
+	machine.HandleEvent("break", nil)
+	AssertState(t, machine, "broken")
+	machine.HandleEvent("repair", nil)
+	AssertState(t, machine, "idle")
+}
+
+func TestMerge_Errors(t *testing.T) {
+	base := buildWorkflowBase()
+
+	_, err := Merge(base, base, MergeOptions{})
+	if !IsConfigurationError(err) {
+		t.Errorf("Expected a configuration error for colliding states, got %v", err)
+	}
+
+	_, err = Merge(base, buildRecoveryFragment(), MergeOptions{Namespace: "working"})
+	if !IsConfigurationError(err) {
+		t.Errorf("Expected a configuration error for a namespace naming a state, got %v", err)
+	}
+
+	_, err = Merge(base, buildRecoveryFragment(), MergeOptions{
+		Namespace:   "recovery",
+		Transitions: []Transition{*NewTransition("working", "failed", "fail")},
+	})
+	if GetErrorCode(err) != ErrCodeStateNotFound {
+		t.Errorf("Expected a state not found error for an unknown stitching target, got %v", err)
+	}
+}
+
+func TestMerge_NamespacedParallelFragment(t *testing.T) {
+	builder := NewMachine()
+	builder.Parallel("checks", func(p ParallelScope) {
+		p.Region("disk", func(r RegionScope) {
+			r.State("scanning").Initial().
+				To("clean").On("scanned")
+			r.State("clean").Final()
+		})
+		p.Region("network", func(r RegionScope) {
+			r.State("probing").Initial()
+		})
+	})
+	builder.State("checks").Initial()
+	fragment := builder.Build()
+
+	merged, err := Merge(buildWorkflowBase(), fragment, MergeOptions{
+		Namespace:   "diagnostics",
+		Transitions: []Transition{*NewTransition("idle", "diagnostics", "diagnose")},
+	})
+	if err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+
+	machine := merged.CreateInstance()
+	_ = machine.Start()
+	machine.HandleEvent("diagnose", nil)
+	active := machine.GetActiveStates()
+	if !slices.Contains(active, "diagnostics.checks.disk.scanning") || !slices.Contains(active, "diagnostics.checks.network.probing") {
+		t.Fatalf("Expected both namespaced regions to be active, got %v", machine.GetActiveStates())
+	}
+	machine.HandleEvent("scanned", nil)
+	if !slices.Contains(machine.GetActiveStates(), "diagnostics.checks.disk.clean") {
+		t.Errorf("Expected the disk region to reach clean, got %v", machine.GetActiveStates())
+	}
+}
+
+func TestMerge_TransitionOutOfNamespace(t *testing.T) {
+	fragment := NewMachine().
+		State("start").Initial().
+		To("waiting").On("next").
+		State("waiting").
+		Build()
+	merged, err := Merge(buildWorkflowBase(), fragment, MergeOptions{
+		Namespace: "err",
+		Transitions: []Transition{
+			*NewTransition("working", "err", "fail"),
+			*NewTransition("err", "idle", "retry"),
+		},
+	})
+	if err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+
+	machine := merged.CreateInstance()
+	_ = machine.Start()
+	machine.HandleEvent("start", nil)
+	machine.HandleEvent("fail", nil)
+	AssertState(t, machine, "err.start")
+	if !machine.IsInState("err") {
+		t.Error("Expected the machine to be in the namespace")
+	}
+
+	AssertEventProcessed(t, machine.HandleEvent("next", nil), true)
+	AssertEventProcessed(t, machine.HandleEvent("retry", nil), true)
+	AssertState(t, machine, "idle")
+}
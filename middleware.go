@@ -0,0 +1,46 @@
+package fluo
+
+import "context"
+
+// Handler handles an event sent to the machine and returns its result
+type Handler func(ctx context.Context, event Event) *EventResult
+
+// Middleware wraps event handling. It may inspect, enrich or replace the
+// event before calling next, or reject it by returning a result without
+// calling next. Middleware runs before the machine mutex is taken, so it may
+// read the machine's state; the event's name and data reach transition
+// resolution.
+type Middleware func(next Handler) Handler
+
+// WithMiddleware adds middleware to the machine's event handling chain
+func WithMiddleware(middleware ...Middleware) MachineOption {
+	return func(sm *StateMachine) {
+		sm.middleware = append(sm.middleware, middleware...)
+	}
+}
+
+// Use adds middleware to the event handling chain. Middleware added first
+// runs outermost. Events raised internally by timers, do-activities and
+// submachines bypass the chain.
+func (sm *StateMachine) Use(middleware ...Middleware) {
+	sm.middlewareMutex.Lock()
+	defer sm.middlewareMutex.Unlock()
+	sm.middleware = append(sm.middleware, middleware...)
+}
+
+// handleEvent runs an event through the middleware chain and then processes
+// it under the machine mutex
+func (sm *StateMachine) handleEvent(ctx context.Context, eventName string, eventData any) *EventResult {
+	sm.middlewareMutex.RLock()
+	middleware := sm.middleware
+	sm.middlewareMutex.RUnlock()
+
+	handler := Handler(func(ctx context.Context, event Event) *EventResult {
+		defer sm.lockForEvent(event.GetName())()
+		return sm.processEvent(ctx, event.GetName(), event.GetData())
+	})
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+	return handler(ctx, NewEvent(eventName, eventData))
+}
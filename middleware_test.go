@@ -0,0 +1,75 @@
+package fluo
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func buildMiddlewareMachine() MachineDefinition {
+	isAdmin := func(ctx Context) bool {
+		event := ctx.GetCurrentEvent()
+		return event != nil && event.GetData() == "token:admin"
+	}
+	return NewMachine().
+		State("locked").Initial().
+		To("unlocked").On("unlock").When(isAdmin).
+		State("unlocked").
+		Build()
+}
+
+func TestMiddleware_OrderAndEnrichment(t *testing.T) {
+	machine := buildMiddlewareMachine().CreateInstance()
+	var order []string
+	machine.Use(func(next Handler) Handler {
+		return func(ctx context.Context, event Event) *EventResult {
+			order = append(order, "outer:"+machine.CurrentState())
+			return next(ctx, event)
+		}
+	}, func(next Handler) Handler {
+		return func(ctx context.Context, event Event) *EventResult {
+			order = append(order, "inner")
+			return next(ctx, NewEvent(event.GetName(), "token:"+event.GetData().(string)))
+		}
+	})
+	_ = machine.Start()
+
+	AssertEventProcessed(t, machine.HandleEvent("unlock", "admin"), true)
+	AssertState(t, machine, "unlocked")
+	if strings.Join(order, ",") != "outer:locked,inner" {
+		t.Errorf("Expected outer middleware first, got %v", order)
+	}
+}
+
+func TestMiddleware_ShortCircuit(t *testing.T) {
+	machine := buildMiddlewareMachine().CreateInstance(WithMiddleware(func(next Handler) Handler {
+		return func(ctx context.Context, event Event) *EventResult {
+			return NewEventResult(false, false, "", "").WithRejection("unauthorized")
+		}
+	}))
+	_ = machine.Start()
+
+	result := machine.HandleEvent("unlock", "token:admin")
+	AssertEventProcessed(t, result, false)
+	if result.RejectionReason != "unauthorized" {
+		t.Errorf("Expected middleware rejection, got %q", result.RejectionReason)
+	}
+	AssertState(t, machine, "locked")
+}
+
+func TestMiddleware_EventLoop(t *testing.T) {
+	calls := 0
+	machine := buildMiddlewareMachine().CreateInstance(WithEventLoop(0), WithMiddleware(func(next Handler) Handler {
+		return func(ctx context.Context, event Event) *EventResult {
+			calls++
+			return next(ctx, event)
+		}
+	}))
+	_ = machine.Start()
+	defer func() { _ = machine.Stop() }()
+
+	AssertEventProcessed(t, <-machine.SendEventAsync("unlock", "token:admin"), true)
+	if calls != 1 {
+		t.Errorf("Expected middleware to run once, got %d", calls)
+	}
+}
@@ -0,0 +1,324 @@
+package fluo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// encodeMsgpack writes a value in MessagePack format. Supported values are nil,
+// booleans, numbers, strings, byte slices, slices and maps with string keys.
+func encodeMsgpack(buf *bytes.Buffer, value any) error {
+	switch v := value.(type) {
+	case nil:
+		buf.WriteByte(0xc0)
+	case bool:
+		if v {
+			buf.WriteByte(0xc3)
+		} else {
+			buf.WriteByte(0xc2)
+		}
+	case string:
+		writeMsgpackString(buf, v)
+	case []byte:
+		writeMsgpackBinary(buf, v)
+	case float32:
+		buf.WriteByte(0xca)
+		_ = binary.Write(buf, binary.BigEndian, math.Float32bits(v))
+	case float64:
+		buf.WriteByte(0xcb)
+		_ = binary.Write(buf, binary.BigEndian, math.Float64bits(v))
+	default:
+		return encodeMsgpackReflect(buf, reflect.ValueOf(value))
+	}
+	return nil
+}
+
+// encodeMsgpackReflect encodes integers, slices and maps by kind
+func encodeMsgpackReflect(buf *bytes.Buffer, rv reflect.Value) error {
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		buf.WriteByte(0xd3)
+		_ = binary.Write(buf, binary.BigEndian, rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		buf.WriteByte(0xcf)
+		_ = binary.Write(buf, binary.BigEndian, rv.Uint())
+	case reflect.String:
+		writeMsgpackString(buf, rv.String())
+	case reflect.Bool:
+		return encodeMsgpack(buf, rv.Bool())
+	case reflect.Float32, reflect.Float64:
+		return encodeMsgpack(buf, rv.Float())
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			buf.WriteByte(0xc0)
+			return nil
+		}
+		writeMsgpackHeader(buf, rv.Len(), 0x90, 15, 0xdc, 0xdd)
+		for i := 0; i < rv.Len(); i++ {
+			if err := encodeMsgpack(buf, rv.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("msgpack: unsupported map key type %s", rv.Type().Key())
+		}
+		if rv.IsNil() {
+			buf.WriteByte(0xc0)
+			return nil
+		}
+		keys := rv.MapKeys()
+		slices.SortFunc(keys, func(a, b reflect.Value) int {
+			return strings.Compare(a.String(), b.String())
+		})
+		writeMsgpackHeader(buf, len(keys), 0x80, 15, 0xde, 0xdf)
+		for _, key := range keys {
+			writeMsgpackString(buf, key.String())
+			if err := encodeMsgpack(buf, rv.MapIndex(key).Interface()); err != nil {
+				return err
+			}
+		}
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			buf.WriteByte(0xc0)
+			return nil
+		}
+		return encodeMsgpack(buf, rv.Elem().Interface())
+	default:
+		return fmt.Errorf("msgpack: unsupported type %s, register a value marshaler for it", rv.Type())
+	}
+	return nil
+}
+
+// writeMsgpackHeader writes a fixed, 16-bit or 32-bit length header
+func writeMsgpackHeader(buf *bytes.Buffer, length int, fixPrefix byte, fixMax int, prefix16, prefix32 byte) {
+	switch {
+	case length <= fixMax:
+		buf.WriteByte(fixPrefix | byte(length))
+	case length <= math.MaxUint16:
+		buf.WriteByte(prefix16)
+		_ = binary.Write(buf, binary.BigEndian, uint16(length))
+	default:
+		buf.WriteByte(prefix32)
+		_ = binary.Write(buf, binary.BigEndian, uint32(length))
+	}
+}
+
+// writeMsgpackString writes a str value
+func writeMsgpackString(buf *bytes.Buffer, s string) {
+	if len(s) <= math.MaxUint8 && len(s) > 31 {
+		buf.WriteByte(0xd9)
+		buf.WriteByte(byte(len(s)))
+	} else {
+		writeMsgpackHeader(buf, len(s), 0xa0, 31, 0xda, 0xdb)
+	}
+	buf.WriteString(s)
+}
+
+// writeMsgpackBinary writes a bin value
+func writeMsgpackBinary(buf *bytes.Buffer, b []byte) {
+	switch {
+	case len(b) <= math.MaxUint8:
+		buf.WriteByte(0xc4)
+		buf.WriteByte(byte(len(b)))
+	case len(b) <= math.MaxUint16:
+		buf.WriteByte(0xc5)
+		_ = binary.Write(buf, binary.BigEndian, uint16(len(b)))
+	default:
+		buf.WriteByte(0xc6)
+		_ = binary.Write(buf, binary.BigEndian, uint32(len(b)))
+	}
+	buf.Write(b)
+}
+
+// decodeMsgpack reads a single MessagePack value. Integers decode as int64
+// (uint64 when they overflow int64), floats as float64, arrays as []any and
+// maps as map[string]any.
+func decodeMsgpack(r *bytes.Reader) (any, error) {
+	prefix, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case prefix <= 0x7f:
+		return int64(prefix), nil
+	case prefix >= 0xe0:
+		return int64(int8(prefix)), nil
+	case prefix&0xf0 == 0x80:
+		return decodeMsgpackMap(r, int(prefix&0x0f))
+	case prefix&0xf0 == 0x90:
+		return decodeMsgpackArray(r, int(prefix&0x0f))
+	case prefix&0xe0 == 0xa0:
+		return readMsgpackString(r, int(prefix&0x1f))
+	}
+
+	switch prefix {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		length, err := readMsgpackLength(r, prefix-0xc4)
+		if err != nil {
+			return nil, err
+		}
+		return readMsgpackBytes(r, length)
+	case 0xca:
+		var bits uint32
+		if err := binary.Read(r, binary.BigEndian, &bits); err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(bits)), nil
+	case 0xcb:
+		var bits uint64
+		if err := binary.Read(r, binary.BigEndian, &bits); err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(bits), nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		var value uint64
+		switch prefix {
+		case 0xcc:
+			var v uint8
+			err = binary.Read(r, binary.BigEndian, &v)
+			value = uint64(v)
+		case 0xcd:
+			var v uint16
+			err = binary.Read(r, binary.BigEndian, &v)
+			value = uint64(v)
+		case 0xce:
+			var v uint32
+			err = binary.Read(r, binary.BigEndian, &v)
+			value = uint64(v)
+		default:
+			err = binary.Read(r, binary.BigEndian, &value)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if value > math.MaxInt64 {
+			return value, nil
+		}
+		return int64(value), nil
+	case 0xd0:
+		var v int8
+		err = binary.Read(r, binary.BigEndian, &v)
+		return int64(v), err
+	case 0xd1:
+		var v int16
+		err = binary.Read(r, binary.BigEndian, &v)
+		return int64(v), err
+	case 0xd2:
+		var v int32
+		err = binary.Read(r, binary.BigEndian, &v)
+		return int64(v), err
+	case 0xd3:
+		var v int64
+		err = binary.Read(r, binary.BigEndian, &v)
+		return v, err
+	case 0xd9, 0xda, 0xdb:
+		length, err := readMsgpackLength(r, prefix-0xd9)
+		if err != nil {
+			return nil, err
+		}
+		return readMsgpackString(r, length)
+	case 0xdc, 0xdd:
+		length, err := readMsgpackLength(r, prefix-0xdc+1)
+		if err != nil {
+			return nil, err
+		}
+		return decodeMsgpackArray(r, length)
+	case 0xde, 0xdf:
+		length, err := readMsgpackLength(r, prefix-0xde+1)
+		if err != nil {
+			return nil, err
+		}
+		return decodeMsgpackMap(r, length)
+	}
+
+	return nil, fmt.Errorf("msgpack: unsupported format 0x%02x", prefix)
+}
+
+// readMsgpackLength reads an 8, 16 or 32-bit length (size 0, 1 or 2)
+func readMsgpackLength(r *bytes.Reader, size byte) (int, error) {
+	switch size {
+	case 0:
+		b, err := r.ReadByte()
+		return int(b), err
+	case 1:
+		var v uint16
+		err := binary.Read(r, binary.BigEndian, &v)
+		return int(v), err
+	default:
+		var v uint32
+		err := binary.Read(r, binary.BigEndian, &v)
+		return int(v), err
+	}
+}
+
+// readMsgpackBytes reads length raw bytes
+func readMsgpackBytes(r *bytes.Reader, length int) ([]byte, error) {
+	if length > r.Len() {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b := make([]byte, length)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// readMsgpackString reads a string of the given length
+func readMsgpackString(r *bytes.Reader, length int) (string, error) {
+	b, err := readMsgpackBytes(r, length)
+	return string(b), err
+}
+
+// decodeMsgpackArray reads length array elements
+func decodeMsgpackArray(r *bytes.Reader, length int) ([]any, error) {
+	if length > r.Len() {
+		return nil, io.ErrUnexpectedEOF
+	}
+	values := make([]any, 0, length)
+	for i := 0; i < length; i++ {
+		value, err := decodeMsgpack(r)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// decodeMsgpackMap reads length map entries with string keys
+func decodeMsgpackMap(r *bytes.Reader, length int) (map[string]any, error) {
+	if length > r.Len() {
+		return nil, io.ErrUnexpectedEOF
+	}
+	values := make(map[string]any, length)
+	for i := 0; i < length; i++ {
+		key, err := decodeMsgpack(r)
+		if err != nil {
+			return nil, err
+		}
+		keyString, ok := key.(string)
+		if !ok {
+			return nil, fmt.Errorf("msgpack: unsupported map key %T", key)
+		}
+		value, err := decodeMsgpack(r)
+		if err != nil {
+			return nil, err
+		}
+		values[keyString] = value
+	}
+	return values, nil
+}
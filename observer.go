@@ -1,6 +1,12 @@
 package fluo
 
-import "fmt"
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
 
 // Observer represents an entity that observes state machine lifecycle
 type Observer interface {
@@ -29,7 +35,9 @@ type ExtendedObserver interface {
 	// OnError is called when an error occurs during processing
 	OnError(err error, ctx Context)
 
-	// OnActionExecution is called when an action is executed
+	// OnActionExecution is called when a transition action is executed.
+	// actionType is the name the action was declared with, or for an unnamed
+	// action "transition", "internal" or "completion_transition".
 	OnActionExecution(actionType string, state string, event Event, ctx Context)
 
 	// OnMachineStarted is called when the state machine starts
@@ -39,6 +47,95 @@ type ExtendedObserver interface {
 	OnMachineStopped(ctx Context)
 }
 
+// TimerObserver is notified when a timed transition fires
+type TimerObserver interface {
+	// OnTimerFired is called when a state's After() timer expires, before the timed transition is taken
+	OnTimerFired(state string, after time.Duration, ctx Context)
+}
+
+// RegionCompletionObserver is notified when a region of a parallel state reaches a final state
+type RegionCompletionObserver interface {
+	// OnRegionCompleted is called after the region enters its final state, before the
+	// parallel state's own completion is checked
+	OnRegionCompleted(parallelState string, region string, ctx Context)
+}
+
+// ForkBranchObserver is notified when a fork starts one of its branches
+type ForkBranchObserver interface {
+	// OnForkBranch is called after the branch's action ran, before its target
+	// is entered. Branches declared without a label are labelled by their target.
+	OnForkBranch(fork string, branch string, target string, ctx Context)
+}
+
+// RegionObserver is notified about the states of a single region, registered
+// with Machine.AddRegionObserver
+type RegionObserver interface {
+	// OnRegionStateEnter is called when the region enters one of its states,
+	// including the region's initial state when the parallel state is entered
+	OnRegionStateEnter(parallelState string, region string, state string, ctx Context)
+}
+
+// PanicObserver is notified about recovered guard and action panics
+type PanicObserver interface {
+	// OnPanic is called after a panic is recovered, before the panic policy is applied
+	OnPanic(panicErr *PanicError, ctx Context)
+}
+
+// Stage identifies where in the machine an error reported to
+// ErrorStageObserver came from
+type Stage int
+
+const (
+	// StageGuard covers guards that panicked or timed out
+	StageGuard Stage = iota
+	// StageAction covers transition, entry and exit actions that returned an
+	// error, panicked or timed out
+	StageAction
+	// StageConfiguration covers definition problems found while the machine
+	// runs, such as a missing initial state or a fork without transitions
+	StageConfiguration
+	// StageObserver covers observers that panicked
+	StageObserver
+)
+
+// String returns the stage name
+func (s Stage) String() string {
+	switch s {
+	case StageGuard:
+		return "guard"
+	case StageAction:
+		return "action"
+	case StageConfiguration:
+		return "configuration"
+	case StageObserver:
+		return "observer"
+	default:
+		return fmt.Sprintf("Stage(%d)", int(s))
+	}
+}
+
+// ErrorStageObserver is notified about errors together with the stage that
+// produced them. The error keeps its type, such as *PanicError or
+// *TimeoutError, for errors.As.
+type ErrorStageObserver interface {
+	// OnStageError is called when a guard, action, configuration or observer error occurs
+	OnStageError(stage Stage, err error, ctx Context)
+}
+
+// ActiveStateLimitObserver is notified when the active state set outgrows its configured bound
+type ActiveStateLimitObserver interface {
+	// OnActiveStateLimitExceeded is called once each time the active state set grows
+	// past the limit, with the active states in ID order
+	OnActiveStateLimitExceeded(active []string, limit int, ctx Context)
+}
+
+// InternalTransitionObserver is notified about internal transitions, which
+// handle an event without leaving their state and so raise no OnTransition
+type InternalTransitionObserver interface {
+	// OnInternalTransition is called when an internal transition of state has handled the event
+	OnInternalTransition(state string, event Event, ctx Context)
+}
+
 // BaseObserver provides a default implementation with no-op methods
 type BaseObserver struct{}
 
@@ -87,169 +184,502 @@ func (o *BaseObserver) OnMachineStopped(ctx Context) {
 	// Default implementation - no operation
 }
 
-// ObserverManager manages a collection of observers
+// OnTimerFired implements the optional TimerObserver method
+func (o *BaseObserver) OnTimerFired(state string, after time.Duration, ctx Context) {
+	// Default implementation - no operation
+}
+
+// OnRegionCompleted implements the optional RegionCompletionObserver method
+func (o *BaseObserver) OnRegionCompleted(parallelState string, region string, ctx Context) {
+	// Default implementation - no operation
+}
+
+// OnPanic implements the optional PanicObserver method
+func (o *BaseObserver) OnPanic(panicErr *PanicError, ctx Context) {
+	// Default implementation - no operation
+}
+
+// OnStageError implements the optional ErrorStageObserver method
+func (o *BaseObserver) OnStageError(stage Stage, err error, ctx Context) {
+	// Default implementation - no operation
+}
+
+// OnActiveStateLimitExceeded implements the optional ActiveStateLimitObserver method
+func (o *BaseObserver) OnActiveStateLimitExceeded(active []string, limit int, ctx Context) {
+	// Default implementation - no operation
+}
+
+// OnInternalTransition implements the optional InternalTransitionObserver method
+func (o *BaseObserver) OnInternalTransition(state string, event Event, ctx Context) {
+	// Default implementation - no operation
+}
+
+// ObserverManager manages a collection of observers.
+//
+// Each notification is delivered to observers in registration order, and
+// notifications are delivered in the order the machine raised them. A panic
+// in one observer is recovered and reported with StageObserver; the other
+// observers and event processing carry on.
+//
+// By default notifications are delivered synchronously on the goroutine
+// processing the event, while the machine is locked, so observers see the
+// context as it is at that point and must not call back into the machine.
+// With EnableAsync they are delivered by a single background goroutine
+// instead: the order above still holds, but observers run after the fact and
+// may see the context of a later step.
 type ObserverManager struct {
-	observers []Observer
+	// Observers may be added and removed while notifications are dispatched
+	mutex           sync.RWMutex
+	observers       []Observer
+	filters         []*ObserverFilter // Filter of each observer, nil when unfiltered
+	regionObservers map[string][]RegionObserver
+
+	// Async dispatch queue, nil for synchronous delivery
+	asyncMutex sync.RWMutex
+	queue      chan func()
+	stop       chan struct{}
+	drained    chan struct{}
+	dropped    atomic.Int64
 }
 
 // NewObserverManager creates a new observer manager
 func NewObserverManager() *ObserverManager {
 	return &ObserverManager{
-		observers: make([]Observer, 0),
+		observers:       make([]Observer, 0),
+		filters:         make([]*ObserverFilter, 0),
+		regionObservers: make(map[string][]RegionObserver),
+	}
+}
+
+// ObserverFilter limits the notifications delivered to an observer to those
+// about some states or raised by some events. Notifications about the machine
+// as a whole, such as start, stop and errors, are always delivered.
+type ObserverFilter struct {
+	// States keeps notifications involving one of these states or a state
+	// nested in them. Empty keeps every state.
+	States []string
+
+	// Events keeps notifications raised while processing one of these
+	// events. Empty keeps every event, and notifications raised outside event
+	// processing.
+	Events []string
+}
+
+// about describes what a notification concerns, for matching filters
+type about struct {
+	states []string
+	event  string
+}
+
+// matches reports whether a notification passes the filter
+func (f *ObserverFilter) matches(subject *about) bool {
+	if f == nil || subject == nil {
+		return true
+	}
+	if len(f.Events) > 0 && !slices.Contains(f.Events, subject.event) {
+		return false
+	}
+	if len(f.States) == 0 {
+		return true
+	}
+	for _, state := range subject.states {
+		for _, wanted := range f.States {
+			if StatePath(wanted).Contains(StatePath(state)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// aboutStates describes a notification about states, raised by the event ctx is processing
+func aboutStates(ctx Context, states ...string) *about {
+	subject := &about{states: states}
+	if ctx != nil {
+		subject.event = ctx.GetEventName()
+	}
+	return subject
+}
+
+// aboutEvent describes a notification about states raised by an event
+func aboutEvent(event Event, states ...string) *about {
+	subject := &about{states: states}
+	if event != nil {
+		subject.event = event.GetName()
+	}
+	return subject
+}
+
+// aboutRejection describes the rejection of an event in the current state
+func aboutRejection(event Event, ctx Context) *about {
+	if ctx == nil {
+		return aboutEvent(event)
 	}
+	return aboutEvent(event, ctx.GetCurrentState())
 }
 
 // AddObserver adds an observer to the manager
 func (om *ObserverManager) AddObserver(observer Observer) {
+	om.mutex.Lock()
+	defer om.mutex.Unlock()
 	om.observers = append(om.observers, observer)
+	om.filters = append(om.filters, nil)
+}
+
+// AddObserverFiltered adds an observer receiving only the notifications that
+// pass the filter. Filtering happens before delivery, so observers filtered
+// out cost nothing beyond the match.
+func (om *ObserverManager) AddObserverFiltered(observer Observer, filter ObserverFilter) {
+	om.mutex.Lock()
+	defer om.mutex.Unlock()
+	om.observers = append(om.observers, observer)
+	om.filters = append(om.filters, &filter)
 }
 
 // RemoveObserver removes an observer from the manager
 func (om *ObserverManager) RemoveObserver(observer Observer) {
+	om.mutex.Lock()
+	defer om.mutex.Unlock()
 	for i, obs := range om.observers {
 		if obs == observer {
-			om.observers = append(om.observers[:i], om.observers[i+1:]...)
+			om.observers = slices.Delete(om.observers, i, i+1)
+			om.filters = slices.Delete(om.filters, i, i+1)
 			break
 		}
 	}
 }
 
-// NotifyTransition notifies all observers of a state transition
-func (om *ObserverManager) NotifyTransition(from string, to string, event Event, ctx Context) {
-	observers := make([]Observer, len(om.observers))
-	copy(observers, om.observers)
+// AddRegionObserver adds an observer of the region at path "<parallel state>.<region>"
+func (om *ObserverManager) AddRegionObserver(regionPath string, observer RegionObserver) {
+	om.mutex.Lock()
+	defer om.mutex.Unlock()
+	om.regionObservers[regionPath] = append(om.regionObservers[regionPath], observer)
+}
 
-	for _, observer := range observers {
-		func() {
-			defer func() {
-				if r := recover(); r != nil {
-					// Observer panicked - log it if there's an error observer but don't crash
-					if extObs, ok := observer.(ExtendedObserver); ok {
-						// Try to notify about the observer error, but catch any panic from that too
-						func() {
-							defer func() { _ = recover() }()
-							extObs.OnError(fmt.Errorf("observer panic in OnTransition: %v", r), ctx)
-						}()
+// RemoveRegionObserver removes a region observer from every region it observes
+func (om *ObserverManager) RemoveRegionObserver(observer RegionObserver) {
+	om.mutex.Lock()
+	defer om.mutex.Unlock()
+	for regionPath, observers := range om.regionObservers {
+		om.regionObservers[regionPath] = slices.DeleteFunc(observers, func(obs RegionObserver) bool {
+			return obs == observer
+		})
+		if len(om.regionObservers[regionPath]) == 0 {
+			delete(om.regionObservers, regionPath)
+		}
+	}
+}
+
+// WithAsyncObservers delivers the machine's observer notifications from a
+// background goroutine through a queue of the given size, see
+// ObserverManager.EnableAsync
+func WithAsyncObservers(buffer int) MachineOption {
+	return func(sm *StateMachine) {
+		sm.observers.EnableAsync(buffer)
+	}
+}
+
+// EnableAsync delivers notifications from a background goroutine through a
+// queue holding up to buffer notifications. Notifications raised while the
+// queue is full are dropped and counted by Dropped, so a slow observer never
+// blocks event processing. Calling it again while async has no effect.
+func (om *ObserverManager) EnableAsync(buffer int) {
+	om.asyncMutex.Lock()
+	defer om.asyncMutex.Unlock()
+
+	if om.queue != nil {
+		return
+	}
+	queue := make(chan func(), max(buffer, 1))
+	stop := make(chan struct{})
+	drained := make(chan struct{})
+	om.queue, om.stop, om.drained = queue, stop, drained
+	go func() {
+		defer close(drained)
+		for {
+			select {
+			case delivery := <-queue:
+				delivery()
+			case <-stop:
+				for {
+					select {
+					case delivery := <-queue:
+						delivery()
+					default:
+						return
 					}
 				}
-			}()
-			observer.OnTransition(from, to, event, ctx)
-		}()
+			}
+		}
+	}()
+}
+
+// Flush blocks until the notifications queued so far have been delivered
+func (om *ObserverManager) Flush() {
+	om.asyncMutex.RLock()
+	queue, drained := om.queue, om.drained
+	om.asyncMutex.RUnlock()
+	if queue == nil {
+		return
+	}
+
+	flushed := make(chan struct{})
+	select {
+	case queue <- func() { close(flushed) }:
+	case <-drained:
+		return
+	}
+	select {
+	case <-flushed:
+	case <-drained:
+	}
+}
+
+// Close delivers the queued notifications and returns to synchronous delivery
+func (om *ObserverManager) Close() {
+	om.asyncMutex.Lock()
+	stop, drained := om.stop, om.drained
+	om.queue, om.stop, om.drained = nil, nil, nil
+	om.asyncMutex.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-drained
+	}
+}
+
+// Dropped returns how many notifications were dropped because the async queue was full
+func (om *ObserverManager) Dropped() int64 {
+	return om.dropped.Load()
+}
+
+// dispatch delivers a notification to every observer registered now whose
+// filter it passes, either right away or through the async queue. A nil
+// subject concerns the machine as a whole and passes every filter.
+func (om *ObserverManager) dispatch(method string, ctx Context, subject *about, notify func(observer Observer)) {
+	om.mutex.RLock()
+	observers := make([]Observer, 0, len(om.observers))
+	for i, observer := range om.observers {
+		if om.filters[i].matches(subject) {
+			observers = append(observers, observer)
+		}
+	}
+	om.mutex.RUnlock()
+	if len(observers) == 0 {
+		return
+	}
+	om.deliver(func() {
+		for _, observer := range observers {
+			om.isolate(observer, method, ctx, func() { notify(observer) })
+		}
+	})
+}
+
+// deliver runs a delivery now or queues it when async
+func (om *ObserverManager) deliver(delivery func()) {
+	om.asyncMutex.RLock()
+	queue := om.queue
+	om.asyncMutex.RUnlock()
+
+	if queue == nil {
+		delivery()
+		return
+	}
+	select {
+	case queue <- delivery:
+	default:
+		om.dropped.Add(1)
 	}
 }
 
+// isolate calls an observer method, recovering and reporting a panic so it
+// cannot reach the other observers or the machine
+func (om *ObserverManager) isolate(observer any, method string, ctx Context, call func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			om.observerPanicked(observer, method, r, ctx)
+		}
+	}()
+	call()
+}
+
+// NotifyTransition notifies all observers of a state transition
+func (om *ObserverManager) NotifyTransition(from string, to string, event Event, ctx Context) {
+	om.dispatch("OnTransition", ctx, aboutEvent(event, from, to), func(observer Observer) {
+		observer.OnTransition(from, to, event, ctx)
+	})
+}
+
+// NotifyInternalTransition notifies observers implementing
+// InternalTransitionObserver of an internal transition
+func (om *ObserverManager) NotifyInternalTransition(state string, event Event, ctx Context) {
+	om.dispatch("OnInternalTransition", ctx, aboutEvent(event, state), func(observer Observer) {
+		if internalObs, ok := observer.(InternalTransitionObserver); ok {
+			internalObs.OnInternalTransition(state, event, ctx)
+		}
+	})
+}
+
 // NotifyStateEnter notifies all observers of state entry
 func (om *ObserverManager) NotifyStateEnter(state string, ctx Context) {
-	observers := make([]Observer, len(om.observers))
-	copy(observers, om.observers)
+	om.dispatch("OnStateEnter", ctx, aboutStates(ctx, state), func(observer Observer) {
+		observer.OnStateEnter(state, ctx)
+	})
+}
+
+// NotifyStateExit notifies all observers of state exit
+func (om *ObserverManager) NotifyStateExit(state string, ctx Context) {
+	om.dispatch("OnStateExit", ctx, aboutStates(ctx, state), func(observer Observer) {
+		if extObs, ok := observer.(ExtendedObserver); ok {
+			extObs.OnStateExit(state, ctx)
+		}
+	})
+}
 
-	for _, observer := range observers {
+// observerPanicked reports a panicking observer to the observer itself, if it
+// observes errors, and to every stage error observer, recovering from any
+// panic in that reporting too
+func (om *ObserverManager) observerPanicked(observer any, method string, r any, ctx Context) {
+	err := fmt.Errorf("observer panic in %s: %v", method, r)
+	if extObs, ok := observer.(ExtendedObserver); ok {
 		func() {
-			defer func() {
-				if r := recover(); r != nil {
-					if extObs, ok := observer.(ExtendedObserver); ok {
-						func() {
-							defer func() { _ = recover() }()
-							extObs.OnError(fmt.Errorf("observer panic in OnStateEnter: %v", r), ctx)
-						}()
-					}
-				}
-			}()
-			observer.OnStateEnter(state, ctx)
+			defer func() { _ = recover() }()
+			extObs.OnError(err, ctx)
 		}()
 	}
+	om.NotifyStageError(StageObserver, err, ctx)
 }
 
-// NotifyStateExit notifies all observers of state exit
-func (om *ObserverManager) NotifyStateExit(state string, ctx Context) {
-	observers := make([]Observer, len(om.observers))
-	copy(observers, om.observers)
-
-	for _, observer := range observers {
-		if extObs, ok := observer.(ExtendedObserver); ok {
-			func() {
-				defer func() {
-					if r := recover(); r != nil {
-						func() {
-							defer func() { _ = recover() }()
-							extObs.OnError(fmt.Errorf("observer panic in OnStateExit: %v", r), ctx)
-						}()
-					}
+// NotifyStageError notifies all stage error observers of an error. A panic in
+// one of them is recovered and not reported again.
+func (om *ObserverManager) NotifyStageError(stage Stage, err error, ctx Context) {
+	om.mutex.RLock()
+	observers := slices.Clone(om.observers)
+	om.mutex.RUnlock()
+	om.deliver(func() {
+		for _, observer := range observers {
+			if stageObs, ok := observer.(ErrorStageObserver); ok {
+				func() {
+					defer func() { _ = recover() }()
+					stageObs.OnStageError(stage, err, ctx)
 				}()
-				extObs.OnStateExit(state, ctx)
-			}()
+			}
 		}
-	}
+	})
 }
 
 // NotifyGuardEvaluation notifies all observers of guard evaluation
 func (om *ObserverManager) NotifyGuardEvaluation(from string, to string, event Event, result bool, ctx Context) {
-	observers := make([]Observer, len(om.observers))
-	copy(observers, om.observers)
-
-	for _, observer := range observers {
+	om.dispatch("OnGuardEvaluation", ctx, aboutEvent(event, from, to), func(observer Observer) {
 		if extObs, ok := observer.(ExtendedObserver); ok {
 			extObs.OnGuardEvaluation(from, to, event, result, ctx)
 		}
-	}
+	})
 }
 
 // NotifyEventRejected notifies all observers of event rejection
 func (om *ObserverManager) NotifyEventRejected(event Event, reason string, ctx Context) {
-	observers := make([]Observer, len(om.observers))
-	copy(observers, om.observers)
-
-	for _, observer := range observers {
+	om.dispatch("OnEventRejected", ctx, aboutRejection(event, ctx), func(observer Observer) {
 		if extObs, ok := observer.(ExtendedObserver); ok {
 			extObs.OnEventRejected(event, reason, ctx)
 		}
-	}
+	})
 }
 
 // NotifyError notifies all observers of errors
 func (om *ObserverManager) NotifyError(err error, ctx Context) {
-	observers := make([]Observer, len(om.observers))
-	copy(observers, om.observers)
-
-	for _, observer := range observers {
+	om.dispatch("OnError", ctx, nil, func(observer Observer) {
 		if extObs, ok := observer.(ExtendedObserver); ok {
 			extObs.OnError(err, ctx)
 		}
-	}
+	})
 }
 
 // NotifyActionExecution notifies all observers of action execution
 func (om *ObserverManager) NotifyActionExecution(actionType string, state string, event Event, ctx Context) {
-	observers := make([]Observer, len(om.observers))
-	copy(observers, om.observers)
-
-	for _, observer := range observers {
+	om.dispatch("OnActionExecution", ctx, aboutEvent(event, state), func(observer Observer) {
 		if extObs, ok := observer.(ExtendedObserver); ok {
 			extObs.OnActionExecution(actionType, state, event, ctx)
 		}
-	}
+	})
 }
 
 // NotifyMachineStarted notifies all observers that the machine has started
 func (om *ObserverManager) NotifyMachineStarted(ctx Context) {
-	observers := make([]Observer, len(om.observers))
-	copy(observers, om.observers)
-
-	for _, observer := range observers {
+	om.dispatch("OnMachineStarted", ctx, nil, func(observer Observer) {
 		if extObs, ok := observer.(ExtendedObserver); ok {
 			extObs.OnMachineStarted(ctx)
 		}
-	}
+	})
 }
 
 // NotifyMachineStopped notifies all observers that the machine has stopped
 func (om *ObserverManager) NotifyMachineStopped(ctx Context) {
-	observers := make([]Observer, len(om.observers))
-	copy(observers, om.observers)
-
-	for _, observer := range observers {
+	om.dispatch("OnMachineStopped", ctx, nil, func(observer Observer) {
 		if extObs, ok := observer.(ExtendedObserver); ok {
 			extObs.OnMachineStopped(ctx)
 		}
+	})
+}
+
+// NotifyTimerFired notifies all timer observers that a timed transition fired
+func (om *ObserverManager) NotifyTimerFired(state string, after time.Duration, ctx Context) {
+	om.dispatch("OnTimerFired", ctx, aboutStates(ctx, state), func(observer Observer) {
+		if timerObs, ok := observer.(TimerObserver); ok {
+			timerObs.OnTimerFired(state, after, ctx)
+		}
+	})
+}
+
+// NotifyRegionCompleted notifies all region completion observers that a region reached a final state
+func (om *ObserverManager) NotifyRegionCompleted(parallelState string, region string, ctx Context) {
+	om.dispatch("OnRegionCompleted", ctx, aboutStates(ctx, parallelState+"."+region), func(observer Observer) {
+		if regionObs, ok := observer.(RegionCompletionObserver); ok {
+			regionObs.OnRegionCompleted(parallelState, region, ctx)
+		}
+	})
+}
+
+// NotifyForkBranch notifies all fork branch observers that a fork started a branch
+func (om *ObserverManager) NotifyForkBranch(fork string, branch string, target string, ctx Context) {
+	om.dispatch("OnForkBranch", ctx, aboutStates(ctx, fork, target), func(observer Observer) {
+		if branchObs, ok := observer.(ForkBranchObserver); ok {
+			branchObs.OnForkBranch(fork, branch, target, ctx)
+		}
+	})
+}
+
+// NotifyRegionStateEnter notifies the observers of a region that it entered a state
+func (om *ObserverManager) NotifyRegionStateEnter(parallelState string, region string, state string, ctx Context) {
+	om.mutex.RLock()
+	observers := slices.Clone(om.regionObservers[parallelState+"."+region])
+	om.mutex.RUnlock()
+	if len(observers) == 0 {
+		return
 	}
+	om.deliver(func() {
+		for _, observer := range observers {
+			om.isolate(observer, "OnRegionStateEnter", ctx, func() {
+				observer.OnRegionStateEnter(parallelState, region, state, ctx)
+			})
+		}
+	})
+}
+
+// NotifyPanic notifies all panic observers that a guard or action panic was recovered
+func (om *ObserverManager) NotifyPanic(panicErr *PanicError, ctx Context) {
+	om.dispatch("OnPanic", ctx, nil, func(observer Observer) {
+		if panicObs, ok := observer.(PanicObserver); ok {
+			panicObs.OnPanic(panicErr, ctx)
+		}
+	})
+}
+
+// NotifyActiveStateLimitExceeded notifies all active state limit observers that the active state set outgrew its bound
+func (om *ObserverManager) NotifyActiveStateLimitExceeded(active []string, limit int, ctx Context) {
+	om.dispatch("OnActiveStateLimitExceeded", ctx, nil, func(observer Observer) {
+		if limitObs, ok := observer.(ActiveStateLimitObserver); ok {
+			limitObs.OnActiveStateLimitExceeded(active, limit, ctx)
+		}
+	})
 }
@@ -1,6 +1,8 @@
 package fluo
 
 import (
+	"errors"
+	"slices"
 	"sync"
 	"testing"
 	"time"
@@ -510,3 +512,265 @@ func TestObserver_PerformanceWithManyObservers(t *testing.T) {
 
 	t.Logf("Notified %d observers in %v", numObservers, duration)
 }
+
+// regionRecorder records the states a region observer is notified about
+type regionRecorder struct {
+	entered []string
+}
+
+func (r *regionRecorder) OnRegionStateEnter(parallelState, region, state string, ctx Context) {
+	r.entered = append(r.entered, parallelState+"/"+region+"/"+state)
+}
+
+func TestObserver_RegionObserver(t *testing.T) {
+	machine := buildBroadcastMachine(WithRegionBroadcast())
+	security := &regionRecorder{}
+	if err := machine.AddRegionObserver("home", "security", security); err != nil {
+		t.Fatalf("Failed to add region observer: %v", err)
+	}
+	_ = machine.Start()
+	machine.HandleEvent("power_on", nil)
+	machine.HandleEvent("night", nil)
+
+	expected := []string{"home/security/home.security.disarmed", "home/security/home.security.armed"}
+	if !slices.Equal(security.entered, expected) {
+		t.Errorf("Expected %v, got %v", expected, security.entered)
+	}
+
+	machine.RemoveRegionObserver(security)
+	_ = machine.Reset()
+	machine.HandleEvent("power_on", nil)
+	if len(security.entered) != len(expected) {
+		t.Errorf("Expected no notifications after removal, got %v", security.entered)
+	}
+}
+
+func TestObserver_RegionObserverUnknownRegion(t *testing.T) {
+	machine := buildBroadcastMachine()
+	if err := machine.AddRegionObserver("home", "garden", &regionRecorder{}); err == nil {
+		t.Error("Expected an error for an unknown region")
+	}
+	if err := machine.AddRegionObserver("off", "security", &regionRecorder{}); err == nil {
+		t.Error("Expected an error for a state that is not parallel")
+	}
+}
+
+// stageRecorder records the errors it is notified about by stage
+type stageRecorder struct {
+	BaseObserver
+	stages []Stage
+	errs   []error
+}
+
+func (r *stageRecorder) OnStageError(stage Stage, err error, ctx Context) {
+	r.stages = append(r.stages, stage)
+	r.errs = append(r.errs, err)
+}
+
+// panickingObserver panics on every transition
+type panickingObserver struct {
+	BaseObserver
+}
+
+func (o *panickingObserver) OnTransition(from, to string, event Event, ctx Context) {
+	panic("observer boom")
+}
+
+func TestObserver_StageErrors(t *testing.T) {
+	machine := NewMachine().
+		State("idle").Initial().
+		To("checked").On("check").When(func(ctx Context) bool {
+		panic("guard boom")
+	}).
+		To("done").On("go").Do(func(ctx Context) error {
+		return errors.New("action failed")
+	}).
+		To("next").On("next").
+		State("checked").
+		State("done").
+		State("next").
+		Build().CreateInstance()
+	recorder := &stageRecorder{}
+	machine.AddObserver(recorder)
+	machine.AddObserver(&panickingObserver{})
+	_ = machine.Start()
+
+	machine.HandleEvent("check", nil)
+	machine.HandleEvent("go", nil)
+	machine.HandleEvent("next", nil)
+
+	expected := []Stage{StageGuard, StageAction, StageObserver}
+	if !slices.Equal(recorder.stages, expected) {
+		t.Fatalf("Expected stages %v, got %v", expected, recorder.stages)
+	}
+	if !IsPanicError(recorder.errs[0]) {
+		t.Errorf("Expected the guard panic to keep its type, got %T", recorder.errs[0])
+	}
+	if recorder.errs[1].Error() != "action failed" {
+		t.Errorf("Expected the action error, got %v", recorder.errs[1])
+	}
+}
+
+func TestObserver_StageConfigurationError(t *testing.T) {
+	// The builder rejects a definition without an initial state
+	machine := newStateMachine()
+	recorder := &stageRecorder{}
+	machine.AddObserver(recorder)
+
+	err := machine.Start()
+	if !IsConfigurationError(err) {
+		t.Fatalf("Expected a configuration error, got %v", err)
+	}
+	if !slices.Equal(recorder.stages, []Stage{StageConfiguration}) || recorder.errs[0] != err {
+		t.Errorf("Expected the configuration error to be reported, got %v %v", recorder.stages, recorder.errs)
+	}
+	if StageConfiguration.String() != "configuration" {
+		t.Errorf("Unexpected stage name %s", StageConfiguration)
+	}
+}
+
+// rejectionPanicObserver panics whenever an event is rejected
+type rejectionPanicObserver struct {
+	BaseObserver
+}
+
+func (o *rejectionPanicObserver) OnEventRejected(event Event, reason string, ctx Context) {
+	panic("rejection boom")
+}
+
+// orderRecorder records transitions, optionally blocking until released
+type orderRecorder struct {
+	BaseObserver
+	release     chan struct{}
+	transitions []string
+}
+
+func (r *orderRecorder) OnTransition(from, to string, event Event, ctx Context) {
+	if r.release != nil {
+		<-r.release
+	}
+	r.transitions = append(r.transitions, from+"->"+to)
+}
+
+func buildToggleMachine(opts ...MachineOption) Machine {
+	return NewMachine().
+		State("off").Initial().
+		To("on").On("toggle").
+		State("on").
+		To("off").On("toggle").
+		Build().CreateInstance(opts...)
+}
+
+func TestObserver_PanicIsolation(t *testing.T) {
+	machine := buildToggleMachine()
+	recorder := &stageRecorder{}
+	machine.AddObserver(&rejectionPanicObserver{})
+	machine.AddObserver(recorder)
+	_ = machine.Start()
+
+	AssertEventProcessed(t, machine.HandleEvent("unknown", nil), false)
+	AssertEventProcessed(t, machine.HandleEvent("toggle", nil), true)
+	if !slices.Equal(recorder.stages, []Stage{StageObserver}) {
+		t.Errorf("Expected the observer panic to be reported, got %v", recorder.stages)
+	}
+}
+
+func TestObserver_AsyncDelivery(t *testing.T) {
+	machine := buildToggleMachine(WithAsyncObservers(16))
+	recorder := &orderRecorder{}
+	machine.AddObserver(recorder)
+	_ = machine.Start()
+	defer machine.Observers().Close()
+
+	for i := 0; i < 4; i++ {
+		machine.HandleEvent("toggle", nil)
+	}
+	machine.Observers().Flush()
+
+	expected := []string{"off->on", "on->off", "off->on", "on->off"}
+	if !slices.Equal(recorder.transitions, expected) {
+		t.Errorf("Expected transitions %v in order, got %v", expected, recorder.transitions)
+	}
+}
+
+func TestObserver_AsyncDropsWhenFull(t *testing.T) {
+	machine := buildToggleMachine(WithAsyncObservers(1))
+	recorder := &orderRecorder{release: make(chan struct{})}
+	machine.AddObserver(recorder)
+	_ = machine.Start()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			machine.HandleEvent("toggle", nil)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected a blocked observer not to hold up event processing")
+	}
+	if machine.Observers().Dropped() == 0 {
+		t.Error("Expected notifications to be dropped while the queue was full")
+	}
+
+	close(recorder.release)
+	machine.Observers().Close()
+	AssertState(t, machine, "off")
+}
+
+func TestObserver_FilteredByState(t *testing.T) {
+	builder := NewMachine()
+	builder.State("off").Initial().
+		To("on").On("toggle")
+	builder.Composite("on", func(c CompositeScope) {
+		c.State("bright").Initial().
+			To("off").On("toggle")
+	})
+	machine := builder.Build().CreateInstance()
+	_ = machine.Start()
+	AssertEventProcessed(t, machine.HandleEvent("toggle", nil), true)
+
+	observer := NewTestObserver()
+	machine.AddObserverFiltered(observer, ObserverFilter{States: []string{"on"}})
+	machine.HandleEvent("toggle", nil)
+	machine.HandleEvent("missing", nil)
+	machine.HandleEvent("toggle", nil)
+	_ = machine.Stop()
+
+	if len(observer.Transitions) != 2 {
+		t.Errorf("Expected both toggles to pass the filter, got %d", len(observer.Transitions))
+	}
+	if len(observer.StateEnters) != 1 || observer.StateEnters[0].State != "on.bright" {
+		t.Errorf("Expected only the entry of the nested state, got %+v", observer.StateEnters)
+	}
+	if len(observer.EventRejects) != 0 {
+		t.Errorf("Expected the rejection in 'off' to be filtered out, got %d", len(observer.EventRejects))
+	}
+	if len(observer.Stopped) != 1 {
+		t.Error("Expected machine notifications to bypass the filter")
+	}
+}
+
+func TestObserver_FilteredByEvent(t *testing.T) {
+	machine := buildToggleMachine()
+	observer := NewTestObserver()
+	machine.AddObserverFiltered(observer, ObserverFilter{Events: []string{"reset"}})
+	_ = machine.Start()
+
+	machine.HandleEvent("toggle", nil)
+	machine.HandleEvent("reset", nil)
+	if len(observer.Transitions) != 0 || len(observer.StateEnters) != 0 {
+		t.Errorf("Expected toggles and the initial entry to be filtered out, got %+v", observer.Transitions)
+	}
+	if len(observer.EventRejects) != 1 || observer.EventRejects[0].Event.GetName() != "reset" {
+		t.Errorf("Expected the rejected reset event, got %+v", observer.EventRejects)
+	}
+
+	machine.RemoveObserver(observer)
+	machine.HandleEvent("reset", nil)
+	if len(observer.EventRejects) != 1 {
+		t.Error("Expected no notifications after removal")
+	}
+}
@@ -0,0 +1,71 @@
+package fluo
+
+// ErrorContextKey is the transient context key holding the error that routed
+// the machine to the error state of a transition declared with OnError
+const ErrorContextKey = "__error"
+
+// recordRoutedError stores an error routed to an error state in the context
+// and reports it to observers
+func (sm *StateMachine) recordRoutedError(err error) error {
+	sm.context.SetTransient(ErrorContextKey, err)
+	sm.observers.NotifyError(err, sm.context)
+	return err
+}
+
+// finishErrorRouting completes the result of a transition: a failed entry
+// action of the target routes the machine on to the transition's error state,
+// and the routed error, or else the failed entry action's error, is attached
+// to the result.
+// The caller must hold the machine mutex.
+func (sm *StateMachine) finishErrorRouting(result *EventResult, transition *Transition, routedErr error, event Event) *EventResult {
+	if routedErr == nil && sm.entryErr != nil && transition.ErrorState != "" {
+		routedErr = sm.recordRoutedError(sm.entryErr)
+		if region := sm.findRegionForState(result.CurrentState); region != nil && sm.findRegionForState(transition.ErrorState) == region {
+			// An error state in the failing region leaves its sibling regions running
+			sm.takeRegionTransition(result.CurrentState, transition.ErrorState, event)
+			result.CurrentState = transition.ErrorState
+		} else {
+			result.CurrentState = sm.enterErrorState(transition.ErrorState, event)
+		}
+		result.StateChanged = true
+	} else if routedErr == nil && sm.entryErr != nil && result.Error == nil {
+		result.Error = sm.entryErr
+	}
+	sm.entryErr = nil
+
+	if routedErr != nil {
+		result.Error = routedErr
+	}
+	return result
+}
+
+// enterErrorState moves the machine from its current state to an error state.
+// Entry action errors of the error state itself are not routed again.
+func (sm *StateMachine) enterErrorState(errorState string, event Event) string {
+	previousState := sm.currentState
+	if state, exists := sm.states[previousState]; exists && state.IsParallel() {
+		if parallelState, ok := state.(ParallelState); ok {
+			sm.exitParallelRegions(parallelState)
+			delete(sm.activeStates, previousState)
+		}
+	}
+	sm.executeExitActions(previousState, errorState, event)
+	delete(sm.activeStates, previousState)
+	sm.updateStateHistory(previousState)
+
+	target := sm.executeCompositeStateEntry(errorState, event)
+	if resolved, err := sm.executePseudoState(target, event); err == nil {
+		target = sm.executeCompositeStateEntry(resolved, event)
+	}
+	sm.currentState = target
+	if smCtx, ok := sm.context.(*StateMachineContext); ok {
+		smCtx.updateTransitionInfo(target, previousState, errorState, event)
+	}
+
+	sm.executeEntryActions(previousState, target, event)
+	sm.enterRegions("")
+	sm.observers.NotifyStateExit(previousState, sm.context)
+	sm.observers.NotifyTransition(previousState, target, event, sm.context)
+	sm.observers.NotifyStateEnter(target, sm.context)
+	return target
+}
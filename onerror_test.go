@@ -0,0 +1,150 @@
+package fluo
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+var errPaymentDeclined = errors.New("payment declined")
+
+func buildOnErrorDefinition() MachineDefinition {
+	builder := NewMachine()
+	builder.State("cart").Initial().
+		To("paid").On("pay").
+		Do(func(ctx Context) error {
+			return errPaymentDeclined
+		}).
+		OnError("failed").
+		To("shipping").On("ship").OnError("failed").
+		To("cart").On("retry_unrouted").
+		Do(func(ctx Context) error {
+			return errPaymentDeclined
+		})
+	builder.State("paid")
+	builder.State("shipping").
+		OnEntry(func(ctx Context) error {
+			return errors.New("carrier unavailable")
+		})
+	builder.State("failed")
+	return builder.Build()
+}
+
+func TestOnError_TransitionActionRoutesToErrorState(t *testing.T) {
+	machine := buildOnErrorDefinition().CreateInstance()
+	observer := NewTestObserver()
+	machine.AddObserver(observer)
+	_ = machine.Start()
+
+	result := machine.HandleEvent("pay", nil)
+	if !result.Processed || !result.StateChanged || result.CurrentState != "failed" {
+		t.Fatalf("Expected the failed action to route to the error state, got %+v", result)
+	}
+	if !errors.Is(result.Error, errPaymentDeclined) || GetErrorCode(result.Error) != ErrCodeActionFailed {
+		t.Errorf("Expected the action error in the result, got %v", result.Error)
+	}
+	AssertState(t, machine, "failed")
+	if err, _ := machine.Context().Get(ErrorContextKey); !errors.Is(err.(error), errPaymentDeclined) {
+		t.Errorf("Expected the error in the context, got %v", err)
+	}
+	if len(observer.Errors) != 1 {
+		t.Errorf("Expected one error notification, got %d", len(observer.Errors))
+	}
+}
+
+func TestOnError_EntryActionRoutesToErrorState(t *testing.T) {
+	machine := buildOnErrorDefinition().CreateInstance()
+	_ = machine.Start()
+
+	result := machine.HandleEvent("ship", nil)
+	if !result.Processed || result.CurrentState != "failed" {
+		t.Fatalf("Expected the failed entry action to route to the error state, got %+v", result)
+	}
+	if result.Error == nil || !strings.Contains(result.Error.Error(), "carrier unavailable") {
+		t.Errorf("Expected the entry error in the result, got %v", result.Error)
+	}
+	AssertState(t, machine, "failed")
+}
+
+func TestOnError_WithoutErrorStateRejects(t *testing.T) {
+	machine := buildOnErrorDefinition().CreateInstance()
+	_ = machine.Start()
+
+	result := machine.HandleEvent("retry_unrouted", nil)
+	if result.Processed || !errors.Is(result.Error, errPaymentDeclined) {
+		t.Errorf("Expected the event to be rejected with the action error, got %+v", result)
+	}
+	AssertState(t, machine, "cart")
+}
+
+func TestOnError_UnknownErrorState(t *testing.T) {
+	builder := NewMachine()
+	builder.State("idle").Initial().
+		To("busy").On("start").OnError("missing")
+	builder.State("busy")
+	if _, err := builder.BuildE(); err == nil || !strings.Contains(err.Error(), "error state 'missing' does not exist") {
+		t.Errorf("Expected an unknown error state to fail building, got %v", err)
+	}
+}
+
+func TestOnError_RegionEntryFailureKeepsSiblingRegions(t *testing.T) {
+	builder := NewMachine()
+	builder.State("idle").Initial().
+		To("home").On("arrive")
+	builder.Parallel("home", func(p ParallelScope) {
+		p.Region("security", func(r RegionScope) {
+			r.State("off").Initial().
+				To("armed").On("arm").OnError("faulted")
+			r.State("armed").
+				OnEntry(func(ctx Context) error {
+					return errors.New("sensor offline")
+				})
+			r.State("faulted")
+		})
+		p.Region("lighting", func(r RegionScope) {
+			r.State("dim").Initial()
+		})
+	})
+	definition, err := builder.BuildE()
+	if err != nil {
+		t.Fatalf("BuildE failed: %v", err)
+	}
+	machine := definition.CreateInstance()
+	_ = machine.Start()
+	machine.HandleEvent("arrive", nil)
+
+	result := machine.HandleEvent("arm", nil)
+	if !result.Processed || result.CurrentState != "home.security.faulted" {
+		t.Fatalf("Expected routing to the region's error state, got %+v", result)
+	}
+	for _, stateID := range []string{"home", "home.security.faulted", "home.lighting.dim"} {
+		if !machine.IsStateActive(stateID) {
+			t.Errorf("Expected '%s' to stay active, got %v", stateID, machine.GetActiveStates())
+		}
+	}
+}
+
+func TestOnError_RelativeToComposite(t *testing.T) {
+	builder := NewMachine()
+	builder.State("idle").Initial().
+		To("checkout").On("start")
+	builder.Composite("checkout", func(c CompositeScope) {
+		c.State("cart").Initial().
+			To("paid").On("pay").
+			Do(func(ctx Context) error {
+				return errPaymentDeclined
+			}).
+			OnError("declined")
+		c.State("paid")
+		c.State("declined")
+	})
+	definition, err := builder.BuildE()
+	if err != nil {
+		t.Fatalf("BuildE failed: %v", err)
+	}
+	machine := definition.CreateInstance()
+	_ = machine.Start()
+	machine.HandleEvent("start", nil)
+	machine.HandleEvent("pay", nil)
+	AssertState(t, machine, "checkout.declined")
+}
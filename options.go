@@ -0,0 +1,4 @@
+package fluo
+
+// MachineOption configures a machine instance created from a definition
+type MachineOption func(*StateMachine)
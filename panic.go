@@ -0,0 +1,193 @@
+package fluo
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicError is returned in place of a guard, action, activity or
+// before-transition hook that panicked
+type PanicError struct {
+	Kind  string // "guard", "action", "activity" or "hook"
+	State string
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("%s panic: %v", e.Kind, e.Value)
+}
+
+// code returns the error code of a panic of its kind
+func (e *PanicError) code() ErrorCode {
+	switch e.Kind {
+	case "guard":
+		return ErrCodeGuardPanicked
+	case "activity":
+		return ErrCodeActivityPanicked
+	case "hook":
+		return ErrCodeTransitionVetoed
+	default:
+		return ErrCodeActionFailed
+	}
+}
+
+// IsPanicError checks if an error is a PanicError
+func IsPanicError(err error) bool {
+	_, ok := err.(*PanicError)
+	return ok
+}
+
+// PanicReporter receives recovered guard and action panics with their stack,
+// for forwarding to crash reporting services. It is called synchronously while
+// the machine is processing and must not call back into the machine.
+type PanicReporter interface {
+	ReportPanic(panicErr *PanicError, ctx Context)
+}
+
+// PanicReporterFunc adapts a function to the PanicReporter interface
+type PanicReporterFunc func(panicErr *PanicError, ctx Context)
+
+// ReportPanic calls f(panicErr, ctx)
+func (f PanicReporterFunc) ReportPanic(panicErr *PanicError, ctx Context) {
+	f(panicErr, ctx)
+}
+
+// WithPanicReporter forwards recovered guard and action panics to reporter
+func WithPanicReporter(reporter PanicReporter) MachineOption {
+	return func(sm *StateMachine) {
+		sm.panicReporter = reporter
+	}
+}
+
+// PanicPolicy decides how a recovered guard or action panic is handled
+type PanicPolicy int
+
+const (
+	// PanicRouteToErrorState handles a panic like an error: a panicking guard
+	// fails, and a panicking action is routed to the error state declared with
+	// OnError or otherwise rejects the event
+	PanicRouteToErrorState PanicPolicy = iota
+	// PanicRejectEvent rejects the event, even when the transition declares an
+	// error state. Panics in entry and exit actions are reported but cannot
+	// undo a transition already taken.
+	PanicRejectEvent
+	// PanicPropagate re-panics with the PanicError once the machine is unlocked
+	PanicPropagate
+	// PanicCallback lets the handler set with WithPanicHandler pick the policy
+	// for each panic
+	PanicCallback
+)
+
+// String returns the policy name
+func (p PanicPolicy) String() string {
+	switch p {
+	case PanicRouteToErrorState:
+		return "RouteToErrorState"
+	case PanicRejectEvent:
+		return "RejectEvent"
+	case PanicPropagate:
+		return "Propagate"
+	case PanicCallback:
+		return "Callback"
+	default:
+		return fmt.Sprintf("PanicPolicy(%d)", int(p))
+	}
+}
+
+// PanicHandlerFunc picks the policy applied to a recovered panic. Returning
+// PanicCallback applies PanicRouteToErrorState.
+type PanicHandlerFunc func(panicErr *PanicError, ctx Context) PanicPolicy
+
+// WithPanicPolicy sets how recovered guard and action panics are handled
+func WithPanicPolicy(policy PanicPolicy) MachineOption {
+	return func(sm *StateMachine) {
+		sm.panicPolicy = policy
+	}
+}
+
+// WithPanicHandler applies the PanicCallback policy with the given handler
+func WithPanicHandler(handler PanicHandlerFunc) MachineOption {
+	return func(sm *StateMachine) {
+		sm.panicPolicy = PanicCallback
+		sm.panicHandler = handler
+	}
+}
+
+// recoveredPanic applies the panic policy to a guard or action error if it is
+// a recovered panic, records the panic for the event result and notifies
+// observers. It runs on the goroutine processing the event.
+func (sm *StateMachine) recoveredPanic(err error) {
+	panicErr, ok := err.(*PanicError)
+	if !ok {
+		return
+	}
+
+	policy := sm.panicPolicy
+	if policy == PanicCallback {
+		policy = PanicRouteToErrorState
+		if sm.panicHandler != nil {
+			if chosen := sm.panicHandler(panicErr, sm.context); chosen != PanicCallback {
+				policy = chosen
+			}
+		}
+	}
+
+	sm.observers.NotifyPanic(panicErr, sm.context)
+	if sm.trace.panic == nil {
+		sm.trace.panic = panicErr
+	}
+
+	switch policy {
+	case PanicPropagate:
+		panic(panicErr)
+	case PanicRejectEvent:
+		if sm.trace.rejectedPanic == nil {
+			sm.trace.rejectedPanic = panicErr
+		}
+	}
+}
+
+// panicRejection rejects an event once a panic handled with PanicRejectEvent
+// was recovered, leaving the machine in the given state, and returns nil
+// otherwise. The transition is the one whose action ran, if any.
+func (sm *StateMachine) panicRejection(event Event, state string, transition *Transition) *EventResult {
+	panicErr := sm.trace.rejectedPanic
+	if panicErr == nil {
+		return nil
+	}
+	reason := fmt.Sprintf("event rejected after %v", panicErr)
+	sm.observers.NotifyEventRejected(event, reason, sm.context)
+	result := NewEventResult(false, false, state, state).
+		WithRejection(reason).
+		WithError(panicErr)
+	if panicErr.Kind == "guard" {
+		result.RejectionCode = RejectionGuardFailed
+		result.FailedGuard = sm.trace.failedGuard
+	} else {
+		result.RejectionCode = RejectionActionFailed
+		if transition != nil {
+			result.FailedAction = transition.actionIdentity()
+		}
+	}
+	return result
+}
+
+// newPanicError captures the stack of a recovered panic and forwards it to
+// the panic reporter of the machine owning ctx
+func newPanicError(kind string, value any, ctx Context) *PanicError {
+	panicErr := &PanicError{
+		Kind:  kind,
+		Value: value,
+		Stack: debug.Stack(),
+	}
+	if ctx == nil {
+		return panicErr
+	}
+	panicErr.State = ctx.GetCurrentState()
+
+	if sm, ok := ctx.GetMachine().(*StateMachine); ok && sm.panicReporter != nil {
+		sm.panicReporter.ReportPanic(panicErr, ctx)
+	}
+	return panicErr
+}
@@ -0,0 +1,227 @@
+package fluo
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPanicReporter_GuardPanic(t *testing.T) {
+	var reports []*PanicError
+	reporter := PanicReporterFunc(func(panicErr *PanicError, ctx Context) {
+		reports = append(reports, panicErr)
+	})
+
+	definition := NewMachine().
+		State("idle").Initial().
+		To("done").On("go").When(func(ctx Context) bool {
+		panic("boom")
+	}).
+		State("done").
+		Build()
+
+	machine := definition.CreateInstance(WithPanicReporter(reporter))
+	observer := NewTestObserver()
+	machine.AddObserver(observer)
+	_ = machine.Start()
+
+	machine.HandleEvent("go", nil)
+	AssertState(t, machine, "idle")
+
+	if len(reports) != 1 {
+		t.Fatalf("Expected 1 panic report, got %d", len(reports))
+	}
+	report := reports[0]
+	if report.Kind != "guard" || report.Value != "boom" || report.State != "idle" {
+		t.Errorf("Unexpected panic report: %+v", report)
+	}
+	if !strings.Contains(string(report.Stack), "panic_test.go") {
+		t.Error("Expected the stack to include the panicking guard")
+	}
+	if report.Error() != "guard panic: boom" {
+		t.Errorf("Unexpected error text: %s", report.Error())
+	}
+	if GetErrorCode(report) != ErrCodeGuardPanicked {
+		t.Errorf("Expected ErrCodeGuardPanicked for a guard panic, got %v", GetErrorCode(report))
+	}
+
+	if len(observer.Errors) != 1 || !IsPanicError(observer.Errors[0].Error) {
+		t.Errorf("Expected the panic on the error path too, got %v", observer.Errors)
+	}
+}
+
+func TestPanicReporter_ActionPanic(t *testing.T) {
+	var reports []*PanicError
+	reporter := PanicReporterFunc(func(panicErr *PanicError, ctx Context) {
+		reports = append(reports, panicErr)
+	})
+
+	definition := NewMachine().
+		State("idle").Initial().
+		OnEntry(func(ctx Context) error {
+			panic("entry failed")
+		}).
+		To("done").On("go").Do(func(ctx Context) error {
+		panic("action failed")
+	}).
+		State("done").
+		Build()
+
+	machine := definition.CreateInstance(WithPanicReporter(reporter))
+	_ = machine.Start()
+	machine.HandleEvent("go", nil)
+
+	if len(reports) != 2 {
+		t.Fatalf("Expected 2 panic reports, got %d", len(reports))
+	}
+	for _, report := range reports {
+		if report.Kind != "action" || len(report.Stack) == 0 {
+			t.Errorf("Unexpected panic report: %+v", report)
+		}
+	}
+	if GetErrorCode(reports[0]) != ErrCodeActionFailed {
+		t.Error("Expected ErrCodeActionFailed for a panic error")
+	}
+}
+
+func TestPanicError_Codes(t *testing.T) {
+	codes := map[string]ErrorCode{
+		"guard":    ErrCodeGuardPanicked,
+		"action":   ErrCodeActionFailed,
+		"activity": ErrCodeActivityPanicked,
+		"hook":     ErrCodeTransitionVetoed,
+	}
+	for kind, want := range codes {
+		if code := GetErrorCode(&PanicError{Kind: kind}); code != want {
+			t.Errorf("Expected code %v for a %s panic, got %v", want, kind, code)
+		}
+	}
+}
+
+func TestPanicReporter_None(t *testing.T) {
+	definition := NewMachine().
+		State("idle").Initial().
+		To("done").On("go").Do(func(ctx Context) error {
+		panic("action failed")
+	}).
+		State("done").
+		Build()
+
+	machine := definition.CreateInstance()
+	_ = machine.Start()
+	machine.HandleEvent("go", nil)
+}
+
+// buildPanickingMachine builds a machine whose guarded transition falls back
+// to an unguarded one and whose action transition declares an error state
+func buildPanickingMachine(opts ...MachineOption) Machine {
+	return NewMachine().
+		State("idle").Initial().
+		To("checked").On("check").When(func(ctx Context) bool {
+		panic("guard boom")
+	}).
+		To("fallback").On("check").
+		To("done").On("go").Do(func(ctx Context) error {
+		panic("action boom")
+	}).OnError("failed").
+		State("checked").
+		State("fallback").
+		State("done").
+		State("failed").
+		Build().CreateInstance(opts...)
+}
+
+func TestPanicPolicy_RouteToErrorStateByDefault(t *testing.T) {
+	machine := buildPanickingMachine()
+	_ = machine.Start()
+
+	result := machine.HandleEvent("check", nil)
+	AssertState(t, machine, "fallback")
+	if result.Panic == nil || result.Panic.Kind != "guard" {
+		t.Errorf("Expected the guard panic in the result, got %+v", result.Panic)
+	}
+
+	machine = buildPanickingMachine()
+	_ = machine.Start()
+	result = machine.HandleEvent("go", nil)
+	AssertState(t, machine, "failed")
+	if result.Panic == nil || result.Panic.Value != "action boom" {
+		t.Errorf("Expected the action panic in the result, got %+v", result.Panic)
+	}
+}
+
+func TestPanicPolicy_RejectEvent(t *testing.T) {
+	machine := buildPanickingMachine(WithPanicPolicy(PanicRejectEvent))
+	_ = machine.Start()
+
+	result := machine.HandleEvent("check", nil)
+	AssertEventProcessed(t, result, false)
+	AssertState(t, machine, "idle")
+	if !IsPanicError(result.Error) {
+		t.Errorf("Expected the guard panic as the error, got %v", result.Error)
+	}
+
+	result = machine.HandleEvent("go", nil)
+	AssertEventProcessed(t, result, false)
+	AssertState(t, machine, "idle")
+
+	if result := machine.HandleEvent("check", nil); result.Panic == nil {
+		t.Error("Expected each event to report its own panic")
+	}
+}
+
+func TestPanicPolicy_Propagate(t *testing.T) {
+	machine := buildPanickingMachine(WithPanicPolicy(PanicPropagate))
+	_ = machine.Start()
+
+	func() {
+		defer func() {
+			recovered := recover()
+			if panicErr, ok := recovered.(*PanicError); !ok || panicErr.Value != "action boom" {
+				t.Errorf("Expected the action panic to propagate, got %v", recovered)
+			}
+		}()
+		machine.HandleEvent("go", nil)
+	}()
+
+	// The machine is unlocked after the panic
+	AssertState(t, machine, "idle")
+	if err := machine.Reset(); err != nil {
+		t.Errorf("Expected the machine to reset after the panic, got %v", err)
+	}
+}
+
+func TestPanicPolicy_Callback(t *testing.T) {
+	var handled []string
+	machine := buildPanickingMachine(WithPanicHandler(func(panicErr *PanicError, ctx Context) PanicPolicy {
+		handled = append(handled, panicErr.Kind)
+		if panicErr.Kind == "guard" {
+			return PanicRejectEvent
+		}
+		return PanicRouteToErrorState
+	}))
+	observer := &panicRecorder{}
+	machine.AddObserver(observer)
+	_ = machine.Start()
+
+	AssertEventProcessed(t, machine.HandleEvent("check", nil), false)
+	AssertState(t, machine, "idle")
+	machine.HandleEvent("go", nil)
+	AssertState(t, machine, "failed")
+
+	if strings.Join(handled, ",") != "guard,action" {
+		t.Errorf("Expected the handler to see both panics, got %v", handled)
+	}
+	if len(observer.panics) != 2 {
+		t.Errorf("Expected observers to see both panics, got %d", len(observer.panics))
+	}
+}
+
+// panicRecorder records the panics it is notified about
+type panicRecorder struct {
+	BaseObserver
+	panics []*PanicError
+}
+
+func (r *panicRecorder) OnPanic(panicErr *PanicError, ctx Context) {
+	r.panics = append(r.panics, panicErr)
+}
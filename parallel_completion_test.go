@@ -1,6 +1,7 @@
 package fluo
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -272,3 +273,154 @@ func TestParallelStateMultipleCompletionTransitions(t *testing.T) {
 		t.Error("Expected transition to high_total state")
 	}
 }
+
+func TestRegionFinalStateCompletion(t *testing.T) {
+	builder := NewMachine()
+
+	builder.State("start").Initial().
+		To("checks").On("begin")
+
+	parallel := builder.ParallelState("checks")
+
+	credit := parallel.Region("credit")
+	credit.State("pending").Initial().
+		To("done").On("credit_ok")
+	credit.FinalState("done")
+
+	fraud := parallel.Region("fraud")
+	fraud.State("pending").Initial().
+		To("done").On("fraud_ok")
+	fraud.FinalState("done")
+
+	builder.ParallelState("checks").
+		To("approved").OnCompletion()
+	builder.State("approved")
+
+	machine := builder.Build().CreateInstance()
+	observer := NewTestObserver()
+	machine.AddObserver(observer)
+	_ = machine.Start()
+	machine.HandleEvent("begin", nil)
+
+	if machine.RegionCompleted("credit") {
+		t.Error("Expected credit region to be incomplete")
+	}
+
+	machine.HandleEvent("credit_ok", nil)
+	if !machine.RegionCompleted("credit") || !machine.RegionCompleted("checks.credit") {
+		t.Error("Expected credit region to be complete by ID and by path")
+	}
+	if machine.RegionCompleted("fraud") {
+		t.Error("Expected fraud region to be incomplete")
+	}
+	if len(observer.Regions) != 1 || observer.Regions[0].ParallelState != "checks" || observer.Regions[0].Region != "credit" {
+		t.Errorf("Expected one credit region completion, got %+v", observer.Regions)
+	}
+
+	machine.HandleEvent("fraud_ok", nil)
+	if len(observer.Regions) != 2 || observer.Regions[1].Region != "fraud" {
+		t.Errorf("Expected fraud region completion, got %+v", observer.Regions)
+	}
+	AssertState(t, machine, "approved")
+}
+
+func buildReentryMachine(policy RegionReentryPolicy) Machine {
+	builder := NewMachine()
+	builder.State("start").Initial().
+		To("work").On("begin")
+
+	parallel := builder.ParallelState("work").RegionReentry(policy)
+	upload := parallel.Region("upload")
+	upload.State("sending").Initial().
+		To("sent").On("send")
+	upload.FinalState("sent").
+		To("sending").On("resend")
+
+	review := parallel.Region("review")
+	review.State("waiting").Initial().
+		To("approved").On("approve")
+	review.FinalState("approved")
+
+	machine := builder.Build().CreateInstance()
+	_ = machine.Start()
+	machine.HandleEvent("begin", nil)
+	machine.HandleEvent("send", nil)
+	return machine
+}
+
+func TestRegionReentry_AllowFromFinal(t *testing.T) {
+	machine := buildReentryMachine(AllowFromFinal)
+
+	AssertEventProcessed(t, machine.HandleEvent("resend", nil), true)
+	if !machine.IsStateActive("work.upload.sending") {
+		t.Errorf("Expected upload region back in sending, active: %v", machine.GetActiveStates())
+	}
+}
+
+func TestRegionReentry_RejectWithReason(t *testing.T) {
+	machine := buildReentryMachine(RejectWithReason)
+
+	result := machine.HandleEvent("resend", nil)
+	AssertEventProcessed(t, result, false)
+	if !strings.Contains(result.RejectionReason, "region 'upload' of 'work' has completed") {
+		t.Errorf("Expected completed region reason, got %q", result.RejectionReason)
+	}
+	if !machine.IsStateActive("work.upload.sent") {
+		t.Error("Expected upload region to stay completed")
+	}
+}
+
+func TestRegionReentry_ReinitializeRegion(t *testing.T) {
+	machine := buildReentryMachine(ReinitializeRegion)
+	observer := NewTestObserver()
+	machine.AddObserver(observer)
+
+	AssertEventProcessed(t, machine.HandleEvent("send", nil), true)
+	if !machine.IsStateActive("work.upload.sent") || machine.IsStateActive("work.upload.sending") {
+		t.Errorf("Expected upload region completed again, active: %v", machine.GetActiveStates())
+	}
+	if len(observer.Regions) != 1 || observer.Regions[0].Region != "upload" {
+		t.Errorf("Expected the region to complete a second time, got %+v", observer.Regions)
+	}
+}
+
+func TestRegionReentry_ReinitializeOnlyWhenTaken(t *testing.T) {
+	entries := 0
+	builder := NewMachine()
+	builder.State("start").Initial().
+		To("work").On("begin")
+	parallel := builder.ParallelState("work").RegionReentry(ReinitializeRegion)
+	upload := parallel.Region("upload")
+	upload.State("sending").Initial().
+		OnEntry(func(ctx Context) error {
+			entries++
+			return nil
+		})
+	upload.State("sending").
+		To("sent").On("send").
+		When(func(ctx Context) bool {
+			blocked, _ := ctx.Get("blocked")
+			return blocked != true
+		})
+	upload.FinalState("sent")
+	review := parallel.Region("review")
+	review.State("waiting").Initial()
+
+	machine := builder.Build().CreateInstance()
+	_ = machine.Start()
+	machine.HandleEvent("begin", nil)
+	AssertEventProcessed(t, machine.HandleEvent("send", nil), true)
+	entries = 0
+
+	machine.Context().Set("blocked", true)
+	AssertEventProcessed(t, machine.HandleEvent("send", nil), false)
+	if entries != 0 || !machine.IsStateActive("work.upload.sent") {
+		t.Errorf("Expected a rejected event to leave the region completed, entries %d, active: %v", entries, machine.GetActiveStates())
+	}
+
+	machine.Context().Set("blocked", false)
+	AssertEventProcessed(t, machine.HandleEvent("send", nil), true)
+	if entries != 1 || !machine.IsStateActive("work.upload.sent") {
+		t.Errorf("Expected the region restarted once, entries %d, active: %v", entries, machine.GetActiveStates())
+	}
+}
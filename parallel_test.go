@@ -1,6 +1,7 @@
 package fluo
 
 import (
+	"sync"
 	"testing"
 )
 
@@ -638,3 +639,143 @@ func TestParallel_SimpleNestedParallelStates(t *testing.T) {
 	t.Logf("Motor region state: %s", motorState)
 	t.Logf("Lights region state: %s", lightsState)
 }
+
+// buildParallelHistoryMachine builds a smart-home "on" state whose three
+// subsystems are resumed through a history pseudostate
+func buildParallelHistoryMachine(deep bool) Machine {
+	builder := NewMachine()
+	builder.State("off").Initial().
+		To("on").On("power_on").
+		To("on.history").On("resume")
+
+	on := builder.ParallelState("on")
+	if deep {
+		on.DeepHistory("history")
+	} else {
+		on.History("history")
+	}
+
+	security := on.Region("security")
+	security.State("disarmed").Initial().
+		To("on.security.armed").On("arm")
+	security.State("armed")
+
+	climate := on.Region("climate")
+	climate.State("idle").Initial().
+		To("on.climate.heating").On("heat")
+	climate.State("heating")
+
+	lighting := on.Region("lighting")
+	lighting.State("manual").Initial().
+		To("on.lighting.schedule").On("schedule")
+	lighting.State("schedule")
+
+	builder.ParallelState("on").
+		To("off").On("power_off")
+
+	return builder.Build().CreateInstance()
+}
+
+func TestParallel_HistoryRestoresRegions(t *testing.T) {
+	for _, deep := range []bool{false, true} {
+		machine := buildParallelHistoryMachine(deep)
+		_ = machine.Start()
+
+		for _, event := range []string{"power_on", "arm", "heat", "schedule", "power_off"} {
+			AssertEventProcessed(t, machine.HandleEvent(event, nil), true)
+		}
+		AssertState(t, machine, "off")
+
+		AssertEventProcessed(t, machine.HandleEvent("resume", nil), true)
+		AssertState(t, machine, "on")
+		for _, stateID := range []string{"on.security.armed", "on.climate.heating", "on.lighting.schedule"} {
+			if !machine.IsStateActive(stateID) {
+				t.Errorf("deep=%v: expected '%s' to be restored, active states: %v", deep, stateID, machine.GetActiveStates())
+			}
+		}
+		for _, stateID := range []string{"on.security.disarmed", "on.climate.idle", "on.lighting.manual"} {
+			if machine.IsStateActive(stateID) {
+				t.Errorf("deep=%v: expected initial state '%s' to stay inactive", deep, stateID)
+			}
+		}
+
+		// The restored regions keep processing events
+		AssertEventProcessed(t, machine.HandleEvent("power_off", nil), true)
+		AssertEventProcessed(t, machine.HandleEvent("power_on", nil), true)
+		if !machine.IsStateActive("on.security.disarmed") {
+			t.Errorf("deep=%v: expected entering without history to use initial states, got %v", deep, machine.GetActiveStates())
+		}
+	}
+}
+
+func TestParallel_HistoryWithoutRecordedRegions(t *testing.T) {
+	machine := buildParallelHistoryMachine(true)
+	_ = machine.Start()
+
+	AssertEventProcessed(t, machine.HandleEvent("resume", nil), true)
+	AssertState(t, machine, "on")
+	for _, stateID := range []string{"on.security.disarmed", "on.climate.idle", "on.lighting.manual"} {
+		if !machine.IsStateActive(stateID) {
+			t.Errorf("Expected initial state '%s' without history, active states: %v", stateID, machine.GetActiveStates())
+		}
+	}
+}
+
+func TestParallel_HistorySurvivesSnapshot(t *testing.T) {
+	machine := buildParallelHistoryMachine(true)
+	_ = machine.Start()
+	for _, event := range []string{"power_on", "arm", "power_off"} {
+		AssertEventProcessed(t, machine.HandleEvent(event, nil), true)
+	}
+
+	if err := machine.SetConfiguration(machine.CaptureConfiguration()); err != nil {
+		t.Fatalf("SetConfiguration failed after leaving a parallel state: %v", err)
+	}
+	snapshot, err := machine.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	restored := buildParallelHistoryMachine(true)
+	if err := restored.Restore(snapshot); err != nil {
+		t.Fatalf("Restore failed after leaving a parallel state: %v", err)
+	}
+
+	AssertEventProcessed(t, restored.HandleEvent("resume", nil), true)
+	if !restored.IsStateActive("on.security.armed") {
+		t.Errorf("Expected the restored region history to be used, active states: %v", restored.GetActiveStates())
+	}
+
+	snapshot.History["on.security"] = "on.climate.idle"
+	if err := buildParallelHistoryMachine(true).Restore(snapshot); !IsStateError(err) {
+		t.Errorf("Expected a state error for region history outside its region, got: %v", err)
+	}
+}
+
+func TestParallel_InstancesShareNoRegionState(t *testing.T) {
+	definition := buildParallelHistoryMachine(false).(*StateMachine).definition
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			machine := definition.CreateInstance()
+			_ = machine.Start()
+			for range 20 {
+				machine.HandleEvent("power_on", nil)
+				machine.HandleEvent("arm", nil)
+				machine.HandleEvent("power_off", nil)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, state := range definition.GetStates() {
+		if parallelState, ok := state.(ParallelState); ok {
+			for _, region := range parallelState.Regions() {
+				if region.CurrentState() != nil {
+					t.Errorf("Expected region '%s' to hold no runtime state", region.ID())
+				}
+			}
+		}
+	}
+}
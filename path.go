@@ -0,0 +1,81 @@
+package fluo
+
+import "strings"
+
+// PathSeparator separates the segments of a hierarchical state ID
+const PathSeparator = "."
+
+// StatePath is a hierarchical state ID such as "active.transaction.withdrawal",
+// naming a state through its enclosing states from the outermost in. The
+// states of a parallel region are nested in the region's path, such as
+// "home.security.armed" for the state "armed" of the region "security".
+type StatePath string
+
+// Path joins segments into a state path, skipping empty segments
+func Path(segments ...string) StatePath {
+	var sb strings.Builder
+	for _, segment := range segments {
+		if segment == "" {
+			continue
+		}
+		if sb.Len() > 0 {
+			sb.WriteString(PathSeparator)
+		}
+		sb.WriteString(segment)
+	}
+	return StatePath(sb.String())
+}
+
+// String returns the state ID
+func (p StatePath) String() string {
+	return string(p)
+}
+
+// Segments returns the segments of the path, nil for the empty path
+func (p StatePath) Segments() []string {
+	if p == "" {
+		return nil
+	}
+	return strings.Split(string(p), PathSeparator)
+}
+
+// Depth returns the number of segments of the path
+func (p StatePath) Depth() int {
+	if p == "" {
+		return 0
+	}
+	return strings.Count(string(p), PathSeparator) + 1
+}
+
+// Name returns the last segment of the path, the local name of the state
+func (p StatePath) Name() string {
+	if i := strings.LastIndex(string(p), PathSeparator); i >= 0 {
+		return string(p[i+1:])
+	}
+	return string(p)
+}
+
+// Parent returns the path without its last segment, empty for a top-level state
+func (p StatePath) Parent() StatePath {
+	if i := strings.LastIndex(string(p), PathSeparator); i >= 0 {
+		return p[:i]
+	}
+	return ""
+}
+
+// Child returns the path of a state nested in p. The child of the empty path
+// is a top-level state.
+func (p StatePath) Child(id string) StatePath {
+	return Path(string(p), id)
+}
+
+// IsAncestorOf reports whether other is nested in p at any depth. A path is
+// not its own ancestor.
+func (p StatePath) IsAncestorOf(other StatePath) bool {
+	return p != "" && strings.HasPrefix(string(other), string(p)+PathSeparator)
+}
+
+// Contains reports whether other is p or nested in it
+func (p StatePath) Contains(other StatePath) bool {
+	return p == other || p.IsAncestorOf(other)
+}
@@ -0,0 +1,74 @@
+package fluo
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestStatePath(t *testing.T) {
+	path := Path("active", "", "transaction", "withdrawal")
+	if path != "active.transaction.withdrawal" {
+		t.Fatalf("Expected empty segments to be skipped, got %q", path)
+	}
+	if !slices.Equal(path.Segments(), []string{"active", "transaction", "withdrawal"}) || path.Depth() != 3 {
+		t.Errorf("Unexpected segments %v or depth %d", path.Segments(), path.Depth())
+	}
+	if path.Name() != "withdrawal" || path.Parent() != "active.transaction" {
+		t.Errorf("Unexpected name %q or parent %q", path.Name(), path.Parent())
+	}
+	if path.Parent().Child("deposit") != "active.transaction.deposit" {
+		t.Errorf("Expected a sibling path, got %q", path.Parent().Child("deposit"))
+	}
+	if StatePath("").Child("idle") != "idle" || StatePath("idle").Parent() != "" {
+		t.Error("Expected top-level states to have the empty path as parent")
+	}
+	if StatePath("").Segments() != nil || StatePath("").Depth() != 0 {
+		t.Error("Expected the empty path to have no segments")
+	}
+
+	tests := []struct {
+		ancestor, other StatePath
+		want            bool
+	}{
+		{"active", "active.transaction.withdrawal", true},
+		{"active.transaction", "active.transaction", false},
+		{"active", "activeX.transaction", false},
+		{"", "active", false},
+	}
+	for _, tt := range tests {
+		if got := tt.ancestor.IsAncestorOf(tt.other); got != tt.want {
+			t.Errorf("IsAncestorOf(%q, %q) = %v, want %v", tt.ancestor, tt.other, got, tt.want)
+		}
+	}
+	if !path.Contains(path) || path.Contains(path.Parent()) {
+		t.Error("Expected a path to contain itself but not its parent")
+	}
+}
+
+func TestScope_Path(t *testing.T) {
+	var composite, region StatePath
+	builder := NewMachine()
+	builder.State("idle").Initial().To("home").On("arrive")
+	builder.Parallel("home", func(p ParallelScope) {
+		p.Region("security", func(r RegionScope) {
+			region = r.Path()
+			r.State("disarmed").Initial()
+		})
+	})
+	builder.Composite("active", func(c CompositeScope) {
+		composite = c.Path()
+		c.State("running").Initial().
+			To(region.Child("disarmed").String()).On("disarm")
+	})
+	definition, err := builder.BuildE()
+	if err != nil {
+		t.Fatalf("BuildE failed: %v", err)
+	}
+
+	if composite != "active" || region != "home.security" {
+		t.Errorf("Unexpected scope paths %q and %q", composite, region)
+	}
+	if _, exists := definition.GetStates()[composite.Child("running").String()]; !exists {
+		t.Error("Expected the nested state under the scope path")
+	}
+}
@@ -0,0 +1,108 @@
+package fluo
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Peek evaluates guards and computes the state an event would lead to, without
+// running actions or changing the machine. The result reports the would-be
+// transition as HandleEvent would, so callers can list the events available in
+// the current state. Choice, junction and connection point targets are followed
+// to the state they would settle in; other pseudostates are reported as is.
+func (sm *StateMachine) Peek(eventName string, eventData any) *EventResult {
+	sm.mutex.Lock()
+	defer sm.unlock()
+
+	if sm.machineState != MachineStateStarted {
+		return NewEventResult(false, false, sm.currentState, sm.currentState).
+			WithRejection("machine is not started").
+			WithRejectionCode(RejectionNotStarted)
+	}
+	if strings.TrimSpace(eventName) == "" {
+		reason := "event name cannot be empty"
+		return NewEventResult(false, false, sm.currentState, sm.currentState).
+			WithRejection(reason).
+			WithRejectionCode(RejectionInvalidEvent).
+			WithError(fmt.Errorf("%s", reason))
+	}
+	if err := sm.checkEventData(eventName, eventData); err != nil {
+		return NewEventResult(false, false, sm.currentState, sm.currentState).
+			WithRejection(err.Error()).
+			WithRejectionCode(RejectionInvalidEvent).
+			WithError(err)
+	}
+
+	event := NewEvent(eventName, eventData)
+	_, end := sm.startProbe(event, false)
+	defer end()
+
+	transition, sourceStateID, err := sm.findMatchingTransition(eventName, event)
+	if err != nil {
+		reason := fmt.Sprintf("no valid transition found for event '%s' in state '%s'", eventName, sm.currentState)
+		code := RejectionNoTransition
+		if GetErrorCode(err) == ErrCodeAmbiguousTransition {
+			reason, code = err.Error(), RejectionAmbiguous
+		} else if regionReason := sm.completedRegionRejection(eventName); regionReason != "" {
+			reason, code = regionReason, RejectionTerminated
+		} else if sm.completed() {
+			code = RejectionTerminated
+		} else if sm.trace.failedGuard != "" {
+			code = RejectionGuardFailed
+		}
+		result := NewEventResult(false, false, sm.currentState, sm.currentState).
+			WithRejection(reason).
+			WithRejectionCode(code).
+			WithError(fmt.Errorf("%s", reason))
+		if code == RejectionGuardFailed {
+			result.FailedGuard = sm.trace.failedGuard
+		}
+		return result
+	}
+
+	previousState := sm.currentState
+	if sm.isRegionTransition(sourceStateID, transition.TargetState) {
+		previousState = sourceStateID
+	}
+	if transition.Internal {
+		return NewEventResult(true, false, sourceStateID, sourceStateID)
+	}
+
+	target, err := sm.settledTarget(transition.TargetState)
+	if err != nil {
+		return NewEventResult(false, false, previousState, previousState).
+			WithRejection(err.Error()).
+			WithError(err)
+	}
+	return NewEventResult(true, true, previousState, target)
+}
+
+// settledTarget follows the choices, junctions and connection points a
+// target leads through to the state a transition would settle in, selecting
+// branches as executing them would. Actions of the branches are not run.
+func (sm *StateMachine) settledTarget(stateID string) (string, error) {
+	pseudo, ok := sm.states[stateID].(*PseudoStateImpl)
+	if !ok {
+		return stateID, nil
+	}
+
+	switch pseudo.Kind() {
+	case Choice:
+		target, _, err := sm.choiceBranch(pseudo)
+		if err != nil {
+			return "", err
+		}
+		return sm.settledTarget(target)
+	case Junction:
+		target, _, err := sm.junctionPath(stateID)
+		if err != nil {
+			return "", err
+		}
+		return sm.settledTarget(target)
+	case EntryPoint, ExitPoint:
+		if pseudo.defaultTarget != "" {
+			return sm.settledTarget(pseudo.defaultTarget)
+		}
+	}
+	return stateID, nil
+}
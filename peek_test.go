@@ -0,0 +1,95 @@
+package fluo
+
+import "testing"
+
+func buildPeekDefinition() (MachineDefinition, *int) {
+	actions := 0
+	builder := NewMachine()
+	builder.State("cart").Initial().
+		OnExit(func(ctx Context) error {
+			actions++
+			return nil
+		}).
+		To("route").On("checkout").
+		Do(func(ctx Context) error {
+			actions++
+			return nil
+		}).
+		To("cart").On("add").Internal()
+	isExpress := func(ctx Context) bool {
+		return ctx.GetEventData() == "express"
+	}
+	builder.Choice("route").When(isExpress).To("express").Otherwise("standard")
+	builder.State("express")
+	builder.State("standard")
+	return builder.Build(), &actions
+}
+
+func TestPeek_ComputesTargetWithoutSideEffects(t *testing.T) {
+	def, actions := buildPeekDefinition()
+	machine := def.CreateInstance()
+	observer := NewTestObserver()
+	machine.AddObserver(observer)
+	_ = machine.Start()
+
+	result := machine.Peek("checkout", "express")
+	if !result.Processed || !result.StateChanged || result.CurrentState != "express" {
+		t.Errorf("Expected checkout to lead to express, got %+v", result)
+	}
+	if result := machine.Peek("checkout", nil); result.CurrentState != "standard" {
+		t.Errorf("Expected the choice to fall through to standard, got %+v", result)
+	}
+	if *actions != 0 || len(observer.Transitions) != 0 {
+		t.Errorf("Expected no actions or transitions, got %d actions and %v", *actions, observer.Transitions)
+	}
+	AssertState(t, machine, "cart")
+	if machine.Context().GetCurrentEvent() != nil {
+		t.Error("Expected the peeked event not to remain in the context")
+	}
+}
+
+func TestPeek_InternalAndRejected(t *testing.T) {
+	def, _ := buildPeekDefinition()
+	machine := def.CreateInstance()
+
+	if result := machine.Peek("checkout", nil); result.Processed {
+		t.Errorf("Expected a stopped machine to reject, got %+v", result)
+	}
+	_ = machine.Start()
+
+	if result := machine.Peek("add", nil); !result.Processed || result.StateChanged {
+		t.Errorf("Expected an internal transition that keeps the state, got %+v", result)
+	}
+	result := machine.Peek("pay", nil)
+	if result.Processed || result.RejectionReason != machine.HandleEvent("pay", nil).RejectionReason {
+		t.Errorf("Expected the same rejection as HandleEvent, got %+v", result)
+	}
+}
+
+func TestPeek_GuardPanicsStayQuiet(t *testing.T) {
+	builder := NewMachine()
+	builder.State("idle").Initial().
+		To("busy").On("go").When(func(ctx Context) bool { panic("guard boom") }).
+		To("route").On("route")
+	builder.Junction("route").
+		When(func(ctx Context) bool { panic("segment boom") }).To("busy").
+		Otherwise("idle")
+	builder.State("busy")
+
+	recorder := &panicRecorder{}
+	machine := builder.Build().CreateInstance(WithPanicPolicy(PanicPropagate))
+	observer := NewTestObserver()
+	machine.AddObserver(observer)
+	machine.AddObserver(recorder)
+	_ = machine.Start()
+
+	if result := machine.Peek("go", nil); result.Processed || result.RejectionCode != RejectionGuardFailed {
+		t.Errorf("Expected the panicking guard to fail, got %+v", result)
+	}
+	if result := machine.Peek("route", nil); !result.Processed || result.CurrentState != "idle" {
+		t.Errorf("Expected the junction to fall through to idle, got %+v", result)
+	}
+	if len(observer.Errors) != 0 || len(recorder.panics) != 0 {
+		t.Errorf("Expected peeking to notify nothing, got %v and %d panics", observer.Errors, len(recorder.panics))
+	}
+}
@@ -0,0 +1,85 @@
+package fluo
+
+import (
+	"fmt"
+	"plugin"
+)
+
+// PluginSymbol is the function a Go plugin exports to provide guards and
+// actions. Its signature must be func(*fluo.Registry) error.
+const PluginSymbol = "FluoRegister"
+
+// Module provides named guards and actions to a registry. Go plugins are
+// loaded as modules by LoadPlugin and WASM instances are bound by WASMModule;
+// hosts for other formats implement Module to bind their functions to names.
+type Module interface {
+	Register(r *Registry) error
+}
+
+// ModuleFunc adapts a function to a Module
+type ModuleFunc func(r *Registry) error
+
+// Register calls f(r)
+func (f ModuleFunc) Register(r *Registry) error {
+	return f(r)
+}
+
+// Import registers the guards and actions of a module. They are collected in
+// a separate registry first, so a module that fails leaves r unchanged.
+// Imported names replace existing ones, which lets a newer module version
+// override the guards and actions of an older one for definitions loaded
+// afterwards.
+func (r *Registry) Import(module Module) error {
+	staged := NewRegistry()
+	if err := module.Register(staged); err != nil {
+		return err
+	}
+
+	staged.mutex.RLock()
+	defer staged.mutex.RUnlock()
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for name, guard := range staged.guards {
+		r.guards[name] = guard
+	}
+	for name, action := range staged.actions {
+		r.actions[name] = action
+	}
+	return nil
+}
+
+// LoadPlugin opens a Go plugin and imports the guards and actions its
+// FluoRegister function registers. The plugin must be built with the same Go
+// toolchain and fluo version as the host. Go cannot unload plugins or open
+// the same path twice, so ship each update under a new file name.
+func (r *Registry) LoadPlugin(path string) error {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return NewConfigurationError("plugin", fmt.Sprintf("failed to open plugin '%s': %v", path, err))
+	}
+
+	symbol, err := p.Lookup(PluginSymbol)
+	if err != nil {
+		return NewConfigurationError("plugin", fmt.Sprintf("plugin '%s' does not export %s", path, PluginSymbol))
+	}
+	register, ok := symbol.(func(*Registry) error)
+	if !ok {
+		return NewConfigurationError("plugin", fmt.Sprintf("%s in plugin '%s' is a %T, not a func(*fluo.Registry) error", PluginSymbol, path, symbol))
+	}
+
+	if err := r.Import(ModuleFunc(register)); err != nil {
+		return fmt.Errorf("plugin '%s' failed to register: %w", path, err)
+	}
+	return nil
+}
+
+// Import registers the guards and actions of a module in the default registry
+func Import(module Module) error {
+	return DefaultRegistry.Import(module)
+}
+
+// LoadPlugin loads a Go plugin into the default registry
+func LoadPlugin(path string) error {
+	return DefaultRegistry.LoadPlugin(path)
+}
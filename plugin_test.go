@@ -0,0 +1,48 @@
+package fluo
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestRegistry_Import(t *testing.T) {
+	registry := NewRegistry()
+	registry.RegisterGuard("existing", func(ctx Context) bool { return false })
+
+	err := registry.Import(ModuleFunc(func(r *Registry) error {
+		r.RegisterGuard("existing", func(ctx Context) bool { return true })
+		r.RegisterAction("imported", func(ctx Context) error { return nil })
+		return nil
+	}))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if guard, _ := registry.Guard("existing"); !guard(nil) {
+		t.Error("Expected the imported guard to replace the existing one")
+	}
+	if _, ok := registry.Action("imported"); !ok {
+		t.Error("Expected the imported action to be registered")
+	}
+}
+
+func TestRegistry_ImportFailureLeavesRegistryUnchanged(t *testing.T) {
+	registry := NewRegistry()
+	err := registry.Import(ModuleFunc(func(r *Registry) error {
+		r.RegisterAction("partial", func(ctx Context) error { return nil })
+		return errors.New("missing export")
+	}))
+	if err == nil {
+		t.Fatal("Expected the module error")
+	}
+	if _, ok := registry.Action("partial"); ok {
+		t.Error("Expected a failed module to register nothing")
+	}
+}
+
+func TestRegistry_LoadPluginMissing(t *testing.T) {
+	err := NewRegistry().LoadPlugin(filepath.Join(t.TempDir(), "missing.so"))
+	if GetErrorCode(err) != ErrCodeInvalidConfiguration {
+		t.Errorf("Expected a configuration error, got %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+package fluo
+
+import (
+	"context"
+	"sync"
+)
+
+// Pool recycles the instances of a definition for workloads creating many
+// short-lived machines. Instances share the immutable states and transitions of
+// their definition, so a pooled instance only keeps its runtime configuration
+// and context. Both are cleared when it is put back, along with the observers,
+// middleware and metadata added to it, and the pool's options are applied again.
+type Pool struct {
+	definition MachineDefinition
+	opts       []MachineOption
+	instances  sync.Pool
+}
+
+// NewPool creates a pool of instances created with the given options
+func (smd *simpleMachineDefinition) NewPool(opts ...MachineOption) *Pool {
+	return &Pool{
+		definition: smd,
+		opts:       opts,
+	}
+}
+
+// Get returns a stopped instance in the initial state, reusing a recycled one
+// when available
+func (p *Pool) Get() Machine {
+	if sm, ok := p.instances.Get().(*StateMachine); ok {
+		return sm
+	}
+	sm := p.definition.CreateInstance(p.opts...).(*StateMachine)
+	sm.pool = p
+	return sm
+}
+
+// Put stops an instance obtained from Get and recycles it. The caller must not
+// use the instance afterwards. Instances from other pools are ignored.
+func (p *Pool) Put(machine Machine) {
+	sm, ok := machine.(*StateMachine)
+	if !ok || sm.pool != p {
+		return
+	}
+	sm.recycle(p.opts)
+	p.instances.Put(sm)
+}
+
+// recycle stops the instance and discards its runtime configuration, context
+// data, identity and everything configured on it since it was created, then
+// applies the pool's options again so it behaves like a freshly created
+// instance. Only the allocated maps are reused.
+func (sm *StateMachine) recycle(opts []MachineOption) {
+	// Stop notifies observers and halts the event loop of a started instance
+	_ = sm.Stop()
+	sm.observers.Close()
+
+	sm.mutex.Lock()
+	defer sm.unlock()
+
+	sm.stopAllTimers()
+	sm.stopAllActivities()
+	sm.stopAllSubmachines()
+
+	sm.machineState = MachineStateStopped
+	sm.resumable = false
+	sm.currentState = sm.initialState
+	clear(sm.activeStates)
+	clear(sm.stateHistory)
+	clear(sm.historyTimes)
+	clear(sm.historyExits)
+	clear(sm.parallelRegions)
+	clear(sm.joinTracking)
+	clear(sm.regionStates)
+	sm.historyRestore = nil
+	sm.entryErr = nil
+	sm.regionEntries = nil
+	sm.activeLimitExceeded = false
+	sm.skipActions = false
+	sm.eventDepth = 0
+	sm.trace = eventTrace{}
+
+	sm.labelMutex.Lock()
+	sm.id = newInstanceID()
+	sm.metadata = nil
+	sm.labelMutex.Unlock()
+	sm.middlewareMutex.Lock()
+	sm.middleware = nil
+	sm.middlewareMutex.Unlock()
+
+	// Everything an option or a setter may have configured
+	sm.observers = NewObserverManager()
+	sm.eventLoop = nil
+	sm.valueMarshalers = nil
+	sm.typeRegistry = nil
+	sm.definitionVersion = ""
+	sm.snapshotMigration = nil
+	sm.limits = PayloadLimits{}
+	sm.flags = nil
+	sm.choiceFallback = nil
+	sm.panicReporter = nil
+	sm.panicPolicy = 0
+	sm.panicHandler = nil
+	sm.guardTimeout = 0
+	sm.actionTimeout = 0
+	sm.beforeTransition = nil
+	sm.conflictPolicy = 0
+	sm.broadcast = false
+	sm.maxActiveStates = 0
+	sm.eventLog = nil
+	sm.timeline = nil
+	sm.stats = nil
+	sm.debugLogger = nil
+	sm.debugLevel.Store(0)
+
+	sm.context = NewContext(context.Background(), sm)
+	sm.initVars()
+	for _, opt := range opts {
+		opt(sm)
+	}
+}
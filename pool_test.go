@@ -0,0 +1,136 @@
+package fluo
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestCreateInstance_SharesDefinition(t *testing.T) {
+	built := NewMachine().
+		State("idle").Initial().
+		To("running").On("start").
+		State("running").
+		Build()
+
+	first := built.CreateInstance().(*StateMachine)
+	second := built.CreateInstance().(*StateMachine)
+
+	if reflect.ValueOf(first.states).UnsafePointer() != reflect.ValueOf(second.states).UnsafePointer() {
+		t.Error("Expected instances to share the state map of their definition")
+	}
+	if reflect.ValueOf(first.transitions).UnsafePointer() != reflect.ValueOf(second.transitions).UnsafePointer() {
+		t.Error("Expected instances to share the transition index of their definition")
+	}
+
+	_ = first.Start()
+	_ = second.Start()
+	first.HandleEvent("start", nil)
+	AssertState(t, first, "running")
+	AssertState(t, second, "idle")
+}
+
+func TestPool_RecyclesInstances(t *testing.T) {
+	definition := NewMachine().
+		State("idle").Initial().
+		To("running").On("start").
+		State("running").
+		Build()
+	pool := definition.NewPool(WithMetadata(map[string]string{"tenant": "acme"}))
+
+	machine := pool.Get()
+	if err := machine.Start(); err != nil {
+		t.Fatalf("Expected pooled instance to start, got %v", err)
+	}
+	machine.Context().Set("order", 42)
+	AssertEventProcessed(t, machine.HandleEvent("start", nil), true)
+	id := machine.ID()
+	pool.Put(machine)
+
+	// sync.Pool may or may not hand the same instance back; either way it must be fresh
+	for i := 0; i < 3; i++ {
+		reused := pool.Get()
+		if reused.CurrentState() != "idle" {
+			t.Errorf("Expected a pooled instance in the initial state, got '%s'", reused.CurrentState())
+		}
+		if _, ok := reused.Context().Get("order"); ok {
+			t.Error("Expected context data to be cleared")
+		}
+		if reused.ID() == id {
+			t.Error("Expected a recycled instance to get a new ID")
+		}
+		if reused.Metadata()["tenant"] != "acme" {
+			t.Errorf("Expected pool options to apply, got %v", reused.Metadata())
+		}
+		if err := reused.Start(); err != nil {
+			t.Fatalf("Expected recycled instance to start, got %v", err)
+		}
+		AssertEventProcessed(t, reused.HandleEvent("start", nil), true)
+		pool.Put(reused)
+	}
+}
+
+func TestPool_IgnoresForeignInstances(t *testing.T) {
+	definition := NewMachine().
+		State("idle").Initial().
+		Build()
+	pool := definition.NewPool()
+
+	foreign := definition.CreateInstance()
+	_ = foreign.Start()
+	pool.Put(foreign)
+
+	if err := foreign.Stop(); err != nil {
+		t.Errorf("Expected a foreign instance to be left running, got %v", err)
+	}
+}
+
+// transitionCounter counts the transitions it observes
+type transitionCounter struct {
+	BaseObserver
+	transitions int
+}
+
+func (o *transitionCounter) OnTransition(from string, to string, event Event, ctx Context) {
+	o.transitions++
+}
+
+func TestPool_RecycleDropsInstanceConfiguration(t *testing.T) {
+	definition := NewMachine().
+		State("idle").Initial().
+		To("running").On("start").
+		State("running").
+		Build()
+	pool := definition.NewPool(WithMetadata(map[string]string{"tenant": "acme"}))
+
+	machine := pool.Get()
+	observer := &transitionCounter{}
+	machine.AddObserver(observer)
+	var intercepted int
+	machine.Use(func(next Handler) Handler {
+		return func(ctx context.Context, event Event) *EventResult {
+			intercepted++
+			return next(ctx, event)
+		}
+	})
+	machine.SetMetadata("order", "42")
+	_ = machine.Start()
+	machine.HandleEvent("start", nil)
+	pool.Put(machine)
+
+	// Inspect the recycled instance directly, whether or not sync.Pool returns it
+	_ = machine.Start()
+	AssertEventProcessed(t, machine.HandleEvent("start", nil), true)
+	if observer.transitions != 1 {
+		t.Errorf("Expected the observer to be dropped, got %d transitions", observer.transitions)
+	}
+	if intercepted != 1 {
+		t.Errorf("Expected the middleware to be dropped, got %d calls", intercepted)
+	}
+	if _, ok := machine.Metadata()["order"]; ok {
+		t.Errorf("Expected instance metadata to be cleared, got %v", machine.Metadata())
+	}
+	if machine.Metadata()["tenant"] != "acme" {
+		t.Errorf("Expected pool options to apply again, got %v", machine.Metadata())
+	}
+}
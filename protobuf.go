@@ -0,0 +1,272 @@
+package fluo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// Protocol buffer wire types used by the snapshot schema
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+	protoFixed32 = 5
+)
+
+// Field numbers of the Snapshot message, see ProtobufCodec
+const (
+	protoInstanceID = iota + 1
+	protoMetadata
+	protoCurrentState
+	protoInitialState
+	protoMachineState
+	protoRegionStates
+	protoActiveStates
+	protoHistory
+	protoContextData
+	protoEncodedContextData
+	protoTypedContextData
+	protoContextScopes
+	protoDefinitionVersion
+	protoDefinitionHash
+)
+
+// encodeProtobuf writes a snapshot as a Snapshot message. Map entries are
+// written in key order, so equal snapshots encode to equal bytes.
+func encodeProtobuf(buf *bytes.Buffer, snapshot *Snapshot) error {
+	writeProtoString(buf, protoInstanceID, snapshot.InstanceID)
+	writeProtoStringMap(buf, protoMetadata, snapshot.Metadata)
+	writeProtoString(buf, protoCurrentState, snapshot.CurrentState)
+	writeProtoString(buf, protoInitialState, snapshot.InitialState)
+	if snapshot.MachineState != 0 {
+		writeProtoTag(buf, protoMachineState, protoVarint)
+		buf.Write(binary.AppendUvarint(nil, uint64(int64(snapshot.MachineState))))
+	}
+	writeProtoStringMap(buf, protoRegionStates, snapshot.RegionStates)
+	for _, stateID := range snapshot.ActiveStates {
+		writeProtoBytes(buf, protoActiveStates, []byte(stateID))
+	}
+	writeProtoStringMap(buf, protoHistory, snapshot.History)
+	for _, key := range slices.Sorted(maps.Keys(snapshot.Context)) {
+		data, err := json.Marshal(snapshot.Context[key])
+		if err != nil {
+			return fmt.Errorf("protobuf: context value '%s': %w", key, err)
+		}
+		writeProtoEntry(buf, protoContextData, key, data)
+	}
+	for _, key := range slices.Sorted(maps.Keys(snapshot.EncodedContext)) {
+		writeProtoEntry(buf, protoEncodedContextData, key, snapshot.EncodedContext[key])
+	}
+	for _, key := range slices.Sorted(maps.Keys(snapshot.TypedContext)) {
+		typed := snapshot.TypedContext[key]
+		var value bytes.Buffer
+		writeProtoString(&value, 1, typed.Type)
+		if len(typed.Data) > 0 {
+			writeProtoBytes(&value, 2, typed.Data)
+		}
+		writeProtoEntry(buf, protoTypedContextData, key, value.Bytes())
+	}
+	writeProtoStringMap(buf, protoContextScopes, snapshot.ContextScopes)
+	writeProtoString(buf, protoDefinitionVersion, snapshot.DefinitionVersion)
+	writeProtoString(buf, protoDefinitionHash, snapshot.DefinitionHash)
+	return nil
+}
+
+// decodeProtobuf reads a Snapshot message, skipping unknown fields
+func decodeProtobuf(data []byte) (*Snapshot, error) {
+	snapshot := &Snapshot{Context: make(map[string]any)}
+	err := readProtoFields(data, func(field int, wireType int, varint uint64, value []byte) error {
+		if field == protoMachineState && wireType == protoVarint {
+			snapshot.MachineState = MachineState(int64(varint))
+			return nil
+		}
+		if wireType != protoBytes {
+			return nil
+		}
+
+		switch field {
+		case protoInstanceID:
+			snapshot.InstanceID = string(value)
+		case protoCurrentState:
+			snapshot.CurrentState = string(value)
+		case protoInitialState:
+			snapshot.InitialState = string(value)
+		case protoActiveStates:
+			snapshot.ActiveStates = append(snapshot.ActiveStates, string(value))
+		case protoDefinitionVersion:
+			snapshot.DefinitionVersion = string(value)
+		case protoDefinitionHash:
+			snapshot.DefinitionHash = string(value)
+		case protoMetadata:
+			return readProtoStringEntry(&snapshot.Metadata, value)
+		case protoRegionStates:
+			return readProtoStringEntry(&snapshot.RegionStates, value)
+		case protoHistory:
+			return readProtoStringEntry(&snapshot.History, value)
+		case protoContextScopes:
+			return readProtoStringEntry(&snapshot.ContextScopes, value)
+		case protoContextData:
+			key, entry, err := readProtoEntry(value)
+			if err != nil {
+				return err
+			}
+			var decoded any
+			if err := json.Unmarshal(entry, &decoded); err != nil {
+				return fmt.Errorf("protobuf: context value '%s': %w", key, err)
+			}
+			snapshot.Context[key] = decoded
+		case protoEncodedContextData:
+			key, entry, err := readProtoEntry(value)
+			if err != nil {
+				return err
+			}
+			if snapshot.EncodedContext == nil {
+				snapshot.EncodedContext = make(map[string][]byte)
+			}
+			snapshot.EncodedContext[key] = entry
+		case protoTypedContextData:
+			key, entry, err := readProtoEntry(value)
+			if err != nil {
+				return err
+			}
+			var typed TypedValue
+			err = readProtoFields(entry, func(field int, wireType int, _ uint64, value []byte) error {
+				if wireType == protoBytes && field == 1 {
+					typed.Type = string(value)
+				} else if wireType == protoBytes && field == 2 {
+					typed.Data = value
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if snapshot.TypedContext == nil {
+				snapshot.TypedContext = make(map[string]TypedValue)
+			}
+			snapshot.TypedContext[key] = typed
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// writeProtoTag writes the key of a field
+func writeProtoTag(buf *bytes.Buffer, field int, wireType int) {
+	buf.Write(binary.AppendUvarint(nil, uint64(field)<<3|uint64(wireType)))
+}
+
+// writeProtoBytes writes a length-delimited field
+func writeProtoBytes(buf *bytes.Buffer, field int, value []byte) {
+	writeProtoTag(buf, field, protoBytes)
+	buf.Write(binary.AppendUvarint(nil, uint64(len(value))))
+	buf.Write(value)
+}
+
+// writeProtoString writes a string field, omitted when empty as in proto3
+func writeProtoString(buf *bytes.Buffer, field int, value string) {
+	if value != "" {
+		writeProtoBytes(buf, field, []byte(value))
+	}
+}
+
+// writeProtoEntry writes a map entry, a message with the key as field 1 and
+// the value as field 2
+func writeProtoEntry(buf *bytes.Buffer, field int, key string, value []byte) {
+	var entry bytes.Buffer
+	writeProtoString(&entry, 1, key)
+	if len(value) > 0 {
+		writeProtoBytes(&entry, 2, value)
+	}
+	writeProtoBytes(buf, field, entry.Bytes())
+}
+
+// writeProtoStringMap writes a map<string, string> field in key order
+func writeProtoStringMap(buf *bytes.Buffer, field int, values map[string]string) {
+	for _, key := range slices.Sorted(maps.Keys(values)) {
+		writeProtoEntry(buf, field, key, []byte(values[key]))
+	}
+}
+
+// readProtoFields calls fn with every field of a message: the varint of
+// varint fields, and the value of length-delimited ones. Fixed-size fields
+// are skipped.
+func readProtoFields(data []byte, fn func(field int, wireType int, varint uint64, value []byte) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return NewConfigurationError("protobuf", "invalid field key")
+		}
+		data = data[n:]
+		field, wireType := int(key>>3), int(key&7)
+
+		var varint uint64
+		var value []byte
+		switch wireType {
+		case protoVarint:
+			varint, n = binary.Uvarint(data)
+			if n <= 0 {
+				return NewConfigurationError("protobuf", fmt.Sprintf("invalid varint in field %d", field))
+			}
+			data = data[n:]
+		case protoBytes:
+			length, n := binary.Uvarint(data)
+			if n <= 0 || length > uint64(len(data)-n) {
+				return NewConfigurationError("protobuf", fmt.Sprintf("truncated field %d", field))
+			}
+			value = data[n : n+int(length)]
+			data = data[n+int(length):]
+		case protoFixed64, protoFixed32:
+			size := 8
+			if wireType == protoFixed32 {
+				size = 4
+			}
+			if len(data) < size {
+				return NewConfigurationError("protobuf", fmt.Sprintf("truncated field %d", field))
+			}
+			data = data[size:]
+			continue
+		default:
+			return NewConfigurationError("protobuf", fmt.Sprintf("unsupported wire type %d in field %d", wireType, field))
+		}
+		if err := fn(field, wireType, varint, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readProtoEntry reads the key and value of a map entry
+func readProtoEntry(data []byte) (string, []byte, error) {
+	var key string
+	var value []byte
+	err := readProtoFields(data, func(field int, wireType int, _ uint64, v []byte) error {
+		if wireType == protoBytes && field == 1 {
+			key = string(v)
+		} else if wireType == protoBytes && field == 2 {
+			value = v
+		}
+		return nil
+	})
+	return key, value, err
+}
+
+// readProtoStringEntry reads a map<string, string> entry into values
+func readProtoStringEntry(values *map[string]string, data []byte) error {
+	key, value, err := readProtoEntry(data)
+	if err != nil {
+		return err
+	}
+	if *values == nil {
+		*values = make(map[string]string)
+	}
+	(*values)[key] = string(value)
+	return nil
+}
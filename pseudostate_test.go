@@ -1,6 +1,9 @@
 package fluo
 
 import (
+	"errors"
+	"slices"
+	"strings"
 	"testing"
 )
 
@@ -41,6 +44,51 @@ func TestPseudostate_ChoiceBasic(t *testing.T) {
 	AssertState(t, machine2, "path_b")
 }
 
+func TestPseudostate_ChoiceToFunc(t *testing.T) {
+	builder := NewMachine()
+
+	builder.State("start").Initial().
+		To("route").On("submit")
+
+	builder.Choice("route").
+		When(func(ctx Context) bool {
+			_, ok := ctx.Get("reviewer")
+			return ok
+		}).ToFunc(func(ctx Context) string {
+		return "queue_" + GetOr(ctx, "reviewer", "")
+	}).
+		Otherwise("unassigned")
+
+	builder.State("queue_alice")
+	builder.State("queue_bob")
+	builder.State("unassigned")
+
+	definition := builder.Build()
+
+	for _, reviewer := range []string{"alice", "bob"} {
+		machine := definition.CreateInstance()
+		machine.Context().Set("reviewer", reviewer)
+		_ = machine.Start()
+		AssertEventProcessed(t, machine.HandleEvent("submit", nil), true)
+		AssertState(t, machine, "queue_"+reviewer)
+	}
+
+	machine := definition.CreateInstance()
+	_ = machine.Start()
+	machine.HandleEvent("submit", nil)
+	AssertState(t, machine, "unassigned")
+
+	observer := NewTestObserver()
+	machine = definition.CreateInstance()
+	machine.AddObserver(observer)
+	machine.Context().Set("reviewer", "carol")
+	_ = machine.Start()
+	machine.HandleEvent("submit", nil)
+	if len(observer.Errors) == 0 {
+		t.Error("Expected an error for a computed target that does not exist")
+	}
+}
+
 func TestPseudostate_ChoiceWithAction(t *testing.T) {
 	actionCalled := false
 	actionData := ""
@@ -149,6 +197,136 @@ func TestPseudostate_Junction(t *testing.T) {
 	AssertState(t, machine, "end")
 }
 
+func TestPseudostate_JunctionGuardedSegments(t *testing.T) {
+	var log []string
+	amountAtLeast := func(limit int) GuardFunc {
+		return func(ctx Context) bool {
+			return GetOr(ctx, "amount", 0) >= limit
+		}
+	}
+
+	builder := NewMachine()
+	builder.State("start").Initial().
+		To("route").On("submit").Do(func(ctx Context) error {
+		// Guards of the junction were evaluated before this action ran
+		ctx.Set("amount", 0)
+		log = append(log, "transition")
+		return nil
+	})
+	builder.Junction("route").
+		When(amountAtLeast(1000)).Do(func(ctx Context) error {
+		log = append(log, "large")
+		return nil
+	}).To("manual_review").
+		When(amountAtLeast(100)).To("auto_review").
+		Otherwise("approved")
+	builder.State("manual_review")
+	builder.State("auto_review")
+	builder.State("approved")
+	definition := builder.Build()
+
+	for amount, want := range map[int]string{5000: "manual_review", 500: "auto_review", 5: "approved"} {
+		machine := definition.CreateInstance()
+		machine.Context().Set("amount", amount)
+		_ = machine.Start()
+		AssertEventProcessed(t, machine.HandleEvent("submit", nil), true)
+		AssertState(t, machine, want)
+	}
+
+	log = nil
+	machine := definition.CreateInstance()
+	machine.Context().Set("amount", 5000)
+	_ = machine.Start()
+	machine.HandleEvent("submit", nil)
+	if !slices.Equal(log, []string{"transition", "large"}) {
+		t.Errorf("Expected the segment action after the transition action, got %v", log)
+	}
+
+	builder = NewMachine()
+	builder.State("start").Initial().To("route").On("go")
+	builder.Junction("route").When(amountAtLeast(1)).To("start")
+	_, err := builder.BuildE()
+	if err == nil || !strings.Contains(err.Error(), "else segment") {
+		t.Errorf("Expected a junction without an else segment to be rejected, got %v", err)
+	}
+}
+
+func TestPseudostate_JunctionSegmentActionFailure(t *testing.T) {
+	segmentErr := errors.New("segment failed")
+	build := func(errorState string) MachineDefinition {
+		builder := NewMachine()
+		transition := builder.State("start").Initial().To("route").On("submit")
+		if errorState != "" {
+			transition.OnError(errorState)
+		}
+		builder.Junction("route").
+			When(func(ctx Context) bool { return true }).Do(func(ctx Context) error {
+			return segmentErr
+		}).To("approved").
+			Otherwise("approved")
+		builder.State("approved")
+		builder.State("failed")
+		return builder.Build()
+	}
+
+	machine := build("").CreateInstance()
+	_ = machine.Start()
+	result := machine.HandleEvent("submit", nil)
+	AssertEventProcessed(t, result, false)
+	if result.RejectionCode != RejectionActionFailed || !errors.Is(result.Error, segmentErr) {
+		t.Errorf("Expected the segment action failure to reject the event, got %+v", result)
+	}
+	AssertState(t, machine, "start")
+
+	machine = build("failed").CreateInstance()
+	_ = machine.Start()
+	result = machine.HandleEvent("submit", nil)
+	AssertEventProcessed(t, result, true)
+	if !errors.Is(result.Error, segmentErr) {
+		t.Errorf("Expected the routed segment error in the result, got %v", result.Error)
+	}
+	AssertState(t, machine, "failed")
+}
+
+func TestPseudostate_ChoiceBranchActionFailure(t *testing.T) {
+	branchErr := errors.New("branch failed")
+	build := func(errorState string) MachineDefinition {
+		builder := NewMachine()
+		transition := builder.State("start").Initial().To("route").On("submit")
+		if errorState != "" {
+			transition.OnError(errorState)
+		}
+		builder.Choice("route").
+			When(func(ctx Context) bool { return true }).Do(func(ctx Context) error {
+			return branchErr
+		}).ToFunc(func(ctx Context) string {
+			return "queue"
+		}).
+			Otherwise("queue")
+		builder.State("queue")
+		builder.State("failed")
+		return builder.Build()
+	}
+
+	// The source was already left when the choice selects its branch
+	machine := build("").CreateInstance()
+	_ = machine.Start()
+	result := machine.HandleEvent("submit", nil)
+	AssertEventProcessed(t, result, true)
+	if !errors.Is(result.Error, branchErr) {
+		t.Errorf("Expected the branch action failure in the result, got %v", result.Error)
+	}
+	AssertState(t, machine, "queue")
+
+	machine = build("failed").CreateInstance()
+	_ = machine.Start()
+	result = machine.HandleEvent("submit", nil)
+	if !errors.Is(result.Error, branchErr) {
+		t.Errorf("Expected the routed branch error in the result, got %v", result.Error)
+	}
+	AssertState(t, machine, "failed")
+}
+
 func TestPseudostate_Fork(t *testing.T) {
 	builder := NewMachine()
 
@@ -182,6 +360,49 @@ func TestPseudostate_Fork(t *testing.T) {
 	}
 }
 
+// forkBranchRecorder records the fork branches it is notified about
+type forkBranchRecorder struct {
+	BaseObserver
+	branches []string
+}
+
+func (r *forkBranchRecorder) OnForkBranch(fork string, branch string, target string, ctx Context) {
+	r.branches = append(r.branches, fork+"/"+branch+"/"+target)
+}
+
+func TestPseudostate_ForkBranches(t *testing.T) {
+	var ran []string
+	builder := NewMachine()
+	builder.State("draft").Initial().
+		To("review").On("submit")
+	builder.Fork("review").
+		ToBranch("legal", "legal_review", func(ctx Context) error {
+			ran = append(ran, "legal")
+			return nil
+		}).
+		To("technical_review")
+	builder.State("legal_review")
+	builder.State("technical_review")
+	definition := builder.Build()
+
+	machine := definition.CreateInstance()
+	recorder := &forkBranchRecorder{}
+	machine.AddObserver(recorder)
+	_ = machine.Start()
+	AssertEventProcessed(t, machine.HandleEvent("submit", nil), true)
+
+	if !slices.Equal(ran, []string{"legal"}) {
+		t.Errorf("Expected the legal branch action to run once, got %v", ran)
+	}
+	expected := []string{"review/legal/legal_review", "review/technical_review/technical_review"}
+	if !slices.Equal(recorder.branches, expected) {
+		t.Errorf("Expected %v, got %v", expected, recorder.branches)
+	}
+	if !strings.Contains(ExportMermaid(definition), "review --> legal_review : legal") {
+		t.Error("Expected the branch label in the Mermaid export")
+	}
+}
+
 func TestPseudostate_JoinSynchronization(t *testing.T) {
 	builder := NewMachine()
 
@@ -222,6 +443,58 @@ func TestPseudostate_JoinSynchronization(t *testing.T) {
 	}
 }
 
+func TestPseudostate_JoinFromFork(t *testing.T) {
+	builder := NewMachine()
+	builder.State("start").Initial().
+		To("split").On("begin")
+	builder.Join("sync").
+		FromFork("split").
+		To("end")
+	// The fork is declared after the join and gains a branch later on
+	split := builder.Fork("split").
+		To("legal")
+	builder.State("legal").
+		To("legal_ok").On("approve").
+		To("legal_denied").On("deny")
+	builder.State("legal_ok").
+		To("sync").On("done")
+	builder.State("legal_denied").
+		To("sync").On("done")
+	builder.State("tech").
+		To("sync").On("done")
+	builder.State("end")
+	split.To("tech")
+
+	definition, err := builder.BuildE()
+	if err != nil {
+		t.Fatalf("BuildE failed: %v", err)
+	}
+	combinations := definition.(*simpleMachineDefinition).joinConditions["sync"]
+	want := [][]string{{"legal_denied", "tech"}, {"legal_ok", "tech"}}
+	if !slices.EqualFunc(combinations, want, slices.Equal) {
+		t.Errorf("Expected combinations %v, got %v", want, combinations)
+	}
+
+	_, err = NewMachine().
+		State("start").Initial().
+		Join("sync").FromFork("start").To("start").
+		BuildE()
+	var configErr *ConfigurationError
+	if !errors.As(err, &configErr) {
+		t.Errorf("Expected ConfigurationError for a join from a state that is not a fork, got %v", err)
+	}
+
+	builder = NewMachine()
+	builder.State("start").Initial().To("split").On("begin")
+	builder.Fork("split").To("dead_end")
+	builder.State("dead_end")
+	builder.Join("sync").FromFork("split").To("start")
+	_, err = builder.BuildE()
+	if !errors.As(err, &configErr) {
+		t.Errorf("Expected ConfigurationError for a branch never reaching the join, got %v", err)
+	}
+}
+
 func TestPseudostate_HistoryShallow(t *testing.T) {
 	builder := NewMachine()
 
@@ -518,3 +791,206 @@ func TestPseudostate_ObserverNotifications(t *testing.T) {
 
 	AssertState(t, machine, "end")
 }
+
+func buildWizardWithConnectionPoints(entered *[]string) MachineDefinition {
+	builder := NewMachine()
+
+	builder.State("idle").Initial().
+		To("wizard").On("start").
+		To("wizard.resume").On("resume")
+
+	wizard := builder.CompositeState("wizard")
+	wizard.EntryPoint("resume").To("review").
+		Do(func(ctx Context) error {
+			*entered = append(*entered, "resume")
+			return nil
+		})
+	wizard.ExitPoint("aborted").To("cancelled")
+	wizard.State("details").Initial().
+		To("review").On("next")
+	wizard.State("review").
+		To("wizard.aborted").On("cancel")
+
+	return builder.State("cancelled").Build()
+}
+
+func TestPseudostate_EntryPoint(t *testing.T) {
+	var entered []string
+	machine := buildWizardWithConnectionPoints(&entered).CreateInstance()
+	_ = machine.Start()
+
+	AssertEventProcessed(t, machine.HandleEvent("resume", nil), true)
+	AssertState(t, machine, "wizard.review")
+	if len(entered) != 1 {
+		t.Errorf("Expected the entry point action to run once, got %v", entered)
+	}
+}
+
+func TestPseudostate_ExitPoint(t *testing.T) {
+	var entered []string
+	machine := buildWizardWithConnectionPoints(&entered).CreateInstance()
+	_ = machine.Start()
+
+	machine.HandleEvent("start", nil)
+	machine.HandleEvent("next", nil)
+	AssertState(t, machine, "wizard.review")
+
+	AssertEventProcessed(t, machine.HandleEvent("cancel", nil), true)
+	AssertState(t, machine, "cancelled")
+}
+
+func TestPseudostate_ConnectionPointsInTooling(t *testing.T) {
+	var entered []string
+	definition := buildWizardWithConnectionPoints(&entered)
+
+	if err := definition.SelfTest(); err != nil {
+		t.Errorf("Expected self-test to pass, got %v", err)
+	}
+
+	kinds := make(map[string]string)
+	for _, state := range Describe(definition).States {
+		kinds[state.ID] = state.Kind
+	}
+	if kinds["wizard.resume"] != "entryPoint" || kinds["wizard.aborted"] != "exitPoint" {
+		t.Errorf("Unexpected connection point kinds: %v", kinds)
+	}
+
+	plantUML := ExportPlantUML(definition)
+	if !strings.Contains(plantUML, "<<entryPoint>>") || !strings.Contains(plantUML, "<<exitPoint>>") {
+		t.Error("Expected connection point stereotypes in the PlantUML export")
+	}
+}
+
+func TestRequireOtherwise_RejectsChoiceWithoutDefault(t *testing.T) {
+	b := NewMachine().RequireOtherwise()
+	b.State("idle").Initial().To("route").On("go")
+	b.Choice("route").When(func(ctx Context) bool { return false }).To("a")
+	b.State("a")
+
+	_, err := b.BuildE()
+	if err == nil || !strings.Contains(err.Error(), "route") {
+		t.Fatalf("Expected error naming the choice, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "Otherwise()") {
+		t.Errorf("Expected Otherwise() hint, got %v", err)
+	}
+}
+
+func TestRequireOtherwise_AcceptsChoiceWithDefault(t *testing.T) {
+	b := NewMachine().RequireOtherwise()
+	b.State("idle").Initial().To("route").On("go")
+	b.Choice("route").When(func(ctx Context) bool { return false }).To("a").Otherwise("b")
+	b.State("a")
+	b.State("b")
+
+	if _, err := b.BuildE(); err != nil {
+		t.Fatalf("Expected build to succeed, got %v", err)
+	}
+}
+
+func TestChoiceFallback(t *testing.T) {
+	b := NewMachine()
+	b.State("idle").Initial().To("route").On("go")
+	b.Choice("route").When(func(ctx Context) bool { return false }).To("a")
+	b.State("a")
+	b.State("rescued")
+	definition := b.Build()
+
+	var seen string
+	machine := definition.CreateInstance(WithChoiceFallback(func(choiceID string, ctx Context) (string, error) {
+		seen = choiceID
+		return "rescued", nil
+	}))
+	_ = machine.Start()
+	machine.HandleEvent("go", nil)
+
+	AssertState(t, machine, "rescued")
+	if seen != "route" {
+		t.Errorf("Expected fallback for 'route', got %q", seen)
+	}
+}
+
+func TestChoiceWithoutBranch_ReportsError(t *testing.T) {
+	b := NewMachine()
+	b.State("idle").Initial().To("route").On("go")
+	b.Choice("route").When(func(ctx Context) bool { return false }).To("a")
+	b.State("a")
+
+	machine := b.Build().CreateInstance()
+	observer := NewTestObserver()
+	machine.AddObserver(observer)
+	_ = machine.Start()
+	machine.HandleEvent("go", nil)
+
+	if len(observer.Errors) == 0 || !IsTransitionError(observer.Errors[0].Error) {
+		t.Errorf("Expected a reported TransitionError, got %v", observer.Errors)
+	}
+}
+
+func TestPseudostate_ForkBranchesExitedWithAncestor(t *testing.T) {
+	var exited []string
+	builder := NewMachine()
+	builder.State("idle").Initial().
+		To("job.split").On("start")
+	builder.State("job")
+	builder.Fork("job.split").
+		To("job.upload", "job.notify")
+	builder.State("job.upload").
+		To("done").On("finish")
+	builder.State("job.notify").
+		OnExit(func(ctx Context) error {
+			exited = append(exited, "job.notify")
+			return nil
+		})
+	builder.State("done")
+
+	machine := builder.Build().CreateInstance()
+	observer := NewTestObserver()
+	machine.AddObserver(observer)
+	_ = machine.Start()
+
+	machine.HandleEvent("start", nil)
+	if !machine.IsStateActive("job.notify") {
+		t.Fatalf("Expected fork branch to be active, got %v", machine.GetActiveStates())
+	}
+
+	machine.HandleEvent("finish", nil)
+	if machine.IsStateActive("job.notify") {
+		t.Errorf("Expected stale fork branch to be exited, got %v", machine.GetActiveStates())
+	}
+	if len(exited) != 1 {
+		t.Errorf("Expected the branch exit action to run once, got %v", exited)
+	}
+	notified := false
+	for _, exit := range observer.StateExits {
+		notified = notified || exit.State == "job.notify"
+	}
+	if !notified {
+		t.Error("Expected an exit notification for the stale branch")
+	}
+	if regions := machine.GetParallelRegions(); len(regions) != 0 {
+		t.Errorf("Expected fork tracking to be cleared, got %v", regions)
+	}
+}
+
+func TestChoiceFallback_UnknownTarget(t *testing.T) {
+	b := NewMachine()
+	b.State("idle").Initial().To("route").On("go")
+	b.Choice("route").When(func(ctx Context) bool { return false }).To("a")
+	b.State("a")
+
+	machine := b.Build().CreateInstance(WithChoiceFallback(func(choiceID string, ctx Context) (string, error) {
+		return "missing", nil
+	}))
+	observer := NewTestObserver()
+	machine.AddObserver(observer)
+	_ = machine.Start()
+	machine.HandleEvent("go", nil)
+
+	if machine.IsInState("missing") {
+		t.Error("Expected the machine not to enter an unknown state")
+	}
+	if len(observer.Errors) == 0 || !IsTransitionError(observer.Errors[0].Error) {
+		t.Errorf("Expected a reported TransitionError, got %v", observer.Errors)
+	}
+}
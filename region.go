@@ -0,0 +1,225 @@
+package fluo
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// RegionReentryPolicy decides what happens when an event targets a region
+// that has already reached its final state
+type RegionReentryPolicy int
+
+const (
+	// AllowFromFinal fires transitions declared on the region's final state
+	AllowFromFinal RegionReentryPolicy = iota
+	// RejectWithReason rejects the event, naming the completed region
+	RejectWithReason
+	// ReinitializeRegion restarts the region at its initial state and handles the event there
+	ReinitializeRegion
+)
+
+// String returns the policy name
+func (p RegionReentryPolicy) String() string {
+	switch p {
+	case AllowFromFinal:
+		return "AllowFromFinal"
+	case RejectWithReason:
+		return "RejectWithReason"
+	case ReinitializeRegion:
+		return "ReinitializeRegion"
+	default:
+		return fmt.Sprintf("RegionReentryPolicy(%d)", int(p))
+	}
+}
+
+// regionCurrent returns the current state of a region in this instance
+func (sm *StateMachine) regionCurrent(region Region) State {
+	return sm.regionStates[region]
+}
+
+// setRegionCurrent records the current state of a region in this instance;
+// nil marks the region inactive
+func (sm *StateMachine) setRegionCurrent(region Region, state State) {
+	if state == nil {
+		delete(sm.regionStates, region)
+	} else {
+		sm.regionStates[region] = state
+	}
+}
+
+// lookupRegion finds a region by its path "<parallel state>.<region>" or bare ID
+func (sm *StateMachine) lookupRegion(key string) Region {
+	if region := sm.findRegionByPath(key); region != nil {
+		return region
+	}
+	return sm.findRegionByID(key)
+}
+
+// completedRegion is a region resting in its final state
+type completedRegion struct {
+	stateID string
+	region  Region
+	policy  RegionReentryPolicy
+}
+
+// completedRegions returns the active final states of parallel regions with
+// their regions and reentry policies, in state ID order
+func (sm *StateMachine) completedRegions() []completedRegion {
+	var completed []completedRegion
+	for _, stateID := range slices.Sorted(maps.Keys(sm.activeStates)) {
+		state, exists := sm.states[stateID]
+		if !exists || !state.IsFinal() {
+			continue
+		}
+		region := sm.findRegionForState(stateID)
+		if region == nil {
+			continue
+		}
+		completed = append(completed, completedRegion{
+			stateID: stateID,
+			region:  region,
+			policy:  regionReentryPolicy(region),
+		})
+	}
+	return completed
+}
+
+// regionReentryPolicy returns the policy of the parallel state owning a region
+func regionReentryPolicy(region Region) RegionReentryPolicy {
+	if parallel, ok := region.ParentState().(*ParallelStateImpl); ok {
+		return parallel.reentryPolicy
+	}
+	return AllowFromFinal
+}
+
+// rejectsFromFinal reports whether transitions from a state are suppressed
+// because it is the final state of a region that rejects reentry
+func (sm *StateMachine) rejectsFromFinal(stateID string) bool {
+	state, exists := sm.states[stateID]
+	if !exists || !state.IsFinal() {
+		return false
+	}
+	region := sm.findRegionForState(stateID)
+	return region != nil && regionReentryPolicy(region) == RejectWithReason
+}
+
+// regionHandles reports whether any state of a region has a transition for the event
+func (sm *StateMachine) regionHandles(region Region, eventName string) bool {
+	for _, state := range region.States() {
+		for _, transition := range sm.transitions[state.ID()] {
+			if transition.EventName == eventName {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// completedRegionRejection returns the rejection reason for an event that
+// targets a completed region which rejects reentry, or "" if there is none
+func (sm *StateMachine) completedRegionRejection(eventName string) string {
+	for _, completed := range sm.completedRegions() {
+		if completed.policy == RejectWithReason && sm.regionHandles(completed.region, eventName) {
+			return fmt.Sprintf("region '%s' of '%s' has completed", completed.region.ID(), completed.region.ParentState().ID())
+		}
+	}
+	return ""
+}
+
+// reinitializationSource returns the initial state of the region a final
+// state completed, when the region reinitializes and its initial state handles
+// the event, so the event is matched against the initial state instead
+func (sm *StateMachine) reinitializationSource(stateID, eventName string) string {
+	state, exists := sm.states[stateID]
+	if !exists || !state.IsFinal() {
+		return ""
+	}
+	region := sm.findRegionForState(stateID)
+	if region == nil || regionReentryPolicy(region) != ReinitializeRegion || region.InitialState() == nil {
+		return ""
+	}
+	initialState := region.InitialState()
+	for _, transition := range sm.transitions[initialState.ID()] {
+		if transition.EventName == eventName && sm.flagEnabled(transition) {
+			return initialState.ID()
+		}
+	}
+	return ""
+}
+
+// reinitializeCompletedRegion restarts the completed region whose initial
+// state is the source of the selected transition, exiting its final state and
+// entering the initial state before the transition runs
+func (sm *StateMachine) reinitializeCompletedRegion(sourceStateID string, event Event) {
+	for _, completed := range sm.completedRegions() {
+		initialState := completed.region.InitialState()
+		if completed.policy != ReinitializeRegion || initialState == nil || initialState.ID() != sourceStateID {
+			continue
+		}
+
+		sm.exitState(sm.states[completed.stateID])
+		sm.observers.NotifyStateExit(completed.stateID, sm.context)
+		delete(sm.activeStates, completed.stateID)
+
+		sm.setRegionCurrent(completed.region, initialState)
+		entered := sm.executeCompositeStateEntry(initialState.ID(), event)
+		sm.activeStates[entered] = true
+		if state, exists := sm.states[entered]; exists {
+			sm.enterState(state)
+		}
+		sm.enterRegions("")
+		sm.observers.NotifyStateEnter(entered, sm.context)
+		sm.observers.NotifyRegionStateEnter(completed.region.ParentState().ID(), completed.region.ID(), entered, sm.context)
+		return
+	}
+}
+
+// takeRegionTransition moves a region from one of its states to another,
+// updating the region's current state but not the machine's, and checks
+// whether the parallel state has completed. The caller runs the transition
+// action and must hold the machine mutex.
+func (sm *StateMachine) takeRegionTransition(sourceStateID, targetState string, event Event) {
+	// Store the target state info before updating to check for completion later
+	var targetRegion Region
+	var isFinalState bool
+	if targetStateObj, exists := sm.states[targetState]; exists {
+		isFinalState = targetStateObj.IsFinal()
+		targetRegion = sm.findRegionForState(targetState)
+	}
+
+	if region := sm.findRegionForState(sourceStateID); region != nil {
+		sm.trace.regionSteps = append(sm.trace.regionSteps, RegionTransition{
+			ParallelState: region.ParentState().ID(),
+			Region:        region.ID(),
+			From:          sourceStateID,
+			To:            targetState,
+		})
+	}
+
+	sm.updateRegionStateWithoutCompletionCheck(sourceStateID, targetState)
+
+	delete(sm.activeStates, sourceStateID)
+	sm.activeStates[targetState] = true
+
+	if sourceState, exists := sm.states[sourceStateID]; exists {
+		sm.exitState(sourceState)
+	}
+
+	if targetStateObj, exists := sm.states[targetState]; exists {
+		sm.enterState(targetStateObj)
+	}
+
+	sm.observers.NotifyStateExit(sourceStateID, sm.context)
+	sm.observers.NotifyTransition(sourceStateID, targetState, event, sm.context)
+	sm.observers.NotifyStateEnter(targetState, sm.context)
+	if targetRegion != nil {
+		sm.observers.NotifyRegionStateEnter(targetRegion.ParentState().ID(), targetRegion.ID(), targetState, sm.context)
+	}
+
+	// Check for parallel state completion AFTER action execution
+	if isFinalState && targetRegion != nil {
+		sm.observers.NotifyRegionCompleted(targetRegion.ParentState().ID(), targetRegion.ID(), sm.context)
+		sm.checkParallelStateCompletion(targetRegion.ParentState())
+	}
+}
@@ -0,0 +1,62 @@
+package fluo
+
+import "sync"
+
+// Registry maps names to guards and actions so definitions loaded from
+// documents can reference behavior implemented in Go
+type Registry struct {
+	guards  map[string]GuardFunc
+	actions map[string]ActionFunc
+	mutex   sync.RWMutex
+}
+
+// NewRegistry creates an empty guard and action registry
+func NewRegistry() *Registry {
+	return &Registry{
+		guards:  make(map[string]GuardFunc),
+		actions: make(map[string]ActionFunc),
+	}
+}
+
+// DefaultRegistry is the registry used by the package-level loader functions
+var DefaultRegistry = NewRegistry()
+
+// RegisterGuard adds a named guard, replacing any guard with the same name
+func (r *Registry) RegisterGuard(name string, guard GuardFunc) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.guards[name] = guard
+}
+
+// RegisterAction adds a named action, replacing any action with the same name
+func (r *Registry) RegisterAction(name string, action ActionFunc) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.actions[name] = action
+}
+
+// Guard returns the guard registered under name
+func (r *Registry) Guard(name string) (GuardFunc, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	guard, ok := r.guards[name]
+	return guard, ok
+}
+
+// Action returns the action registered under name
+func (r *Registry) Action(name string) (ActionFunc, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	action, ok := r.actions[name]
+	return action, ok
+}
+
+// RegisterGuard adds a named guard to the default registry
+func RegisterGuard(name string, guard GuardFunc) {
+	DefaultRegistry.RegisterGuard(name, guard)
+}
+
+// RegisterAction adds a named action to the default registry
+func RegisterAction(name string, action ActionFunc) {
+	DefaultRegistry.RegisterAction(name, action)
+}
@@ -0,0 +1,148 @@
+package fluo
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// JournalEntry records an event delivered to a machine and the configuration it produced
+type JournalEntry struct {
+	EventName     string
+	EventData     any
+	Timestamp     time.Time
+	Processed     bool
+	PreviousState string
+	CurrentState  string
+	ActiveStates  []string
+}
+
+// NewJournalEntry records the outcome of an event handled by the given machine
+func NewJournalEntry(eventName string, eventData any, result *EventResult, machine Machine) JournalEntry {
+	activeStates := machine.GetActiveStates()
+	slices.Sort(activeStates)
+
+	return JournalEntry{
+		EventName:     eventName,
+		EventData:     eventData,
+		Timestamp:     time.Now(),
+		Processed:     result.Processed,
+		PreviousState: result.PreviousState,
+		CurrentState:  result.CurrentState,
+		ActiveStates:  activeStates,
+	}
+}
+
+// ReplayMismatch describes a difference between a recorded and a replayed outcome
+type ReplayMismatch struct {
+	Run       int
+	Index     int
+	EventName string
+	Field     string
+	Expected  any
+	Actual    any
+}
+
+func (m ReplayMismatch) String() string {
+	return fmt.Sprintf("run %d, entry %d (%s): %s expected %v, got %v", m.Run, m.Index, m.EventName, m.Field, m.Expected, m.Actual)
+}
+
+// ReplayReport summarizes a determinism check
+type ReplayReport struct {
+	Runs             int
+	Entries          int
+	Mismatches       []ReplayMismatch
+	Nondeterministic bool // Replays disagreed with each other, not only with the journal
+}
+
+// Deterministic returns true if every replay matched the journal
+func (r *ReplayReport) Deterministic() bool {
+	return len(r.Mismatches) == 0
+}
+
+// VerifyReplay replays a journal against a definition the given number of times and
+// compares every outcome with what was recorded. Replays that disagree with each other
+// indicate guards or actions depending on wall-clock time, randomness or other external state.
+func VerifyReplay(def MachineDefinition, journal []JournalEntry, runs int) (*ReplayReport, error) {
+	if runs < 1 {
+		runs = 1
+	}
+
+	report := &ReplayReport{
+		Runs:       runs,
+		Entries:    len(journal),
+		Mismatches: make([]ReplayMismatch, 0),
+	}
+
+	var baseline []JournalEntry
+	for run := 0; run < runs; run++ {
+		replayed, err := replayJournal(def, journal)
+		if err != nil {
+			return nil, err
+		}
+
+		for i, entry := range journal {
+			report.Mismatches = append(report.Mismatches, compareJournalEntries(run, i, entry, replayed[i])...)
+		}
+
+		if baseline == nil {
+			baseline = replayed
+		} else if !report.Nondeterministic {
+			for i := range baseline {
+				if len(compareJournalEntries(run, i, baseline[i], replayed[i])) > 0 {
+					report.Nondeterministic = true
+					break
+				}
+			}
+		}
+	}
+
+	return report, nil
+}
+
+// replayJournal runs the journal's events through a fresh instance and records the outcomes
+func replayJournal(def MachineDefinition, journal []JournalEntry) ([]JournalEntry, error) {
+	machine := def.CreateInstance()
+	if err := machine.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start replay machine: %w", err)
+	}
+	defer func() { _ = machine.Stop() }()
+
+	replayed := make([]JournalEntry, 0, len(journal))
+	for _, entry := range journal {
+		result := machine.HandleEvent(entry.EventName, entry.EventData)
+		replayed = append(replayed, NewJournalEntry(entry.EventName, entry.EventData, result, machine))
+	}
+
+	return replayed, nil
+}
+
+// compareJournalEntries returns the mismatches between an expected and an actual entry
+func compareJournalEntries(run, index int, expected, actual JournalEntry) []ReplayMismatch {
+	var mismatches []ReplayMismatch
+	mismatch := func(field string, want, got any) {
+		mismatches = append(mismatches, ReplayMismatch{
+			Run:       run,
+			Index:     index,
+			EventName: expected.EventName,
+			Field:     field,
+			Expected:  want,
+			Actual:    got,
+		})
+	}
+
+	if expected.Processed != actual.Processed {
+		mismatch("Processed", expected.Processed, actual.Processed)
+	}
+	if expected.PreviousState != actual.PreviousState {
+		mismatch("PreviousState", expected.PreviousState, actual.PreviousState)
+	}
+	if expected.CurrentState != actual.CurrentState {
+		mismatch("CurrentState", expected.CurrentState, actual.CurrentState)
+	}
+	if expected.ActiveStates != nil && !slices.Equal(expected.ActiveStates, actual.ActiveStates) {
+		mismatch("ActiveStates", expected.ActiveStates, actual.ActiveStates)
+	}
+
+	return mismatches
+}
@@ -0,0 +1,103 @@
+package fluo
+
+import (
+	"testing"
+)
+
+func recordJournal(machine Machine, events ...string) []JournalEntry {
+	journal := make([]JournalEntry, 0, len(events))
+	for _, eventName := range events {
+		result := machine.HandleEvent(eventName, nil)
+		journal = append(journal, NewJournalEntry(eventName, nil, result, machine))
+	}
+	return journal
+}
+
+func TestVerifyReplay_Deterministic(t *testing.T) {
+	definition := NewMachine().
+		State("idle").Initial().
+		To("running").On("start").
+		State("running").
+		To("stopped").On("stop").
+		State("stopped").
+		Build()
+
+	machine := definition.CreateInstance()
+	_ = machine.Start()
+	journal := recordJournal(machine, "start", "bogus", "stop")
+
+	report, err := VerifyReplay(definition, journal, 3)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !report.Deterministic() {
+		t.Errorf("Expected deterministic replay, got mismatches %v", report.Mismatches)
+	}
+	if report.Nondeterministic {
+		t.Error("Expected replays to agree with each other")
+	}
+	if report.Entries != 3 || report.Runs != 3 {
+		t.Errorf("Expected 3 entries over 3 runs, got %d entries over %d runs", report.Entries, report.Runs)
+	}
+}
+
+func TestVerifyReplay_DetectsNondeterministicGuard(t *testing.T) {
+	calls := 0
+	definition := NewMachine().
+		State("idle").Initial().
+		To("running").On("start").When(func(ctx Context) bool {
+		calls++
+		return calls%2 == 1
+	}).
+		State("running").
+		Build()
+
+	machine := definition.CreateInstance()
+	_ = machine.Start()
+	journal := recordJournal(machine, "start")
+
+	report, err := VerifyReplay(definition, journal, 2)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if report.Deterministic() {
+		t.Fatal("Expected mismatches for a guard depending on external state")
+	}
+	if !report.Nondeterministic {
+		t.Error("Expected replays to disagree with each other")
+	}
+
+	mismatch := report.Mismatches[0]
+	if mismatch.Index != 0 || mismatch.EventName != "start" {
+		t.Errorf("Expected mismatch on the first entry, got %s", mismatch)
+	}
+}
+
+func TestVerifyReplay_DetectsDefinitionDrift(t *testing.T) {
+	original := NewMachine().
+		State("idle").Initial().
+		To("running").On("start").
+		State("running").
+		Build()
+
+	machine := original.CreateInstance()
+	_ = machine.Start()
+	journal := recordJournal(machine, "start")
+
+	changed := NewMachine().
+		State("idle").Initial().
+		To("paused").On("start").
+		State("paused").
+		Build()
+
+	report, err := VerifyReplay(changed, journal, 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if report.Deterministic() {
+		t.Fatal("Expected mismatches against a changed definition")
+	}
+	if report.Nondeterministic {
+		t.Error("Expected a single run not to be flagged as nondeterministic")
+	}
+}
@@ -0,0 +1,157 @@
+package fluo
+
+import "fmt"
+
+// ResetSubtree exits and re-initializes a single composite state, parallel state or region,
+// re-entering its initial configuration while leaving the rest of the machine untouched.
+// Regions are addressed by their full path ("parallelState.region").
+func (sm *StateMachine) ResetSubtree(stateID string) error {
+	sm.mutex.Lock()
+	defer sm.unlock()
+
+	if sm.machineState != MachineStateStarted {
+		return NewMachineNotStartedError("ResetSubtree")
+	}
+
+	if region := sm.findRegionByPath(stateID); region != nil {
+		return sm.resetRegion(region)
+	}
+
+	state, exists := sm.states[stateID]
+	if !exists {
+		return NewStateNotFoundError(stateID)
+	}
+
+	if !state.IsComposite() {
+		return NewInvalidStateError(stateID, fmt.Sprintf("state '%s' is not a composite state or region", stateID))
+	}
+
+	if sm.currentState != stateID && !sm.isDescendantOf(sm.currentState, stateID) {
+		return NewInvalidStateError(stateID, fmt.Sprintf("state '%s' is not active", stateID))
+	}
+
+	previousState := sm.currentState
+
+	// Exit everything below the subtree root, innermost first
+	if prevStateObj, ok := sm.states[previousState]; ok && previousState != stateID {
+		if parallelState, ok := prevStateObj.(ParallelState); ok {
+			sm.exitParallelRegions(parallelState)
+		}
+		sm.executeExitActions(previousState, stateID, nil)
+		sm.observers.NotifyStateExit(previousState, sm.context)
+	}
+	if parallelState, ok := state.(ParallelState); ok {
+		sm.exitParallelRegions(parallelState)
+	}
+	for activeStateID := range sm.activeStates {
+		if activeStateID != stateID && sm.isDescendantOf(activeStateID, stateID) {
+			if activeState, exists := sm.states[activeStateID]; exists {
+				sm.exitState(activeState)
+				sm.observers.NotifyStateExit(activeStateID, sm.context)
+			}
+			delete(sm.activeStates, activeStateID)
+		}
+	}
+
+	// Re-enter the initial configuration of the subtree
+	targetState := sm.executeCompositeStateEntry(stateID, nil)
+	sm.currentState = targetState
+
+	if smCtx, ok := sm.context.(*StateMachineContext); ok {
+		smCtx.updateCurrentState(sm.currentState)
+	}
+
+	for _, entryStateID := range sm.subtreeEntryPath(stateID, targetState) {
+		if entryState, exists := sm.states[entryStateID]; exists {
+			sm.enterState(entryState)
+		}
+	}
+	sm.enterRegions("")
+
+	sm.observers.NotifyStateEnter(targetState, sm.context)
+	if previousState != targetState {
+		sm.observers.NotifyTransition(previousState, targetState, nil, sm.context)
+	}
+
+	return nil
+}
+
+// resetRegion exits the current state of a region and re-enters its initial state
+func (sm *StateMachine) resetRegion(region Region) error {
+	initialState := region.InitialState()
+	if initialState == nil {
+		return NewInvalidStateError(region.ID(), fmt.Sprintf("region '%s' has no initial state", region.ID()))
+	}
+
+	previous := sm.regionCurrent(region)
+	if previous == nil {
+		return NewInvalidStateError(region.ID(), fmt.Sprintf("region '%s' is not active", region.ID()))
+	}
+
+	sm.exitState(previous)
+	sm.observers.NotifyStateExit(previous.ID(), sm.context)
+	delete(sm.activeStates, previous.ID())
+
+	sm.setRegionCurrent(region, initialState)
+	targetState := sm.executeCompositeStateEntry(initialState.ID(), nil)
+	sm.activeStates[targetState] = true
+	if regionState, exists := sm.states[targetState]; exists {
+		sm.enterState(regionState)
+	}
+	sm.enterRegions("")
+
+	sm.observers.NotifyStateEnter(targetState, sm.context)
+	if previous.ID() != targetState {
+		sm.observers.NotifyTransition(previous.ID(), targetState, nil, sm.context)
+	}
+
+	return nil
+}
+
+// findRegionByPath finds a region by its "parallelState.region" path
+func (sm *StateMachine) findRegionByPath(path string) Region {
+	for stateID, state := range sm.states {
+		if parallelState, ok := state.(ParallelState); ok {
+			for _, region := range parallelState.Regions() {
+				if stateID+"."+region.ID() == path {
+					return region
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// isDescendantOf reports whether stateID is nested below ancestorID,
+// following parent links and falling back to dotted state paths
+func (sm *StateMachine) isDescendantOf(stateID, ancestorID string) bool {
+	if stateID == "" || ancestorID == "" || stateID == ancestorID {
+		return false
+	}
+
+	if state, exists := sm.states[stateID]; exists {
+		for parent := state.Parent(); parent != nil; parent = parent.Parent() {
+			if parent.ID() == ancestorID {
+				return true
+			}
+		}
+	}
+
+	return StatePath(ancestorID).IsAncestorOf(StatePath(stateID))
+}
+
+// subtreeEntryPath returns the states to enter, outermost first, when descending from root to leaf
+func (sm *StateMachine) subtreeEntryPath(root, leaf string) []string {
+	if root == leaf {
+		return []string{}
+	}
+
+	hierarchy := sm.getStateHierarchy(leaf)
+	for i, stateID := range hierarchy {
+		if stateID == root {
+			return hierarchy[i+1:]
+		}
+	}
+
+	return []string{leaf}
+}
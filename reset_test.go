@@ -0,0 +1,122 @@
+package fluo
+
+import (
+	"testing"
+)
+
+func createTransactionMachine(log *[]string) Machine {
+	record := func(entry string) ActionFunc {
+		return func(ctx Context) error {
+			*log = append(*log, entry)
+			return nil
+		}
+	}
+
+	builder := NewMachine()
+	builder.State("idle").Initial().
+		To("transaction").On("insert_card")
+
+	transaction := builder.CompositeState("transaction")
+	transaction.State("select_account").Initial().
+		OnEntry(record("enter:select_account")).
+		OnExit(record("exit:select_account")).
+		To("enter_amount").On("select")
+	transaction.State("enter_amount").
+		OnEntry(record("enter:enter_amount")).
+		OnExit(record("exit:enter_amount"))
+
+	builder.State("idle").
+		OnEntry(record("enter:idle"))
+
+	return builder.Build().CreateInstance()
+}
+
+func TestResetSubtree_Composite(t *testing.T) {
+	var log []string
+	machine := createTransactionMachine(&log)
+	observer := NewTestObserver()
+	machine.AddObserver(observer)
+
+	_ = machine.Start()
+	_ = machine.HandleEvent("insert_card", nil)
+	_ = machine.HandleEvent("select", nil)
+	AssertState(t, machine, "transaction.enter_amount")
+
+	log = nil
+	observer.Reset()
+
+	if err := machine.ResetSubtree("transaction"); err != nil {
+		t.Fatalf("Expected no error resetting subtree, got: %v", err)
+	}
+
+	AssertState(t, machine, "transaction.select_account")
+
+	expected := []string{"exit:enter_amount", "enter:select_account"}
+	if len(log) != len(expected) {
+		t.Fatalf("Expected actions %v, got %v", expected, log)
+	}
+	for i := range expected {
+		if log[i] != expected[i] {
+			t.Errorf("Expected action %d to be %s, got %s", i, expected[i], log[i])
+		}
+	}
+
+	last := observer.LastTransition()
+	if last == nil || last.From != "transaction.enter_amount" || last.To != "transaction.select_account" {
+		t.Errorf("Expected reset transition to be reported, got %+v", last)
+	}
+}
+
+func TestResetSubtree_Region(t *testing.T) {
+	machine := CreateParallelMachine()
+
+	_ = machine.Start()
+	_ = machine.HandleEvent("activate", nil)
+	_ = machine.HandleEvent("start_motor", nil)
+	_ = machine.HandleEvent("turn_on_lights", nil)
+
+	if state := machine.RegionState("motor"); state != "active.motor.running" {
+		t.Fatalf("Expected motor to be running, got %s", state)
+	}
+
+	if err := machine.ResetSubtree("active.motor"); err != nil {
+		t.Fatalf("Expected no error resetting region, got: %v", err)
+	}
+
+	if state := machine.RegionState("motor"); state != "active.motor.stopped" {
+		t.Errorf("Expected motor to be reset to stopped, got %s", state)
+	}
+	if state := machine.RegionState("lights"); state != "active.lights.on" {
+		t.Errorf("Expected lights region to be untouched, got %s", state)
+	}
+	if machine.IsStateActive("active.motor.running") {
+		t.Error("Expected running to be inactive after region reset")
+	}
+	if !machine.IsStateActive("active.motor.stopped") {
+		t.Error("Expected stopped to be active after region reset")
+	}
+	AssertState(t, machine, "active")
+}
+
+func TestResetSubtree_Errors(t *testing.T) {
+	var log []string
+	machine := createTransactionMachine(&log)
+
+	if err := machine.ResetSubtree("transaction"); !IsMachineError(err) {
+		t.Errorf("Expected machine error before start, got %v", err)
+	}
+
+	_ = machine.Start()
+
+	if err := machine.ResetSubtree("missing"); !IsStateError(err) || GetErrorCode(err) != ErrCodeStateNotFound {
+		t.Errorf("Expected state not found error, got %v", err)
+	}
+
+	if err := machine.ResetSubtree("idle"); GetErrorCode(err) != ErrCodeInvalidState {
+		t.Errorf("Expected invalid state error for atomic state, got %v", err)
+	}
+
+	if err := machine.ResetSubtree("transaction"); GetErrorCode(err) != ErrCodeInvalidState {
+		t.Errorf("Expected invalid state error for inactive composite, got %v", err)
+	}
+}
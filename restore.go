@@ -0,0 +1,156 @@
+package fluo
+
+import (
+	"context"
+	"maps"
+	"runtime"
+	"slices"
+	"sync"
+)
+
+// StoreLister is implemented by stores that can list the instances they hold,
+// letting RestoreAll restore every stored instance
+type StoreLister interface {
+	IDs() []string
+}
+
+// RestoreOptions controls RestoreAll
+type RestoreOptions struct {
+	// IDs are the instances to restore. When empty, every instance of a store
+	// implementing StoreLister is restored.
+	IDs []string
+
+	// Concurrency bounds the number of instances loaded and validated at
+	// once. Zero or less uses GOMAXPROCS.
+	Concurrency int
+
+	// Lazy validates the snapshots but leaves the instances in the store,
+	// restoring each on its first event or Load instead of building every
+	// machine up front
+	Lazy bool
+}
+
+// RestoreReport is the outcome of RestoreAll
+type RestoreReport struct {
+	// Restored lists the instances restored, or validated when restoring
+	// lazily, in sorted order
+	Restored []string
+
+	// Failed maps the instances that could not be restored to their errors
+	Failed map[string]error
+}
+
+// RestoreAll restores stored instances in parallel, typically when a service
+// hosting many instances restarts. Every snapshot is validated against the
+// current definition, migrating it from older versions as Load does, and
+// instances failing to load or validate are reported in Failed without
+// stopping the others. Instances already tracked count as restored, and
+// archived instances, including stored tombstones, fail with
+// ErrCodeInstanceArchived. Restored instances are saved to the manager's
+// store after their next event, which may differ from store. The returned
+// error is set when the instances could not be listed or ctx ended before
+// every instance was attempted.
+func (m *Manager) RestoreAll(ctx context.Context, store Store, opts RestoreOptions) (*RestoreReport, error) {
+	ids := opts.IDs
+	if len(ids) == 0 {
+		lister, ok := store.(StoreLister)
+		if !ok {
+			return nil, NewConfigurationError("manager", "restoring every instance requires a store implementing StoreLister")
+		}
+		ids = lister.IDs()
+	}
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = runtime.GOMAXPROCS(0)
+	}
+
+	m.mutex.Lock()
+	lineage := m.lineage()
+	evicted := maps.Clone(m.evicted)
+	m.mutex.Unlock()
+
+	report := &RestoreReport{Failed: make(map[string]error)}
+	var reportMutex sync.Mutex
+	record := func(id string, err error) {
+		reportMutex.Lock()
+		defer reportMutex.Unlock()
+		if err != nil {
+			report.Failed[id] = err
+		} else {
+			report.Restored = append(report.Restored, id)
+		}
+	}
+
+	// Snapshots are loaded and restored without the manager mutex, so events
+	// keep flowing to tracked instances during a cold start
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+launch:
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			break launch
+		case slots <- struct{}{}:
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			record(id, m.restoreStored(lineage, store, id, evicted[id], opts.Lazy))
+		}()
+	}
+	wg.Wait()
+
+	slices.Sort(report.Restored)
+	return report, ctx.Err()
+}
+
+// restoreStored restores one stored instance of a RestoreAll run and tracks
+// it, or records it as pending when restoring lazily. Archived instances are
+// reported rather than brought back.
+func (m *Manager) restoreStored(lineage definitionLineage, store Store, id string, opts []MachineOption, lazy bool) error {
+	m.mutex.Lock()
+	_, tracked := m.instances[id]
+	archived := m.archived.has(id)
+	m.mutex.Unlock()
+	if tracked {
+		return nil
+	}
+	if archived {
+		return NewInstanceArchivedError(id)
+	}
+	machine, version, err := m.restoreStore(lineage, store, id, opts)
+	if err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	// The definition may have been upgraded or the instance loaded or
+	// archived meanwhile, all of which leave the restored machine unused
+	_, tracked = m.instances[id]
+	archived = m.archived.has(id)
+	if lazy || tracked || archived || m.version != lineage.version {
+		if sm, ok := machine.(*StateMachine); ok {
+			sm.suspend()
+		}
+	}
+	if tracked {
+		return nil
+	}
+	if archived {
+		return NewInstanceArchivedError(id)
+	}
+	if lazy || m.version != lineage.version {
+		m.pending[id] = store
+		return nil
+	}
+	m.instances[id] = m.newInstance(id, machine, version, opts)
+	delete(m.evicted, id)
+	delete(m.pending, id)
+	return nil
+}
@@ -0,0 +1,138 @@
+package fluo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// storedInstances fills a store with started instances of the test manager's
+// definition, each moved to the running state
+func storedInstances(t *testing.T, count int) *MemoryStore {
+	t.Helper()
+	store := NewMemoryStore()
+	source := newTestManager(WithStore(store))
+	for i := range count {
+		id := fmt.Sprintf("order-%d", i)
+		machine, _ := source.Create(id)
+		_ = machine.Start()
+		AssertEventProcessed(t, source.SendEvent(id, "start", nil), true)
+	}
+	return store
+}
+
+func TestManager_RestoreAll(t *testing.T) {
+	store := storedInstances(t, 8)
+	_ = store.Save("broken", &Snapshot{CurrentState: "missing", MachineState: MachineStateStarted})
+	manager := newTestManager(WithStore(store))
+
+	report, err := manager.RestoreAll(context.Background(), store, RestoreOptions{Concurrency: 3})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(report.Restored) != 8 || report.Restored[0] != "order-0" {
+		t.Errorf("Expected every valid instance to be restored, got %v", report.Restored)
+	}
+	if len(report.Failed) != 1 || report.Failed["broken"] == nil {
+		t.Errorf("Expected the broken snapshot to be reported, got %v", report.Failed)
+	}
+	if manager.Len() != 8 {
+		t.Errorf("Expected the restored instances to be tracked, got %v", manager.IDs())
+	}
+
+	machine, _ := manager.Get("order-3")
+	AssertState(t, machine, "running")
+	AssertEventProcessed(t, manager.SendEvent("order-3", "stop", nil), true)
+	AssertState(t, machine, "idle")
+
+	var configErr *ConfigurationError
+	if _, err := manager.RestoreAll(context.Background(), struct{ Store }{store}, RestoreOptions{}); !errors.As(err, &configErr) {
+		t.Errorf("Expected a store without listing to be rejected, got %v", err)
+	}
+}
+
+func TestManager_RestoreAllLazy(t *testing.T) {
+	store := storedInstances(t, 3)
+	manager := newTestManager()
+
+	report, err := manager.RestoreAll(context.Background(), store, RestoreOptions{
+		IDs:  []string{"order-0", "order-2", "order-9"},
+		Lazy: true,
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(report.Restored) != 2 || GetErrorCode(report.Failed["order-9"]) != ErrCodeInstanceNotFound {
+		t.Errorf("Expected two validated instances and a missing one, got %v %v", report.Restored, report.Failed)
+	}
+	if manager.Len() != 0 {
+		t.Errorf("Expected lazily restored instances not to be tracked yet, got %v", manager.IDs())
+	}
+	if _, err := manager.Create("order-0"); GetErrorCode(err) != ErrCodeInstanceExists {
+		t.Errorf("Expected a pending ID to be taken, got %v", err)
+	}
+
+	AssertEventProcessed(t, manager.SendEvent("order-2", "stop", nil), true)
+	machine, ok := manager.Get("order-2")
+	if !ok {
+		t.Fatal("Expected the first event to restore the instance")
+	}
+	AssertState(t, machine, "idle")
+	if _, ok := manager.Get("order-1"); ok {
+		t.Error("Expected instances outside IDs to stay in the store")
+	}
+
+	report, err = manager.RestoreAll(context.Background(), store, RestoreOptions{IDs: []string{"order-0"}})
+	if err != nil || len(report.Restored) != 1 {
+		t.Fatalf("Expected the pending instance to be restored, got %v %v", report, err)
+	}
+	if _, ok := manager.Get("order-0"); !ok {
+		t.Error("Expected an eager restore to track a pending instance")
+	}
+}
+
+func TestManager_RestoreAllCancelled(t *testing.T) {
+	store := storedInstances(t, 2)
+	manager := newTestManager()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	report, err := manager.RestoreAll(ctx, store, RestoreOptions{})
+	if err != context.Canceled {
+		t.Errorf("Expected the cancellation to be returned, got %v", err)
+	}
+	if manager.Len() != 0 || len(report.Restored) != 0 {
+		t.Errorf("Expected nothing to be restored, got %v", report.Restored)
+	}
+}
+
+func TestManager_RestoreAllSkipsArchived(t *testing.T) {
+	discard := ArchiveFunc(func(*ArchiveRecord) error { return nil })
+	backup := NewMemoryStore()
+	source := newArchivingManager(discard, WithStore(backup))
+	manager := newArchivingManager(discard)
+	for _, target := range []*Manager{source, manager} {
+		for _, id := range []string{"order-0", "order-1"} {
+			machine, _ := target.Create(id)
+			_ = machine.Start()
+			AssertEventProcessed(t, target.SendEvent(id, "start", nil), true)
+		}
+	}
+	AssertEventProcessed(t, manager.SendEvent("order-1", "finish", nil), true)
+	_ = manager.Delete("order-0")
+
+	report, err := manager.RestoreAll(context.Background(), backup, RestoreOptions{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(report.Restored) != 1 || report.Restored[0] != "order-0" {
+		t.Errorf("Expected only the live instance to be restored, got %v", report.Restored)
+	}
+	if GetErrorCode(report.Failed["order-1"]) != ErrCodeInstanceArchived {
+		t.Errorf("Expected the archived instance to be reported, got %v", report.Failed)
+	}
+	if _, ok := manager.Get("order-1"); ok {
+		t.Error("Expected the archived instance to stay untracked")
+	}
+}
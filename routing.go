@@ -0,0 +1,188 @@
+package fluo
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// RoutingExplanation describes how the machine would route an event without dispatching it
+type RoutingExplanation struct {
+	Event        string
+	CurrentState string
+	ActiveStates []string
+	Steps        []RoutingStep     // States consulted, in routing priority order
+	Match        *RoutingCandidate // Transition that would fire, nil if the event would be rejected
+	Rejection    string            // Reason the event would be rejected
+}
+
+// RoutingStep is one state consulted while routing an event
+type RoutingStep struct {
+	Priority   int
+	Origin     string // "regional", "active", "parallel", "parent-parallel", "hierarchical" or "parallel-region"
+	State      string
+	Region     string // Region of the state, if any
+	Candidates []RoutingCandidate
+}
+
+// RoutingCandidate is a transition declared for the event on a consulted state
+type RoutingCandidate struct {
+	Source   string
+	Target   string
+	Guard    string // Registered guard name, if any
+	Guarded  bool
+	Outcome  GuardOutcome
+	Priority int
+	Viable   bool
+	Reason   string // Why the transition would not fire
+}
+
+// String renders the explanation as one line per consulted state
+func (e RoutingExplanation) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "event '%s' in state '%s'\n", e.Event, e.CurrentState)
+	for _, step := range e.Steps {
+		fmt.Fprintf(&sb, "  %d %s %s:", step.Priority, step.Origin, step.State)
+		if len(step.Candidates) == 0 {
+			sb.WriteString(" no transitions")
+		}
+		for _, candidate := range step.Candidates {
+			if candidate.Viable {
+				fmt.Fprintf(&sb, " -> %s", candidate.Target)
+			} else {
+				fmt.Fprintf(&sb, " -> %s (%s)", candidate.Target, candidate.Reason)
+			}
+		}
+		sb.WriteString("\n")
+	}
+	if e.Match != nil {
+		fmt.Fprintf(&sb, "  matched %s -> %s\n", e.Match.Source, e.Match.Target)
+	} else {
+		fmt.Fprintf(&sb, "  rejected: %s\n", e.Rejection)
+	}
+	return sb.String()
+}
+
+// routingProbe marks the transition selector as probed: Peek, Explain and
+// AvailableEvents run the selector of HandleEvent without dispatching, and
+// guards evaluated meanwhile are not reported to observers, the panic
+// reporter or the panic policy
+type routingProbe struct {
+	skipGuards bool          // Treat guarded transitions as viable without running their guards
+	steps      []RoutingStep // States consulted, in routing order
+	current    *RoutingStep  // Step receiving the transitions considered, nil for a state consulted before
+}
+
+// consult records a state consulted by the selector. A state the same
+// priority consulted before keeps its first step.
+func (p *routingProbe) consult(priority int, origin, stateID string, region Region) {
+	p.current = nil
+	for _, step := range p.steps {
+		if step.Priority == priority && step.State == stateID {
+			return
+		}
+	}
+	step := RoutingStep{Priority: priority, Origin: origin, State: stateID}
+	if region != nil {
+		step.Region = region.ID()
+	}
+	p.steps = append(p.steps, step)
+	p.current = &p.steps[len(p.steps)-1]
+}
+
+// consider records a transition of the consulted state, with the outcome of
+// its guard and the reason it cannot fire, if any. It does nothing unless the
+// selector is probed.
+func (p *routingProbe) consider(transition Transition, outcome GuardOutcome, reason string) {
+	if p == nil || p.current == nil {
+		return
+	}
+	p.current.Candidates = append(p.current.Candidates, RoutingCandidate{
+		Source:   p.current.State,
+		Target:   transition.TargetState,
+		Guard:    transition.GuardName,
+		Guarded:  transition.Guard != nil,
+		Outcome:  outcome,
+		Priority: transition.Priority,
+		Viable:   reason == "",
+		Reason:   reason,
+	})
+}
+
+// startProbe probes the selector for an event. Guards see the event as the
+// current event, and the trace of an event being resolved is left untouched.
+// With skipGuards guarded transitions are selected without running their
+// guards. The returned function ends the probe. The caller must hold the
+// machine mutex.
+func (sm *StateMachine) startProbe(event Event, skipGuards bool) (*routingProbe, func()) {
+	probe := &routingProbe{skipGuards: skipGuards}
+	outer, outerTrace := sm.probe, sm.trace
+	sm.probe, sm.trace = probe, eventTrace{}
+
+	var previous Event
+	smCtx, hasEvents := sm.context.(*StateMachineContext)
+	if hasEvents && event != nil {
+		previous = smCtx.GetCurrentEvent()
+		smCtx.updateCurrentEvent(event)
+	}
+	return probe, func() {
+		if hasEvents && event != nil {
+			smCtx.updateCurrentEvent(previous)
+		}
+		sm.probe, sm.trace = outer, outerTrace
+	}
+}
+
+// probeTarget returns the state a target settles in, as settledTarget, while
+// probing. The caller must hold the machine mutex.
+func (sm *StateMachine) probeTarget(target string) (string, error) {
+	_, end := sm.startProbe(nil, false)
+	defer end()
+	return sm.settledTarget(target)
+}
+
+// ExplainRouting reports which states the transition selector consults for an
+// event, in routing priority order, and how it judged their transitions, up to
+// the transition that would fire. Guards are evaluated without being reported
+// and nothing is dispatched. Active states are listed in ID order.
+func (sm *StateMachine) ExplainRouting(eventName string) RoutingExplanation {
+	sm.mutex.Lock()
+	defer sm.unlock()
+
+	explanation := RoutingExplanation{
+		Event:        eventName,
+		CurrentState: sm.currentState,
+		ActiveStates: slices.Sorted(maps.Keys(sm.activeStates)),
+	}
+	event := NewEvent(eventName, nil)
+	probe, end := sm.startProbe(event, false)
+	transition, _, err := sm.findMatchingTransition(eventName, event)
+	end()
+
+	explanation.Steps = probe.steps
+	if err == nil && transition != nil {
+		// The selector stops at the transition that fires
+		step := &explanation.Steps[len(explanation.Steps)-1]
+		explanation.Match = &step.Candidates[len(step.Candidates)-1]
+		return explanation
+	}
+	explanation.Rejection = fmt.Sprintf("no valid transition found for event '%s' in state '%s'", eventName, sm.currentState)
+	if GetErrorCode(err) == ErrCodeAmbiguousTransition {
+		explanation.Rejection = err.Error()
+	} else if reason := sm.completedRegionRejection(eventName); reason != "" {
+		explanation.Rejection = reason
+	}
+	return explanation
+}
+
+// explainGuard evaluates a guard, recovering panics without reporting them
+func explainGuard(guard GuardFunc, ctx Context) (result bool, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = false
+			err = fmt.Errorf("guard panic: %v", r)
+		}
+	}()
+	return guard(ctx), nil
+}
@@ -0,0 +1,96 @@
+package fluo
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExplainRouting_Match(t *testing.T) {
+	calls := 0
+	isVIP := func(ctx Context) bool {
+		calls++
+		return false
+	}
+	definition := NewMachine().
+		State("idle").Initial().
+		To("vip").On("go").When(isVIP).
+		To("normal").On("go").
+		State("vip").
+		State("normal").
+		Build()
+
+	machine := definition.CreateInstance()
+	_ = machine.Start()
+
+	explanation := machine.ExplainRouting("go")
+	if explanation.Match == nil || explanation.Match.Target != "normal" {
+		t.Fatalf("Expected match to normal, got %+v", explanation.Match)
+	}
+	if calls == 0 {
+		t.Error("Expected the guard to be evaluated")
+	}
+	AssertState(t, machine, "idle")
+
+	var rejected *RoutingCandidate
+	for _, step := range explanation.Steps {
+		for i := range step.Candidates {
+			if step.Candidates[i].Target == "vip" {
+				rejected = &step.Candidates[i]
+			}
+		}
+	}
+	if rejected == nil || rejected.Viable || rejected.Reason != "guard rejected" {
+		t.Errorf("Expected guarded candidate to be rejected, got %+v", rejected)
+	}
+}
+
+func TestExplainRouting_Rejected(t *testing.T) {
+	definition := NewMachine().
+		State("idle").Initial().
+		To("busy").On("start").
+		State("busy").
+		Build()
+
+	machine := definition.CreateInstance()
+	_ = machine.Start()
+
+	explanation := machine.ExplainRouting("stop")
+	if explanation.Match != nil {
+		t.Fatalf("Expected no match, got %+v", explanation.Match)
+	}
+	result := machine.HandleEvent("stop", nil)
+	if explanation.Rejection != result.RejectionReason {
+		t.Errorf("Expected rejection %q, got %q", result.RejectionReason, explanation.Rejection)
+	}
+	if !strings.Contains(explanation.String(), "rejected:") {
+		t.Errorf("Expected rendered rejection, got %s", explanation.String())
+	}
+}
+
+func TestExplainRouting_ParallelOrder(t *testing.T) {
+	builder := NewMachine()
+	builder.State("start").Initial().
+		To("work").On("begin")
+	parallel := builder.ParallelState("work")
+	parallel.Region("a").State("idle").Initial().
+		To("done").On("tick")
+	parallel.Region("a").State("done")
+	parallel.Region("b").State("idle").Initial()
+
+	machine := builder.Build().CreateInstance()
+	_ = machine.Start()
+	machine.HandleEvent("begin", nil)
+
+	explanation := machine.ExplainRouting("tick")
+	if len(explanation.Steps) == 0 || explanation.Steps[0].Priority != 1 || explanation.Steps[0].Region != "a" {
+		t.Fatalf("Expected regional states to be consulted first, got %+v", explanation.Steps)
+	}
+	for i := 1; i < len(explanation.Steps); i++ {
+		if explanation.Steps[i].Priority < explanation.Steps[i-1].Priority {
+			t.Errorf("Expected steps in priority order, got %+v", explanation.Steps)
+		}
+	}
+	if explanation.Match == nil || explanation.Match.Source != "work.a.idle" {
+		t.Errorf("Expected match from work.a.idle, got %+v", explanation.Match)
+	}
+}
@@ -0,0 +1,220 @@
+package fluo
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultPayloadSummaryLimit is the length at which payload summaries are truncated
+const DefaultPayloadSummaryLimit = 80
+
+// Scenario is a recorded event sequence stored as a test fixture
+type Scenario struct {
+	Name    string          `json:"name"`
+	Initial string          `json:"initial"`
+	Events  []ScenarioEvent `json:"events"`
+}
+
+// ScenarioEvent is a single recorded event and its outcome
+type ScenarioEvent struct {
+	Event string `json:"event"`
+	// Payload summarizes the event data; Data holds it only when the recorder captures payloads
+	Payload string `json:"payload,omitempty"`
+	Data    any    `json:"data,omitempty"`
+	// Offset is the time since the first recorded event
+	Offset   time.Duration `json:"offset"`
+	Accepted bool          `json:"accepted"`
+	From     string        `json:"from,omitempty"`
+	To       string        `json:"to,omitempty"`
+	Reason   string        `json:"reason,omitempty"`
+}
+
+// LoadScenarioJSON decodes a scenario fixture
+func LoadScenarioJSON(data []byte) (*Scenario, error) {
+	var scenario Scenario
+	if err := json.Unmarshal(data, &scenario); err != nil {
+		return nil, fmt.Errorf("failed to decode scenario: %w", err)
+	}
+	return &scenario, nil
+}
+
+// JSON encodes the scenario as an indented fixture
+func (s *Scenario) JSON() ([]byte, error) {
+	return json.MarshalIndent(s, "", "  ")
+}
+
+// Journal converts the scenario to journal entries for VerifyReplay. Rejected
+// events are included so a replay checks that they are still rejected.
+func (s *Scenario) Journal() []JournalEntry {
+	journal := make([]JournalEntry, 0, len(s.Events))
+	for _, event := range s.Events {
+		journal = append(journal, JournalEntry{
+			EventName:     event.Event,
+			EventData:     event.Data,
+			Processed:     event.Accepted,
+			PreviousState: event.From,
+			CurrentState:  event.To,
+		})
+	}
+	return journal
+}
+
+// Verify replays the scenario against a definition and reports every outcome
+// that differs from the recording
+func (s *Scenario) Verify(def MachineDefinition, runs int) (*ReplayReport, error) {
+	return VerifyReplay(def, s.Journal(), runs)
+}
+
+// ScenarioRecorder is an observer that records the events a live machine
+// receives into a Scenario fixture. Completion events are not recorded since
+// replaying the event that triggered them reproduces them.
+type ScenarioRecorder struct {
+	BaseObserver
+	name            string
+	summaryLimit    int
+	capturePayloads bool
+
+	mutex    sync.Mutex
+	scenario Scenario
+	start    time.Time
+	last     Event
+}
+
+// ScenarioRecorderOption configures a ScenarioRecorder
+type ScenarioRecorderOption func(*ScenarioRecorder)
+
+// WithPayloadCapture records full event data alongside the payload summaries,
+// so the scenario can be replayed with its original payloads
+func WithPayloadCapture() ScenarioRecorderOption {
+	return func(r *ScenarioRecorder) {
+		r.capturePayloads = true
+	}
+}
+
+// WithPayloadSummaryLimit sets the length at which payload summaries are truncated
+func WithPayloadSummaryLimit(limit int) ScenarioRecorderOption {
+	return func(r *ScenarioRecorder) {
+		r.summaryLimit = limit
+	}
+}
+
+// NewScenarioRecorder creates a recorder for a scenario with the given name
+func NewScenarioRecorder(name string, opts ...ScenarioRecorderOption) *ScenarioRecorder {
+	r := &ScenarioRecorder{
+		name:         name,
+		summaryLimit: DefaultPayloadSummaryLimit,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	r.scenario = Scenario{Name: name, Events: make([]ScenarioEvent, 0)}
+	return r
+}
+
+// Scenario returns a copy of the scenario recorded so far
+func (r *ScenarioRecorder) Scenario() *Scenario {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	scenario := r.scenario
+	scenario.Events = append([]ScenarioEvent(nil), r.scenario.Events...)
+	return &scenario
+}
+
+// Reset discards the recorded events
+func (r *ScenarioRecorder) Reset() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.scenario = Scenario{Name: r.name, Events: make([]ScenarioEvent, 0)}
+	r.start = time.Time{}
+	r.last = nil
+}
+
+// OnMachineStarted records the state the machine started in
+func (r *ScenarioRecorder) OnMachineStarted(ctx Context) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if len(r.scenario.Events) == 0 {
+		r.scenario.Initial = ctx.GetCurrentState()
+	}
+}
+
+// OnTransition records an accepted event. Further transitions taken for the
+// same event, such as through a choice, update the recorded target.
+func (r *ScenarioRecorder) OnTransition(from string, to string, event Event, ctx Context) {
+	if event == nil || strings.HasPrefix(event.GetName(), "__completion_") {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if event == r.last {
+		r.scenario.Events[len(r.scenario.Events)-1].To = to
+		return
+	}
+	r.record(event, ScenarioEvent{Accepted: true, From: from, To: to})
+}
+
+// OnInternalTransition records an event handled by an internal transition,
+// which leaves the machine in the state that handled it
+func (r *ScenarioRecorder) OnInternalTransition(state string, event Event, ctx Context) {
+	if event == nil {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	// Regions handling a broadcast event after the first add nothing to replay
+	if event == r.last {
+		return
+	}
+	r.record(event, ScenarioEvent{Accepted: true, From: state, To: state})
+}
+
+// OnEventRejected records a rejected event with its reason
+func (r *ScenarioRecorder) OnEventRejected(event Event, reason string, ctx Context) {
+	if event == nil {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.record(event, ScenarioEvent{From: ctx.GetCurrentState(), To: ctx.GetCurrentState(), Reason: reason})
+}
+
+// record appends an event. The caller must hold the recorder mutex.
+func (r *ScenarioRecorder) record(event Event, entry ScenarioEvent) {
+	now := time.Now()
+	if r.start.IsZero() {
+		r.start = now
+	}
+
+	entry.Event = event.GetName()
+	entry.Offset = now.Sub(r.start)
+	if data := event.GetData(); data != nil {
+		entry.Payload = summarizePayload(data, r.summaryLimit)
+		if r.capturePayloads {
+			entry.Data = data
+		}
+	}
+
+	r.scenario.Events = append(r.scenario.Events, entry)
+	r.last = event
+}
+
+// summarizePayload renders event data as a string truncated to limit runes
+func summarizePayload(data any, limit int) string {
+	summary := fmt.Sprintf("%+v", data)
+	if runes := []rune(summary); limit > 0 && len(runes) > limit {
+		return string(runes[:limit]) + "…"
+	}
+	return summary
+}
@@ -0,0 +1,136 @@
+package fluo
+
+import (
+	"strings"
+	"testing"
+)
+
+func buildScenarioDefinition() MachineDefinition {
+	builder := NewMachine()
+	builder.State("idle").Initial().
+		To("route").On("submit")
+	isLarge := func(ctx Context) bool {
+		amount, _ := ctx.Get("amount")
+		return amount == 100
+	}
+	builder.Choice("route").When(isLarge).To("manual").Otherwise("auto")
+	builder.State("manual").
+		To("idle").On("reset")
+	builder.State("auto").
+		To("idle").On("reset")
+	return builder.Build()
+}
+
+func TestScenarioRecorder_Records(t *testing.T) {
+	recorder := NewScenarioRecorder("checkout", WithPayloadSummaryLimit(10))
+	machine := buildScenarioDefinition().CreateInstance()
+	machine.AddObserver(recorder)
+	_ = machine.Start()
+
+	machine.HandleEvent("submit", strings.Repeat("x", 20))
+	machine.HandleEvent("submit", nil)
+	machine.HandleEvent("reset", nil)
+
+	scenario := recorder.Scenario()
+	if scenario.Name != "checkout" || scenario.Initial != "idle" {
+		t.Errorf("Unexpected scenario header: %+v", scenario)
+	}
+	if len(scenario.Events) != 3 {
+		t.Fatalf("Expected 3 events, got %+v", scenario.Events)
+	}
+
+	first := scenario.Events[0]
+	if !first.Accepted || first.From != "idle" || first.To != "auto" {
+		t.Errorf("Expected choice to be folded into one event, got %+v", first)
+	}
+	if first.Payload != strings.Repeat("x", 10)+"…" || first.Data != nil {
+		t.Errorf("Expected a truncated summary without data, got %+v", first)
+	}
+	if second := scenario.Events[1]; second.Accepted || second.Reason == "" {
+		t.Errorf("Expected a rejected event with reason, got %+v", second)
+	}
+	if scenario.Events[2].Offset < scenario.Events[0].Offset {
+		t.Error("Expected offsets to increase")
+	}
+}
+
+func TestScenario_RoundTripAndVerify(t *testing.T) {
+	def := buildScenarioDefinition()
+	recorder := NewScenarioRecorder("regression", WithPayloadCapture())
+	machine := def.CreateInstance()
+	machine.AddObserver(recorder)
+	_ = machine.Start()
+
+	machine.HandleEvent("submit", "order-1")
+	machine.HandleEvent("submit", nil)
+	machine.HandleEvent("reset", nil)
+
+	data, err := recorder.Scenario().JSON()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	fixture, err := LoadScenarioJSON(data)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if fixture.Events[0].Data != "order-1" {
+		t.Errorf("Expected captured payload, got %v", fixture.Events[0].Data)
+	}
+
+	report, err := fixture.Verify(def, 1)
+	if err != nil || !report.Deterministic() {
+		t.Fatalf("Expected the fixture to replay, got %+v, %v", report, err)
+	}
+
+	drifted := NewMachine()
+	drifted.State("idle").Initial().
+		To("auto").On("submit")
+	drifted.State("auto")
+	report, _ = fixture.Verify(drifted.Build(), 1)
+	if report.Deterministic() {
+		t.Error("Expected a changed definition to be reported")
+	}
+}
+
+func TestScenarioRecorder_Reset(t *testing.T) {
+	recorder := NewScenarioRecorder("reset")
+	machine := buildScenarioDefinition().CreateInstance()
+	machine.AddObserver(recorder)
+	_ = machine.Start()
+	machine.HandleEvent("submit", nil)
+
+	recorder.Reset()
+	if events := recorder.Scenario().Events; len(events) != 0 {
+		t.Errorf("Expected no events after reset, got %+v", events)
+	}
+}
+
+func TestScenarioRecorder_RecordsInternalTransitions(t *testing.T) {
+	builder := NewMachine()
+	builder.State("editing").Initial().
+		ToSelf().On("autosave").Internal().
+		To("published").On("publish")
+	builder.State("published")
+	def := builder.Build()
+
+	recorder := NewScenarioRecorder("draft")
+	machine := def.CreateInstance()
+	machine.AddObserver(recorder)
+	_ = machine.Start()
+
+	machine.HandleEvent("autosave", nil)
+	machine.HandleEvent("autosave", nil)
+	machine.HandleEvent("publish", nil)
+
+	scenario := recorder.Scenario()
+	if len(scenario.Events) != 3 {
+		t.Fatalf("Expected 3 events, got %+v", scenario.Events)
+	}
+	if internal := scenario.Events[1]; internal.Event != "autosave" || !internal.Accepted || internal.From != "editing" || internal.To != "editing" {
+		t.Errorf("Expected the internal transition to be recorded in place, got %+v", internal)
+	}
+	report, err := scenario.Verify(def, 1)
+	if err != nil || !report.Deterministic() {
+		t.Fatalf("Expected the fixture to replay, got %+v, %v", report, err)
+	}
+}
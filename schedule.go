@@ -0,0 +1,103 @@
+package fluo
+
+import (
+	"sync"
+	"time"
+)
+
+// ScheduledEvent is an event waiting to be sent by SendEventAfter or SendEventAt
+type ScheduledEvent struct {
+	schedule *eventSchedule
+	name     string
+	at       time.Time
+	timer    *time.Timer
+	result   chan *EventResult
+}
+
+// eventSchedule tracks the pending scheduled events of a machine. It has its
+// own mutex so actions can schedule events while the machine mutex is held.
+type eventSchedule struct {
+	mutex   sync.Mutex
+	pending map[*ScheduledEvent]struct{}
+}
+
+// Name returns the name of the scheduled event
+func (e *ScheduledEvent) Name() string {
+	return e.name
+}
+
+// At returns when the event is due
+func (e *ScheduledEvent) At() time.Time {
+	return e.at
+}
+
+// Result returns a channel receiving the result once the event has been
+// processed. Nothing is delivered for a cancelled event.
+func (e *ScheduledEvent) Result() <-chan *EventResult {
+	return e.result
+}
+
+// Cancel prevents the event from being sent and reports whether it was still
+// pending. Stopping or resetting the machine cancels its scheduled events.
+func (e *ScheduledEvent) Cancel() bool {
+	if !e.schedule.remove(e) {
+		return false
+	}
+	e.timer.Stop()
+	return true
+}
+
+// SendEventAfter sends an event once the delay has passed. The event is
+// processed like one sent with SendEventAsync, through the queue in event-loop
+// mode. Actions may schedule follow-up events.
+func (sm *StateMachine) SendEventAfter(delay time.Duration, eventName string, eventData any) *ScheduledEvent {
+	return sm.SendEventAt(time.Now().Add(delay), eventName, eventData)
+}
+
+// SendEventAt sends an event at the given time, or right away when the time
+// has passed
+func (sm *StateMachine) SendEventAt(at time.Time, eventName string, eventData any) *ScheduledEvent {
+	scheduled := &ScheduledEvent{
+		schedule: &sm.schedule,
+		name:     eventName,
+		at:       at,
+		result:   make(chan *EventResult, 1),
+	}
+
+	sm.schedule.mutex.Lock()
+	defer sm.schedule.mutex.Unlock()
+
+	if sm.schedule.pending == nil {
+		sm.schedule.pending = make(map[*ScheduledEvent]struct{})
+	}
+	sm.schedule.pending[scheduled] = struct{}{}
+	scheduled.timer = time.AfterFunc(max(time.Until(at), 0), func() {
+		if scheduled.schedule.remove(scheduled) {
+			scheduled.result <- <-sm.SendEventAsync(eventName, eventData)
+		}
+	})
+	return scheduled
+}
+
+// remove drops a pending event and reports whether it was still pending
+func (s *eventSchedule) remove(scheduled *ScheduledEvent) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, pending := s.pending[scheduled]; !pending {
+		return false
+	}
+	delete(s.pending, scheduled)
+	return true
+}
+
+// cancelAll cancels every pending event
+func (s *eventSchedule) cancelAll() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for scheduled := range s.pending {
+		scheduled.timer.Stop()
+	}
+	clear(s.pending)
+}
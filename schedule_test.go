@@ -0,0 +1,88 @@
+package fluo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSendEventAfter(t *testing.T) {
+	machine := CreateSimpleMachine()
+	_ = machine.Start()
+
+	scheduled := machine.SendEventAfter(20*time.Millisecond, "start", nil)
+	if scheduled.Name() != "start" || time.Until(scheduled.At()) <= 0 {
+		t.Errorf("Unexpected scheduled event: %s at %v", scheduled.Name(), scheduled.At())
+	}
+	AssertState(t, machine, "idle")
+
+	select {
+	case result := <-scheduled.Result():
+		AssertEventProcessed(t, result, true)
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for the scheduled event")
+	}
+	AssertState(t, machine, "running")
+
+	if scheduled.Cancel() {
+		t.Error("Expected a sent event not to be cancellable")
+	}
+}
+
+func TestSendEventAt_PastTime(t *testing.T) {
+	machine := CreateSimpleMachine()
+	_ = machine.Start()
+
+	scheduled := machine.SendEventAt(time.Now().Add(-time.Minute), "start", nil)
+	select {
+	case <-scheduled.Result():
+	case <-time.After(time.Second):
+		t.Fatal("Expected an overdue event to be sent right away")
+	}
+	AssertState(t, machine, "running")
+}
+
+func TestScheduledEvent_Cancel(t *testing.T) {
+	machine := CreateSimpleMachine()
+	_ = machine.Start()
+
+	scheduled := machine.SendEventAfter(20*time.Millisecond, "start", nil)
+	if !scheduled.Cancel() {
+		t.Fatal("Expected a pending event to be cancelled")
+	}
+	if scheduled.Cancel() {
+		t.Error("Expected a second cancel to report nothing pending")
+	}
+
+	time.Sleep(40 * time.Millisecond)
+	AssertState(t, machine, "idle")
+}
+
+func TestScheduledEvent_StopCancels(t *testing.T) {
+	machine := CreateSimpleMachine()
+	_ = machine.Start()
+
+	scheduled := machine.SendEventAfter(20*time.Millisecond, "start", nil)
+	_ = machine.Stop()
+
+	if scheduled.Cancel() {
+		t.Error("Expected Stop to cancel scheduled events")
+	}
+}
+
+func TestSendEventAfter_FromAction(t *testing.T) {
+	builder := NewMachine()
+	builder.State("pending").Initial().
+		To("awaiting").On("submit").Do(func(ctx Context) error {
+		ctx.GetMachine().SendEventAfter(10*time.Millisecond, "remind", nil)
+		return nil
+	})
+	builder.State("awaiting").
+		To("reminded").On("remind")
+	builder.State("reminded")
+
+	machine := builder.Build().CreateInstance()
+	_ = machine.Start()
+	AssertEventProcessed(t, machine.HandleEvent("submit", nil), true)
+
+	waitForState(t, machine, "reminded", time.Second)
+}
@@ -0,0 +1,291 @@
+package fluo
+
+import "fmt"
+
+// CompositeScope declares the contents of a composite state inside a
+// MachineBuilder.Composite closure. State IDs are relative to the composite.
+type CompositeScope interface {
+	State(id string) StateBuilder
+	Composite(id string, declare func(CompositeScope))
+
+	Choice(id string) ChoiceBuilder
+	Junction(id string) JunctionBuilder
+	Fork(id string) ForkBuilder
+	Join(id string) JoinBuilder
+	History(id string) HistoryBuilder
+	DeepHistory(id string) HistoryBuilder
+	EntryPoint(id string) ConnectionPointBuilder
+	ExitPoint(id string) ConnectionPointBuilder
+
+	// Transitions from the composite state itself
+	To(target string) TransitionBuilder
+
+	// Path returns the path of the composite state, for naming its nested
+	// states in targets declared elsewhere
+	Path() StatePath
+}
+
+// ParallelScope declares the regions of a parallel state inside a
+// MachineBuilder.Parallel closure
+type ParallelScope interface {
+	Region(id string, declare func(RegionScope))
+	RegionReentry(policy RegionReentryPolicy)
+	History(id string) HistoryBuilder
+	DeepHistory(id string) HistoryBuilder
+
+	// Transitions from the parallel state itself
+	To(target string) TransitionBuilder
+
+	// Path returns the path of the parallel state
+	Path() StatePath
+}
+
+// RegionScope declares the contents of a parallel region inside a
+// ParallelScope.Region closure. State IDs are relative to the region.
+type RegionScope interface {
+	State(id string) StateBuilder
+	FinalState(id string) StateBuilder
+	Composite(id string, declare func(CompositeScope))
+
+	Choice(id string) ChoiceBuilder
+	Junction(id string) JunctionBuilder
+	Fork(id string) ForkBuilder
+	Join(id string) JoinBuilder
+	History(id string) HistoryBuilder
+	DeepHistory(id string) HistoryBuilder
+
+	// Path returns the path of the region, which its states are nested in
+	Path() StatePath
+}
+
+// Composite declares a composite state and its contents in a closure. Any
+// pending transition is saved before each declaration and when the closure
+// returns, and states declared on the machine builder while the closure runs,
+// for instance by navigating away from a transition chain with State(), make
+// building fail instead of silently landing at the top level.
+func (mb *machineBuilderImpl) Composite(id string, declare func(CompositeScope)) MachineBuilder {
+	mb.checkScope("Composite", id)
+	mb.openComposite(id, declare)
+	return mb
+}
+
+// Parallel declares a parallel state and its regions in a closure, with the
+// same guard rails as Composite
+func (mb *machineBuilderImpl) Parallel(id string, declare func(ParallelScope)) MachineBuilder {
+	mb.checkScope("Parallel", id)
+	mb.saveCurrentTransition()
+
+	mb.scopeCalls++
+	psb := mb.ParallelState(id).(*parallelStateBuilderImpl)
+	mb.scopeCalls--
+
+	mb.runScope(id, func(s *builderScope) {
+		declare(&parallelScope{builderScope: s, psb: psb})
+	})
+	return mb
+}
+
+// openComposite creates a composite state and runs its declaring closure
+func (mb *machineBuilderImpl) openComposite(id string, declare func(CompositeScope)) {
+	mb.saveCurrentTransition()
+
+	mb.scopeCalls++
+	csb := mb.CompositeState(id).(*compositeStateBuilderImpl)
+	mb.scopeCalls--
+
+	mb.runScope(id, func(s *builderScope) {
+		declare(&compositeScope{builderScope: s, csb: csb})
+	})
+}
+
+// runScope runs a declaring closure with the scope open, then closes it
+func (mb *machineBuilderImpl) runScope(id string, run func(*builderScope)) {
+	scope := &builderScope{mb: mb, id: id}
+	mb.scopes = append(mb.scopes, id)
+	defer func() {
+		mb.saveCurrentTransition()
+		mb.scopes = mb.scopes[:len(mb.scopes)-1]
+		scope.closed = true
+	}()
+	run(scope)
+}
+
+// checkScope records an error when a declaration reaches the machine builder
+// directly while a scope closure is running
+func (mb *machineBuilderImpl) checkScope(operation, id string) {
+	if len(mb.scopes) == 0 || mb.scopeCalls > 0 {
+		return
+	}
+	scope := mb.scopes[len(mb.scopes)-1]
+	mb.scopeErrs = append(mb.scopeErrs, NewConfigurationError("builder",
+		fmt.Sprintf("%s('%s') was declared on the machine builder inside the closure of '%s'; declare it through the scope", operation, id, scope)))
+}
+
+// builderScope is the state shared by all scope kinds
+type builderScope struct {
+	mb     *machineBuilderImpl
+	id     string
+	closed bool
+}
+
+// Path returns the path of the state or region the scope declares
+func (s *builderScope) Path() StatePath {
+	return StatePath(s.id)
+}
+
+// enter starts a declaration through the scope. The returned function ends it.
+func (s *builderScope) enter(operation, id string) func() {
+	if s.closed {
+		s.mb.scopeErrs = append(s.mb.scopeErrs, NewConfigurationError("builder",
+			fmt.Sprintf("%s('%s') was declared through the scope of '%s' after its closure returned", operation, id, s.id)))
+	}
+	s.mb.saveCurrentTransition()
+	s.mb.scopeCalls++
+	return func() { s.mb.scopeCalls-- }
+}
+
+// compositeScope implements CompositeScope
+type compositeScope struct {
+	*builderScope
+	csb *compositeStateBuilderImpl
+}
+
+func (s *compositeScope) State(id string) StateBuilder {
+	defer s.enter("State", id)()
+	return s.csb.State(id)
+}
+
+func (s *compositeScope) Composite(id string, declare func(CompositeScope)) {
+	s.enter("Composite", id)()
+	s.mb.openComposite(Path(s.csb.stateID, id).String(), declare)
+}
+
+func (s *compositeScope) Choice(id string) ChoiceBuilder {
+	defer s.enter("Choice", id)()
+	return s.csb.Choice(id)
+}
+
+func (s *compositeScope) Junction(id string) JunctionBuilder {
+	defer s.enter("Junction", id)()
+	return s.csb.Junction(id)
+}
+
+func (s *compositeScope) Fork(id string) ForkBuilder {
+	defer s.enter("Fork", id)()
+	return s.csb.Fork(id)
+}
+
+func (s *compositeScope) Join(id string) JoinBuilder {
+	defer s.enter("Join", id)()
+	return s.csb.Join(id)
+}
+
+func (s *compositeScope) History(id string) HistoryBuilder {
+	defer s.enter("History", id)()
+	return s.csb.History(id)
+}
+
+func (s *compositeScope) DeepHistory(id string) HistoryBuilder {
+	defer s.enter("DeepHistory", id)()
+	return s.csb.DeepHistory(id)
+}
+
+func (s *compositeScope) EntryPoint(id string) ConnectionPointBuilder {
+	defer s.enter("EntryPoint", id)()
+	return s.csb.EntryPoint(id)
+}
+
+func (s *compositeScope) ExitPoint(id string) ConnectionPointBuilder {
+	defer s.enter("ExitPoint", id)()
+	return s.csb.ExitPoint(id)
+}
+
+func (s *compositeScope) To(target string) TransitionBuilder {
+	defer s.enter("To", target)()
+	return s.csb.To(target)
+}
+
+// parallelScope implements ParallelScope
+type parallelScope struct {
+	*builderScope
+	psb *parallelStateBuilderImpl
+}
+
+func (s *parallelScope) Region(id string, declare func(RegionScope)) {
+	s.enter("Region", id)()
+	rb := s.psb.Region(id).(*regionBuilderImpl)
+	s.mb.runScope(Path(s.psb.stateID, id).String(), func(scope *builderScope) {
+		declare(&regionScope{builderScope: scope, rb: rb})
+	})
+}
+
+func (s *parallelScope) RegionReentry(policy RegionReentryPolicy) {
+	defer s.enter("RegionReentry", s.psb.stateID)()
+	s.psb.RegionReentry(policy)
+}
+
+func (s *parallelScope) History(id string) HistoryBuilder {
+	defer s.enter("History", id)()
+	return s.psb.History(id)
+}
+
+func (s *parallelScope) DeepHistory(id string) HistoryBuilder {
+	defer s.enter("DeepHistory", id)()
+	return s.psb.DeepHistory(id)
+}
+
+func (s *parallelScope) To(target string) TransitionBuilder {
+	defer s.enter("To", target)()
+	return s.psb.To(target)
+}
+
+// regionScope implements RegionScope
+type regionScope struct {
+	*builderScope
+	rb *regionBuilderImpl
+}
+
+func (s *regionScope) State(id string) StateBuilder {
+	defer s.enter("State", id)()
+	return s.rb.State(id)
+}
+
+func (s *regionScope) FinalState(id string) StateBuilder {
+	defer s.enter("FinalState", id)()
+	return s.rb.FinalState(id)
+}
+
+func (s *regionScope) Composite(id string, declare func(CompositeScope)) {
+	s.enter("Composite", id)()
+	s.mb.openComposite(Path(s.rb.parentStateID, s.rb.regionID, id).String(), declare)
+}
+
+func (s *regionScope) Choice(id string) ChoiceBuilder {
+	defer s.enter("Choice", id)()
+	return s.rb.Choice(id)
+}
+
+func (s *regionScope) Junction(id string) JunctionBuilder {
+	defer s.enter("Junction", id)()
+	return s.rb.Junction(id)
+}
+
+func (s *regionScope) Fork(id string) ForkBuilder {
+	defer s.enter("Fork", id)()
+	return s.rb.Fork(id)
+}
+
+func (s *regionScope) Join(id string) JoinBuilder {
+	defer s.enter("Join", id)()
+	return s.rb.Join(id)
+}
+
+func (s *regionScope) History(id string) HistoryBuilder {
+	defer s.enter("History", id)()
+	return s.rb.History(id)
+}
+
+func (s *regionScope) DeepHistory(id string) HistoryBuilder {
+	defer s.enter("DeepHistory", id)()
+	return s.rb.DeepHistory(id)
+}
@@ -0,0 +1,130 @@
+package fluo
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestScopedBuilder_Composite(t *testing.T) {
+	builder := NewMachine()
+	builder.State("idle").Initial().
+		To("active").On("activate")
+
+	builder.Composite("active", func(c CompositeScope) {
+		c.State("loading").Initial().
+			To("ready").On("loaded")
+		c.State("ready").
+			To("loading").On("reload")
+		c.Composite("settings", func(s CompositeScope) {
+			s.State("general").Initial()
+		})
+		c.To("idle").On("deactivate")
+	})
+	builder.State("done")
+
+	definition, err := builder.BuildE()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	states := definition.GetStates()
+	for _, id := range []string{"active.loading", "active.ready", "active.settings", "active.settings.general", "done"} {
+		if _, ok := states[id]; !ok {
+			t.Errorf("Expected state '%s' to be declared", id)
+		}
+	}
+
+	machine := definition.CreateInstance()
+	_ = machine.Start()
+	AssertEventProcessed(t, machine.HandleEvent("activate", nil), true)
+	AssertState(t, machine, "active.loading")
+	AssertEventProcessed(t, machine.HandleEvent("loaded", nil), true)
+	AssertState(t, machine, "active.ready")
+}
+
+func TestScopedBuilder_Parallel(t *testing.T) {
+	builder := NewMachine()
+	builder.State("start").Initial().
+		To("checks").On("begin")
+
+	builder.Parallel("checks", func(p ParallelScope) {
+		p.Region("credit", func(r RegionScope) {
+			r.State("pending").Initial().
+				To("done").On("credit_ok")
+			r.FinalState("done")
+		})
+		p.Region("fraud", func(r RegionScope) {
+			r.State("pending").Initial().
+				To("done").On("fraud_ok")
+			r.FinalState("done")
+		})
+		p.To("approved").OnCompletion()
+	})
+	builder.State("approved")
+
+	machine := builder.Build().CreateInstance()
+	_ = machine.Start()
+	machine.HandleEvent("begin", nil)
+	if state := machine.RegionState("checks.credit"); state != "checks.credit.pending" {
+		t.Fatalf("Expected credit region pending, got %q", state)
+	}
+	machine.HandleEvent("credit_ok", nil)
+	machine.HandleEvent("fraud_ok", nil)
+	AssertState(t, machine, "approved")
+}
+
+func TestScopedBuilder_EscapedDeclaration(t *testing.T) {
+	builder := NewMachine()
+	builder.State("idle").Initial().
+		To("active").On("activate")
+
+	builder.Composite("active", func(c CompositeScope) {
+		c.State("a").Initial().
+			To("b").On("next").
+			State("b") // Navigates to the machine builder, declaring a top-level "b"
+	})
+
+	_, err := builder.BuildE()
+	if err == nil || !strings.Contains(err.Error(), "State('b') was declared on the machine builder inside the closure of 'active'") {
+		t.Errorf("Expected escaped declaration error, got %v", err)
+	}
+}
+
+func TestScopedBuilder_ScopeUsedAfterClosure(t *testing.T) {
+	builder := NewMachine()
+	builder.State("idle").Initial()
+
+	var leaked CompositeScope
+	builder.Composite("active", func(c CompositeScope) {
+		c.State("a").Initial()
+		leaked = c
+	})
+	leaked.State("late")
+
+	_, err := builder.BuildE()
+	if err == nil || !strings.Contains(err.Error(), "after its closure returned") {
+		t.Errorf("Expected use-after-close error, got %v", err)
+	}
+}
+
+func TestScopedBuilder_PendingTransitionSavedOnClose(t *testing.T) {
+	builder := NewMachine()
+	builder.State("idle").Initial().
+		To("active").On("activate")
+
+	builder.Composite("active", func(c CompositeScope) {
+		c.State("a").Initial().
+			To("b").On("next")
+		c.History("history").Default("a")
+		c.State("b")
+	})
+
+	definition := builder.Build()
+	found := false
+	for _, transition := range definition.GetTransitions()["active.a"] {
+		found = found || (transition.EventName == "next" && transition.TargetState == "active.b")
+	}
+	if !found {
+		t.Errorf("Expected the transition declared before History() to be kept, got %+v", definition.GetTransitions()["active.a"])
+	}
+}
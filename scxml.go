@@ -0,0 +1,548 @@
+package fluo
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+)
+
+// scxmlNamespace is the W3C SCXML namespace
+const scxmlNamespace = "http://www.w3.org/2005/07/scxml"
+
+// scxmlNode is a generic SCXML element used for both parsing and rendering
+type scxmlNode struct {
+	XMLName  xml.Name
+	Xmlns    string      `xml:"xmlns,attr,omitempty"`
+	Version  string      `xml:"version,attr,omitempty"`
+	ID       string      `xml:"id,attr,omitempty"`
+	Initial  string      `xml:"initial,attr,omitempty"`
+	Type     string      `xml:"type,attr,omitempty"`
+	Event    string      `xml:"event,attr,omitempty"`
+	Cond     string      `xml:"cond,attr,omitempty"`
+	Target   string      `xml:"target,attr,omitempty"`
+	Children []scxmlNode `xml:",any"`
+}
+
+// isStateElement reports whether the element declares a state
+func (n scxmlNode) isStateElement() bool {
+	switch n.XMLName.Local {
+	case "state", "parallel", "final", "history":
+		return true
+	}
+	return false
+}
+
+// transitions returns the transition children of an element
+func (n scxmlNode) transitions() []scxmlNode {
+	var transitions []scxmlNode
+	for _, child := range n.Children {
+		if child.XMLName.Local == "transition" {
+			transitions = append(transitions, child)
+		}
+	}
+	return transitions
+}
+
+// initialTarget returns the initial child of an element from its initial
+// attribute, its <initial> element, or its first state child
+func (n scxmlNode) initialTarget() string {
+	if n.Initial != "" {
+		return strings.Fields(n.Initial)[0]
+	}
+	for _, child := range n.Children {
+		if child.XMLName.Local == "initial" {
+			for _, transition := range child.transitions() {
+				if transition.Target != "" {
+					return strings.Fields(transition.Target)[0]
+				}
+			}
+		}
+	}
+	for _, child := range n.Children {
+		if child.isStateElement() && child.XMLName.Local != "history" {
+			return child.ID
+		}
+	}
+	return ""
+}
+
+// isChoice reports whether an atomic state only has eventless transitions,
+// which SCXML takes immediately and fluo models as a choice or fork pseudostate
+func (n scxmlNode) isChoice() bool {
+	transitions := n.transitions()
+	if n.XMLName.Local != "state" || len(transitions) == 0 {
+		return false
+	}
+	for _, child := range n.Children {
+		if child.isStateElement() {
+			return false
+		}
+	}
+	for _, transition := range transitions {
+		if transition.Event != "" || transition.Target == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// scxmlPendingTransitions are the transitions of a state, resolved once every state is declared
+type scxmlPendingTransitions struct {
+	source string
+	node   scxmlNode
+}
+
+// scxmlPendingInitial is an initial child to resolve once every state is declared
+type scxmlPendingInitial struct {
+	owner  string
+	target string
+	set    func(State)
+}
+
+// scxmlImporter converts an SCXML document into a machine definition
+type scxmlImporter struct {
+	mb          *machineBuilderImpl
+	guards      map[string]GuardFunc
+	ids         map[string]string
+	transitions []scxmlPendingTransitions
+	initials    []scxmlPendingInitial
+}
+
+// ImportSCXML parses a W3C SCXML document into a machine definition. Transition
+// conditions are resolved by name from guards, then from DefaultRegistry. Atomic states with only eventless
+// transitions become choice pseudostates (fork pseudostates for multiple targets);
+// other eventless transitions become completion transitions.
+func ImportSCXML(r io.Reader, guards map[string]GuardFunc) (MachineDefinition, error) {
+	var root scxmlNode
+	if err := xml.NewDecoder(r).Decode(&root); err != nil {
+		return nil, err
+	}
+	if root.XMLName.Local != "scxml" {
+		return nil, NewConfigurationError("scxml", fmt.Sprintf("unexpected root element <%s>", root.XMLName.Local))
+	}
+
+	mb, _ := NewMachine().(*machineBuilderImpl)
+	importer := &scxmlImporter{
+		mb:     mb,
+		guards: guards,
+		ids:    make(map[string]string),
+	}
+
+	for _, child := range root.Children {
+		if child.isStateElement() {
+			if err := importer.declare(child, "", nil); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if initial := root.initialTarget(); initial != "" {
+		importer.initials = append(importer.initials, scxmlPendingInitial{
+			owner:  "scxml",
+			target: initial,
+			set:    func(state State) { mb.initialState = state.ID() },
+		})
+	}
+
+	if err := importer.resolve(); err != nil {
+		return nil, err
+	}
+	definition, err := mb.BuildE()
+	if err != nil {
+		return nil, NewConfigurationError("scxml", err.Error())
+	}
+	return definition, nil
+}
+
+// stateID returns the fluo ID for an SCXML ID nested below parentPath
+func (imp *scxmlImporter) stateID(id, parentPath string) string {
+	if parentPath == "" || strings.HasPrefix(id, parentPath+".") {
+		return id
+	}
+	return parentPath + "." + id
+}
+
+// declare creates the state for an SCXML element and its descendants
+func (imp *scxmlImporter) declare(node scxmlNode, parentPath string, region *RegionImpl) error {
+	if node.ID == "" {
+		return NewConfigurationError("scxml", fmt.Sprintf("<%s> element without id", node.XMLName.Local))
+	}
+	if _, exists := imp.ids[node.ID]; exists {
+		return NewConfigurationError("scxml", fmt.Sprintf("duplicate state id '%s'", node.ID))
+	}
+
+	id := imp.stateID(node.ID, parentPath)
+	imp.ids[node.ID] = id
+
+	var state State
+	switch {
+	case node.XMLName.Local == "final":
+		state = NewFinalState(id)
+	case node.XMLName.Local == "history":
+		history := NewHistoryState(id, node.Type == "deep")
+		for _, transition := range node.transitions() {
+			if transition.Target != "" {
+				target := strings.Fields(transition.Target)[0]
+				imp.initials = append(imp.initials, scxmlPendingInitial{
+					owner:  node.ID,
+					target: target,
+					set:    func(s State) { history.SetHistoryDefault(s.ID()) },
+				})
+			}
+		}
+		state = history
+	case node.XMLName.Local == "parallel":
+		parallel := NewParallelState(id)
+		state = parallel
+		for _, child := range node.Children {
+			if !child.isStateElement() {
+				continue
+			}
+			if child.XMLName.Local != "state" || child.ID == "" {
+				return NewConfigurationError("scxml", fmt.Sprintf("parallel state '%s' may only contain compound <state> regions", node.ID))
+			}
+			if err := imp.declareRegion(child, parallel, id); err != nil {
+				return err
+			}
+		}
+	case node.isChoice():
+		transitions := node.transitions()
+		kind := Choice
+		if len(transitions) == 1 && len(strings.Fields(transitions[0].Target)) > 1 {
+			kind = Fork
+		}
+		state = NewPseudoState(id, kind)
+	case slices.ContainsFunc(node.Children, scxmlNode.isStateElement):
+		composite := NewCompositeState(id)
+		state = composite
+		for _, child := range node.Children {
+			if child.isStateElement() {
+				if err := imp.declare(child, id, nil); err != nil {
+					return err
+				}
+			}
+		}
+		if initial := node.initialTarget(); initial != "" {
+			imp.initials = append(imp.initials, scxmlPendingInitial{
+				owner:  node.ID,
+				target: initial,
+				set:    func(s State) { composite.WithInitialState(s) },
+			})
+		}
+	default:
+		state = NewAtomicState(id)
+	}
+
+	imp.mb.states[id] = state
+	if region != nil {
+		region.AddState(state)
+	}
+	imp.transitions = append(imp.transitions, scxmlPendingTransitions{source: id, node: node})
+	return nil
+}
+
+// declareRegion creates a parallel region from a compound SCXML state
+func (imp *scxmlImporter) declareRegion(node scxmlNode, parallel *ParallelStateImpl, parallelID string) error {
+	regionID := strings.TrimPrefix(node.ID, parallelID+".")
+	if _, exists := imp.ids[node.ID]; exists {
+		return NewConfigurationError("scxml", fmt.Sprintf("duplicate state id '%s'", node.ID))
+	}
+	imp.ids[node.ID] = parallelID + "." + regionID
+
+	region := NewRegion(regionID, parallel)
+	parallel.AddRegion(region)
+
+	regionPath := parallelID + "." + regionID
+	for _, child := range node.Children {
+		if child.isStateElement() {
+			if err := imp.declare(child, regionPath, region); err != nil {
+				return err
+			}
+		}
+	}
+
+	if initial := node.initialTarget(); initial != "" {
+		imp.initials = append(imp.initials, scxmlPendingInitial{
+			owner:  node.ID,
+			target: initial,
+			set:    func(s State) { region.WithInitialState(s) },
+		})
+	}
+	return nil
+}
+
+// resolve wires initial states and transitions once every state is declared
+func (imp *scxmlImporter) resolve() error {
+	for _, initial := range imp.initials {
+		targetID, ok := imp.ids[initial.target]
+		if !ok {
+			return NewConfigurationError("scxml", fmt.Sprintf("initial target '%s' of '%s' does not exist", initial.target, initial.owner))
+		}
+		initial.set(imp.mb.states[targetID])
+	}
+
+	for _, pending := range imp.transitions {
+		if pending.node.XMLName.Local == "history" {
+			continue
+		}
+		if pseudo, ok := imp.mb.states[pending.source].(*PseudoStateImpl); ok {
+			if err := imp.resolvePseudoState(pseudo, pending.node); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, transition := range pending.node.transitions() {
+			if err := imp.resolveTransition(pending.source, transition); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// resolveTargets maps space-separated SCXML target IDs to fluo IDs
+func (imp *scxmlImporter) resolveTargets(target string) ([]string, error) {
+	var targets []string
+	for _, id := range strings.Fields(target) {
+		targetID, ok := imp.ids[id]
+		if !ok {
+			return nil, NewConfigurationError("scxml", fmt.Sprintf("transition target '%s' does not exist", id))
+		}
+		targets = append(targets, targetID)
+	}
+	return targets, nil
+}
+
+// resolveGuard looks up a named transition condition
+func (imp *scxmlImporter) resolveGuard(cond string) (GuardFunc, error) {
+	if cond == "" {
+		return nil, nil
+	}
+	guard, ok := imp.guards[cond]
+	if !ok {
+		guard, ok = DefaultRegistry.Guard(cond)
+	}
+	if !ok {
+		return nil, NewConfigurationError("scxml", fmt.Sprintf("unknown guard condition '%s'", cond))
+	}
+	return guard, nil
+}
+
+// resolvePseudoState configures the branches of an imported choice or fork
+func (imp *scxmlImporter) resolvePseudoState(pseudo *PseudoStateImpl, node scxmlNode) error {
+	for _, transition := range node.transitions() {
+		targets, err := imp.resolveTargets(transition.Target)
+		if err != nil {
+			return err
+		}
+		if pseudo.Kind() == Fork {
+			pseudo.SetForkTargets(targets)
+			continue
+		}
+
+		guard, err := imp.resolveGuard(transition.Cond)
+		if err != nil {
+			return err
+		}
+		if guard == nil {
+			pseudo.SetDefaultTarget(targets[0])
+		} else {
+			pseudo.addChoiceCondition(ChoiceCondition{Guard: guard, GuardName: transition.Cond, Target: targets[0]})
+		}
+	}
+	return nil
+}
+
+// resolveTransition adds the fluo transitions for an SCXML transition
+func (imp *scxmlImporter) resolveTransition(source string, node scxmlNode) error {
+	guard, err := imp.resolveGuard(node.Cond)
+	if err != nil {
+		return err
+	}
+
+	targets, err := imp.resolveTargets(node.Target)
+	if err != nil {
+		return err
+	}
+	if len(targets) > 1 {
+		return NewConfigurationError("scxml", fmt.Sprintf("transition from '%s' has multiple targets", source))
+	}
+
+	events := strings.Fields(node.Event)
+	if len(events) == 0 {
+		events = []string{"__completion_" + source}
+	}
+
+	for _, event := range events {
+		transition := Transition{
+			SourceState: source,
+			EventName:   event,
+			Guard:       guard,
+			GuardName:   node.Cond,
+		}
+		if len(targets) == 0 {
+			transition.TargetState = source
+			transition.Internal = true
+		} else {
+			transition.TargetState = targets[0]
+		}
+		if after, ok := parseTimedEventName(source, event); ok {
+			transition.After = after
+		}
+		imp.mb.addTransition(transition)
+	}
+	return nil
+}
+
+// parseTimedEventName recovers the duration of a timed transition event name
+func parseTimedEventName(stateID, eventName string) (time.Duration, bool) {
+	prefix := timedEventPrefix + stateID + "_"
+	if !strings.HasPrefix(eventName, prefix) {
+		return 0, false
+	}
+	after, err := time.ParseDuration(eventName[len(prefix):])
+	if err != nil || after <= 0 {
+		return 0, false
+	}
+	return after, true
+}
+
+// ExportSCXML renders a machine definition as a W3C SCXML document. Named
+// guards are exported as the cond of their transitions, and anonymous guards
+// with an opaque cond="guard" since they are Go functions; join pseudostates
+// have no SCXML equivalent and export as states with an eventless transition
+// to their target.
+func ExportSCXML(def MachineDefinition) ([]byte, error) {
+	model := newDiagramModel(def)
+
+	root := scxmlNode{
+		XMLName: xml.Name{Local: "scxml"},
+		Xmlns:   scxmlNamespace,
+		Version: "1.0",
+		Initial: def.GetInitialState(),
+	}
+	for _, node := range model.roots {
+		root.Children = append(root.Children, model.scxmlElement(node))
+	}
+
+	out, err := xml.MarshalIndent(root, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(out, '\n')...), nil
+}
+
+// scxmlElement renders a state, its transitions and its children
+func (model *diagramModel) scxmlElement(node *diagramNode) scxmlNode {
+	element := scxmlNode{XMLName: xml.Name{Local: "state"}, ID: node.id}
+
+	if pseudo, ok := node.state.(*PseudoStateImpl); ok {
+		return scxmlPseudoState(pseudo, element)
+	}
+
+	if node.state.IsFinal() {
+		element.XMLName.Local = "final"
+	}
+
+	if len(node.regions) > 0 {
+		element.XMLName.Local = "parallel"
+		for _, region := range node.regions {
+			regionElement := scxmlNode{XMLName: xml.Name{Local: "state"}, ID: region.id}
+			if initial := region.region.InitialState(); initial != nil {
+				regionElement.Initial = initial.ID()
+			}
+			for _, child := range region.children {
+				regionElement.Children = append(regionElement.Children, model.scxmlElement(child))
+			}
+			element.Children = append(element.Children, regionElement)
+		}
+	}
+
+	if composite, ok := node.state.(CompositeState); ok && composite.InitialState() != nil && len(node.children) > 0 {
+		element.Initial = composite.InitialState().ID()
+	}
+
+	var transitions []scxmlNode
+	for _, transition := range model.transitions {
+		if transition.SourceState == node.id {
+			transitions = append(transitions, scxmlTransition(transition))
+		}
+	}
+	element.Children = append(transitions, element.Children...)
+
+	for _, child := range node.children {
+		element.Children = append(element.Children, model.scxmlElement(child))
+	}
+	return element
+}
+
+// scxmlTransition renders a transition
+func scxmlTransition(transition Transition) scxmlNode {
+	element := scxmlNode{XMLName: xml.Name{Local: "transition"}}
+	if !strings.HasPrefix(transition.EventName, "__completion") {
+		element.Event = transition.EventName
+	}
+	if transition.Guard != nil {
+		element.Cond = scxmlCond(transition.GuardName)
+	}
+	if transition.Internal {
+		element.Type = "internal"
+	} else {
+		element.Target = transition.TargetState
+	}
+	return element
+}
+
+// scxmlPseudoState renders a pseudostate as the closest SCXML construct
+func scxmlPseudoState(pseudo *PseudoStateImpl, element scxmlNode) scxmlNode {
+	eventless := func(target, cond string) scxmlNode {
+		return scxmlNode{XMLName: xml.Name{Local: "transition"}, Target: target, Cond: cond}
+	}
+
+	switch pseudo.Kind() {
+	case History, DeepHistory:
+		element.XMLName.Local = "history"
+		element.Type = "shallow"
+		if pseudo.Kind() == DeepHistory {
+			element.Type = "deep"
+		}
+		if pseudo.historyDefault != "" {
+			element.Children = append(element.Children, eventless(pseudo.historyDefault, ""))
+		}
+	case Fork:
+		element.Children = append(element.Children, eventless(strings.Join(pseudo.forkTargets, " "), ""))
+	case Join:
+		if pseudo.joinTarget != "" {
+			element.Children = append(element.Children, eventless(pseudo.joinTarget, ""))
+		}
+	case Terminate:
+		element.XMLName.Local = "final"
+	default:
+		for _, condition := range pseudo.choiceConditions {
+			if condition.TargetFunc != nil {
+				continue
+			}
+			cond := ""
+			if condition.Guard != nil {
+				cond = scxmlCond(condition.GuardName)
+			}
+			element.Children = append(element.Children, eventless(condition.Target, cond))
+		}
+		if pseudo.defaultTarget != "" {
+			element.Children = append(element.Children, eventless(pseudo.defaultTarget, ""))
+		}
+	}
+	return element
+}
+
+// scxmlCond returns the cond of a guard: its name, or an opaque "guard" for
+// an anonymous guard
+func scxmlCond(guardName string) string {
+	if guardName == "" {
+		return "guard"
+	}
+	return guardName
+}
@@ -0,0 +1,217 @@
+package fluo
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSCXMLDocument = `<?xml version="1.0" encoding="UTF-8"?>
+<scxml xmlns="http://www.w3.org/2005/07/scxml" version="1.0" initial="offline">
+  <state id="offline">
+    <transition event="connect" target="online"/>
+  </state>
+  <state id="online" initial="idle">
+    <transition event="disconnect" target="offline"/>
+    <history id="online_history" type="deep">
+      <transition target="idle"/>
+    </history>
+    <state id="idle">
+      <transition event="process" target="processing"/>
+    </state>
+    <state id="processing">
+      <transition event="done" target="route"/>
+      <transition event="ping"/>
+    </state>
+    <state id="route">
+      <transition cond="isLarge" target="devices"/>
+      <transition target="idle"/>
+    </state>
+  </state>
+  <parallel id="devices">
+    <state id="motor">
+      <state id="stopped">
+        <transition event="start_motor" target="running"/>
+      </state>
+      <state id="running"/>
+    </state>
+    <state id="lights">
+      <initial><transition target="off"/></initial>
+      <state id="off">
+        <transition event="turn_on" target="on"/>
+      </state>
+      <state id="on"/>
+    </state>
+    <transition event="shutdown" target="finished"/>
+  </parallel>
+  <final id="finished"/>
+</scxml>`
+
+func TestImportSCXML(t *testing.T) {
+	large := false
+	definition, err := ImportSCXML(strings.NewReader(testSCXMLDocument), map[string]GuardFunc{
+		"isLarge": func(ctx Context) bool { return large },
+	})
+	if err != nil {
+		t.Fatalf("Expected no error importing SCXML, got: %v", err)
+	}
+
+	states := definition.GetStates()
+	for _, id := range []string{"offline", "online", "online.idle", "online.processing", "online.route", "online.online_history", "devices", "devices.motor.stopped", "devices.lights.on", "finished"} {
+		if _, exists := states[id]; !exists {
+			t.Errorf("Expected state %s to be imported", id)
+		}
+	}
+	if !states["finished"].IsFinal() {
+		t.Error("Expected finished to be a final state")
+	}
+	if pseudo, ok := states["online.route"].(PseudoState); !ok || pseudo.Kind() != Choice {
+		t.Error("Expected route to be imported as a choice pseudostate")
+	}
+	if pseudo, ok := states["online.online_history"].(PseudoState); !ok || pseudo.Kind() != DeepHistory {
+		t.Error("Expected online_history to be imported as a deep history pseudostate")
+	}
+
+	machine := definition.CreateInstance()
+	_ = machine.Start()
+	AssertState(t, machine, "offline")
+
+	_ = machine.HandleEvent("connect", nil)
+	AssertState(t, machine, "online.idle")
+
+	_ = machine.HandleEvent("process", nil)
+	result := machine.HandleEvent("ping", nil)
+	AssertEventProcessed(t, result, true)
+	AssertState(t, machine, "online.processing")
+
+	_ = machine.HandleEvent("done", nil)
+	AssertState(t, machine, "online.idle")
+
+	large = true
+	_ = machine.HandleEvent("process", nil)
+	_ = machine.HandleEvent("done", nil)
+	if machine.RegionState("motor") != "devices.motor.stopped" || machine.RegionState("lights") != "devices.lights.off" {
+		t.Errorf("Expected regions to enter their initial states, got %s and %s", machine.RegionState("motor"), machine.RegionState("lights"))
+	}
+}
+
+func TestImportSCXML_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+	}{
+		{"unknown guard", `<scxml initial="a"><state id="a"><transition event="go" cond="missing" target="a"/></state></scxml>`},
+		{"unknown target", `<scxml initial="a"><state id="a"><transition event="go" target="b"/></state></scxml>`},
+		{"duplicate id", `<scxml initial="a"><state id="a"/><state id="a"/></scxml>`},
+		{"wrong root", `<machine/>`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ImportSCXML(strings.NewReader(tt.document), nil); err == nil {
+				t.Error("Expected error importing invalid SCXML")
+			}
+		})
+	}
+}
+
+func TestExportSCXML_RoundTrip(t *testing.T) {
+	builder := NewMachine()
+	builder.State("idle").Initial().
+		To("active").On("activate")
+	builder.State("idle").
+		After(2 * time.Second).To("timeout")
+	builder.State("timeout").
+		To("idle").On("retry")
+
+	parallelBuilder := builder.ParallelState("active")
+	motorRegion := parallelBuilder.Region("motor")
+	motorRegion.State("stopped").Initial().
+		To("running").On("start_motor")
+	motorRegion.State("running")
+
+	original := builder.Build()
+
+	data, err := ExportSCXML(original)
+	if err != nil {
+		t.Fatalf("Expected no error exporting SCXML, got: %v", err)
+	}
+	if !bytes.Contains(data, []byte(`<scxml xmlns="http://www.w3.org/2005/07/scxml" version="1.0" initial="idle">`)) {
+		t.Errorf("Expected SCXML root element, got:\n%s", data)
+	}
+	if !bytes.Contains(data, []byte(`<parallel id="active">`)) {
+		t.Errorf("Expected parallel element, got:\n%s", data)
+	}
+
+	imported, err := ImportSCXML(bytes.NewReader(data), nil)
+	if err != nil {
+		t.Fatalf("Expected no error re-importing SCXML, got: %v\n%s", err, data)
+	}
+
+	for id := range original.GetStates() {
+		if _, exists := imported.GetStates()[id]; !exists {
+			t.Errorf("Expected state %s to survive the round trip", id)
+		}
+	}
+
+	var timed bool
+	for _, transition := range imported.GetTransitions()["idle"] {
+		if transition.After == 2*time.Second && transition.TargetState == "timeout" {
+			timed = true
+		}
+	}
+	if !timed {
+		t.Error("Expected timed transition to survive the round trip")
+	}
+
+	machine := imported.CreateInstance()
+	_ = machine.Start()
+	_ = machine.HandleEvent("activate", nil)
+	_ = machine.HandleEvent("start_motor", nil)
+	if machine.RegionState("motor") != "active.motor.running" {
+		t.Errorf("Expected motor region to be running, got %s", machine.RegionState("motor"))
+	}
+}
+
+func TestExportSCXML_GuardNames(t *testing.T) {
+	isLarge := func(ctx Context) bool { return GetOr(ctx, "size", 0) > 10 }
+	builder := NewMachine()
+	builder.State("idle").Initial().
+		To("sizing").On("measure").
+		To("review").On("submit").WhenNamed("isReady", func(ctx Context) bool { return true }).
+		To("idle").On("poke").When(func(ctx Context) bool { return false })
+	builder.Choice("sizing").
+		WhenNamed("isLarge", isLarge).To("review").
+		Otherwise("idle")
+	builder.State("review")
+
+	data, err := ExportSCXML(builder.Build())
+	if err != nil {
+		t.Fatalf("Expected no error exporting SCXML, got: %v", err)
+	}
+	for _, want := range []string{
+		`<transition event="submit" cond="isReady" target="review">`,
+		`<transition event="poke" cond="guard" target="idle">`,
+		`<transition cond="isLarge" target="review">`,
+	} {
+		if !bytes.Contains(data, []byte(want)) {
+			t.Errorf("Expected %s, got:\n%s", want, data)
+		}
+	}
+
+	// Named guards resolve again on import
+	imported, err := ImportSCXML(bytes.NewReader(data), map[string]GuardFunc{
+		"isReady": func(ctx Context) bool { return true },
+		"isLarge": isLarge,
+		"guard":   func(ctx Context) bool { return false },
+	})
+	if err != nil {
+		t.Fatalf("Expected no error re-importing SCXML, got: %v\n%s", err, data)
+	}
+	machine := imported.CreateInstance()
+	machine.Context().Set("size", 20)
+	_ = machine.Start()
+	_ = machine.HandleEvent("measure", nil)
+	AssertState(t, machine, "review")
+}
@@ -0,0 +1,67 @@
+package fluo
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// SelfTest dry-runs instances of the machine along every path taken without
+// an external event: it starts an instance, then on a fresh instance enters
+// each state as a transition targeting it would, following choice, junction,
+// fork, history and connection point pseudostates and entering the initial
+// substates of composite states and regions. Guards fail and no actions,
+// timers, do-activities or submachines run, so choice and junction
+// pseudostates must declare an unguarded default branch. All problems found
+// are returned joined into a single error.
+func (smd *simpleMachineDefinition) SelfTest() error {
+	var errs []error
+	if err := smd.dryRunInstance().Start(); err != nil {
+		errs = append(errs, err)
+	}
+
+	for _, id := range slices.Sorted(maps.Keys(smd.states)) {
+		if pseudoState, ok := smd.states[id].(PseudoState); ok && pseudoState.Kind() == Join {
+			// Joins are reached from their source regions only
+			continue
+		}
+		if err := smd.dryRunInstance().dryRunEnter(id); err != nil {
+			errs = append(errs, NewConfigurationError(fmt.Sprintf("state '%s'", id), err.Error()))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// dryRunInstance creates an instance that runs no guards or actions
+func (smd *simpleMachineDefinition) dryRunInstance() *StateMachine {
+	sm := smd.CreateInstance().(*StateMachine)
+	sm.dryRun = true
+	sm.skipActions = true
+	return sm
+}
+
+// dryRunEnter enters a state on a dry-run instance the way the target of a
+// transition is entered, returning the error that entering it hits
+func (sm *StateMachine) dryRunEnter(stateID string) error {
+	sm.mutex.Lock()
+	defer sm.unlock()
+
+	target, err := sm.resolvePseudoStateTarget(stateID, nil)
+	if err != nil {
+		return err
+	}
+	if _, exists := sm.states[target]; !exists {
+		return fmt.Errorf("default path ends in unknown state '%s'", target)
+	}
+
+	sm.machineState = MachineStateStarted
+	sm.currentState = sm.executeCompositeStateEntry(target, nil)
+	if _, ok := sm.states[sm.currentState].(PseudoState); ok {
+		return fmt.Errorf("default path ends in pseudostate '%s'", sm.currentState)
+	}
+	sm.enterWithin("", sm.currentState, false)
+	sm.enterRegions("")
+	return nil
+}
@@ -0,0 +1,142 @@
+package fluo
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSelfTest_ValidMachines(t *testing.T) {
+	definitions := map[string]MachineDefinition{
+		"simple": NewMachine().
+			State("idle").Initial().To("done").On("go").
+			State("done").Final().
+			Build(),
+	}
+
+	choice := NewMachine()
+	choice.State("start").Initial().To("decide").On("go")
+	choice.Choice("decide").
+		When(func(ctx Context) bool { return false }).To("a").
+		Otherwise("b")
+	choice.State("a")
+	choice.State("b")
+	definitions["choice"] = choice.Build()
+
+	parallel := NewMachine()
+	parallel.State("inactive").Initial().To("active").On("activate")
+	region := parallel.ParallelState("active").Region("motor")
+	region.State("stopped").Initial()
+	region.State("running")
+	definitions["parallel"] = parallel.Build()
+
+	for name, definition := range definitions {
+		t.Run(name, func(t *testing.T) {
+			if err := definition.SelfTest(); err != nil {
+				t.Errorf("Expected self-test to pass, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestSelfTest_ChoiceWithoutDefault(t *testing.T) {
+	builder := NewMachine()
+	builder.State("start").Initial().To("decide").On("go")
+	builder.Choice("decide").
+		When(func(ctx Context) bool { return true }).To("a")
+	builder.State("a")
+
+	err := builder.Build().SelfTest()
+	if err == nil || !strings.Contains(err.Error(), "'decide'") || !strings.Contains(err.Error(), "no valid transition") {
+		t.Errorf("Expected missing default branch to be reported, got: %v", err)
+	}
+}
+
+func TestSelfTest_UnknownDefaultTarget(t *testing.T) {
+	builder := NewMachine()
+	builder.State("start").Initial().To("decide").On("go")
+	builder.Choice("decide").Otherwise("missing")
+
+	err := builder.Build().SelfTest()
+	if err == nil || !strings.Contains(err.Error(), "unknown state 'missing'") {
+		t.Errorf("Expected unknown target to be reported, got: %v", err)
+	}
+}
+
+func TestSelfTest_HistoryWithoutDefault(t *testing.T) {
+	builder := NewMachine()
+	builder.State("start").Initial().To("resume").On("back")
+	builder.History("resume")
+
+	err := builder.Build().SelfTest()
+	if err == nil || !strings.Contains(err.Error(), "'resume'") {
+		t.Errorf("Expected history without default to be reported, got: %v", err)
+	}
+}
+
+func TestSelfTest_EventlessCycle(t *testing.T) {
+	builder := NewMachine()
+	builder.State("start").Initial().To("first").On("go")
+	builder.Junction("first").To("second")
+	builder.Junction("second").To("first")
+
+	definition := builder.Build()
+	err := definition.SelfTest()
+	if err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("Expected eventless cycle to be reported, got: %v", err)
+	}
+
+	// The running machine rejects the cycle instead of recursing forever
+	machine := definition.CreateInstance()
+	_ = machine.Start()
+	result := machine.HandleEvent("go", nil)
+	if result.Error == nil || !strings.Contains(result.Error.Error(), "cycle") {
+		t.Errorf("Expected the event to hit the cycle, got %+v", result)
+	}
+	AssertState(t, machine, "start")
+}
+
+func TestSelfTest_RunsNoActions(t *testing.T) {
+	var mutex sync.Mutex
+	var ran []string
+	record := func(name string) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		ran = append(ran, name)
+	}
+	action := func(name string) ActionFunc {
+		return func(ctx Context) error {
+			record(name)
+			return nil
+		}
+	}
+
+	builder := NewMachine()
+	builder.State("start").Initial().OnEntry(action("enter start")).To("split").On("go")
+	builder.Choice("split").
+		When(func(ctx Context) bool {
+			record("guard")
+			return true
+		}).To("start").
+		Otherwise("active")
+	active := builder.ParallelState("active").OnEntry(action("enter active"))
+	active.Region("motor").State("stopped").Initial().OnEntry(action("enter stopped"))
+	active.Region("lights").State("off").Initial().
+		OnActivity(func(ctx Context, stop <-chan struct{}) error {
+			record("activity")
+			return nil
+		}).
+		After(time.Millisecond).To("on")
+	active.Region("lights").State("on").OnEntry(action("enter on"))
+
+	if err := builder.Build().SelfTest(); err != nil {
+		t.Fatalf("Expected self-test to pass, got: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(ran) != 0 {
+		t.Errorf("Expected no guards, actions, timers or activities to run, got %v", ran)
+	}
+}
@@ -0,0 +1,340 @@
+package fluo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+)
+
+// Snapshot is the persisted state of a machine instance
+type Snapshot struct {
+	InstanceID   string            `json:"instanceId,omitempty"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+	CurrentState string            `json:"currentState"`
+	InitialState string            `json:"initialState"`
+	MachineState MachineState      `json:"machineState"`
+	RegionStates map[string]string `json:"regionStates,omitempty"`
+	ActiveStates []string          `json:"activeStates,omitempty"`
+	History      map[string]string `json:"history,omitempty"`
+	// Context holds context values encoded directly by the codec
+	Context map[string]any `json:"contextData"`
+	// EncodedContext holds context values encoded by a per-key ValueMarshaler
+	EncodedContext map[string][]byte `json:"encodedContextData,omitempty"`
+	// TypedContext holds context values of types registered in the type registry
+	TypedContext map[string]TypedValue `json:"typedContextData,omitempty"`
+	// ContextScopes maps context keys set with SetScoped to their owning state
+	ContextScopes map[string]string `json:"contextScopes,omitempty"`
+	// DefinitionVersion is the version label set with WithDefinitionVersion
+	DefinitionVersion string `json:"definitionVersion,omitempty"`
+	// DefinitionHash is the structural hash of the definition, see DefinitionHash
+	DefinitionHash string `json:"definitionHash,omitempty"`
+}
+
+// ValueMarshaler encodes context values that the snapshot codec cannot represent faithfully
+type ValueMarshaler interface {
+	MarshalValue(value any) ([]byte, error)
+	UnmarshalValue(data []byte) (any, error)
+}
+
+// ValueMarshalerFuncs adapts a pair of functions to the ValueMarshaler interface
+type ValueMarshalerFuncs struct {
+	Marshal   func(value any) ([]byte, error)
+	Unmarshal func(data []byte) (any, error)
+}
+
+// MarshalValue calls the Marshal function
+func (f ValueMarshalerFuncs) MarshalValue(value any) ([]byte, error) {
+	return f.Marshal(value)
+}
+
+// UnmarshalValue calls the Unmarshal function
+func (f ValueMarshalerFuncs) UnmarshalValue(data []byte) (any, error) {
+	return f.Unmarshal(data)
+}
+
+// TimeMarshaler preserves time.Time values with full precision and location
+type TimeMarshaler struct{}
+
+// MarshalValue encodes a time.Time value
+func (TimeMarshaler) MarshalValue(value any) ([]byte, error) {
+	t, ok := value.(time.Time)
+	if !ok {
+		return nil, fmt.Errorf("expected time.Time, got %T", value)
+	}
+	return t.MarshalBinary()
+}
+
+// UnmarshalValue decodes a time.Time value
+func (TimeMarshaler) UnmarshalValue(data []byte) (any, error) {
+	var t time.Time
+	if err := t.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// WithDefinitionVersion records a version label for the definition in
+// snapshots. Restoring a snapshot with a different label fails unless a
+// migration is set with WithSnapshotMigration.
+func WithDefinitionVersion(version string) MachineOption {
+	return func(sm *StateMachine) {
+		sm.definitionVersion = version
+	}
+}
+
+// WithSnapshotMigration sets the migration adapting snapshots taken from an
+// incompatible definition, instead of refusing to restore them. It works on a
+// copy of the snapshot.
+func WithSnapshotMigration(migrate MigrationFunc) MachineOption {
+	return func(sm *StateMachine) {
+		sm.snapshotMigration = migrate
+	}
+}
+
+// DefinitionHash returns a structural hash of a definition: its initial state
+// and the IDs, kinds and nesting of its states. Snapshots stay compatible
+// across changes to transitions, guards and actions, but not across added,
+// removed, renamed or moved states.
+func DefinitionHash(def MachineDefinition) string {
+	description := Describe(def)
+	hash := sha256.New()
+	fmt.Fprintf(hash, "initial %s\n", description.InitialState)
+	for _, state := range description.States {
+		fmt.Fprintf(hash, "state %s %s %s %t\n", state.ID, state.Kind, state.Parent, state.Initial)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// definitionHash returns the structural hash of the instance's definition, or
+// "" when the instance was not created from a definition
+func (sm *StateMachine) definitionHash() string {
+	if sm.definition == nil || sm.definition.hash == nil {
+		return ""
+	}
+	return sm.definition.hash()
+}
+
+// compatibleSnapshot checks that a snapshot was taken from a compatible
+// definition, running the snapshot migration when it was not. Snapshots
+// without a version label or hash are accepted as they are.
+func (sm *StateMachine) compatibleSnapshot(snapshot *Snapshot) (*Snapshot, error) {
+	var reason string
+	hash := sm.definitionHash()
+	switch {
+	case snapshot.DefinitionVersion != "" && sm.definitionVersion != "" && snapshot.DefinitionVersion != sm.definitionVersion:
+		reason = fmt.Sprintf("snapshot of definition version '%s' does not match version '%s'", snapshot.DefinitionVersion, sm.definitionVersion)
+	case snapshot.DefinitionHash != "" && hash != "" && snapshot.DefinitionHash != hash:
+		reason = fmt.Sprintf("snapshot of definition %.12s does not match definition %.12s", snapshot.DefinitionHash, hash)
+	default:
+		return snapshot, nil
+	}
+
+	if sm.snapshotMigration == nil {
+		return nil, NewIncompatibleSnapshotError(reason)
+	}
+	migrated := snapshot.clone()
+	if err := sm.snapshotMigration(migrated); err != nil {
+		return nil, fmt.Errorf("snapshot migration failed: %w", err)
+	}
+	return migrated, nil
+}
+
+// clone copies a snapshot and its maps and slices, sharing context values
+func (snapshot *Snapshot) clone() *Snapshot {
+	copied := *snapshot
+	copied.Metadata = maps.Clone(snapshot.Metadata)
+	copied.RegionStates = maps.Clone(snapshot.RegionStates)
+	copied.ActiveStates = slices.Clone(snapshot.ActiveStates)
+	copied.History = maps.Clone(snapshot.History)
+	copied.Context = maps.Clone(snapshot.Context)
+	copied.EncodedContext = maps.Clone(snapshot.EncodedContext)
+	copied.TypedContext = maps.Clone(snapshot.TypedContext)
+	copied.ContextScopes = maps.Clone(snapshot.ContextScopes)
+	return &copied
+}
+
+// WithValueMarshaler sets a custom marshaler for a context key in snapshots
+func WithValueMarshaler(key string, marshaler ValueMarshaler) MachineOption {
+	return func(sm *StateMachine) {
+		if sm.valueMarshalers == nil {
+			sm.valueMarshalers = make(map[string]ValueMarshaler)
+		}
+		sm.valueMarshalers[key] = marshaler
+	}
+}
+
+// Snapshot captures the machine's state and context
+func (sm *StateMachine) Snapshot() (*Snapshot, error) {
+	sm.mutex.RLock()
+	defer sm.mutex.RUnlock()
+
+	if err := sm.checkContextSize(); err != nil {
+		return nil, err
+	}
+
+	cfg := sm.captureConfiguration()
+	snapshot := &Snapshot{
+		InstanceID:        sm.id,
+		Metadata:          maps.Clone(sm.metadata),
+		CurrentState:      sm.currentState,
+		InitialState:      sm.initialState,
+		MachineState:      sm.machineState,
+		RegionStates:      cfg.RegionStates,
+		ActiveStates:      cfg.ActiveStates,
+		History:           cfg.History,
+		Context:           make(map[string]any),
+		DefinitionVersion: sm.definitionVersion,
+		DefinitionHash:    sm.definitionHash(),
+	}
+
+	if smCtx, ok := sm.context.(*StateMachineContext); ok {
+		if owners := smCtx.scopeOwners(); len(owners) > 0 {
+			snapshot.ContextScopes = owners
+		}
+	}
+
+	contextData := sm.context.GetAll()
+	keys := slices.Sorted(maps.Keys(contextData))
+	for _, key := range keys {
+		value := contextData[key]
+		marshaler, ok := sm.valueMarshalers[key]
+		if !ok {
+			typed, registered, err := sm.types().encode(value)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal context key '%s': %w", key, err)
+			}
+			if !registered {
+				snapshot.Context[key] = value
+				continue
+			}
+			if snapshot.TypedContext == nil {
+				snapshot.TypedContext = make(map[string]TypedValue)
+			}
+			snapshot.TypedContext[key] = typed
+			continue
+		}
+
+		data, err := marshaler.MarshalValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal context key '%s': %w", key, err)
+		}
+		if snapshot.EncodedContext == nil {
+			snapshot.EncodedContext = make(map[string][]byte)
+		}
+		snapshot.EncodedContext[key] = data
+	}
+
+	return snapshot, nil
+}
+
+// Restore applies a snapshot to the machine. The snapshot is validated against the
+// machine definition; no entry or exit actions are executed. Restoring a
+// started snapshot starts the event loop of a machine in event-loop mode.
+func (sm *StateMachine) Restore(snapshot *Snapshot) error {
+	if snapshot == nil {
+		return NewConfigurationError("snapshot", "snapshot is nil")
+	}
+	snapshot, err := sm.compatibleSnapshot(snapshot)
+	if err != nil {
+		return err
+	}
+
+	values := make(map[string]any, len(snapshot.Context)+len(snapshot.EncodedContext))
+	maps.Copy(values, snapshot.Context)
+	for key, data := range snapshot.EncodedContext {
+		marshaler, ok := sm.valueMarshalers[key]
+		if !ok {
+			return NewConfigurationError("snapshot", fmt.Sprintf("no value marshaler registered for context key '%s'", key))
+		}
+		value, err := marshaler.UnmarshalValue(data)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal context key '%s': %w", key, err)
+		}
+		values[key] = value
+	}
+	for key, typed := range snapshot.TypedContext {
+		value, err := sm.types().decode(typed)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal context key '%s': %w", key, err)
+		}
+		values[key] = value
+	}
+	if err := sm.convertVars(values); err != nil {
+		return err
+	}
+
+	sm.mutex.Lock()
+	defer sm.unlock()
+
+	previousMachineState := sm.machineState
+	sm.machineState = snapshot.MachineState
+
+	if snapshot.CurrentState != "" {
+		err := sm.setConfiguration(ActiveConfiguration{
+			CurrentState: snapshot.CurrentState,
+			RegionStates: snapshot.RegionStates,
+			ActiveStates: snapshot.ActiveStates,
+			History:      snapshot.History,
+		})
+		if err != nil {
+			sm.machineState = previousMachineState
+			return err
+		}
+	}
+
+	if snapshot.InitialState != "" {
+		sm.initialState = snapshot.InitialState
+	}
+	sm.labelMutex.Lock()
+	if snapshot.InstanceID != "" {
+		sm.id = snapshot.InstanceID
+	}
+	if snapshot.Metadata != nil {
+		sm.metadata = maps.Clone(snapshot.Metadata)
+	}
+	sm.labelMutex.Unlock()
+
+	for key, value := range values {
+		sm.context.Set(key, value)
+	}
+	if smCtx, ok := sm.context.(*StateMachineContext); ok {
+		smCtx.setScopeOwners(snapshot.ContextScopes)
+	}
+
+	// A restored started machine processes its events on the event loop, as
+	// after Start
+	if sm.machineState == MachineStateStarted && sm.eventLoop != nil {
+		sm.eventLoop.start(sm)
+	}
+
+	return nil
+}
+
+// types returns the machine's type registry
+func (sm *StateMachine) types() *TypeRegistry {
+	if sm.typeRegistry != nil {
+		return sm.typeRegistry
+	}
+	return DefaultTypeRegistry
+}
+
+// MarshalSnapshot captures the machine's state and encodes it with the given codec
+func (sm *StateMachine) MarshalSnapshot(codec Codec) ([]byte, error) {
+	snapshot, err := sm.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	return codec.Encode(snapshot)
+}
+
+// UnmarshalSnapshot decodes a snapshot with the given codec and restores it
+func (sm *StateMachine) UnmarshalSnapshot(codec Codec, data []byte) error {
+	snapshot, err := codec.Decode(data)
+	if err != nil {
+		return err
+	}
+	return sm.Restore(snapshot)
+}
@@ -0,0 +1,235 @@
+package fluo
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSnapshot_CodecsRoundTrip(t *testing.T) {
+	codecs := []Codec{JSONCodec{}, GobCodec{}, MsgpackCodec{}, ProtobufCodec{}}
+
+	for _, codec := range codecs {
+		t.Run(codec.Name(), func(t *testing.T) {
+			machine := CreateParallelMachine()
+			machine.Context().Set("order_id", "A-42")
+			machine.Context().Set("approved", true)
+			_ = machine.Start()
+			_ = machine.HandleEvent("activate", nil)
+			_ = machine.HandleEvent("start_motor", nil)
+
+			data, err := machine.MarshalSnapshot(codec)
+			if err != nil {
+				t.Fatalf("Expected no error encoding snapshot, got: %v", err)
+			}
+
+			restored := CreateParallelMachine()
+			if err := restored.UnmarshalSnapshot(codec, data); err != nil {
+				t.Fatalf("Expected no error restoring snapshot, got: %v", err)
+			}
+
+			AssertState(t, restored, machine.CurrentState())
+			if restored.RegionState("motor") != "active.motor.running" {
+				t.Errorf("Expected motor region to be restored to running, got %s", restored.RegionState("motor"))
+			}
+			if value, ok := restored.Context().Get("order_id"); !ok || value != "A-42" {
+				t.Errorf("Expected order_id to be restored, got %v", value)
+			}
+			if value, ok := restored.Context().Get("approved"); !ok || value != true {
+				t.Errorf("Expected approved to be restored, got %v", value)
+			}
+		})
+	}
+}
+
+func TestProtobufCodec_RoundTripsEveryField(t *testing.T) {
+	snapshot := &Snapshot{
+		InstanceID:        "order-7",
+		Metadata:          map[string]string{"tenant": "acme"},
+		CurrentState:      "active",
+		InitialState:      "idle",
+		MachineState:      MachineStateStarted,
+		RegionStates:      map[string]string{"active.motor": "active.motor.running"},
+		ActiveStates:      []string{"active", "active.motor.running"},
+		History:           map[string]string{"active": "active.motor.running"},
+		Context:           map[string]any{"total": 42.5, "tags": []any{"a", "b"}, "note": nil},
+		EncodedContext:    map[string][]byte{"deadline": []byte("2026-10-18")},
+		TypedContext:      map[string]TypedValue{"order": {Type: "order", Data: []byte(`{"id":"A-42"}`)}},
+		ContextScopes:     map[string]string{"step": "active.motor"},
+		DefinitionVersion: "v2",
+		DefinitionHash:    "abc123",
+	}
+
+	data, err := ProtobufCodec{}.Encode(snapshot)
+	if err != nil {
+		t.Fatalf("Expected no error encoding snapshot, got: %v", err)
+	}
+	decoded, err := ProtobufCodec{}.Decode(data)
+	if err != nil {
+		t.Fatalf("Expected no error decoding snapshot, got: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, snapshot) {
+		t.Errorf("Expected %+v, got %+v", snapshot, decoded)
+	}
+
+	if _, err := (ProtobufCodec{}).Decode(data[:len(data)-3]); err == nil {
+		t.Error("Expected a truncated snapshot to be rejected")
+	}
+}
+
+func TestSnapshot_ExcludesTransientKeys(t *testing.T) {
+	machine := CreateSimpleMachine()
+	machine.Context().Set("order_id", "A-42")
+	machine.Context().SetTransient("connection", make(chan struct{}))
+	_ = machine.Start()
+
+	snapshot, err := machine.Snapshot()
+	if err != nil {
+		t.Fatalf("Expected no error taking snapshot, got: %v", err)
+	}
+	if _, ok := snapshot.Context["connection"]; ok {
+		t.Error("Expected transient key to be excluded from snapshot")
+	}
+
+	data, err := machine.MarshalSnapshot(DefaultCodec)
+	if err != nil {
+		t.Fatalf("Expected transient non-serializable value to be skipped, got: %v", err)
+	}
+
+	restored := CreateSimpleMachine()
+	if err := restored.UnmarshalSnapshot(DefaultCodec, data); err != nil {
+		t.Fatalf("Expected no error restoring snapshot, got: %v", err)
+	}
+	if _, ok := restored.Context().Get("connection"); ok {
+		t.Error("Expected transient key not to be restored")
+	}
+	if value, ok := restored.Context().Get("order_id"); !ok || value != "A-42" {
+		t.Errorf("Expected order_id to be restored, got %v", value)
+	}
+}
+
+func TestSnapshot_ValueMarshaler(t *testing.T) {
+	definition := NewMachine().
+		State("idle").Initial().
+		To("running").On("start").
+		State("running").
+		Build()
+
+	deadline := time.Date(2024, 3, 1, 12, 30, 0, 123456789, time.FixedZone("UTC+7", 7*3600))
+
+	machine := definition.CreateInstance(WithValueMarshaler("deadline", TimeMarshaler{}))
+	machine.Context().Set("deadline", deadline)
+	_ = machine.Start()
+
+	data, err := machine.MarshalSnapshot(JSONCodec{})
+	if err != nil {
+		t.Fatalf("Expected no error encoding snapshot, got: %v", err)
+	}
+
+	restored := definition.CreateInstance(WithValueMarshaler("deadline", TimeMarshaler{}))
+	if err := restored.UnmarshalSnapshot(JSONCodec{}, data); err != nil {
+		t.Fatalf("Expected no error restoring snapshot, got: %v", err)
+	}
+
+	value, _ := restored.Context().Get("deadline")
+	restoredDeadline, ok := value.(time.Time)
+	if !ok {
+		t.Fatalf("Expected time.Time, got %T", value)
+	}
+	if !restoredDeadline.Equal(deadline) || restoredDeadline.Nanosecond() != deadline.Nanosecond() {
+		t.Errorf("Expected deadline %v, got %v", deadline, restoredDeadline)
+	}
+
+	withoutMarshaler := definition.CreateInstance()
+	if err := withoutMarshaler.UnmarshalSnapshot(JSONCodec{}, data); !IsConfigurationError(err) {
+		t.Errorf("Expected configuration error without registered marshaler, got: %v", err)
+	}
+}
+
+func TestSnapshot_RestoreRejectsUnknownState(t *testing.T) {
+	machine := CreateSimpleMachine()
+
+	err := machine.Restore(&Snapshot{CurrentState: "missing", MachineState: MachineStateStarted})
+	if !IsStateError(err) {
+		t.Fatalf("Expected state error, got: %v", err)
+	}
+	if result := machine.HandleEvent("start", nil); result.Success() {
+		t.Error("Expected machine to remain stopped after a failed restore")
+	}
+}
+
+// buildSnapshotDefinition toggles between idle and a state with the given ID
+func buildSnapshotDefinition(running string) MachineDefinition {
+	builder := NewMachine()
+	builder.State("idle").Initial().
+		To(running).On("start")
+	builder.State(running).
+		To("idle").On("stop")
+	return builder.Build()
+}
+
+func TestSnapshot_RecordsDefinition(t *testing.T) {
+	definition := buildSnapshotDefinition("running")
+	machine := definition.CreateInstance(WithDefinitionVersion("v1"))
+	_ = machine.Start()
+
+	for _, codec := range []Codec{JSONCodec{}, GobCodec{}, MsgpackCodec{}, ProtobufCodec{}} {
+		data, _ := machine.MarshalSnapshot(codec)
+		decoded, err := codec.Decode(data)
+		if err != nil {
+			t.Fatalf("%s: Expected no error decoding snapshot, got: %v", codec.Name(), err)
+		}
+		if decoded.DefinitionVersion != "v1" || decoded.DefinitionHash != DefinitionHash(definition) {
+			t.Errorf("%s: Expected the definition version and hash, got %q %q", codec.Name(), decoded.DefinitionVersion, decoded.DefinitionHash)
+		}
+	}
+
+	if DefinitionHash(buildSnapshotDefinition("running")) != DefinitionHash(definition) {
+		t.Error("Expected equal definitions to hash equally")
+	}
+	if DefinitionHash(buildSnapshotDefinition("active")) == DefinitionHash(definition) {
+		t.Error("Expected a renamed state to change the hash")
+	}
+}
+
+func TestSnapshot_RestoreRefusesIncompatibleDefinition(t *testing.T) {
+	machine := buildSnapshotDefinition("running").CreateInstance()
+	_ = machine.Start()
+	machine.HandleEvent("start", nil)
+	snapshot, _ := machine.Snapshot()
+
+	renamed := buildSnapshotDefinition("active")
+	err := renamed.CreateInstance().Restore(snapshot)
+	if GetErrorCode(err) != ErrCodeIncompatibleSnapshot {
+		t.Errorf("Expected an incompatible snapshot error, got %v", err)
+	}
+
+	relabeled := buildSnapshotDefinition("running").CreateInstance(WithDefinitionVersion("v2"))
+	snapshot.DefinitionVersion = "v1"
+	if err := relabeled.Restore(snapshot); GetErrorCode(err) != ErrCodeIncompatibleSnapshot {
+		t.Errorf("Expected a version mismatch to be refused, got %v", err)
+	}
+
+	legacy := *snapshot
+	legacy.DefinitionVersion, legacy.DefinitionHash = "", ""
+	if err := renamed.CreateInstance().Restore(&legacy); !IsStateError(err) {
+		t.Errorf("Expected a snapshot without definition data to be restored as before, got %v", err)
+	}
+}
+
+func TestSnapshot_RestoreMigratesIncompatibleDefinition(t *testing.T) {
+	machine := buildSnapshotDefinition("running").CreateInstance()
+	_ = machine.Start()
+	machine.HandleEvent("start", nil)
+	snapshot, _ := machine.Snapshot()
+
+	migrated := buildSnapshotDefinition("active").CreateInstance(
+		WithSnapshotMigration(MapStates(map[string]string{"running": "active"})))
+	if err := migrated.Restore(snapshot); err != nil {
+		t.Fatalf("Expected the migration to adapt the snapshot, got %v", err)
+	}
+	AssertState(t, migrated, "active")
+	if snapshot.CurrentState != "running" {
+		t.Errorf("Expected the migration to leave the snapshot untouched, got %s", snapshot.CurrentState)
+	}
+}
@@ -1,5 +1,7 @@
 package fluo
 
+import "time"
+
 // State represents a state in the state machine
 type State interface {
 	ID() string
@@ -37,14 +39,18 @@ type ParallelState interface {
 	AddRegion(region Region)
 }
 
-// Region represents a parallel region
+// Region represents a parallel region. Regions are structural and shared by
+// every instance of a definition; use Machine.RegionState, SetRegionStates
+// and RegionCompleted for the runtime state of a region.
 type Region interface {
 	ID() string
 	ParentState() ParallelState
+	// Deprecated: region state is per instance; use Machine.RegionState.
 	CurrentState() State
 	InitialState() State
 	States() []State
-	IsComplete() bool    // Check if region has reached final state
+	// Deprecated: region state is per instance; use Machine.RegionCompleted.
+	IsComplete() bool
 	HasFinalState() bool // Check if region contains a final state
 }
 
@@ -74,6 +80,10 @@ const (
 	History
 	// DeepHistory pseudostate for deep history
 	DeepHistory
+	// EntryPoint pseudostate for a named way into a composite state
+	EntryPoint
+	// ExitPoint pseudostate for a named way out of a composite state
+	ExitPoint
 )
 
 // ActionFunc represents an enhanced action function with error support
@@ -82,12 +92,17 @@ type ActionFunc func(ctx Context) error
 // GuardFunc represents a guard condition function
 type GuardFunc func(ctx Context) bool
 
+// TargetFunc computes the ID of a target state at runtime
+type TargetFunc func(ctx Context) string
+
 // AtomicStateImpl implements the AtomicState interface
 type AtomicStateImpl struct {
 	id          string
 	parent      State
 	entryAction ActionFunc
 	exitAction  ActionFunc
+	activity    ActivityFunc
+	submachine  MachineDefinition
 	final       bool
 }
 
@@ -165,6 +180,18 @@ func (s *AtomicStateImpl) WithExitAction(action ActionFunc) *AtomicStateImpl {
 	return s
 }
 
+// WithActivity sets the do-activity run while the state is active
+func (s *AtomicStateImpl) WithActivity(activity ActivityFunc) *AtomicStateImpl {
+	s.activity = activity
+	return s
+}
+
+// WithSubmachine embeds a machine definition run while the state is active
+func (s *AtomicStateImpl) WithSubmachine(definition MachineDefinition) *AtomicStateImpl {
+	s.submachine = definition
+	return s
+}
+
 // WithParent sets the parent state
 func (s *AtomicStateImpl) WithParent(parent State) *AtomicStateImpl {
 	s.parent = parent
@@ -224,6 +251,14 @@ func (s *CompositeStateImpl) AddSubstate(state State) {
 	if parallelState, ok := state.(*ParallelStateImpl); ok {
 		parallelState.WithParent(s)
 	}
+
+	// Set parent relationship for sequential states and pseudostates
+	if sequentialState, ok := state.(*SequentialStateImpl); ok {
+		sequentialState.parent = s
+	}
+	if pseudoState, ok := state.(*PseudoStateImpl); ok {
+		pseudoState.parent = s
+	}
 }
 
 // WithInitialState sets the initial substate
@@ -253,7 +288,8 @@ func NewSequentialState(id string) *SequentialStateImpl {
 // ParallelStateImpl implements the ParallelState interface
 type ParallelStateImpl struct {
 	CompositeStateImpl
-	regions []Region
+	regions       []Region
+	reentryPolicy RegionReentryPolicy
 }
 
 // NewParallelState creates a new parallel composite state
@@ -279,11 +315,21 @@ func (s *ParallelStateImpl) AddRegion(region Region) {
 	s.regions = append(s.regions, region)
 }
 
+// WithRegionReentry sets what happens when an event targets a completed region
+func (s *ParallelStateImpl) WithRegionReentry(policy RegionReentryPolicy) *ParallelStateImpl {
+	s.reentryPolicy = policy
+	return s
+}
+
+// RegionReentry returns the policy for events targeting a completed region
+func (s *ParallelStateImpl) RegionReentry() RegionReentryPolicy {
+	return s.reentryPolicy
+}
+
 // RegionImpl implements the Region interface
 type RegionImpl struct {
 	id           string
 	parentState  ParallelState
-	currentState State
 	initialState State
 	states       []State
 	stateMap     map[string]State
@@ -309,9 +355,12 @@ func (r *RegionImpl) ParentState() ParallelState {
 	return r.parentState
 }
 
-// CurrentState returns the current state in this region
+// CurrentState returns nil: a region is shared by every instance of a
+// definition and holds no runtime state.
+//
+// Deprecated: region state is per instance; use Machine.RegionState.
 func (r *RegionImpl) CurrentState() State {
-	return r.currentState
+	return nil
 }
 
 // InitialState returns the initial state of this region
@@ -336,12 +385,12 @@ func (r *RegionImpl) WithInitialState(state State) *RegionImpl {
 	return r
 }
 
-// IsComplete checks if this region has reached a final state
+// IsComplete returns false: a region is shared by every instance of a
+// definition and holds no runtime state.
+//
+// Deprecated: region state is per instance; use Machine.RegionCompleted.
 func (r *RegionImpl) IsComplete() bool {
-	if r.currentState == nil {
-		return false
-	}
-	return r.currentState.IsFinal()
+	return false
 }
 
 // HasFinalState checks if this region contains at least one final state
@@ -358,13 +407,17 @@ func (r *RegionImpl) HasFinalState() bool {
 type PseudoStateImpl struct {
 	AtomicStateImpl
 	kind                   PseudoStateKind
-	choiceConditions       []ChoiceCondition // For Choice pseudostates
-	defaultTarget          string            // Default target for Choice/Junction (state ID)
-	forkTargets            []string          // Target states for Fork (state IDs)
-	joinSourceCombinations [][]string        // Source state combinations for Join (each element is one valid combination)
-	joinTarget             string            // Target state for Join (state ID)
-	historyDefault         string            // Default state for History pseudostates (state ID)
-	historyType            PseudoStateKind   // History or DeepHistory
+	choiceConditions       []ChoiceCondition     // For Choice pseudostates
+	defaultTarget          string                // Default target for Choice/Junction (state ID)
+	forkTargets            []string              // Target states for Fork (state IDs)
+	forkBranches           map[string]ForkBranch // Labels and actions of Fork targets, by target state ID
+	joinSourceCombinations [][]string            // Source state combinations for Join (each element is one valid combination)
+	joinTarget             string                // Target state for Join (state ID)
+	joinFork               string                // Fork whose branches a Join derives its sources from (state ID)
+	historyDefault         string                // Default state for History pseudostates (state ID)
+	historyType            PseudoStateKind       // History or DeepHistory
+	historyTTL             time.Duration         // How long recorded history stays restorable (0 keeps it)
+	historyExitLimit       int                   // How many exits recorded history is kept for (0 is unlimited)
 }
 
 // NewPseudoState creates a new pseudostate
@@ -402,13 +455,28 @@ func (s *PseudoStateImpl) Kind() PseudoStateKind {
 
 // AddChoiceCondition adds a condition for Choice pseudostates
 func (s *PseudoStateImpl) AddChoiceCondition(guard GuardFunc, target string, action ActionFunc) {
-	s.choiceConditions = append(s.choiceConditions, ChoiceCondition{
+	s.addChoiceCondition(ChoiceCondition{
 		Guard:  guard,
 		Target: target,
 		Action: action,
 	})
 }
 
+// addChoiceCondition adds a condition for Choice and Junction pseudostates
+func (s *PseudoStateImpl) addChoiceCondition(condition ChoiceCondition) {
+	s.choiceConditions = append(s.choiceConditions, condition)
+}
+
+// AddChoiceTargetFunc adds a condition whose target is computed when the
+// choice is taken, for Choice pseudostates
+func (s *PseudoStateImpl) AddChoiceTargetFunc(guard GuardFunc, target TargetFunc, action ActionFunc) {
+	s.addChoiceCondition(ChoiceCondition{
+		Guard:      guard,
+		TargetFunc: target,
+		Action:     action,
+	})
+}
+
 // SetDefaultTarget sets the default target for Choice/Junction pseudostates
 func (s *PseudoStateImpl) SetDefaultTarget(target string) {
 	s.defaultTarget = target
@@ -424,6 +492,38 @@ func (s *PseudoStateImpl) AddForkTarget(target string) {
 	s.forkTargets = append(s.forkTargets, target)
 }
 
+// ForkBranch is a labelled target of a fork pseudostate with its own action
+type ForkBranch struct {
+	// Label identifies the branch to observers and exports
+	Label string
+	// Target is the state the branch enters
+	Target string
+	// Action runs before the branch's target is entered
+	Action ActionFunc
+}
+
+// AddForkBranch adds a labelled target with its own action for Fork pseudostates
+func (s *PseudoStateImpl) AddForkBranch(branch ForkBranch) {
+	s.forkTargets = append(s.forkTargets, branch.Target)
+	if s.forkBranches == nil {
+		s.forkBranches = make(map[string]ForkBranch)
+	}
+	s.forkBranches[branch.Target] = branch
+}
+
+// forkBranch returns the branch of a fork target, labelled by the target
+// when it was added without a label
+func (s *PseudoStateImpl) forkBranch(target string) ForkBranch {
+	branch, ok := s.forkBranches[target]
+	if !ok {
+		branch = ForkBranch{Target: target}
+	}
+	if branch.Label == "" {
+		branch.Label = target
+	}
+	return branch
+}
+
 // SetJoinSources adds a combination of source states for Join pseudostates
 // Each call to this method represents one valid source combination
 func (s *PseudoStateImpl) SetJoinSources(sources []string) {
@@ -433,6 +533,12 @@ func (s *PseudoStateImpl) SetJoinSources(sources []string) {
 	s.joinSourceCombinations = append(s.joinSourceCombinations, combination)
 }
 
+// SetJoinFork derives the source combinations of a Join pseudostate from the
+// branches of a fork when the machine is built
+func (s *PseudoStateImpl) SetJoinFork(forkID string) {
+	s.joinFork = forkID
+}
+
 // SetJoinTarget sets the target state for Join pseudostates
 func (s *PseudoStateImpl) SetJoinTarget(target string) {
 	s.joinTarget = target
@@ -442,3 +548,14 @@ func (s *PseudoStateImpl) SetJoinTarget(target string) {
 func (s *PseudoStateImpl) SetHistoryDefault(target string) {
 	s.historyDefault = target
 }
+
+// SetHistoryTTL makes History pseudostates ignore history recorded longer ago than ttl
+func (s *PseudoStateImpl) SetHistoryTTL(ttl time.Duration) {
+	s.historyTTL = ttl
+}
+
+// SetHistoryExitLimit makes History pseudostates keep recorded history for
+// the given number of exits of the state owning it
+func (s *PseudoStateImpl) SetHistoryExitLimit(exits int) {
+	s.historyExitLimit = exits
+}
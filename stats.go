@@ -0,0 +1,220 @@
+package fluo
+
+import (
+	"expvar"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MachineStats reports how long event handlers wait for the machine mutex and
+// how long they hold it, along with the time spent in guards and actions.
+// Handlers include sent events as well as timer, do-activity and submachine
+// completions.
+type MachineStats struct {
+	// Enabled is false unless the instance was created with WithStats
+	Enabled bool
+	// Elapsed is the time since statistics collection started
+	Elapsed time.Duration
+	// InFlight is the number of handlers currently waiting for or holding the mutex
+	InFlight int64
+	// Handled is the number of handlers that ran, and Contended how many of
+	// them found the mutex held by someone else
+	Handled   int64
+	Contended int64
+
+	LockWaitTotal time.Duration
+	LockWaitMax   time.Duration
+	HoldTotal     time.Duration
+	HoldMax       time.Duration
+
+	// Guards and Actions time the guards and actions run by handlers, including
+	// entry and exit actions
+	Guards  DurationStats
+	Actions DurationStats
+
+	// Events breaks the totals down by event name
+	Events map[string]EventStats
+}
+
+// EventStats reports mutex usage by the handlers of a single event name
+type EventStats struct {
+	Handled       int64
+	LockWaitTotal time.Duration
+	HoldTotal     time.Duration
+	HoldMax       time.Duration
+}
+
+// DurationStats summarizes the run times of one kind of callback
+type DurationStats struct {
+	Count int64
+	Total time.Duration
+	Max   time.Duration
+}
+
+// Average returns the mean run time
+func (d DurationStats) Average() time.Duration {
+	if d.Count == 0 {
+		return 0
+	}
+	return d.Total / time.Duration(d.Count)
+}
+
+// add records one run
+func (d *DurationStats) add(elapsed time.Duration) {
+	d.Count++
+	d.Total += elapsed
+	d.Max = max(d.Max, elapsed)
+}
+
+// AverageLockWait returns the mean time a handler waited for the mutex
+func (s MachineStats) AverageLockWait() time.Duration {
+	if s.Handled == 0 {
+		return 0
+	}
+	return s.LockWaitTotal / time.Duration(s.Handled)
+}
+
+// AverageHold returns the mean time a handler held the mutex
+func (s MachineStats) AverageHold() time.Duration {
+	if s.Handled == 0 {
+		return 0
+	}
+	return s.HoldTotal / time.Duration(s.Handled)
+}
+
+// AverageLatency returns the mean time from a handler asking for the mutex to
+// releasing it, the latency an event sender observes
+func (s MachineStats) AverageLatency() time.Duration {
+	return s.AverageLockWait() + s.AverageHold()
+}
+
+// Occupancy returns the fraction of the elapsed time the mutex was held by handlers
+func (s MachineStats) Occupancy() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.HoldTotal) / float64(s.Elapsed)
+}
+
+// WithStats collects mutex wait and hold times of event handlers and the run
+// times of guards and actions, reported by Stats. Without it, Stats returns
+// zero values and nothing is timed.
+func WithStats() MachineOption {
+	return func(sm *StateMachine) {
+		sm.stats = &lockStats{
+			since:  time.Now(),
+			events: make(map[string]*EventStats),
+		}
+	}
+}
+
+// ExpvarStats returns an expvar.Var publishing the statistics of a machine,
+// for use with expvar.Publish
+func ExpvarStats(machine Machine) expvar.Var {
+	return expvar.Func(func() any {
+		return machine.Stats()
+	})
+}
+
+// Stats returns the statistics collected since the instance was created
+func (sm *StateMachine) Stats() MachineStats {
+	if sm.stats == nil {
+		return MachineStats{}
+	}
+	return sm.stats.snapshot()
+}
+
+// lockStats accumulates mutex usage and guard and action timings. Its own
+// mutex is only held briefly, after the machine mutex is released for handler
+// runs and after each guard or action, so collecting adds little contention.
+type lockStats struct {
+	since    time.Time
+	inFlight atomic.Int64
+
+	mutex  sync.Mutex
+	totals MachineStats
+	events map[string]*EventStats
+}
+
+// lockForEvent acquires the machine mutex for the handler of an event and
+// returns the function releasing it
+func (sm *StateMachine) lockForEvent(eventName string) (unlock func()) {
+	if sm.stats == nil {
+		sm.mutex.Lock()
+		return sm.unlock
+	}
+
+	sm.stats.inFlight.Add(1)
+	requested := time.Now()
+	contended := !sm.mutex.TryLock()
+	if contended {
+		sm.mutex.Lock()
+	}
+	acquired := time.Now()
+
+	return func() {
+		sm.publishView()
+		hold := time.Since(acquired)
+		sm.mutex.Unlock()
+		sm.stats.inFlight.Add(-1)
+		sm.stats.observe(eventName, contended, acquired.Sub(requested), hold)
+	}
+}
+
+// observe records one handler run
+func (s *lockStats) observe(eventName string, contended bool, wait, hold time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.totals.Handled++
+	if contended {
+		s.totals.Contended++
+	}
+	s.totals.LockWaitTotal += wait
+	s.totals.LockWaitMax = max(s.totals.LockWaitMax, wait)
+	s.totals.HoldTotal += hold
+	s.totals.HoldMax = max(s.totals.HoldMax, hold)
+
+	event, ok := s.events[eventName]
+	if !ok {
+		event = &EventStats{}
+		s.events[eventName] = event
+	}
+	event.Handled++
+	event.LockWaitTotal += wait
+	event.HoldTotal += hold
+	event.HoldMax = max(event.HoldMax, hold)
+}
+
+// observeGuard records a guard that started at the given time
+func (s *lockStats) observeGuard(started time.Time) {
+	elapsed := time.Since(started)
+	s.mutex.Lock()
+	s.totals.Guards.add(elapsed)
+	s.mutex.Unlock()
+}
+
+// observeAction records an action that started at the given time
+func (s *lockStats) observeAction(started time.Time) {
+	elapsed := time.Since(started)
+	s.mutex.Lock()
+	s.totals.Actions.add(elapsed)
+	s.mutex.Unlock()
+}
+
+// snapshot copies the collected statistics
+func (s *lockStats) snapshot() MachineStats {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	stats := s.totals
+	stats.Enabled = true
+	stats.Elapsed = time.Since(s.since)
+	stats.InFlight = s.inFlight.Load()
+	stats.Events = make(map[string]EventStats, len(s.events))
+	for name, event := range s.events {
+		stats.Events[name] = *event
+	}
+	return stats
+}
@@ -0,0 +1,121 @@
+package fluo
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStats_Disabled(t *testing.T) {
+	machine := buildEventLogDefinition().CreateInstance()
+	_ = machine.Start()
+	machine.HandleEvent("submit", nil)
+
+	if stats := machine.Stats(); stats.Enabled || stats.Handled != 0 {
+		t.Errorf("Expected no statistics without WithStats, got %+v", stats)
+	}
+}
+
+func TestStats_CountsHandlers(t *testing.T) {
+	builder := NewMachine()
+	builder.State("idle").Initial().
+		To("busy").On("work").
+		Do(func(ctx Context) error {
+			time.Sleep(5 * time.Millisecond)
+			return nil
+		})
+	builder.State("busy").
+		To("idle").On("rest")
+
+	machine := builder.Build().CreateInstance(WithStats())
+	_ = machine.Start()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			machine.HandleEvent("work", nil)
+			machine.HandleEvent("rest", nil)
+		}()
+	}
+	wg.Wait()
+
+	stats := machine.Stats()
+	if !stats.Enabled || stats.Handled != 8 || stats.InFlight != 0 {
+		t.Fatalf("Expected 8 handled events, got %+v", stats)
+	}
+	if stats.Events["work"].Handled != 4 || stats.Events["rest"].Handled != 4 {
+		t.Errorf("Unexpected per-event counts: %+v", stats.Events)
+	}
+	if stats.HoldMax < 5*time.Millisecond || stats.AverageHold() <= 0 {
+		t.Errorf("Expected hold times to include the action, got %+v", stats)
+	}
+	if stats.Occupancy() <= 0 || stats.Occupancy() > 1 {
+		t.Errorf("Expected occupancy within (0, 1], got %f", stats.Occupancy())
+	}
+}
+
+func TestStats_TimedEvents(t *testing.T) {
+	machine := buildEventLogDefinition().CreateInstance(WithStats())
+	_ = machine.Start()
+	machine.HandleEvent("submit", nil)
+	machine.HandleEvent("approve", nil)
+
+	deadline := time.Now().Add(time.Second)
+	for machine.CurrentState() != "archived" && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if stats := machine.Stats(); stats.Handled != 3 {
+		t.Errorf("Expected the timer handler to be counted, got %+v", stats)
+	}
+}
+
+func TestStats_GuardsAndActions(t *testing.T) {
+	builder := NewMachine()
+	builder.State("idle").Initial().
+		OnExit(func(ctx Context) error { return nil }).
+		To("busy").On("work").
+		When(func(ctx Context) bool {
+			time.Sleep(2 * time.Millisecond)
+			return true
+		}).
+		Do(func(ctx Context) error {
+			time.Sleep(3 * time.Millisecond)
+			return nil
+		})
+	builder.State("busy").
+		OnEntry(func(ctx Context) error { return nil })
+
+	machine := builder.Build().CreateInstance(WithStats())
+	_ = machine.Start()
+	machine.HandleEvent("work", nil)
+
+	stats := machine.Stats()
+	if stats.Guards.Count != 1 || stats.Guards.Max < 2*time.Millisecond {
+		t.Errorf("Expected one timed guard, got %+v", stats.Guards)
+	}
+	// The transition action plus the exit and entry actions
+	if stats.Actions.Count != 3 || stats.Actions.Max < 3*time.Millisecond {
+		t.Errorf("Expected three timed actions, got %+v", stats.Actions)
+	}
+	if stats.Actions.Average() <= 0 || stats.AverageLatency() < stats.AverageHold() {
+		t.Errorf("Unexpected averages: %+v", stats)
+	}
+}
+
+func TestExpvarStats(t *testing.T) {
+	machine := buildEventLogDefinition().CreateInstance(WithStats())
+	_ = machine.Start()
+	machine.HandleEvent("submit", nil)
+
+	var published MachineStats
+	if err := json.Unmarshal([]byte(ExpvarStats(machine).String()), &published); err != nil {
+		t.Fatalf("Expected JSON, got %v", err)
+	}
+	if published.Handled != 1 || published.Events["submit"].Handled != 1 {
+		t.Errorf("Unexpected published statistics: %+v", published)
+	}
+}
@@ -0,0 +1,65 @@
+package fluo
+
+import (
+	"slices"
+	"sync"
+)
+
+// Store persists machine snapshots by instance ID
+type Store interface {
+	// Save stores the snapshot of an instance, replacing any previous one
+	Save(id string, snapshot *Snapshot) error
+	// Load returns the snapshot of an instance, reporting false if none is stored
+	Load(id string) (*Snapshot, bool, error)
+	// Delete removes the snapshot of an instance
+	Delete(id string) error
+}
+
+// MemoryStore is a Store keeping snapshots in memory
+type MemoryStore struct {
+	snapshots map[string]*Snapshot
+	mutex     sync.RWMutex
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		snapshots: make(map[string]*Snapshot),
+	}
+}
+
+// Save stores the snapshot of an instance
+func (s *MemoryStore) Save(id string, snapshot *Snapshot) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.snapshots[id] = snapshot
+	return nil
+}
+
+// Load returns the snapshot of an instance
+func (s *MemoryStore) Load(id string) (*Snapshot, bool, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	snapshot, ok := s.snapshots[id]
+	return snapshot, ok, nil
+}
+
+// Delete removes the snapshot of an instance
+func (s *MemoryStore) Delete(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.snapshots, id)
+	return nil
+}
+
+// IDs returns the IDs of the stored instances in sorted order
+func (s *MemoryStore) IDs() []string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	ids := make([]string, 0, len(s.snapshots))
+	for id := range s.snapshots {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
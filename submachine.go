@@ -0,0 +1,190 @@
+package fluo
+
+import (
+	"context"
+	"slices"
+	"strings"
+)
+
+// runningSubmachine is the child instance of a submachine state
+type runningSubmachine struct {
+	stateID    string
+	definition MachineDefinition
+	machine    Machine
+}
+
+// submachineOf returns the definition embedded by a submachine state, if any
+func submachineOf(state State) MachineDefinition {
+	if atomicState, ok := state.(*AtomicStateImpl); ok {
+		return atomicState.submachine
+	}
+	return nil
+}
+
+// completed reports whether the child instance is in a top-level final state
+func (rs *runningSubmachine) completed() bool {
+	current := rs.machine.CurrentState()
+	if strings.Contains(current, ".") {
+		return false
+	}
+	state, ok := rs.definition.GetStates()[current]
+	return ok && state.IsFinal()
+}
+
+// startSubmachine creates and starts the child instance of a submachine state.
+// The caller must hold the machine mutex.
+func (sm *StateMachine) startSubmachine(state State) {
+	definition := submachineOf(state)
+	if definition == nil {
+		return
+	}
+	sm.stopSubmachine(state.ID())
+
+	rs := &runningSubmachine{
+		stateID:    state.ID(),
+		definition: definition,
+		machine:    definition.CreateInstance(),
+	}
+	rs.machine.AddObserver(&submachineObserver{parent: sm, submachine: rs})
+	sm.submachines[rs.stateID] = rs
+
+	if err := rs.machine.Start(); err != nil {
+		delete(sm.submachines, rs.stateID)
+		sm.observers.NotifyError(err, sm.context)
+	}
+}
+
+// stopSubmachine stops the child instance of a submachine state.
+// The caller must hold the machine mutex.
+func (sm *StateMachine) stopSubmachine(stateID string) {
+	if rs, ok := sm.submachines[stateID]; ok {
+		delete(sm.submachines, stateID)
+		_ = rs.machine.Stop()
+	}
+}
+
+// suspendSubmachines stops every child instance of the machine, keeping them
+// to be resumed by resumeSubmachine. The caller must hold the machine mutex.
+func (sm *StateMachine) suspendSubmachines() {
+	for _, rs := range sm.submachines {
+		_ = rs.machine.Stop()
+	}
+}
+
+// resumeSubmachine restarts the suspended child instance of a submachine
+// state, or starts a new one when there is none. The caller must hold the
+// machine mutex.
+func (sm *StateMachine) resumeSubmachine(state State) {
+	if state == nil {
+		return
+	}
+	rs, ok := sm.submachines[state.ID()]
+	if !ok {
+		sm.startSubmachine(state)
+		return
+	}
+	// A child instance that is already running is left alone
+	if err := rs.machine.Start(); err != nil && GetErrorCode(err) != ErrCodeInvalidState {
+		delete(sm.submachines, rs.stateID)
+		sm.observers.NotifyError(err, sm.context)
+	}
+}
+
+// stopAllSubmachines stops every child instance of the machine.
+// The caller must hold the machine mutex.
+func (sm *StateMachine) stopAllSubmachines() {
+	for stateID := range sm.submachines {
+		sm.stopSubmachine(stateID)
+	}
+}
+
+// Submachine returns the running child instance of a submachine state, or nil
+func (sm *StateMachine) Submachine(stateID string) Machine {
+	sm.mutex.RLock()
+	defer sm.mutex.RUnlock()
+
+	if rs, ok := sm.submachines[stateID]; ok {
+		return rs.machine
+	}
+	return nil
+}
+
+// forwardToSubmachines offers an event to the running child instances before
+// the machine's own transitions. It returns nil when no child processed it.
+// The caller must hold the machine mutex.
+func (sm *StateMachine) forwardToSubmachines(eventName string, eventData any) *EventResult {
+	if len(sm.submachines) == 0 {
+		return nil
+	}
+
+	for _, rs := range sm.sortedSubmachines() {
+		result := rs.machine.HandleEvent(eventName, eventData)
+		if !result.Processed {
+			continue
+		}
+		if rs.completed() {
+			if completion := sm.completeSubmachineLocked(rs); completion != nil {
+				return completion
+			}
+		}
+		return NewEventResult(true, false, sm.currentState, sm.currentState)
+	}
+	return nil
+}
+
+// sortedSubmachines returns the running child instances ordered by state ID
+func (sm *StateMachine) sortedSubmachines() []*runningSubmachine {
+	submachines := make([]*runningSubmachine, 0, len(sm.submachines))
+	for _, rs := range sm.submachines {
+		submachines = append(submachines, rs)
+	}
+	slices.SortFunc(submachines, func(a, b *runningSubmachine) int {
+		return strings.Compare(a.stateID, b.stateID)
+	})
+	return submachines
+}
+
+// completeSubmachine takes the completion transition of a submachine state
+// whose child instance finished outside of a forwarded event, e.g. on a timer
+func (sm *StateMachine) completeSubmachine(rs *runningSubmachine) {
+	if sm.eventLoop != nil && sm.eventLoop.enqueue(&queuedEvent{ctx: context.Background(), submachine: rs}) {
+		return
+	}
+
+	defer sm.lockForEvent("__completion_" + rs.stateID)()
+
+	sm.completeSubmachineLocked(rs)
+}
+
+// completeSubmachineLocked stops a finished child instance and fires the
+// completion transition of its state, unless the state was exited meanwhile.
+// The caller must hold the machine mutex.
+func (sm *StateMachine) completeSubmachineLocked(rs *runningSubmachine) *EventResult {
+	if sm.submachines[rs.stateID] != rs {
+		return nil
+	}
+	sm.stopSubmachine(rs.stateID)
+
+	if sm.machineState != MachineStateStarted {
+		return nil
+	}
+	return sm.fireCompletion(rs.stateID)
+}
+
+// submachineObserver watches a child instance for its final state
+type submachineObserver struct {
+	BaseObserver
+	parent     *StateMachine
+	submachine *runningSubmachine
+}
+
+// OnStateEnter completes the submachine state once the child reaches a final state.
+// It runs under the child's mutex, so the parent is notified asynchronously.
+func (o *submachineObserver) OnStateEnter(state string, ctx Context) {
+	if strings.Contains(state, ".") {
+		return
+	}
+	if s, ok := o.submachine.definition.GetStates()[state]; ok && s.IsFinal() {
+		go o.parent.completeSubmachine(o.submachine)
+	}
+}
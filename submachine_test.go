@@ -0,0 +1,109 @@
+package fluo
+
+import (
+	"testing"
+	"time"
+)
+
+func buildPaymentSubmachine() MachineDefinition {
+	return NewMachine().
+		State("authorizing").Initial().
+		To("capturing").On("authorized").
+		State("capturing").
+		To("paid").On("captured").
+		State("paid").Final().
+		Build()
+}
+
+func buildCheckoutMachine() MachineDefinition {
+	return NewMachine().
+		State("cart").Initial().
+		To("payment").On("checkout").
+		SubmachineState("payment", buildPaymentSubmachine()).
+		To("shipped").OnCompletion().
+		To("cart").On("abort").
+		State("shipped").
+		Build()
+}
+
+func TestSubmachine_ForwardsEventsAndCompletes(t *testing.T) {
+	machine := buildCheckoutMachine().CreateInstance()
+	_ = machine.Start()
+
+	machine.HandleEvent("checkout", nil)
+	AssertState(t, machine, "payment")
+
+	child := machine.Submachine("payment")
+	if child == nil {
+		t.Fatal("Expected a running submachine")
+	}
+	AssertState(t, child, "authorizing")
+
+	AssertEventProcessed(t, machine.HandleEvent("authorized", nil), true)
+	AssertState(t, machine, "payment")
+	AssertState(t, child, "capturing")
+
+	AssertEventProcessed(t, machine.HandleEvent("captured", nil), true)
+	AssertState(t, machine, "shipped")
+	if machine.Submachine("payment") != nil {
+		t.Error("Expected the submachine to stop once completed")
+	}
+}
+
+func TestSubmachine_ParentTransitionsStillApply(t *testing.T) {
+	machine := buildCheckoutMachine().CreateInstance()
+	_ = machine.Start()
+
+	machine.HandleEvent("checkout", nil)
+	child := machine.Submachine("payment")
+
+	AssertEventProcessed(t, machine.HandleEvent("abort", nil), true)
+	AssertState(t, machine, "cart")
+	if machine.Submachine("payment") != nil {
+		t.Error("Expected the submachine to stop on exit")
+	}
+	if child.HandleEvent("authorized", nil).Processed {
+		t.Error("Expected the stopped submachine to reject events")
+	}
+}
+
+func TestSubmachine_FreshInstancePerEntry(t *testing.T) {
+	machine := buildCheckoutMachine().CreateInstance()
+	_ = machine.Start()
+
+	machine.HandleEvent("checkout", nil)
+	machine.HandleEvent("authorized", nil)
+	machine.HandleEvent("abort", nil)
+	machine.HandleEvent("checkout", nil)
+
+	AssertState(t, machine.Submachine("payment"), "authorizing")
+}
+
+func TestSubmachine_CompletesOnChildTimer(t *testing.T) {
+	child := NewMachine().
+		State("waiting").Initial().
+		After(10 * time.Millisecond).To("done").
+		State("done").Final().
+		Build()
+
+	machine := NewMachine().
+		State("idle").Initial().
+		To("sub").On("go").
+		SubmachineState("sub", child).
+		To("finished").OnCompletion().
+		State("finished").
+		Build().
+		CreateInstance()
+	_ = machine.Start()
+	machine.HandleEvent("go", nil)
+
+	waitForState(t, machine, "finished", time.Second)
+}
+
+func TestSubmachine_Describe(t *testing.T) {
+	for _, state := range Describe(buildCheckoutMachine()).States {
+		if state.ID == "payment" && state.Kind != "submachine" {
+			t.Errorf("Expected submachine kind, got %s", state.Kind)
+		}
+	}
+}
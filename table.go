@@ -0,0 +1,69 @@
+package fluo
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strings"
+)
+
+// TransitionTable lists the workflow rules of a definition, one row per
+// transition or pseudostate branch, for review outside of Go code
+type TransitionTable []TransitionRow
+
+// TransitionRow is one rule of a transition table
+type TransitionRow struct {
+	Source string `json:"source"`
+	Event  string `json:"event"`
+	Guard  string `json:"guard,omitempty"` // Annotation, registered guard name or "guarded"
+	Target string `json:"target"`
+	Action string `json:"action,omitempty"` // Registered action name, or the kind of an unnamed action
+}
+
+// TransitionTable returns the transitions of the definition in the order of
+// Describe. Timed and completion transitions show their trigger as the event.
+func (smd *simpleMachineDefinition) TransitionTable() TransitionTable {
+	description := Describe(smd)
+	table := make(TransitionTable, 0, len(description.Transitions))
+	for _, transition := range description.Transitions {
+		guard, _ := docTransitionNotes(transition)
+		table = append(table, TransitionRow{
+			Source: transition.From,
+			Event:  transition.Trigger,
+			Guard:  guard,
+			Target: transition.To,
+			Action: docTransitionAction(transition),
+		})
+	}
+	return table
+}
+
+// CSV renders the table with a header row: source, event, guard, target, action
+func (t TransitionTable) CSV() ([]byte, error) {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	if err := writer.Write([]string{"source", "event", "guard", "target", "action"}); err != nil {
+		return nil, err
+	}
+	for _, row := range t {
+		if err := writer.Write([]string{row.Source, row.Event, row.Guard, row.Target, row.Action}); err != nil {
+			return nil, err
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Markdown renders the table as a Markdown table
+func (t TransitionTable) Markdown() string {
+	var out strings.Builder
+	out.WriteString("| Source | Event | Guard | Target | Action |\n| --- | --- | --- | --- | --- |\n")
+	for _, row := range t {
+		out.WriteString(fmt.Sprintf("| `%s` | %s | %s | `%s` | %s |\n",
+			row.Source, escapeMarkdownCell(row.Event), escapeMarkdownCell(row.Guard), row.Target, escapeMarkdownCell(row.Action)))
+	}
+	return out.String()
+}
@@ -0,0 +1,77 @@
+package fluo
+
+import (
+	"encoding/csv"
+	"strings"
+	"testing"
+)
+
+func buildTransitionTableDefinition() MachineDefinition {
+	registry := NewRegistry()
+	registry.RegisterGuard("hasApprover", func(ctx Context) bool { return true })
+	registry.RegisterAction("notifyAuthor", func(ctx Context) error { return nil })
+
+	definition, err := registry.LoadDefinitionJSON([]byte(`{
+		"initial": "draft",
+		"states": [
+			{"id": "draft", "transitions": [
+				{"event": "submit", "target": "review", "guard": "hasApprover", "action": "notifyAuthor"}
+			]},
+			{"id": "review", "transitions": [
+				{"event": "reject", "target": "draft", "description": "reviewer | editor declined"},
+				{"after": "48h", "target": "draft"}
+			]}
+		]
+	}`))
+	if err != nil {
+		panic(err)
+	}
+	return definition
+}
+
+func TestTransitionTable(t *testing.T) {
+	table := buildTransitionTableDefinition().TransitionTable()
+	if len(table) != 3 {
+		t.Fatalf("Expected 3 rows, got %+v", table)
+	}
+
+	expected := TransitionRow{Source: "draft", Event: "submit", Guard: "hasApprover", Target: "review", Action: "notifyAuthor"}
+	if table[0] != expected {
+		t.Errorf("Expected %+v, got %+v", expected, table[0])
+	}
+	for _, row := range table[1:] {
+		if row.Source != "review" || row.Target != "draft" || row.Action != "" {
+			t.Errorf("Unexpected row %+v", row)
+		}
+	}
+}
+
+func TestTransitionTable_Renderers(t *testing.T) {
+	table := buildTransitionTableDefinition().TransitionTable()
+
+	data, err := table.CSV()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	records, err := csv.NewReader(strings.NewReader(string(data))).ReadAll()
+	if err != nil {
+		t.Fatalf("Expected valid CSV, got %v", err)
+	}
+	if len(records) != 4 || strings.Join(records[0], ",") != "source,event,guard,target,action" {
+		t.Fatalf("Unexpected CSV: %v", records)
+	}
+	if strings.Join(records[1], ",") != "draft,submit,hasApprover,review,notifyAuthor" {
+		t.Errorf("Unexpected first record: %v", records[1])
+	}
+
+	markdown := table.Markdown()
+	for _, fragment := range []string{
+		"| Source | Event | Guard | Target | Action |",
+		"| `draft` | submit | hasApprover | `review` | notifyAuthor |",
+		"reviewer \\| editor declined",
+	} {
+		if !strings.Contains(markdown, fragment) {
+			t.Errorf("Expected markdown to contain %q\n%s", fragment, markdown)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package fluo
 import (
 	"sync"
 	"testing"
+	"time"
 )
 
 // TestObserver is a mock observer for testing that captures all observer events
@@ -17,6 +18,9 @@ type TestObserver struct {
 	Started      []ContextEvent
 	Stopped      []ContextEvent
 	Guards       []GuardEvent
+	Timers       []TimerEvent
+	Regions      []RegionEvent
+	LimitAlerts  []LimitAlertEvent
 }
 
 type TransitionEvent struct {
@@ -61,6 +65,24 @@ type GuardEvent struct {
 	Ctx    Context
 }
 
+type TimerEvent struct {
+	State string
+	After time.Duration
+	Ctx   Context
+}
+
+type RegionEvent struct {
+	ParallelState string
+	Region        string
+	Ctx           Context
+}
+
+type LimitAlertEvent struct {
+	Active []string
+	Limit  int
+	Ctx    Context
+}
+
 // NewTestObserver creates a new test observer
 func NewTestObserver() *TestObserver {
 	return &TestObserver{
@@ -73,6 +95,8 @@ func NewTestObserver() *TestObserver {
 		Started:      make([]ContextEvent, 0),
 		Stopped:      make([]ContextEvent, 0),
 		Guards:       make([]GuardEvent, 0),
+		Timers:       make([]TimerEvent, 0),
+		Regions:      make([]RegionEvent, 0),
 	}
 }
 
@@ -132,6 +156,25 @@ func (o *TestObserver) OnMachineStopped(ctx Context) {
 	o.Stopped = append(o.Stopped, ContextEvent{Ctx: ctx})
 }
 
+// TimerObserver interface implementation
+func (o *TestObserver) OnTimerFired(state string, after time.Duration, ctx Context) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.Timers = append(o.Timers, TimerEvent{State: state, After: after, Ctx: ctx})
+}
+
+func (o *TestObserver) OnRegionCompleted(parallelState string, region string, ctx Context) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.Regions = append(o.Regions, RegionEvent{ParallelState: parallelState, Region: region, Ctx: ctx})
+}
+
+func (o *TestObserver) OnActiveStateLimitExceeded(active []string, limit int, ctx Context) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.LimitAlerts = append(o.LimitAlerts, LimitAlertEvent{Active: active, Limit: limit, Ctx: ctx})
+}
+
 // Helper methods for test assertions
 func (o *TestObserver) Reset() {
 	o.mutex.Lock()
@@ -145,6 +188,9 @@ func (o *TestObserver) Reset() {
 	o.Started = nil
 	o.Stopped = nil
 	o.Guards = nil
+	o.Timers = nil
+	o.Regions = nil
+	o.LimitAlerts = nil
 }
 
 func (o *TestObserver) TransitionCount() int {
@@ -165,6 +211,12 @@ func (o *TestObserver) StateExitCount() int {
 	return len(o.StateExits)
 }
 
+func (o *TestObserver) TimerCount() int {
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+	return len(o.Timers)
+}
+
 func (o *TestObserver) LastTransition() *TransitionEvent {
 	o.mutex.RLock()
 	defer o.mutex.RUnlock()
@@ -0,0 +1,119 @@
+package fluo
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// timedEventPrefix prefixes the internal event names used by timed transitions
+const timedEventPrefix = "__after_"
+
+// stateTimer is an armed timer for a single timed transition
+type stateTimer struct {
+	stateID   string
+	eventName string
+	after     time.Duration
+	deadline  time.Time
+	timeout   bool // Armed for the state's timeout rather than an After transition
+	timer     *time.Timer
+}
+
+// timedEventName returns the internal event name fired when a timed transition expires
+func timedEventName(stateID string, after time.Duration) string {
+	return fmt.Sprintf("%s%s_%s", timedEventPrefix, stateID, after)
+}
+
+// armTimers starts a timer for every timed transition leaving the given state.
+// The caller must hold the machine mutex.
+func (sm *StateMachine) armTimers(stateID string) {
+	sm.cancelTimers(stateID)
+
+	for _, transition := range sm.transitions[stateID] {
+		if transition.After <= 0 {
+			continue
+		}
+
+		st := &stateTimer{
+			stateID:   stateID,
+			eventName: transition.EventName,
+			after:     transition.After,
+			deadline:  time.Now().Add(transition.After),
+			timeout:   transition.Timeout,
+		}
+		st.timer = time.AfterFunc(transition.After, func() {
+			sm.fireTimer(st)
+		})
+		sm.timers[stateID] = append(sm.timers[stateID], st)
+	}
+}
+
+// cancelTimers stops all armed timers owned by the given state.
+// The caller must hold the machine mutex.
+func (sm *StateMachine) cancelTimers(stateID string) {
+	for _, st := range sm.timers[stateID] {
+		st.timer.Stop()
+	}
+	delete(sm.timers, stateID)
+}
+
+// stopAllTimers stops every armed timer of the machine and cancels its
+// scheduled events. The caller must hold the machine mutex.
+func (sm *StateMachine) stopAllTimers() {
+	for stateID := range sm.timers {
+		sm.cancelTimers(stateID)
+	}
+	sm.schedule.cancelAll()
+}
+
+// fireTimer dispatches the timed event of an expired timer if it is still armed
+func (sm *StateMachine) fireTimer(st *stateTimer) {
+	if sm.eventLoop != nil && sm.eventLoop.enqueue(&queuedEvent{ctx: context.Background(), timer: st}) {
+		return
+	}
+
+	defer sm.lockForEvent(st.eventName)()
+
+	sm.fireTimerLocked(st)
+}
+
+// fireTimerLocked takes the timed transition of an armed timer.
+// The caller must hold the machine mutex.
+func (sm *StateMachine) fireTimerLocked(st *stateTimer) *EventResult {
+	if !sm.removeTimer(st) || sm.machineState != MachineStateStarted {
+		return nil
+	}
+
+	sm.observers.NotifyTimerFired(st.stateID, st.after, sm.context)
+	return sm.processEvent(context.Background(), st.eventName, nil)
+}
+
+// removeTimer removes an armed timer and reports whether it was still armed
+func (sm *StateMachine) removeTimer(st *stateTimer) bool {
+	timers := sm.timers[st.stateID]
+	for i, armed := range timers {
+		if armed == st {
+			sm.timers[st.stateID] = append(timers[:i], timers[i+1:]...)
+			if len(sm.timers[st.stateID]) == 0 {
+				delete(sm.timers, st.stateID)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// TimeoutRemaining returns the time left before an active state exceeds its
+// timeout and takes its OnTimeout transition. It reports false when the state
+// is not active or declares no timeout.
+func (sm *StateMachine) TimeoutRemaining(stateID string) (time.Duration, bool) {
+	sm.mutex.RLock()
+	defer sm.mutex.RUnlock()
+
+	for _, st := range sm.timers[stateID] {
+		if st.timeout {
+			return max(time.Until(st.deadline), 0), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,227 @@
+package fluo
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func createTimedMachine(after time.Duration) Machine {
+	definition := NewMachine().
+		State("green").Initial().
+		To("yellow").On("change").
+		State("yellow").
+		After(after).To("red").
+		To("green").On("reset").
+		State("red").
+		Build()
+
+	return definition.CreateInstance()
+}
+
+func waitForState(t *testing.T, machine Machine, expected string, timeout time.Duration) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if machine.CurrentState() == expected {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("Expected state %s within %v, got %s", expected, timeout, machine.CurrentState())
+}
+
+func TestTimedTransition_FiresAfterDuration(t *testing.T) {
+	machine := createTimedMachine(20 * time.Millisecond)
+	observer := NewTestObserver()
+	machine.AddObserver(observer)
+
+	_ = machine.Start()
+	_ = machine.HandleEvent("change", nil)
+	AssertState(t, machine, "yellow")
+
+	waitForState(t, machine, "red", time.Second)
+
+	if observer.TimerCount() != 1 {
+		t.Fatalf("Expected 1 timer notification, got %d", observer.TimerCount())
+	}
+	if observer.Timers[0].State != "yellow" {
+		t.Errorf("Expected timer from yellow, got %s", observer.Timers[0].State)
+	}
+	if observer.Timers[0].After != 20*time.Millisecond {
+		t.Errorf("Expected timer duration 20ms, got %v", observer.Timers[0].After)
+	}
+
+	last := observer.LastTransition()
+	if last == nil || last.From != "yellow" || last.To != "red" {
+		t.Errorf("Expected yellow -> red transition, got %+v", last)
+	}
+}
+
+func TestTimedTransition_CancelledOnExit(t *testing.T) {
+	machine := createTimedMachine(30 * time.Millisecond)
+	observer := NewTestObserver()
+	machine.AddObserver(observer)
+
+	_ = machine.Start()
+	_ = machine.HandleEvent("change", nil)
+	_ = machine.HandleEvent("reset", nil)
+	AssertState(t, machine, "green")
+
+	time.Sleep(60 * time.Millisecond)
+
+	AssertState(t, machine, "green")
+	if observer.TimerCount() != 0 {
+		t.Errorf("Expected no timer notifications after exit, got %d", observer.TimerCount())
+	}
+}
+
+func TestTimedTransition_RearmedOnReentry(t *testing.T) {
+	machine := createTimedMachine(30 * time.Millisecond)
+
+	_ = machine.Start()
+	_ = machine.HandleEvent("change", nil)
+	time.Sleep(15 * time.Millisecond)
+	_ = machine.HandleEvent("reset", nil)
+	_ = machine.HandleEvent("change", nil)
+
+	time.Sleep(20 * time.Millisecond)
+	AssertState(t, machine, "yellow")
+
+	waitForState(t, machine, "red", time.Second)
+}
+
+func TestTimedTransition_CancelledOnStop(t *testing.T) {
+	machine := createTimedMachine(20 * time.Millisecond)
+
+	_ = machine.Start()
+	_ = machine.HandleEvent("change", nil)
+	_ = machine.Stop()
+
+	time.Sleep(40 * time.Millisecond)
+	AssertState(t, machine, "yellow")
+}
+
+func TestTimedTransition_InitialState(t *testing.T) {
+	definition := NewMachine().
+		State("booting").Initial().
+		After(10 * time.Millisecond).To("ready").
+		State("ready").
+		Build()
+
+	machine := definition.CreateInstance()
+	_ = machine.Start()
+
+	waitForState(t, machine, "ready", time.Second)
+}
+
+func TestTimedTransition_Guarded(t *testing.T) {
+	allow := false
+	definition := NewMachine().
+		State("waiting").Initial().
+		After(10 * time.Millisecond).To("done").When(func(ctx Context) bool { return allow }).
+		State("done").
+		Build()
+
+	machine := definition.CreateInstance()
+	_ = machine.Start()
+
+	time.Sleep(30 * time.Millisecond)
+	AssertState(t, machine, "waiting")
+}
+
+func buildTimeoutDefinition(timeout time.Duration) MachineDefinition {
+	builder := NewMachine()
+	builder.State("idle").Initial().
+		To("waiting").On("request")
+	builder.State("waiting").Timeout(timeout).
+		To("done").On("reply").OnTimeout("expired")
+	builder.State("done")
+	builder.State("expired")
+	return builder.Build()
+}
+
+func TestStateTimeout_FiresOnTimeoutTransition(t *testing.T) {
+	machine := buildTimeoutDefinition(20 * time.Millisecond).CreateInstance()
+	_ = machine.Start()
+	defer func() { _ = machine.Stop() }()
+
+	if _, ok := machine.TimeoutRemaining("waiting"); ok {
+		t.Error("Expected no remaining time before the state is entered")
+	}
+	machine.HandleEvent("request", nil)
+	remaining, ok := machine.TimeoutRemaining("waiting")
+	if !ok || remaining <= 0 || remaining > 20*time.Millisecond {
+		t.Errorf("Expected remaining time within the timeout, got %v, %v", remaining, ok)
+	}
+
+	waitForState(t, machine, "expired", time.Second)
+	if _, ok := machine.TimeoutRemaining("waiting"); ok {
+		t.Error("Expected no remaining time after the timeout fired")
+	}
+}
+
+func TestStateTimeout_CancelledOnExit(t *testing.T) {
+	machine := buildTimeoutDefinition(30 * time.Millisecond).CreateInstance()
+	_ = machine.Start()
+	defer func() { _ = machine.Stop() }()
+
+	machine.HandleEvent("request", nil)
+	machine.HandleEvent("reply", nil)
+	if _, ok := machine.TimeoutRemaining("waiting"); ok {
+		t.Error("Expected exiting the state to cancel its timeout")
+	}
+	time.Sleep(60 * time.Millisecond)
+	AssertState(t, machine, "done")
+}
+
+func TestStateTimeout_RequiresDurationAndTarget(t *testing.T) {
+	builder := NewMachine()
+	builder.State("idle").Initial().
+		To("busy").On("start").OnTimeout("idle")
+	builder.State("busy").Timeout(time.Second)
+	_, err := builder.BuildE()
+	if err == nil || !strings.Contains(err.Error(), "state 'idle' declares OnTimeout without a positive Timeout") ||
+		!strings.Contains(err.Error(), "state 'busy' declares a Timeout without an OnTimeout target") {
+		t.Errorf("Expected both timeout declarations to be rejected, got %v", err)
+	}
+}
+
+func TestStateTimeout_RelativeToComposite(t *testing.T) {
+	builder := NewMachine()
+	builder.State("idle").Initial().
+		To("session").On("login")
+	builder.Composite("session", func(c CompositeScope) {
+		c.State("waiting").Initial().Timeout(20 * time.Millisecond).
+			To("done").On("reply").OnTimeout("expired")
+		c.State("done")
+		c.State("expired")
+	})
+	definition, err := builder.BuildE()
+	if err != nil {
+		t.Fatalf("BuildE failed: %v", err)
+	}
+	machine := definition.CreateInstance()
+	_ = machine.Start()
+	defer func() { _ = machine.Stop() }()
+
+	machine.HandleEvent("login", nil)
+	waitForState(t, machine, "session.expired", time.Second)
+}
+
+func TestStateTimeout_Loader(t *testing.T) {
+	definition, err := NewRegistry().LoadDefinitionJSON([]byte(`{
+		"initial": "waiting",
+		"states": [
+			{"id": "waiting", "timeout": "20ms", "onTimeout": "expired"},
+			{"id": "expired"}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	machine := definition.CreateInstance()
+	_ = machine.Start()
+	defer func() { _ = machine.Stop() }()
+	waitForState(t, machine, "expired", time.Second)
+}
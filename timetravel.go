@@ -0,0 +1,97 @@
+package fluo
+
+import "fmt"
+
+// timeline records the events an instance accepted together with a checkpoint
+// of the instance after each of them. checkpoints[0] is the instance as started.
+type timeline struct {
+	entries     []JournalEntry
+	checkpoints []checkpoint
+}
+
+// checkpoint is the configuration and persistent context of an instance at one
+// point of its timeline
+type checkpoint struct {
+	configuration ActiveConfiguration
+	context       map[string]any
+}
+
+// WithTimeTravel records every event the instance accepts, in the same form as
+// WithEventLog, along with a checkpoint of the resulting configuration and
+// context, so History and RewindTo can be used to inspect how the instance
+// reached its current state. Checkpoints copy the context map but not the
+// values it holds, and they are kept in memory for the lifetime of the
+// instance, so the option is meant for tests and debugging.
+func WithTimeTravel() MachineOption {
+	return func(sm *StateMachine) {
+		sm.timeline = &timeline{}
+	}
+}
+
+// start discards the recorded timeline and checkpoints the started instance.
+// The caller must hold the machine mutex.
+func (t *timeline) start(sm *StateMachine) {
+	t.entries = nil
+	t.checkpoints = []checkpoint{sm.checkpoint()}
+}
+
+// record appends an accepted event and checkpoints the instance after it.
+// The caller must hold the machine mutex.
+func (t *timeline) record(sm *StateMachine, entry JournalEntry) {
+	t.entries = append(t.entries, entry)
+	t.checkpoints = append(t.checkpoints, sm.checkpoint())
+}
+
+// checkpoint captures the configuration and persistent context of the instance.
+// The caller must hold the machine mutex.
+func (sm *StateMachine) checkpoint() checkpoint {
+	return checkpoint{
+		configuration: sm.captureConfiguration(),
+		context:       sm.context.GetAll(),
+	}
+}
+
+// History returns the events the instance accepted since it was started, in
+// order, each with the transition it took. It returns nil unless the instance
+// was created with WithTimeTravel.
+func (sm *StateMachine) History() []JournalEntry {
+	sm.mutex.RLock()
+	defer sm.mutex.RUnlock()
+
+	if sm.timeline == nil {
+		return nil
+	}
+	return append([]JournalEntry(nil), sm.timeline.entries...)
+}
+
+// RewindTo puts the instance back into the configuration and context it had
+// after the first index events of its History; RewindTo(0) returns it to the
+// state it started in. Later entries are discarded from the history, no entry
+// or exit actions are executed and the event log, if any, is left untouched.
+func (sm *StateMachine) RewindTo(index int) error {
+	sm.mutex.Lock()
+	defer sm.unlock()
+
+	if sm.timeline == nil {
+		return NewMachineError(ErrCodeInvalidConfiguration, "RewindTo", "time travel is not enabled; create the instance with WithTimeTravel")
+	}
+	if sm.machineState != MachineStateStarted {
+		return NewMachineNotStartedError("RewindTo")
+	}
+	if index < 0 || index > len(sm.timeline.entries) {
+		return NewMachineError(ErrCodeInvalidState, "RewindTo",
+			fmt.Sprintf("index %d is outside the history of %d events", index, len(sm.timeline.entries)))
+	}
+
+	target := sm.timeline.checkpoints[index]
+	if err := sm.setConfiguration(target.configuration); err != nil {
+		return err
+	}
+	if smCtx, ok := sm.context.(*StateMachineContext); ok {
+		smCtx.replaceData(target.context)
+	}
+
+	sm.timeline.entries = sm.timeline.entries[:index]
+	sm.timeline.checkpoints = sm.timeline.checkpoints[:index+1]
+	return nil
+}
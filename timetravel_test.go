@@ -0,0 +1,72 @@
+package fluo
+
+import "testing"
+
+func TestTimeTravel_History(t *testing.T) {
+	machine := buildEventLogDefinition().CreateInstance(WithTimeTravel())
+	_ = machine.Start()
+
+	machine.HandleEvent("submit", "v1")
+	machine.HandleEvent("unknown", nil)
+	machine.HandleEvent("reject", nil)
+
+	history := machine.History()
+	if len(history) != 2 {
+		t.Fatalf("Expected 2 accepted events, got %+v", history)
+	}
+	if history[0].EventName != "submit" || history[0].EventData != "v1" || history[0].CurrentState != "review" {
+		t.Errorf("Unexpected first record: %+v", history[0])
+	}
+	if history[1].PreviousState != "review" || history[1].CurrentState != "draft" {
+		t.Errorf("Unexpected second record: %+v", history[1])
+	}
+}
+
+func TestTimeTravel_RewindTo(t *testing.T) {
+	machine := buildEventLogDefinition().CreateInstance(WithTimeTravel())
+	machine.Context().Set("owner", "alice")
+	_ = machine.Start()
+
+	machine.HandleEvent("submit", nil)
+	machine.HandleEvent("approve", nil)
+
+	if err := machine.RewindTo(1); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	AssertState(t, machine, "review")
+	if len(machine.History()) != 1 {
+		t.Errorf("Expected later records to be discarded, got %+v", machine.History())
+	}
+
+	if err := machine.RewindTo(0); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	AssertState(t, machine, "draft")
+	if _, ok := machine.Context().Get("submitted"); ok {
+		t.Error("Expected context written after the checkpoint to be removed")
+	}
+	if owner, _ := machine.Context().Get("owner"); owner != "alice" {
+		t.Errorf("Expected context set before starting to be kept, got %v", owner)
+	}
+
+	AssertEventProcessed(t, machine.HandleEvent("submit", nil), true)
+	AssertState(t, machine, "review")
+}
+
+func TestTimeTravel_RewindToErrors(t *testing.T) {
+	machine := buildEventLogDefinition().CreateInstance()
+	_ = machine.Start()
+	if err := machine.RewindTo(0); GetErrorCode(err) != ErrCodeInvalidConfiguration {
+		t.Errorf("Expected an error without time travel, got %v", err)
+	}
+	if machine.History() != nil {
+		t.Error("Expected no history without time travel")
+	}
+
+	machine = buildEventLogDefinition().CreateInstance(WithTimeTravel())
+	_ = machine.Start()
+	machine.HandleEvent("submit", nil)
+	if err := machine.RewindTo(2); GetErrorCode(err) != ErrCodeInvalidState {
+		t.Errorf("Expected an out of range error, got %v", err)
+	}
+}
@@ -1,5 +1,10 @@
 package fluo
 
+import (
+	"fmt"
+	"time"
+)
+
 // Transition represents a state transition
 type Transition struct {
 	SourceState string
@@ -7,6 +12,49 @@ type Transition struct {
 	EventName   string
 	Guard       GuardFunc
 	Action      ActionFunc
+
+	// GuardName is the registry name of the guard, used by exports
+	GuardName string
+
+	// ActionName is the registry name of the action, used by exports
+	ActionName string
+
+	// Internal transitions run their action without exiting or re-entering the source state
+	Internal bool
+
+	// Local transitions into a substate of the source leave the source active
+	// instead of exiting and re-entering it
+	Local bool
+
+	// After makes this a timed transition fired automatically once the source
+	// state has been active for the given duration
+	After time.Duration
+
+	// ErrorState is entered instead of the target when the transition action
+	// fails, or after the target when one of its entry actions fails
+	ErrorState string
+
+	// Timeout marks the timed transition taken when the source state exceeds
+	// the timeout declared with StateBuilder.Timeout
+	Timeout bool
+
+	// GuardTimeout bounds the evaluation time of the guard, overriding the
+	// machine-wide guard timeout
+	GuardTimeout time.Duration
+
+	// Priority ranks transitions from the same state for the same event;
+	// higher values win under the HighestPriority and ErrorOnAmbiguity policies
+	Priority int
+
+	// Flag gates the transition behind a named feature flag resolved by the
+	// machine's FlagProvider
+	Flag string
+
+	// Description documents the transition and its guard for generated documentation
+	Description string
+
+	// Tags label the transition for audits and exports
+	Tags []string
 }
 
 // NewTransition creates a new transition
@@ -29,3 +77,43 @@ func (t *Transition) WithAction(action ActionFunc) *Transition {
 	t.Action = action
 	return t
 }
+
+// guardIdentity names the guard of the transition by its registered name, or
+// by the transition for an anonymous guard
+func (t Transition) guardIdentity() string {
+	if t.GuardName != "" {
+		return t.GuardName
+	}
+	return t.describe()
+}
+
+// actionIdentity names the action of the transition by its registered name,
+// or by the transition for an anonymous action
+func (t Transition) actionIdentity() string {
+	if t.ActionName != "" {
+		return t.ActionName
+	}
+	return t.describe()
+}
+
+// actionLabel names the action of the transition for observers: the name it
+// was declared with, or else the kind of transition running it
+func (t Transition) actionLabel(kind string) string {
+	if t.ActionName != "" {
+		return t.ActionName
+	}
+	return kind
+}
+
+// actionFailure describes a failed action of the transition for rejections
+func (t Transition) actionFailure(err error) string {
+	if t.ActionName != "" {
+		return fmt.Sprintf("transition action '%s' failed: %v", t.ActionName, err)
+	}
+	return fmt.Sprintf("transition action failed: %v", err)
+}
+
+// describe renders the transition as "<source>-><target> on <event>"
+func (t Transition) describe() string {
+	return fmt.Sprintf("%s->%s on %s", t.SourceState, t.TargetState, t.EventName)
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"runtime"
+	"slices"
 	"strings"
 	"sync"
 	"testing"
@@ -948,33 +949,29 @@ func TestMachine_StartStopDuringTransitions(t *testing.T) {
 	_ = machine.Start()
 
 	// Start transition in goroutine
+	handled := make(chan *EventResult, 1)
 	go func() {
-		result := machine.HandleEvent("start", nil)
-		if result.Processed {
-			t.Log("Transition completed successfully")
-		}
+		handled <- machine.HandleEvent("start", nil)
 	}()
 
-	// Wait a bit then try to stop machine during transition
+	// Stop during the transition waits for it to finish
 	time.Sleep(10 * time.Millisecond)
-	stopErr := machine.Stop()
-	if stopErr != nil {
-		t.Logf("Machine stop during transition: %v", stopErr)
+	if err := machine.Stop(); err != nil {
+		t.Fatalf("Machine stop during transition: %v", err)
 	}
-
-	// Wait for transition to complete
-	transitionInProgress.Wait()
-
-	// Try to restart machine
-	startErr := machine.Start()
-	if startErr != nil {
-		t.Logf("Machine restart after interruption: %v", startErr)
+	if completed, _ := machine.Context().Get("action_completed"); completed != true {
+		t.Error("Expected Stop to return after the transition action completed")
+	}
+	if result := <-handled; !result.Processed {
+		t.Errorf("Expected the in-flight transition to complete, got %v", result.RejectionReason)
 	}
+	transitionInProgress.Wait()
 
-	// Verify machine state
-	if machine.CurrentState() != "processing" {
-		t.Logf("Final state: %s", machine.CurrentState())
+	// Restarting resumes from the retained configuration
+	if err := machine.Start(); err != nil {
+		t.Fatalf("Machine restart after interruption: %v", err)
 	}
+	AssertState(t, machine, "processing")
 }
 
 // Test for reset during complex parallel state execution
@@ -1035,10 +1032,16 @@ func TestMachine_ResetDuringParallelExecution(t *testing.T) {
 		t.Errorf("Expected initial state after reset, got %s", machine.CurrentState())
 	}
 
-	// Verify context is cleaned up
+	// Verify context and parallel runtime state are cleaned up
 	if _, ok := machine.Context().Get("region1_completed"); ok {
 		t.Error("Context should be cleaned up after reset")
 	}
+	if active := machine.GetActiveStates(); !slices.Equal(active, []string{"initial"}) {
+		t.Errorf("Expected only the initial state to remain active after reset, got %v", active)
+	}
+	if state := machine.RegionState("parallel.region1"); state != "" {
+		t.Errorf("Expected no region state after reset, got %q", state)
+	}
 }
 
 // Test for multiple rapid start/stop cycles
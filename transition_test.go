@@ -2,6 +2,7 @@ package fluo
 
 import (
 	"errors"
+	"strings"
 	"testing"
 )
 
@@ -536,3 +537,216 @@ func TestTransition_ErrorRecovery(t *testing.T) {
 		t.Errorf("Expected at least 3 transitions, got %d", observer.TransitionCount())
 	}
 }
+
+func TestInternalTransition_NoExitOrEntry(t *testing.T) {
+	entries, exits, actions := 0, 0, 0
+	definition := NewMachine().
+		State("active").Initial().
+		OnEntry(func(ctx Context) error {
+			entries++
+			return nil
+		}).
+		OnExit(func(ctx Context) error {
+			exits++
+			return nil
+		}).
+		ToSelf().On("tick").Internal().Do(func(ctx Context) error {
+		actions++
+		return nil
+	}).
+		To("done").On("finish").
+		State("done").
+		Build()
+
+	machine := definition.CreateInstance()
+	observer := NewTestObserver()
+	machine.AddObserver(observer)
+	_ = machine.Start()
+	observer.Reset()
+
+	result := machine.HandleEvent("tick", nil)
+	if !result.Success() {
+		t.Fatalf("Expected internal transition to succeed, got %+v", result)
+	}
+	if result.StateChanged {
+		t.Error("Expected internal transition not to change state")
+	}
+
+	AssertState(t, machine, "active")
+	if actions != 1 {
+		t.Errorf("Expected action to run once, got %d", actions)
+	}
+	if entries != 1 || exits != 0 {
+		t.Errorf("Expected no exit/entry actions, got %d entries and %d exits", entries, exits)
+	}
+	AssertObserverCalled(t, observer, 0, 0, 0)
+
+	result = machine.HandleEvent("finish", nil)
+	if !result.Success() || exits != 1 {
+		t.Errorf("Expected external transition to still exit the state, got exits=%d", exits)
+	}
+}
+
+func TestInternalTransition_RejectsOtherTarget(t *testing.T) {
+	_, err := NewMachine().
+		State("active").Initial().
+		To("done").On("tick").Internal().
+		State("done").
+		BuildE()
+
+	var configErr *ConfigurationError
+	if !errors.As(err, &configErr) {
+		t.Fatalf("Expected a configuration error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "active->done on tick") {
+		t.Errorf("Expected the error to name the transition, got %v", err)
+	}
+
+	if _, err := NewMachine().
+		State("active").Initial().
+		To("active").On("tick").Internal().
+		BuildE(); err != nil {
+		t.Errorf("Expected an internal transition to its source to build, got %v", err)
+	}
+}
+
+func TestInternalTransition_ActionError(t *testing.T) {
+	definition := NewMachine().
+		State("active").Initial().
+		ToSelf().On("tick").Internal().Do(func(ctx Context) error {
+		return errors.New("boom")
+	}).
+		Build()
+
+	machine := definition.CreateInstance()
+	_ = machine.Start()
+
+	result := machine.HandleEvent("tick", nil)
+	if result.Processed || result.Error == nil {
+		t.Errorf("Expected failed internal transition, got %+v", result)
+	}
+	AssertState(t, machine, "active")
+}
+
+func buildPanelMachine(local bool) (MachineDefinition, *[]string) {
+	var log []string
+	record := func(entry string) ActionFunc {
+		return func(ctx Context) error {
+			log = append(log, entry)
+			return nil
+		}
+	}
+
+	builder := NewMachine()
+	builder.State("panel").Initial().
+		OnEntry(record("enter panel")).
+		OnExit(record("exit panel"))
+	transition := builder.State("panel").To("panel.details").On("expand")
+	if local {
+		transition.Local()
+	}
+	builder.State("panel.details").
+		OnEntry(record("enter details"))
+	return builder.Build(), &log
+}
+
+func TestTransition_ExternalIntoSubstateReentersSource(t *testing.T) {
+	definition, log := buildPanelMachine(false)
+	machine := definition.CreateInstance()
+	_ = machine.Start()
+	*log = nil
+
+	machine.HandleEvent("expand", nil)
+	AssertState(t, machine, "panel.details")
+
+	want := []string{"exit panel", "enter panel", "enter details"}
+	if strings.Join(*log, ", ") != strings.Join(want, ", ") {
+		t.Errorf("Expected %v, got %v", want, *log)
+	}
+}
+
+func TestTransition_LocalIntoSubstateKeepsSource(t *testing.T) {
+	definition, log := buildPanelMachine(true)
+	machine := definition.CreateInstance()
+	observer := NewTestObserver()
+	machine.AddObserver(observer)
+	_ = machine.Start()
+	*log = nil
+
+	machine.HandleEvent("expand", nil)
+	AssertState(t, machine, "panel.details")
+
+	want := []string{"enter details"}
+	if strings.Join(*log, ", ") != strings.Join(want, ", ") {
+		t.Errorf("Expected %v, got %v", want, *log)
+	}
+	if observer.StateExitCount() != 0 {
+		t.Errorf("Expected no state exits, got %d", observer.StateExitCount())
+	}
+}
+
+func buildCompositePanelMachine(local bool) (MachineDefinition, *[]string) {
+	var log []string
+	record := func(entry string) ActionFunc {
+		return func(ctx Context) error {
+			log = append(log, entry)
+			return nil
+		}
+	}
+
+	builder := NewMachine()
+	builder.State("closed").Initial().
+		To("panel").On("open")
+	panel := builder.CompositeState("panel").
+		OnEntry(record("enter panel")).
+		OnExit(record("exit panel"))
+	panel.State("summary").Initial().
+		OnEntry(record("enter summary")).
+		OnExit(record("exit summary"))
+	panel.State("details").
+		OnEntry(record("enter details"))
+	transition := panel.To("panel.details").On("expand")
+	if local {
+		transition.Local()
+	}
+	panel.To("closed").On("close")
+	return builder.Build(), &log
+}
+
+func TestTransition_CompositeBuilderActions(t *testing.T) {
+	definition, log := buildCompositePanelMachine(false)
+	machine := definition.CreateInstance()
+	_ = machine.Start()
+
+	machine.HandleEvent("open", nil)
+	AssertState(t, machine, "panel.summary")
+	machine.HandleEvent("expand", nil)
+	AssertState(t, machine, "panel.details")
+	machine.HandleEvent("close", nil)
+	AssertState(t, machine, "closed")
+
+	want := []string{
+		"enter panel", "enter summary",
+		"exit summary", "exit panel", "enter panel", "enter details",
+		"exit panel",
+	}
+	if strings.Join(*log, ", ") != strings.Join(want, ", ") {
+		t.Errorf("Expected %v, got %v", want, *log)
+	}
+}
+
+func TestTransition_CompositeBuilderLocalKeepsSource(t *testing.T) {
+	definition, log := buildCompositePanelMachine(true)
+	machine := definition.CreateInstance()
+	_ = machine.Start()
+	machine.HandleEvent("open", nil)
+	*log = nil
+
+	machine.HandleEvent("expand", nil)
+	AssertState(t, machine, "panel.details")
+
+	want := []string{"exit summary", "enter details"}
+	if strings.Join(*log, ", ") != strings.Join(want, ", ") {
+		t.Errorf("Expected %v, got %v", want, *log)
+	}
+}
@@ -0,0 +1,124 @@
+package fluo
+
+import (
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Configuration is the active configuration of a machine as a tree: every
+// active state with the active states nested in it, and every parallel state
+// with its regions. Its order is stable, so it can be compared directly.
+type Configuration struct {
+	// States are the active top-level states, sorted by ID
+	States []ConfigurationNode `json:"states"`
+}
+
+// ConfigurationNode is an active state and the active states nested in it
+type ConfigurationNode struct {
+	// State is the ID of the state
+	State string `json:"state"`
+	// Children are the active substates of a composite state, sorted by ID
+	Children []ConfigurationNode `json:"children,omitempty"`
+	// Regions are the regions of a parallel state, in declaration order
+	Regions []ConfigurationRegion `json:"regions,omitempty"`
+}
+
+// ConfigurationRegion is a region of an active parallel state
+type ConfigurationRegion struct {
+	// Region is the path "<parallel state>.<region>" of the region
+	Region string `json:"region"`
+	// States are the active states of the region, sorted by ID, and empty
+	// for a region without a current state
+	States []ConfigurationNode `json:"states,omitempty"`
+}
+
+// GetConfiguration returns the active configuration as a tree of parallel
+// states, their regions and the states active in them, down to the leaves
+func (sm *StateMachine) GetConfiguration() Configuration {
+	within := sm.loadView().within()
+
+	children := make(map[string][]string)
+	var roots []string
+	for _, stateID := range slices.Sorted(maps.Keys(within)) {
+		if _, exists := sm.states[stateID]; !exists {
+			// Region paths become region nodes of their parallel state
+			continue
+		}
+		if parent := sm.enclosingState(stateID); parent != "" {
+			children[parent] = append(children[parent], stateID)
+		} else {
+			roots = append(roots, stateID)
+		}
+	}
+
+	var node func(stateID string) ConfigurationNode
+	nodes := func(stateIDs []string) []ConfigurationNode {
+		var result []ConfigurationNode
+		for _, stateID := range stateIDs {
+			result = append(result, node(stateID))
+		}
+		return result
+	}
+	node = func(stateID string) ConfigurationNode {
+		n := ConfigurationNode{State: stateID}
+		parallelState, ok := sm.states[stateID].(ParallelState)
+		if !ok {
+			n.Children = nodes(children[stateID])
+			return n
+		}
+		for _, region := range parallelState.Regions() {
+			var regionStates []string
+			for _, child := range children[stateID] {
+				if sm.findRegionForState(child) == region {
+					regionStates = append(regionStates, child)
+				}
+			}
+			n.Regions = append(n.Regions, ConfigurationRegion{
+				Region: regionHistoryKey(stateID, region),
+				States: nodes(regionStates),
+			})
+		}
+		return n
+	}
+
+	return Configuration{States: nodes(roots)}
+}
+
+// Leaves returns the innermost active states, in tree order
+func (c Configuration) Leaves() []string {
+	var leaves []string
+	var walk func(nodes []ConfigurationNode)
+	walk = func(nodes []ConfigurationNode) {
+		for _, n := range nodes {
+			if len(n.Children) == 0 && len(n.Regions) == 0 {
+				leaves = append(leaves, n.State)
+			}
+			walk(n.Children)
+			for _, region := range n.Regions {
+				walk(region.States)
+			}
+		}
+	}
+	walk(c.States)
+	return leaves
+}
+
+// String renders the configuration as an indented tree, one state or
+// bracketed region per line
+func (c Configuration) String() string {
+	var sb strings.Builder
+	var walk func(nodes []ConfigurationNode, depth int)
+	walk = func(nodes []ConfigurationNode, depth int) {
+		for _, n := range nodes {
+			sb.WriteString(strings.Repeat("  ", depth) + n.State + "\n")
+			walk(n.Children, depth+1)
+			for _, region := range n.Regions {
+				sb.WriteString(strings.Repeat("  ", depth+1) + "[" + region.Region + "]\n")
+				walk(region.States, depth+2)
+			}
+		}
+	}
+	walk(c.States, 0)
+	return sb.String()
+}
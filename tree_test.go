@@ -0,0 +1,74 @@
+package fluo
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestGetConfiguration(t *testing.T) {
+	builder := NewMachine()
+	builder.State("outside").Initial().
+		To("site").On("enter").
+		To("home").On("arrive")
+	builder.Composite("site", func(c CompositeScope) {
+		c.State("idle").Initial()
+	})
+	builder.Parallel("home", func(p ParallelScope) {
+		p.Region("security", func(r RegionScope) {
+			r.State("off").Initial().
+				To("armed").On("arm")
+			r.State("armed")
+		})
+		p.Region("lighting", func(r RegionScope) {
+			r.State("dim").Initial()
+		})
+	})
+	definition := builder.Build()
+	machine := definition.CreateInstance()
+	_ = machine.Start()
+	machine.HandleEvent("enter", nil)
+
+	want := "site\n  site.idle\n"
+	if got := machine.GetConfiguration().String(); got != want {
+		t.Errorf("Expected configuration\n%s\ngot\n%s", want, got)
+	}
+
+	machine = definition.CreateInstance()
+	_ = machine.Start()
+	machine.HandleEvent("arrive", nil)
+	machine.HandleEvent("arm", nil)
+	configuration := machine.GetConfiguration()
+	want = "home\n  [home.security]\n    home.security.armed\n  [home.lighting]\n    home.lighting.dim\n"
+	if got := configuration.String(); got != want {
+		t.Errorf("Expected configuration\n%s\ngot\n%s", want, got)
+	}
+	if leaves := configuration.Leaves(); !slices.Equal(leaves, []string{"home.security.armed", "home.lighting.dim"}) {
+		t.Errorf("Unexpected leaves %v", leaves)
+	}
+	if regions := configuration.States[0].Regions; len(regions) != 2 || regions[0].Region != "home.security" {
+		t.Errorf("Expected the regions in declaration order, got %+v", regions)
+	}
+}
+
+func TestGetActiveStates_Ordered(t *testing.T) {
+	builder := NewMachine()
+	builder.State("start").Initial().
+		To("split").On("fork")
+	builder.Fork("split").To("scan", "archive", "upload")
+	builder.State("scan")
+	builder.State("archive")
+	builder.State("upload")
+	machine := builder.Build().CreateInstance()
+	_ = machine.Start()
+	machine.HandleEvent("fork", nil)
+
+	first := machine.GetActiveStates()
+	for range 10 {
+		if active := machine.GetActiveStates(); !slices.Equal(active, first) {
+			t.Fatalf("Expected a stable order, got %v and %v", first, active)
+		}
+	}
+	if !slices.IsSorted(first[1:]) {
+		t.Errorf("Expected the states after the current one sorted, got %v", first)
+	}
+}
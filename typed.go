@@ -0,0 +1,107 @@
+package fluo
+
+// GetAs retrieves a context value of type T, reporting false when the key
+// is missing or holds a value of a different type
+func GetAs[T any](ctx Context, key string) (T, bool) {
+	var zero T
+	if ctx == nil {
+		return zero, false
+	}
+	value, exists := ctx.Get(key)
+	if !exists {
+		return zero, false
+	}
+	typed, ok := value.(T)
+	if !ok {
+		return zero, false
+	}
+	return typed, true
+}
+
+// GetOr retrieves a context value of type T, returning fallback when the key
+// is missing or holds a value of a different type
+func GetOr[T any](ctx Context, key string, fallback T) T {
+	if value, ok := GetAs[T](ctx, key); ok {
+		return value
+	}
+	return fallback
+}
+
+// EventDataAs returns the data of the current event as type T
+func EventDataAs[T any](ctx Context) (T, bool) {
+	var zero T
+	if ctx == nil {
+		return zero, false
+	}
+	typed, ok := ctx.GetEventData().(T)
+	if !ok {
+		return zero, false
+	}
+	return typed, true
+}
+
+// TypedContext wraps a Context and gives typed access to the machine data
+// stored under a single key
+type TypedContext[T any] struct {
+	Context
+	key string
+}
+
+// NewTypedContext creates a typed view of the data stored under key
+func NewTypedContext[T any](ctx Context, key string) TypedContext[T] {
+	return TypedContext[T]{Context: ctx, key: key}
+}
+
+// Key returns the context key holding the typed data
+func (tc TypedContext[T]) Key() string {
+	return tc.key
+}
+
+// Data returns the typed data, reporting false when it is missing or has a different type
+func (tc TypedContext[T]) Data() (T, bool) {
+	return GetAs[T](tc.Context, tc.key)
+}
+
+// MustData returns the typed data, panicking when it is missing or has a different type
+func (tc TypedContext[T]) MustData() T {
+	value, ok := tc.Data()
+	if !ok {
+		panic(NewConfigurationError("context", "no value of the expected type under key '"+tc.key+"'"))
+	}
+	return value
+}
+
+// SetData stores the typed data
+func (tc TypedContext[T]) SetData(value T) {
+	tc.Context.Set(tc.key, value)
+}
+
+// Update applies fn to the current data (or the zero value) and stores the result
+func (tc TypedContext[T]) Update(fn func(T) T) {
+	value, _ := tc.Data()
+	tc.SetData(fn(value))
+}
+
+// TypedAction adapts a function taking typed data into an ActionFunc; the action
+// fails when the data is missing
+func TypedAction[T any](key string, fn func(ctx Context, data T) error) ActionFunc {
+	return func(ctx Context) error {
+		data, ok := GetAs[T](ctx, key)
+		if !ok {
+			return NewConfigurationError("context", "no value of the expected type under key '"+key+"'")
+		}
+		return fn(ctx, data)
+	}
+}
+
+// TypedGuard adapts a predicate on typed data into a GuardFunc; the guard
+// rejects when the data is missing
+func TypedGuard[T any](key string, fn func(ctx Context, data T) bool) GuardFunc {
+	return func(ctx Context) bool {
+		data, ok := GetAs[T](ctx, key)
+		if !ok {
+			return false
+		}
+		return fn(ctx, data)
+	}
+}
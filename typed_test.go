@@ -0,0 +1,108 @@
+package fluo
+
+import "testing"
+
+type typedTestOrder struct {
+	ID    string
+	Total int
+}
+
+func TestGetAs(t *testing.T) {
+	ctx := NewSimpleContext()
+	ctx.Set("order", &typedTestOrder{ID: "o-1"})
+	ctx.Set("count", 3)
+
+	order, ok := GetAs[*typedTestOrder](ctx, "order")
+	if !ok || order.ID != "o-1" {
+		t.Errorf("Expected order o-1, got %v (ok=%v)", order, ok)
+	}
+
+	if _, ok := GetAs[string](ctx, "count"); ok {
+		t.Error("Expected type mismatch to report false")
+	}
+	if _, ok := GetAs[int](ctx, "missing"); ok {
+		t.Error("Expected missing key to report false")
+	}
+	if _, ok := GetAs[int](nil, "count"); ok {
+		t.Error("Expected nil context to report false")
+	}
+
+	if got := GetOr(ctx, "count", 0); got != 3 {
+		t.Errorf("Expected 3, got %d", got)
+	}
+	if got := GetOr(ctx, "missing", 7); got != 7 {
+		t.Errorf("Expected fallback 7, got %d", got)
+	}
+}
+
+func TestEventDataAs(t *testing.T) {
+	machine := NewMachine().
+		State("idle").Initial().
+		To("done").On("submit").When(func(ctx Context) bool {
+		order, ok := EventDataAs[*typedTestOrder](ctx)
+		return ok && order.Total > 0
+	}).
+		State("done").
+		Build().
+		CreateInstance()
+	_ = machine.Start()
+
+	AssertEventProcessed(t, machine.HandleEvent("submit", "not an order"), false)
+	AssertEventProcessed(t, machine.HandleEvent("submit", &typedTestOrder{Total: 5}), true)
+	AssertState(t, machine, "done")
+}
+
+func TestTypedContext(t *testing.T) {
+	ctx := NewSimpleContext()
+	orders := NewTypedContext[typedTestOrder](ctx, "order")
+
+	if _, ok := orders.Data(); ok {
+		t.Error("Expected no data before it is set")
+	}
+
+	orders.SetData(typedTestOrder{ID: "o-2"})
+	orders.Update(func(o typedTestOrder) typedTestOrder {
+		o.Total += 10
+		return o
+	})
+
+	order := orders.MustData()
+	if order.ID != "o-2" || order.Total != 10 {
+		t.Errorf("Unexpected order: %+v", order)
+	}
+	if orders.Key() != "order" {
+		t.Errorf("Expected key 'order', got '%s'", orders.Key())
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected MustData to panic on a type mismatch")
+		}
+	}()
+	ctx.Set("order", "wrong type")
+	orders.MustData()
+}
+
+func TestTypedActionAndGuard(t *testing.T) {
+	var seen string
+	machine := NewMachine().
+		State("idle").Initial().
+		To("done").On("go").
+		When(TypedGuard("order", func(ctx Context, o *typedTestOrder) bool { return o.Total > 0 })).
+		Do(TypedAction("order", func(ctx Context, o *typedTestOrder) error {
+			seen = o.ID
+			return nil
+		})).
+		State("done").
+		Build().
+		CreateInstance()
+	_ = machine.Start()
+
+	AssertEventProcessed(t, machine.HandleEvent("go", nil), false)
+
+	machine.Context().Set("order", &typedTestOrder{ID: "o-3", Total: 1})
+	AssertEventProcessed(t, machine.HandleEvent("go", nil), true)
+	if seen != "o-3" {
+		t.Errorf("Expected action to see o-3, got '%s'", seen)
+	}
+}
@@ -0,0 +1,274 @@
+package fluo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// TypedBuilder declares a machine whose states and events are user-defined
+// string types, such as `type OrderState string`, so a misspelled state or
+// event fails to compile instead of failing at runtime. States are named by
+// their full IDs: a state nested in the composite "review" is declared as
+// "review.pending". Builder returns the untyped builder for the features the
+// typed API does not cover.
+type TypedBuilder[S, E ~string] struct {
+	builder MachineBuilder
+	errs    []error
+}
+
+// TypedStateBuilder configures a state declared through a TypedBuilder
+type TypedStateBuilder[S, E ~string] struct {
+	builder *TypedBuilder[S, E]
+	state   StateBuilder
+}
+
+// TypedTransitionBuilder configures a transition declared through a TypedBuilder
+type TypedTransitionBuilder[S, E ~string] struct {
+	builder    *TypedBuilder[S, E]
+	transition TransitionBuilder
+}
+
+// TypedScope declares the states of a composite state or parallel region.
+// State IDs are full IDs and must lie within the composite or region.
+type TypedScope[S, E ~string] struct {
+	builder   *TypedBuilder[S, E]
+	path      string
+	state     func(id string) StateBuilder
+	composite func(id string, declare func(CompositeScope))
+}
+
+// TypedParallelScope declares the regions of a parallel state
+type TypedParallelScope[S, E ~string] struct {
+	builder *TypedBuilder[S, E]
+	id      string
+	scope   ParallelScope
+}
+
+// NewTypedMachine creates a builder for a machine with typed states and events
+func NewTypedMachine[S, E ~string]() *TypedBuilder[S, E] {
+	return &TypedBuilder[S, E]{builder: NewMachine()}
+}
+
+// Builder returns the underlying untyped builder
+func (b *TypedBuilder[S, E]) Builder() MachineBuilder {
+	return b.builder
+}
+
+// State declares a top-level state or returns its builder
+func (b *TypedBuilder[S, E]) State(id S) *TypedStateBuilder[S, E] {
+	return &TypedStateBuilder[S, E]{builder: b, state: b.builder.State(string(id))}
+}
+
+// Composite declares a composite state and its substates in a closure
+func (b *TypedBuilder[S, E]) Composite(id S, declare func(*TypedScope[S, E])) *TypedBuilder[S, E] {
+	b.builder.Composite(string(id), func(c CompositeScope) {
+		declare(b.compositeScope(string(id), c))
+	})
+	return b
+}
+
+// Parallel declares a parallel state and its regions in a closure
+func (b *TypedBuilder[S, E]) Parallel(id S, declare func(*TypedParallelScope[S, E])) *TypedBuilder[S, E] {
+	b.builder.Parallel(string(id), func(p ParallelScope) {
+		declare(&TypedParallelScope[S, E]{builder: b, id: string(id), scope: p})
+	})
+	return b
+}
+
+// Build finalizes the machine, panicking on an invalid configuration
+func (b *TypedBuilder[S, E]) Build() *TypedDefinition[S, E] {
+	definition, err := b.BuildE()
+	if err != nil {
+		panic(fmt.Sprintf("Failed to build machine: %v", err))
+	}
+	return definition
+}
+
+// BuildE finalizes the machine, returning validation errors instead of panicking
+func (b *TypedBuilder[S, E]) BuildE() (*TypedDefinition[S, E], error) {
+	if len(b.errs) > 0 {
+		return nil, errors.Join(b.errs...)
+	}
+	definition, err := b.builder.BuildE()
+	if err != nil {
+		return nil, err
+	}
+	return &TypedDefinition[S, E]{MachineDefinition: definition}, nil
+}
+
+// compositeScope creates the typed scope of a composite state
+func (b *TypedBuilder[S, E]) compositeScope(id string, c CompositeScope) *TypedScope[S, E] {
+	return &TypedScope[S, E]{builder: b, path: id, state: c.State, composite: c.Composite}
+}
+
+// State declares a state within the scope or returns its builder
+func (s *TypedScope[S, E]) State(id S) *TypedStateBuilder[S, E] {
+	return &TypedStateBuilder[S, E]{builder: s.builder, state: s.state(s.local(id))}
+}
+
+// Composite declares a composite state within the scope
+func (s *TypedScope[S, E]) Composite(id S, declare func(*TypedScope[S, E])) {
+	s.composite(s.local(id), func(c CompositeScope) {
+		declare(s.builder.compositeScope(string(id), c))
+	})
+}
+
+// local returns the ID of a state relative to the scope, recording an error
+// when the state lies outside it
+func (s *TypedScope[S, E]) local(id S) string {
+	local, ok := strings.CutPrefix(string(id), s.path+".")
+	if !ok || local == "" {
+		s.builder.errs = append(s.builder.errs, NewConfigurationError("builder",
+			fmt.Sprintf("state '%s' is declared in '%s' but does not belong to it", id, s.path)))
+		return string(id)
+	}
+	return local
+}
+
+// Region declares a region of the parallel state and its states
+func (p *TypedParallelScope[S, E]) Region(name string, declare func(*TypedScope[S, E])) {
+	p.scope.Region(name, func(r RegionScope) {
+		declare(&TypedScope[S, E]{builder: p.builder, path: p.id + "." + name, state: r.State, composite: r.Composite})
+	})
+}
+
+// Initial marks the state as the initial state of the machine or of its
+// composite state or region
+func (sb *TypedStateBuilder[S, E]) Initial() *TypedStateBuilder[S, E] {
+	sb.state.Initial()
+	return sb
+}
+
+// Final marks the state as final
+func (sb *TypedStateBuilder[S, E]) Final() *TypedStateBuilder[S, E] {
+	sb.state.Final()
+	return sb
+}
+
+// OnEntry sets the entry action of the state
+func (sb *TypedStateBuilder[S, E]) OnEntry(action ActionFunc) *TypedStateBuilder[S, E] {
+	sb.state.OnEntry(action)
+	return sb
+}
+
+// OnExit sets the exit action of the state
+func (sb *TypedStateBuilder[S, E]) OnExit(action ActionFunc) *TypedStateBuilder[S, E] {
+	sb.state.OnExit(action)
+	return sb
+}
+
+// To starts a transition from the state to the target
+func (sb *TypedStateBuilder[S, E]) To(target S) *TypedTransitionBuilder[S, E] {
+	transition := sb.state.To(string(target))
+	// Typed IDs are full IDs, never resolved relative to the enclosing scope
+	if impl, ok := transition.(*transitionBuilderImpl); ok {
+		impl.transition.TargetState = string(target)
+	}
+	return &TypedTransitionBuilder[S, E]{builder: sb.builder, transition: transition}
+}
+
+// On sets the event triggering the transition
+func (tb *TypedTransitionBuilder[S, E]) On(event E) *TypedTransitionBuilder[S, E] {
+	tb.transition = tb.transition.On(string(event))
+	return tb
+}
+
+// When guards the transition
+func (tb *TypedTransitionBuilder[S, E]) When(guard GuardFunc) *TypedTransitionBuilder[S, E] {
+	tb.transition = tb.transition.When(guard)
+	return tb
+}
+
+// WhenNamed guards the transition with a guard named for diagnostics
+func (tb *TypedTransitionBuilder[S, E]) WhenNamed(name string, guard GuardFunc) *TypedTransitionBuilder[S, E] {
+	tb.transition = tb.transition.WhenNamed(name, guard)
+	return tb
+}
+
+// Do sets the transition action
+func (tb *TypedTransitionBuilder[S, E]) Do(action ActionFunc) *TypedTransitionBuilder[S, E] {
+	tb.transition = tb.transition.Do(action)
+	return tb
+}
+
+// DoNamed sets a transition action named for diagnostics
+func (tb *TypedTransitionBuilder[S, E]) DoNamed(name string, action ActionFunc) *TypedTransitionBuilder[S, E] {
+	tb.transition = tb.transition.DoNamed(name, action)
+	return tb
+}
+
+// Internal makes the transition run its action without leaving the state
+func (tb *TypedTransitionBuilder[S, E]) Internal() *TypedTransitionBuilder[S, E] {
+	tb.transition = tb.transition.Internal()
+	return tb
+}
+
+// OnError routes a failing transition action to the error state
+func (tb *TypedTransitionBuilder[S, E]) OnError(errorState S) *TypedTransitionBuilder[S, E] {
+	tb.transition = tb.transition.OnError(string(errorState))
+	return tb
+}
+
+// To starts another transition from the same source state
+func (tb *TypedTransitionBuilder[S, E]) To(target S) *TypedTransitionBuilder[S, E] {
+	tb.transition = tb.transition.To(string(target))
+	return tb
+}
+
+// TypedDefinition is a machine definition with typed states and events
+type TypedDefinition[S, E ~string] struct {
+	MachineDefinition
+}
+
+// CreateTypedInstance creates a machine instance with typed states and events
+func (d *TypedDefinition[S, E]) CreateTypedInstance(opts ...MachineOption) TypedMachine[S, E] {
+	return Typed[S, E](d.CreateInstance(opts...))
+}
+
+// TypedMachine is a machine instance sending typed events and reporting typed
+// states. The embedded Machine keeps the untyped API available.
+type TypedMachine[S, E ~string] struct {
+	Machine
+}
+
+// Typed wraps a machine to send typed events and report typed states
+func Typed[S, E ~string](machine Machine) TypedMachine[S, E] {
+	return TypedMachine[S, E]{Machine: machine}
+}
+
+// Send sends a typed event to the machine
+func (m TypedMachine[S, E]) Send(event E, data any) *EventResult {
+	return m.SendEvent(string(event), data)
+}
+
+// SendWithContext sends a typed event bounded by the context
+func (m TypedMachine[S, E]) SendWithContext(ctx context.Context, event E, data any) *EventResult {
+	return m.SendEventWithContext(ctx, string(event), data)
+}
+
+// State returns the current state
+func (m TypedMachine[S, E]) State() S {
+	return S(m.CurrentState())
+}
+
+// In reports whether the state is active
+func (m TypedMachine[S, E]) In(state S) bool {
+	return m.IsInState(string(state))
+}
+
+// ActiveStates returns every active state
+func (m TypedMachine[S, E]) ActiveStates() []S {
+	active := m.GetActiveStates()
+	states := make([]S, len(active))
+	for i, state := range active {
+		states[i] = S(state)
+	}
+	return states
+}
+
+// SetTypedState forces the machine into a state without running actions
+func (m TypedMachine[S, E]) SetTypedState(state S) error {
+	return m.SetState(string(state))
+}
@@ -0,0 +1,87 @@
+package fluo
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+type orderState string
+
+type orderEvent string
+
+const (
+	orderDraft          orderState = "draft"
+	orderReview         orderState = "review"
+	orderReviewPending  orderState = "review.pending"
+	orderReviewApproved orderState = "review.approved"
+	orderShipped        orderState = "shipped"
+	orderChecks         orderState = "checks"
+	orderChecksStock    orderState = "checks.stock.counting"
+	orderChecksPayment  orderState = "checks.payment.charging"
+
+	orderSubmit  orderEvent = "submit"
+	orderApprove orderEvent = "approve"
+	orderShip    orderEvent = "ship"
+	orderRecheck orderEvent = "recheck"
+)
+
+func TestTypedBuilder(t *testing.T) {
+	builder := NewTypedMachine[orderState, orderEvent]()
+	builder.State(orderDraft).Initial().
+		To(orderReview).On(orderSubmit)
+	builder.Composite(orderReview, func(c *TypedScope[orderState, orderEvent]) {
+		c.State(orderReviewPending).Initial().
+			To(orderReviewApproved).On(orderApprove)
+		// The target is declared later at the top level and must not be
+		// resolved relative to the composite
+		c.State(orderReviewApproved).
+			To(orderShipped).On(orderShip).
+			To(orderChecks).On(orderRecheck)
+	})
+	builder.State(orderShipped).Final()
+	builder.Parallel(orderChecks, func(p *TypedParallelScope[orderState, orderEvent]) {
+		p.Region("stock", func(r *TypedScope[orderState, orderEvent]) {
+			r.State(orderChecksStock).Initial()
+		})
+		p.Region("payment", func(r *TypedScope[orderState, orderEvent]) {
+			r.State(orderChecksPayment).Initial()
+		})
+	})
+
+	machine := builder.Build().CreateTypedInstance()
+	_ = machine.Start()
+	if machine.State() != orderDraft {
+		t.Fatalf("Expected draft, got %s", machine.State())
+	}
+
+	AssertEventProcessed(t, machine.Send(orderSubmit, nil), true)
+	if machine.State() != orderReviewPending {
+		t.Errorf("Expected the composite's initial state, got %s", machine.State())
+	}
+	machine.Send(orderApprove, nil)
+	AssertEventProcessed(t, machine.Send(orderShip, nil), true)
+	if !machine.In(orderShipped) {
+		t.Errorf("Expected shipped, got %s", machine.State())
+	}
+
+	_ = machine.SetTypedState(orderReviewApproved)
+	machine.Send(orderRecheck, nil)
+	active := machine.ActiveStates()
+	if !slices.Contains(active, orderChecksStock) || !slices.Contains(active, orderChecksPayment) {
+		t.Errorf("Expected both regions to be active, got %v", active)
+	}
+}
+
+func TestTypedBuilder_StateOutsideScope(t *testing.T) {
+	builder := NewTypedMachine[orderState, orderEvent]()
+	builder.State(orderDraft).Initial()
+	builder.Composite(orderReview, func(c *TypedScope[orderState, orderEvent]) {
+		c.State(orderShipped).Initial()
+	})
+
+	var configErr *ConfigurationError
+	if _, err := builder.BuildE(); !errors.As(err, &configErr) {
+		t.Errorf("Expected a configuration error for a state outside the composite, got %v", err)
+	}
+}
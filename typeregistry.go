@@ -0,0 +1,108 @@
+package fluo
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// TypedValue is a context value of a registered type, encoded as JSON with its type name
+type TypedValue struct {
+	Type string `json:"type"`
+	Data []byte `json:"data"`
+}
+
+// TypeRegistry maps Go types to stable names so context values keep their
+// concrete types across snapshot and restore
+type TypeRegistry struct {
+	byName map[string]reflect.Type
+	byType map[reflect.Type]string
+	mutex  sync.RWMutex
+}
+
+// NewTypeRegistry creates an empty type registry
+func NewTypeRegistry() *TypeRegistry {
+	return &TypeRegistry{
+		byName: make(map[string]reflect.Type),
+		byType: make(map[reflect.Type]string),
+	}
+}
+
+// DefaultTypeRegistry is the registry used by machines without a WithTypeRegistry option
+var DefaultTypeRegistry = NewTypeRegistry()
+
+// Register adds the type of sample to the registry under the given name
+func (r *TypeRegistry) Register(name string, sample any) error {
+	if name == "" {
+		return NewConfigurationError("type registry", "type name cannot be empty")
+	}
+	if sample == nil {
+		return NewConfigurationError("type registry", fmt.Sprintf("sample for type '%s' cannot be nil", name))
+	}
+
+	t := reflect.TypeOf(sample)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if existing, ok := r.byName[name]; ok && existing != t {
+		return NewConfigurationError("type registry", fmt.Sprintf("type name '%s' is already registered for %s", name, existing))
+	}
+	if existing, ok := r.byType[t]; ok && existing != name {
+		return NewConfigurationError("type registry", fmt.Sprintf("type %s is already registered as '%s'", t, existing))
+	}
+
+	r.byName[name] = t
+	r.byType[t] = name
+	return nil
+}
+
+// RegisterType registers T in the default type registry under the given name
+func RegisterType[T any](name string) error {
+	var zero T
+	return DefaultTypeRegistry.Register(name, zero)
+}
+
+// encode wraps a value of a registered type, reporting false for unregistered types
+func (r *TypeRegistry) encode(value any) (TypedValue, bool, error) {
+	if value == nil {
+		return TypedValue{}, false, nil
+	}
+
+	r.mutex.RLock()
+	name, ok := r.byType[reflect.TypeOf(value)]
+	r.mutex.RUnlock()
+	if !ok {
+		return TypedValue{}, false, nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return TypedValue{}, true, err
+	}
+	return TypedValue{Type: name, Data: data}, true, nil
+}
+
+// decode restores a typed value to its registered concrete type
+func (r *TypeRegistry) decode(value TypedValue) (any, error) {
+	r.mutex.RLock()
+	t, ok := r.byName[value.Type]
+	r.mutex.RUnlock()
+	if !ok {
+		return nil, NewConfigurationError("type registry", fmt.Sprintf("type '%s' is not registered", value.Type))
+	}
+
+	target := reflect.New(t)
+	if err := json.Unmarshal(value.Data, target.Interface()); err != nil {
+		return nil, err
+	}
+	return target.Elem().Interface(), nil
+}
+
+// WithTypeRegistry sets the type registry used for snapshot context values
+func WithTypeRegistry(registry *TypeRegistry) MachineOption {
+	return func(sm *StateMachine) {
+		sm.typeRegistry = registry
+	}
+}
@@ -0,0 +1,96 @@
+package fluo
+
+import "testing"
+
+type registryTestOrder struct {
+	ID    string
+	Total int
+	Items []string
+}
+
+func TestTypeRegistry_RoundTripThroughSnapshot(t *testing.T) {
+	registry := NewTypeRegistry()
+	if err := registry.Register("order", registryTestOrder{}); err != nil {
+		t.Fatalf("Expected no error registering type, got: %v", err)
+	}
+
+	for _, codec := range []Codec{JSONCodec{}, MsgpackCodec{}, ProtobufCodec{}} {
+		t.Run(codec.Name(), func(t *testing.T) {
+			machine := CreateSimpleMachine().(*StateMachine)
+			WithTypeRegistry(registry)(machine)
+			machine.Context().Set("order", registryTestOrder{ID: "A-42", Total: 300, Items: []string{"book", "pen"}})
+			_ = machine.Start()
+
+			data, err := machine.MarshalSnapshot(codec)
+			if err != nil {
+				t.Fatalf("Expected no error encoding snapshot, got: %v", err)
+			}
+
+			restored := CreateSimpleMachine().(*StateMachine)
+			WithTypeRegistry(registry)(restored)
+			if err := restored.UnmarshalSnapshot(codec, data); err != nil {
+				t.Fatalf("Expected no error restoring snapshot, got: %v", err)
+			}
+
+			value, _ := restored.Context().Get("order")
+			order, ok := value.(registryTestOrder)
+			if !ok {
+				t.Fatalf("Expected registryTestOrder, got %T", value)
+			}
+			if order.ID != "A-42" || order.Total != 300 || len(order.Items) != 2 {
+				t.Errorf("Expected order to be restored, got %+v", order)
+			}
+		})
+	}
+}
+
+func TestTypeRegistry_UnmarshalJSONKeepsRegisteredTypes(t *testing.T) {
+	registry := NewTypeRegistry()
+	_ = registry.Register("order", registryTestOrder{})
+
+	definition := NewMachine().
+		State("idle").Initial().
+		Build()
+
+	machine := definition.CreateInstance(WithTypeRegistry(registry))
+	machine.Context().Set("order", registryTestOrder{ID: "B-7"})
+
+	data, err := machine.MarshalJSON()
+	if err != nil {
+		t.Fatalf("Expected no error marshaling, got: %v", err)
+	}
+
+	restored := definition.CreateInstance(WithTypeRegistry(registry))
+	if err := restored.UnmarshalJSON(data); err != nil {
+		t.Fatalf("Expected no error unmarshaling, got: %v", err)
+	}
+
+	if order, ok := restored.Context().Get("order"); !ok || order.(registryTestOrder).ID != "B-7" {
+		t.Errorf("Expected registered type to survive UnmarshalJSON, got %T", order)
+	}
+
+	unregistered := definition.CreateInstance(WithTypeRegistry(NewTypeRegistry()))
+	if err := unregistered.UnmarshalJSON(data); err == nil {
+		t.Error("Expected error restoring an unregistered type")
+	}
+}
+
+func TestTypeRegistry_RegisterConflicts(t *testing.T) {
+	registry := NewTypeRegistry()
+
+	if err := registry.Register("order", registryTestOrder{}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if err := registry.Register("order", registryTestOrder{}); err != nil {
+		t.Errorf("Expected re-registering the same type to succeed, got: %v", err)
+	}
+	if err := registry.Register("order", 42); !IsConfigurationError(err) {
+		t.Errorf("Expected configuration error for name conflict, got: %v", err)
+	}
+	if err := registry.Register("other", registryTestOrder{}); !IsConfigurationError(err) {
+		t.Errorf("Expected configuration error for type conflict, got: %v", err)
+	}
+	if err := registry.Register("", registryTestOrder{}); !IsConfigurationError(err) {
+		t.Errorf("Expected configuration error for empty name, got: %v", err)
+	}
+}
@@ -0,0 +1,225 @@
+package fluo
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+)
+
+// DefinitionVersionKey is the metadata label recording the manager definition
+// version an instance runs. Instances of the first version carry no label.
+const DefinitionVersionKey = "fluo.definition-version"
+
+// MigrationFunc moves an in-flight instance to a new definition version. It
+// adjusts the snapshot taken from the instance, typically renaming states that
+// changed, before the snapshot is restored into an instance of the new
+// definition. An error leaves the instance on its previous version.
+type MigrationFunc func(snapshot *Snapshot) error
+
+// definitionUpgrade is a definition version and the migration into it
+type definitionUpgrade struct {
+	definition MachineDefinition
+	migrate    MigrationFunc
+}
+
+// MapStates returns a migration renaming the states of a snapshot according to
+// mapping. States missing from the mapping keep their IDs.
+func MapStates(mapping map[string]string) MigrationFunc {
+	rename := func(stateID string) string {
+		if renamed, ok := mapping[stateID]; ok {
+			return renamed
+		}
+		return stateID
+	}
+	return func(snapshot *Snapshot) error {
+		snapshot.CurrentState = rename(snapshot.CurrentState)
+		for i, stateID := range snapshot.ActiveStates {
+			snapshot.ActiveStates[i] = rename(stateID)
+		}
+		for region, stateID := range snapshot.RegionStates {
+			snapshot.RegionStates[region] = rename(stateID)
+		}
+		if snapshot.History != nil {
+			history := make(map[string]string, len(snapshot.History))
+			for owner, stateID := range snapshot.History {
+				history[rename(owner)] = rename(stateID)
+			}
+			snapshot.History = history
+		}
+		for key, owner := range snapshot.ContextScopes {
+			snapshot.ContextScopes[key] = rename(owner)
+		}
+		return nil
+	}
+}
+
+// Version returns the current definition version, starting at 1
+func (m *Manager) Version() int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.version
+}
+
+// InstanceVersion returns the definition version a tracked instance runs
+func (m *Manager) InstanceVersion(id string) (int, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	instance, ok := m.instances[id]
+	if !ok {
+		return 0, false
+	}
+	return instance.version, true
+}
+
+// UpgradeDefinition swaps in a new definition version for instances created
+// from now on and returns the new version. With a migration, tracked
+// instances are migrated right away and stored instances of older versions
+// when they are next loaded; Machine values obtained earlier for migrated
+// instances are replaced and must be fetched again. Without a migration,
+// existing instances keep running their previous definition. Instances whose
+// migration fails stay on their previous version, and their errors are
+// returned joined.
+func (m *Manager) UpgradeDefinition(definition MachineDefinition, migrate MigrationFunc) (int, error) {
+	m.mutex.Lock()
+	if m.upgrades == nil {
+		m.upgrades = map[int]definitionUpgrade{1: {definition: m.definition}}
+	}
+	m.version++
+	m.definition = definition
+	m.upgrades[m.version] = definitionUpgrade{definition: definition, migrate: migrate}
+	lineage := m.lineage()
+	ids := slices.Sorted(maps.Keys(m.instances))
+	instances := make([]*managedInstance, len(ids))
+	for i, id := range ids {
+		instances[i] = m.instances[id]
+	}
+	m.mutex.Unlock()
+
+	if migrate == nil {
+		return lineage.version, nil
+	}
+
+	// Instances are migrated without the manager mutex, so events keep flowing
+	// to the other instances while one waits for its event to finish
+	var errs []error
+	for i, id := range ids {
+		if err := m.migrateTracked(lineage, id, instances[i]); err != nil {
+			errs = append(errs, fmt.Errorf("instance '%s': %w", id, err))
+		}
+	}
+	return lineage.version, errors.Join(errs...)
+}
+
+// definitionLineage is the definition versions of a manager up to the current one
+type definitionLineage struct {
+	version  int
+	current  MachineDefinition
+	upgrades map[int]definitionUpgrade
+}
+
+// lineage returns the definition versions known so far. The caller must hold
+// the manager mutex.
+func (m *Manager) lineage() definitionLineage {
+	return definitionLineage{
+		version:  m.version,
+		current:  m.definition,
+		upgrades: maps.Clone(m.upgrades),
+	}
+}
+
+// migrateTracked moves a tracked instance to the lineage's version, replacing
+// its machine. Instances evicted or migrated meanwhile are left alone.
+func (m *Manager) migrateTracked(lineage definitionLineage, id string, instance *managedInstance) error {
+	instance.mutex.Lock()
+	defer instance.mutex.Unlock()
+
+	if instance.evicted || instance.version >= lineage.version {
+		return nil
+	}
+	// An instance left behind by an upgrade without migration stays behind
+	if lineage.upgrades[instance.version+1].migrate == nil {
+		return nil
+	}
+	snapshot, err := instance.machine.Snapshot()
+	if err != nil {
+		return err
+	}
+	machine, version, err := m.restoreFrom(lineage, id, snapshot, instance.version, instance.options)
+	if err != nil {
+		return err
+	}
+	if sm, ok := instance.machine.(*StateMachine); ok {
+		sm.suspend()
+	}
+	instance.machine = machine
+	instance.version = version
+	m.watch(id, instance)
+	return m.save(id, machine)
+}
+
+// restoreLatest restores a stored snapshot into the lineage, migrating it from
+// the definition version recorded in its metadata
+func (m *Manager) restoreLatest(lineage definitionLineage, id string, snapshot *Snapshot, opts []MachineOption) (Machine, int, error) {
+	version := 1
+	if label, ok := snapshot.Metadata[DefinitionVersionKey]; ok {
+		parsed, err := strconv.Atoi(label)
+		if err != nil || parsed < 1 || parsed > lineage.version {
+			return nil, 0, NewConfigurationError("manager", fmt.Sprintf("instance '%s' has unknown definition version '%s'", id, label))
+		}
+		version = parsed
+	}
+	return m.restoreFrom(lineage, id, snapshot, version, opts)
+}
+
+// restoreFrom restores a snapshot of the given version, applying the
+// migrations into later versions of the lineage until one is missing, and
+// creates the instance with the manager's options followed by opts.
+// Migrations work on a copy, leaving a stored snapshot untouched.
+func (m *Manager) restoreFrom(lineage definitionLineage, id string, snapshot *Snapshot, version int, opts []MachineOption) (Machine, int, error) {
+	definition := lineage.current
+	if version < lineage.version {
+		definition = lineage.upgrades[version].definition
+		snapshot = snapshot.clone()
+		for next := version + 1; next <= lineage.version; next++ {
+			upgrade := lineage.upgrades[next]
+			if upgrade.migrate == nil {
+				break
+			}
+			if err := upgrade.migrate(snapshot); err != nil {
+				return nil, 0, err
+			}
+			definition, version = upgrade.definition, next
+			snapshot.InitialState = definition.GetInitialState()
+			// The migration vouches for the snapshot fitting the new definition
+			snapshot.DefinitionVersion, snapshot.DefinitionHash = "", ""
+		}
+	}
+
+	if version > 1 {
+		if snapshot.Metadata == nil {
+			snapshot.Metadata = make(map[string]string)
+		}
+		snapshot.Metadata[DefinitionVersionKey] = strconv.Itoa(version)
+	}
+	machine := definition.CreateInstanceWithID(id, m.instanceOptions(version, opts)...)
+	if err := machine.Restore(snapshot); err != nil {
+		return nil, 0, err
+	}
+	return machine, version, nil
+}
+
+// instanceOptions returns the options of an instance of a definition version:
+// the manager's options, then the instance's own
+func (m *Manager) instanceOptions(version int, opts []MachineOption) []MachineOption {
+	all := slices.Clone(m.options)
+	if version > 1 {
+		all = append(all, WithMetadata(map[string]string{DefinitionVersionKey: strconv.Itoa(version)}))
+	}
+	if m.archive != nil {
+		all = append(all, withTransitionCount())
+	}
+	return append(all, opts...)
+}
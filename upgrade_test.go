@@ -0,0 +1,180 @@
+package fluo
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// buildUpgradedDefinition renames "running" to "active" and adds a pause state
+func buildUpgradedDefinition() MachineDefinition {
+	builder := NewMachine()
+	builder.State("idle").Initial().
+		To("active").On("start")
+	builder.State("active").
+		To("idle").On("stop").
+		To("paused").On("pause")
+	builder.State("paused").
+		To("active").On("resume")
+	return builder.Build()
+}
+
+func TestManager_UpgradeDefinitionMigratesTrackedInstances(t *testing.T) {
+	manager := newTestManager()
+	machine, _ := manager.Create("order-1")
+	_ = machine.Start()
+	machine.Context().Set("owner", "alice")
+	manager.SendEvent("order-1", "start", nil)
+
+	version, err := manager.UpgradeDefinition(buildUpgradedDefinition(), MapStates(map[string]string{"running": "active"}))
+	if err != nil || version != 2 {
+		t.Fatalf("Expected version 2 without error, got %d, %v", version, err)
+	}
+
+	migrated, _ := manager.Get("order-1")
+	AssertState(t, migrated, "active")
+	if owner, _ := migrated.Context().Get("owner"); owner != "alice" {
+		t.Errorf("Expected the context to be migrated, got %v", owner)
+	}
+	AssertEventProcessed(t, manager.SendEvent("order-1", "pause", nil), true)
+	AssertState(t, migrated, "paused")
+	if v, _ := manager.InstanceVersion("order-1"); v != 2 {
+		t.Errorf("Expected the instance to run version 2, got %d", v)
+	}
+	if migrated.Metadata()[DefinitionVersionKey] != "2" {
+		t.Errorf("Expected the version label, got %v", migrated.Metadata())
+	}
+
+	created, _ := manager.Create("order-2")
+	_ = created.Start()
+	AssertEventProcessed(t, manager.SendEvent("order-2", "start", nil), true)
+	AssertState(t, created, "active")
+}
+
+func TestManager_UpgradeDefinitionWithoutMigration(t *testing.T) {
+	manager := newTestManager()
+	machine, _ := manager.Create("order-1")
+	_ = machine.Start()
+
+	if _, err := manager.UpgradeDefinition(buildUpgradedDefinition(), nil); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	AssertEventProcessed(t, manager.SendEvent("order-1", "start", nil), true)
+	AssertState(t, machine, "running")
+	if v, _ := manager.InstanceVersion("order-1"); v != 1 {
+		t.Errorf("Expected the instance to stay on version 1, got %d", v)
+	}
+
+	created, _ := manager.Create("order-2")
+	_ = created.Start()
+	manager.SendEvent("order-2", "start", nil)
+	AssertState(t, created, "active")
+}
+
+func TestManager_UpgradeDefinitionMigratesStoredInstances(t *testing.T) {
+	store := NewMemoryStore()
+	manager := newTestManager(WithStore(store))
+	machine, _ := manager.Create("order-1")
+	_ = machine.Start()
+	manager.SendEvent("order-1", "start", nil)
+	_ = manager.Evict("order-1")
+
+	if _, err := manager.UpgradeDefinition(buildUpgradedDefinition(), MapStates(map[string]string{"running": "active"})); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if stored, _, _ := store.Load("order-1"); stored.CurrentState != "running" {
+		t.Errorf("Expected the stored snapshot to be migrated lazily, got %s", stored.CurrentState)
+	}
+
+	AssertEventProcessed(t, manager.SendEvent("order-1", "pause", nil), true)
+	restored, _ := manager.Get("order-1")
+	AssertState(t, restored, "paused")
+	if stored, _, _ := store.Load("order-1"); stored.Metadata[DefinitionVersionKey] != "2" {
+		t.Errorf("Expected the saved snapshot to record version 2, got %v", stored.Metadata)
+	}
+}
+
+func TestManager_UpgradeDefinitionFailedMigration(t *testing.T) {
+	manager := newTestManager()
+	machine, _ := manager.Create("order-1")
+	_ = machine.Start()
+
+	refused := errors.New("refused")
+	_, err := manager.UpgradeDefinition(buildUpgradedDefinition(), func(snapshot *Snapshot) error {
+		return refused
+	})
+	if !errors.Is(err, refused) {
+		t.Fatalf("Expected the migration error, got %v", err)
+	}
+	if current, _ := manager.Get("order-1"); current != machine {
+		t.Error("Expected the instance to keep its machine")
+	}
+	if v, _ := manager.InstanceVersion("order-1"); v != 1 {
+		t.Errorf("Expected the instance to stay on version 1, got %d", v)
+	}
+}
+
+func TestManager_UpgradeDefinitionKeepsCreateOptions(t *testing.T) {
+	manager := newTestManager()
+	var count atomic.Int32
+	machine, _ := manager.Create("order-1", WithMiddleware(countingMiddleware(&count)))
+	_ = machine.Start()
+
+	if _, err := manager.UpgradeDefinition(buildUpgradedDefinition(), MapStates(nil)); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	AssertEventProcessed(t, manager.SendEvent("order-1", "start", nil), true)
+	if count.Load() != 1 {
+		t.Errorf("Expected the migrated instance to keep its middleware, got %d calls", count.Load())
+	}
+}
+
+func TestManager_UpgradeDefinitionDoesNotBlockManager(t *testing.T) {
+	release := make(chan struct{})
+	builder := NewMachine()
+	builder.State("idle").Initial().
+		To("running").On("start").Do(func(ctx Context) error {
+		<-release
+		return nil
+	})
+	builder.State("running")
+	manager := NewManager(builder.Build())
+
+	machine, _ := manager.Create("busy")
+	_ = machine.Start()
+	processed := make(chan *EventResult)
+	go func() { processed <- manager.SendEvent("busy", "start", nil) }()
+	// Let the action block the instance mid-event
+	time.Sleep(10 * time.Millisecond)
+
+	upgraded := make(chan error)
+	go func() {
+		_, err := manager.UpgradeDefinition(buildUpgradedDefinition(), MapStates(map[string]string{"running": "active"}))
+		upgraded <- err
+	}()
+
+	created := make(chan error)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		_, err := manager.Create("other")
+		created <- err
+	}()
+	select {
+	case err := <-created:
+		if err != nil {
+			t.Errorf("Expected the instance to be created, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("Expected the manager to stay available while an instance is migrated")
+	}
+
+	close(release)
+	AssertEventProcessed(t, <-processed, true)
+	if err := <-upgraded; err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	migrated, _ := manager.Get("busy")
+	AssertState(t, migrated, "active")
+}
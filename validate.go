@@ -0,0 +1,191 @@
+package fluo
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ValidationSeverity classifies a validation issue
+type ValidationSeverity int
+
+const (
+	// ValidationWarning marks a suspicious but runnable configuration
+	ValidationWarning ValidationSeverity = iota
+	// ValidationError marks a configuration that fails at runtime
+	ValidationError
+)
+
+// String returns the name of the severity
+func (s ValidationSeverity) String() string {
+	if s == ValidationError {
+		return "error"
+	}
+	return "warning"
+}
+
+// Validation issue codes
+const (
+	IssueUnknownTarget          = "unknown-target"
+	IssueMissingInitial         = "missing-initial"
+	IssueJoinWithoutSources     = "join-without-sources"
+	IssueConflictingTransitions = "conflicting-transitions"
+	IssueUnreachableState       = "unreachable-state"
+	IssueDeadEnd                = "dead-end"
+)
+
+// ValidationIssue is a problem found by static validation of a definition
+type ValidationIssue struct {
+	Severity ValidationSeverity
+	Code     string
+	StateID  string
+	Message  string
+}
+
+// String renders the issue as "severity [code] state: message"
+func (i ValidationIssue) String() string {
+	return fmt.Sprintf("%s [%s] %s: %s", i.Severity, i.Code, i.StateID, i.Message)
+}
+
+// Validate statically lints the definition without running it. It reports
+// missing initial states in composites and regions, pseudostates targeting
+// undefined states, joins without sources and conflicting unguarded
+// transitions as errors, and unreachable states and non-final dead ends as
+// warnings. Issues are ordered by state ID.
+func (smd *simpleMachineDefinition) Validate() []ValidationIssue {
+	model := newDiagramModel(smd)
+	containment := model.containment()
+	transitions := smd.GetTransitions()
+
+	var issues []ValidationIssue
+	report := func(severity ValidationSeverity, code, stateID, message string) {
+		issues = append(issues, ValidationIssue{Severity: severity, Code: code, StateID: stateID, Message: message})
+	}
+	exists := func(id string) bool {
+		_, ok := model.nodes[id]
+		return ok
+	}
+
+	if initial := smd.GetInitialState(); initial == "" {
+		report(ValidationError, IssueMissingInitial, "", "machine has no initial state")
+	} else if !exists(initial) {
+		report(ValidationError, IssueUnknownTarget, "", fmt.Sprintf("initial state '%s' is not defined", initial))
+	}
+
+	// Outgoing edges of every state, for reachability and dead-end detection
+	edges := make(map[string][]string)
+
+	for _, node := range sortedDiagramNodes(model.nodes) {
+		id := node.id
+
+		if composite, ok := node.state.(CompositeState); ok && composite.InitialState() == nil && len(node.children) > 0 {
+			report(ValidationError, IssueMissingInitial, id, "composite state has substates but no initial state")
+		}
+		for _, region := range node.regions {
+			if region.region.InitialState() == nil && len(region.children) > 0 {
+				report(ValidationError, IssueMissingInitial, id, fmt.Sprintf("region '%s' has no initial state", region.region.ID()))
+			}
+		}
+
+		for _, transition := range transitions[id] {
+			if !exists(transition.TargetState) {
+				report(ValidationError, IssueUnknownTarget, id, fmt.Sprintf("transition on '%s' targets undefined state '%s'", transitionTrigger(transition), transition.TargetState))
+				continue
+			}
+			edges[id] = append(edges[id], transition.TargetState)
+		}
+
+		for _, edge := range pseudoStateEdges(node.state) {
+			if !exists(edge.from) {
+				report(ValidationError, IssueUnknownTarget, id, fmt.Sprintf("join source '%s' is not defined", edge.from))
+				continue
+			}
+			if !exists(edge.to) {
+				report(ValidationError, IssueUnknownTarget, id, fmt.Sprintf("pseudostate targets undefined state '%s'", edge.to))
+				continue
+			}
+			edges[edge.from] = append(edges[edge.from], edge.to)
+		}
+
+		if pseudo, ok := node.state.(*PseudoStateImpl); ok && pseudo.Kind() == Join && len(pseudo.joinSourceCombinations) == 0 && !hasIncomingTransition(transitions, id) {
+			report(ValidationError, IssueJoinWithoutSources, id, "join has no source states")
+		}
+
+		unguarded := make(map[string]int)
+		for _, transition := range transitions[id] {
+			if transition.Guard == nil && transition.Flag == "" {
+				unguarded[transition.EventName]++
+			}
+		}
+		for _, transition := range transitions[id] {
+			if count := unguarded[transition.EventName]; count > 1 {
+				report(ValidationError, IssueConflictingTransitions, id, fmt.Sprintf("%d unguarded transitions on '%s'", count, transitionTrigger(transition)))
+				delete(unguarded, transition.EventName)
+			}
+		}
+	}
+
+	reachable := make(map[string]bool)
+	var visit func(id string)
+	visit = func(id string) {
+		if id == "" || reachable[id] || !exists(id) {
+			return
+		}
+		reachable[id] = true
+		node := model.nodes[id]
+
+		// Ancestors are active while a descendant is
+		visit(containment.parent[id])
+		if composite, ok := node.state.(CompositeState); ok && composite.InitialState() != nil {
+			visit(composite.InitialState().ID())
+		}
+		for _, region := range node.regions {
+			if initial := region.region.InitialState(); initial != nil {
+				visit(initial.ID())
+			}
+		}
+		for _, target := range edges[id] {
+			visit(target)
+		}
+	}
+	visit(smd.GetInitialState())
+
+	for _, node := range sortedDiagramNodes(model.nodes) {
+		id := node.id
+		if !reachable[id] {
+			report(ValidationWarning, IssueUnreachableState, id, "state cannot be reached from the initial state")
+			continue
+		}
+
+		if node.state.IsPseudo() || node.state.IsFinal() || len(node.children) > 0 || len(node.regions) > 0 {
+			continue
+		}
+		deadEnd := true
+		for ancestor := id; ancestor != ""; ancestor = containment.parent[ancestor] {
+			if len(edges[ancestor]) > 0 {
+				deadEnd = false
+				break
+			}
+		}
+		if deadEnd {
+			report(ValidationWarning, IssueDeadEnd, id, "non-final state has no outgoing transitions")
+		}
+	}
+
+	slices.SortStableFunc(issues, func(a, b ValidationIssue) int {
+		return strings.Compare(a.StateID, b.StateID)
+	})
+	return issues
+}
+
+// hasIncomingTransition reports whether any transition targets the state
+func hasIncomingTransition(transitions map[string][]Transition, stateID string) bool {
+	for _, list := range transitions {
+		for _, transition := range list {
+			if transition.TargetState == stateID {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+package fluo
+
+import "testing"
+
+func findIssue(issues []ValidationIssue, code, stateID string) *ValidationIssue {
+	for i := range issues {
+		if issues[i].Code == code && issues[i].StateID == stateID {
+			return &issues[i]
+		}
+	}
+	return nil
+}
+
+func TestValidate_CleanMachine(t *testing.T) {
+	definition := NewMachine().
+		State("idle").Initial().
+		To("running").On("start").
+		State("running").
+		To("done").On("finish").
+		State("done").Final().
+		Build()
+
+	if issues := definition.Validate(); len(issues) != 0 {
+		t.Errorf("Expected no issues, got %v", issues)
+	}
+}
+
+func TestValidate_UnreachableAndDeadEnd(t *testing.T) {
+	definition := NewMachine().
+		State("idle").Initial().
+		To("stuck").On("start").
+		State("stuck").
+		State("orphan").
+		To("idle").On("back").
+		Build()
+
+	issues := definition.Validate()
+
+	if issue := findIssue(issues, IssueUnreachableState, "orphan"); issue == nil || issue.Severity != ValidationWarning {
+		t.Errorf("Expected orphan to be reported unreachable, got %v", issues)
+	}
+	if findIssue(issues, IssueDeadEnd, "stuck") == nil {
+		t.Errorf("Expected stuck to be reported as a dead end, got %v", issues)
+	}
+	if findIssue(issues, IssueDeadEnd, "idle") != nil {
+		t.Error("Expected idle not to be a dead end")
+	}
+}
+
+func TestValidate_ConflictingUnguardedTransitions(t *testing.T) {
+	definition := NewMachine().
+		State("idle").Initial().
+		To("a").On("go").
+		To("b").On("go").
+		To("c").On("go").When(func(ctx Context) bool { return true }).
+		State("a").Final().
+		State("b").Final().
+		State("c").Final().
+		Build()
+
+	issues := definition.Validate()
+	issue := findIssue(issues, IssueConflictingTransitions, "idle")
+	if issue == nil || issue.Severity != ValidationError {
+		t.Fatalf("Expected conflicting transitions error, got %v", issues)
+	}
+	if issue.Message != "2 unguarded transitions on 'go'" {
+		t.Errorf("Unexpected message: %s", issue.Message)
+	}
+}
+
+func TestValidate_PseudostateTargetsAndJoins(t *testing.T) {
+	builder := NewMachine()
+	builder.State("idle").Initial().
+		To("decide").On("go").
+		To("sync").On("sync")
+	builder.Choice("decide").Otherwise("nowhere")
+	builder.Join("sync")
+
+	issues := builder.Build().Validate()
+
+	if findIssue(issues, IssueUnknownTarget, "decide") == nil {
+		t.Errorf("Expected unknown choice target to be reported, got %v", issues)
+	}
+	if findIssue(issues, IssueJoinWithoutSources, "sync") != nil {
+		t.Error("Expected join with incoming transitions not to be reported")
+	}
+
+	orphanJoin := NewMachine()
+	orphanJoin.State("idle").Initial()
+	orphanJoin.Join("sync")
+	if findIssue(orphanJoin.Build().Validate(), IssueJoinWithoutSources, "sync") == nil {
+		t.Error("Expected join without sources to be reported")
+	}
+}
+
+func TestValidate_MissingRegionInitial(t *testing.T) {
+	builder := NewMachine()
+	builder.State("idle").Initial().To("active").On("activate")
+	region := builder.ParallelState("active").Region("motor")
+	region.State("stopped")
+	region.State("running")
+
+	issues := builder.Build().Validate()
+	if issue := findIssue(issues, IssueMissingInitial, "active"); issue == nil || issue.Severity != ValidationError {
+		t.Errorf("Expected missing region initial to be reported, got %v", issues)
+	}
+}
+
+func TestValidate_ParallelRegionsReachable(t *testing.T) {
+	builder := NewMachine()
+	builder.State("inactive").Initial().To("active").On("activate")
+	parallel := builder.ParallelState("active")
+	motor := parallel.Region("motor")
+	motor.State("stopped").Initial().To("running").On("start_motor")
+	motor.State("running").To("stopped").On("stop_motor")
+
+	for _, issue := range builder.Build().Validate() {
+		if issue.Code == IssueUnreachableState {
+			t.Errorf("Expected all region states to be reachable, got %v", issue)
+		}
+	}
+}
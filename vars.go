@@ -0,0 +1,158 @@
+package fluo
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// VarDeclaration is an extended state variable declared on a definition with
+// MachineBuilder.Vars. Declarations are created with DeclareVar.
+type VarDeclaration interface {
+	// Name returns the context key holding the variable
+	Name() string
+
+	// Type returns the Go type of the variable
+	Type() reflect.Type
+
+	initialValue() any
+	convert(value any) (any, error)
+}
+
+// Var is a typed handle to an extended state variable. Every instance of a
+// definition declaring the variable starts with its initial value, which Reset
+// restores. The value lives in the machine context under the variable name,
+// so it is included in snapshots and keeps its type across a restore.
+type Var[T any] struct {
+	name    string
+	initial T
+}
+
+// DeclareVar declares an extended state variable of type T with its initial
+// value, to be added to a definition with MachineBuilder.Vars
+func DeclareVar[T any](name string, initial T) Var[T] {
+	return Var[T]{name: name, initial: initial}
+}
+
+// Name returns the context key holding the variable
+func (v Var[T]) Name() string {
+	return v.name
+}
+
+// Type returns the Go type of the variable
+func (v Var[T]) Type() reflect.Type {
+	return reflect.TypeFor[T]()
+}
+
+// Initial returns the initial value of the variable
+func (v Var[T]) Initial() T {
+	return v.initial
+}
+
+// Get returns the value of the variable, or its initial value when the
+// context holds none of type T
+func (v Var[T]) Get(ctx Context) T {
+	return GetOr(ctx, v.name, v.initial)
+}
+
+// Set stores a value of the variable
+func (v Var[T]) Set(ctx Context, value T) {
+	ctx.Set(v.name, value)
+}
+
+// Update applies fn to the current value of the variable and stores the result
+func (v Var[T]) Update(ctx Context, fn func(T) T) {
+	v.Set(ctx, fn(v.Get(ctx)))
+}
+
+// Of returns the value of the variable in a machine instance
+func (v Var[T]) Of(machine Machine) T {
+	return v.Get(machine.Context())
+}
+
+// initialValue returns the initial value stored in new instances
+func (v Var[T]) initialValue() any {
+	return v.initial
+}
+
+// convert turns a restored value back into T, such as the float64 a JSON
+// snapshot holds for an int variable
+func (v Var[T]) convert(value any) (any, error) {
+	if typed, ok := value.(T); ok {
+		return typed, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var typed T
+	if err := json.Unmarshal(data, &typed); err != nil {
+		return nil, fmt.Errorf("variable '%s' does not hold a %s: %w", v.name, v.Type(), err)
+	}
+	return typed, nil
+}
+
+// Vars returns the extended state variables declared on the definition
+func (smd *simpleMachineDefinition) Vars() []VarDeclaration {
+	return append([]VarDeclaration(nil), smd.vars...)
+}
+
+// definitionVars returns the variables declared on a definition, if any
+func definitionVars(definition MachineDefinition) []VarDeclaration {
+	if declared, ok := definition.(interface{ Vars() []VarDeclaration }); ok {
+		return declared.Vars()
+	}
+	return nil
+}
+
+// Vars declares extended state variables on the definition
+func (mb *machineBuilderImpl) Vars(vars ...VarDeclaration) MachineBuilder {
+	mb.vars = append(mb.vars, vars...)
+	return mb
+}
+
+// validateVars reports variables without a name or declared more than once
+func validateVars(vars []VarDeclaration) []error {
+	var errs []error
+	seen := make(map[string]bool, len(vars))
+	for _, v := range vars {
+		switch {
+		case v.Name() == "":
+			errs = append(errs, NewConfigurationError("builder", "variable name cannot be empty"))
+		case seen[v.Name()]:
+			errs = append(errs, NewConfigurationError("builder", fmt.Sprintf("variable '%s' is declared more than once", v.Name())))
+		}
+		seen[v.Name()] = true
+	}
+	return errs
+}
+
+// initVars stores the initial value of every declared variable in the context
+func (sm *StateMachine) initVars() {
+	if sm.definition == nil {
+		return
+	}
+	for _, v := range sm.definition.vars {
+		sm.context.Set(v.Name(), v.initialValue())
+	}
+}
+
+// convertVars turns the restored values of declared variables back into
+// their declared types
+func (sm *StateMachine) convertVars(values map[string]any) error {
+	if sm.definition == nil {
+		return nil
+	}
+	for _, v := range sm.definition.vars {
+		value, exists := values[v.Name()]
+		if !exists {
+			continue
+		}
+		converted, err := v.convert(value)
+		if err != nil {
+			return fmt.Errorf("failed to restore context key '%s': %w", v.Name(), err)
+		}
+		values[v.Name()] = converted
+	}
+	return nil
+}
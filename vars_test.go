@@ -0,0 +1,92 @@
+package fluo
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func retryDefinition(retries Var[int]) MachineDefinition {
+	return NewMachine().
+		Vars(retries).
+		State("idle").Initial().
+		To("idle").On("retry").Do(func(ctx Context) error {
+		retries.Update(ctx, func(n int) int { return n + 1 })
+		return nil
+	}).
+		To("done").On("finish").
+		State("done").
+		Build()
+}
+
+func TestDeclareVar_InitializedPerInstance(t *testing.T) {
+	retries := DeclareVar("retryCount", 3)
+	definition := retryDefinition(retries)
+
+	first := definition.CreateInstance()
+	second := definition.CreateInstance()
+	_ = first.Start()
+	first.SendEvent("retry", nil)
+	first.SendEvent("retry", nil)
+
+	if got := retries.Of(first); got != 5 {
+		t.Errorf("Expected 5 retries, got %d", got)
+	}
+	if got := retries.Of(second); got != 3 {
+		t.Errorf("Expected the second instance to keep the initial value, got %d", got)
+	}
+
+	_ = first.Reset()
+	if got := retries.Of(first); got != 3 {
+		t.Errorf("Expected Reset to restore the initial value, got %d", got)
+	}
+}
+
+func TestDeclareVar_KeepsTypeAcrossSnapshot(t *testing.T) {
+	retries := DeclareVar("retryCount", 0)
+	definition := retryDefinition(retries)
+
+	machine := definition.CreateInstance()
+	_ = machine.Start()
+	machine.SendEvent("retry", nil)
+
+	snapshot, err := machine.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	decoded := &Snapshot{}
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	restored := definition.CreateInstance()
+	if err := restored.Restore(decoded); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if value, _ := restored.Context().Get("retryCount"); value != 1 {
+		t.Errorf("Expected the restored variable to be the int 1, got %#v", value)
+	}
+}
+
+func TestDeclareVar_Validation(t *testing.T) {
+	_, err := NewMachine().
+		Vars(DeclareVar("count", 0), DeclareVar("count", "")).
+		State("idle").Initial().
+		BuildE()
+
+	var configErr *ConfigurationError
+	if !errors.As(err, &configErr) {
+		t.Errorf("Expected ConfigurationError for a duplicate variable, got %v", err)
+	}
+}
+
+func TestDeclareVar_Described(t *testing.T) {
+	description := Describe(retryDefinition(DeclareVar("retryCount", 2)))
+	if len(description.Vars) != 1 || description.Vars[0].Name != "retryCount" || description.Vars[0].Type != "int" {
+		t.Errorf("Expected the variable in the description, got %+v", description.Vars)
+	}
+}
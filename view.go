@@ -0,0 +1,87 @@
+package fluo
+
+import (
+	"maps"
+	"sync"
+)
+
+// configurationView is an immutable copy of the active configuration. Every
+// writer publishes a new view as it releases the machine mutex, so
+// CurrentState, GetActiveStates, IsStateActive and IsInState read the last
+// committed configuration without contending with event processing.
+type configurationView struct {
+	current string
+	active  []string        // In GetActiveStates order
+	set     map[string]bool // States tracked as active besides the current state
+	regions map[Region]State
+
+	// within lists the states and region paths the configuration is in,
+	// built on first use
+	within func() map[string]bool
+}
+
+// unlock publishes the configuration view and releases the machine mutex
+func (sm *StateMachine) unlock() {
+	sm.publishView()
+	sm.mutex.Unlock()
+}
+
+// publishView swaps in a view of the current configuration.
+// The caller must hold the machine mutex.
+func (sm *StateMachine) publishView() {
+	sm.view.Store(sm.buildView())
+}
+
+// loadView returns the published view, building one under the read lock for
+// a machine that has not published yet
+func (sm *StateMachine) loadView() *configurationView {
+	if view := sm.view.Load(); view != nil {
+		return view
+	}
+
+	sm.mutex.RLock()
+	defer sm.mutex.RUnlock()
+	return sm.buildView()
+}
+
+// buildView copies the current configuration into a view.
+// The caller must hold the machine mutex.
+func (sm *StateMachine) buildView() *configurationView {
+	view := &configurationView{
+		current: sm.currentState,
+		active:  sm.activeStatesLocked(),
+		set:     maps.Clone(sm.activeStates),
+		regions: maps.Clone(sm.regionStates),
+	}
+	view.within = sync.OnceValue(func() map[string]bool {
+		return sm.configurationWithin(view)
+	})
+	return view
+}
+
+// configurationWithin collects the active states of a view, the states and
+// regions enclosing them, and the current states of the regions of every
+// active parallel state. It only reads the immutable state structure besides
+// the view.
+func (sm *StateMachine) configurationWithin(view *configurationView) map[string]bool {
+	within := make(map[string]bool)
+	pending := append([]string{view.current}, view.active...)
+	for len(pending) > 0 {
+		stateID := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		for ; stateID != "" && !within[stateID]; stateID = sm.enclosingState(stateID) {
+			within[stateID] = true
+			if region := sm.findRegionForState(stateID); region != nil {
+				within[regionHistoryKey(region.ParentState().ID(), region)] = true
+			}
+			if parallelState, ok := sm.states[stateID].(ParallelState); ok {
+				for _, region := range parallelState.Regions() {
+					if current := view.regions[region]; current != nil {
+						pending = append(pending, current.ID())
+					}
+				}
+			}
+		}
+	}
+	return within
+}
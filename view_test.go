@@ -0,0 +1,95 @@
+package fluo
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStateQueries_DoNotWaitForTransitions(t *testing.T) {
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	builder := NewMachine()
+	builder.State("idle").Initial().
+		To("busy").On("start").
+		Do(func(ctx Context) error {
+			close(entered)
+			<-release
+			return nil
+		})
+	builder.State("busy")
+	machine := builder.Build().CreateInstance()
+	_ = machine.Start()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		machine.HandleEvent("start", nil)
+	}()
+	<-entered
+
+	queried := make(chan string)
+	go func() {
+		queried <- machine.CurrentState()
+	}()
+	select {
+	case state := <-queried:
+		if state != "idle" {
+			t.Errorf("Expected the last committed state, got %s", state)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected CurrentState not to wait for the running transition")
+	}
+	if !machine.IsStateActive("idle") || !machine.IsInState("idle") || len(machine.GetActiveStates()) != 1 {
+		t.Errorf("Expected the queries to agree on the committed configuration, got %v", machine.GetActiveStates())
+	}
+
+	close(release)
+	<-done
+	AssertState(t, machine, "busy")
+}
+
+func TestStateQueries_ConcurrentWithEvents(t *testing.T) {
+	builder := NewMachine()
+	builder.State("start").Initial().
+		To("work").On("begin")
+	parallel := builder.ParallelState("work")
+	parallel.Region("a").State("idle").Initial().
+		To("done").On("tick")
+	parallel.Region("a").State("done").
+		To("idle").On("tick")
+	parallel.Region("b").State("idle").Initial()
+	machine := builder.Build().CreateInstance()
+	_ = machine.Start()
+	machine.HandleEvent("begin", nil)
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if machine.CurrentState() != "work" {
+					t.Error("Expected the parallel state to stay current")
+					return
+				}
+				if !machine.IsStateActive("work.a.idle") && !machine.IsStateActive("work.a.done") {
+					t.Error("Expected one state of region a to be active")
+					return
+				}
+				_ = machine.GetActiveStates()
+			}
+		}()
+	}
+	for range 200 {
+		machine.HandleEvent("tick", nil)
+	}
+	close(stop)
+	wg.Wait()
+}
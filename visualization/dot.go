@@ -176,6 +176,10 @@ func (g *DOTGenerator) getPseudostateKindName(kind fluo.PseudoStateKind) string
 		return "History"
 	case fluo.DeepHistory:
 		return "Deep History"
+	case fluo.EntryPoint:
+		return "Entry Point"
+	case fluo.ExitPoint:
+		return "Exit Point"
 	default:
 		return "Unknown"
 	}
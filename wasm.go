@@ -0,0 +1,224 @@
+package fluo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// WASM modules provide guards and actions through the following ABI. Every
+// pointer and length is an i32 offset or byte count in the module's exported
+// memory, and the host writes the input of each call, a JSON WASMCall, to
+// memory reserved with fluo_alloc.
+//
+//	fluo_alloc(size i32) -> ptr i32
+//	    Reserves size bytes for the host to write a call input to. Required.
+//	fluo_free(ptr i32, size i32)
+//	    Releases an input or an action result once the host read it. Optional.
+//	guard_<name>(ptr i32, len i32) -> i32
+//	    Evaluates the guard <name> on the input: 1 passes and 0 fails. Any
+//	    other value is an error, which fails the guard.
+//	action_<name>(ptr i32, len i32) -> i64
+//	    Runs the action <name> on the input. 0 succeeds without output;
+//	    otherwise the high 32 bits point to a JSON WASMActionResult and the
+//	    low 32 bits are its length.
+const (
+	WASMAllocExport  = "fluo_alloc"
+	WASMFreeExport   = "fluo_free"
+	WASMGuardPrefix  = "guard_"
+	WASMActionPrefix = "action_"
+)
+
+// WASMInstance is an instantiated WASM module, as provided by a WASM runtime.
+// A wazero api.Module is adapted by listing its ExportedFunctionDefinitions
+// and calling ExportedFunction(name).Call.
+type WASMInstance interface {
+	// ExportedFunctions lists the names of the functions the module exports
+	ExportedFunctions() []string
+	// Call calls an exported function with its i32 and i64 parameters
+	Call(ctx context.Context, name string, params ...uint64) ([]uint64, error)
+	// Memory returns the module's exported memory, nil if it has none
+	Memory() WASMMemory
+}
+
+// WASMMemory is the linear memory of a WASM instance
+type WASMMemory interface {
+	Read(offset, byteCount uint32) ([]byte, bool)
+	Write(offset uint32, data []byte) bool
+}
+
+// WASMCall is the input of a guard or action call
+type WASMCall struct {
+	State string `json:"state"`
+	Event string `json:"event,omitempty"`
+	// Data is the event data, omitted when it does not encode to JSON
+	Data json.RawMessage `json:"data,omitempty"`
+	// Values holds the context values that encode to JSON
+	Values map[string]json.RawMessage `json:"values,omitempty"`
+}
+
+// WASMActionResult is the output of an action
+type WASMActionResult struct {
+	// Set holds the context values the action sets
+	Set map[string]any `json:"set,omitempty"`
+	// Error fails the action with the given message, after Set is applied
+	Error string `json:"error,omitempty"`
+}
+
+// wasmModule binds the exports of a WASM instance to guards and actions
+type wasmModule struct {
+	instance WASMInstance
+	memory   WASMMemory
+	free     bool
+	// WASM instances run one call at a time
+	mutex sync.Mutex
+}
+
+// WASMModule returns a module registering the guards and actions a WASM
+// instance exports under the ABI above, named without their prefix. Calls are
+// serialized, since a WASM instance is not safe for concurrent use.
+func WASMModule(instance WASMInstance) Module {
+	return ModuleFunc(func(r *Registry) error {
+		exports := instance.ExportedFunctions()
+		if !slices.Contains(exports, WASMAllocExport) {
+			return NewConfigurationError("wasm", fmt.Sprintf("module does not export %s", WASMAllocExport))
+		}
+		module := &wasmModule{
+			instance: instance,
+			memory:   instance.Memory(),
+			free:     slices.Contains(exports, WASMFreeExport),
+		}
+		if module.memory == nil {
+			return NewConfigurationError("wasm", "module does not export its memory")
+		}
+
+		for _, export := range exports {
+			if name, ok := strings.CutPrefix(export, WASMGuardPrefix); ok && name != "" {
+				r.RegisterGuard(name, module.guard(export))
+			}
+			if name, ok := strings.CutPrefix(export, WASMActionPrefix); ok && name != "" {
+				r.RegisterAction(name, module.action(export))
+			}
+		}
+		return nil
+	})
+}
+
+// guard calls an exported guard function
+func (m *wasmModule) guard(export string) GuardFunc {
+	return func(ctx Context) bool {
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+		result, err := m.call(ctx, export)
+		return err == nil && uint32(result) == 1
+	}
+}
+
+// action calls an exported action function and applies its result
+func (m *wasmModule) action(export string) ActionFunc {
+	return func(ctx Context) error {
+		m.mutex.Lock()
+		packed, err := m.call(ctx, export)
+		var result *WASMActionResult
+		if err == nil && packed != 0 {
+			result, err = m.result(export, uint32(packed>>32), uint32(packed))
+		}
+		m.mutex.Unlock()
+		if err != nil || result == nil {
+			return err
+		}
+		for key, value := range result.Set {
+			ctx.Set(key, value)
+		}
+		if result.Error != "" {
+			return errors.New(result.Error)
+		}
+		return nil
+	}
+}
+
+// call writes the input of a call to the module's memory and calls the
+// export with it, returning the export's result. The module mutex is held.
+func (m *wasmModule) call(ctx Context, export string) (uint64, error) {
+	input, err := json.Marshal(wasmCallOf(ctx))
+	if err != nil {
+		return 0, err
+	}
+	var goCtx context.Context = context.Background()
+	if ctx != nil {
+		goCtx = ctx
+	}
+
+	size := uint64(len(input))
+	allocated, err := m.instance.Call(goCtx, WASMAllocExport, size)
+	if err != nil {
+		return 0, fmt.Errorf("wasm %s: %w", WASMAllocExport, err)
+	}
+	if len(allocated) != 1 {
+		return 0, fmt.Errorf("wasm %s returned %d results", WASMAllocExport, len(allocated))
+	}
+	ptr := uint64(uint32(allocated[0]))
+	if !m.memory.Write(uint32(ptr), input) {
+		return 0, fmt.Errorf("wasm %s: input at %d is out of memory bounds", export, ptr)
+	}
+
+	results, err := m.instance.Call(goCtx, export, ptr, size)
+	if m.free {
+		_, _ = m.instance.Call(goCtx, WASMFreeExport, ptr, size)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("wasm %s: %w", export, err)
+	}
+	if len(results) != 1 {
+		return 0, fmt.Errorf("wasm %s returned %d results", export, len(results))
+	}
+	return results[0], nil
+}
+
+// result reads and releases the result an action wrote to the module's
+// memory. The module mutex is held.
+func (m *wasmModule) result(export string, ptr, length uint32) (*WASMActionResult, error) {
+	data, ok := m.memory.Read(ptr, length)
+	if !ok {
+		return nil, fmt.Errorf("wasm %s: result at %d is out of memory bounds", export, ptr)
+	}
+	result := &WASMActionResult{}
+	err := json.Unmarshal(data, result)
+	if m.free {
+		_, _ = m.instance.Call(context.Background(), WASMFreeExport, uint64(ptr), uint64(length))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("wasm %s: invalid result: %w", export, err)
+	}
+	return result, nil
+}
+
+// wasmCallOf builds the input of a call from the machine context
+func wasmCallOf(ctx Context) *WASMCall {
+	call := &WASMCall{}
+	if ctx == nil {
+		return call
+	}
+	call.State = ctx.GetCurrentState()
+	call.Event = ctx.GetEventName()
+	if data := ctx.GetEventData(); data != nil {
+		if encoded, err := json.Marshal(data); err == nil {
+			call.Data = encoded
+		}
+	}
+	for key, value := range ctx.GetAll() {
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			continue
+		}
+		if call.Values == nil {
+			call.Values = make(map[string]json.RawMessage)
+		}
+		call.Values[key] = encoded
+	}
+	return call
+}
@@ -0,0 +1,190 @@
+package fluo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// fakeWASM is a WASM instance whose exports are Go functions working on its
+// linear memory the way a compiled module would
+type fakeWASM struct {
+	memory  fakeWASMMemory
+	next    uint32
+	freed   int
+	exports map[string]func(input []byte) uint64
+}
+
+type fakeWASMMemory []byte
+
+func (m fakeWASMMemory) Read(offset, byteCount uint32) ([]byte, bool) {
+	if uint64(offset)+uint64(byteCount) > uint64(len(m)) {
+		return nil, false
+	}
+	return m[offset : offset+byteCount], true
+}
+
+func (m fakeWASMMemory) Write(offset uint32, data []byte) bool {
+	if uint64(offset)+uint64(len(data)) > uint64(len(m)) {
+		return false
+	}
+	copy(m[offset:], data)
+	return true
+}
+
+func (f *fakeWASM) ExportedFunctions() []string {
+	names := []string{WASMAllocExport, WASMFreeExport}
+	for name := range f.exports {
+		names = append(names, name)
+	}
+	return names
+}
+
+func (f *fakeWASM) Memory() WASMMemory {
+	return f.memory
+}
+
+func (f *fakeWASM) alloc(size uint32) uint32 {
+	ptr := f.next
+	f.next += size
+	return ptr
+}
+
+// output writes an action result to memory, returning its packed location
+func (f *fakeWASM) output(result WASMActionResult) uint64 {
+	data, _ := json.Marshal(result)
+	ptr := f.alloc(uint32(len(data)))
+	f.memory.Write(ptr, data)
+	return uint64(ptr)<<32 | uint64(len(data))
+}
+
+func (f *fakeWASM) Call(ctx context.Context, name string, params ...uint64) ([]uint64, error) {
+	switch name {
+	case WASMAllocExport:
+		return []uint64{uint64(f.alloc(uint32(params[0])))}, nil
+	case WASMFreeExport:
+		f.freed++
+		return nil, nil
+	}
+	export, ok := f.exports[name]
+	if !ok {
+		return nil, fmt.Errorf("no export %s", name)
+	}
+	input, _ := f.memory.Read(uint32(params[0]), uint32(params[1]))
+	return []uint64{export(input)}, nil
+}
+
+func newFakeWASM() *fakeWASM {
+	wasm := &fakeWASM{memory: make(fakeWASMMemory, 1<<16)}
+	wasm.exports = map[string]func([]byte) uint64{
+		"guard_isLarge": func(input []byte) uint64 {
+			var call struct {
+				Data   struct{ Amount int }
+				Values map[string]any
+			}
+			if err := json.Unmarshal(input, &call); err != nil {
+				return 2
+			}
+			if call.Data.Amount > 100 {
+				return 1
+			}
+			return 0
+		},
+		"action_approve": func(input []byte) uint64 {
+			var call WASMCall
+			_ = json.Unmarshal(input, &call)
+			return wasm.output(WASMActionResult{Set: map[string]any{"approvedIn": call.State, "approvedOn": call.Event}})
+		},
+		"action_noop": func([]byte) uint64 { return 0 },
+		"action_reject": func([]byte) uint64 {
+			return wasm.output(WASMActionResult{Error: "over budget"})
+		},
+	}
+	return wasm
+}
+
+func TestWASMModule(t *testing.T) {
+	wasm := newFakeWASM()
+	registry := NewRegistry()
+	if err := registry.Import(WASMModule(wasm)); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	definition, err := registry.LoadDefinitionJSON([]byte(`{
+	  "initial": "pending",
+	  "states": [
+	    {"id": "pending", "transitions": [
+	      {"event": "submit", "guard": "isLarge", "target": "review", "action": "approve"},
+	      {"event": "submit", "target": "done", "action": "noop"}
+	    ]},
+	    {"id": "review", "transitions": [{"event": "approve", "target": "done", "action": "reject"}]},
+	    {"id": "done", "type": "final"}
+	  ]
+	}`))
+	if err != nil {
+		t.Fatalf("Expected the WASM guards and actions to resolve, got %v", err)
+	}
+
+	machine := definition.CreateInstance()
+	_ = machine.Start()
+	AssertEventProcessed(t, machine.HandleEvent("submit", map[string]int{"amount": 500}), true)
+	AssertState(t, machine, "review")
+	if state, _ := machine.Context().Get("approvedIn"); state != "pending" {
+		t.Errorf("Expected the action to see its input and set context values, got %v", state)
+	}
+	if event, _ := machine.Context().Get("approvedOn"); event != "submit" {
+		t.Errorf("Expected the action to see the event, got %v", event)
+	}
+
+	result := machine.HandleEvent("approve", nil)
+	if result.Error == nil || !strings.Contains(result.Error.Error(), "over budget") {
+		t.Errorf("Expected the action error to be reported, got %+v", result)
+	}
+
+	small := definition.CreateInstance()
+	_ = small.Start()
+	AssertEventProcessed(t, small.HandleEvent("submit", map[string]int{"amount": 5}), true)
+	AssertState(t, small, "done")
+
+	// Every input and action result was released
+	if wasm.freed != 7 {
+		t.Errorf("Expected 7 buffers to be freed, got %d", wasm.freed)
+	}
+}
+
+func TestWASMModule_RequiresABI(t *testing.T) {
+	wasm := newFakeWASM()
+	err := NewRegistry().Import(WASMModule(restrictedWASM{fakeWASM: wasm, exports: []string{"guard_isLarge"}}))
+	if GetErrorCode(err) != ErrCodeInvalidConfiguration || !strings.Contains(err.Error(), WASMAllocExport) {
+		t.Errorf("Expected a module without %s to be rejected, got %v", WASMAllocExport, err)
+	}
+
+	registry := NewRegistry()
+	err = registry.Import(WASMModule(restrictedWASM{fakeWASM: wasm, exports: wasm.ExportedFunctions(), noMemory: true}))
+	if GetErrorCode(err) != ErrCodeInvalidConfiguration || !strings.Contains(err.Error(), "memory") {
+		t.Errorf("Expected a module without memory to be rejected, got %v", err)
+	}
+	if _, ok := registry.Guard("isLarge"); ok {
+		t.Error("Expected a rejected module to register nothing")
+	}
+}
+
+// restrictedWASM hides exports or the memory of a fake WASM instance
+type restrictedWASM struct {
+	*fakeWASM
+	exports  []string
+	noMemory bool
+}
+
+func (r restrictedWASM) ExportedFunctions() []string {
+	return r.exports
+}
+
+func (r restrictedWASM) Memory() WASMMemory {
+	if r.noMemory {
+		return nil
+	}
+	return r.fakeWASM.Memory()
+}
@@ -0,0 +1,250 @@
+package fluo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Webhook notification types
+const (
+	WebhookTransition    = "transition"
+	WebhookStateEnter    = "state_enter"
+	WebhookEventRejected = "event_rejected"
+)
+
+// WebhookPayload is the JSON body posted by a WebhookObserver
+type WebhookPayload struct {
+	Type      string            `json:"type"`
+	MachineID string            `json:"machine_id"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	From      string            `json:"from,omitempty"`
+	To        string            `json:"to,omitempty"`
+	State     string            `json:"state,omitempty"`
+	Event     string            `json:"event,omitempty"`
+	Reason    string            `json:"reason,omitempty"`
+	Timestamp time.Time         `json:"timestamp"`
+}
+
+// WebhookObserver posts transitions, state entries and rejected events as
+// JSON to one or more URLs. Notifications are queued and delivered in order
+// by a background goroutine, so a slow endpoint never blocks the machine;
+// failed deliveries are retried with exponential backoff. Close the observer
+// to deliver the queued notifications and stop the goroutine.
+type WebhookObserver struct {
+	BaseObserver
+	urls     []string
+	client   *http.Client
+	headers  map[string]string
+	types    map[string]bool
+	attempts int
+	backoff  time.Duration
+	onError  func(payload WebhookPayload, url string, err error)
+
+	queue     chan WebhookPayload
+	done      chan struct{}
+	closeOnce sync.Once
+	mutex     sync.RWMutex
+	closed    bool
+}
+
+// WebhookOption configures a WebhookObserver
+type WebhookOption func(*WebhookObserver)
+
+// WithWebhookClient sets the HTTP client used for deliveries
+func WithWebhookClient(client *http.Client) WebhookOption {
+	return func(o *WebhookObserver) {
+		o.client = client
+	}
+}
+
+// WithWebhookHeader adds a header to every request, such as an authorization token
+func WithWebhookHeader(key, value string) WebhookOption {
+	return func(o *WebhookObserver) {
+		o.headers[key] = value
+	}
+}
+
+// WithWebhookTypes limits notifications to the given types (all by default)
+func WithWebhookTypes(types ...string) WebhookOption {
+	return func(o *WebhookObserver) {
+		o.types = make(map[string]bool, len(types))
+		for _, t := range types {
+			o.types[t] = true
+		}
+	}
+}
+
+// WithWebhookRetry sets the number of delivery attempts per URL and the wait
+// before the first retry, which doubles with every further attempt
+func WithWebhookRetry(attempts int, backoff time.Duration) WebhookOption {
+	return func(o *WebhookObserver) {
+		o.attempts = max(attempts, 1)
+		o.backoff = backoff
+	}
+}
+
+// WithWebhookQueueSize sets how many notifications may wait for delivery.
+// Notifications arriving while the queue is full are dropped and reported.
+func WithWebhookQueueSize(size int) WebhookOption {
+	return func(o *WebhookObserver) {
+		o.queue = make(chan WebhookPayload, size)
+	}
+}
+
+// WithWebhookErrorHandler is called for notifications that could not be
+// delivered to a URL after all attempts, or were dropped from a full queue
+// (with an empty URL)
+func WithWebhookErrorHandler(handler func(payload WebhookPayload, url string, err error)) WebhookOption {
+	return func(o *WebhookObserver) {
+		o.onError = handler
+	}
+}
+
+// NewWebhookObserver creates an observer posting notifications to urls and
+// starts its delivery goroutine
+func NewWebhookObserver(urls []string, opts ...WebhookOption) *WebhookObserver {
+	o := &WebhookObserver{
+		urls:     urls,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		headers:  make(map[string]string),
+		attempts: 3,
+		backoff:  500 * time.Millisecond,
+		queue:    make(chan WebhookPayload, 256),
+		done:     make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	go o.deliverAll()
+	return o
+}
+
+// Close delivers the queued notifications and stops the observer. Later
+// notifications are ignored.
+func (o *WebhookObserver) Close() {
+	o.closeOnce.Do(func() {
+		o.mutex.Lock()
+		o.closed = true
+		close(o.queue)
+		o.mutex.Unlock()
+	})
+	<-o.done
+}
+
+// OnTransition posts a transition notification
+func (o *WebhookObserver) OnTransition(from string, to string, event Event, ctx Context) {
+	o.notify(ctx, WebhookPayload{Type: WebhookTransition, From: from, To: to, Event: eventName(event)})
+}
+
+// OnStateEnter posts a state entry notification
+func (o *WebhookObserver) OnStateEnter(state string, ctx Context) {
+	o.notify(ctx, WebhookPayload{Type: WebhookStateEnter, State: state})
+}
+
+// OnEventRejected posts a rejection notification with its reason
+func (o *WebhookObserver) OnEventRejected(event Event, reason string, ctx Context) {
+	state := ""
+	if ctx != nil {
+		state = ctx.GetCurrentState()
+	}
+	o.notify(ctx, WebhookPayload{Type: WebhookEventRejected, State: state, Event: eventName(event), Reason: reason})
+}
+
+// eventName returns the name of an event, or "" for eventless notifications
+func eventName(event Event) string {
+	if event == nil {
+		return ""
+	}
+	return event.GetName()
+}
+
+// notify queues a notification without blocking
+func (o *WebhookObserver) notify(ctx Context, payload WebhookPayload) {
+	if o.types != nil && !o.types[payload.Type] {
+		return
+	}
+	payload.MachineID = InstanceID(ctx)
+	payload.Metadata = InstanceMetadata(ctx)
+	payload.Timestamp = time.Now()
+
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+	if o.closed {
+		return
+	}
+	select {
+	case o.queue <- payload:
+	default:
+		o.reportError(payload, "", fmt.Errorf("webhook queue is full, dropping %s notification", payload.Type))
+	}
+}
+
+// deliverAll delivers queued notifications until the queue is closed
+func (o *WebhookObserver) deliverAll() {
+	defer close(o.done)
+	for payload := range o.queue {
+		body, err := json.Marshal(payload)
+		if err != nil {
+			o.reportError(payload, "", err)
+			continue
+		}
+		for _, url := range o.urls {
+			if err := o.deliver(url, body); err != nil {
+				o.reportError(payload, url, err)
+			}
+		}
+	}
+}
+
+// deliver posts a notification to a URL, retrying server errors and failed requests
+func (o *WebhookObserver) deliver(url string, body []byte) error {
+	var err error
+	wait := o.backoff
+	for attempt := 1; attempt <= o.attempts; attempt++ {
+		var retry bool
+		if retry, err = o.post(url, body); err == nil || !retry {
+			return err
+		}
+		if attempt < o.attempts {
+			time.Sleep(wait)
+			wait *= 2
+		}
+	}
+	return err
+}
+
+// post sends one request and reports whether a failure is worth retrying
+func (o *WebhookObserver) post(url string, body []byte) (retry bool, err error) {
+	request, err := http.NewRequestWithContext(context.Background(), http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	for key, value := range o.headers {
+		request.Header.Set(key, value)
+	}
+
+	response, err := o.client.Do(request)
+	if err != nil {
+		return true, err
+	}
+	response.Body.Close()
+
+	if response.StatusCode >= 300 {
+		retry = response.StatusCode >= 500 || response.StatusCode == http.StatusTooManyRequests
+		return retry, fmt.Errorf("webhook %s responded %s", url, response.Status)
+	}
+	return false, nil
+}
+
+func (o *WebhookObserver) reportError(payload WebhookPayload, url string, err error) {
+	if o.onError != nil {
+		o.onError(payload, url, err)
+	}
+}
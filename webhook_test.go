@@ -0,0 +1,105 @@
+package fluo
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type webhookRecorder struct {
+	payloads []WebhookPayload
+	headers  []string
+	failures int
+	mutex    sync.Mutex
+}
+
+func (r *webhookRecorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.failures > 0 {
+		r.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	var payload WebhookPayload
+	_ = json.NewDecoder(req.Body).Decode(&payload)
+	r.payloads = append(r.payloads, payload)
+	r.headers = append(r.headers, req.Header.Get("Authorization"))
+}
+
+func TestWebhookObserver_Delivers(t *testing.T) {
+	recorder := &webhookRecorder{failures: 1}
+	server := httptest.NewServer(recorder)
+	defer server.Close()
+
+	observer := NewWebhookObserver([]string{server.URL},
+		WithWebhookTypes(WebhookTransition, WebhookEventRejected),
+		WithWebhookHeader("Authorization", "Bearer token"),
+		WithWebhookRetry(3, time.Millisecond))
+
+	machine := buildEventLogDefinition().CreateInstanceWithID("doc-1")
+	machine.AddObserver(observer)
+	_ = machine.Start()
+	machine.HandleEvent("submit", nil)
+	machine.HandleEvent("unknown", nil)
+	observer.Close()
+
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	if len(recorder.payloads) != 2 {
+		t.Fatalf("Expected 2 notifications, got %+v", recorder.payloads)
+	}
+	transition := recorder.payloads[0]
+	if transition.Type != WebhookTransition || transition.MachineID != "doc-1" || transition.From != "draft" ||
+		transition.To != "review" || transition.Event != "submit" || transition.Timestamp.IsZero() {
+		t.Errorf("Unexpected transition payload: %+v", transition)
+	}
+	if rejection := recorder.payloads[1]; rejection.Type != WebhookEventRejected || rejection.Reason == "" || rejection.State != "review" {
+		t.Errorf("Unexpected rejection payload: %+v", rejection)
+	}
+	if recorder.headers[0] != "Bearer token" {
+		t.Errorf("Expected the configured header, got %q", recorder.headers[0])
+	}
+}
+
+func TestWebhookObserver_ReportsFailures(t *testing.T) {
+	recorder := &webhookRecorder{failures: 10}
+	server := httptest.NewServer(recorder)
+	defer server.Close()
+
+	var mutex sync.Mutex
+	var failed []string
+	observer := NewWebhookObserver([]string{server.URL},
+		WithWebhookTypes(WebhookStateEnter),
+		WithWebhookRetry(2, time.Millisecond),
+		WithWebhookErrorHandler(func(payload WebhookPayload, url string, err error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			failed = append(failed, payload.State)
+		}))
+
+	machine := buildEventLogDefinition().CreateInstance()
+	machine.AddObserver(observer)
+	_ = machine.Start()
+	observer.Close()
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(failed) != 1 || failed[0] != "draft" {
+		t.Errorf("Expected the failed delivery to be reported, got %v", failed)
+	}
+	if recorder.failures != 8 {
+		t.Errorf("Expected 2 attempts, got %d", 10-recorder.failures)
+	}
+}
+
+func TestWebhookObserver_IgnoresNotificationsAfterClose(t *testing.T) {
+	observer := NewWebhookObserver(nil)
+	observer.Close()
+	observer.OnStateEnter("idle", nil)
+	observer.Close()
+}
@@ -0,0 +1,376 @@
+package fluo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// yamlLine is a significant line of a YAML document
+type yamlLine struct {
+	number int
+	indent int
+	text   string
+}
+
+// yamlParser parses the block-style YAML subset used by definition documents:
+// mappings, sequences, plain and quoted scalars, flow sequences and flow
+// mappings, and comments. Anchors, tags, multi-document streams and block
+// scalars are not supported.
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// parseYAML parses a YAML document into maps, slices and scalars
+func parseYAML(data []byte) (any, error) {
+	parser := &yamlParser{}
+	for i, raw := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		text := stripYAMLComment(raw)
+		trimmed := strings.TrimSpace(text)
+		if trimmed == "" || (trimmed == "---" && len(parser.lines) == 0) {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "---") || strings.HasPrefix(trimmed, "...") {
+			return nil, fmt.Errorf("yaml: line %d: multiple documents are not supported", i+1)
+		}
+		indent := len(text) - len(strings.TrimLeft(text, " "))
+		if strings.HasPrefix(text[indent:], "\t") {
+			return nil, fmt.Errorf("yaml: line %d: tabs are not allowed for indentation", i+1)
+		}
+		parser.lines = append(parser.lines, yamlLine{number: i + 1, indent: indent, text: strings.TrimRight(text[indent:], " \t")})
+	}
+
+	if len(parser.lines) == 0 {
+		return nil, nil
+	}
+
+	value, err := parser.parseBlock(parser.lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if parser.pos < len(parser.lines) {
+		line := parser.lines[parser.pos]
+		return nil, fmt.Errorf("yaml: line %d: unexpected indentation", line.number)
+	}
+	return value, nil
+}
+
+// stripYAMLComment removes a trailing comment outside of quotes
+func stripYAMLComment(line string) string {
+	var quote rune
+	for i, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// isSequenceEntry reports whether a line starts a sequence item
+func isSequenceEntry(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// parseBlock parses the mapping or sequence starting at the current line
+func (p *yamlParser) parseBlock(indent int) (any, error) {
+	if isSequenceEntry(p.lines[p.pos].text) {
+		return p.parseSequence(indent)
+	}
+	return p.parseMapping(indent)
+}
+
+// parseSequence parses sequence items at the given indentation
+func (p *yamlParser) parseSequence(indent int) ([]any, error) {
+	items := make([]any, 0)
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent < indent || (line.indent == indent && !isSequenceEntry(line.text)) {
+			break
+		}
+		if line.indent > indent {
+			return nil, fmt.Errorf("yaml: line %d: unexpected indentation", line.number)
+		}
+
+		content := strings.TrimSpace(strings.TrimPrefix(line.text, "-"))
+		if content == "" {
+			p.pos++
+			if p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+				item, err := p.parseBlock(p.lines[p.pos].indent)
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, item)
+			} else {
+				items = append(items, nil)
+			}
+			continue
+		}
+
+		if _, _, isPair := splitYAMLPair(content); isPair || isSequenceEntry(content) {
+			// The item is a nested block starting on the same line as the dash
+			offset := len(line.text) - len(strings.TrimLeft(line.text[1:], " "))
+			p.lines[p.pos] = yamlLine{number: line.number, indent: indent + offset, text: content}
+			item, err := p.parseBlock(indent + offset)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+			continue
+		}
+
+		value, err := parseYAMLScalar(content, line.number)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, value)
+		p.pos++
+	}
+	return items, nil
+}
+
+// parseMapping parses mapping entries at the given indentation
+func (p *yamlParser) parseMapping(indent int) (map[string]any, error) {
+	values := make(map[string]any)
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent < indent {
+			break
+		}
+		if line.indent > indent || isSequenceEntry(line.text) {
+			return nil, fmt.Errorf("yaml: line %d: unexpected indentation", line.number)
+		}
+
+		key, rest, isPair := splitYAMLPair(line.text)
+		if !isPair {
+			return nil, fmt.Errorf("yaml: line %d: expected a 'key: value' pair", line.number)
+		}
+		if _, exists := values[key]; exists {
+			return nil, fmt.Errorf("yaml: line %d: duplicate key '%s'", line.number, key)
+		}
+		p.pos++
+
+		if rest != "" {
+			value, err := parseYAMLScalar(rest, line.number)
+			if err != nil {
+				return nil, err
+			}
+			values[key] = value
+			continue
+		}
+
+		if p.pos < len(p.lines) {
+			next := p.lines[p.pos]
+			if next.indent > indent || (next.indent == indent && isSequenceEntry(next.text)) {
+				value, err := p.parseBlock(next.indent)
+				if err != nil {
+					return nil, err
+				}
+				values[key] = value
+				continue
+			}
+		}
+		values[key] = nil
+	}
+	return values, nil
+}
+
+// splitYAMLPair splits a "key: value" line outside of quotes
+func splitYAMLPair(text string) (string, string, bool) {
+	var quote rune
+	for i, r := range text {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			if i == 0 {
+				quote = r
+			}
+		case r == '[' || r == '{':
+			if i == 0 {
+				return "", "", false
+			}
+		case r == ':' && (i == len(text)-1 || text[i+1] == ' '):
+			key := strings.TrimSpace(text[:i])
+			if unquoted, err := unquoteYAML(key); err == nil {
+				key = unquoted
+			}
+			return key, strings.TrimSpace(text[i+1:]), true
+		}
+	}
+	return "", "", false
+}
+
+// unquoteYAML removes single or double quotes from a scalar
+func unquoteYAML(text string) (string, error) {
+	if len(text) >= 2 && text[0] == '"' && text[len(text)-1] == '"' {
+		return strconv.Unquote(text)
+	}
+	if len(text) >= 2 && text[0] == '\'' && text[len(text)-1] == '\'' {
+		return strings.ReplaceAll(text[1:len(text)-1], "''", "'"), nil
+	}
+	return text, nil
+}
+
+// parseYAMLScalar parses a scalar or flow collection
+func parseYAMLScalar(text string, lineNumber int) (any, error) {
+	if strings.HasPrefix(text, "[") || strings.HasPrefix(text, "{") {
+		flow := &yamlFlowParser{text: text, line: lineNumber}
+		value, err := flow.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		flow.skipSpaces()
+		if flow.pos != len(flow.text) {
+			return nil, fmt.Errorf("yaml: line %d: unexpected characters after flow collection", lineNumber)
+		}
+		return value, nil
+	}
+	if strings.HasPrefix(text, "|") || strings.HasPrefix(text, ">") {
+		return nil, fmt.Errorf("yaml: line %d: block scalars are not supported", lineNumber)
+	}
+	if strings.HasPrefix(text, "&") || strings.HasPrefix(text, "*") || strings.HasPrefix(text, "!") {
+		return nil, fmt.Errorf("yaml: line %d: anchors, aliases and tags are not supported", lineNumber)
+	}
+	if strings.HasPrefix(text, "\"") || strings.HasPrefix(text, "'") {
+		value, err := unquoteYAML(text)
+		if err != nil {
+			return nil, fmt.Errorf("yaml: line %d: invalid quoted string", lineNumber)
+		}
+		return value, nil
+	}
+
+	switch text {
+	case "~", "null", "Null", "NULL":
+		return nil, nil
+	case "true", "True", "TRUE":
+		return true, nil
+	case "false", "False", "FALSE":
+		return false, nil
+	}
+	if i, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return i, nil
+	}
+	if f, err := strconv.ParseFloat(text, 64); err == nil {
+		return f, nil
+	}
+	return text, nil
+}
+
+// yamlFlowParser parses flow collections such as [a, b] and {key: value}
+type yamlFlowParser struct {
+	text string
+	pos  int
+	line int
+}
+
+// skipSpaces advances past whitespace
+func (f *yamlFlowParser) skipSpaces() {
+	for f.pos < len(f.text) && f.text[f.pos] == ' ' {
+		f.pos++
+	}
+}
+
+// parseValue parses a flow collection or scalar
+func (f *yamlFlowParser) parseValue() (any, error) {
+	f.skipSpaces()
+	if f.pos >= len(f.text) {
+		return nil, fmt.Errorf("yaml: line %d: unexpected end of flow collection", f.line)
+	}
+
+	switch f.text[f.pos] {
+	case '[':
+		f.pos++
+		items := make([]any, 0)
+		for {
+			f.skipSpaces()
+			if f.pos < len(f.text) && f.text[f.pos] == ']' {
+				f.pos++
+				return items, nil
+			}
+			item, err := f.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+			if err := f.parseSeparator(']'); err != nil {
+				return nil, err
+			}
+			if f.text[f.pos-1] == ']' {
+				return items, nil
+			}
+		}
+	case '{':
+		f.pos++
+		values := make(map[string]any)
+		for {
+			f.skipSpaces()
+			if f.pos < len(f.text) && f.text[f.pos] == '}' {
+				f.pos++
+				return values, nil
+			}
+			key := f.scanScalar(":")
+			if f.pos >= len(f.text) || f.text[f.pos] != ':' {
+				return nil, fmt.Errorf("yaml: line %d: expected ':' in flow mapping", f.line)
+			}
+			f.pos++
+			value, err := f.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			unquoted, err := unquoteYAML(key)
+			if err != nil {
+				return nil, fmt.Errorf("yaml: line %d: invalid quoted key", f.line)
+			}
+			values[unquoted] = value
+			if err := f.parseSeparator('}'); err != nil {
+				return nil, err
+			}
+			if f.text[f.pos-1] == '}' {
+				return values, nil
+			}
+		}
+	}
+
+	return parseYAMLScalar(f.scanScalar(",]}"), f.line)
+}
+
+// parseSeparator consumes a comma or the closing delimiter
+func (f *yamlFlowParser) parseSeparator(closing byte) error {
+	f.skipSpaces()
+	if f.pos < len(f.text) && (f.text[f.pos] == ',' || f.text[f.pos] == closing) {
+		f.pos++
+		return nil
+	}
+	return fmt.Errorf("yaml: line %d: expected ',' or '%c' in flow collection", f.line, closing)
+}
+
+// scanScalar reads a scalar up to one of the terminators, honoring quotes
+func (f *yamlFlowParser) scanScalar(terminators string) string {
+	f.skipSpaces()
+	start := f.pos
+	var quote byte
+	for f.pos < len(f.text) {
+		c := f.text[f.pos]
+		if quote != 0 {
+			if c == quote {
+				quote = 0
+			}
+		} else if c == '"' || c == '\'' {
+			quote = c
+		} else if strings.IndexByte(terminators, c) >= 0 {
+			break
+		}
+		f.pos++
+	}
+	return strings.TrimSpace(f.text[start:f.pos])
+}
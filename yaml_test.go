@@ -0,0 +1,62 @@
+package fluo
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseYAML(t *testing.T) {
+	value, err := parseYAML([]byte(`
+name: "order # 1"   # trailing comment
+count: 3
+ratio: 0.5
+enabled: true
+empty: ~
+tags: [a, 'b c', {k: v}]
+items:
+- id: first
+  nested:
+    - x
+    -
+      deep: yes
+- plain
+`))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	expected := map[string]any{
+		"name":    "order # 1",
+		"count":   int64(3),
+		"ratio":   0.5,
+		"enabled": true,
+		"empty":   nil,
+		"tags":    []any{"a", "b c", map[string]any{"k": "v"}},
+		"items": []any{
+			map[string]any{
+				"id":     "first",
+				"nested": []any{"x", map[string]any{"deep": "yes"}},
+			},
+			"plain",
+		},
+	}
+	if !reflect.DeepEqual(value, expected) {
+		t.Errorf("Expected %#v, got %#v", expected, value)
+	}
+}
+
+func TestParseYAML_Errors(t *testing.T) {
+	documents := []string{
+		"a: 1\n  b: 2\n",
+		"a: 1\na: 2\n",
+		"a: |\n  text\n",
+		"a: [1, 2\n",
+		"just text\n",
+	}
+
+	for _, document := range documents {
+		if _, err := parseYAML([]byte(document)); err == nil {
+			t.Errorf("Expected error parsing %q", document)
+		}
+	}
+}